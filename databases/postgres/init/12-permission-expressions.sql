@@ -0,0 +1,20 @@
+-- 权限表达式支持：角色继承与策略版本号
+-- 权限编码支持通配与显式拒绝（company:*、!company:delete），角色可通过extends_role继承父角色的全部权限
+-- 角色/权限变更时递增zervigo_auth_policy_version.version，各服务据此清空本地权限缓存
+
+-- 1. 角色继承
+ALTER TABLE zervigo_auth_roles ADD COLUMN IF NOT EXISTS extends_role VARCHAR(50) DEFAULT '';
+COMMENT ON COLUMN zervigo_auth_roles.extends_role IS '父角色名称，继承其全部权限';
+
+UPDATE zervigo_auth_roles SET extends_role = 'guest' WHERE role_name = 'user' AND COALESCE(extends_role, '') = '';
+UPDATE zervigo_auth_roles SET extends_role = 'user' WHERE role_name = 'admin' AND COALESCE(extends_role, '') = '';
+
+-- 2. 策略版本号
+CREATE TABLE IF NOT EXISTS zervigo_auth_policy_version (
+    id INTEGER PRIMARY KEY,
+    version BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO zervigo_auth_policy_version (id, version) VALUES (1, 1)
+ON CONFLICT (id) DO NOTHING;
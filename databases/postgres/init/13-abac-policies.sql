@@ -0,0 +1,28 @@
+-- 基于属性的访问控制（ABAC）规则表
+-- 规则按资源类型和操作（支持通配）匹配，condition为条件表达式，例如：
+--   subject.company_role == "admin" && action != "set_legal_representative"
+--   subject.user_id != resource.owner_id && intersects(subject.company_ids, resource.blacklisted_companies)
+-- 拒绝规则优先，没有匹配的允许规则时默认拒绝；各服务启动时若表为空会写入本服务的默认规则
+-- 试运行：POST /api/v1/policies/explain（简历服务）、POST /api/v1/company/auth/policies/explain（企业服务）
+
+CREATE TABLE IF NOT EXISTS zervigo_abac_policies (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    resource VARCHAR(50) NOT NULL,
+    actions VARCHAR(500) NOT NULL,
+    effect VARCHAR(10) NOT NULL CHECK (effect IN ('allow', 'deny')),
+    condition TEXT,
+    priority BIGINT DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_zervigo_abac_policies_resource ON zervigo_abac_policies(resource);
+
+COMMENT ON TABLE zervigo_abac_policies IS '基于属性的访问控制规则';
+COMMENT ON COLUMN zervigo_abac_policies.resource IS '资源类型，如company、resume，*表示全部';
+COMMENT ON COLUMN zervigo_abac_policies.actions IS '逗号分隔的操作，支持通配，如view_*';
+COMMENT ON COLUMN zervigo_abac_policies.condition IS '条件表达式，为空时恒成立';
+COMMENT ON COLUMN zervigo_abac_policies.priority IS '数值越大越先评估';
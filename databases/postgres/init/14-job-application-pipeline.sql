@@ -0,0 +1,33 @@
+-- 职位申请招聘流程
+-- 申请阶段保存在 zervigo_job_applications.application_stage，默认流程：
+--   applied -> screening -> interview -> offer -> hired，任意进行中阶段可转为 rejected
+-- 职位可通过 PUT /api/v1/jobs/:id/pipeline 自定义阶段与允许的阶段变更，未配置时使用默认流程
+-- 每次阶段变更写入 zervigo_job_application_stage_history，并投递到通知服务 /api/v1/events/application-stage-changed
+
+CREATE TABLE IF NOT EXISTS zervigo_job_pipelines (
+    job_id BIGINT PRIMARY KEY REFERENCES zervigo_jobs(id) ON DELETE CASCADE,
+    config TEXT NOT NULL,
+    updated_by BIGINT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS zervigo_job_application_stage_history (
+    id BIGSERIAL PRIMARY KEY,
+    application_id BIGINT NOT NULL REFERENCES zervigo_job_applications(id) ON DELETE CASCADE,
+    job_id BIGINT NOT NULL,
+    from_stage VARCHAR(50),
+    to_stage VARCHAR(50) NOT NULL,
+    changed_by BIGINT,
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_application_stage_history_application_id ON zervigo_job_application_stage_history(application_id);
+CREATE INDEX IF NOT EXISTS idx_job_application_stage_history_job_id ON zervigo_job_application_stage_history(job_id);
+CREATE INDEX IF NOT EXISTS idx_job_applications_stage ON zervigo_job_applications(job_id, application_stage);
+
+-- 历史数据阶段为空的按已申请处理
+UPDATE zervigo_job_applications SET application_stage = 'applied' WHERE application_stage IS NULL OR application_stage = '';
+
+COMMENT ON TABLE zervigo_job_pipelines IS '职位自定义招聘流程（JSON：stages、transitions、closed）';
+COMMENT ON TABLE zervigo_job_application_stage_history IS '职位申请阶段变更记录';
@@ -0,0 +1,13 @@
+-- 职位全文搜索索引
+-- search_vector 由 job-service 在创建/更新职位的同一事务中写入：
+--   标题(A)、技能/公司/分类(B)、要求与职责(C)、描述(D)
+-- 分词在服务端完成（拉丁词转小写，中文按二元组切分），数据库使用 simple 配置，
+-- 因此不依赖 zhparser 等中文分词扩展；存量数据在服务启动时自动补建索引
+-- 搜索接口：GET /api/v1/jobs/search?keyword=&category=&workType=&location=&salaryBand=&experience=&sort=
+
+ALTER TABLE zervigo_jobs ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE INDEX IF NOT EXISTS idx_zervigo_jobs_search_vector ON zervigo_jobs USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_zervigo_jobs_status ON zervigo_jobs(status);
+
+COMMENT ON COLUMN zervigo_jobs.search_vector IS '职位全文索引（由job-service维护）';
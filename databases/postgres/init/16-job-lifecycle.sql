@@ -0,0 +1,33 @@
+-- 职位生命周期调度
+-- job-service 后台调度器按 publish_at 定时发布草稿，按 expire_at 到期关闭，
+-- 录用人数达到 headcount 时关闭，并在到期前（默认72小时）提醒职位创建者
+-- 多副本部署时通过 zervigo_scheduler_leases 中的租约保证同一时间只有一个副本执行调度
+
+ALTER TABLE zervigo_jobs ADD COLUMN IF NOT EXISTS headcount INTEGER DEFAULT 0;
+COMMENT ON COLUMN zervigo_jobs.headcount IS '招聘人数，录用满额后自动关闭，0表示不限';
+
+CREATE INDEX IF NOT EXISTS idx_zervigo_jobs_publish_at ON zervigo_jobs(publish_at) WHERE status = 'draft';
+CREATE INDEX IF NOT EXISTS idx_zervigo_jobs_expire_at ON zervigo_jobs(expire_at);
+
+CREATE TABLE IF NOT EXISTS zervigo_job_status_transitions (
+    id BIGSERIAL PRIMARY KEY,
+    job_id BIGINT NOT NULL REFERENCES zervigo_jobs(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    reason VARCHAR(50) NOT NULL, -- scheduled_publish, expired, headcount_filled, expiry_reminder
+    detail VARCHAR(200),
+    triggered_by VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_zervigo_job_status_transitions_job_id ON zervigo_job_status_transitions(job_id);
+
+CREATE TABLE IF NOT EXISTS zervigo_scheduler_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(200) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE zervigo_job_status_transitions IS '职位自动状态变更与到期提醒记录';
+COMMENT ON TABLE zervigo_scheduler_leases IS '后台调度租约，多副本部署时保证单实例执行';
@@ -0,0 +1,39 @@
+-- 保存的搜索与职位提醒
+-- 用户保存搜索条件后，新职位发布时匹配命中记录到 zervigo_job_alert_matches，
+-- instant 订阅立即提醒，daily/weekly 订阅由 job-service 调度器汇总后投递到通知服务
+-- 提醒中的退订链接使用 zervigo_job_alert_tokens 中的令牌，无需登录
+
+CREATE TABLE IF NOT EXISTS zervigo_job_saved_searches (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    criteria TEXT, -- JSON: keyword, category, workType, location, salaryBand, experience
+    frequency VARCHAR(20) NOT NULL, -- instant, daily, weekly
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_notified_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_zervigo_job_saved_searches_user_id ON zervigo_job_saved_searches(user_id);
+
+CREATE TABLE IF NOT EXISTS zervigo_job_alert_matches (
+    id BIGSERIAL PRIMARY KEY,
+    saved_search_id BIGINT NOT NULL REFERENCES zervigo_job_saved_searches(id) ON DELETE CASCADE,
+    job_id BIGINT NOT NULL REFERENCES zervigo_jobs(id) ON DELETE CASCADE,
+    matched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    notified_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_alert_match ON zervigo_job_alert_matches(saved_search_id, job_id);
+CREATE INDEX IF NOT EXISTS idx_zervigo_job_alert_matches_notified_at ON zervigo_job_alert_matches(notified_at);
+
+CREATE TABLE IF NOT EXISTS zervigo_job_alert_tokens (
+    user_id BIGINT PRIMARY KEY,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE zervigo_job_saved_searches IS '用户保存的职位搜索及提醒频率';
+COMMENT ON TABLE zervigo_job_alert_matches IS '保存的搜索命中的职位，notified_at 为空表示待提醒';
+COMMENT ON TABLE zervigo_job_alert_tokens IS '职位提醒退订令牌';
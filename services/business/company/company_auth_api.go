@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/szjason72/zervigo/shared/core"
+	"gorm.io/gorm"
 )
 
 // CompanyAuthAPI 企业认证API
@@ -56,9 +57,21 @@ func (api *CompanyAuthAPI) SetupCompanyAuthRoutes(r *gin.Engine) {
 		auth.GET("/sync/:company_id/status", api.getSyncStatus)
 		auth.POST("/sync/:company_id/check", api.checkDataConsistency)
 
+		// 同步发件箱运维API
+		auth.POST("/outbox/replay", api.replayCompanySync)
+		auth.GET("/outbox/stats", api.getOutboxStats)
+		auth.GET("/outbox/dead-letters", api.getDeadLetters)
+
+		// 跨存储对账API
+		auth.POST("/reconcile", api.runReconciliation)
+		auth.GET("/reconcile/reports", api.getReconcileReports)
+
 		// 权限审计API
 		auth.GET("/audit/:company_id", api.getPermissionAuditLogs)
 	}
+
+	// 访问策略管理与试运行API
+	api.permissionManager.Policies().RegisterRoutes(auth)
 }
 
 // addAuthorizedUser 添加授权用户
@@ -93,8 +106,8 @@ func (api *CompanyAuthAPI) addAuthorizedUser(c *gin.Context) {
 		return
 	}
 
-	// 触发数据同步
-	go api.dataSyncService.SyncCompanyData(req.CompanyID)
+	// 唤醒同步投递器
+	api.dataSyncService.NotifyRelay()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -167,8 +180,8 @@ func (api *CompanyAuthAPI) removeAuthorizedUser(c *gin.Context) {
 		return
 	}
 
-	// 触发数据同步
-	go api.dataSyncService.SyncCompanyData(uint(companyID))
+	// 唤醒同步投递器
+	api.dataSyncService.NotifyRelay()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -218,8 +231,8 @@ func (api *CompanyAuthAPI) updateUserRole(c *gin.Context) {
 		return
 	}
 
-	// 触发数据同步
-	go api.dataSyncService.SyncCompanyData(uint(companyID))
+	// 唤醒同步投递器
+	api.dataSyncService.NotifyRelay()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -262,8 +275,8 @@ func (api *CompanyAuthAPI) setLegalRepresentative(c *gin.Context) {
 		return
 	}
 
-	// 触发数据同步
-	go api.dataSyncService.SyncCompanyData(uint(companyID))
+	// 唤醒同步投递器
+	api.dataSyncService.NotifyRelay()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -376,13 +389,19 @@ func (api *CompanyAuthAPI) updateCompanyAuthInfo(c *gin.Context) {
 	company.SetAuthInfo(&authInfo)
 	company.UpdatedAt = time.Now()
 
-	if err := db.Save(&company).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&company).Error; err != nil {
+			return err
+		}
+		return AppendCompanySyncEvent(tx, company.ID, CompanySyncEventCompanyUpdated)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新企业认证信息失败"})
 		return
 	}
 
-	// 触发数据同步
-	go api.dataSyncService.SyncCompanyData(uint(companyID))
+	// 唤醒同步投递器
+	api.dataSyncService.NotifyRelay()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
@@ -461,13 +480,19 @@ func (api *CompanyAuthAPI) updateCompanyLocation(c *gin.Context) {
 	company.SetLocationInfo(&locationInfo)
 	company.UpdatedAt = time.Now()
 
-	if err := db.Save(&company).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&company).Error; err != nil {
+			return err
+		}
+		return AppendCompanySyncEvent(tx, company.ID, CompanySyncEventCompanyUpdated)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新企业地理位置失败"})
 		return
 	}
 
-	// 触发数据同步
-	go api.dataSyncService.SyncCompanyData(uint(companyID))
+	// 唤醒同步投递器
+	api.dataSyncService.NotifyRelay()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
@@ -529,15 +554,15 @@ func (api *CompanyAuthAPI) syncCompanyData(c *gin.Context) {
 		return
 	}
 
-	// 执行数据同步
-	if err := api.dataSyncService.SyncCompanyData(uint(companyID)); err != nil {
+	// 追加重放事件，由同步投递器异步完成同步与重试
+	if _, err := api.dataSyncService.ReplayCompanies([]uint{uint(companyID)}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusAccepted, gin.H{
 		"status":  "success",
-		"message": "企业数据同步成功",
+		"message": "企业数据同步已加入队列",
 	})
 }
 
@@ -594,18 +619,21 @@ func (api *CompanyAuthAPI) checkDataConsistency(c *gin.Context) {
 		return
 	}
 
-	// 检查数据一致性
-	if err := api.dataSyncService.CheckDataConsistency(uint(companyID)); err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "inconsistent",
-			"error":  err.Error(),
-		})
+	// 检查数据一致性，repair=true 时以MySQL为准将差异加入同步队列
+	repair, _ := strconv.ParseBool(c.Query("repair"))
+	report, err := api.dataSyncService.CheckDataConsistency(uint(companyID), repair)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	status := "consistent"
+	if !report.Consistent() {
+		status = "inconsistent"
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "consistent",
-		"message": "数据一致性检查通过",
+		"status": status,
+		"data":   report,
 	})
 }
 
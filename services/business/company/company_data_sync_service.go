@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/szjason72/zervigo/shared/core/reconcile"
 	"gorm.io/gorm"
 )
 
@@ -19,6 +21,10 @@ type CompanyDataSyncService struct {
 	postgresDB  *gorm.DB
 	neo4jDriver neo4j.Driver
 	redisClient *redis.Client
+	relayWake   chan struct{}
+
+	reconcilerOnce sync.Once
+	reconciler     *reconcile.Engine
 }
 
 // NewCompanyDataSyncService 创建企业数据同步服务
@@ -28,6 +34,15 @@ func NewCompanyDataSyncService(mysqlDB *gorm.DB, postgresDB *gorm.DB, neo4jDrive
 		postgresDB:  postgresDB,
 		neo4jDriver: neo4jDriver,
 		redisClient: redisClient,
+		relayWake:   make(chan struct{}, 1),
+	}
+}
+
+// NotifyRelay 唤醒发件箱投递器，写操作事务提交后调用
+func (s *CompanyDataSyncService) NotifyRelay() {
+	select {
+	case s.relayWake <- struct{}{}:
+	default:
 	}
 }
 
@@ -39,31 +54,89 @@ func (s *CompanyDataSyncService) SyncCompanyData(companyID uint) error {
 		return fmt.Errorf("获取企业数据失败: %v", err)
 	}
 
-	// 2. 同步到PostgreSQL（职位相关数据）
-	if err := s.syncToPostgreSQL(company); err != nil {
-		log.Printf("同步到PostgreSQL失败: %v", err)
-		s.updateSyncStatus(companyID, SyncTargetPostgreSQL, SyncStatusFailed, err.Error())
-	} else {
-		s.updateSyncStatus(companyID, SyncTargetPostgreSQL, SyncStatusSuccess, "")
+	// 2. 依次同步到PostgreSQL、Neo4j、Redis
+	for _, target := range companySyncTargets {
+		if err := s.syncTarget(target, company); err != nil {
+			log.Printf("同步到%s失败: %v", target, err)
+			s.updateSyncStatus(companyID, target, SyncStatusFailed, err.Error())
+		} else {
+			s.updateSyncStatus(companyID, target, SyncStatusSuccess, "")
+		}
 	}
 
-	// 3. 同步到Neo4j（地理位置和关系数据）
-	if err := s.syncToNeo4j(company); err != nil {
-		log.Printf("同步到Neo4j失败: %v", err)
-		s.updateSyncStatus(companyID, SyncTargetNeo4j, SyncStatusFailed, err.Error())
-	} else {
-		s.updateSyncStatus(companyID, SyncTargetNeo4j, SyncStatusSuccess, "")
-	}
+	return nil
+}
 
-	// 4. 同步到Redis（缓存数据）
-	if err := s.syncToRedis(company); err != nil {
-		log.Printf("同步到Redis失败: %v", err)
-		s.updateSyncStatus(companyID, SyncTargetRedis, SyncStatusFailed, err.Error())
-	} else {
-		s.updateSyncStatus(companyID, SyncTargetRedis, SyncStatusSuccess, "")
-	}
+// targetConfigured 同步目标是否已配置连接
+func (s *CompanyDataSyncService) targetConfigured(target SyncTarget) bool {
+	switch target {
+	case SyncTargetPostgreSQL:
+		return s.postgresDB != nil
+	case SyncTargetNeo4j:
+		return s.neo4jDriver != nil
+	case SyncTargetRedis:
+		return s.redisClient != nil
+	}
+	return false
+}
 
-	return nil
+// syncTarget 将企业当前状态写入单个目标，所有写入均为幂等的覆盖写
+func (s *CompanyDataSyncService) syncTarget(target SyncTarget, company EnhancedCompany) error {
+	switch target {
+	case SyncTargetPostgreSQL:
+		return s.syncToPostgreSQL(company)
+	case SyncTargetNeo4j:
+		return s.syncToNeo4j(company)
+	case SyncTargetRedis:
+		return s.syncToRedis(company)
+	}
+	return fmt.Errorf("未知的同步目标: %s", target)
+}
+
+// removeFromTarget 企业已删除时从目标中移除其数据
+func (s *CompanyDataSyncService) removeFromTarget(target SyncTarget, companyID uint) error {
+	switch target {
+	case SyncTargetPostgreSQL:
+		if s.postgresDB == nil {
+			return fmt.Errorf("PostgreSQL连接未初始化")
+		}
+		return s.postgresDB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM company_users WHERE company_id = ?", companyID).Error; err != nil {
+				return fmt.Errorf("删除PostgreSQL企业用户关联失败: %v", err)
+			}
+			if err := tx.Exec("DELETE FROM companies WHERE id = ?", companyID).Error; err != nil {
+				return fmt.Errorf("删除PostgreSQL企业信息失败: %v", err)
+			}
+			return nil
+		})
+	case SyncTargetNeo4j:
+		if s.neo4jDriver == nil {
+			return fmt.Errorf("Neo4j连接未初始化")
+		}
+		session := s.neo4jDriver.NewSession(neo4j.SessionConfig{})
+		defer session.Close()
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			return nil, runNeo4jWrite(tx, "MATCH (c:Company {id: $id}) DETACH DELETE c", map[string]interface{}{"id": companyID})
+		})
+		if err != nil {
+			return fmt.Errorf("删除Neo4j企业节点失败: %v", err)
+		}
+		return nil
+	case SyncTargetRedis:
+		if s.redisClient == nil {
+			return fmt.Errorf("Redis连接未初始化")
+		}
+		keys := []string{
+			fmt.Sprintf("company:%d", companyID),
+			fmt.Sprintf("company_permissions:%d", companyID),
+			fmt.Sprintf("company_location:%d", companyID),
+		}
+		if err := s.redisClient.Del(context.Background(), keys...).Err(); err != nil {
+			return fmt.Errorf("删除企业缓存失败: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的同步目标: %s", target)
 }
 
 // syncToPostgreSQL 同步到PostgreSQL
@@ -129,45 +202,58 @@ func (s *CompanyDataSyncService) syncToPostgreSQL(company EnhancedCompany) error
 			updated_at = EXCLUDED.updated_at
 	`
 
-	if err := s.postgresDB.Exec(query, companyInfo).Error; err != nil {
-		return fmt.Errorf("同步企业信息到PostgreSQL失败: %v", err)
-	}
-
-	// 同步企业用户关联信息
-	for _, companyUser := range company.CompanyUsers {
-		userInfo := map[string]interface{}{
-			"id":          companyUser.ID,
-			"company_id":  companyUser.CompanyID,
-			"user_id":     companyUser.UserID,
-			"role":        companyUser.Role,
-			"status":      companyUser.Status,
-			"permissions": companyUser.Permissions,
-			"created_at":  companyUser.CreatedAt,
-			"updated_at":  companyUser.UpdatedAt,
+	return s.postgresDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(query, companyInfo).Error; err != nil {
+			return fmt.Errorf("同步企业信息到PostgreSQL失败: %v", err)
 		}
 
-		userQuery := `
-			INSERT INTO company_users (
-				id, company_id, user_id, role, status, permissions, created_at, updated_at
-			) VALUES (
-				@id, @company_id, @user_id, @role, @status, @permissions, @created_at, @updated_at
-			)
-			ON CONFLICT (id) DO UPDATE SET
-				role = EXCLUDED.role,
-				status = EXCLUDED.status,
-				permissions = EXCLUDED.permissions,
-				updated_at = EXCLUDED.updated_at
-		`
-
-		if err := s.postgresDB.Exec(userQuery, userInfo).Error; err != nil {
-			log.Printf("同步企业用户关联到PostgreSQL失败: %v", err)
+		// 先删除MySQL中已不存在的企业用户关联，再逐条覆盖写
+		userIDs := make([]uint, 0, len(company.CompanyUsers))
+		for _, companyUser := range company.CompanyUsers {
+			userIDs = append(userIDs, companyUser.ID)
+		}
+		staleQuery := tx.Where("company_id = ?", company.ID)
+		if len(userIDs) > 0 {
+			staleQuery = staleQuery.Where("id NOT IN ?", userIDs)
+		}
+		if err := staleQuery.Delete(&CompanyUser{}).Error; err != nil {
+			return fmt.Errorf("清理PostgreSQL企业用户关联失败: %v", err)
 		}
-	}
 
-	return nil
+		for _, companyUser := range company.CompanyUsers {
+			userInfo := map[string]interface{}{
+				"id":          companyUser.ID,
+				"company_id":  companyUser.CompanyID,
+				"user_id":     companyUser.UserID,
+				"role":        companyUser.Role,
+				"status":      companyUser.Status,
+				"permissions": companyUser.Permissions,
+				"created_at":  companyUser.CreatedAt,
+				"updated_at":  companyUser.UpdatedAt,
+			}
+
+			userQuery := `
+				INSERT INTO company_users (
+					id, company_id, user_id, role, status, permissions, created_at, updated_at
+				) VALUES (
+					@id, @company_id, @user_id, @role, @status, @permissions, @created_at, @updated_at
+				)
+				ON CONFLICT (id) DO UPDATE SET
+					role = EXCLUDED.role,
+					status = EXCLUDED.status,
+					permissions = EXCLUDED.permissions,
+					updated_at = EXCLUDED.updated_at
+			`
+
+			if err := tx.Exec(userQuery, userInfo).Error; err != nil {
+				return fmt.Errorf("同步企业用户关联到PostgreSQL失败: %v", err)
+			}
+		}
+		return nil
+	})
 }
 
-// syncToNeo4j 同步到Neo4j
+// syncToNeo4j 同步到Neo4j，所有语句在同一个写事务中执行
 func (s *CompanyDataSyncService) syncToNeo4j(company EnhancedCompany) error {
 	if s.neo4jDriver == nil {
 		return fmt.Errorf("Neo4j连接未初始化")
@@ -176,7 +262,7 @@ func (s *CompanyDataSyncService) syncToNeo4j(company EnhancedCompany) error {
 	session := s.neo4jDriver.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
-	// 创建或更新企业节点
+	// 创建或更新企业节点；可选字段为空时写入 null 以移除旧值
 	query := `
 		MERGE (c:Company {id: $id})
 		SET c.name = $name,
@@ -197,7 +283,16 @@ func (s *CompanyDataSyncService) syncToNeo4j(company EnhancedCompany) error {
 			c.view_count = $view_count,
 			c.created_by = $created_by,
 			c.created_at = $created_at,
-			c.updated_at = $updated_at
+			c.updated_at = $updated_at,
+			c.bd_latitude = $bd_latitude,
+			c.bd_longitude = $bd_longitude,
+			c.bd_altitude = $bd_altitude,
+			c.bd_accuracy = $bd_accuracy,
+			c.bd_timestamp = $bd_timestamp,
+			c.address = $address,
+			c.city = $city,
+			c.district = $district,
+			c.area = $area
 	`
 
 	params := map[string]interface{}{
@@ -221,105 +316,120 @@ func (s *CompanyDataSyncService) syncToNeo4j(company EnhancedCompany) error {
 		"created_by":                 company.CreatedBy,
 		"created_at":                 company.CreatedAt.Unix(),
 		"updated_at":                 company.UpdatedAt.Unix(),
+		"bd_latitude":                nil,
+		"bd_longitude":               nil,
+		"bd_altitude":                nil,
+		"bd_accuracy":                nil,
+		"bd_timestamp":               nil,
+		"address":                    nullableString(company.Address),
+		"city":                       nullableString(company.City),
+		"district":                   nullableString(company.District),
+		"area":                       nullableString(company.Area),
 	}
 
 	// 添加地理位置信息
 	if company.BDLatitude != nil && company.BDLongitude != nil {
-		query += `, c.bd_latitude = $bd_latitude, c.bd_longitude = $bd_longitude`
 		params["bd_latitude"] = *company.BDLatitude
 		params["bd_longitude"] = *company.BDLongitude
-
 		if company.BDAltitude != nil {
-			query += `, c.bd_altitude = $bd_altitude`
 			params["bd_altitude"] = *company.BDAltitude
 		}
 		if company.BDAccuracy != nil {
-			query += `, c.bd_accuracy = $bd_accuracy`
 			params["bd_accuracy"] = *company.BDAccuracy
 		}
 		if company.BDTimestamp != nil {
-			query += `, c.bd_timestamp = $bd_timestamp`
 			params["bd_timestamp"] = *company.BDTimestamp
 		}
 	}
 
-	// 添加地址信息
-	if company.Address != "" {
-		query += `, c.address = $address`
-		params["address"] = company.Address
-	}
-	if company.City != "" {
-		query += `, c.city = $city`
-		params["city"] = company.City
-	}
-	if company.District != "" {
-		query += `, c.district = $district`
-		params["district"] = company.District
-	}
-	if company.Area != "" {
-		query += `, c.area = $area`
-		params["area"] = company.Area
-	}
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		if err := runNeo4jWrite(tx, query, params); err != nil {
+			return nil, fmt.Errorf("同步企业节点到Neo4j失败: %v", err)
+		}
 
-	_, err := session.Run(query, params)
-	if err != nil {
-		return fmt.Errorf("同步企业节点到Neo4j失败: %v", err)
-	}
-
-	// 创建地理位置关系
-	if company.City != "" && company.District != "" {
-		locationQuery := `
-			MATCH (c:Company {id: $company_id})
-			MERGE (city:City {name: $city})
-			MERGE (district:District {name: $district})
-			MERGE (city)-[:CONTAINS]->(district)
-			MERGE (c)-[:LOCATED_IN]->(district)
-			MERGE (c)-[:IN_CITY]->(city)
-		`
-
-		locationParams := map[string]interface{}{
-			"company_id": company.ID,
-			"city":       company.City,
-			"district":   company.District,
+		// 重建地理位置关系，避免地址变更后残留旧关系
+		if err := runNeo4jWrite(tx, `
+			MATCH (c:Company {id: $company_id})-[r:LOCATED_IN|IN_CITY]->()
+			DELETE r
+		`, map[string]interface{}{"company_id": company.ID}); err != nil {
+			return nil, fmt.Errorf("清理地理位置关系失败: %v", err)
 		}
 
-		if company.Area != "" {
-			locationQuery += `
-				MERGE (area:Area {name: $area})
-				MERGE (district)-[:CONTAINS]->(area)
-				MERGE (c)-[:LOCATED_IN]->(area)
+		if company.City != "" && company.District != "" {
+			locationQuery := `
+				MATCH (c:Company {id: $company_id})
+				MERGE (city:City {name: $city})
+				MERGE (district:District {name: $district})
+				MERGE (city)-[:CONTAINS]->(district)
+				MERGE (c)-[:LOCATED_IN]->(district)
+				MERGE (c)-[:IN_CITY]->(city)
 			`
-			locationParams["area"] = company.Area
-		}
 
-		_, err := session.Run(locationQuery, locationParams)
-		if err != nil {
-			log.Printf("创建地理位置关系失败: %v", err)
+			locationParams := map[string]interface{}{
+				"company_id": company.ID,
+				"city":       company.City,
+				"district":   company.District,
+			}
+
+			if company.Area != "" {
+				locationQuery += `
+					MERGE (area:Area {name: $area})
+					MERGE (district)-[:CONTAINS]->(area)
+					MERGE (c)-[:LOCATED_IN]->(area)
+				`
+				locationParams["area"] = company.Area
+			}
+
+			if err := runNeo4jWrite(tx, locationQuery, locationParams); err != nil {
+				return nil, fmt.Errorf("创建地理位置关系失败: %v", err)
+			}
 		}
-	}
 
-	// 创建企业用户关系
-	for _, companyUser := range company.CompanyUsers {
-		userQuery := `
-			MATCH (c:Company {id: $company_id})
-			MERGE (u:User {id: $user_id})
-			MERGE (u)-[:WORKS_FOR {role: $role, status: $status}]->(c)
-		`
-
-		userParams := map[string]interface{}{
-			"company_id": company.ID,
-			"user_id":    companyUser.UserID,
-			"role":       companyUser.Role,
-			"status":     companyUser.Status,
+		// 删除已不在企业中的用户关系；关系按 (用户, 企业) 唯一，角色与状态作为属性覆盖写
+		userIDs := make([]int64, 0, len(company.CompanyUsers))
+		for _, companyUser := range company.CompanyUsers {
+			userIDs = append(userIDs, int64(companyUser.UserID))
+		}
+		if err := runNeo4jWrite(tx, `
+			MATCH (u:User)-[r:WORKS_FOR]->(c:Company {id: $company_id})
+			WHERE NOT u.id IN $user_ids
+			DELETE r
+		`, map[string]interface{}{"company_id": company.ID, "user_ids": userIDs}); err != nil {
+			return nil, fmt.Errorf("清理企业用户关系失败: %v", err)
 		}
 
-		_, err := session.Run(userQuery, userParams)
-		if err != nil {
-			log.Printf("创建企业用户关系失败: %v", err)
+		for _, companyUser := range company.CompanyUsers {
+			userQuery := `
+				MATCH (c:Company {id: $company_id})
+				MERGE (u:User {id: $user_id})
+				MERGE (u)-[r:WORKS_FOR]->(c)
+				SET r.role = $role, r.status = $status
+			`
+
+			userParams := map[string]interface{}{
+				"company_id": company.ID,
+				"user_id":    int64(companyUser.UserID),
+				"role":       companyUser.Role,
+				"status":     companyUser.Status,
+			}
+
+			if err := runNeo4jWrite(tx, userQuery, userParams); err != nil {
+				return nil, fmt.Errorf("创建企业用户关系失败: %v", err)
+			}
 		}
-	}
+		return nil, nil
+	})
+	return err
+}
 
-	return nil
+// runNeo4jWrite 执行写语句并消费结果，确保服务端错误能够返回
+func runNeo4jWrite(tx neo4j.Transaction, query string, params map[string]interface{}) error {
+	result, err := tx.Run(query, params)
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
 }
 
 // syncToRedis 同步到Redis
@@ -386,33 +496,38 @@ func (s *CompanyDataSyncService) syncToRedis(company EnhancedCompany) error {
 		return fmt.Errorf("缓存权限数据失败: %v", err)
 	}
 
-	// 缓存地理位置信息
-	if company.BDLatitude != nil && company.BDLongitude != nil {
-		locationKey := fmt.Sprintf("company_location:%d", company.ID)
-		locationData := map[string]interface{}{
-			"latitude":      *company.BDLatitude,
-			"longitude":     *company.BDLongitude,
-			"altitude":      getFloat64Value(company.BDAltitude),
-			"accuracy":      getFloat64Value(company.BDAccuracy),
-			"timestamp":     getInt64Value(company.BDTimestamp),
-			"address":       company.Address,
-			"city":          company.City,
-			"district":      company.District,
-			"area":          company.Area,
-			"postal_code":   company.PostalCode,
-			"city_code":     company.CityCode,
-			"district_code": company.DistrictCode,
-			"area_code":     company.AreaCode,
-		}
-
-		locationJSON, err := json.Marshal(locationData)
-		if err != nil {
-			return fmt.Errorf("序列化地理位置数据失败: %v", err)
+	// 缓存地理位置信息，位置被清除时删除旧缓存
+	locationKey := fmt.Sprintf("company_location:%d", company.ID)
+	if company.BDLatitude == nil || company.BDLongitude == nil {
+		if err := s.redisClient.Del(context.Background(), locationKey).Err(); err != nil {
+			return fmt.Errorf("删除地理位置缓存失败: %v", err)
 		}
+		return nil
+	}
+
+	locationData := map[string]interface{}{
+		"latitude":      *company.BDLatitude,
+		"longitude":     *company.BDLongitude,
+		"altitude":      getFloat64Value(company.BDAltitude),
+		"accuracy":      getFloat64Value(company.BDAccuracy),
+		"timestamp":     getInt64Value(company.BDTimestamp),
+		"address":       company.Address,
+		"city":          company.City,
+		"district":      company.District,
+		"area":          company.Area,
+		"postal_code":   company.PostalCode,
+		"city_code":     company.CityCode,
+		"district_code": company.DistrictCode,
+		"area_code":     company.AreaCode,
+	}
+
+	locationJSON, err := json.Marshal(locationData)
+	if err != nil {
+		return fmt.Errorf("序列化地理位置数据失败: %v", err)
+	}
 
-		if err := s.redisClient.Set(context.Background(), locationKey, locationJSON, time.Hour).Err(); err != nil {
-			return fmt.Errorf("缓存地理位置数据失败: %v", err)
-		}
+	if err := s.redisClient.Set(context.Background(), locationKey, locationJSON, time.Hour).Err(); err != nil {
+		return fmt.Errorf("缓存地理位置数据失败: %v", err)
 	}
 
 	return nil
@@ -443,47 +558,9 @@ func (s *CompanyDataSyncService) updateSyncStatus(companyID uint, target SyncTar
 		FirstOrCreate(&syncStatus)
 }
 
-// CheckDataConsistency 检查数据一致性
-func (s *CompanyDataSyncService) CheckDataConsistency(companyID uint) error {
-	// 1. 检查MySQL核心数据
-	var company EnhancedCompany
-	if err := s.mysqlDB.First(&company, companyID).Error; err != nil {
-		return fmt.Errorf("MySQL数据缺失: %v", err)
-	}
-
-	// 2. 检查PostgreSQL数据
-	if s.postgresDB != nil {
-		var count int64
-		s.postgresDB.Table("companies").Where("id = ?", companyID).Count(&count)
-		if count == 0 {
-			return fmt.Errorf("PostgreSQL数据缺失")
-		}
-	}
-
-	// 3. 检查Neo4j数据
-	if s.neo4jDriver != nil {
-		session := s.neo4jDriver.NewSession(neo4j.SessionConfig{})
-		defer session.Close()
-
-		result, err := session.Run("MATCH (c:Company {id: $id}) RETURN c", map[string]interface{}{"id": companyID})
-		if err != nil {
-			return fmt.Errorf("Neo4j数据检查失败: %v", err)
-		}
-
-		if !result.Next() {
-			return fmt.Errorf("Neo4j数据缺失")
-		}
-	}
-
-	// 4. 检查Redis缓存
-	if s.redisClient != nil {
-		companyKey := fmt.Sprintf("company:%d", companyID)
-		if _, err := s.redisClient.Get(context.Background(), companyKey).Result(); err != nil {
-			return fmt.Errorf("Redis缓存缺失: %v", err)
-		}
-	}
-
-	return nil
+// CheckDataConsistency 逐字段比较企业在各数据库中的内容，返回差异报告
+func (s *CompanyDataSyncService) CheckDataConsistency(companyID uint, repair bool) (*reconcile.Report, error) {
+	return s.ReconcileCompany(companyID, repair)
 }
 
 // GetSyncStatus 获取同步状态
@@ -726,3 +803,11 @@ func getInt64Value(ptr *int64) int64 {
 	}
 	return *ptr
 }
+
+// nullableString 空字符串转为 nil，写入 Neo4j 时用于移除属性
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/szjason72/zervigo/shared/core"
+	"gorm.io/gorm"
 )
 
 // JobData 职位数据模型（PostgreSQL）
@@ -48,15 +49,15 @@ func setupCompanyEnhancedRoutes(r *gin.Engine, core *jobfirst.Core, dataSyncServ
 					return
 				}
 
-				// 同步到所有数据库
-				if err := dataSyncService.SyncCompanyData(uint(companyID)); err != nil {
+				// 追加重放事件，由同步投递器同步到所有数据库
+				if _, err := dataSyncService.ReplayCompanies([]uint{uint(companyID)}); err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "同步失败: " + err.Error()})
 					return
 				}
 
-				c.JSON(http.StatusOK, gin.H{
+				c.JSON(http.StatusAccepted, gin.H{
 					"status":     "success",
-					"message":    "企业数据同步成功",
+					"message":    "企业数据同步已加入队列",
 					"company_id": companyID,
 					"timestamp":  time.Now(),
 				})
@@ -251,10 +252,17 @@ func setupCompanyEnhancedRoutes(r *gin.Engine, core *jobfirst.Core, dataSyncServ
 				company.AreaCode = updateData.AreaCode
 				company.UpdatedAt = time.Now()
 
-				if err := core.GetDB().Save(&company).Error; err != nil {
+				err = core.GetDB().Transaction(func(tx *gorm.DB) error {
+					if err := tx.Save(&company).Error; err != nil {
+						return err
+					}
+					return AppendCompanySyncEvent(tx, company.ID, CompanySyncEventCompanyUpdated)
+				})
+				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "更新地理位置信息失败"})
 					return
 				}
+				dataSyncService.NotifyRelay()
 
 				c.JSON(http.StatusOK, gin.H{
 					"status":  "success",
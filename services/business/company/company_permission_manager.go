@@ -2,14 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"github.com/szjason72/zervigo/shared/core/policy"
 	"gorm.io/gorm"
 )
 
@@ -17,105 +19,50 @@ import (
 type CompanyPermissionManager struct {
 	mysqlDB     *gorm.DB
 	redisClient *redis.Client
-	cacheTTL    time.Duration
+	policies    *policy.Middleware
 }
 
 // NewCompanyPermissionManager 创建企业权限管理器
 func NewCompanyPermissionManager(mysqlDB *gorm.DB, redisClient *redis.Client) *CompanyPermissionManager {
-	return &CompanyPermissionManager{
+	cpm := &CompanyPermissionManager{
 		mysqlDB:     mysqlDB,
 		redisClient: redisClient,
-		cacheTTL:    time.Hour, // 缓存1小时
+		policies:    newCompanyPolicyMiddleware(mysqlDB),
 	}
+	cpm.policies.RegisterResource(companyResourceType, cpm.resolveCompany)
+	cpm.policies.AddSubjectEnricher(cpm.enrichCompanySubject)
+	return cpm
 }
 
-// CheckCompanyAccess 检查企业访问权限
+// CheckCompanyAccess 检查企业访问权限，由访问策略按用户在企业中的角色与操作决定
 func (cpm *CompanyPermissionManager) CheckCompanyAccess(userID uint, companyID uint, action string, c *gin.Context) bool {
-	// 1. 尝试从缓存获取权限
-	cacheKey := fmt.Sprintf("company_permission:%d:%d:%s", userID, companyID, action)
-	if cpm.redisClient != nil {
-		if cached, err := cpm.redisClient.Get(context.Background(), cacheKey).Result(); err == nil {
-			if cached == "true" {
-				cpm.logPermissionCheck(userID, companyID, action, true, c)
-				return true
-			}
-			cpm.logPermissionCheck(userID, companyID, action, false, c)
-			return false
-		}
-	}
-
-	// 2. 检查系统管理员权限
-	var user User
-	if err := cpm.mysqlDB.First(&user, userID).Error; err == nil {
-		if user.Role == "admin" || user.Role == "super_admin" {
-			if cpm.redisClient != nil {
-				cpm.redisClient.Set(context.Background(), cacheKey, "true", cpm.cacheTTL)
-			}
-			cpm.logPermissionCheck(userID, companyID, action, true, c)
-			return true
+	decision, err := cpm.policies.Check(c, companyResourceType, strconv.FormatUint(uint64(companyID), 10), action, nil)
+	if err != nil {
+		if errors.Is(err, policy.ErrResourceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "企业不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "权限检查失败: " + err.Error()})
 		}
-	}
-
-	// 3. 检查企业权限
-	var company EnhancedCompany
-	if err := cpm.mysqlDB.First(&company, companyID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "企业不存在"})
 		cpm.logPermissionCheck(userID, companyID, action, false, c)
 		return false
 	}
 
-	// 4. 检查企业创建者权限
-	if company.CreatedBy == userID {
-		if cpm.redisClient != nil {
-			cpm.redisClient.Set(context.Background(), cacheKey, "true", cpm.cacheTTL)
-		}
-		cpm.logPermissionCheck(userID, companyID, action, true, c)
-		return true
-	}
-
-	// 5. 检查法定代表人权限
-	if company.LegalRepUserID == userID {
-		if cpm.redisClient != nil {
-			cpm.redisClient.Set(context.Background(), cacheKey, "true", cpm.cacheTTL)
-		}
-		cpm.logPermissionCheck(userID, companyID, action, true, c)
-		return true
-	}
-
-	// 6. 检查企业用户关联权限
-	var companyUser CompanyUser
-	if err := cpm.mysqlDB.Where("company_id = ? AND user_id = ? AND status = ?",
-		companyID, userID, "active").First(&companyUser).Error; err == nil {
-		if cpm.redisClient != nil {
-			cpm.redisClient.Set(context.Background(), cacheKey, "true", cpm.cacheTTL)
-		}
-		cpm.logPermissionCheck(userID, companyID, action, true, c)
-		return true
+	if !decision.Allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，您没有执行该操作的权限",
+			"reason": decision.Reason,
+		})
+		cpm.logPermissionCheck(userID, companyID, action, false, c)
+		return false
 	}
 
-	// 7. 检查JSON授权用户列表
-	if company.AuthorizedUsers != "" {
-		var authorizedUsers []uint
-		if err := json.Unmarshal([]byte(company.AuthorizedUsers), &authorizedUsers); err == nil {
-			for _, authorizedUserID := range authorizedUsers {
-				if authorizedUserID == userID {
-					if cpm.redisClient != nil {
-						cpm.redisClient.Set(context.Background(), cacheKey, "true", cpm.cacheTTL)
-					}
-					cpm.logPermissionCheck(userID, companyID, action, true, c)
-					return true
-				}
-			}
-		}
-	}
+	cpm.logPermissionCheck(userID, companyID, action, true, c)
+	return true
+}
 
-	// 8. 权限不足
-	if cpm.redisClient != nil {
-		cpm.redisClient.Set(context.Background(), cacheKey, "false", cpm.cacheTTL)
-	}
-	c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，您没有访问该企业的权限"})
-	cpm.logPermissionCheck(userID, companyID, action, false, c)
-	return false
+// Policies 企业访问控制中间件（用于注册策略管理与试运行接口）
+func (cpm *CompanyPermissionManager) Policies() *policy.Middleware {
+	return cpm.policies
 }
 
 // GetUserCompanyPermissions 获取用户的企业权限列表
@@ -227,8 +174,14 @@ func (cpm *CompanyPermissionManager) AddAuthorizedUser(companyID uint, userID ui
 	}
 	companyUser.SetPermissions(permissions)
 
-	if err := cpm.mysqlDB.Create(&companyUser).Error; err != nil {
-		return fmt.Errorf("添加授权用户失败: %v", err)
+	err := cpm.mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&companyUser).Error; err != nil {
+			return fmt.Errorf("添加授权用户失败: %v", err)
+		}
+		return AppendCompanySyncEvent(tx, companyID, CompanySyncEventUsersChanged)
+	})
+	if err != nil {
+		return err
 	}
 
 	// 清除相关缓存
@@ -240,8 +193,14 @@ func (cpm *CompanyPermissionManager) AddAuthorizedUser(companyID uint, userID ui
 // RemoveAuthorizedUser 移除授权用户
 func (cpm *CompanyPermissionManager) RemoveAuthorizedUser(companyID uint, userID uint) error {
 	// 删除企业用户关联
-	if err := cpm.mysqlDB.Where("company_id = ? AND user_id = ?", companyID, userID).Delete(&CompanyUser{}).Error; err != nil {
-		return fmt.Errorf("移除授权用户失败: %v", err)
+	err := cpm.mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("company_id = ? AND user_id = ?", companyID, userID).Delete(&CompanyUser{}).Error; err != nil {
+			return fmt.Errorf("移除授权用户失败: %v", err)
+		}
+		return AppendCompanySyncEvent(tx, companyID, CompanySyncEventUsersChanged)
+	})
+	if err != nil {
+		return err
 	}
 
 	// 清除相关缓存
@@ -261,8 +220,14 @@ func (cpm *CompanyPermissionManager) UpdateUserRole(companyID uint, userID uint,
 	companyUser.SetPermissions(permissions)
 	companyUser.UpdatedAt = time.Now()
 
-	if err := cpm.mysqlDB.Save(&companyUser).Error; err != nil {
-		return fmt.Errorf("更新用户角色失败: %v", err)
+	err := cpm.mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&companyUser).Error; err != nil {
+			return fmt.Errorf("更新用户角色失败: %v", err)
+		}
+		return AppendCompanySyncEvent(tx, companyID, CompanySyncEventUsersChanged)
+	})
+	if err != nil {
+		return err
 	}
 
 	// 清除相关缓存
@@ -285,40 +250,46 @@ func (cpm *CompanyPermissionManager) SetLegalRepresentative(companyID uint, user
 		return fmt.Errorf("用户不存在: %v", err)
 	}
 
-	// 更新企业法定代表人
+	// 更新企业法定代表人，并确保用户在企业用户关联表中
 	company.LegalRepUserID = userID
 	company.UpdatedAt = time.Now()
 
-	if err := cpm.mysqlDB.Save(&company).Error; err != nil {
-		return fmt.Errorf("设置法定代表人失败: %v", err)
-	}
-
-	// 确保用户在企业用户关联表中
-	var companyUser CompanyUser
-	if err := cpm.mysqlDB.Where("company_id = ? AND user_id = ?", companyID, userID).First(&companyUser).Error; err != nil {
-		// 如果不存在，创建关联
-		companyUser = CompanyUser{
-			CompanyID: companyID,
-			UserID:    userID,
-			Role:      string(RoleLegalRepresentative),
-			Status:    "active",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+	err := cpm.mysqlDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&company).Error; err != nil {
+			return fmt.Errorf("设置法定代表人失败: %v", err)
 		}
-		companyUser.SetPermissions([]string{"read", "write", "manage_users"})
 
-		if err := cpm.mysqlDB.Create(&companyUser).Error; err != nil {
-			return fmt.Errorf("创建企业用户关联失败: %v", err)
-		}
-	} else {
-		// 如果存在，更新角色
-		companyUser.Role = string(RoleLegalRepresentative)
-		companyUser.SetPermissions([]string{"read", "write", "manage_users"})
-		companyUser.UpdatedAt = time.Now()
-
-		if err := cpm.mysqlDB.Save(&companyUser).Error; err != nil {
-			return fmt.Errorf("更新企业用户关联失败: %v", err)
+		var companyUser CompanyUser
+		if err := tx.Where("company_id = ? AND user_id = ?", companyID, userID).First(&companyUser).Error; err != nil {
+			// 如果不存在，创建关联
+			companyUser = CompanyUser{
+				CompanyID: companyID,
+				UserID:    userID,
+				Role:      string(RoleLegalRepresentative),
+				Status:    "active",
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+			companyUser.SetPermissions([]string{"read", "write", "manage_users"})
+
+			if err := tx.Create(&companyUser).Error; err != nil {
+				return fmt.Errorf("创建企业用户关联失败: %v", err)
+			}
+		} else {
+			// 如果存在，更新角色
+			companyUser.Role = string(RoleLegalRepresentative)
+			companyUser.SetPermissions([]string{"read", "write", "manage_users"})
+			companyUser.UpdatedAt = time.Now()
+
+			if err := tx.Save(&companyUser).Error; err != nil {
+				return fmt.Errorf("更新企业用户关联失败: %v", err)
+			}
 		}
+
+		return AppendCompanySyncEvent(tx, companyID, CompanySyncEventCompanyUpdated)
+	})
+	if err != nil {
+		return err
 	}
 
 	// 清除相关缓存
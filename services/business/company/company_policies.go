@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/policy"
+	"gorm.io/gorm"
+)
+
+// companyResourceType 企业资源在访问策略中的类型
+const companyResourceType = "company"
+
+// companyOwnerRole 企业创建者在策略中的企业角色
+const companyOwnerRole = string(PermissionCompanyOwner)
+
+// defaultCompanyPolicies 默认企业访问策略（规则表中还没有企业规则时写入，之后以数据库为准）
+func defaultCompanyPolicies() []policy.Policy {
+	return []policy.Policy{
+		{
+			Name:        "company-system-admin",
+			Description: "系统管理员可以管理所有企业",
+			Resource:    companyResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.role in ["admin", "super_admin"]`,
+			Priority:    100,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-owner",
+			Description: "企业创建者拥有企业的全部权限",
+			Resource:    companyResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.company_role == "company_owner"`,
+			Priority:    50,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-legal-rep",
+			Description: "法定代表人拥有企业的全部权限",
+			Resource:    companyResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.company_role == "legal_rep"`,
+			Priority:    50,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-admin",
+			Description: "企业管理员不能变更法定代表人",
+			Resource:    companyResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.company_role == "admin" && action != "set_legal_representative"`,
+			Priority:    40,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-authorized-user-view",
+			Description: "授权用户可以查看企业信息",
+			Resource:    companyResourceType,
+			Actions:     "view_*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.company_role == "authorized_user"`,
+			Priority:    30,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-granted-action",
+			Description: "企业用户被单独授予的操作",
+			Resource:    companyResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectAllow,
+			Condition:   `action in subject.company_permissions`,
+			Priority:    20,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-granted-read",
+			Description: "兼容旧权限：read 可以查看企业信息",
+			Resource:    companyResourceType,
+			Actions:     "view_*",
+			Effect:      policy.EffectAllow,
+			Condition:   `"read" in subject.company_permissions`,
+			Priority:    20,
+			Enabled:     true,
+		},
+		{
+			Name:        "company-granted-manage-users",
+			Description: "兼容旧权限：manage_users 可以管理授权用户",
+			Resource:    companyResourceType,
+			Actions:     "add_authorized_user,remove_authorized_user,update_user_role,view_authorized_users",
+			Effect:      policy.EffectAllow,
+			Condition:   `"manage_users" in subject.company_permissions`,
+			Priority:    20,
+			Enabled:     true,
+		},
+	}
+}
+
+// newCompanyPolicyMiddleware 创建企业访问控制中间件，规则表不可用时使用内存中的默认规则
+func newCompanyPolicyMiddleware(db *gorm.DB) *policy.Middleware {
+	var engine *policy.Engine
+	store, err := policy.NewGormStore(db)
+	if err == nil {
+		engine, err = policy.NewEngine(store)
+	}
+	if err == nil {
+		err = engine.SeedDefaults(defaultCompanyPolicies())
+	}
+	if err != nil {
+		log.Printf("初始化访问策略失败，使用默认规则: %v", err)
+		engine, _ = policy.NewEngine(policy.NewMemoryStore(defaultCompanyPolicies()))
+	}
+	return policy.NewMiddleware(engine)
+}
+
+// resolveCompany 企业资源属性
+func (cpm *CompanyPermissionManager) resolveCompany(c *gin.Context, resourceID string) (policy.Attributes, error) {
+	id, err := strconv.ParseUint(resourceID, 10, 64)
+	if err != nil {
+		return nil, policy.ErrResourceNotFound
+	}
+
+	var company EnhancedCompany
+	if err := cpm.mysqlDB.First(&company, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, policy.ErrResourceNotFound
+		}
+		return nil, err
+	}
+
+	authorizedUsers := []uint{}
+	if company.AuthorizedUsers != "" {
+		json.Unmarshal([]byte(company.AuthorizedUsers), &authorizedUsers)
+	}
+
+	return policy.Attributes{
+		"owner_id":           company.CreatedBy,
+		"company_id":         company.ID,
+		"legal_rep_user_id":  company.LegalRepUserID,
+		"status":             company.Status,
+		"verification_level": company.VerificationLevel,
+		"authorized_users":   authorizedUsers,
+	}, nil
+}
+
+// enrichCompanySubject 补充当前用户在企业中的角色与被授予的操作
+func (cpm *CompanyPermissionManager) enrichCompanySubject(c *gin.Context, subject, resource policy.Attributes) error {
+	userID, _ := subject["user_id"].(uint)
+	if userID == 0 {
+		return nil
+	}
+
+	// 认证中间件未提供系统角色时从用户表读取
+	if role, _ := subject["role"].(string); role == "" {
+		var user User
+		if err := cpm.mysqlDB.Select("role").First(&user, userID).Error; err == nil {
+			subject["role"] = user.Role
+		}
+	}
+
+	if resource["type"] != companyResourceType {
+		return nil
+	}
+	subject["company_role"] = ""
+	subject["company_permissions"] = []string{}
+
+	if ownerID, _ := resource["owner_id"].(uint); ownerID == userID {
+		subject["company_role"] = companyOwnerRole
+		return nil
+	}
+	if legalRepID, _ := resource["legal_rep_user_id"].(uint); legalRepID == userID {
+		subject["company_role"] = string(RoleLegalRepresentative)
+		return nil
+	}
+
+	var companyUser CompanyUser
+	err := cpm.mysqlDB.Where("company_id = ? AND user_id = ? AND status = ?",
+		resource["company_id"], userID, "active").First(&companyUser).Error
+	if err == nil {
+		subject["company_role"] = companyUser.Role
+		if permissions := companyUser.GetPermissions(); permissions != nil {
+			subject["company_permissions"] = permissions
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// 旧数据：企业JSON授权用户列表中的用户按授权用户处理
+	authorizedUsers, _ := resource["authorized_users"].([]uint)
+	for _, authorizedUserID := range authorizedUsers {
+		if authorizedUserID == userID {
+			subject["company_role"] = string(RoleAuthorizedUser)
+			break
+		}
+	}
+	return nil
+}
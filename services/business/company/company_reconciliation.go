@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/szjason72/zervigo/shared/core/reconcile"
+	"gorm.io/gorm"
+)
+
+const (
+	companyReconcileEntity          = "company"
+	defaultCompanyReconcileInterval = 6 * time.Hour
+)
+
+// companyBaseFields 各目标都保存的企业字段；job_count、view_count 等计数会被直接更新、不经过同步，不参与对账
+var companyBaseFields = []string{
+	"name", "short_name", "industry", "company_size", "location", "website", "description",
+	"founded_year", "unified_social_credit_code", "legal_representative", "legal_representative_id",
+	"legal_rep_user_id", "status", "verification_level", "created_by",
+}
+
+// companyReconcileFields 企业的规范化字段，users 为 "用户ID:角色:状态" 的有序集合
+func companyReconcileFields(company EnhancedCompany) map[string]string {
+	users := make([]string, 0, len(company.CompanyUsers))
+	for _, companyUser := range company.CompanyUsers {
+		users = append(users, companyUserKey(companyUser.UserID, companyUser.Role, companyUser.Status))
+	}
+
+	return map[string]string{
+		"name":                       company.Name,
+		"short_name":                 company.ShortName,
+		"industry":                   company.Industry,
+		"company_size":               company.CompanySize,
+		"location":                   company.Location,
+		"website":                    company.Website,
+		"description":                company.Description,
+		"founded_year":               reconcile.Int(int64(company.FoundedYear)),
+		"unified_social_credit_code": company.UnifiedSocialCreditCode,
+		"legal_representative":       company.LegalRepresentative,
+		"legal_representative_id":    company.LegalRepresentativeID,
+		"legal_rep_user_id":          reconcile.Uint(company.LegalRepUserID),
+		"status":                     company.Status,
+		"verification_level":         company.VerificationLevel,
+		"created_by":                 reconcile.Uint(company.CreatedBy),
+		"bd_latitude":                reconcile.OptionalFloat(company.BDLatitude),
+		"bd_longitude":               reconcile.OptionalFloat(company.BDLongitude),
+		"address":                    company.Address,
+		"city":                       company.City,
+		"district":                   company.District,
+		"area":                       company.Area,
+		"users":                      reconcile.SortedList(users),
+	}
+}
+
+func companyUserKey(userID uint, role, status string) string {
+	return fmt.Sprintf("%d:%s:%s", userID, role, status)
+}
+
+// pickFields 只保留目标实际保存的字段
+func pickFields(fields map[string]string, names []string) map[string]string {
+	picked := make(map[string]string, len(names))
+	for _, name := range names {
+		picked[name] = fields[name]
+	}
+	return picked
+}
+
+// Reconciler 企业跨存储对账引擎（MySQL为数据源），修复通过发件箱重放完成
+func (s *CompanyDataSyncService) Reconciler() *reconcile.Engine {
+	s.reconcilerOnce.Do(func() {
+		var targets []reconcile.Target
+		if s.postgresDB != nil {
+			targets = append(targets, &companyPostgresTarget{db: s.postgresDB})
+		}
+		if s.neo4jDriver != nil {
+			targets = append(targets, &companyNeo4jTarget{driver: s.neo4jDriver})
+		}
+		if s.redisClient != nil {
+			targets = append(targets, &companyRedisTarget{client: s.redisClient})
+		}
+
+		// 修复即追加重放事件，由投递器异步完成并负责重试
+		repairer := reconcile.RepairFunc(func(ctx context.Context, id uint) error {
+			_, err := s.ReplayCompanies([]uint{id})
+			return err
+		})
+		s.reconciler = reconcile.NewEngine(companyReconcileEntity, &companyMySQLSource{db: s.mysqlDB}, targets, repairer)
+	})
+	return s.reconciler
+}
+
+// ReconcileCompany 对单个企业做字段级对账，repair 时将差异加入同步队列
+func (s *CompanyDataSyncService) ReconcileCompany(companyID uint, repair bool) (*reconcile.Report, error) {
+	return s.Reconciler().CheckEntity(context.Background(), companyID, repair)
+}
+
+// StartReconciliation 定期全量对账，间隔与是否自动修复由环境变量配置
+func (s *CompanyDataSyncService) StartReconciliation(ctx context.Context) {
+	interval := defaultCompanyReconcileInterval
+	if value := os.Getenv("COMPANY_RECONCILE_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			interval = parsed
+		}
+	}
+	autoRepair, _ := strconv.ParseBool(os.Getenv("COMPANY_RECONCILE_AUTO_REPAIR"))
+	s.Reconciler().Schedule(ctx, interval, reconcile.Options{Repair: autoRepair})
+}
+
+// companyMySQLSource MySQL企业数据源
+type companyMySQLSource struct {
+	db *gorm.DB
+}
+
+func (src *companyMySQLSource) Batch(ctx context.Context, after uint, limit int) ([]reconcile.Record, error) {
+	var companies []EnhancedCompany
+	if err := src.db.WithContext(ctx).Preload("CompanyUsers").
+		Where("id > ?", after).Order("id ASC").Limit(limit).
+		Find(&companies).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]reconcile.Record, 0, len(companies))
+	for _, company := range companies {
+		records = append(records, reconcile.Record{ID: company.ID, Fields: companyReconcileFields(company)})
+	}
+	return records, nil
+}
+
+func (src *companyMySQLSource) Get(ctx context.Context, id uint) (*reconcile.Record, error) {
+	var companies []EnhancedCompany
+	if err := src.db.WithContext(ctx).Preload("CompanyUsers").Where("id = ?", id).Limit(1).Find(&companies).Error; err != nil {
+		return nil, err
+	}
+	if len(companies) == 0 {
+		return nil, nil
+	}
+	return &reconcile.Record{ID: id, Fields: companyReconcileFields(companies[0])}, nil
+}
+
+// companyPostgresTarget PostgreSQL中的企业及企业用户关联
+type companyPostgresTarget struct {
+	db *gorm.DB
+}
+
+func (t *companyPostgresTarget) Name() string {
+	return string(SyncTargetPostgreSQL)
+}
+
+func (t *companyPostgresTarget) Load(ctx context.Context, ids []uint) (map[uint]reconcile.Record, error) {
+	db := t.db.WithContext(ctx)
+
+	var companies []EnhancedCompany
+	columns := append([]string{"id"}, companyBaseFields...)
+	if err := db.Table("companies").Select(columns).Where("id IN ?", ids).Find(&companies).Error; err != nil {
+		return nil, err
+	}
+	var companyUsers []CompanyUser
+	if err := db.Table("company_users").Where("company_id IN ?", ids).Find(&companyUsers).Error; err != nil {
+		return nil, err
+	}
+
+	usersByCompany := make(map[uint][]CompanyUser)
+	for _, companyUser := range companyUsers {
+		usersByCompany[companyUser.CompanyID] = append(usersByCompany[companyUser.CompanyID], companyUser)
+	}
+
+	records := make(map[uint]reconcile.Record, len(companies))
+	for _, company := range companies {
+		company.CompanyUsers = usersByCompany[company.ID]
+		fields := pickFields(companyReconcileFields(company), append(companyBaseFields, "users"))
+		records[company.ID] = reconcile.Record{ID: company.ID, Fields: fields}
+	}
+	return records, nil
+}
+
+func (t *companyPostgresTarget) IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error) {
+	query := t.db.WithContext(ctx).Table("companies").Where("id > ?", after)
+	if upTo > 0 {
+		query = query.Where("id <= ?", upTo)
+	}
+	var ids []uint
+	err := query.Order("id ASC").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// companyNeo4jTarget Neo4j中的企业节点与 WORKS_FOR 关系
+type companyNeo4jTarget struct {
+	driver neo4j.Driver
+}
+
+func (t *companyNeo4jTarget) Name() string {
+	return string(SyncTargetNeo4j)
+}
+
+func (t *companyNeo4jTarget) Load(ctx context.Context, ids []uint) (map[uint]reconcile.Record, error) {
+	companyIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		companyIDs = append(companyIDs, int64(id))
+	}
+
+	session := t.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	result, err := session.Run(`
+		MATCH (c:Company) WHERE c.id IN $ids
+		RETURN c.id AS id, properties(c) AS props,
+			[(u:User)-[r:WORKS_FOR]->(c) | toString(u.id) + ':' + coalesce(r.role, '') + ':' + coalesce(r.status, '')] AS users
+	`, map[string]interface{}{"ids": companyIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	names := append(append([]string{}, companyBaseFields...), "bd_latitude", "bd_longitude", "address", "city", "district", "area")
+	records := make(map[uint]reconcile.Record)
+	for result.Next() {
+		record := result.Record()
+		id, _ := record.Values[0].(int64)
+		props, _ := record.Values[1].(map[string]interface{})
+		rawUsers, _ := record.Values[2].([]interface{})
+
+		fields := make(map[string]string, len(names)+1)
+		for _, name := range names {
+			fields[name] = neo4jValueString(props[name])
+		}
+		users := make([]string, 0, len(rawUsers))
+		for _, user := range rawUsers {
+			users = append(users, fmt.Sprint(user))
+		}
+		fields["users"] = reconcile.SortedList(users)
+		records[uint(id)] = reconcile.Record{ID: uint(id), Fields: fields}
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (t *companyNeo4jTarget) IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error) {
+	session := t.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	result, err := session.Run(`
+		MATCH (c:Company) WHERE c.id > $after AND ($up_to = 0 OR c.id <= $up_to)
+		RETURN c.id AS id ORDER BY id LIMIT $limit
+	`, map[string]interface{}{"after": int64(after), "up_to": int64(upTo), "limit": int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	for result.Next() {
+		if id, ok := result.Record().Values[0].(int64); ok {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, result.Err()
+}
+
+// neo4jValueString 将Neo4j属性值转换为与MySQL一致的字符串
+func neo4jValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return reconcile.Int(v)
+	case float64:
+		return reconcile.Float(v)
+	case bool:
+		return reconcile.Bool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// companyRedisTarget Redis中的企业缓存
+type companyRedisTarget struct {
+	client *redis.Client
+}
+
+func (t *companyRedisTarget) Name() string {
+	return string(SyncTargetRedis)
+}
+
+func (t *companyRedisTarget) Load(ctx context.Context, ids []uint) (map[uint]reconcile.Record, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("company:%d", id))
+	}
+	values, err := t.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]reconcile.Record)
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var company EnhancedCompany
+		if err := json.NewDecoder(strings.NewReader(data)).Decode(&company); err != nil {
+			return nil, fmt.Errorf("解析企业缓存 %s 失败: %v", keys[i], err)
+		}
+		records[ids[i]] = reconcile.Record{ID: ids[i], Fields: companyReconcileFields(company)}
+	}
+	return records, nil
+}
+
+// IDsInRange 缓存有过期时间且无法高效按范围枚举，不检查孤儿缓存
+func (t *companyRedisTarget) IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error) {
+	return nil, reconcile.ErrRangeUnsupported
+}
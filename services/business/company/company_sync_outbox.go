@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 企业同步事件类型
+const (
+	CompanySyncEventCompanyUpdated = "company_updated"
+	CompanySyncEventUsersChanged   = "company_users_changed"
+	CompanySyncEventCompanyDeleted = "company_deleted"
+	CompanySyncEventReplay         = "replay"
+)
+
+// 投递状态
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"     // 超过最大重试次数，进入死信
+	DeliveryStatusSkipped   = "skipped"  // 目标未配置
+	DeliveryStatusReplayed  = "replayed" // 死信已被重放事件取代
+)
+
+const (
+	syncRetryBaseDelay   = 5 * time.Second
+	syncRetryMaxDelay    = 30 * time.Minute
+	syncMaxAttempts      = 10
+	maxReplayCompanies   = 5000 // 单次按时间范围重放的企业上限
+	deadLetterListLimit  = 200
+	syncDeliveryErrorMax = 2000
+)
+
+var ErrInvalidReplayRange = errors.New("重放时间范围无效")
+
+// CompanySyncOutbox 企业同步发件箱事件，与企业写操作在同一个MySQL事务中写入
+type CompanySyncOutbox struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CompanyID uint      `json:"company_id" gorm:"not null;index"`
+	EventType string    `json:"event_type" gorm:"size:50;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+func (CompanySyncOutbox) TableName() string {
+	return "company_sync_outbox"
+}
+
+// CompanySyncDelivery 发件箱事件到单个同步目标的投递记录
+type CompanySyncDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	OutboxID      uint       `json:"outbox_id" gorm:"not null;uniqueIndex:idx_company_sync_delivery"`
+	CompanyID     uint       `json:"company_id" gorm:"not null;index"`
+	Target        string     `json:"target" gorm:"size:50;not null;uniqueIndex:idx_company_sync_delivery"`
+	Status        string     `json:"status" gorm:"size:20;not null;index:idx_company_sync_delivery_due"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_company_sync_delivery_due"`
+	ClaimToken    string     `json:"-" gorm:"size:64"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (CompanySyncDelivery) TableName() string {
+	return "company_sync_deliveries"
+}
+
+// CompanySyncTargetStats 单个同步目标的积压情况
+type CompanySyncTargetStats struct {
+	Target     SyncTarget `json:"target"`
+	Pending    int64      `json:"pending"`
+	Dead       int64      `json:"dead"`
+	LagSeconds float64    `json:"lag_seconds"` // 最早一条待投递事件距今的时间
+}
+
+// CompanySyncReplayRequest 重放请求，按企业或按事件时间范围
+type CompanySyncReplayRequest struct {
+	CompanyID uint       `json:"company_id"`
+	From      *time.Time `json:"from"`
+	To        *time.Time `json:"to"`
+}
+
+// CompanySyncReplayResult 重放结果
+type CompanySyncReplayResult struct {
+	Companies []uint `json:"companies"`
+	Events    int    `json:"events"`
+}
+
+// companySyncTargets 所有同步目标，按投递顺序排列
+var companySyncTargets = []SyncTarget{SyncTargetPostgreSQL, SyncTargetNeo4j, SyncTargetRedis}
+
+// AppendCompanySyncEvent 在调用方事务中追加同步事件，并为每个同步目标生成投递记录
+func AppendCompanySyncEvent(tx *gorm.DB, companyID uint, eventType string) error {
+	event := CompanySyncOutbox{
+		CompanyID: companyID,
+		EventType: eventType,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("写入同步事件失败: %v", err)
+	}
+
+	deliveries := make([]CompanySyncDelivery, 0, len(companySyncTargets))
+	for _, target := range companySyncTargets {
+		deliveries = append(deliveries, CompanySyncDelivery{
+			OutboxID:      event.ID,
+			CompanyID:     companyID,
+			Target:        string(target),
+			Status:        DeliveryStatusPending,
+			NextAttemptAt: event.CreatedAt,
+			CreatedAt:     event.CreatedAt,
+			UpdatedAt:     event.CreatedAt,
+		})
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("写入同步投递记录失败: %v", err)
+	}
+	return nil
+}
+
+// syncRetryDelay 第 attempts 次失败后的重试间隔，指数退避并设上限
+func syncRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := syncRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= syncRetryMaxDelay {
+			return syncRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// ReplayCompanies 为指定企业追加重放事件；这些企业原有的死信标记为已重放
+func (s *CompanyDataSyncService) ReplayCompanies(companyIDs []uint) (int, error) {
+	if len(companyIDs) == 0 {
+		return 0, nil
+	}
+	err := s.mysqlDB.Transaction(func(tx *gorm.DB) error {
+		for _, companyID := range companyIDs {
+			if err := AppendCompanySyncEvent(tx, companyID, CompanySyncEventReplay); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&CompanySyncDelivery{}).
+			Where("company_id IN ? AND status = ?", companyIDs, DeliveryStatusDead).
+			Updates(map[string]interface{}{
+				"status":     DeliveryStatusReplayed,
+				"updated_at": time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.NotifyRelay()
+	return len(companyIDs), nil
+}
+
+// Replay 按企业或时间范围重放；时间范围内有同步事件或有更新的企业都会被重放
+func (s *CompanyDataSyncService) Replay(req CompanySyncReplayRequest) (*CompanySyncReplayResult, error) {
+	var companyIDs []uint
+	if req.CompanyID > 0 {
+		companyIDs = []uint{req.CompanyID}
+	} else {
+		if req.From == nil || req.To == nil || req.To.Before(*req.From) {
+			return nil, ErrInvalidReplayRange
+		}
+		ids, err := s.companiesChangedBetween(*req.From, *req.To)
+		if err != nil {
+			return nil, err
+		}
+		companyIDs = ids
+	}
+
+	events, err := s.ReplayCompanies(companyIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &CompanySyncReplayResult{Companies: companyIDs, Events: events}, nil
+}
+
+// companiesChangedBetween 时间范围内产生过同步事件或被更新过的企业
+func (s *CompanyDataSyncService) companiesChangedBetween(from, to time.Time) ([]uint, error) {
+	seen := make(map[uint]bool)
+	var ids []uint
+
+	var eventCompanies []uint
+	if err := s.mysqlDB.Model(&CompanySyncOutbox{}).
+		Where("created_at BETWEEN ? AND ?", from, to).
+		Distinct().Limit(maxReplayCompanies).
+		Pluck("company_id", &eventCompanies).Error; err != nil {
+		return nil, fmt.Errorf("查询同步事件失败: %v", err)
+	}
+	var updatedCompanies []uint
+	if err := s.mysqlDB.Model(&EnhancedCompany{}).
+		Where("updated_at BETWEEN ? AND ?", from, to).
+		Limit(maxReplayCompanies).
+		Pluck("id", &updatedCompanies).Error; err != nil {
+		return nil, fmt.Errorf("查询企业更新记录失败: %v", err)
+	}
+
+	for _, id := range append(eventCompanies, updatedCompanies...) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		if len(ids) >= maxReplayCompanies {
+			break
+		}
+	}
+	return ids, nil
+}
+
+// OutboxStats 各同步目标的待投递数、死信数与同步延迟
+func (s *CompanyDataSyncService) OutboxStats() ([]CompanySyncTargetStats, error) {
+	now := time.Now()
+	stats := make([]CompanySyncTargetStats, 0, len(companySyncTargets))
+	for _, target := range companySyncTargets {
+		item := CompanySyncTargetStats{Target: target}
+		if err := s.mysqlDB.Model(&CompanySyncDelivery{}).
+			Where("target = ? AND status = ?", target, DeliveryStatusPending).
+			Count(&item.Pending).Error; err != nil {
+			return nil, fmt.Errorf("统计待投递事件失败: %v", err)
+		}
+		if err := s.mysqlDB.Model(&CompanySyncDelivery{}).
+			Where("target = ? AND status = ?", target, DeliveryStatusDead).
+			Count(&item.Dead).Error; err != nil {
+			return nil, fmt.Errorf("统计死信失败: %v", err)
+		}
+		if item.Pending > 0 {
+			var oldest CompanySyncDelivery
+			err := s.mysqlDB.Where("target = ? AND status = ?", target, DeliveryStatusPending).
+				Order("created_at ASC").First(&oldest).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("查询最早待投递事件失败: %v", err)
+			}
+			if err == nil {
+				item.LagSeconds = now.Sub(oldest.CreatedAt).Seconds()
+			}
+		}
+		stats = append(stats, item)
+	}
+	return stats, nil
+}
+
+// DeadLetters 死信列表，可按企业筛选
+func (s *CompanyDataSyncService) DeadLetters(companyID uint, limit int) ([]CompanySyncDelivery, error) {
+	if limit <= 0 || limit > deadLetterListLimit {
+		limit = deadLetterListLimit
+	}
+	query := s.mysqlDB.Where("status = ?", DeliveryStatusDead)
+	if companyID > 0 {
+		query = query.Where("company_id = ?", companyID)
+	}
+	var deliveries []CompanySyncDelivery
+	if err := query.Order("updated_at DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("获取死信失败: %v", err)
+	}
+	return deliveries, nil
+}
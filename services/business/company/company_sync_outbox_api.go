@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/reconcile"
+)
+
+// replayCompanySync 重放企业同步：指定企业时需具备该企业的同步权限，按时间范围重放仅限系统管理员
+func (api *CompanyAuthAPI) replayCompanySync(c *gin.Context) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户ID不存在"})
+		return
+	}
+	userID := userIDInterface.(uint)
+
+	var req CompanySyncReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.CompanyID > 0 {
+		if !api.permissionManager.CheckCompanyAccess(userID, req.CompanyID, "sync_data", c) {
+			return
+		}
+	} else if !isSystemAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅系统管理员可按时间范围重放"})
+		return
+	}
+
+	result, err := api.dataSyncService.Replay(req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidReplayRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "企业同步重放已加入队列",
+		"data":    result,
+	})
+}
+
+// getOutboxStats 获取各同步目标的积压、死信与延迟
+func (api *CompanyAuthAPI) getOutboxStats(c *gin.Context) {
+	if !isSystemAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+		return
+	}
+
+	stats, err := api.dataSyncService.OutboxStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   stats,
+	})
+}
+
+// getDeadLetters 获取同步死信列表
+func (api *CompanyAuthAPI) getDeadLetters(c *gin.Context) {
+	if !isSystemAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+		return
+	}
+
+	var companyID uint64
+	if companyIDStr := c.Query("company_id"); companyIDStr != "" {
+		id, err := strconv.ParseUint(companyIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的企业ID"})
+			return
+		}
+		companyID = id
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	deliveries, err := api.dataSyncService.DeadLetters(uint(companyID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   deliveries,
+	})
+}
+
+// runReconciliation 按游标分批执行企业跨存储对账，仅限系统管理员
+func (api *CompanyAuthAPI) runReconciliation(c *gin.Context) {
+	if !isSystemAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+		return
+	}
+
+	var opts reconcile.Options
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 对账与请求生命周期解耦，客户端断开不会中断已开始的批次
+	report, err := api.dataSyncService.Reconciler().Run(context.Background(), opts)
+	if err != nil {
+		if errors.Is(err, reconcile.ErrRunInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+// getReconcileReports 获取最近的对账报告
+func (api *CompanyAuthAPI) getReconcileReports(c *gin.Context) {
+	if !isSystemAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   api.dataSyncService.Reconciler().History(),
+	})
+}
+
+func isSystemAdmin(c *gin.Context) bool {
+	role := c.GetString("role")
+	return role == "admin" || role == "super_admin"
+}
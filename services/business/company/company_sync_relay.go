@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const (
+	syncRelayInterval   = 5 * time.Second
+	syncRelayBatchSize  = 100
+	syncRelayMaxBatches = 20              // 单轮最多处理的批次数，避免长时间占用
+	syncClaimLease      = 2 * time.Minute // 领取后未完成的投递在租约到期后可被重新领取
+)
+
+var (
+	// 各同步目标待投递事件数
+	companySyncPendingGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "company_sync_outbox_pending",
+			Help: "Number of pending company sync deliveries per target",
+		},
+		[]string{"target"},
+	)
+
+	// 各同步目标死信数
+	companySyncDeadGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "company_sync_outbox_dead",
+			Help: "Number of dead-lettered company sync deliveries per target",
+		},
+		[]string{"target"},
+	)
+
+	// 各同步目标的同步延迟（最早一条待投递事件距今的秒数）
+	companySyncLagGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "company_sync_lag_seconds",
+			Help: "Age in seconds of the oldest pending company sync delivery per target",
+		},
+		[]string{"target"},
+	)
+
+	// 投递结果计数
+	companySyncDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "company_sync_deliveries_total",
+			Help: "Total number of company sync delivery attempts",
+		},
+		[]string{"target", "result"},
+	)
+)
+
+// CompanySyncRelay 发件箱投递器，把同步事件投递到各目标，失败按指数退避重试，超过次数进入死信
+type CompanySyncRelay struct {
+	service   *CompanyDataSyncService
+	interval  time.Duration
+	batchSize int
+}
+
+// NewCompanySyncRelay 创建发件箱投递器
+func NewCompanySyncRelay(service *CompanyDataSyncService) *CompanySyncRelay {
+	return &CompanySyncRelay{
+		service:   service,
+		interval:  syncRelayInterval,
+		batchSize: syncRelayBatchSize,
+	}
+}
+
+// Start 启动投递循环，写操作提交后可通过 NotifyRelay 立即唤醒
+func (r *CompanySyncRelay) Start(ctx context.Context) {
+	go r.run(ctx)
+}
+
+func (r *CompanySyncRelay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.drain()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.service.relayWake:
+		}
+	}
+}
+
+// drain 处理所有到期的投递并刷新指标
+func (r *CompanySyncRelay) drain() {
+	for i := 0; i < syncRelayMaxBatches; i++ {
+		processed, err := r.ProcessBatch()
+		if err != nil {
+			log.Printf("处理企业同步事件失败: %v", err)
+			break
+		}
+		if processed < r.batchSize {
+			break
+		}
+	}
+	r.refreshMetrics()
+}
+
+// syncDeliveryGroup 同一企业、同一目标的待投递记录，合并为一次同步
+type syncDeliveryGroup struct {
+	companyID  uint
+	target     SyncTarget
+	deliveries []CompanySyncDelivery
+}
+
+// ProcessBatch 领取一批到期的投递并执行，返回本批到期记录数
+func (r *CompanySyncRelay) ProcessBatch() (int, error) {
+	db := r.service.mysqlDB
+	now := time.Now()
+
+	var due []CompanySyncDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", DeliveryStatusPending, now).
+		Order("id ASC").Limit(r.batchSize).Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("查询待投递事件失败: %v", err)
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	claimed, err := r.claim(due, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// 同一企业同一目标的多条事件只需同步一次当前状态
+	var groups []*syncDeliveryGroup
+	index := make(map[string]*syncDeliveryGroup)
+	for _, delivery := range claimed {
+		key := fmt.Sprintf("%d:%s", delivery.CompanyID, delivery.Target)
+		group, ok := index[key]
+		if !ok {
+			group = &syncDeliveryGroup{companyID: delivery.CompanyID, target: SyncTarget(delivery.Target)}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.deliveries = append(group.deliveries, delivery)
+	}
+
+	companies := make(map[uint]*EnhancedCompany)
+	for _, group := range groups {
+		r.deliverGroup(group, companies)
+	}
+	return len(due), nil
+}
+
+// claim 以随机令牌领取投递记录，多实例同时运行时每条记录只会被一个实例处理
+func (r *CompanySyncRelay) claim(due []CompanySyncDelivery, now time.Time) ([]CompanySyncDelivery, error) {
+	ids := make([]uint, 0, len(due))
+	for _, delivery := range due {
+		ids = append(ids, delivery.ID)
+	}
+	token, err := newSyncClaimToken()
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.service.mysqlDB
+	if err := db.Model(&CompanySyncDelivery{}).
+		Where("id IN ? AND status = ? AND next_attempt_at <= ?", ids, DeliveryStatusPending, now).
+		Updates(map[string]interface{}{
+			"claim_token":     token,
+			"next_attempt_at": now.Add(syncClaimLease),
+		}).Error; err != nil {
+		return nil, fmt.Errorf("领取投递记录失败: %v", err)
+	}
+
+	var claimed []CompanySyncDelivery
+	if err := db.Where("claim_token = ? AND status = ?", token, DeliveryStatusPending).
+		Order("id ASC").Find(&claimed).Error; err != nil {
+		return nil, fmt.Errorf("查询已领取投递记录失败: %v", err)
+	}
+	return claimed, nil
+}
+
+// deliverGroup 把企业当前状态同步到目标；企业已删除时从目标中移除
+func (r *CompanySyncRelay) deliverGroup(group *syncDeliveryGroup, companies map[uint]*EnhancedCompany) {
+	if !r.service.targetConfigured(group.target) {
+		r.markGroup(group, DeliveryStatusSkipped)
+		companySyncDeliveriesTotal.WithLabelValues(string(group.target), DeliveryStatusSkipped).Inc()
+		return
+	}
+
+	company, ok := companies[group.companyID]
+	if !ok {
+		var loaded EnhancedCompany
+		err := r.service.mysqlDB.Preload("CompanyUsers").First(&loaded, group.companyID).Error
+		switch {
+		case err == nil:
+			company = &loaded
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			company = nil
+		default:
+			r.failGroup(group, fmt.Errorf("获取企业数据失败: %v", err))
+			return
+		}
+		companies[group.companyID] = company
+	}
+
+	var err error
+	if company == nil {
+		err = r.service.removeFromTarget(group.target, group.companyID)
+	} else {
+		err = r.service.syncTarget(group.target, *company)
+	}
+
+	if err != nil {
+		log.Printf("同步企业 %d 到 %s 失败: %v", group.companyID, group.target, err)
+		r.failGroup(group, err)
+		if company != nil {
+			r.service.updateSyncStatus(group.companyID, group.target, SyncStatusFailed, err.Error())
+		}
+		return
+	}
+
+	r.markGroup(group, DeliveryStatusDelivered)
+	companySyncDeliveriesTotal.WithLabelValues(string(group.target), DeliveryStatusDelivered).Inc()
+	if company != nil {
+		r.service.updateSyncStatus(group.companyID, group.target, SyncStatusSuccess, "")
+	}
+}
+
+// markGroup 将一组投递记录标记为终态
+func (r *CompanySyncRelay) markGroup(group *syncDeliveryGroup, status string) {
+	ids := make([]uint, 0, len(group.deliveries))
+	for _, delivery := range group.deliveries {
+		ids = append(ids, delivery.ID)
+	}
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      status,
+		"claim_token": "",
+		"last_error":  "",
+		"updated_at":  now,
+	}
+	if status == DeliveryStatusDelivered {
+		updates["delivered_at"] = now
+	}
+	if err := r.service.mysqlDB.Model(&CompanySyncDelivery{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+		log.Printf("更新投递状态失败: %v", err)
+	}
+}
+
+// failGroup 记录失败并安排退避重试，超过最大次数的进入死信
+func (r *CompanySyncRelay) failGroup(group *syncDeliveryGroup, cause error) {
+	message := cause.Error()
+	if runes := []rune(message); len(runes) > syncDeliveryErrorMax {
+		message = string(runes[:syncDeliveryErrorMax])
+	}
+	now := time.Now()
+
+	for _, delivery := range group.deliveries {
+		attempts := delivery.Attempts + 1
+		updates := map[string]interface{}{
+			"attempts":    attempts,
+			"claim_token": "",
+			"last_error":  message,
+			"updated_at":  now,
+		}
+		result := "retry"
+		if attempts >= syncMaxAttempts {
+			updates["status"] = DeliveryStatusDead
+			result = DeliveryStatusDead
+			log.Printf("WARN: 企业 %d 同步到 %s 重试 %d 次仍失败，已转入死信", delivery.CompanyID, delivery.Target, attempts)
+		} else {
+			updates["next_attempt_at"] = now.Add(syncRetryDelay(attempts))
+		}
+
+		if err := r.service.mysqlDB.Model(&CompanySyncDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error; err != nil {
+			log.Printf("更新投递重试状态失败: %v", err)
+		}
+		companySyncDeliveriesTotal.WithLabelValues(delivery.Target, result).Inc()
+	}
+}
+
+// refreshMetrics 刷新各目标的积压、死信与延迟指标
+func (r *CompanySyncRelay) refreshMetrics() {
+	stats, err := r.service.OutboxStats()
+	if err != nil {
+		log.Printf("统计企业同步积压失败: %v", err)
+		return
+	}
+	for _, item := range stats {
+		target := string(item.Target)
+		companySyncPendingGauge.WithLabelValues(target).Set(float64(item.Pending))
+		companySyncDeadGauge.WithLabelValues(target).Set(float64(item.Dead))
+		companySyncLagGauge.WithLabelValues(target).Set(item.LagSeconds)
+	}
+}
+
+func newSyncClaimToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成领取令牌失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,36 @@
+-- Company服务数据同步发件箱
+-- 目标：企业写操作在同一事务中追加同步事件，由投递器可靠地同步到PostgreSQL、Neo4j、Redis
+
+-- 1. 同步事件表（不设外键，企业删除后仍需投递删除事件）
+CREATE TABLE IF NOT EXISTS company_sync_outbox (
+    id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    company_id BIGINT UNSIGNED NOT NULL,
+    event_type VARCHAR(50) NOT NULL COMMENT '事件类型：company_updated, company_users_changed, company_deleted, replay',
+    created_at TIMESTAMP(3) DEFAULT CURRENT_TIMESTAMP(3)
+) COMMENT='企业同步事件发件箱';
+
+-- 2. 事件投递表（每个事件对每个同步目标一条）
+CREATE TABLE IF NOT EXISTS company_sync_deliveries (
+    id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    outbox_id BIGINT UNSIGNED NOT NULL,
+    company_id BIGINT UNSIGNED NOT NULL,
+    target VARCHAR(50) NOT NULL COMMENT '同步目标：postgresql, neo4j, redis',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '投递状态：pending, delivered, dead, skipped, replayed',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '已尝试次数',
+    next_attempt_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) COMMENT '下次投递时间',
+    claim_token VARCHAR(64) COMMENT '投递器领取令牌',
+    last_error TEXT COMMENT '最后一次错误',
+    delivered_at TIMESTAMP(3) NULL COMMENT '投递成功时间',
+    created_at TIMESTAMP(3) DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at TIMESTAMP(3) DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (outbox_id) REFERENCES company_sync_outbox(id) ON DELETE CASCADE,
+    UNIQUE KEY idx_company_sync_delivery (outbox_id, target)
+) COMMENT='企业同步事件投递记录';
+
+-- 3. 创建索引
+CREATE INDEX idx_company_sync_outbox_company_id ON company_sync_outbox(company_id);
+CREATE INDEX idx_company_sync_outbox_created_at ON company_sync_outbox(created_at);
+CREATE INDEX idx_company_sync_deliveries_company_id ON company_sync_deliveries(company_id);
+CREATE INDEX idx_company_sync_delivery_due ON company_sync_deliveries(status, next_attempt_at);
+CREATE INDEX idx_company_sync_deliveries_claim ON company_sync_deliveries(claim_token);
+CREATE INDEX idx_company_sync_deliveries_target_status ON company_sync_deliveries(target, status, created_at);
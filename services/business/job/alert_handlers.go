@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/response"
+)
+
+// registerAlertRoutes 保存的搜索（需要登录）与退订路由（凭令牌访问，无需登录）
+func (h *jobHandler) registerAlertRoutes(r *gin.Engine) {
+	searchGroup := r.Group("/api/v1/saved-searches", h.auth)
+	{
+		searchGroup.GET("", h.handleListSavedSearches)
+		searchGroup.POST("", h.handleCreateSavedSearch)
+		searchGroup.PUT("/:id", h.handleUpdateSavedSearch)
+		searchGroup.DELETE("/:id", h.handleDeleteSavedSearch)
+		searchGroup.GET("/:id/jobs", h.handleSearchSavedSearch)
+	}
+
+	// 邮件中的退订链接直接以 GET 打开
+	r.GET("/api/v1/job-alerts/unsubscribe", h.handleUnsubscribe)
+	r.POST("/api/v1/job-alerts/unsubscribe", h.handleUnsubscribe)
+}
+
+func (h *jobHandler) handleListSavedSearches(c *gin.Context) {
+	searches, err := h.service.ListSavedSearches(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	writeSuccess(c, "获取保存的搜索成功", searches)
+}
+
+func (h *jobHandler) handleCreateSavedSearch(c *gin.Context) {
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	search, err := h.service.CreateSavedSearch(c.Request.Context(), currentUserID(c), req)
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	writeSuccess(c, "保存搜索成功", search)
+}
+
+func (h *jobHandler) handleUpdateSavedSearch(c *gin.Context) {
+	searchID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的搜索ID")
+		return
+	}
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	search, err := h.service.UpdateSavedSearch(c.Request.Context(), currentUserID(c), searchID, req)
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	writeSuccess(c, "更新保存的搜索成功", search)
+}
+
+func (h *jobHandler) handleDeleteSavedSearch(c *gin.Context) {
+	searchID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的搜索ID")
+		return
+	}
+
+	if err := h.service.DeleteSavedSearch(c.Request.Context(), currentUserID(c), searchID); err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	writeSuccess(c, "删除保存的搜索成功", nil)
+}
+
+func (h *jobHandler) handleSearchSavedSearch(c *gin.Context) {
+	searchID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的搜索ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.service.SearchSavedSearch(c.Request.Context(), currentUserID(c), searchID, page, size)
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	writeSuccess(c, "搜索职位成功", result)
+}
+
+func (h *jobHandler) handleUnsubscribe(c *gin.Context) {
+	token := strings.TrimSpace(c.Query("token"))
+	var searchID uint
+	if raw := strings.TrimSpace(c.Query("searchId")); raw != "" {
+		id, err := parseUintParam(raw)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的搜索ID")
+			return
+		}
+		searchID = id
+	}
+
+	count, err := h.service.Unsubscribe(c.Request.Context(), token, searchID)
+	if err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	writeSuccess(c, "已退订职位提醒", gin.H{"unsubscribed": count})
+}
+
+func writeAlertError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrSavedSearchNotFound):
+		writeError(c, http.StatusNotFound, response.CodeNotFound, err.Error())
+	case errors.Is(err, ErrInvalidSavedSearch), errors.Is(err, ErrSavedSearchLimit):
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+	case errors.Is(err, ErrInvalidUnsubscribeKey):
+		writeError(c, http.StatusNotFound, response.CodeNotFound, err.Error())
+	default:
+		writeError(c, http.StatusInternalServerError, response.CodeInternalError, err.Error())
+	}
+}
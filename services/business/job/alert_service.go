@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// alertCatchUpWindow 周期提醒补扫最近发布职位的时间范围，覆盖每周提醒的间隔
+const alertCatchUpWindow = 8 * 24 * time.Hour
+
+// EnsureAlertSchema 创建保存的搜索、命中记录与退订令牌表
+func (s *JobService) EnsureAlertSchema(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&SavedSearch{}, &JobAlertMatch{}, &JobAlertToken{})
+}
+
+// ListSavedSearches 用户保存的搜索
+func (s *JobService) ListSavedSearches(ctx context.Context, userID uint) ([]SavedSearch, error) {
+	searches := []SavedSearch{}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&searches).Error
+	return searches, err
+}
+
+// CreateSavedSearch 保存搜索条件并订阅新职位提醒
+func (s *JobService) CreateSavedSearch(ctx context.Context, userID uint, req SavedSearchRequest) (SavedSearch, error) {
+	search := SavedSearch{UserID: userID, Active: true}
+	if err := applySavedSearchRequest(&search, req); err != nil {
+		return SavedSearch{}, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&SavedSearch{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxSavedSearchesPerUser {
+			return ErrSavedSearchLimit
+		}
+		if _, err := alertToken(tx, userID); err != nil {
+			return err
+		}
+		return tx.Create(&search).Error
+	})
+	return search, err
+}
+
+// UpdateSavedSearch 修改搜索条件、频率或启用状态
+func (s *JobService) UpdateSavedSearch(ctx context.Context, userID, searchID uint, req SavedSearchRequest) (SavedSearch, error) {
+	db := s.db.WithContext(ctx)
+	search, err := loadSavedSearch(db, userID, searchID)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	criteriaChanged := search.Criteria != req.Criteria.normalized()
+	if err := applySavedSearchRequest(&search, req); err != nil {
+		return SavedSearch{}, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if criteriaChanged {
+			// 条件变更后，按旧条件命中但尚未提醒的职位不再提醒
+			if err := tx.Where("saved_search_id = ? AND notified_at IS NULL", search.ID).Delete(&JobAlertMatch{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&search).Error
+	})
+	return search, err
+}
+
+// DeleteSavedSearch 删除保存的搜索及其命中记录
+func (s *JobService) DeleteSavedSearch(ctx context.Context, userID, searchID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		search, err := loadSavedSearch(tx, userID, searchID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("saved_search_id = ?", search.ID).Delete(&JobAlertMatch{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&search).Error
+	})
+}
+
+// SearchSavedSearch 按保存的条件执行一次搜索
+func (s *JobService) SearchSavedSearch(ctx context.Context, userID, searchID uint, page, pageSize int) (JobSearchResult, error) {
+	search, err := loadSavedSearch(s.db.WithContext(ctx), userID, searchID)
+	if err != nil {
+		return JobSearchResult{}, err
+	}
+	req := search.Criteria.toSearchRequest()
+	req.Page = page
+	req.PageSize = pageSize
+	req.Sort = "newest"
+	return s.SearchJobs(ctx, req)
+}
+
+// Unsubscribe 通过退订令牌停用提醒，searchID 为0时停用该用户全部提醒
+func (s *JobService) Unsubscribe(ctx context.Context, token string, searchID uint) (int64, error) {
+	db := s.db.WithContext(ctx)
+	var tokens []JobAlertToken
+	if err := db.Where("token = ?", token).Limit(1).Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	if token == "" || len(tokens) == 0 {
+		return 0, ErrInvalidUnsubscribeKey
+	}
+
+	query := db.Model(&SavedSearch{}).Where("user_id = ? AND active = ?", tokens[0].UserID, true)
+	if searchID != 0 {
+		query = query.Where("id = ?", searchID)
+	}
+	result := query.Updates(map[string]interface{}{"active": false, "updated_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// onJobPublished 职位发布后在后台匹配保存的搜索，即时提醒的订阅立即投递
+func (s *JobService) onJobPublished(job Job) {
+	go func() {
+		if err := s.matchSavedSearches(context.Background(), job); err != nil {
+			log.Printf("WARN: 匹配保存的搜索失败 job=%d: %v", job.ID, err)
+		}
+	}()
+}
+
+func (s *JobService) matchSavedSearches(ctx context.Context, job Job) error {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+
+	var instant []SavedSearch
+	var searches []SavedSearch
+	err := db.Where("active = ?", true).FindInBatches(&searches, 500, func(tx *gorm.DB, batch int) error {
+		for _, search := range searches {
+			if !search.Criteria.Matches(job) {
+				continue
+			}
+			created, err := recordAlertMatch(db, search.ID, job.ID, now)
+			if err != nil {
+				return err
+			}
+			if created && search.Frequency == AlertInstant {
+				instant = append(instant, search)
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	for _, search := range instant {
+		if _, err := s.deliverAlerts(ctx, search, now); err != nil {
+			log.Printf("WARN: 投递职位提醒失败 search=%d: %v", search.ID, err)
+		}
+	}
+	return nil
+}
+
+// RunAlertDigests 补扫最近发布的职位并按频率投递到期的提醒，由生命周期调度器周期调用
+func (s *JobService) RunAlertDigests(ctx context.Context, now time.Time) (AlertDigestReport, error) {
+	var report AlertDigestReport
+	db := s.db.WithContext(ctx)
+
+	var recent []Job
+	if err := db.Where("status IN ?", []string{JobStatusPublished, JobStatusOpen}).
+		Where("expire_at IS NULL OR expire_at > ?", now).
+		Where("COALESCE(publish_at, created_at) > ?", now.Add(-alertCatchUpWindow)).
+		Order("id DESC").Limit(matchCandidateLimit).Find(&recent).Error; err != nil {
+		return report, err
+	}
+
+	var searches []SavedSearch
+	err := db.Where("active = ?", true).FindInBatches(&searches, 500, func(tx *gorm.DB, batch int) error {
+		for _, search := range searches {
+			if search.LastNotifiedAt != nil && now.Sub(*search.LastNotifiedAt) < alertIntervals[search.Frequency] {
+				continue
+			}
+
+			for _, job := range recent {
+				if !jobPublishedTime(job).After(search.CreatedAt) || !search.Criteria.Matches(job) {
+					continue
+				}
+				if _, err := recordAlertMatch(db, search.ID, job.ID, now); err != nil {
+					return err
+				}
+			}
+
+			sent, err := s.deliverAlerts(ctx, search, now)
+			if err != nil {
+				log.Printf("WARN: 投递职位提醒失败 search=%d: %v", search.ID, err)
+				continue
+			}
+			if sent > 0 {
+				report.Searches++
+				report.Jobs += sent
+			}
+		}
+		return nil
+	}).Error
+	return report, err
+}
+
+// deliverAlerts 投递待提醒的职位。先以 last_notified_at 乐观锁占用本轮投递，避免多副本重复发送；
+// 投递失败时恢复 last_notified_at，命中记录保持待提醒，下一轮重试。
+func (s *JobService) deliverAlerts(ctx context.Context, search SavedSearch, now time.Time) (int, error) {
+	db := s.db.WithContext(ctx)
+
+	var pending []JobAlertMatch
+	if err := db.Where("saved_search_id = ? AND notified_at IS NULL", search.ID).
+		Order("matched_at").Find(&pending).Error; err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	claim := db.Model(&SavedSearch{}).Where("id = ? AND active = ?", search.ID, true)
+	if search.LastNotifiedAt == nil {
+		claim = claim.Where("last_notified_at IS NULL")
+	} else {
+		claim = claim.Where("last_notified_at = ?", *search.LastNotifiedAt)
+	}
+	result := claim.Update("last_notified_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, result.Error
+	}
+
+	matchIDs := make([]uint, len(pending))
+	jobIDs := make([]uint, len(pending))
+	for i, match := range pending {
+		matchIDs[i] = match.ID
+		jobIDs[i] = match.JobID
+	}
+
+	// 命中后已关闭或到期的职位不再提醒
+	var jobs []Job
+	if err := db.Where("id IN ? AND status IN ?", jobIDs, []string{JobStatusPublished, JobStatusOpen}).
+		Where("expire_at IS NULL OR expire_at > ?", now).
+		Order("id DESC").Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+
+	if len(jobs) > 0 {
+		event, err := s.newAlertEvent(db, search, jobs, now)
+		if err == nil {
+			err = s.events.PublishAlert(event)
+		}
+		if err != nil {
+			db.Model(&SavedSearch{}).Where("id = ?", search.ID).Update("last_notified_at", search.LastNotifiedAt)
+			return 0, err
+		}
+	}
+
+	if err := db.Model(&JobAlertMatch{}).Where("id IN ?", matchIDs).Update("notified_at", now).Error; err != nil {
+		return 0, err
+	}
+	return len(jobs), nil
+}
+
+func (s *JobService) newAlertEvent(db *gorm.DB, search SavedSearch, jobs []Job, now time.Time) (JobAlertEvent, error) {
+	token, err := alertToken(db, search.UserID)
+	if err != nil {
+		return JobAlertEvent{}, err
+	}
+
+	event := JobAlertEvent{
+		UserID:         search.UserID,
+		SavedSearchID:  search.ID,
+		SearchName:     search.Name,
+		Frequency:      search.Frequency,
+		Total:          len(jobs),
+		UnsubscribeURL: unsubscribeURL(token, search.ID),
+		OccurredAt:     now,
+	}
+	for i, job := range jobs {
+		if i >= alertDigestJobLimit {
+			break
+		}
+		summary := job.toSummary()
+		event.Jobs = append(event.Jobs, JobAlertItem{
+			JobID:       job.ID,
+			Title:       summary.Title,
+			CompanyName: summary.Company,
+			Location:    summary.Location,
+			Salary:      summary.Salary,
+		})
+	}
+	return event, nil
+}
+
+func applySavedSearchRequest(search *SavedSearch, req SavedSearchRequest) error {
+	criteria := req.Criteria.normalized()
+	if criteria.isEmpty() {
+		return fmt.Errorf("%w: 至少需要一个搜索条件", ErrInvalidSavedSearch)
+	}
+	frequency := strings.TrimSpace(req.Frequency)
+	if frequency == "" {
+		frequency = AlertDaily
+	}
+	if !validAlertFrequency(frequency) {
+		return fmt.Errorf("%w: 不支持的提醒频率 %s", ErrInvalidSavedSearch, frequency)
+	}
+
+	search.Name = strings.TrimSpace(req.Name)
+	search.Criteria = criteria
+	search.Frequency = frequency
+	if req.Active != nil {
+		search.Active = *req.Active
+	}
+	return nil
+}
+
+func loadSavedSearch(db *gorm.DB, userID, searchID uint) (SavedSearch, error) {
+	var searches []SavedSearch
+	if err := db.Where("id = ? AND user_id = ?", searchID, userID).Limit(1).Find(&searches).Error; err != nil {
+		return SavedSearch{}, err
+	}
+	if len(searches) == 0 {
+		return SavedSearch{}, ErrSavedSearchNotFound
+	}
+	return searches[0], nil
+}
+
+// recordAlertMatch 记录命中，同一职位只记录一次，返回是否新增
+func recordAlertMatch(db *gorm.DB, searchID, jobID uint, now time.Time) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&JobAlertMatch{
+		SavedSearchID: searchID,
+		JobID:         jobID,
+		MatchedAt:     now,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// alertToken 获取用户的退订令牌，不存在时创建
+func alertToken(db *gorm.DB, userID uint) (string, error) {
+	var tokens []JobAlertToken
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&tokens).Error; err != nil {
+		return "", err
+	}
+	if len(tokens) > 0 {
+		return tokens[0].Token, nil
+	}
+
+	token, err := newUnsubscribeToken()
+	if err != nil {
+		return "", err
+	}
+	record := JobAlertToken{UserID: userID, Token: token, CreatedAt: time.Now()}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return "", err
+	}
+	// 并发创建时以先写入的令牌为准
+	if err := db.Where("user_id = ?", userID).First(&record).Error; err != nil {
+		return "", err
+	}
+	return record.Token, nil
+}
+
+// unsubscribeURL 退订链接，JOB_ALERT_UNSUBSCRIBE_URL 可配置为网关地址
+func unsubscribeURL(token string, searchID uint) string {
+	base := os.Getenv("JOB_ALERT_UNSUBSCRIBE_URL")
+	if base == "" {
+		base = "http://localhost:8084/api/v1/job-alerts/unsubscribe"
+	}
+	return fmt.Sprintf("%s?token=%s&searchId=%d", base, url.QueryEscape(token), searchID)
+}
+
+func jobPublishedTime(job Job) time.Time {
+	if job.PublishAt != nil {
+		return *job.PublishAt
+	}
+	return job.CreatedAt
+}
+
+func isActiveJobStatus(status string) bool {
+	return status == JobStatusPublished || status == JobStatusOpen
+}
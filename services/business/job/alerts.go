@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 职位提醒频率
+const (
+	AlertInstant = "instant" // 职位发布后立即提醒
+	AlertDaily   = "daily"
+	AlertWeekly  = "weekly"
+)
+
+// alertIntervals 各频率两次提醒之间的最小间隔
+var alertIntervals = map[string]time.Duration{
+	AlertInstant: 0,
+	AlertDaily:   24 * time.Hour,
+	AlertWeekly:  7 * 24 * time.Hour,
+}
+
+const (
+	maxSavedSearchesPerUser = 20
+	alertDigestJobLimit     = 10 // 单条提醒中列出的职位数
+)
+
+var (
+	ErrSavedSearchNotFound   = errors.New("保存的搜索不存在")
+	ErrSavedSearchLimit      = errors.New("保存的搜索数量已达上限")
+	ErrInvalidSavedSearch    = errors.New("保存的搜索条件无效")
+	ErrInvalidUnsubscribeKey = errors.New("退订链接无效")
+)
+
+// SavedSearchCriteria 保存的搜索条件，与 /api/v1/jobs/search 的筛选参数一致
+type SavedSearchCriteria struct {
+	Keyword    string `json:"keyword,omitempty"`
+	Category   string `json:"category,omitempty"`
+	WorkType   string `json:"workType,omitempty"`
+	Location   string `json:"location,omitempty"`
+	SalaryBand string `json:"salaryBand,omitempty"`
+	Experience string `json:"experience,omitempty"`
+}
+
+// SavedSearch 用户保存的搜索及提醒设置
+type SavedSearch struct {
+	ID             uint                `json:"id" gorm:"primaryKey"`
+	UserID         uint                `json:"userId" gorm:"column:user_id;not null;index"`
+	Name           string              `json:"name" gorm:"column:name;size:100;not null"`
+	Criteria       SavedSearchCriteria `json:"criteria" gorm:"column:criteria;serializer:json;type:text"`
+	Frequency      string              `json:"frequency" gorm:"column:frequency;size:20;not null"`
+	Active         bool                `json:"active" gorm:"column:active;not null"`
+	LastNotifiedAt *time.Time          `json:"lastNotifiedAt" gorm:"column:last_notified_at"`
+	CreatedAt      time.Time           `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt      time.Time           `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+func (SavedSearch) TableName() string {
+	return "zervigo_job_saved_searches"
+}
+
+// JobAlertMatch 保存的搜索命中的职位，NotifiedAt 为空表示待提醒
+type JobAlertMatch struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	SavedSearchID uint       `json:"savedSearchId" gorm:"column:saved_search_id;not null;uniqueIndex:idx_job_alert_match"`
+	JobID         uint       `json:"jobId" gorm:"column:job_id;not null;uniqueIndex:idx_job_alert_match"`
+	MatchedAt     time.Time  `json:"matchedAt" gorm:"column:matched_at"`
+	NotifiedAt    *time.Time `json:"notifiedAt" gorm:"column:notified_at;index"`
+}
+
+func (JobAlertMatch) TableName() string {
+	return "zervigo_job_alert_matches"
+}
+
+// JobAlertToken 用户的退订令牌，提醒中的退订链接使用该令牌，无需登录
+type JobAlertToken struct {
+	UserID    uint      `gorm:"column:user_id;primaryKey;autoIncrement:false"`
+	Token     string    `gorm:"column:token;size:64;not null;uniqueIndex"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (JobAlertToken) TableName() string {
+	return "zervigo_job_alert_tokens"
+}
+
+// SavedSearchRequest 创建或更新保存的搜索
+type SavedSearchRequest struct {
+	Name      string              `json:"name" binding:"required,max=100"`
+	Criteria  SavedSearchCriteria `json:"criteria"`
+	Frequency string              `json:"frequency"`
+	Active    *bool               `json:"active"`
+}
+
+// AlertDigestReport 一轮提醒投递的结果
+type AlertDigestReport struct {
+	Searches int `json:"searches"`
+	Jobs     int `json:"jobs"`
+}
+
+func (c SavedSearchCriteria) normalized() SavedSearchCriteria {
+	return SavedSearchCriteria{
+		Keyword:    strings.TrimSpace(c.Keyword),
+		Category:   strings.TrimSpace(c.Category),
+		WorkType:   strings.TrimSpace(c.WorkType),
+		Location:   strings.TrimSpace(c.Location),
+		SalaryBand: strings.TrimSpace(c.SalaryBand),
+		Experience: strings.TrimSpace(c.Experience),
+	}
+}
+
+func (c SavedSearchCriteria) isEmpty() bool {
+	return c == SavedSearchCriteria{}
+}
+
+// toSearchRequest 转换为搜索请求，用于按保存的条件直接搜索
+func (c SavedSearchCriteria) toSearchRequest() JobSearchRequest {
+	return JobSearchRequest{
+		Keyword:    c.Keyword,
+		Category:   c.Category,
+		WorkType:   c.WorkType,
+		Location:   c.Location,
+		SalaryBand: c.SalaryBand,
+		Experience: c.Experience,
+	}
+}
+
+// Matches 判断职位是否满足条件，关键词语义与全文搜索一致：所有查询词都需出现在职位中
+func (c SavedSearchCriteria) Matches(job Job) bool {
+	if c.Category != "" && job.JobCategory != c.Category {
+		return false
+	}
+	if c.WorkType != "" && job.WorkType != c.WorkType {
+		return false
+	}
+	if c.Location != "" && job.WorkLocation != c.Location {
+		return false
+	}
+	if c.SalaryBand != "" && salaryBandOf(job) != c.SalaryBand {
+		return false
+	}
+	if c.Experience != "" && job.ExperienceRequired != c.Experience {
+		return false
+	}
+
+	terms := uniqueTokens(tokenizeForSearch(c.Keyword))
+	if len(terms) == 0 {
+		return true
+	}
+	doc := newSearchDocument(job)
+	present := make(map[string]bool)
+	for _, field := range [][]string{doc.Title, doc.Keywords, doc.Requirements, doc.Description} {
+		for _, token := range field {
+			present[token] = true
+		}
+	}
+	for _, term := range terms {
+		if !present[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// salaryBandOf 与搜索分面 salaryBandExpr 的划分一致
+func salaryBandOf(job Job) string {
+	var salary *int
+	if job.SalaryMax != nil {
+		salary = job.SalaryMax
+	} else {
+		salary = job.SalaryMin
+	}
+	if salary == nil {
+		return salaryBandNegotiable
+	}
+	for _, band := range salaryBands {
+		if band.Upper == 0 || *salary < band.Upper {
+			return band.Name
+		}
+	}
+	return salaryBandNegotiable
+}
+
+func validAlertFrequency(frequency string) bool {
+	_, ok := alertIntervals[frequency]
+	return ok
+}
+
+func newUnsubscribeToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 申请流程阶段
+const (
+	StageApplied   = "applied"
+	StageScreening = "screening"
+	StageInterview = "interview"
+	StageOffer     = "offer"
+	StageHired     = "hired"
+	StageRejected  = "rejected"
+	StageWithdrawn = "withdrawn" // 候选人撤回，不属于招聘流程配置
+)
+
+var (
+	ErrApplicationNotFound    = errors.New("职位申请不存在")
+	ErrAlreadyApplied         = errors.New("已申请该职位")
+	ErrJobNotOpen             = errors.New("职位未开放申请")
+	ErrApplicationForbidden   = errors.New("无权操作该职位的申请")
+	ErrInvalidStageTransition = errors.New("不允许的阶段变更")
+	ErrInvalidPipeline        = errors.New("招聘流程配置错误")
+)
+
+// stageStatus 阶段对应的申请状态（兼容 status 字段：pending, reviewing, interviewed, offered, rejected, withdrawn）
+var stageStatus = map[string]string{
+	StageApplied:   "pending",
+	StageScreening: "reviewing",
+	StageInterview: "interviewed",
+	StageOffer:     "offered",
+	StageHired:     "hired",
+	StageRejected:  "rejected",
+	StageWithdrawn: "withdrawn",
+}
+
+// ApplicationPipeline 职位的招聘流程：阶段及允许的阶段变更
+type ApplicationPipeline struct {
+	Stages      []string            `json:"stages"`
+	Transitions map[string][]string `json:"transitions"`
+	Closed      []string            `json:"closed"` // 结束阶段，候选人不能再撤回
+}
+
+// DefaultApplicationPipeline 默认招聘流程
+func DefaultApplicationPipeline() ApplicationPipeline {
+	return ApplicationPipeline{
+		Stages: []string{StageApplied, StageScreening, StageInterview, StageOffer, StageHired, StageRejected},
+		Transitions: map[string][]string{
+			StageApplied:   {StageScreening, StageInterview, StageRejected},
+			StageScreening: {StageInterview, StageRejected},
+			StageInterview: {StageOffer, StageRejected},
+			StageOffer:     {StageHired, StageRejected},
+			StageRejected:  {StageScreening},
+		},
+		Closed: []string{StageHired, StageRejected},
+	}
+}
+
+// Validate 校验流程配置
+func (p ApplicationPipeline) Validate() error {
+	if len(p.Stages) == 0 || p.Stages[0] != StageApplied {
+		return fmt.Errorf("%w: 第一个阶段必须是 %s", ErrInvalidPipeline, StageApplied)
+	}
+	known := make(map[string]bool, len(p.Stages))
+	for _, stage := range p.Stages {
+		if stage == "" || stage == StageWithdrawn {
+			return fmt.Errorf("%w: 阶段名称 %q 不可用", ErrInvalidPipeline, stage)
+		}
+		if known[stage] {
+			return fmt.Errorf("%w: 阶段 %s 重复", ErrInvalidPipeline, stage)
+		}
+		known[stage] = true
+	}
+	for from, targets := range p.Transitions {
+		if !known[from] {
+			return fmt.Errorf("%w: 未知阶段 %s", ErrInvalidPipeline, from)
+		}
+		for _, to := range targets {
+			if !known[to] || to == from {
+				return fmt.Errorf("%w: 阶段变更 %s -> %s 无效", ErrInvalidPipeline, from, to)
+			}
+		}
+	}
+	for _, stage := range p.Closed {
+		if !known[stage] {
+			return fmt.Errorf("%w: 未知结束阶段 %s", ErrInvalidPipeline, stage)
+		}
+	}
+	return nil
+}
+
+// HasStage 流程是否包含该阶段
+func (p ApplicationPipeline) HasStage(stage string) bool {
+	for _, s := range p.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition 招聘方是否可以把申请从from移动到to
+func (p ApplicationPipeline) CanTransition(from, to string) bool {
+	for _, target := range p.Transitions[from] {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsClosed 是否为结束阶段（含候选人撤回）
+func (p ApplicationPipeline) IsClosed(stage string) bool {
+	if stage == StageWithdrawn {
+		return true
+	}
+	for _, s := range p.Closed {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// statusForStage 自定义阶段按审核中处理
+func statusForStage(stage string) string {
+	if status, ok := stageStatus[stage]; ok {
+		return status
+	}
+	return "reviewing"
+}
+
+// currentStage 旧数据的 application_stage 可能为空
+func (a JobApplication) currentStage() string {
+	if a.ApplicationStage == "" {
+		return StageApplied
+	}
+	return a.ApplicationStage
+}
+
+// JobPipeline 职位自定义招聘流程，未配置时使用默认流程
+type JobPipeline struct {
+	JobID     uint      `json:"jobId" gorm:"column:job_id;primaryKey;autoIncrement:false"`
+	Config    string    `json:"config" gorm:"column:config;type:text;not null"`
+	UpdatedBy uint      `json:"updatedBy" gorm:"column:updated_by"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+func (JobPipeline) TableName() string {
+	return "zervigo_job_pipelines"
+}
+
+// JobApplicationStageHistory 申请阶段变更记录
+type JobApplicationStageHistory struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	ApplicationID uint      `json:"applicationId" gorm:"column:application_id;not null;index"`
+	JobID         uint      `json:"jobId" gorm:"column:job_id;not null;index"`
+	FromStage     string    `json:"fromStage" gorm:"column:from_stage;size:50"`
+	ToStage       string    `json:"toStage" gorm:"column:to_stage;size:50;not null"`
+	ChangedBy     uint      `json:"changedBy" gorm:"column:changed_by"`
+	Note          string    `json:"note" gorm:"column:note;type:text"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"column:created_at"`
+}
+
+func (JobApplicationStageHistory) TableName() string {
+	return "zervigo_job_application_stage_history"
+}
+
+// ApplyRequest 申请职位请求
+type ApplyRequest struct {
+	ResumeID    uint   `json:"resumeId" binding:"required"`
+	CoverLetter string `json:"coverLetter"`
+	Source      string `json:"source"`
+}
+
+// StageChangeRequest 变更申请阶段请求
+type StageChangeRequest struct {
+	Stage string `json:"stage" binding:"required"`
+	Note  string `json:"note"`
+}
+
+// BulkStageChangeRequest 批量变更申请阶段请求
+type BulkStageChangeRequest struct {
+	ApplicationIDs []uint `json:"applicationIds" binding:"required,min=1,max=200"`
+	Stage          string `json:"stage" binding:"required"`
+	Note           string `json:"note"`
+}
+
+// WithdrawRequest 撤回申请请求
+type WithdrawRequest struct {
+	Reason string `json:"reason"`
+}
+
+// BulkStageResult 批量变更中单个申请的结果
+type BulkStageResult struct {
+	ApplicationID uint   `json:"applicationId"`
+	Success       bool   `json:"success"`
+	FromStage     string `json:"fromStage,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ApplicationListResult 申请列表
+type ApplicationListResult struct {
+	Items    []JobApplication `json:"items"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+}
+
+// FunnelStage 漏斗中的单个阶段
+type FunnelStage struct {
+	Stage   string `json:"stage"`
+	Current int64  `json:"current"` // 当前处于该阶段的申请数
+	Reached int64  `json:"reached"` // 曾进入该阶段的申请数
+}
+
+// JobFunnel 职位申请漏斗
+type JobFunnel struct {
+	JobID     uint          `json:"jobId"`
+	Total     int64         `json:"total"`
+	Withdrawn int64         `json:"withdrawn"`
+	Stages    []FunnelStage `json:"stages"`
+}
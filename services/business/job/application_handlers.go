@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/response"
+	"gorm.io/gorm"
+)
+
+// registerApplicationRoutes 职位申请与招聘流程路由（需要登录）
+func (h *jobHandler) registerApplicationRoutes(r *gin.Engine) {
+	jobGroup := r.Group("/api/v1/jobs", h.auth)
+	{
+		jobGroup.POST("/:id/apply", h.handleApply)
+		jobGroup.GET("/:id/applications", h.handleListJobApplications)
+		jobGroup.POST("/:id/applications/stage", h.handleBulkMoveStage)
+		jobGroup.GET("/:id/funnel", h.handleGetFunnel)
+		jobGroup.GET("/:id/pipeline", h.handleGetPipeline)
+		jobGroup.PUT("/:id/pipeline", h.handleUpdatePipeline)
+		jobGroup.GET("/:id/lifecycle", h.handleListStatusTransitions)
+	}
+
+	applicationGroup := r.Group("/api/v1/applications", h.auth)
+	{
+		applicationGroup.GET("/mine", h.handleListMyApplications)
+		applicationGroup.POST("/:id/withdraw", h.handleWithdraw)
+		applicationGroup.PUT("/:id/stage", h.handleMoveStage)
+		applicationGroup.GET("/:id/history", h.handleGetStageHistory)
+	}
+}
+
+func (h *jobHandler) handleApply(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	var req ApplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	application, err := h.service.Apply(c.Request.Context(), currentUserID(c), jobID, req)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "申请职位成功", application)
+}
+
+func (h *jobHandler) handleWithdraw(c *gin.Context) {
+	applicationID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的申请ID")
+		return
+	}
+
+	var req WithdrawRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+			return
+		}
+	}
+
+	application, err := h.service.Withdraw(c.Request.Context(), currentUserID(c), applicationID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "撤回申请成功", application)
+}
+
+func (h *jobHandler) handleListMyApplications(c *gin.Context) {
+	items, err := h.service.ListUserApplications(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, response.CodeInternalError, err.Error())
+		return
+	}
+	writeSuccess(c, "获取申请列表成功", items)
+}
+
+func (h *jobHandler) handleListJobApplications(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.service.ListJobApplications(c.Request.Context(), currentUserID(c), c.GetString("role"),
+		jobID, strings.TrimSpace(c.Query("stage")), page, size)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "获取职位申请成功", result)
+}
+
+func (h *jobHandler) handleMoveStage(c *gin.Context) {
+	applicationID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的申请ID")
+		return
+	}
+
+	var req StageChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	application, err := h.service.MoveStage(c.Request.Context(), currentUserID(c), c.GetString("role"), applicationID, req)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "申请阶段已更新", application)
+}
+
+func (h *jobHandler) handleBulkMoveStage(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	var req BulkStageChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	results, err := h.service.BulkMoveStage(c.Request.Context(), currentUserID(c), c.GetString("role"), jobID, req)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	writeSuccess(c, "批量更新申请阶段完成", gin.H{
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+func (h *jobHandler) handleGetStageHistory(c *gin.Context) {
+	applicationID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的申请ID")
+		return
+	}
+
+	history, err := h.service.GetStageHistory(c.Request.Context(), currentUserID(c), c.GetString("role"), applicationID)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "获取阶段记录成功", history)
+}
+
+func (h *jobHandler) handleGetFunnel(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	funnel, err := h.service.GetFunnel(c.Request.Context(), currentUserID(c), c.GetString("role"), jobID)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "获取申请漏斗成功", funnel)
+}
+
+func (h *jobHandler) handleGetPipeline(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	pipeline, err := h.service.GetPipeline(c.Request.Context(), jobID)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "获取招聘流程成功", pipeline)
+}
+
+func (h *jobHandler) handleUpdatePipeline(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	var pipeline ApplicationPipeline
+	if err := c.ShouldBindJSON(&pipeline); err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	updated, err := h.service.UpdatePipeline(c.Request.Context(), jobID, currentUserID(c), c.GetString("role"), pipeline)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "招聘流程已更新", updated)
+}
+
+func (h *jobHandler) handleListStatusTransitions(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	transitions, err := h.service.ListStatusTransitions(c.Request.Context(), currentUserID(c), c.GetString("role"), jobID)
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeSuccess(c, "获取职位状态变更记录成功", transitions)
+}
+
+// currentUserID 认证中间件写入的用户ID可能是int或uint
+func currentUserID(c *gin.Context) uint {
+	value, _ := c.Get("user_id")
+	switch v := value.(type) {
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	case int64:
+		return uint(v)
+	}
+	return 0
+}
+
+func writeApplicationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(c, http.StatusNotFound, response.CodeNotFound, "职位不存在")
+	case errors.Is(err, ErrApplicationNotFound):
+		writeError(c, http.StatusNotFound, response.CodeNotFound, err.Error())
+	case errors.Is(err, ErrApplicationForbidden):
+		writeError(c, http.StatusForbidden, response.CodeForbidden, err.Error())
+	case errors.Is(err, ErrAlreadyApplied):
+		writeError(c, http.StatusConflict, response.CodeInvalidParams, err.Error())
+	case errors.Is(err, ErrJobNotOpen), errors.Is(err, ErrInvalidStageTransition), errors.Is(err, ErrInvalidPipeline):
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+	default:
+		writeError(c, http.StatusInternalServerError, response.CodeInternalError, err.Error())
+	}
+}
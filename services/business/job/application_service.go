@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// managerRoles 可以处理任意职位申请的系统角色
+var managerRoles = map[string]bool{"admin": true, "super_admin": true}
+
+// SetEventPublisher 设置申请事件投递器
+func (s *JobService) SetEventPublisher(publisher *EventPublisher) {
+	s.events = publisher
+}
+
+// EnsureApplicationSchema 创建招聘流程与阶段记录表
+func (s *JobService) EnsureApplicationSchema(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&JobPipeline{}, &JobApplicationStageHistory{})
+}
+
+// GetPipeline 获取职位的招聘流程
+func (s *JobService) GetPipeline(ctx context.Context, jobID uint) (ApplicationPipeline, error) {
+	return s.loadPipeline(s.db.WithContext(ctx), jobID)
+}
+
+// UpdatePipeline 更新职位的招聘流程，仍有申请处于被删除的阶段时拒绝更新
+func (s *JobService) UpdatePipeline(ctx context.Context, jobID, userID uint, role string, pipeline ApplicationPipeline) (ApplicationPipeline, error) {
+	if err := pipeline.Validate(); err != nil {
+		return ApplicationPipeline{}, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		job, err := s.loadJob(tx, jobID)
+		if err != nil {
+			return err
+		}
+		if !canManageJob(job, userID, role) {
+			return ErrApplicationForbidden
+		}
+
+		var stages []string
+		if err := tx.Model(&JobApplication{}).Where("job_id = ?", jobID).
+			Distinct().Pluck("COALESCE(application_stage, '')", &stages).Error; err != nil {
+			return err
+		}
+		for _, stage := range stages {
+			if stage == "" {
+				stage = StageApplied
+			}
+			if stage != StageWithdrawn && !pipeline.HasStage(stage) {
+				return fmt.Errorf("%w: 仍有申请处于阶段 %s", ErrInvalidPipeline, stage)
+			}
+		}
+
+		config, _ := json.Marshal(pipeline)
+		record := JobPipeline{JobID: jobID, Config: string(config), UpdatedBy: userID, UpdatedAt: time.Now()}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error
+	})
+	if err != nil {
+		return ApplicationPipeline{}, err
+	}
+	return pipeline, nil
+}
+
+// Apply 候选人申请职位，撤回后可以重新申请
+func (s *JobService) Apply(ctx context.Context, userID, jobID uint, req ApplyRequest) (JobApplication, error) {
+	var application JobApplication
+	var event ApplicationStageEvent
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		job, err := s.loadJob(tx, jobID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if job.Status != JobStatusPublished && job.Status != JobStatusOpen {
+			return ErrJobNotOpen
+		}
+		if job.ExpireAt != nil && !job.ExpireAt.After(now) {
+			return ErrJobNotOpen
+		}
+
+		resumeID := req.ResumeID
+		source := strings.TrimSpace(req.Source)
+		if source == "" {
+			source = "web"
+		}
+
+		fromStage := ""
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("job_id = ? AND user_id = ?", jobID, userID).First(&application).Error
+		switch {
+		case err == nil:
+			if application.currentStage() != StageWithdrawn {
+				return ErrAlreadyApplied
+			}
+			fromStage = StageWithdrawn
+			application.ResumeID = &resumeID
+			application.CoverLetter = req.CoverLetter
+			application.ApplicationSource = source
+			application.Status = statusForStage(StageApplied)
+			application.ApplicationStage = StageApplied
+			application.ReviewedBy = nil
+			application.ReviewedAt = nil
+			application.ReviewNotes = ""
+			application.AppliedAt = now
+			application.UpdatedAt = now
+			if err := tx.Save(&application).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			application = JobApplication{
+				JobID:             jobID,
+				UserID:            userID,
+				ResumeID:          &resumeID,
+				CoverLetter:       req.CoverLetter,
+				ApplicationSource: source,
+				Status:            statusForStage(StageApplied),
+				ApplicationStage:  StageApplied,
+				AppliedAt:         now,
+				UpdatedAt:         now,
+			}
+			if err := tx.Create(&application).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&Job{}).Where("id = ?", jobID).
+				UpdateColumn("apply_count", gorm.Expr("apply_count + 1")).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if err := recordStageChange(tx, application, fromStage, StageApplied, userID, ""); err != nil {
+			return err
+		}
+		event = newStageEvent(job, application, fromStage, StageApplied, userID, "")
+		return nil
+	})
+	if err != nil {
+		return JobApplication{}, err
+	}
+
+	s.events.Publish(event)
+	return application, nil
+}
+
+// Withdraw 候选人撤回申请
+func (s *JobService) Withdraw(ctx context.Context, userID, applicationID uint, reason string) (JobApplication, error) {
+	var application JobApplication
+	var event ApplicationStageEvent
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		application, err = lockApplication(tx, applicationID)
+		if err != nil {
+			return err
+		}
+		if application.UserID != userID {
+			return ErrApplicationNotFound
+		}
+
+		pipeline, err := s.loadPipeline(tx, application.JobID)
+		if err != nil {
+			return err
+		}
+		fromStage := application.currentStage()
+		if pipeline.IsClosed(fromStage) {
+			return fmt.Errorf("%w: 申请已处于 %s 阶段，不能撤回", ErrInvalidStageTransition, fromStage)
+		}
+
+		job, err := s.loadJob(tx, application.JobID)
+		if err != nil {
+			return err
+		}
+		if err := updateApplicationStage(tx, &application, StageWithdrawn, nil, ""); err != nil {
+			return err
+		}
+		if err := recordStageChange(tx, application, fromStage, StageWithdrawn, userID, reason); err != nil {
+			return err
+		}
+		event = newStageEvent(job, application, fromStage, StageWithdrawn, userID, reason)
+		return nil
+	})
+	if err != nil {
+		return JobApplication{}, err
+	}
+
+	s.events.Publish(event)
+	return application, nil
+}
+
+// MoveStage 招聘方变更申请阶段
+func (s *JobService) MoveStage(ctx context.Context, recruiterID uint, role string, applicationID uint, req StageChangeRequest) (JobApplication, error) {
+	var application JobApplication
+	var event ApplicationStageEvent
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		application, event, err = s.moveStageTx(tx, recruiterID, role, 0, applicationID, req.Stage, req.Note)
+		return err
+	})
+	if err != nil {
+		return JobApplication{}, err
+	}
+
+	s.events.Publish(event)
+	return application, nil
+}
+
+// BulkMoveStage 批量变更同一职位下申请的阶段，每个申请单独提交，返回逐个结果
+func (s *JobService) BulkMoveStage(ctx context.Context, recruiterID uint, role string, jobID uint, req BulkStageChangeRequest) ([]BulkStageResult, error) {
+	job, err := s.loadJob(s.db.WithContext(ctx), jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageJob(job, recruiterID, role) {
+		return nil, ErrApplicationForbidden
+	}
+
+	results := make([]BulkStageResult, 0, len(req.ApplicationIDs))
+	var events []ApplicationStageEvent
+	for _, applicationID := range req.ApplicationIDs {
+		result := BulkStageResult{ApplicationID: applicationID}
+		var event ApplicationStageEvent
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			_, event, err = s.moveStageTx(tx, recruiterID, role, jobID, applicationID, req.Stage, req.Note)
+			return err
+		})
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+			result.FromStage = event.FromStage
+			events = append(events, event)
+		}
+		results = append(results, result)
+	}
+
+	s.events.Publish(events...)
+	return results, nil
+}
+
+// moveStageTx jobID不为0时要求申请属于该职位
+func (s *JobService) moveStageTx(tx *gorm.DB, recruiterID uint, role string, jobID, applicationID uint, toStage, note string) (JobApplication, ApplicationStageEvent, error) {
+	application, err := lockApplication(tx, applicationID)
+	if err != nil {
+		return application, ApplicationStageEvent{}, err
+	}
+	if jobID != 0 && application.JobID != jobID {
+		return application, ApplicationStageEvent{}, ErrApplicationNotFound
+	}
+
+	job, err := s.loadJob(tx, application.JobID)
+	if err != nil {
+		return application, ApplicationStageEvent{}, err
+	}
+	if !canManageJob(job, recruiterID, role) {
+		return application, ApplicationStageEvent{}, ErrApplicationForbidden
+	}
+
+	pipeline, err := s.loadPipeline(tx, application.JobID)
+	if err != nil {
+		return application, ApplicationStageEvent{}, err
+	}
+	toStage = strings.TrimSpace(toStage)
+	fromStage := application.currentStage()
+	if !pipeline.HasStage(toStage) || !pipeline.CanTransition(fromStage, toStage) {
+		return application, ApplicationStageEvent{}, fmt.Errorf("%w: %s -> %s", ErrInvalidStageTransition, fromStage, toStage)
+	}
+
+	if err := updateApplicationStage(tx, &application, toStage, &recruiterID, note); err != nil {
+		return application, ApplicationStageEvent{}, err
+	}
+	if err := recordStageChange(tx, application, fromStage, toStage, recruiterID, note); err != nil {
+		return application, ApplicationStageEvent{}, err
+	}
+	return application, newStageEvent(job, application, fromStage, toStage, recruiterID, note), nil
+}
+
+// ListJobApplications 招聘方查看职位的申请，stage为空时返回全部
+func (s *JobService) ListJobApplications(ctx context.Context, userID uint, role string, jobID uint, stage string, page, pageSize int) (ApplicationListResult, error) {
+	db := s.db.WithContext(ctx)
+	job, err := s.loadJob(db, jobID)
+	if err != nil {
+		return ApplicationListResult{}, err
+	}
+	if !canManageJob(job, userID, role) {
+		return ApplicationListResult{}, ErrApplicationForbidden
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := db.Model(&JobApplication{}).Where("job_id = ?", jobID)
+	switch stage {
+	case "":
+	case StageApplied:
+		query = query.Where("(application_stage = ? OR application_stage IS NULL OR application_stage = '')", StageApplied)
+	default:
+		query = query.Where("application_stage = ?", stage)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return ApplicationListResult{}, err
+	}
+
+	items := []JobApplication{}
+	if err := query.Order("applied_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		return ApplicationListResult{}, err
+	}
+	return ApplicationListResult{Items: items, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// ListUserApplications 候选人的申请列表
+func (s *JobService) ListUserApplications(ctx context.Context, userID uint) ([]JobApplication, error) {
+	items := []JobApplication{}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("applied_at DESC").Find(&items).Error
+	return items, err
+}
+
+// GetStageHistory 申请的阶段变更记录，候选人本人与招聘方可见
+func (s *JobService) GetStageHistory(ctx context.Context, userID uint, role string, applicationID uint) ([]JobApplicationStageHistory, error) {
+	db := s.db.WithContext(ctx)
+	var application JobApplication
+	if err := db.First(&application, applicationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrApplicationNotFound
+		}
+		return nil, err
+	}
+	if application.UserID != userID {
+		job, err := s.loadJob(db, application.JobID)
+		if err != nil {
+			return nil, err
+		}
+		if !canManageJob(job, userID, role) {
+			return nil, ErrApplicationNotFound
+		}
+	}
+
+	history := []JobApplicationStageHistory{}
+	err := db.Where("application_id = ?", applicationID).Order("created_at ASC, id ASC").Find(&history).Error
+	return history, err
+}
+
+// GetFunnel 职位申请漏斗：各阶段当前数量与累计进入数量
+func (s *JobService) GetFunnel(ctx context.Context, userID uint, role string, jobID uint) (JobFunnel, error) {
+	db := s.db.WithContext(ctx)
+	job, err := s.loadJob(db, jobID)
+	if err != nil {
+		return JobFunnel{}, err
+	}
+	if !canManageJob(job, userID, role) {
+		return JobFunnel{}, ErrApplicationForbidden
+	}
+	pipeline, err := s.loadPipeline(db, jobID)
+	if err != nil {
+		return JobFunnel{}, err
+	}
+
+	type stageCount struct {
+		Stage string
+		Count int64
+	}
+
+	// 旧数据的 application_stage 可能为空，按初始阶段统计
+	stageExpr := fmt.Sprintf("COALESCE(NULLIF(application_stage, ''), '%s')", StageApplied)
+	var current []stageCount
+	if err := db.Model(&JobApplication{}).
+		Select(stageExpr+" AS stage, COUNT(*) AS count").
+		Where("job_id = ?", jobID).
+		Group(stageExpr).
+		Scan(&current).Error; err != nil {
+		return JobFunnel{}, err
+	}
+
+	var reached []stageCount
+	if err := db.Model(&JobApplicationStageHistory{}).
+		Select("to_stage AS stage, COUNT(DISTINCT application_id) AS count").
+		Where("job_id = ?", jobID).
+		Group("to_stage").
+		Scan(&reached).Error; err != nil {
+		return JobFunnel{}, err
+	}
+
+	currentByStage := make(map[string]int64, len(current))
+	funnel := JobFunnel{JobID: jobID, Stages: make([]FunnelStage, 0, len(pipeline.Stages))}
+	for _, row := range current {
+		currentByStage[row.Stage] = row.Count
+		funnel.Total += row.Count
+	}
+	reachedByStage := make(map[string]int64, len(reached))
+	for _, row := range reached {
+		reachedByStage[row.Stage] = row.Count
+	}
+
+	funnel.Withdrawn = currentByStage[StageWithdrawn]
+	for _, stage := range pipeline.Stages {
+		item := FunnelStage{Stage: stage, Current: currentByStage[stage], Reached: reachedByStage[stage]}
+		// 早于阶段记录的申请都经过了初始阶段
+		if stage == StageApplied {
+			item.Reached = funnel.Total
+		}
+		funnel.Stages = append(funnel.Stages, item)
+	}
+	return funnel, nil
+}
+
+func (s *JobService) loadJob(db *gorm.DB, jobID uint) (Job, error) {
+	var job Job
+	err := db.First(&job, jobID).Error
+	return job, err
+}
+
+func (s *JobService) loadPipeline(db *gorm.DB, jobID uint) (ApplicationPipeline, error) {
+	var records []JobPipeline
+	if err := db.Where("job_id = ?", jobID).Limit(1).Find(&records).Error; err != nil {
+		return ApplicationPipeline{}, err
+	}
+	if len(records) == 0 {
+		return DefaultApplicationPipeline(), nil
+	}
+
+	var pipeline ApplicationPipeline
+	if err := json.Unmarshal([]byte(records[0].Config), &pipeline); err != nil {
+		return ApplicationPipeline{}, fmt.Errorf("%w: %v", ErrInvalidPipeline, err)
+	}
+	return pipeline, nil
+}
+
+func lockApplication(tx *gorm.DB, applicationID uint) (JobApplication, error) {
+	var application JobApplication
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&application, applicationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return application, ErrApplicationNotFound
+	}
+	return application, err
+}
+
+func updateApplicationStage(tx *gorm.DB, application *JobApplication, stage string, reviewer *uint, note string) error {
+	now := time.Now()
+	application.ApplicationStage = stage
+	application.Status = statusForStage(stage)
+	application.UpdatedAt = now
+	updates := map[string]interface{}{
+		"application_stage": stage,
+		"status":            application.Status,
+		"updated_at":        now,
+	}
+	if reviewer != nil {
+		application.ReviewedBy = reviewer
+		application.ReviewedAt = &now
+		updates["reviewed_by"] = *reviewer
+		updates["reviewed_at"] = now
+		if note != "" {
+			application.ReviewNotes = note
+			updates["review_notes"] = note
+		}
+	}
+	return tx.Model(&JobApplication{}).Where("id = ?", application.ID).Updates(updates).Error
+}
+
+func recordStageChange(tx *gorm.DB, application JobApplication, fromStage, toStage string, changedBy uint, note string) error {
+	return tx.Create(&JobApplicationStageHistory{
+		ApplicationID: application.ID,
+		JobID:         application.JobID,
+		FromStage:     fromStage,
+		ToStage:       toStage,
+		ChangedBy:     changedBy,
+		Note:          note,
+		CreatedAt:     time.Now(),
+	}).Error
+}
+
+func newStageEvent(job Job, application JobApplication, fromStage, toStage string, changedBy uint, note string) ApplicationStageEvent {
+	return ApplicationStageEvent{
+		ApplicationID: application.ID,
+		JobID:         job.ID,
+		JobTitle:      job.Title,
+		CompanyName:   job.CompanyName,
+		CandidateID:   application.UserID,
+		RecruiterID:   uint(job.CreatedBy),
+		FromStage:     fromStage,
+		ToStage:       toStage,
+		ChangedBy:     changedBy,
+		Note:          note,
+		OccurredAt:    time.Now(),
+	}
+}
+
+// canManageJob 职位创建者与系统管理员可以处理职位的申请
+func canManageJob(job Job, userID uint, role string) bool {
+	if managerRoles[role] {
+		return true
+	}
+	return userID != 0 && job.CreatedBy == int64(userID)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ApplicationStageEvent 申请阶段变更事件，由通知服务 /api/v1/events/application-stage-changed 消费
+type ApplicationStageEvent struct {
+	ApplicationID uint      `json:"application_id"`
+	JobID         uint      `json:"job_id"`
+	JobTitle      string    `json:"job_title"`
+	CompanyName   string    `json:"company_name"`
+	CandidateID   uint      `json:"candidate_id"`
+	RecruiterID   uint      `json:"recruiter_id"`
+	FromStage     string    `json:"from_stage"`
+	ToStage       string    `json:"to_stage"`
+	ChangedBy     uint      `json:"changed_by"`
+	Note          string    `json:"note,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// JobLifecycleEvent 职位自动状态变更或到期提醒事件，由通知服务 /api/v1/events/job-lifecycle 消费
+type JobLifecycleEvent struct {
+	JobID       uint       `json:"job_id"`
+	JobTitle    string     `json:"job_title"`
+	CompanyName string     `json:"company_name"`
+	CreatorID   uint       `json:"creator_id"`
+	Reason      string     `json:"reason"`
+	FromStatus  string     `json:"from_status"`
+	ToStatus    string     `json:"to_status"`
+	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	OccurredAt  time.Time  `json:"occurred_at"`
+}
+
+// JobAlertEvent 保存的搜索命中新职位的提醒，由通知服务 /api/v1/events/job-alert 消费
+type JobAlertEvent struct {
+	UserID         uint           `json:"user_id"`
+	SavedSearchID  uint           `json:"saved_search_id"`
+	SearchName     string         `json:"search_name"`
+	Frequency      string         `json:"frequency"`
+	Jobs           []JobAlertItem `json:"jobs"`
+	Total          int            `json:"total"`
+	UnsubscribeURL string         `json:"unsubscribe_url"`
+	OccurredAt     time.Time      `json:"occurred_at"`
+}
+
+// JobAlertItem 提醒中列出的职位
+type JobAlertItem struct {
+	JobID       uint   `json:"job_id"`
+	Title       string `json:"title"`
+	CompanyName string `json:"company_name"`
+	Location    string `json:"location"`
+	Salary      string `json:"salary"`
+}
+
+const (
+	applicationStageEventPath = "/api/v1/events/application-stage-changed"
+	jobLifecycleEventPath     = "/api/v1/events/job-lifecycle"
+	jobAlertEventPath         = "/api/v1/events/job-alert"
+)
+
+// EventPublisher 向通知服务投递职位相关事件
+type EventPublisher struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewEventPublisher 创建事件投递器，baseURL为空时读取 NOTIFICATION_SERVICE_URL
+func NewEventPublisher(baseURL string) *EventPublisher {
+	if baseURL == "" {
+		baseURL = os.Getenv("NOTIFICATION_SERVICE_URL")
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:8605"
+	}
+	return &EventPublisher{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// Publish 异步投递申请阶段事件，失败只记录日志，不影响申请流程
+func (p *EventPublisher) Publish(events ...ApplicationStageEvent) {
+	if p == nil || len(events) == 0 {
+		return
+	}
+	go func() {
+		for _, event := range events {
+			if err := p.send(applicationStageEventPath, event); err != nil {
+				log.Printf("WARN: 投递申请阶段事件失败 application=%d %s->%s: %v",
+					event.ApplicationID, event.FromStage, event.ToStage, err)
+			}
+		}
+	}()
+}
+
+// PublishLifecycle 同步投递职位生命周期事件，返回投递错误供调度器决定是否重试
+func (p *EventPublisher) PublishLifecycle(event JobLifecycleEvent) error {
+	if p == nil {
+		return nil
+	}
+	return p.send(jobLifecycleEventPath, event)
+}
+
+// PublishAlert 同步投递职位提醒，失败时命中记录保持待提醒，下一轮重试
+func (p *EventPublisher) PublishAlert(event JobAlertEvent) error {
+	if p == nil {
+		return nil
+	}
+	return p.send(jobAlertEventPath, event)
+}
+
+func (p *EventPublisher) send(path string, event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Post(p.baseURL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("通知服务返回错误状态: %d", resp.StatusCode)
+	}
+	return nil
+}
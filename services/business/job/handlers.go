@@ -13,10 +13,11 @@ import (
 
 type jobHandler struct {
 	service *JobService
+	auth    gin.HandlerFunc
 }
 
-func newJobHandler(service *JobService) *jobHandler {
-	return &jobHandler{service: service}
+func newJobHandler(service *JobService, auth gin.HandlerFunc) *jobHandler {
+	return &jobHandler{service: service, auth: auth}
 }
 
 func (h *jobHandler) registerRoutes(r *gin.Engine) {
@@ -30,6 +31,10 @@ func (h *jobHandler) registerRoutes(r *gin.Engine) {
 		jobGroup.POST("/favorite", h.handleFavoriteJob)
 		jobGroup.DELETE("/favorite/:id", h.handleUnfavoriteJob)
 	}
+
+	h.registerApplicationRoutes(r)
+	h.registerMatchingRoutes(r)
+	h.registerAlertRoutes(r)
 }
 
 func (h *jobHandler) handleListJobs(c *gin.Context) {
@@ -43,14 +48,27 @@ func (h *jobHandler) handleListJobs(c *gin.Context) {
 }
 
 func (h *jobHandler) handleSearchJobs(c *gin.Context) {
-	filter := parseJobFilter(c)
-	filter.Keyword = strings.TrimSpace(c.Query("keyword"))
-	result, err := h.service.ListJobs(c.Request.Context(), filter)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	req := JobSearchRequest{
+		Keyword:    strings.TrimSpace(c.Query("keyword")),
+		Category:   strings.TrimSpace(c.Query("category")),
+		WorkType:   strings.TrimSpace(c.Query("workType")),
+		Location:   strings.TrimSpace(c.Query("location")),
+		SalaryBand: strings.TrimSpace(c.Query("salaryBand")),
+		Experience: strings.TrimSpace(c.Query("experience")),
+		Status:     strings.TrimSpace(c.Query("status")),
+		Sort:       strings.TrimSpace(c.Query("sort")),
+		Page:       page,
+		PageSize:   size,
+	}
+
+	result, err := h.service.SearchJobs(c.Request.Context(), req)
 	if err != nil {
 		writeError(c, http.StatusInternalServerError, response.CodeInternalError, err.Error())
 		return
 	}
-	writeSuccess(c, "搜索职位成功", result.Items)
+	writeSuccess(c, "搜索职位成功", result)
 }
 
 func (h *jobHandler) handleGetJob(c *gin.Context) {
@@ -83,6 +101,10 @@ func (h *jobHandler) handleCreateJob(c *gin.Context) {
 
 	detail, err := h.service.CreateJob(c.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, ErrInvalidJobSchedule) {
+			writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+			return
+		}
 		writeError(c, http.StatusInternalServerError, response.CodeInternalError, err.Error())
 		return
 	}
@@ -110,6 +132,9 @@ func (h *jobHandler) handleUpdateJob(c *gin.Context) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
 			code = response.CodeNotFound
+		} else if errors.Is(err, ErrInvalidJobSchedule) {
+			status = http.StatusBadRequest
+			code = response.CodeInvalidParams
 		}
 		writeError(c, status, code, err.Error())
 		return
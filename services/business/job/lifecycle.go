@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 自动状态变更原因
+const (
+	LifecycleScheduledPublish = "scheduled_publish"
+	LifecycleExpired          = "expired"
+	LifecycleHeadcountFilled  = "headcount_filled"
+	LifecycleExpiryReminder   = "expiry_reminder"
+)
+
+const (
+	lifecycleTriggeredBy = "scheduler"
+	lifecycleBatchSize   = 100
+)
+
+var ErrInvalidJobSchedule = errors.New("职位到期时间必须晚于发布时间")
+
+// JobStatusTransition 职位自动状态变更与到期提醒记录
+type JobStatusTransition struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	JobID       uint      `json:"jobId" gorm:"column:job_id;not null;index"`
+	FromStatus  string    `json:"fromStatus" gorm:"column:from_status;size:20"`
+	ToStatus    string    `json:"toStatus" gorm:"column:to_status;size:20"`
+	Reason      string    `json:"reason" gorm:"column:reason;size:50;not null"`
+	Detail      string    `json:"detail" gorm:"column:detail;size:200"`
+	TriggeredBy string    `json:"triggeredBy" gorm:"column:triggered_by;size:50"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"column:created_at"`
+}
+
+func (JobStatusTransition) TableName() string {
+	return "zervigo_job_status_transitions"
+}
+
+// LifecycleReport 一轮调度的处理结果
+type LifecycleReport struct {
+	Published int `json:"published"`
+	Expired   int `json:"expired"`
+	Filled    int `json:"filled"`
+	Reminded  int `json:"reminded"`
+}
+
+// EnsureLifecycleSchema 创建状态变更记录表、调度租约表并为职位表补充招聘人数列
+func (s *JobService) EnsureLifecycleSchema(ctx context.Context) error {
+	db := s.db.WithContext(ctx)
+	if err := db.AutoMigrate(&JobStatusTransition{}, &SchedulerLease{}); err != nil {
+		return err
+	}
+	if !db.Migrator().HasColumn(&Job{}, "Headcount") {
+		return db.Migrator().AddColumn(&Job{}, "Headcount")
+	}
+	return nil
+}
+
+// RunLifecycle 执行一轮职位生命周期处理：定时发布、到期关闭、招满关闭、到期提醒
+func (s *JobService) RunLifecycle(ctx context.Context, now time.Time, reminderWindow time.Duration) (LifecycleReport, error) {
+	var report LifecycleReport
+	db := s.db.WithContext(ctx)
+	active := []string{JobStatusPublished, JobStatusOpen}
+
+	var due []Job
+	if err := db.Where("status = ? AND publish_at IS NOT NULL AND publish_at <= ?", JobStatusDraft, now).
+		Where("expire_at IS NULL OR expire_at > ?", now).
+		Limit(lifecycleBatchSize).Find(&due).Error; err != nil {
+		return report, err
+	}
+	for _, job := range due {
+		if ok, err := s.transitionJob(ctx, job, JobStatusPublished, LifecycleScheduledPublish, now); err != nil {
+			return report, err
+		} else if ok {
+			report.Published++
+		}
+	}
+
+	var expired []Job
+	if err := db.Where("status IN ? AND expire_at IS NOT NULL AND expire_at <= ?", append(active, JobStatusPaused), now).
+		Limit(lifecycleBatchSize).Find(&expired).Error; err != nil {
+		return report, err
+	}
+	for _, job := range expired {
+		if ok, err := s.transitionJob(ctx, job, JobStatusClosed, LifecycleExpired, now); err != nil {
+			return report, err
+		} else if ok {
+			report.Expired++
+		}
+	}
+
+	var filled []Job
+	if err := db.Where("status IN ? AND headcount > 0", active).
+		Where("headcount <= (SELECT COUNT(*) FROM zervigo_job_applications a WHERE a.job_id = zervigo_jobs.id AND a.application_stage = ?)", StageHired).
+		Limit(lifecycleBatchSize).Find(&filled).Error; err != nil {
+		return report, err
+	}
+	for _, job := range filled {
+		if ok, err := s.transitionJob(ctx, job, JobStatusClosed, LifecycleHeadcountFilled, now); err != nil {
+			return report, err
+		} else if ok {
+			report.Filled++
+		}
+	}
+
+	if reminderWindow > 0 {
+		var expiring []Job
+		if err := db.Where("status IN ? AND expire_at > ? AND expire_at <= ?", active, now, now.Add(reminderWindow)).
+			Limit(lifecycleBatchSize).Find(&expiring).Error; err != nil {
+			return report, err
+		}
+		for _, job := range expiring {
+			if ok, err := s.remindExpiring(ctx, job, now); err != nil {
+				return report, err
+			} else if ok {
+				report.Reminded++
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// ListStatusTransitions 职位的自动状态变更记录
+func (s *JobService) ListStatusTransitions(ctx context.Context, userID uint, role string, jobID uint) ([]JobStatusTransition, error) {
+	db := s.db.WithContext(ctx)
+	job, err := s.loadJob(db, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageJob(job, userID, role) {
+		return nil, ErrApplicationForbidden
+	}
+
+	transitions := []JobStatusTransition{}
+	err = db.Where("job_id = ?", jobID).Order("created_at DESC, id DESC").Find(&transitions).Error
+	return transitions, err
+}
+
+// transitionJob 仅当职位仍处于读取时的状态才变更，多个副本或人工操作并发时不会重复处理
+func (s *JobService) transitionJob(ctx context.Context, job Job, toStatus, reason string, now time.Time) (bool, error) {
+	changed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Job{}).Where("id = ? AND status = ?", job.ID, job.Status).
+			Updates(map[string]interface{}{"status": toStatus, "updated_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		changed = true
+		return tx.Create(&JobStatusTransition{
+			JobID:       job.ID,
+			FromStatus:  job.Status,
+			ToStatus:    toStatus,
+			Reason:      reason,
+			TriggeredBy: lifecycleTriggeredBy,
+			CreatedAt:   now,
+		}).Error
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	log.Printf("职位 %d 自动变更状态 %s -> %s (%s)", job.ID, job.Status, toStatus, reason)
+	if err := s.events.PublishLifecycle(newLifecycleEvent(job, toStatus, reason, now)); err != nil {
+		log.Printf("WARN: 投递职位生命周期事件失败 job=%d reason=%s: %v", job.ID, reason, err)
+	}
+	if isActiveJobStatus(toStatus) {
+		job.Status = toStatus
+		s.onJobPublished(job)
+	}
+	return true, nil
+}
+
+// remindExpiring 每个到期时间只提醒一次；投递失败时不记录，下一轮重试
+func (s *JobService) remindExpiring(ctx context.Context, job Job, now time.Time) (bool, error) {
+	detail := job.ExpireAt.UTC().Format(time.RFC3339)
+	db := s.db.WithContext(ctx)
+
+	var count int64
+	if err := db.Model(&JobStatusTransition{}).
+		Where("job_id = ? AND reason = ? AND detail = ?", job.ID, LifecycleExpiryReminder, detail).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if err := s.events.PublishLifecycle(newLifecycleEvent(job, job.Status, LifecycleExpiryReminder, now)); err != nil {
+		log.Printf("WARN: 投递职位到期提醒失败 job=%d: %v", job.ID, err)
+		return false, nil
+	}
+	err := db.Create(&JobStatusTransition{
+		JobID:       job.ID,
+		FromStatus:  job.Status,
+		ToStatus:    job.Status,
+		Reason:      LifecycleExpiryReminder,
+		Detail:      detail,
+		TriggeredBy: lifecycleTriggeredBy,
+		CreatedAt:   now,
+	}).Error
+	return err == nil, err
+}
+
+func newLifecycleEvent(job Job, toStatus, reason string, now time.Time) JobLifecycleEvent {
+	return JobLifecycleEvent{
+		JobID:       job.ID,
+		JobTitle:    job.Title,
+		CompanyName: job.CompanyName,
+		CreatorID:   uint(job.CreatedBy),
+		Reason:      reason,
+		FromStatus:  job.Status,
+		ToStatus:    toStatus,
+		ExpireAt:    job.ExpireAt,
+		OccurredAt:  now,
+	}
+}
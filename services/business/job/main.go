@@ -33,6 +33,30 @@ func main() {
 	if err := jobService.EnsureSeedData(context.Background()); err != nil {
 		log.Printf("WARN: 初始化职位种子数据失败: %v", err)
 	}
+	if err := jobService.EnsureApplicationSchema(context.Background()); err != nil {
+		log.Printf("WARN: 初始化招聘流程数据表失败: %v", err)
+	}
+	if err := jobService.EnsureSearchSchema(context.Background()); err != nil {
+		log.Printf("WARN: 初始化职位全文索引失败: %v", err)
+	}
+	if err := jobService.EnsureLifecycleSchema(context.Background()); err != nil {
+		log.Printf("WARN: 初始化职位生命周期数据表失败: %v", err)
+	}
+	if err := jobService.EnsureAlertSchema(context.Background()); err != nil {
+		log.Printf("WARN: 初始化职位提醒数据表失败: %v", err)
+	}
+	jobService.SetEventPublisher(NewEventPublisher(""))
+	if aiServiceURL := os.Getenv("AI_SERVICE_URL"); aiServiceURL != "" {
+		jobService.SetAIClient(NewAIClient(aiServiceURL))
+	}
+
+	if os.Getenv("JOB_SCHEDULER_ENABLED") != "false" {
+		interval := durationFromEnv("JOB_SCHEDULER_INTERVAL", time.Minute)
+		reminderWindow := durationFromEnv("JOB_EXPIRY_REMINDER_WINDOW", 72*time.Hour)
+		schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+		defer stopScheduler()
+		NewLifecycleScheduler(jobService, interval, reminderWindow).Start(schedulerCtx)
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
@@ -40,7 +64,7 @@ func main() {
 	r.GET("/health", healthCheck)
 	r.GET("/info", serviceInfo)
 
-	handler := newJobHandler(jobService)
+	handler := newJobHandler(jobService, core.AuthMiddleware.RequireAuth())
 	handler.registerRoutes(r)
 
 	registerToConsul("job-service", "127.0.0.1", 8084)
@@ -51,6 +75,20 @@ func main() {
 	}
 }
 
+// durationFromEnv 读取如 "30s"、"72h" 的时长配置，未设置或格式错误时使用默认值
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("WARN: 环境变量 %s=%q 格式错误，使用默认值 %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
 func registerToConsul(serviceName, serviceHost string, servicePort int) {
 	// 创建Consul客户端
 	config := api.DefaultConfig()
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrResumeNotFound    = errors.New("简历不存在")
+	ErrResumeNotParsed   = errors.New("简历尚未完成解析")
+	ErrMatchingForbidden = errors.New("无权查看该匹配结果")
+)
+
+// 匹配因子及权重（合计为1）
+const (
+	FactorSkills     = "skills"
+	FactorKeywords   = "keywords"
+	FactorExperience = "experience"
+	FactorEducation  = "education"
+	FactorLocation   = "location"
+	FactorSalary     = "salary"
+)
+
+var matchWeights = map[string]float64{
+	FactorSkills:     0.40,
+	FactorKeywords:   0.15,
+	FactorExperience: 0.20,
+	FactorEducation:  0.10,
+	FactorLocation:   0.10,
+	FactorSalary:     0.05,
+}
+
+// aiBlendWeight 外部AI匹配分可用时在综合分中的占比
+const aiBlendWeight = 0.3
+
+// unknownFactorScore 简历缺少对应信息时的中性分
+const unknownFactorScore = 0.5
+
+// skillAliases 常见技能别名，统一后再比较
+var skillAliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"ts":         "typescript",
+	"postgres":   "postgresql",
+	"pg":         "postgresql",
+	"k8s":        "kubernetes",
+	"reactjs":    "react",
+	"react.js":   "react",
+	"vuejs":      "vue",
+	"vue.js":     "vue",
+	"nodejs":     "node",
+	"node.js":    "node",
+	"py":         "python",
+	"springboot": "spring boot",
+}
+
+// educationLevels 学历等级，数字越大学历越高
+var educationLevels = []struct {
+	Level    int
+	Name     string
+	Keywords []string
+}{
+	{5, "博士", []string{"博士", "phd", "doctor"}},
+	{4, "硕士", []string{"硕士", "研究生", "master", "mba"}},
+	{3, "本科", []string{"本科", "学士", "bachelor"}},
+	{2, "大专", []string{"大专", "专科", "associate", "college"}},
+	{1, "高中", []string{"高中", "中专", "中技", "high school"}},
+}
+
+// ResumeRecord 简历元数据（由简历服务维护的 resume_metadata 表，只读）
+type ResumeRecord struct {
+	ID            uint      `gorm:"column:id;primaryKey"`
+	UserID        uint      `gorm:"column:user_id"`
+	Title         string    `gorm:"column:title"`
+	Status        string    `gorm:"column:status"`
+	ParsingStatus string    `gorm:"column:parsing_status"`
+	ParsedData    string    `gorm:"column:parsed_data"`
+	IsPublic      bool      `gorm:"column:is_public"`
+	UpdatedAt     time.Time `gorm:"column:updated_at"`
+}
+
+func (ResumeRecord) TableName() string {
+	return "resume_metadata"
+}
+
+// ResumeProfile 从解析结果中提取的匹配画像
+type ResumeProfile struct {
+	ResumeID        uint     `json:"resumeId"`
+	UserID          uint     `json:"userId"`
+	Title           string   `json:"title"`
+	Skills          []string `json:"skills"`
+	Keywords        []string `json:"keywords"`
+	YearsExperience float64  `json:"yearsExperience"`
+	EducationLevel  int      `json:"educationLevel"`
+	Education       string   `json:"education"`
+	Location        string   `json:"location"`
+	ExpectedSalary  int      `json:"expectedSalary"` // 期望月薪，0表示未知
+}
+
+// MatchFactor 单个匹配因子的得分与说明
+type MatchFactor struct {
+	Factor  string   `json:"factor"`
+	Score   float64  `json:"score"` // 0-1
+	Weight  float64  `json:"weight"`
+	Detail  string   `json:"detail"`
+	Matched []string `json:"matched,omitempty"`
+	Missing []string `json:"missing,omitempty"`
+}
+
+// MatchResult 简历与职位的匹配结果，Score为0-100
+type MatchResult struct {
+	JobID     uint          `json:"jobId"`
+	ResumeID  uint          `json:"resumeId"`
+	UserID    uint          `json:"userId"`
+	Score     float64       `json:"score"`
+	RuleScore float64       `json:"ruleScore"`
+	AIScore   *float64      `json:"aiScore,omitempty"`
+	AIReason  string        `json:"aiReason,omitempty"`
+	Factors   []MatchFactor `json:"factors"`
+	Job       *JobSummary   `json:"job,omitempty"`
+	Resume    *ResumeBrief  `json:"resume,omitempty"`
+}
+
+// ResumeBrief 候选人列表中的简历摘要
+type ResumeBrief struct {
+	Title           string   `json:"title"`
+	Skills          []string `json:"skills"`
+	YearsExperience float64  `json:"yearsExperience"`
+	Education       string   `json:"education"`
+	Location        string   `json:"location"`
+	Applied         bool     `json:"applied"`
+}
+
+// MatchListResult 匹配列表
+type MatchListResult struct {
+	Items       []MatchResult `json:"items"`
+	Evaluated   int           `json:"evaluated"`
+	AIBlended   bool          `json:"aiBlended"`
+	AIError     string        `json:"aiError,omitempty"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+}
+
+// newResumeProfile 解析 parsed_data，字段名兼容MinerU与敏感信息感知解析器的输出
+func newResumeProfile(record ResumeRecord) (ResumeProfile, error) {
+	profile := ResumeProfile{ResumeID: record.ID, UserID: record.UserID, Title: record.Title}
+	if strings.TrimSpace(record.ParsedData) == "" {
+		return profile, ErrResumeNotParsed
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(record.ParsedData), &data); err != nil {
+		return profile, fmt.Errorf("%w: %v", ErrResumeNotParsed, err)
+	}
+	personal, _ := data["personal_info"].(map[string]interface{})
+	intention, _ := data["job_intention"].(map[string]interface{})
+
+	profile.Skills = normalizeSkills(stringList(data["skills"]))
+	profile.Keywords = stringList(data["keywords"])
+
+	if years, ok := firstNumber(data, "years_of_experience", "work_years", "total_experience_years"); ok {
+		profile.YearsExperience = years
+	} else if years, ok := firstNumber(personal, "years_of_experience", "work_years"); ok {
+		profile.YearsExperience = years
+	} else {
+		profile.YearsExperience = experienceYears(data["work_experience"])
+	}
+
+	for _, entry := range mapList(data["education"]) {
+		for _, key := range []string{"degree", "education", "level"} {
+			if level, name := educationLevel(stringValue(entry[key])); level > profile.EducationLevel {
+				profile.EducationLevel, profile.Education = level, name
+			}
+		}
+	}
+	if profile.EducationLevel == 0 {
+		profile.EducationLevel, profile.Education = educationLevel(stringValue(personal["education"]))
+	}
+
+	profile.Location = firstString(
+		stringValue(intention["location"]), stringValue(intention["city"]),
+		stringValue(data["expected_location"]), stringValue(data["location"]),
+		stringValue(personal["location"]), stringValue(personal["city"]), stringValue(personal["address"]),
+	)
+	profile.ExpectedSalary = parseMonthlySalary(firstString(
+		stringValue(intention["salary"]), stringValue(intention["expected_salary"]),
+		stringValue(data["expected_salary"]), stringValue(personal["expected_salary"]),
+	))
+	return profile, nil
+}
+
+// scoreMatch 按各因子计算规则匹配分
+func scoreMatch(profile ResumeProfile, job Job) MatchResult {
+	factors := []MatchFactor{
+		scoreSkills(profile, job),
+		scoreKeywords(profile, job),
+		scoreExperience(profile, job),
+		scoreEducation(profile, job),
+		scoreLocation(profile, job),
+		scoreSalary(profile, job),
+	}
+
+	total := 0.0
+	for i := range factors {
+		factors[i].Weight = matchWeights[factors[i].Factor]
+		factors[i].Score = roundScore(factors[i].Score)
+		total += factors[i].Score * factors[i].Weight
+	}
+	ruleScore := roundScore(total * 100)
+
+	return MatchResult{
+		JobID:     job.ID,
+		ResumeID:  profile.ResumeID,
+		UserID:    profile.UserID,
+		Score:     ruleScore,
+		RuleScore: ruleScore,
+		Factors:   factors,
+	}
+}
+
+// blendAIScore 把外部AI分（0-1或0-100）按 aiBlendWeight 混入综合分
+func (r *MatchResult) blendAIScore(aiScore float64, reason string) {
+	if aiScore <= 1 {
+		aiScore *= 100
+	}
+	aiScore = math.Max(0, math.Min(100, aiScore))
+	rounded := roundScore(aiScore)
+	r.AIScore = &rounded
+	r.AIReason = reason
+	r.Score = roundScore(r.RuleScore*(1-aiBlendWeight) + aiScore*aiBlendWeight)
+}
+
+func scoreSkills(profile ResumeProfile, job Job) MatchFactor {
+	factor := MatchFactor{Factor: FactorSkills}
+	required := normalizeSkills(job.SkillsRequired)
+	if len(required) == 0 {
+		factor.Score = 1
+		factor.Detail = "职位未列出技能要求"
+		return factor
+	}
+	if len(profile.Skills) == 0 {
+		factor.Score = 0
+		factor.Missing = required
+		factor.Detail = "简历中未识别到技能"
+		return factor
+	}
+
+	for _, skill := range required {
+		if hasSkill(profile.Skills, skill) {
+			factor.Matched = append(factor.Matched, skill)
+		} else {
+			factor.Missing = append(factor.Missing, skill)
+		}
+	}
+	factor.Score = float64(len(factor.Matched)) / float64(len(required))
+	factor.Detail = fmt.Sprintf("满足 %d/%d 项技能要求", len(factor.Matched), len(required))
+	return factor
+}
+
+func scoreKeywords(profile ResumeProfile, job Job) MatchFactor {
+	factor := MatchFactor{Factor: FactorKeywords}
+	jobTerms := uniqueTokens(tokenizeForSearch(job.Title + " " + job.Requirements))
+	if len(jobTerms) == 0 {
+		factor.Score = 1
+		factor.Detail = "职位描述没有可比较的关键词"
+		return factor
+	}
+
+	resumeText := profile.Title + " " + strings.Join(profile.Skills, " ") + " " + strings.Join(profile.Keywords, " ")
+	resumeTerms := make(map[string]bool)
+	for _, term := range tokenizeForSearch(resumeText) {
+		resumeTerms[term] = true
+	}
+
+	hits := 0
+	for _, term := range jobTerms {
+		if resumeTerms[term] {
+			hits++
+		}
+	}
+	// 职位描述通常远长于简历关键词，命中一半即视为完全覆盖
+	factor.Score = math.Min(1, float64(hits)/(float64(len(jobTerms))*0.5))
+	factor.Detail = fmt.Sprintf("命中职位标题与要求中 %d/%d 个关键词", hits, len(jobTerms))
+	return factor
+}
+
+func scoreExperience(profile ResumeProfile, job Job) MatchFactor {
+	factor := MatchFactor{Factor: FactorExperience}
+	required, ok := parseRequiredYears(job.ExperienceRequired)
+	switch {
+	case !ok || required == 0:
+		factor.Score = 1
+		factor.Detail = "职位不限工作经验"
+	case profile.YearsExperience >= required:
+		factor.Score = 1
+		factor.Detail = fmt.Sprintf("%.1f 年经验，满足 %s 的要求", profile.YearsExperience, job.ExperienceRequired)
+	default:
+		factor.Score = profile.YearsExperience / required
+		factor.Detail = fmt.Sprintf("%.1f 年经验，低于 %s 的要求", profile.YearsExperience, job.ExperienceRequired)
+	}
+	return factor
+}
+
+func scoreEducation(profile ResumeProfile, job Job) MatchFactor {
+	factor := MatchFactor{Factor: FactorEducation}
+	required, requiredName := educationLevel(job.EducationRequired)
+	switch {
+	case required == 0:
+		factor.Score = 1
+		factor.Detail = "职位不限学历"
+	case profile.EducationLevel == 0:
+		factor.Score = unknownFactorScore
+		factor.Detail = "简历中未识别到学历"
+	case profile.EducationLevel >= required:
+		factor.Score = 1
+		factor.Detail = fmt.Sprintf("%s学历，满足%s要求", profile.Education, requiredName)
+	case profile.EducationLevel == required-1:
+		factor.Score = 0.5
+		factor.Detail = fmt.Sprintf("%s学历，略低于%s要求", profile.Education, requiredName)
+	default:
+		factor.Score = 0
+		factor.Detail = fmt.Sprintf("%s学历，不满足%s要求", profile.Education, requiredName)
+	}
+	return factor
+}
+
+func scoreLocation(profile ResumeProfile, job Job) MatchFactor {
+	factor := MatchFactor{Factor: FactorLocation}
+	city := jobCity(job.WorkLocation)
+	switch {
+	case job.RemoteAllowed:
+		factor.Score = 1
+		factor.Detail = "职位支持远程办公"
+	case city == "":
+		factor.Score = 1
+		factor.Detail = "职位未注明工作地点"
+	case profile.Location == "":
+		factor.Score = unknownFactorScore
+		factor.Detail = "简历中未识别到期望城市"
+	case strings.Contains(strings.ToLower(profile.Location), strings.ToLower(city)):
+		factor.Score = 1
+		factor.Detail = fmt.Sprintf("期望城市与工作地点 %s 一致", city)
+	default:
+		factor.Score = 0
+		factor.Detail = fmt.Sprintf("期望城市 %s 与工作地点 %s 不一致", profile.Location, city)
+	}
+	return factor
+}
+
+func scoreSalary(profile ResumeProfile, job Job) MatchFactor {
+	factor := MatchFactor{Factor: FactorSalary}
+	upper := jobMonthlySalaryUpper(job)
+	switch {
+	case upper == 0:
+		factor.Score = 1
+		factor.Detail = "职位薪资面议"
+	case profile.ExpectedSalary == 0:
+		factor.Score = unknownFactorScore
+		factor.Detail = "简历中未识别到期望薪资"
+	case profile.ExpectedSalary <= upper:
+		factor.Score = 1
+		factor.Detail = fmt.Sprintf("期望月薪 %d 在职位薪资范围内", profile.ExpectedSalary)
+	default:
+		factor.Score = float64(upper) / float64(profile.ExpectedSalary)
+		factor.Detail = fmt.Sprintf("期望月薪 %d 高于职位上限 %d", profile.ExpectedSalary, upper)
+	}
+	return factor
+}
+
+func normalizeSkill(skill string) string {
+	s := strings.ToLower(strings.TrimSpace(skill))
+	if alias, ok := skillAliases[s]; ok {
+		return alias
+	}
+	return s
+}
+
+func normalizeSkills(skills []string) []string {
+	seen := make(map[string]bool, len(skills))
+	result := make([]string, 0, len(skills))
+	for _, skill := range skills {
+		s := normalizeSkill(skill)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		result = append(result, s)
+	}
+	return result
+}
+
+// hasSkill 精确匹配，或一方作为完整单词出现在另一方中（如 "spring boot" 与 "spring"，但 "sql" 不匹配 "postgresql"）
+func hasSkill(skills []string, required string) bool {
+	for _, skill := range skills {
+		if skill == required || containsWord(skill, required) || containsWord(required, skill) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWord(text, word string) bool {
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '+' || r == '#' }
+	for offset := 0; ; {
+		idx := strings.Index(text[offset:], word)
+		if idx < 0 {
+			return false
+		}
+		start := offset + idx
+		end := start + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(text) || !isWordRune(after)) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+var yearsPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)`)
+
+// parseRequiredYears 解析 "5+年"、"3-5年"、"不限"、"应届" 等经验要求，返回最低年限
+func parseRequiredYears(text string) (float64, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, false
+	}
+	if strings.Contains(text, "不限") || strings.Contains(text, "应届") || strings.Contains(strings.ToLower(text), "fresh") {
+		return 0, true
+	}
+	match := yearsPattern.FindString(text)
+	if match == "" {
+		return 0, false
+	}
+	years, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, false
+	}
+	return years, true
+}
+
+func educationLevel(text string) (int, string) {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	if lower == "" || strings.Contains(lower, "不限") {
+		return 0, ""
+	}
+	for _, level := range educationLevels {
+		for _, keyword := range level.Keywords {
+			if strings.Contains(lower, keyword) {
+				return level.Level, level.Name
+			}
+		}
+	}
+	return 0, ""
+}
+
+// experienceYears 根据工作经历的起止时间或时长估算总工作年限
+func experienceYears(value interface{}) float64 {
+	total := 0.0
+	for _, entry := range mapList(value) {
+		if years, ok := firstNumber(entry, "years", "duration_years"); ok {
+			total += years
+			continue
+		}
+		if duration := stringValue(entry["duration"]); duration != "" {
+			if years, ok := parseRequiredYears(duration); ok && !strings.Contains(duration, "月") {
+				total += years
+				continue
+			}
+		}
+		start, ok := parseYearMonth(firstString(stringValue(entry["start_date"]), stringValue(entry["start"])))
+		if !ok {
+			continue
+		}
+		end, ok := parseYearMonth(firstString(stringValue(entry["end_date"]), stringValue(entry["end"])))
+		if !ok {
+			end = time.Now()
+		}
+		if end.After(start) {
+			total += end.Sub(start).Hours() / 24 / 365
+		}
+	}
+	return math.Round(total*10) / 10
+}
+
+var yearMonthPattern = regexp.MustCompile(`(\d{4})(?:\D+(\d{1,2}))?`)
+
+// parseYearMonth 解析 "2018-03"、"2018.3"、"2018年3月"，"至今"/"present" 视为无法解析（由调用方取当前时间）
+func parseYearMonth(text string) (time.Time, bool) {
+	match := yearMonthPattern.FindStringSubmatch(text)
+	if match == nil {
+		return time.Time{}, false
+	}
+	year, _ := strconv.Atoi(match[1])
+	month := 1
+	if match[2] != "" {
+		if m, err := strconv.Atoi(match[2]); err == nil && m >= 1 && m <= 12 {
+			month = m
+		}
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local), true
+}
+
+var salaryPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(k|千|w|万)?`)
+
+// parseMonthlySalary 解析 "20000"、"20k"、"15-20k"、"2万" 等期望薪资，区间取下限
+func parseMonthlySalary(text string) int {
+	matches := salaryPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	// "15-20k" 的单位写在区间末尾
+	unit := strings.ToLower(matches[len(matches)-1][2])
+	value, err := strconv.ParseFloat(matches[0][1], 64)
+	if err != nil {
+		return 0
+	}
+	if matches[0][2] != "" {
+		unit = strings.ToLower(matches[0][2])
+	}
+	switch unit {
+	case "k", "千":
+		value *= 1000
+	case "w", "万":
+		value *= 10000
+	}
+	if strings.Contains(text, "年") {
+		value /= 12
+	}
+	return int(value)
+}
+
+// jobMonthlySalaryUpper 职位月薪上限，薪资按年计时折算为月薪
+func jobMonthlySalaryUpper(job Job) int {
+	upper := 0
+	if job.SalaryMax != nil {
+		upper = *job.SalaryMax
+	} else if job.SalaryMin != nil {
+		upper = *job.SalaryMin
+	}
+	if job.SalaryPeriod == "yearly" {
+		upper /= 12
+	}
+	return upper
+}
+
+// jobCity 取 "上海·浦东" 中的城市部分
+func jobCity(location string) string {
+	location = strings.TrimSpace(location)
+	for _, sep := range []string{"·", "-", " ", "/"} {
+		if idx := strings.Index(location, sep); idx > 0 {
+			return location[:idx]
+		}
+	}
+	return location
+}
+
+func roundScore(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// stringList 兼容字符串数组、对象数组（取name/skill字段）与逗号分隔字符串
+func stringList(value interface{}) []string {
+	var result []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			switch entry := item.(type) {
+			case string:
+				result = append(result, strings.TrimSpace(entry))
+			case map[string]interface{}:
+				result = append(result, firstString(stringValue(entry["name"]), stringValue(entry["skill"])))
+			}
+		}
+	case string:
+		for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '，' || r == '、' || r == ';' }) {
+			result = append(result, strings.TrimSpace(item))
+		}
+	}
+
+	filtered := result[:0]
+	for _, item := range result {
+		if item != "" {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func mapList(value interface{}) []map[string]interface{} {
+	items, _ := value.([]interface{})
+	result := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if entry, ok := item.(map[string]interface{}); ok {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func firstNumber(data map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			if years, ok := parseRequiredYears(v); ok && years > 0 {
+				return years, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func firstString(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
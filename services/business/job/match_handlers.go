@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/response"
+	"gorm.io/gorm"
+)
+
+// registerMatchingRoutes 简历与职位匹配路由（需要登录）
+func (h *jobHandler) registerMatchingRoutes(r *gin.Engine) {
+	matchGroup := r.Group("/api/v1/matching", h.auth)
+	{
+		matchGroup.GET("/resumes/:id/jobs", h.handleMatchJobsForResume)
+		matchGroup.GET("/resumes/:id/jobs/:jobId", h.handleExplainMatch)
+		matchGroup.GET("/jobs/:id/candidates", h.handleMatchCandidatesForJob)
+	}
+}
+
+func (h *jobHandler) handleMatchJobsForResume(c *gin.Context) {
+	resumeID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的简历ID")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	authToken := ""
+	if c.DefaultQuery("ai", "true") != "false" {
+		authToken = strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+
+	result, err := h.service.MatchJobsForResume(c.Request.Context(), currentUserID(c), c.GetString("role"), resumeID, limit, authToken)
+	if err != nil {
+		writeMatchingError(c, err)
+		return
+	}
+	writeSuccess(c, "获取匹配职位成功", result)
+}
+
+func (h *jobHandler) handleMatchCandidatesForJob(c *gin.Context) {
+	jobID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	result, err := h.service.MatchCandidatesForJob(c.Request.Context(), currentUserID(c), c.GetString("role"), jobID, limit,
+		strings.TrimSpace(c.Query("scope")))
+	if err != nil {
+		writeMatchingError(c, err)
+		return
+	}
+	writeSuccess(c, "获取匹配候选人成功", result)
+}
+
+func (h *jobHandler) handleExplainMatch(c *gin.Context) {
+	resumeID, err := parseUintParam(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的简历ID")
+		return
+	}
+	jobID, err := parseUintParam(c.Param("jobId"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, "无效的职位ID")
+		return
+	}
+
+	result, err := h.service.ExplainMatch(c.Request.Context(), currentUserID(c), c.GetString("role"), resumeID, jobID)
+	if err != nil {
+		writeMatchingError(c, err)
+		return
+	}
+	writeSuccess(c, "获取匹配明细成功", result)
+}
+
+func writeMatchingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		writeError(c, http.StatusNotFound, response.CodeNotFound, "职位不存在")
+	case errors.Is(err, ErrResumeNotFound):
+		writeError(c, http.StatusNotFound, response.CodeNotFound, err.Error())
+	case errors.Is(err, ErrMatchingForbidden):
+		writeError(c, http.StatusForbidden, response.CodeForbidden, err.Error())
+	case errors.Is(err, ErrResumeNotParsed):
+		writeError(c, http.StatusBadRequest, response.CodeInvalidParams, err.Error())
+	default:
+		writeError(c, http.StatusInternalServerError, response.CodeInternalError, err.Error())
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// matchCandidateLimit 单次匹配最多评估的职位或简历数
+	matchCandidateLimit = 500
+	matchDefaultLimit   = 10
+	matchMaxLimit       = 50
+)
+
+// SetAIClient 设置外部AI匹配服务，未设置时只使用规则匹配
+func (s *JobService) SetAIClient(client *AIClient) {
+	s.ai = client
+}
+
+// MatchJobsForResume 为简历推荐匹配度最高的职位，AI服务可用时混入AI匹配分
+func (s *JobService) MatchJobsForResume(ctx context.Context, userID uint, role string, resumeID uint, limit int, authToken string) (MatchListResult, error) {
+	db := s.db.WithContext(ctx)
+	profile, err := s.loadResumeProfile(db, resumeID)
+	if err != nil {
+		return MatchListResult{}, err
+	}
+	if profile.UserID != userID && !managerRoles[role] {
+		return MatchListResult{}, ErrMatchingForbidden
+	}
+
+	var jobs []Job
+	if err := db.Where("status IN ?", []string{JobStatusPublished, JobStatusOpen}).
+		Where("expire_at IS NULL OR expire_at > ?", time.Now()).
+		Order("created_at DESC").Limit(matchCandidateLimit).Find(&jobs).Error; err != nil {
+		return MatchListResult{}, err
+	}
+
+	result := MatchListResult{Evaluated: len(jobs), GeneratedAt: time.Now()}
+	matches := make([]MatchResult, len(jobs))
+	index := make(map[uint]int, len(jobs))
+	for i, job := range jobs {
+		matches[i] = scoreMatch(profile, job)
+		summary := job.toSummary()
+		matches[i].Job = &summary
+		index[job.ID] = i
+	}
+
+	if s.ai != nil && authToken != "" && len(jobs) > 0 {
+		resp, err := s.ai.MatchJob(AIJobMatchingRequest{ResumeID: resumeID, Limit: matchCandidateLimit}, authToken)
+		if err != nil {
+			log.Printf("WARN: AI职位匹配不可用，仅使用规则匹配 resume=%d: %v", resumeID, err)
+			result.AIError = err.Error()
+		} else {
+			for _, item := range resp.Data {
+				if i, ok := index[item.JobID]; ok {
+					matches[i].blendAIScore(item.MatchScore, item.Reason)
+				}
+			}
+			result.AIBlended = true
+		}
+	}
+
+	result.Items = topMatches(matches, limit)
+	return result, nil
+}
+
+// MatchCandidatesForJob 为职位推荐匹配度最高的候选人，scope=applicants 时只评估已申请的候选人，否则同时评估公开简历
+func (s *JobService) MatchCandidatesForJob(ctx context.Context, userID uint, role string, jobID uint, limit int, scope string) (MatchListResult, error) {
+	db := s.db.WithContext(ctx)
+	job, err := s.loadJob(db, jobID)
+	if err != nil {
+		return MatchListResult{}, err
+	}
+	if !canManageJob(job, userID, role) {
+		return MatchListResult{}, ErrMatchingForbidden
+	}
+
+	var appliedIDs []uint
+	if err := db.Model(&JobApplication{}).
+		Where("job_id = ? AND resume_id IS NOT NULL AND COALESCE(application_stage, '') <> ?", jobID, StageWithdrawn).
+		Pluck("resume_id", &appliedIDs).Error; err != nil {
+		return MatchListResult{}, err
+	}
+	applied := make(map[uint]bool, len(appliedIDs))
+	for _, id := range appliedIDs {
+		applied[id] = true
+	}
+
+	query := db.Model(&ResumeRecord{})
+	if scope == "applicants" {
+		query = query.Where("id IN ?", appliedIDs)
+	} else {
+		query = query.Where("id IN ? OR (is_public = ? AND parsing_status = ?)", appliedIDs, true, "completed")
+	}
+	var records []ResumeRecord
+	if err := query.Order("updated_at DESC").Limit(matchCandidateLimit).Find(&records).Error; err != nil {
+		return MatchListResult{}, err
+	}
+
+	result := MatchListResult{GeneratedAt: time.Now()}
+	matches := make([]MatchResult, 0, len(records))
+	for _, record := range records {
+		profile, err := newResumeProfile(record)
+		if err != nil {
+			continue
+		}
+		match := scoreMatch(profile, job)
+		match.Resume = &ResumeBrief{
+			Title:           profile.Title,
+			Skills:          profile.Skills,
+			YearsExperience: profile.YearsExperience,
+			Education:       profile.Education,
+			Location:        profile.Location,
+			Applied:         applied[record.ID],
+		}
+		matches = append(matches, match)
+	}
+	result.Evaluated = len(matches)
+	result.Items = topMatches(matches, limit)
+	return result, nil
+}
+
+// ExplainMatch 计算单个简历与职位的匹配明细，简历本人、职位发布者或管理员可查看
+func (s *JobService) ExplainMatch(ctx context.Context, userID uint, role string, resumeID, jobID uint) (MatchResult, error) {
+	db := s.db.WithContext(ctx)
+	profile, err := s.loadResumeProfile(db, resumeID)
+	if err != nil {
+		return MatchResult{}, err
+	}
+	job, err := s.loadJob(db, jobID)
+	if err != nil {
+		return MatchResult{}, err
+	}
+	if profile.UserID != userID && !canManageJob(job, userID, role) {
+		return MatchResult{}, ErrMatchingForbidden
+	}
+
+	match := scoreMatch(profile, job)
+	summary := job.toSummary()
+	match.Job = &summary
+	return match, nil
+}
+
+func (s *JobService) loadResumeProfile(db *gorm.DB, resumeID uint) (ResumeProfile, error) {
+	var records []ResumeRecord
+	if err := db.Where("id = ?", resumeID).Limit(1).Find(&records).Error; err != nil {
+		return ResumeProfile{}, err
+	}
+	if len(records) == 0 {
+		return ResumeProfile{}, ErrResumeNotFound
+	}
+	return newResumeProfile(records[0])
+}
+
+func topMatches(matches []MatchResult, limit int) []MatchResult {
+	if limit <= 0 {
+		limit = matchDefaultLimit
+	}
+	if limit > matchMaxLimit {
+		limit = matchMaxLimit
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []MatchResult{}
+	}
+	return matches
+}
@@ -55,6 +55,7 @@ type Job struct {
 	FavoriteCount      int64          `json:"favoriteCount" gorm:"column:favorite_count"`
 	PublishAt          *time.Time     `json:"publishAt" gorm:"column:publish_at"`
 	ExpireAt           *time.Time     `json:"expireAt" gorm:"column:expire_at"`
+	Headcount          int            `json:"headcount" gorm:"column:headcount;default:0"`
 	CreatedAt          time.Time      `json:"createdAt" gorm:"column:created_at"`
 	UpdatedAt          time.Time      `json:"updatedAt" gorm:"column:updated_at"`
 	CreatedBy          int64          `json:"createdBy" gorm:"column:created_by"`
@@ -147,6 +148,8 @@ type JobDetail struct {
 	Perks            []string   `json:"perks"`
 	Status           string     `json:"status"`
 	PublishAt        *time.Time `json:"publishAt"`
+	ExpireAt         *time.Time `json:"expireAt"`
+	Headcount        int        `json:"headcount"`
 	UpdatedAt        time.Time  `json:"updatedAt"`
 }
 
@@ -161,50 +164,56 @@ type JobListResult struct {
 
 // CreateJobRequest 创建职位请求
 type CreateJobRequest struct {
-	Title            string   `json:"title" binding:"required"`
-	Description      string   `json:"description" binding:"required"`
-	Requirements     string   `json:"requirements"`
-	Responsibilities string   `json:"responsibilities"`
-	CompanyID        int64    `json:"companyId" binding:"required"`
-	CompanyName      string   `json:"companyName" binding:"required"`
-	CompanyLogo      string   `json:"companyLogo"`
-	Location         string   `json:"location" binding:"required"`
-	WorkType         string   `json:"workType"`
-	SalaryMin        *int     `json:"salaryMin"`
-	SalaryMax        *int     `json:"salaryMax"`
-	SalaryCurrency   string   `json:"salaryCurrency"`
-	SalaryPeriod     string   `json:"salaryPeriod"`
-	Experience       string   `json:"experience"`
-	Education        string   `json:"education"`
-	Tags             []string `json:"tags"`
-	Skills           []string `json:"skills"`
-	Benefits         []string `json:"benefits"`
-	Perks            []string `json:"perks"`
-	Status           string   `json:"status"`
-	CreatedBy        int64    `json:"createdBy"`
+	Title            string     `json:"title" binding:"required"`
+	Description      string     `json:"description" binding:"required"`
+	Requirements     string     `json:"requirements"`
+	Responsibilities string     `json:"responsibilities"`
+	CompanyID        int64      `json:"companyId" binding:"required"`
+	CompanyName      string     `json:"companyName" binding:"required"`
+	CompanyLogo      string     `json:"companyLogo"`
+	Location         string     `json:"location" binding:"required"`
+	WorkType         string     `json:"workType"`
+	SalaryMin        *int       `json:"salaryMin"`
+	SalaryMax        *int       `json:"salaryMax"`
+	SalaryCurrency   string     `json:"salaryCurrency"`
+	SalaryPeriod     string     `json:"salaryPeriod"`
+	Experience       string     `json:"experience"`
+	Education        string     `json:"education"`
+	Tags             []string   `json:"tags"`
+	Skills           []string   `json:"skills"`
+	Benefits         []string   `json:"benefits"`
+	Perks            []string   `json:"perks"`
+	Status           string     `json:"status"`
+	PublishAt        *time.Time `json:"publishAt"` // 晚于当前时间时保存为草稿，到时自动发布
+	ExpireAt         *time.Time `json:"expireAt"`
+	Headcount        int        `json:"headcount"` // 招聘人数，录用满额后自动关闭，0表示不限
+	CreatedBy        int64      `json:"createdBy"`
 }
 
 // UpdateJobRequest 更新职位请求
 type UpdateJobRequest struct {
-	Title            *string  `json:"title"`
-	Description      *string  `json:"description"`
-	Requirements     *string  `json:"requirements"`
-	Responsibilities *string  `json:"responsibilities"`
-	CompanyName      *string  `json:"companyName"`
-	CompanyLogo      *string  `json:"companyLogo"`
-	Location         *string  `json:"location"`
-	WorkType         *string  `json:"workType"`
-	SalaryMin        *int     `json:"salaryMin"`
-	SalaryMax        *int     `json:"salaryMax"`
-	SalaryCurrency   *string  `json:"salaryCurrency"`
-	SalaryPeriod     *string  `json:"salaryPeriod"`
-	Experience       *string  `json:"experience"`
-	Education        *string  `json:"education"`
-	Tags             []string `json:"tags"`
-	Skills           []string `json:"skills"`
-	Benefits         []string `json:"benefits"`
-	Perks            []string `json:"perks"`
-	Status           *string  `json:"status"`
+	Title            *string    `json:"title"`
+	Description      *string    `json:"description"`
+	Requirements     *string    `json:"requirements"`
+	Responsibilities *string    `json:"responsibilities"`
+	CompanyName      *string    `json:"companyName"`
+	CompanyLogo      *string    `json:"companyLogo"`
+	Location         *string    `json:"location"`
+	WorkType         *string    `json:"workType"`
+	SalaryMin        *int       `json:"salaryMin"`
+	SalaryMax        *int       `json:"salaryMax"`
+	SalaryCurrency   *string    `json:"salaryCurrency"`
+	SalaryPeriod     *string    `json:"salaryPeriod"`
+	Experience       *string    `json:"experience"`
+	Education        *string    `json:"education"`
+	Tags             []string   `json:"tags"`
+	Skills           []string   `json:"skills"`
+	Benefits         []string   `json:"benefits"`
+	Perks            []string   `json:"perks"`
+	Status           *string    `json:"status"`
+	PublishAt        *time.Time `json:"publishAt"`
+	ExpireAt         *time.Time `json:"expireAt"`
+	Headcount        *int       `json:"headcount"`
 }
 
 // FavoriteRequest 收藏请求
@@ -314,6 +323,8 @@ func (j Job) toDetail() JobDetail {
 		Perks:            copyStringSlice(j.Perks),
 		Status:           j.Status,
 		PublishAt:        j.PublishAt,
+		ExpireAt:         j.ExpireAt,
+		Headcount:        j.Headcount,
 		UpdatedAt:        j.UpdatedAt,
 	}
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchedulerLease 调度租约，多副本部署时同一时间只有持有者执行调度任务
+type SchedulerLease struct {
+	Name      string    `gorm:"column:name;primaryKey;size:100"`
+	Holder    string    `gorm:"column:holder;size:200;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (SchedulerLease) TableName() string {
+	return "zervigo_scheduler_leases"
+}
+
+// dbLease 基于数据库行的租约：过期或由自己持有时才能续约成功。
+// 时间取各副本本地时钟，TTL 应远大于副本间的时钟偏差。
+type dbLease struct {
+	db     *gorm.DB
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+func newDBLease(db *gorm.DB, name string, ttl time.Duration) *dbLease {
+	hostname, _ := os.Hostname()
+	return &dbLease{
+		db:     db,
+		name:   name,
+		holder: fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		ttl:    ttl,
+	}
+}
+
+// Acquire 获取或续约租约，返回当前副本是否持有租约
+func (l *dbLease) Acquire(ctx context.Context) (bool, error) {
+	db := l.db.WithContext(ctx)
+	now := time.Now()
+
+	// 首次运行时插入一条已过期的租约记录
+	seed := SchedulerLease{Name: l.name, Holder: "", ExpiresAt: now.Add(-time.Second), UpdatedAt: now}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+		return false, err
+	}
+
+	result := db.Model(&SchedulerLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", l.name, l.holder, now).
+		Updates(map[string]interface{}{"holder": l.holder, "expires_at": now.Add(l.ttl), "updated_at": now})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Release 主动释放租约，其他副本无需等待过期即可接管
+func (l *dbLease) Release(ctx context.Context) error {
+	now := time.Now()
+	return l.db.WithContext(ctx).Model(&SchedulerLease{}).
+		Where("name = ? AND holder = ?", l.name, l.holder).
+		Updates(map[string]interface{}{"expires_at": now.Add(-time.Second), "updated_at": now}).Error
+}
+
+// LifecycleScheduler 职位生命周期与职位提醒后台调度
+type LifecycleScheduler struct {
+	service        *JobService
+	lease          *dbLease
+	interval       time.Duration
+	reminderWindow time.Duration
+}
+
+// NewLifecycleScheduler 创建调度器，租约有效期为三个调度周期
+func NewLifecycleScheduler(service *JobService, interval, reminderWindow time.Duration) *LifecycleScheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &LifecycleScheduler{
+		service:        service,
+		lease:          newDBLease(service.db, "job-lifecycle", 3*interval),
+		interval:       interval,
+		reminderWindow: reminderWindow,
+	}
+}
+
+// Start 在后台运行调度，ctx 取消时释放租约并退出
+func (s *LifecycleScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.tick(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := s.lease.Release(releaseCtx); err != nil {
+					log.Printf("WARN: 释放职位调度租约失败: %v", err)
+				}
+				cancel()
+				return
+			case <-ticker.C:
+				s.tick(ctx)
+			}
+		}
+	}()
+}
+
+func (s *LifecycleScheduler) tick(ctx context.Context) {
+	held, err := s.lease.Acquire(ctx)
+	if err != nil {
+		log.Printf("WARN: 获取职位调度租约失败: %v", err)
+		return
+	}
+	if !held {
+		return
+	}
+
+	report, err := s.service.RunLifecycle(ctx, time.Now(), s.reminderWindow)
+	if err != nil {
+		log.Printf("WARN: 职位生命周期调度失败: %v", err)
+	}
+	if report != (LifecycleReport{}) {
+		log.Printf("职位生命周期调度完成: 发布 %d, 到期关闭 %d, 招满关闭 %d, 到期提醒 %d",
+			report.Published, report.Expired, report.Filled, report.Reminded)
+	}
+
+	digest, err := s.service.RunAlertDigests(ctx, time.Now())
+	if err != nil {
+		log.Printf("WARN: 职位提醒投递失败: %v", err)
+	}
+	if digest.Jobs > 0 {
+		log.Printf("职位提醒投递完成: 订阅 %d, 职位 %d", digest.Searches, digest.Jobs)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 搜索分面
+const (
+	FacetCategory   = "category"
+	FacetWorkType   = "workType"
+	FacetLocation   = "location"
+	FacetSalaryBand = "salaryBand"
+	FacetExperience = "experience"
+)
+
+var searchFacets = []string{FacetCategory, FacetWorkType, FacetLocation, FacetSalaryBand, FacetExperience}
+
+// salaryBands 薪资区间（按月薪上限，未填写薪资归为面议）
+var salaryBands = []struct {
+	Name  string
+	Upper int // 0 表示无上限
+}{
+	{"0-10k", 10000},
+	{"10k-20k", 20000},
+	{"20k-30k", 30000},
+	{"30k-50k", 50000},
+	{"50k+", 0},
+}
+
+const salaryBandNegotiable = "negotiable"
+
+// JobSearchRequest 职位搜索请求
+type JobSearchRequest struct {
+	Keyword    string
+	Category   string
+	WorkType   string
+	Location   string
+	SalaryBand string
+	Experience string
+	Status     string
+	Sort       string // relevance（默认，有关键词时）或 newest
+	Page       int
+	PageSize   int
+}
+
+// JobSearchHit 搜索结果中的单个职位
+type JobSearchHit struct {
+	JobSummary
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// FacetBucket 分面中的单个取值
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// JobSearchResult 职位搜索结果
+type JobSearchResult struct {
+	Items      []JobSearchHit           `json:"items"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	PageSize   int                      `json:"pageSize"`
+	TotalPages int                      `json:"totalPages"`
+	Facets     map[string][]FacetBucket `json:"facets"`
+	Engine     string                   `json:"engine"` // postgres-fts 或 fallback
+}
+
+// tokenizeForSearch 搜索分词：拉丁字母与数字按词切分并转小写，连续中文按二元组切分，
+// 单个汉字保留为一个词。索引与查询使用同一分词，保证中文不依赖数据库分词扩展也能检索。
+func tokenizeForSearch(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || ((r == '+' || r == '#') && len(word) > 0):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// searchDocument 单个职位按权重分组的索引文本
+type searchDocument struct {
+	Title        []string // 权重A
+	Keywords     []string // 权重B：技能与公司名
+	Requirements []string // 权重C
+	Description  []string // 权重D
+}
+
+func newSearchDocument(job Job) searchDocument {
+	return searchDocument{
+		Title:        tokenizeForSearch(job.Title),
+		Keywords:     tokenizeForSearch(strings.Join(append([]string{job.CompanyName, job.JobCategory, job.JobSubcategory}, job.SkillsRequired...), " ")),
+		Requirements: tokenizeForSearch(job.Requirements + " " + job.Responsibilities),
+		Description:  tokenizeForSearch(job.Description),
+	}
+}
+
+// highlightSnippet 在文本中标记命中的词，返回以命中位置为中心、最多 window 个字符的片段；未命中返回空串
+func highlightSnippet(text string, terms []string, window int) string {
+	runes := []rune(text)
+	if len(runes) == 0 || len(terms) == 0 {
+		return ""
+	}
+
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		termRunes := []rune(term)
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if string(lower[i:i+len(termRunes)]) != term {
+				continue
+			}
+			for j := i; j < i+len(termRunes); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		return ""
+	}
+
+	start, end := 0, len(runes)
+	if window > 0 && len(runes) > window {
+		start = first - window/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + window
+		if end > len(runes) {
+			end = len(runes)
+			start = end - window
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString("<em>")
+		}
+		b.WriteRune(runes[i])
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString("</em>")
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// buildHighlights 生成标题、描述、要求的高亮片段
+func buildHighlights(job Job, terms []string) map[string]string {
+	if len(terms) == 0 {
+		return nil
+	}
+	highlights := make(map[string]string)
+	if snippet := highlightSnippet(job.Title, terms, 0); snippet != "" {
+		highlights["title"] = snippet
+	}
+	if snippet := highlightSnippet(job.Description, terms, 80); snippet != "" {
+		highlights["description"] = snippet
+	}
+	if snippet := highlightSnippet(job.Requirements, terms, 80); snippet != "" {
+		highlights["requirements"] = snippet
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
+
+// uniqueTokens 去重并保持顺序
+func uniqueTokens(tokens []string) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	result := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		result = append(result, token)
+	}
+	return result
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// searchTextConfig 索引文本已由 tokenizeForSearch 完成分词（含中文二元组），数据库侧只需按空白切分
+	searchTextConfig = "simple"
+	// searchVectorExpr 标题A、技能与公司B、要求与职责C、描述D
+	searchVectorExpr = "setweight(to_tsvector('" + searchTextConfig + "', ?), 'A') || " +
+		"setweight(to_tsvector('" + searchTextConfig + "', ?), 'B') || " +
+		"setweight(to_tsvector('" + searchTextConfig + "', ?), 'C') || " +
+		"setweight(to_tsvector('" + searchTextConfig + "', ?), 'D')"
+	searchQueryExpr = "plainto_tsquery('" + searchTextConfig + "', ?)"
+
+	// searchFallbackCandidates 非PostgreSQL环境在内存中计算相关度时最多取的候选职位数
+	searchFallbackCandidates = 500
+	searchFacetLimit         = 20
+)
+
+var salaryBandExpr = buildSalaryBandExpr()
+
+func buildSalaryBandExpr() string {
+	const salary = "COALESCE(salary_max, salary_min)"
+	var b strings.Builder
+	b.WriteString("CASE WHEN " + salary + " IS NULL THEN '" + salaryBandNegotiable + "'")
+	for _, band := range salaryBands {
+		if band.Upper == 0 {
+			b.WriteString(" ELSE '" + band.Name + "'")
+			continue
+		}
+		b.WriteString(fmt.Sprintf(" WHEN %s < %d THEN '%s'", salary, band.Upper, band.Name))
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+func facetColumn(facet string) string {
+	switch facet {
+	case FacetCategory:
+		return "job_category"
+	case FacetWorkType:
+		return "work_type"
+	case FacetLocation:
+		return "work_location"
+	case FacetExperience:
+		return "experience_required"
+	case FacetSalaryBand:
+		return salaryBandExpr
+	}
+	return ""
+}
+
+// EnsureSearchSchema 创建全文索引列并为尚未建立索引的职位补建索引（仅PostgreSQL）
+func (s *JobService) EnsureSearchSchema(ctx context.Context) error {
+	if !s.isPostgres {
+		return nil
+	}
+
+	db := s.db.WithContext(ctx)
+	if err := db.Exec("ALTER TABLE zervigo_jobs ADD COLUMN IF NOT EXISTS search_vector tsvector").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_zervigo_jobs_search_vector ON zervigo_jobs USING GIN (search_vector)").Error; err != nil {
+		return err
+	}
+
+	var jobs []Job
+	return db.Where("search_vector IS NULL").FindInBatches(&jobs, 200, func(tx *gorm.DB, batch int) error {
+		for _, job := range jobs {
+			if err := s.indexJob(tx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// indexJob 重建单个职位的全文索引，与职位写入放在同一事务中
+func (s *JobService) indexJob(tx *gorm.DB, job Job) error {
+	if !s.isPostgres {
+		return nil
+	}
+	doc := newSearchDocument(job)
+	return tx.Exec("UPDATE zervigo_jobs SET search_vector = "+searchVectorExpr+" WHERE id = ?",
+		strings.Join(doc.Title, " "),
+		strings.Join(doc.Keywords, " "),
+		strings.Join(doc.Requirements, " "),
+		strings.Join(doc.Description, " "),
+		job.ID,
+	).Error
+}
+
+// SearchJobs 全文搜索职位，返回相关度排序结果、高亮片段和分面统计
+func (s *JobService) SearchJobs(ctx context.Context, req JobSearchRequest) (JobSearchResult, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+	terms := uniqueTokens(tokenizeForSearch(req.Keyword))
+
+	result := JobSearchResult{
+		Items:    []JobSearchHit{},
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Engine:   "fallback",
+	}
+	if s.isPostgres {
+		result.Engine = "postgres-fts"
+	}
+
+	if err := s.searchScope(ctx, req, terms, "").Count(&result.Total).Error; err != nil {
+		return JobSearchResult{}, err
+	}
+
+	facets, err := s.searchFacets(ctx, req, terms)
+	if err != nil {
+		return JobSearchResult{}, err
+	}
+	result.Facets = facets
+
+	if result.Total == 0 {
+		return result, nil
+	}
+	result.TotalPages = int((result.Total + int64(req.PageSize) - 1) / int64(req.PageSize))
+	if result.Page > result.TotalPages {
+		result.Page = result.TotalPages
+	}
+
+	byRelevance := len(terms) > 0 && req.Sort != "newest"
+	switch {
+	case byRelevance && s.isPostgres:
+		err = s.searchRankedPostgres(ctx, req, terms, &result)
+	case byRelevance:
+		err = s.searchRankedFallback(ctx, req, terms, &result)
+	default:
+		err = s.searchNewest(ctx, req, terms, &result)
+	}
+	if err != nil {
+		return JobSearchResult{}, err
+	}
+	return result, nil
+}
+
+// searchScope 构造搜索条件，exclude 指定的分面不参与过滤（用于统计该分面的其他取值）
+func (s *JobService) searchScope(ctx context.Context, req JobSearchRequest, terms []string, exclude string) *gorm.DB {
+	query := s.db.WithContext(ctx).Model(&Job{})
+
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	} else {
+		query = query.Where("status IN ?", []string{JobStatusPublished, JobStatusOpen}).
+			Where("expire_at IS NULL OR expire_at > ?", time.Now())
+	}
+
+	filters := map[string]string{
+		FacetCategory:   req.Category,
+		FacetWorkType:   req.WorkType,
+		FacetLocation:   req.Location,
+		FacetSalaryBand: req.SalaryBand,
+		FacetExperience: req.Experience,
+	}
+	for _, facet := range searchFacets {
+		value := filters[facet]
+		if value == "" || facet == exclude {
+			continue
+		}
+		query = query.Where(facetColumn(facet)+" = ?", value)
+	}
+
+	if len(terms) == 0 {
+		return query
+	}
+	if s.isPostgres {
+		return query.Where("search_vector @@ "+searchQueryExpr, strings.Join(terms, " "))
+	}
+	for _, term := range terms {
+		like := "%" + term + "%"
+		query = query.Where("(LOWER(title) LIKE ? OR LOWER(company_name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(requirements) LIKE ? OR LOWER(skills_required) LIKE ?)",
+			like, like, like, like, like)
+	}
+	return query
+}
+
+func (s *JobService) searchFacets(ctx context.Context, req JobSearchRequest, terms []string) (map[string][]FacetBucket, error) {
+	facets := make(map[string][]FacetBucket, len(searchFacets))
+	for _, facet := range searchFacets {
+		column := facetColumn(facet)
+		var buckets []FacetBucket
+		err := s.searchScope(ctx, req, terms, facet).
+			Select(column + " AS value, COUNT(*) AS count").
+			Group(column).
+			Scan(&buckets).Error
+		if err != nil {
+			return nil, err
+		}
+		facets[facet] = sortFacetBuckets(facet, buckets)
+	}
+	return facets, nil
+}
+
+// sortFacetBuckets 薪资按区间顺序，其余按数量降序，去掉空值
+func sortFacetBuckets(facet string, buckets []FacetBucket) []FacetBucket {
+	result := make([]FacetBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		if strings.TrimSpace(bucket.Value) != "" {
+			result = append(result, bucket)
+		}
+	}
+
+	if facet == FacetSalaryBand {
+		order := map[string]int{salaryBandNegotiable: len(salaryBands)}
+		for i, band := range salaryBands {
+			order[band.Name] = i
+		}
+		sort.Slice(result, func(i, j int) bool { return order[result[i].Value] < order[result[j].Value] })
+		return result
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	if len(result) > searchFacetLimit {
+		result = result[:searchFacetLimit]
+	}
+	return result
+}
+
+func (s *JobService) searchNewest(ctx context.Context, req JobSearchRequest, terms []string, result *JobSearchResult) error {
+	orderExpr := "created_at DESC"
+	if s.isPostgres {
+		orderExpr = "COALESCE(publish_at, created_at) DESC"
+	}
+
+	var jobs []Job
+	err := s.searchScope(ctx, req, terms, "").
+		Order(orderExpr).
+		Offset((result.Page - 1) * result.PageSize).
+		Limit(result.PageSize).
+		Find(&jobs).Error
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		result.Items = append(result.Items, newSearchHit(job, 0, terms))
+	}
+	return nil
+}
+
+// searchRankedPostgres ts_rank 按标题/技能/要求/描述加权并按文档长度归一化（normalization=1）
+func (s *JobService) searchRankedPostgres(ctx context.Context, req JobSearchRequest, terms []string, result *JobSearchResult) error {
+	type rankedJob struct {
+		Job
+		SearchScore float64 `gorm:"column:search_score"`
+	}
+
+	var rows []rankedJob
+	err := s.searchScope(ctx, req, terms, "").
+		Select("zervigo_jobs.*, ts_rank(search_vector, "+searchQueryExpr+", 1) AS search_score", strings.Join(terms, " ")).
+		Order("search_score DESC, COALESCE(publish_at, created_at) DESC").
+		Offset((result.Page - 1) * result.PageSize).
+		Limit(result.PageSize).
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		result.Items = append(result.Items, newSearchHit(row.Job, row.SearchScore, terms))
+	}
+	return nil
+}
+
+// searchRankedFallback 非PostgreSQL环境：取最新的候选职位在内存中按BM25计算相关度
+func (s *JobService) searchRankedFallback(ctx context.Context, req JobSearchRequest, terms []string, result *JobSearchResult) error {
+	var jobs []Job
+	err := s.searchScope(ctx, req, terms, "").
+		Order("created_at DESC").
+		Limit(searchFallbackCandidates).
+		Find(&jobs).Error
+	if err != nil {
+		return err
+	}
+
+	scores := bm25Scores(jobs, terms)
+	order := make([]int, len(jobs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	start := (result.Page - 1) * result.PageSize
+	for i := start; i < len(order) && i < start+result.PageSize; i++ {
+		idx := order[i]
+		result.Items = append(result.Items, newSearchHit(jobs[idx], scores[idx], terms))
+	}
+	return nil
+}
+
+// bm25Scores 字段加权的BM25（k1=1.2, b=0.75），权重与PostgreSQL索引的A/B/C/D一致
+func bm25Scores(jobs []Job, terms []string) []float64 {
+	const (
+		k1 = 1.2
+		b  = 0.75
+	)
+	weights := [4]float64{1.0, 0.4, 0.2, 0.1}
+
+	type docStats struct {
+		tf     map[string]float64
+		length float64
+	}
+	docs := make([]docStats, len(jobs))
+	docFreq := make(map[string]int, len(terms))
+	totalLength := 0.0
+
+	for i, job := range jobs {
+		doc := newSearchDocument(job)
+		fields := [4][]string{doc.Title, doc.Keywords, doc.Requirements, doc.Description}
+		stats := docStats{tf: make(map[string]float64, len(terms))}
+		for f, tokens := range fields {
+			stats.length += float64(len(tokens)) * weights[f]
+			for _, token := range tokens {
+				stats.tf[token] += weights[f]
+			}
+		}
+		for _, term := range terms {
+			if stats.tf[term] > 0 {
+				docFreq[term]++
+			}
+		}
+		totalLength += stats.length
+		docs[i] = stats
+	}
+
+	scores := make([]float64, len(jobs))
+	if len(jobs) == 0 {
+		return scores
+	}
+	avgLength := totalLength / float64(len(jobs))
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	n := float64(len(jobs))
+	for i, doc := range docs {
+		for _, term := range terms {
+			tf := doc.tf[term]
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			scores[i] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*doc.length/avgLength))
+		}
+	}
+	return scores
+}
+
+func newSearchHit(job Job, score float64, terms []string) JobSearchHit {
+	return JobSearchHit{
+		JobSummary: job.toSummary(),
+		Score:      math.Round(score*10000) / 10000,
+		Highlights: buildHighlights(job, terms),
+	}
+}
@@ -16,6 +16,8 @@ type JobService struct {
 	db         *gorm.DB
 	dialect    string
 	isPostgres bool
+	events     *EventPublisher
+	ai         *AIClient
 }
 
 func NewJobService(db *gorm.DB) *JobService {
@@ -56,7 +58,8 @@ func (s *JobService) ListJobs(ctx context.Context, filter JobFilter) (JobListRes
 	if filter.Status != "" {
 		query = query.Where("status = ?", filter.Status)
 	} else {
-		query = query.Where("status IN ?", []string{JobStatusDraft, JobStatusPublished, JobStatusOpen, JobStatusPaused})
+		query = query.Where("status IN ?", []string{JobStatusDraft, JobStatusPublished, JobStatusOpen, JobStatusPaused}).
+			Where("expire_at IS NULL OR expire_at > ?", time.Now())
 	}
 
 	if len(filter.Categories) > 0 {
@@ -140,6 +143,8 @@ func (s *JobService) CreateJob(ctx context.Context, req CreateJobRequest) (JobDe
 		ExperienceRequired: req.Experience,
 		EducationRequired:  req.Education,
 		Status:             defaultStatus(req.Status),
+		ExpireAt:           req.ExpireAt,
+		Headcount:          req.Headcount,
 		CreatedBy:          req.CreatedBy,
 	}
 
@@ -156,14 +161,33 @@ func (s *JobService) CreateJob(ctx context.Context, req CreateJobRequest) (JobDe
 		job.Perks = pq.StringArray(normalizeTags(req.Perks))
 	}
 
-	if job.Status == JobStatusPublished || job.Status == JobStatusOpen {
-		now := time.Now()
+	now := time.Now()
+	switch {
+	case req.PublishAt != nil && req.PublishAt.After(now):
+		// 定时发布：先保存为草稿，到发布时间由生命周期调度发布
+		job.Status = JobStatusDraft
+		job.PublishAt = req.PublishAt
+	case job.Status == JobStatusPublished || job.Status == JobStatusOpen:
 		job.PublishAt = &now
+	case req.PublishAt != nil:
+		job.PublishAt = req.PublishAt
+	}
+	if err := validateJobSchedule(job); err != nil {
+		return JobDetail{}, err
 	}
 
-	if err := s.db.WithContext(ctx).Create(&job).Error; err != nil {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		return s.indexJob(tx, job)
+	})
+	if err != nil {
 		return JobDetail{}, err
 	}
+	if isActiveJobStatus(job.Status) {
+		s.onJobPublished(job)
+	}
 
 	return job.toDetail(), nil
 }
@@ -174,6 +198,7 @@ func (s *JobService) UpdateJob(ctx context.Context, id uint, req UpdateJobReques
 	if err := s.db.WithContext(ctx).First(&job, id).Error; err != nil {
 		return JobDetail{}, err
 	}
+	wasActive := isActiveJobStatus(job.Status)
 
 	applyString := func(value **string, src *string) {
 		if src != nil {
@@ -241,10 +266,31 @@ func (s *JobService) UpdateJob(ctx context.Context, id uint, req UpdateJobReques
 	if req.Status != nil {
 		job.Status = strings.TrimSpace(*req.Status)
 	}
+	if req.PublishAt != nil {
+		job.PublishAt = req.PublishAt
+	}
+	if req.ExpireAt != nil {
+		job.ExpireAt = req.ExpireAt
+	}
+	if req.Headcount != nil && *req.Headcount >= 0 {
+		job.Headcount = *req.Headcount
+	}
+	if err := validateJobSchedule(job); err != nil {
+		return JobDetail{}, err
+	}
 
-	if err := s.db.WithContext(ctx).Save(&job).Error; err != nil {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&job).Error; err != nil {
+			return err
+		}
+		return s.indexJob(tx, job)
+	})
+	if err != nil {
 		return JobDetail{}, err
 	}
+	if !wasActive && isActiveJobStatus(job.Status) {
+		s.onJobPublished(job)
+	}
 
 	return job.toDetail(), nil
 }
@@ -382,6 +428,13 @@ func intPtr(value int) *int {
 	return &value
 }
 
+func validateJobSchedule(job Job) error {
+	if job.PublishAt != nil && job.ExpireAt != nil && !job.ExpireAt.After(*job.PublishAt) {
+		return ErrInvalidJobSchedule
+	}
+	return nil
+}
+
 func defaultStatus(status string) string {
 	if strings.TrimSpace(status) == "" {
 		return JobStatusDraft
@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	jobfirst "github.com/szjason72/zervigo/shared/core"
 	"github.com/szjason72/zervigo/shared/core/auth"
 	"github.com/szjason72/zervigo/shared/core/response"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -60,6 +62,22 @@ func main() {
 		log.Fatalf("初始化认证数据库失败: %v", err)
 	}
 
+	// 初始化用户SQLite数据库与敏感字段加密（主密钥文件应放在数据目录之外并单独备份）
+	dataPath := os.Getenv("RESUME_DATA_PATH")
+	if dataPath == "" {
+		dataPath = "./data"
+	}
+	if err := InitSecureSQLiteManager(dataPath); err != nil {
+		log.Fatalf("初始化用户数据库管理器失败: %v", err)
+	}
+	masterKeyPath := os.Getenv("RESUME_MASTER_KEY_FILE")
+	if masterKeyPath == "" {
+		masterKeyPath = "./keys/resume_master_keys.json"
+	}
+	if err := InitFieldEncryption(masterKeyPath); err != nil {
+		log.Fatalf("初始化简历字段加密失败: %v", err)
+	}
+
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
 
@@ -136,9 +154,13 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 	// 需要认证的API路由
 	zerviAuthAdapter := auth.NewZerviAuthAdapter(sqlDB, jwtSecret)
 	authMiddleware := zerviAuthAdapter.RequireAuth()
+	policies := newResumePolicyMiddleware(core, sqlDB)
 	api := r.Group("/api/v1")
 	api.Use(authMiddleware)
 	{
+		// 访问策略管理与试运行
+		policies.RegisterRoutes(api)
+
 		// 简历管理
 		resume := api.Group("/resume")
 		{
@@ -228,6 +250,33 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 				standardSuccessResponse(c, "简历已删除", "简历删除成功")
 			})
 
+			// 查看解析出的个人信息（解密），需要 view_sensitive 权限且隐私设置允许共享
+			resume.GET("/:resumeId/personal-info/:contentId", func(c *gin.Context) {
+				resumeID := c.Param("resumeId")
+				if !requireResumeAccess(c, policies, resumeID, "view_sensitive", "无权限查看简历敏感信息") {
+					return
+				}
+				contentID, err := strconv.ParseUint(c.Param("contentId"), 10, 64)
+				if err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "简历内容ID格式错误", err.Error())
+					return
+				}
+
+				personalInfo, err := revealResumePersonalInfo(sqlDB, resumeID, uint(contentID), c.GetUint("user_id"))
+				switch {
+				case errors.Is(err, ErrSensitiveAccessDenied):
+					standardErrorResponse(c, http.StatusForbidden, "简历隐私设置不允许查看个人信息", "")
+					return
+				case errors.Is(err, sql.ErrNoRows), errors.Is(err, gorm.ErrRecordNotFound):
+					standardErrorResponse(c, http.StatusNotFound, "简历解析结果不存在", "")
+					return
+				case err != nil:
+					standardErrorResponse(c, http.StatusInternalServerError, "获取个人信息失败", err.Error())
+					return
+				}
+				standardSuccessResponse(c, personalInfo, "简历个人信息获取成功")
+			})
+
 			// === 前端兼容层：/api/resume/** ===
 			resume.GET("/current", func(c *gin.Context) {
 				userID := c.GetUint("user_id")
@@ -332,17 +381,63 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 			})
 		}
 
+		// 字段加密主密钥管理（仅系统管理员）
+		encryption := api.Group("/resume/encryption", requireSystemAdmin())
+		{
+			encryption.GET("/status", func(c *gin.Context) {
+				encryptor, err := GetFieldEncryptor()
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "字段加密未初始化", err.Error())
+					return
+				}
+				standardSuccessResponse(c, gin.H{
+					"activeVersion": encryptor.keyring.ActiveVersion(),
+					"versions":      encryptor.keyring.Versions(),
+				}, "加密状态获取成功")
+			})
+
+			// 轮换主密钥，并在后台将所有用户数据迁移到新密钥
+			encryption.POST("/rotate", func(c *gin.Context) {
+				encryptor, err := GetFieldEncryptor()
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "字段加密未初始化", err.Error())
+					return
+				}
+				version, err := encryptor.keyring.Rotate()
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "轮换主密钥失败", err.Error())
+					return
+				}
+				log.Printf("简历字段加密主密钥已轮换为 %s，操作人: %d", version, c.GetUint("user_id"))
+				go runReencryption(encryptor)
+				standardSuccessResponse(c, gin.H{"activeVersion": version}, "主密钥已轮换，正在后台重新加密")
+			})
+
+			// 重新执行迁移（可重复执行，已迁移的数据会跳过）
+			encryption.POST("/reencrypt", func(c *gin.Context) {
+				encryptor, err := GetFieldEncryptor()
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "字段加密未初始化", err.Error())
+					return
+				}
+				report, err := ReencryptAllUsers(globalSQLiteManager, encryptor)
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "重新加密失败", err.Error())
+					return
+				}
+				standardSuccessResponse(c, report, "重新加密完成")
+			})
+		}
+
 		// 简历权限管理
 		permission := api.Group("/resume/permission")
 		{
 			// 获取简历权限配置
 			permission.GET("/:resumeId", func(c *gin.Context) {
 				resumeID := c.Param("resumeId")
-				userID := c.GetUint("user_id")
 
 				// 检查用户是否有权限查看该简历的权限配置
-				if !checkResumeOwnership(sqlDB, resumeID, userID) {
-					standardErrorResponse(c, http.StatusForbidden, "无权限查看该简历的权限配置", "")
+				if !requireResumeAccess(c, policies, resumeID, "view_permission", "无权限查看该简历的权限配置") {
 					return
 				}
 
@@ -358,11 +453,9 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 			// 更新简历权限配置
 			permission.PUT("/:resumeId", func(c *gin.Context) {
 				resumeID := c.Param("resumeId")
-				userID := c.GetUint("user_id")
 
 				// 检查用户是否有权限修改该简历的权限配置
-				if !checkResumeOwnership(sqlDB, resumeID, userID) {
-					standardErrorResponse(c, http.StatusForbidden, "无权限修改该简历的权限配置", "")
+				if !requireResumeAccess(c, policies, resumeID, "update_permission", "无权限修改该简历的权限配置") {
 					return
 				}
 
@@ -387,11 +480,9 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 			// 获取简历黑名单
 			blacklist.GET("/:resumeId", func(c *gin.Context) {
 				resumeID := c.Param("resumeId")
-				userID := c.GetUint("user_id")
 
 				// 检查用户是否有权限查看该简历的黑名单
-				if !checkResumeOwnership(sqlDB, resumeID, userID) {
-					standardErrorResponse(c, http.StatusForbidden, "无权限查看该简历的黑名单", "")
+				if !requireResumeAccess(c, policies, resumeID, "view_blacklist", "无权限查看该简历的黑名单") {
 					return
 				}
 
@@ -402,11 +493,9 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 			// 更新简历黑名单
 			blacklist.PUT("/:resumeId", func(c *gin.Context) {
 				resumeID := c.Param("resumeId")
-				userID := c.GetUint("user_id")
 
 				// 检查用户是否有权限修改该简历的黑名单
-				if !checkResumeOwnership(sqlDB, resumeID, userID) {
-					standardErrorResponse(c, http.StatusForbidden, "无权限修改该简历的黑名单", "")
+				if !requireResumeAccess(c, policies, resumeID, "update_blacklist", "无权限修改该简历的黑名单") {
 					return
 				}
 
@@ -641,6 +730,58 @@ func getResumeDetail(sqlDB *sql.DB, resumeID string, userID uint) *gin.H {
 	return detail
 }
 
+// revealResumePersonalInfo 从简历所有者的SQLite数据库中读取并解密解析出的个人信息
+func revealResumePersonalInfo(sqlDB *sql.DB, resumeID string, contentID uint, viewerID uint) (map[string]interface{}, error) {
+	var ownerID uint
+	if err := sqlDB.QueryRow(`SELECT user_id FROM resume WHERE resume_id = $1`, resumeID).Scan(&ownerID); err != nil {
+		return nil, err
+	}
+
+	userDB, err := GetSecureUserDatabase(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	var parsed ParsedResumeDataDB
+	if err := userDB.Where("resume_content_id = ?", contentID).Order("id DESC").First(&parsed).Error; err != nil {
+		return nil, err
+	}
+	var settings []UserPrivacySettings
+	if err := userDB.Where("resume_content_id = ?", contentID).Limit(1).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	var privacy *UserPrivacySettings
+	if len(settings) > 0 {
+		privacy = &settings[0]
+	}
+
+	// 调用方已通过 view_sensitive 策略检查
+	return NewSensitivityAwareParser().RevealPersonalInfo(userDB, ownerID, viewerID, true, privacy, string(parsed.PersonalInfo))
+}
+
+// runReencryption 主密钥轮换后的重新加密任务
+func runReencryption(encryptor *FieldEncryptor) {
+	report, err := ReencryptAllUsers(globalSQLiteManager, encryptor)
+	if err != nil {
+		log.Printf("重新加密失败: %v", err)
+		return
+	}
+	log.Printf("重新加密完成: 主密钥 %s, 用户 %d, 字段 %d, 失败用户 %v",
+		report.MasterVersion, report.Users, report.Fields, report.FailedUsers)
+}
+
+// requireSystemAdmin 仅允许系统管理员访问
+func requireSystemAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if role != "admin" && role != "super_admin" {
+			standardErrorResponse(c, http.StatusForbidden, "需要系统管理员权限", "")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func createResume(sqlDB *sql.DB, userID uint, req CreateResumeRequest) string {
 	resumeID := fmt.Sprintf("resume_%d_%d", userID, time.Now().Unix())
 
@@ -713,15 +854,6 @@ func deleteResume(sqlDB *sql.DB, resumeID string, userID uint) bool {
 	return rowsAffected > 0
 }
 
-func checkResumeOwnership(sqlDB *sql.DB, resumeID string, userID uint) bool {
-	query := `SELECT 1 FROM resume WHERE resume_id = $1 AND user_id = $2`
-	row := sqlDB.QueryRow(query, resumeID, userID)
-
-	var exists int
-	err := row.Scan(&exists)
-	return err == nil
-}
-
 func getResumePermissionConfig(sqlDB *sql.DB, resumeID string) *gin.H {
 	query := `
 		SELECT resume_id, privacy_level, allow_download, require_approval, 
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	jobfirst "github.com/szjason72/zervigo/shared/core"
+	"github.com/szjason72/zervigo/shared/core/policy"
+	"gorm.io/gorm"
+)
+
+// resumeResourceType 简历资源在访问策略中的类型
+const resumeResourceType = "resume"
+
+// sensitivityRank 敏感级别对应的数值，便于在策略中比较
+var sensitivityRank = map[string]int{
+	SensitivityLevel1: 1,
+	SensitivityLevel2: 2,
+	SensitivityLevel3: 3,
+	SensitivityLevel4: 4,
+}
+
+// defaultResumePolicies 默认简历访问策略（规则表中还没有简历规则时写入，之后以数据库为准）
+func defaultResumePolicies() []policy.Policy {
+	return []policy.Policy{
+		{
+			Name:        "resume-blacklist",
+			Description: "简历所有者拉黑的企业不能访问简历",
+			Resource:    resumeResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectDeny,
+			Condition:   `subject.user_id != resource.owner_id && intersects(subject.company_ids, resource.blacklisted_companies)`,
+			Priority:    100,
+			Enabled:     true,
+		},
+		{
+			Name:        "resume-owner",
+			Description: "简历所有者拥有简历的全部权限",
+			Resource:    resumeResourceType,
+			Actions:     "*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.user_id == resource.owner_id`,
+			Priority:    50,
+			Enabled:     true,
+		},
+		{
+			Name:        "resume-system-admin-read",
+			Description: "系统管理员可以查看简历及其权限配置",
+			Resource:    resumeResourceType,
+			Actions:     "read,view_*",
+			Effect:      policy.EffectAllow,
+			Condition:   `subject.role in ["admin", "super_admin"]`,
+			Priority:    40,
+			Enabled:     true,
+		},
+		{
+			Name:        "resume-enterprise-read-public",
+			Description: "企业用户可以查看公开且不含极高敏感信息的简历",
+			Resource:    resumeResourceType,
+			Actions:     "read",
+			Effect:      policy.EffectAllow,
+			Condition:   `size(subject.company_ids) > 0 && resource.privacy_level == "PUBLIC" && resource.sensitivity_rank < 4`,
+			Priority:    10,
+			Enabled:     true,
+		},
+	}
+}
+
+// newResumePolicyMiddleware 创建简历访问控制中间件，规则表不可用时使用内存中的默认规则
+func newResumePolicyMiddleware(core *jobfirst.Core, sqlDB *sql.DB) *policy.Middleware {
+	var gormDB *gorm.DB
+	if pgManager := core.Database.GetPostgreSQL(); pgManager != nil {
+		gormDB = pgManager.GetDB()
+	} else if mysqlManager := core.Database.GetMySQL(); mysqlManager != nil {
+		gormDB = mysqlManager.GetDB()
+	}
+
+	var engine *policy.Engine
+	var err error
+	if gormDB != nil {
+		var store policy.Store
+		if store, err = policy.NewGormStore(gormDB); err == nil {
+			if engine, err = policy.NewEngine(store); err == nil {
+				err = engine.SeedDefaults(defaultResumePolicies())
+			}
+		}
+	}
+	if engine == nil || err != nil {
+		log.Printf("初始化访问策略失败，使用默认规则: %v", err)
+		engine, _ = policy.NewEngine(policy.NewMemoryStore(defaultResumePolicies()))
+	}
+
+	middleware := policy.NewMiddleware(engine)
+	middleware.RegisterResource(resumeResourceType, func(c *gin.Context, resourceID string) (policy.Attributes, error) {
+		return resolveResume(sqlDB, resourceID)
+	})
+	middleware.AddSubjectEnricher(func(c *gin.Context, subject, resource policy.Attributes) error {
+		return enrichSubjectCompanies(sqlDB, subject)
+	})
+	return middleware
+}
+
+// resolveResume 简历资源属性：所有者、公开级别、敏感级别与黑名单企业
+func resolveResume(sqlDB *sql.DB, resumeID string) (policy.Attributes, error) {
+	query := `
+		SELECT user_id, resume_status, privacy_level, user_name, user_phone, user_email
+		FROM resume
+		WHERE resume_id = $1
+	`
+	var ownerID uint
+	var resumeStatus, privacyLevel, userName, userPhone, userEmail string
+	err := sqlDB.QueryRow(query, resumeID).Scan(&ownerID, &resumeStatus, &privacyLevel, &userName, &userPhone, &userEmail)
+	if err == sql.ErrNoRows {
+		return nil, policy.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 按敏感信息感知解析器的分类规则计算简历整体敏感级别
+	parsed := &SensitivityAwareParsedData{}
+	if personalInfo := nonEmptyFields(map[string]string{"name": userName, "phone": userPhone, "email": userEmail}); len(personalInfo) > 0 {
+		parsed.PersonalInfo = personalInfo
+	}
+	parser := NewSensitivityAwareParser()
+	classification, err := parser.ClassifySensitiveData(parsed)
+	if err != nil {
+		return nil, err
+	}
+	sensitivityLevel := parser.calculateOverallSensitivityLevel(classification)
+
+	blacklisted := []string{}
+	rows, err := sqlDB.Query(`SELECT enterprise_id FROM resume_blacklist WHERE resume_id = $1`, resumeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var enterpriseID string
+		if err := rows.Scan(&enterpriseID); err != nil {
+			return nil, err
+		}
+		blacklisted = append(blacklisted, enterpriseID)
+	}
+
+	return policy.Attributes{
+		"owner_id":              ownerID,
+		"status":                resumeStatus,
+		"privacy_level":         privacyLevel,
+		"sensitivity_level":     sensitivityLevel,
+		"sensitivity_rank":      sensitivityRank[sensitivityLevel],
+		"blacklisted_companies": blacklisted,
+	}, rows.Err()
+}
+
+// enrichSubjectCompanies 补充当前用户所属的企业（与黑名单中的企业ID比较）
+func enrichSubjectCompanies(sqlDB *sql.DB, subject policy.Attributes) error {
+	companyIDs := []string{}
+	subject["company_ids"] = companyIDs
+
+	userID, _ := subject["user_id"].(uint)
+	if userID == 0 {
+		return nil
+	}
+
+	rows, err := sqlDB.Query(`SELECT company_id FROM company_users WHERE user_id = $1 AND status = 'active'`, userID)
+	if err != nil {
+		// 未部署企业服务的环境中没有company_users表，按不属于任何企业处理
+		log.Printf("查询用户所属企业失败: %v", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var companyID uint64
+		if err := rows.Scan(&companyID); err != nil {
+			return err
+		}
+		companyIDs = append(companyIDs, strconv.FormatUint(companyID, 10))
+	}
+	subject["company_ids"] = companyIDs
+	return rows.Err()
+}
+
+// requireResumeAccess 检查当前用户对简历的操作权限，不允许时写入错误响应
+func requireResumeAccess(c *gin.Context, policies *policy.Middleware, resumeID, action, deniedMessage string) bool {
+	decision, err := policies.Check(c, resumeResourceType, resumeID, action, nil)
+	if errors.Is(err, policy.ErrResourceNotFound) {
+		standardErrorResponse(c, http.StatusNotFound, "简历不存在", "")
+		return false
+	}
+	if err != nil {
+		standardErrorResponse(c, http.StatusInternalServerError, "权限检查失败", err.Error())
+		return false
+	}
+	if !decision.Allowed {
+		standardErrorResponse(c, http.StatusForbidden, deniedMessage, decision.Reason)
+		return false
+	}
+	return true
+}
+
+func nonEmptyFields(fields map[string]string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for key, value := range fields {
+		if value != "" {
+			result[key] = value
+		}
+	}
+	return result
+}
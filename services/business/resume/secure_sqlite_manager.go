@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -175,7 +176,7 @@ func (sm *SecureSQLiteManager) migrateTables(db *gorm.DB) error {
 	}
 
 	// 自动迁移表结构
-	if err := db.AutoMigrate(&ResumeFile{}, &Resume{}, &ResumeParsingTask{}, &ResumeContent{}, &ParsedResumeDataDB{}, &UserPrivacySettings{}); err != nil {
+	if err := db.AutoMigrate(&ResumeFile{}, &Resume{}, &ResumeParsingTask{}, &ResumeContent{}, &ParsedResumeDataDB{}, &UserPrivacySettings{}, &ResumeDataKey{}); err != nil {
 		return fmt.Errorf("自动迁移失败: %v", err)
 	}
 
@@ -233,6 +234,33 @@ func (sm *SecureSQLiteManager) CloseAllConnections() error {
 	return nil
 }
 
+// ListUserIDs 列出已有数据目录的用户，供批量维护任务使用
+func (sm *SecureSQLiteManager) ListUserIDs() ([]uint, error) {
+	entries, err := os.ReadDir(filepath.Join(sm.basePath, "users"))
+	if os.IsNotExist(err) {
+		return []uint{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取用户数据目录失败: %v", err)
+	}
+
+	userIDs := []uint{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		userID, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil || userID == 0 {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(sm.basePath, "users", entry.Name(), "resume.db")); err != nil {
+			continue
+		}
+		userIDs = append(userIDs, uint(userID))
+	}
+	return userIDs, nil
+}
+
 // ValidateUserAccess 验证用户访问权限
 func (sm *SecureSQLiteManager) ValidateUserAccess(userID uint, requestUserID uint) error {
 	if userID != requestUserID {
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,6 +8,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // 敏感程度分级定义
@@ -227,16 +228,17 @@ var DataClassificationConfig = map[string]DataClassificationTag{
 
 // 敏感信息感知的文本解析器
 type SensitivityAwareTextParser struct {
-	encryptionKey []byte
+	encryptor *FieldEncryptor // 为空时使用全局字段加密器
 }
 
 // 创建新的敏感信息感知解析器
 func NewSensitivityAwareTextParser() *SensitivityAwareTextParser {
-	// 在实际应用中，应该从安全的配置中获取加密密钥
-	key := []byte("your-32-byte-long-key-here!12345") // 32字节密钥
-	return &SensitivityAwareTextParser{
-		encryptionKey: key,
-	}
+	return &SensitivityAwareTextParser{}
+}
+
+// SetFieldEncryptor 指定高敏感字段使用的加密器
+func (p *SensitivityAwareTextParser) SetFieldEncryptor(encryptor *FieldEncryptor) {
+	p.encryptor = encryptor
 }
 
 // 解析文件并应用敏感信息分类
@@ -1027,9 +1029,7 @@ type SensitivityAwareParsedDataForStorage struct {
 
 // NewSensitivityAwareParser 创建新的敏感信息感知解析器
 func NewSensitivityAwareParser() *SensitivityAwareTextParser {
-	return &SensitivityAwareTextParser{
-		encryptionKey: []byte("default-encryption-key-32-bytes-long"),
-	}
+	return &SensitivityAwareTextParser{}
 }
 
 // ParseMinerUResult 解析MinerU返回的结果
@@ -1278,17 +1278,18 @@ func (p *SensitivityAwareTextParser) ClassifySensitiveData(data *SensitivityAwar
 	return classification, nil
 }
 
-// ProtectSensitiveData 根据敏感度级别保护数据
-func (p *SensitivityAwareTextParser) ProtectSensitiveData(data *SensitivityAwareParsedData, classification map[string]DataClassificationTag) (*SensitivityAwareParsedDataForStorage, error) {
+// ProtectSensitiveData 根据敏感度级别保护数据，高敏感及以上的字段使用数据所有者的数据密钥加密，
+// userDB 为所有者的SQLite数据库
+func (p *SensitivityAwareTextParser) ProtectSensitiveData(userDB *gorm.DB, userID uint, data *SensitivityAwareParsedData, classification map[string]DataClassificationTag) (*SensitivityAwareParsedDataForStorage, error) {
 	result := &SensitivityAwareParsedDataForStorage{
 		Confidence: data.Confidence,
 	}
 
 	// 处理个人信息（高敏感 - 加密）
 	if personalInfo, exists := classification["personal_info"]; exists {
-		if personalInfo.SensitivityLevel == SensitivityLevel3 {
+		if sensitivityRank[personalInfo.SensitivityLevel] >= sensitivityRank[SensitivityLevel3] {
 			// 对高敏感信息进行加密
-			encryptedData, err := p.encryptSensitiveData(data.PersonalInfo)
+			encryptedData, err := p.encryptSensitiveData(userDB, userID, data.PersonalInfo)
 			if err != nil {
 				return nil, fmt.Errorf("加密个人信息失败: %v", err)
 			}
@@ -1394,18 +1395,58 @@ func (p *SensitivityAwareTextParser) determineOverallSensitivityLevel(classifica
 }
 
 // encryptSensitiveData 加密敏感数据
-func (p *SensitivityAwareTextParser) encryptSensitiveData(data interface{}) (string, error) {
-	// 将数据序列化为JSON
-	jsonData, err := json.Marshal(data)
+func (p *SensitivityAwareTextParser) encryptSensitiveData(userDB *gorm.DB, userID uint, data interface{}) (string, error) {
+	encryptor, err := p.fieldEncryptor()
 	if err != nil {
-		return "", fmt.Errorf("序列化数据失败: %v", err)
+		return "", err
+	}
+	return encryptor.EncryptJSON(userDB, userID, data)
+}
+
+// RevealPersonalInfo 解密个人信息。所有者本人可直接查看；其他用户需同时通过访问策略检查（permitted）
+// 且简历隐私设置允许向企业共享
+func (p *SensitivityAwareTextParser) RevealPersonalInfo(userDB *gorm.DB, ownerID, viewerID uint, permitted bool, settings *UserPrivacySettings, personalInfoJSON string) (map[string]interface{}, error) {
+	if err := authorizeSensitiveAccess(ownerID, viewerID, permitted, settings); err != nil {
+		return nil, err
 	}
 
-	// 这里应该使用真正的加密算法，现在只是简单编码
-	// 在实际生产环境中，应该使用AES等强加密算法
-	encoded := base64.StdEncoding.EncodeToString(jsonData)
+	personalInfo := make(map[string]interface{})
+	if personalInfoJSON == "" {
+		return personalInfo, nil
+	}
+	if !IsEncryptedField(personalInfoJSON) {
+		if err := json.Unmarshal([]byte(personalInfoJSON), &personalInfo); err != nil {
+			return nil, fmt.Errorf("解析个人信息失败: %v", err)
+		}
+		return personalInfo, nil
+	}
 
-	return fmt.Sprintf("ENCRYPTED:%s", encoded), nil
+	encryptor, err := p.fieldEncryptor()
+	if err != nil {
+		return nil, err
+	}
+	if err := encryptor.DecryptJSON(userDB, ownerID, personalInfoJSON, &personalInfo); err != nil {
+		return nil, fmt.Errorf("解密个人信息失败: %w", err)
+	}
+	return personalInfo, nil
+}
+
+func (p *SensitivityAwareTextParser) fieldEncryptor() (*FieldEncryptor, error) {
+	if p.encryptor != nil {
+		return p.encryptor, nil
+	}
+	return GetFieldEncryptor()
+}
+
+// authorizeSensitiveAccess 敏感信息查看权限：调用者权限与简历隐私设置须同时允许
+func authorizeSensitiveAccess(ownerID, viewerID uint, permitted bool, settings *UserPrivacySettings) error {
+	if viewerID != 0 && viewerID == ownerID {
+		return nil
+	}
+	if !permitted || settings == nil || !settings.ShareWithCompanies {
+		return ErrSensitiveAccessDenied
+	}
+	return nil
 }
 
 // extractPersonalInfo 从内容中提取个人信息
@@ -7,6 +7,9 @@ import (
 	"os"
 
 	"github.com/szjason72/zervigo/shared/core/auth"
+	"github.com/szjason72/zervigo/shared/core/auth/migrations"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 
 	_ "github.com/lib/pq"
 )
@@ -38,6 +41,8 @@ func main() {
 
 	// 创建统一认证系统
 	authSystem := auth.NewUnifiedAuthSystem(db, jwtSecret)
+	authSystem.SetRevocationStore(auth.RevocationStoreFromEnv()) // 与用户服务共享Redis，登出后令牌立即失效
+	authSystem.SetLoginGuard(auth.NewLoginGuard(auth.LockoutPolicyFromEnv(), auth.LoginAttemptStoreFromEnv())) // 登录失败计数在多实例间共享
 
 	// 初始化数据库
 	log.Println("正在初始化数据库...")
@@ -46,6 +51,35 @@ func main() {
 	}
 	log.Println("数据库初始化完成")
 
+	// 非对称签名密钥集：私钥只在认证服务与用户服务（共用密钥表），其他服务通过JWKS获取公钥
+	keyStore, err := authSystem.SigningKeyStore()
+	if err != nil {
+		log.Fatalf("初始化签名密钥存储失败: %v", err)
+	}
+	keySet, err := auth.NewKeySet(auth.KeySetConfigFromEnv(), keyStore)
+	if err != nil {
+		log.Fatalf("加载签名密钥失败: %v", err)
+	}
+	keySet.Start()
+	defer keySet.Stop()
+	authSystem.SetKeySet(keySet)
+
+	// TOTP两步验证（super_admin 及 MFA_REQUIRED_ROLES 中的角色必须启用）
+	mfaStore, err := authSystem.MFAStore()
+	if err != nil {
+		log.Fatalf("初始化两步验证存储失败: %v", err)
+	}
+	authSystem.SetMFAService(auth.NewMFAService(mfaStore, auth.MFAPolicyFromEnv()))
+
+	// OAuth2表迁移（复用同一连接）
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("初始化OAuth2迁移失败: %v", err)
+	}
+	if err := migrations.RunOAuth2Migrations(gormDB); err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	// 创建API服务器
 	port := 8207
 	if portEnv := os.Getenv("AUTH_SERVICE_PORT"); portEnv != "" {
@@ -65,6 +99,14 @@ func main() {
 	log.Println("  POST /api/v1/auth/log - 访问日志")
 	log.Println("  GET  /api/v1/auth/roles - 获取角色列表")
 	log.Println("  GET  /api/v1/auth/permissions - 获取权限列表")
+	log.Println("  GET  /oauth2/authorize - OAuth2授权（授权码 + PKCE）")
+	log.Println("  POST /oauth2/token - OAuth2令牌（authorization_code/client_credentials/refresh_token）")
+	log.Println("  GET  /oauth2/userinfo - OIDC用户信息")
+	log.Println("  POST /oauth2/introspect - 令牌内省")
+	log.Println("  POST /oauth2/revoke - 令牌吊销")
+	log.Println("  GET  /.well-known/openid-configuration - OIDC发现文档")
+	log.Println("  GET  /.well-known/jwks.json - 签名公钥")
+	log.Println("  POST /api/v1/auth/oauth2/clients - 注册OAuth2客户端（超级管理员）")
 	log.Println("  GET  /health - 健康检查")
 
 	if err := api.Start(); err != nil {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -31,13 +32,32 @@ func main() {
 	}
 	defer core.Close()
 
+	// 登录、刷新与两步验证签发的令牌使用与认证服务相同的非对称密钥集（公钥由认证服务JWKS发布）
+	keyStore, err := core.AuthManager.SigningKeyStore()
+	if err != nil {
+		log.Fatalf("初始化签名密钥存储失败: %v", err)
+	}
+	keySet, err := auth.NewKeySet(auth.KeySetConfigFromEnv(), keyStore)
+	if err != nil {
+		log.Fatalf("加载签名密钥失败: %v", err)
+	}
+	keySet.Start()
+	defer keySet.Stop()
+	core.AuthManager.SetKeySet(keySet)
+
 	// auth-service URL
 	authServiceURL := "http://localhost:8207"
 	log.Printf("初始化集中式认证 - auth-service URL: %s", authServiceURL)
 
 	// 创建认证服务客户端
 	authClient := auth.NewAuthClient(authServiceURL)
-	log.Println("认证客户端已创建")
+	// 与认证服务共享Redis吊销列表时才在本地校验令牌，否则登出无法及时生效
+	if revocations, ok := auth.RevocationStoreFromEnv().(*auth.RedisRevocationStore); ok {
+		authClient.SetRevocationStore(revocations)
+		log.Println("认证客户端已创建（JWKS本地校验）")
+	} else {
+		log.Println("认证客户端已创建")
+	}
 
 	// 执行服务握手（获取Service Token用于内部服务间通信）
 	log.Println("执行服务握手...")
@@ -72,6 +92,11 @@ func main() {
 	// 创建Gin引擎
 	r := gin.Default()
 
+	// 只采信可信代理（TRUSTED_PROXIES）转发的客户端IP，登录锁定与审计按真实IP计数
+	if err := r.SetTrustedProxies(auth.TrustedProxiesFromEnv()); err != nil {
+		log.Fatalf("可信代理配置无效: %v", err)
+	}
+
 	// 设置标准路由 (使用jobfirst-core统一模板)
 	setupStandardRoutes(r, core)
 
@@ -159,20 +184,105 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, authClient *auth.Au
 			userAgent := c.GetHeader("User-Agent")
 			response, err := core.AuthManager.Login(req, clientIP, userAgent)
 			if err != nil {
+				var lockErr *auth.LockoutError
+				if errors.As(err, &lockErr) {
+					c.Header("Retry-After", strconv.Itoa(int(lockErr.RetryAfter().Seconds())+1))
+					standardErrorResponse(c, http.StatusTooManyRequests, err.Error(), err.Error())
+					return
+				}
 				standardErrorResponse(c, http.StatusUnauthorized, "Login failed", err.Error())
 				return
 			}
 
+			if response.MFARequired {
+				// 密码正确，需调用 /auth/mfa/verify 完成两步验证
+				standardSuccessResponse(c, response, "MFA required")
+				return
+			}
 			standardSuccessResponse(c, response, "Login successful")
 		})
 
-		// 刷新Token
+		// 两步验证登录：使用登录返回的 challenge_token 与动态码（或恢复码）换取令牌
+		public.POST("/auth/mfa/verify", func(c *gin.Context) {
+			var req auth.MFAVerifyRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+				return
+			}
+
+			response, err := core.AuthManager.VerifyMFALogin(req.ChallengeToken, req.Code, c.ClientIP(), c.GetHeader("User-Agent"))
+			if err != nil {
+				mfaErrorResponse(c, "MFA verification failed", err)
+				return
+			}
+
+			standardSuccessResponse(c, response, "Login successful")
+		})
+
+		// 角色强制两步验证但尚未登记的账号，凭 challenge_token 生成TOTP密钥
+		public.POST("/auth/mfa/enroll", func(c *gin.Context) {
+			var req auth.MFAEnrollRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+				return
+			}
+
+			enrollment, err := core.AuthManager.BeginMFAEnrollmentWithChallenge(req.ChallengeToken)
+			if err != nil {
+				mfaErrorResponse(c, "MFA enrollment failed", err)
+				return
+			}
+
+			standardSuccessResponse(c, enrollment, "Scan the provisioning URI and verify a code to finish enrollment")
+		})
+
+		// 刷新Token（刷新令牌一次性使用，每次刷新返回新的令牌对）
 		public.POST("/auth/refresh", func(c *gin.Context) {
-			standardSuccessResponse(c, gin.H{"message": "Token refresh functionality"}, "Token refresh endpoint")
+			var req auth.RefreshRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+				return
+			}
+
+			response, err := core.AuthManager.Refresh(req.RefreshToken, c.ClientIP(), c.GetHeader("User-Agent"))
+			if err != nil {
+				switch {
+				case errors.Is(err, auth.ErrRefreshTokenInvalid),
+					errors.Is(err, auth.ErrRefreshTokenExpired),
+					errors.Is(err, auth.ErrRefreshTokenRevoked),
+					errors.Is(err, auth.ErrRefreshTokenReused):
+					standardErrorResponse(c, http.StatusUnauthorized, "Token refresh failed", err.Error())
+				default:
+					standardErrorResponse(c, http.StatusInternalServerError, "Token refresh failed", err.Error())
+				}
+				return
+			}
+
+			standardSuccessResponse(c, response, "Token refreshed successfully")
 		})
 
-		// 用户登出
+		// 用户登出（吊销访问令牌，并吊销refresh_token所在的令牌家族）
 		public.POST("/auth/logout", func(c *gin.Context) {
+			// 请求体可为空（仅吊销访问令牌）；访问令牌已过期时凭refresh_token仍可登出
+			var req auth.LogoutRequest
+			if c.Request.ContentLength > 0 {
+				if err := c.ShouldBindJSON(&req); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+					return
+				}
+			}
+
+			token := extractTokenFromRequest(c)
+			if token == "" && req.RefreshToken == "" {
+				standardErrorResponse(c, http.StatusUnauthorized, "Logout failed", "未登录")
+				return
+			}
+
+			if err := core.AuthManager.Logout(token, req.RefreshToken, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+				standardErrorResponse(c, http.StatusUnauthorized, "Logout failed", err.Error())
+				return
+			}
+
 			standardSuccessResponse(c, gin.H{"message": "Logout successful"}, "Logout successful")
 		})
 	}
@@ -286,7 +396,177 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, authClient *auth.Au
 
 			// 修改密码
 			users.PUT("/password", func(c *gin.Context) {
-				standardSuccessResponse(c, gin.H{"message": "Password change functionality"}, "Password change endpoint")
+				userID := c.GetInt("user_id")
+				if userID == 0 {
+					standardErrorResponse(c, http.StatusUnauthorized, "User ID not found", "")
+					return
+				}
+
+				var req struct {
+					OldPassword string `json:"old_password" binding:"required"`
+					NewPassword string `json:"new_password" binding:"required"`
+				}
+				if err := c.ShouldBindJSON(&req); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+					return
+				}
+
+				if err := core.AuthManager.ChangePassword(uint(userID), req.OldPassword, req.NewPassword, c.ClientIP()); err != nil {
+					var lockErr *auth.LockoutError
+					var policyErr *auth.PasswordPolicyError
+					switch {
+					case errors.As(err, &lockErr):
+						c.Header("Retry-After", strconv.Itoa(int(lockErr.RetryAfter().Seconds())+1))
+						standardErrorResponse(c, http.StatusTooManyRequests, err.Error(), err.Error())
+					case errors.As(err, &policyErr), errors.Is(err, auth.ErrPasswordMismatch):
+						standardErrorResponse(c, http.StatusBadRequest, err.Error(), err.Error())
+					default:
+						standardErrorResponse(c, http.StatusInternalServerError, "Password change failed", err.Error())
+					}
+					return
+				}
+
+				// 修改密码后所有刷新令牌已吊销，客户端需重新登录
+				standardSuccessResponse(c, gin.H{"changed": true}, "Password changed successfully")
+			})
+
+			// 查看登录锁定（管理员功能）
+			users.GET("/lockouts", func(c *gin.Context) {
+				role := c.GetString("role")
+				if role != "admin" && role != "super_admin" {
+					standardErrorResponse(c, http.StatusForbidden, "Insufficient permissions", "")
+					return
+				}
+
+				lockouts, err := core.AuthManager.Lockouts()
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "Failed to get lockouts", err.Error())
+					return
+				}
+
+				standardSuccessResponse(c, lockouts, "Lockouts retrieved successfully")
+			})
+
+			// 解除登录锁定（管理员功能），key 形如 user:alice 或 ip:10.0.0.1
+			users.DELETE("/lockouts/:key", func(c *gin.Context) {
+				role := c.GetString("role")
+				if role != "admin" && role != "super_admin" {
+					standardErrorResponse(c, http.StatusForbidden, "Insufficient permissions", "")
+					return
+				}
+
+				if err := core.AuthManager.ClearLockout(c.Param("key")); err != nil {
+					if errors.Is(err, auth.ErrInvalidLockoutKey) {
+						standardErrorResponse(c, http.StatusBadRequest, "Invalid lockout key", err.Error())
+						return
+					}
+					standardErrorResponse(c, http.StatusInternalServerError, "Failed to clear lockout", err.Error())
+					return
+				}
+
+				standardSuccessResponse(c, gin.H{"cleared": c.Param("key")}, "Lockout cleared successfully")
+			})
+
+			// 两步验证状态
+			users.GET("/mfa", func(c *gin.Context) {
+				userID := c.GetInt("user_id")
+				if userID == 0 {
+					standardErrorResponse(c, http.StatusUnauthorized, "User ID not found", "")
+					return
+				}
+
+				status, err := core.AuthManager.MFAStatus(uint(userID))
+				if err != nil {
+					standardErrorResponse(c, http.StatusInternalServerError, "Failed to get MFA status", err.Error())
+					return
+				}
+
+				standardSuccessResponse(c, status, "MFA status retrieved successfully")
+			})
+
+			// 生成TOTP密钥（需用动态码确认后生效）
+			users.POST("/mfa/enroll", func(c *gin.Context) {
+				userID := c.GetInt("user_id")
+				if userID == 0 {
+					standardErrorResponse(c, http.StatusUnauthorized, "User ID not found", "")
+					return
+				}
+
+				enrollment, err := core.AuthManager.BeginMFAEnrollment(uint(userID))
+				if err != nil {
+					mfaErrorResponse(c, "MFA enrollment failed", err)
+					return
+				}
+
+				standardSuccessResponse(c, enrollment, "Scan the provisioning URI and confirm with a code")
+			})
+
+			// 确认登记，返回恢复码（仅此一次）
+			users.POST("/mfa/confirm", func(c *gin.Context) {
+				userID := c.GetInt("user_id")
+				if userID == 0 {
+					standardErrorResponse(c, http.StatusUnauthorized, "User ID not found", "")
+					return
+				}
+
+				var req auth.MFACodeRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+					return
+				}
+
+				codes, err := core.AuthManager.ConfirmMFAEnrollment(uint(userID), req.Code, c.ClientIP())
+				if err != nil {
+					mfaErrorResponse(c, "MFA confirmation failed", err)
+					return
+				}
+
+				standardSuccessResponse(c, gin.H{"recovery_codes": codes}, "MFA enabled, store the recovery codes safely")
+			})
+
+			// 重新生成恢复码（旧恢复码全部作废）
+			users.POST("/mfa/recovery-codes", func(c *gin.Context) {
+				userID := c.GetInt("user_id")
+				if userID == 0 {
+					standardErrorResponse(c, http.StatusUnauthorized, "User ID not found", "")
+					return
+				}
+
+				var req auth.MFACodeRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+					return
+				}
+
+				codes, err := core.AuthManager.RegenerateMFARecoveryCodes(uint(userID), req.Code, c.ClientIP())
+				if err != nil {
+					mfaErrorResponse(c, "Failed to regenerate recovery codes", err)
+					return
+				}
+
+				standardSuccessResponse(c, gin.H{"recovery_codes": codes}, "Recovery codes regenerated")
+			})
+
+			// 关闭两步验证（角色强制启用时拒绝）
+			users.DELETE("/mfa", func(c *gin.Context) {
+				userID := c.GetInt("user_id")
+				if userID == 0 {
+					standardErrorResponse(c, http.StatusUnauthorized, "User ID not found", "")
+					return
+				}
+
+				var req auth.MFACodeRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "Invalid request payload", err.Error())
+					return
+				}
+
+				if err := core.AuthManager.DisableMFA(uint(userID), req.Code, c.ClientIP()); err != nil {
+					mfaErrorResponse(c, "Failed to disable MFA", err)
+					return
+				}
+
+				standardSuccessResponse(c, gin.H{"enabled": false}, "MFA disabled")
 			})
 
 			// 获取用户列表（管理员功能）
@@ -370,8 +650,8 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, authClient *auth.Au
 				standardSuccessResponse(c, updateData, "User updated successfully")
 			})
 
-			// 删除用户（管理员功能）
-			users.DELETE("/:id", func(c *gin.Context) {
+			// 删除用户（管理员功能，需已完成两步验证的会话）
+			users.DELETE("/:id", requireMFASession(), func(c *gin.Context) {
 				// 检查管理员权限
 				role := c.GetString("role")
 				if role != "super_admin" {
@@ -817,6 +1097,8 @@ func standardErrorResponse(c *gin.Context, statusCode int, message string, detai
 		code = response.CodeForbidden
 	case http.StatusNotFound:
 		code = response.CodeNotFound
+	case http.StatusTooManyRequests:
+		code = response.CodeTooManyRequests
 	}
 
 	resp := response.Error(code, message)
@@ -977,12 +1259,47 @@ func createCentralizedAuthMiddleware(authClient *auth.AuthClient) gin.HandlerFun
 		c.Set("username", result.User.Username)
 		c.Set("role", result.User.Role)
 		c.Set("email", result.User.Email)
+		c.Set("amr", result.AMR)
 
 		fmt.Printf("DEBUG: 集中式认证中间件 - 用户信息已设置到上下文，继续处理请求\n")
 		c.Next()
 	}
 }
 
+// requireMFASession 敏感操作要求当前会话已完成两步验证（需在认证中间件之后）
+func requireMFASession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		amr, _ := c.Get("amr")
+		methods, _ := amr.([]string)
+		if !auth.HasAMR(methods, auth.AMRMFA) {
+			standardErrorResponse(c, http.StatusForbidden, "MFA required", "该操作需要完成两步验证后重新登录")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// mfaErrorResponse 两步验证错误映射为响应码
+func mfaErrorResponse(c *gin.Context, message string, err error) {
+	var lockErr *auth.LockoutError
+	switch {
+	case errors.As(err, &lockErr):
+		c.Header("Retry-After", strconv.Itoa(int(lockErr.RetryAfter().Seconds())+1))
+		standardErrorResponse(c, http.StatusTooManyRequests, err.Error(), err.Error())
+	case errors.Is(err, auth.ErrMFAChallengeInvalid):
+		standardErrorResponse(c, http.StatusUnauthorized, err.Error(), err.Error())
+	case errors.Is(err, auth.ErrMFAInvalidCode),
+		errors.Is(err, auth.ErrMFANotEnrolled),
+		errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		standardErrorResponse(c, http.StatusBadRequest, err.Error(), err.Error())
+	case errors.Is(err, auth.ErrMFARequired):
+		standardErrorResponse(c, http.StatusForbidden, err.Error(), err.Error())
+	default:
+		standardErrorResponse(c, http.StatusInternalServerError, message, err.Error())
+	}
+}
+
 // extractTokenFromRequest 从请求中提取token
 func extractTokenFromRequest(c *gin.Context) string {
 	// 从Authorization头获取
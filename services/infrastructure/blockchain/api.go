@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -61,6 +62,7 @@ func (api *BlockchainAPI) Start() error {
 
 		// 数据一致性校验API
 		v1.POST("/consistency/validate", api.validateDataConsistency)
+		v1.POST("/consistency/validate/:transaction_id", api.validateDataConsistency)
 
 		// 交易查询API
 		v1.GET("/transaction/list", api.getTransactionList)
@@ -213,7 +215,30 @@ func (api *BlockchainAPI) getUserPermissionHistory(c *gin.Context) {
 
 // validateDataConsistency 数据一致性校验
 func (api *BlockchainAPI) validateDataConsistency(c *gin.Context) {
-	response, err := api.service.ValidateDataConsistency(c.Request.Context())
+	var req ConsistencyValidationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "请求参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	// 指定交易的校验
+	if transactionID := c.Param("transaction_id"); transactionID != "" {
+		req.CheckType = CheckTypeSpecific
+		req.TransactionID = transactionID
+	}
+
+	response, err := api.service.ValidateDataConsistency(c.Request.Context(), &req)
+	if errors.Is(err, ErrConsistencyCheckRunning) {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	defaultConsistencyBatchSize = 200
+	maxConsistencyBatchSize     = 1000
+	maxConsistencyFindings      = 1000
+	maxOrphanFindings           = 100 // 每张记录表最多报告的孤儿记录数
+)
+
+// 校验类型
+const (
+	CheckTypeFull        = "FULL"        // 从指定游标（默认开头）检查到末尾
+	CheckTypeIncremental = "INCREMENTAL" // 从上次校验结束的位置继续
+	CheckTypeSpecific    = "SPECIFIC"    // 只检查指定交易
+)
+
+// 差异类型
+const (
+	findingMissing   = "missing"   // 账本中有交易，记录表中缺失
+	findingMismatch  = "mismatch"  // 记录字段与账本不一致
+	findingDuplicate = "duplicate" // 同一交易对应多条记录
+	findingOrphan    = "orphan"    // 记录表中有记录，账本中无对应交易
+)
+
+var ErrConsistencyCheckRunning = errors.New("数据一致性校验正在运行")
+
+// ledgerProjection 账本交易类型与其记录表的对应关系
+type ledgerProjection struct {
+	table     string
+	oldColumn string
+	newColumn string
+	prefix    string
+}
+
+var ledgerProjections = map[string]ledgerProjection{
+	"VERSION_STATUS":    {table: "version_status_record", oldColumn: "old_status", newColumn: "new_status", prefix: "VSR"},
+	"PERMISSION_CHANGE": {table: "permission_change_record", oldColumn: "old_permission", newColumn: "new_permission", prefix: "PCR"},
+}
+
+// consistencyFields 参与比较的字段
+var consistencyFields = []string{"user_id", "version_source", "old_value", "new_value", "change_reason", "operator_id", "block_height"}
+
+// ledgerEntry 账本交易（真实来源）
+type ledgerEntry struct {
+	TransactionID   string
+	TransactionHash string
+	TransactionType string
+	CreateTime      time.Time
+	RecordTime      time.Time
+	Fields          map[string]string
+}
+
+// projectedRecord 记录表中的记录
+type projectedRecord struct {
+	RecordID string
+	Fields   map[string]string
+}
+
+// ValidateDataConsistency 以区块链交易账本为准，按游标分批逐字段校验版本状态与权限变更记录
+func (s *BlockchainService) ValidateDataConsistency(ctx context.Context, req *ConsistencyValidationRequest) (*BlockchainResponse, error) {
+	checkType := strings.ToUpper(req.CheckType)
+	if checkType == "" {
+		checkType = CheckTypeFull
+	}
+
+	var result *ConsistencyCheckResult
+	var err error
+	switch checkType {
+	case CheckTypeSpecific:
+		if req.TransactionID == "" {
+			return nil, fmt.Errorf("SPECIFIC 校验需要指定 transaction_id")
+		}
+		result, err = s.checkTransaction(ctx, req.TransactionID, req.Repair)
+	case CheckTypeFull, CheckTypeIncremental:
+		result, err = s.runConsistencyCheck(ctx, checkType, req)
+	default:
+		return nil, fmt.Errorf("不支持的校验类型: %s", req.CheckType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockchainResponse{
+		Code:    200,
+		Message: "数据一致性校验完成",
+		Data:    result,
+	}, nil
+}
+
+// StartConsistencySchedule 定期执行增量一致性校验
+func (s *BlockchainService) StartConsistencySchedule(ctx context.Context, interval time.Duration, repair bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				req := &ConsistencyValidationRequest{CheckType: CheckTypeIncremental, Repair: repair}
+				result, err := s.runConsistencyCheck(ctx, CheckTypeIncremental, req)
+				if err != nil {
+					log.Printf("定期数据一致性校验失败: %v", err)
+					continue
+				}
+				if result.Inconsistencies > 0 {
+					log.Printf("WARN: 数据一致性校验发现差异: 检查 %d 条，差异 %d 条，修复 %d 条",
+						result.CheckedRecords, result.Inconsistencies, result.Repaired)
+				}
+			}
+		}
+	}()
+}
+
+// runConsistencyCheck 分批校验账本；同一时间只允许一个批量校验运行
+func (s *BlockchainService) runConsistencyCheck(ctx context.Context, checkType string, req *ConsistencyValidationRequest) (*ConsistencyCheckResult, error) {
+	if !s.consistencyRunning.TryLock() {
+		return nil, ErrConsistencyCheckRunning
+	}
+	defer s.consistencyRunning.Unlock()
+
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultConsistencyBatchSize
+	}
+	if batchSize > maxConsistencyBatchSize {
+		batchSize = maxConsistencyBatchSize
+	}
+
+	// 增量校验只对未按用户或版本过滤的校验记录进度
+	filtered := req.UserID != "" || req.VersionSource != ""
+	cursor := req.Cursor
+	if checkType == CheckTypeIncremental && cursor == "" && !filtered {
+		cursor = s.consistencyCursor
+	}
+	afterTime, afterID, err := parseConsistencyCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("开始执行数据一致性校验: type=%s, cursor=%q", checkType, cursor)
+	result := newConsistencyResult(checkType, cursor, req.Repair)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entries, err := s.loadLedgerBatch(ctx, afterTime, afterID, req.UserID, req.VersionSource, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.compareLedgerEntries(ctx, entries, result); err != nil {
+			return nil, err
+		}
+		result.CheckedRecords += len(entries)
+		if len(entries) > 0 {
+			last := entries[len(entries)-1]
+			afterTime, afterID = last.CreateTime, last.TransactionID
+		}
+
+		if len(entries) < batchSize {
+			result.Complete = true
+			break
+		}
+		if req.MaxRecords > 0 && result.CheckedRecords >= req.MaxRecords {
+			break
+		}
+	}
+
+	endCursor := formatConsistencyCursor(afterTime, afterID)
+	if !result.Complete {
+		result.NextCursor = endCursor
+	}
+	if result.Complete && checkType == CheckTypeFull && req.Cursor == "" {
+		if err := s.findOrphanRecords(ctx, req.UserID, req.VersionSource, result); err != nil {
+			return nil, err
+		}
+	}
+	if !filtered {
+		s.consistencyCursor = endCursor
+	}
+
+	s.finishConsistencyResult(result)
+	return result, nil
+}
+
+// checkTransaction 校验单笔交易与其记录
+func (s *BlockchainService) checkTransaction(ctx context.Context, transactionID string, repair bool) (*ConsistencyCheckResult, error) {
+	result := newConsistencyResult(CheckTypeSpecific, "", repair)
+
+	entries, err := s.queryLedger(ctx, "WHERE transaction_id = $1", transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("交易不存在: %s", transactionID)
+	}
+	if err := s.compareLedgerEntries(ctx, entries, result); err != nil {
+		return nil, err
+	}
+	result.CheckedRecords = len(entries)
+	result.Complete = true
+
+	s.finishConsistencyResult(result)
+	return result, nil
+}
+
+func newConsistencyResult(checkType, cursor string, repair bool) *ConsistencyCheckResult {
+	return &ConsistencyCheckResult{
+		CheckType:           checkType,
+		StartCursor:         cursor,
+		RepairMode:          repair,
+		InconsistentRecords: []map[string]interface{}{},
+	}
+}
+
+func (s *BlockchainService) finishConsistencyResult(result *ConsistencyCheckResult) {
+	result.ValidationTime = time.Now().Format("2006-01-02 15:04:05")
+	switch {
+	case result.Inconsistencies > 0 && result.Repaired == result.Inconsistencies:
+		result.Status = "REPAIRED"
+		result.Message = fmt.Sprintf("发现 %d 处差异，已全部按账本修复", result.Inconsistencies)
+	case result.Inconsistencies > 0:
+		result.Status = "FAILED"
+		result.Message = fmt.Sprintf("发现 %d 处差异", result.Inconsistencies)
+	case !result.Complete:
+		result.Status = "PARTIAL"
+		result.Message = "本批次未发现差异，可从 next_cursor 继续校验"
+	default:
+		result.Status = "PASSED"
+		result.Message = "账本与记录表数据一致"
+	}
+}
+
+// loadLedgerBatch 按 (create_time, transaction_id) 游标读取一批账本交易
+func (s *BlockchainService) loadLedgerBatch(ctx context.Context, afterTime time.Time, afterID, userID, versionSource string, limit int) ([]ledgerEntry, error) {
+	conditions := []string{"(create_time, transaction_id) > ($1, $2)"}
+	args := []interface{}{afterTime, afterID}
+	if userID != "" {
+		args = append(args, userID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if versionSource != "" {
+		args = append(args, versionSource)
+		conditions = append(conditions, fmt.Sprintf("version_source = $%d", len(args)))
+	}
+	args = append(args, limit)
+
+	clause := fmt.Sprintf("WHERE %s ORDER BY create_time, transaction_id LIMIT $%d", strings.Join(conditions, " AND "), len(args))
+	return s.queryLedger(ctx, clause, args...)
+}
+
+func (s *BlockchainService) queryLedger(ctx context.Context, clause string, args ...interface{}) ([]ledgerEntry, error) {
+	query := `
+	SELECT transaction_id, transaction_hash, transaction_type, version_source, user_id,
+	       old_status, new_status, change_reason, operator_id, block_height, create_time, confirm_time
+	FROM blockchain_transaction ` + clause
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询区块链交易失败: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ledgerEntry
+	for rows.Next() {
+		var (
+			entry                                                               ledgerEntry
+			versionSource, userID, oldValue, newValue, changeReason, operatorID sql.NullString
+			blockHeight                                                         sql.NullInt64
+			confirmTime                                                         sql.NullTime
+		)
+		if err := rows.Scan(&entry.TransactionID, &entry.TransactionHash, &entry.TransactionType,
+			&versionSource, &userID, &oldValue, &newValue, &changeReason, &operatorID,
+			&blockHeight, &entry.CreateTime, &confirmTime); err != nil {
+			return nil, fmt.Errorf("读取区块链交易失败: %w", err)
+		}
+
+		// 记录时间与写入时保持一致：优先使用确认时间
+		entry.RecordTime = entry.CreateTime
+		if confirmTime.Valid {
+			entry.RecordTime = confirmTime.Time
+		}
+		entry.Fields = map[string]string{
+			"user_id":        stringOrEmpty(userID),
+			"version_source": stringOrEmpty(versionSource),
+			"old_value":      stringOrEmpty(oldValue),
+			"new_value":      stringOrEmpty(newValue),
+			"change_reason":  stringOrEmpty(changeReason),
+			"operator_id":    stringOrEmpty(operatorID),
+			"block_height":   fmt.Sprintf("%d", intOrZero(blockHeight)),
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历区块链交易失败: %w", err)
+	}
+	return entries, nil
+}
+
+// compareLedgerEntries 按交易类型批量读取记录表并逐字段比较
+func (s *BlockchainService) compareLedgerEntries(ctx context.Context, entries []ledgerEntry, result *ConsistencyCheckResult) error {
+	byType := make(map[string][]ledgerEntry)
+	for _, entry := range entries {
+		if _, ok := ledgerProjections[entry.TransactionType]; ok {
+			byType[entry.TransactionType] = append(byType[entry.TransactionType], entry)
+		}
+	}
+
+	for transactionType, typed := range byType {
+		projection := ledgerProjections[transactionType]
+		hashes := make([]string, 0, len(typed))
+		for _, entry := range typed {
+			hashes = append(hashes, entry.TransactionHash)
+		}
+
+		records, err := s.loadProjectedRecords(ctx, projection, hashes)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range typed {
+			matched := records[entry.TransactionHash]
+			switch {
+			case len(matched) == 0:
+				finding := newFinding(entry, projection, "", findingMissing)
+				if result.RepairMode {
+					s.applyRepair(finding, result, s.insertProjectedRecord(ctx, projection, entry))
+				}
+				addFinding(result, finding)
+			case len(matched) > 1:
+				// 重复记录需人工确认保留哪一条，不自动修复
+				addFinding(result, newFinding(entry, projection, matched[0].RecordID, findingDuplicate))
+			default:
+				diffs := compareConsistencyFields(entry.Fields, matched[0].Fields)
+				if len(diffs) == 0 {
+					continue
+				}
+				finding := newFinding(entry, projection, matched[0].RecordID, findingMismatch)
+				finding["fields"] = diffs
+				if result.RepairMode {
+					s.applyRepair(finding, result, s.updateProjectedRecord(ctx, projection, matched[0].RecordID, entry))
+				}
+				addFinding(result, finding)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *BlockchainService) loadProjectedRecords(ctx context.Context, projection ledgerProjection, hashes []string) (map[string][]projectedRecord, error) {
+	query := fmt.Sprintf(`
+	SELECT record_id, transaction_hash, user_id, version_source, %s, %s, change_reason, operator_id, block_height
+	FROM %s
+	WHERE transaction_hash = ANY($1)
+	ORDER BY record_time`, projection.oldColumn, projection.newColumn, projection.table)
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(hashes))
+	if err != nil {
+		return nil, fmt.Errorf("查询%s失败: %w", projection.table, err)
+	}
+	defer rows.Close()
+
+	records := make(map[string][]projectedRecord)
+	for rows.Next() {
+		var (
+			recordID, transactionHash, userID, versionSource string
+			oldValue, changeReason, operatorID               sql.NullString
+			newValue                                         string
+			blockHeight                                      sql.NullInt64
+		)
+		if err := rows.Scan(&recordID, &transactionHash, &userID, &versionSource,
+			&oldValue, &newValue, &changeReason, &operatorID, &blockHeight); err != nil {
+			return nil, fmt.Errorf("读取%s失败: %w", projection.table, err)
+		}
+		records[transactionHash] = append(records[transactionHash], projectedRecord{
+			RecordID: recordID,
+			Fields: map[string]string{
+				"user_id":        userID,
+				"version_source": versionSource,
+				"old_value":      stringOrEmpty(oldValue),
+				"new_value":      newValue,
+				"change_reason":  stringOrEmpty(changeReason),
+				"operator_id":    stringOrEmpty(operatorID),
+				"block_height":   fmt.Sprintf("%d", intOrZero(blockHeight)),
+			},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历%s失败: %w", projection.table, err)
+	}
+	return records, nil
+}
+
+// findOrphanRecords 查找账本中没有对应交易的记录；孤儿记录只报告，不自动删除
+func (s *BlockchainService) findOrphanRecords(ctx context.Context, userID, versionSource string, result *ConsistencyCheckResult) error {
+	for transactionType, projection := range ledgerProjections {
+		conditions := []string{"t.transaction_id IS NULL"}
+		args := []interface{}{transactionType}
+		if userID != "" {
+			args = append(args, userID)
+			conditions = append(conditions, fmt.Sprintf("r.user_id = $%d", len(args)))
+		}
+		if versionSource != "" {
+			args = append(args, versionSource)
+			conditions = append(conditions, fmt.Sprintf("r.version_source = $%d", len(args)))
+		}
+		args = append(args, maxOrphanFindings)
+
+		query := fmt.Sprintf(`
+		SELECT r.record_id, r.transaction_hash
+		FROM %s r
+		LEFT JOIN blockchain_transaction t
+		  ON t.transaction_hash = r.transaction_hash AND t.transaction_type = $1
+		WHERE %s
+		ORDER BY r.record_time
+		LIMIT $%d`, projection.table, strings.Join(conditions, " AND "), len(args))
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("查询%s孤儿记录失败: %w", projection.table, err)
+		}
+		for rows.Next() {
+			var recordID, transactionHash string
+			if err := rows.Scan(&recordID, &transactionHash); err != nil {
+				rows.Close()
+				return fmt.Errorf("读取%s孤儿记录失败: %w", projection.table, err)
+			}
+			result.Orphans++
+			addFinding(result, map[string]interface{}{
+				"kind":             findingOrphan,
+				"table":            projection.table,
+				"record_id":        recordID,
+				"transaction_hash": transactionHash,
+				"transaction_type": transactionType,
+			})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("遍历%s孤儿记录失败: %w", projection.table, err)
+		}
+	}
+	return nil
+}
+
+// insertProjectedRecord 按账本补写缺失的记录
+func (s *BlockchainService) insertProjectedRecord(ctx context.Context, projection ledgerProjection, entry ledgerEntry) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (
+		record_id, user_id, version_source, %s, %s,
+		change_reason, operator_id, transaction_hash, block_height, record_time
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, projection.table, projection.oldColumn, projection.newColumn)
+
+	recordID := fmt.Sprintf("%s%d", projection.prefix, time.Now().UnixNano())
+	_, err := s.db.ExecContext(ctx, query,
+		recordID, entry.Fields["user_id"], entry.Fields["version_source"], entry.Fields["old_value"], entry.Fields["new_value"],
+		entry.Fields["change_reason"], entry.Fields["operator_id"], entry.TransactionHash, entry.Fields["block_height"], entry.RecordTime,
+	)
+	return err
+}
+
+// updateProjectedRecord 按账本覆盖记录字段
+func (s *BlockchainService) updateProjectedRecord(ctx context.Context, projection ledgerProjection, recordID string, entry ledgerEntry) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET user_id = $2, version_source = $3, %s = $4, %s = $5,
+		change_reason = $6, operator_id = $7, block_height = $8
+	WHERE record_id = $1`, projection.table, projection.oldColumn, projection.newColumn)
+
+	_, err := s.db.ExecContext(ctx, query,
+		recordID, entry.Fields["user_id"], entry.Fields["version_source"], entry.Fields["old_value"], entry.Fields["new_value"],
+		entry.Fields["change_reason"], entry.Fields["operator_id"], entry.Fields["block_height"],
+	)
+	return err
+}
+
+func (s *BlockchainService) applyRepair(finding map[string]interface{}, result *ConsistencyCheckResult, err error) {
+	if err != nil {
+		result.RepairFailed++
+		finding["repaired"] = false
+		finding["repair_error"] = err.Error()
+		log.Printf("修复交易 %v 的记录失败: %v", finding["transaction_id"], err)
+		return
+	}
+	result.Repaired++
+	finding["repaired"] = true
+}
+
+func newFinding(entry ledgerEntry, projection ledgerProjection, recordID, kind string) map[string]interface{} {
+	finding := map[string]interface{}{
+		"kind":             kind,
+		"table":            projection.table,
+		"transaction_id":   entry.TransactionID,
+		"transaction_hash": entry.TransactionHash,
+		"transaction_type": entry.TransactionType,
+	}
+	if recordID != "" {
+		finding["record_id"] = recordID
+	}
+	return finding
+}
+
+// addFinding 记录差异；差异过多时只保留前 maxConsistencyFindings 条明细
+func addFinding(result *ConsistencyCheckResult, finding map[string]interface{}) {
+	result.Inconsistencies++
+	if len(result.InconsistentRecords) >= maxConsistencyFindings {
+		result.Truncated = true
+		return
+	}
+	result.InconsistentRecords = append(result.InconsistentRecords, finding)
+}
+
+// compareConsistencyFields 逐字段比较内容哈希，只返回哈希不暴露字段值
+func compareConsistencyFields(ledger, record map[string]string) []map[string]string {
+	var diffs []map[string]string
+	for _, field := range consistencyFields {
+		ledgerHash := consistencyHash(ledger[field])
+		recordHash := consistencyHash(record[field])
+		if ledgerHash != recordHash {
+			diffs = append(diffs, map[string]string{
+				"field":       field,
+				"ledger_hash": ledgerHash,
+				"record_hash": recordHash,
+			})
+		}
+	}
+	return diffs
+}
+
+func consistencyHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// 游标格式："<create_time RFC3339Nano>|<transaction_id>"，空字符串表示从头开始
+func formatConsistencyCursor(createTime time.Time, transactionID string) string {
+	if transactionID == "" {
+		return ""
+	}
+	return createTime.UTC().Format(time.RFC3339Nano) + "|" + transactionID
+}
+
+func parseConsistencyCursor(cursor string) (time.Time, string, error) {
+	if cursor == "" {
+		return time.Time{}, "", nil
+	}
+	parts := strings.SplitN(cursor, "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", fmt.Errorf("无效的游标: %s", cursor)
+	}
+	createTime, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("无效的游标: %s", cursor)
+	}
+	return createTime, parts[1], nil
+}
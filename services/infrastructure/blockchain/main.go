@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -41,6 +42,17 @@ func main() {
 	}
 	log.Println("区块链数据库初始化完成")
 
+	// 定期增量校验账本与记录表的一致性
+	consistencyInterval, err := time.ParseDuration(getEnvString("BLOCKCHAIN_CONSISTENCY_INTERVAL", "6h"))
+	if err != nil || consistencyInterval <= 0 {
+		log.Printf("无效的一致性校验间隔，使用默认值6h: %v", err)
+		consistencyInterval = 6 * time.Hour
+	}
+	autoRepair, _ := strconv.ParseBool(getEnvString("BLOCKCHAIN_CONSISTENCY_AUTO_REPAIR", "false"))
+	scheduleCtx, stopSchedule := context.WithCancel(context.Background())
+	defer stopSchedule()
+	service.StartConsistencySchedule(scheduleCtx, consistencyInterval, autoRepair)
+
 	port := getEnvInt("BLOCKCHAIN_SERVICE_PORT", 8208)
 	api := NewBlockchainAPI(service, port)
 
@@ -51,6 +63,7 @@ func main() {
 	log.Println("  POST /api/v1/blockchain/permission/change/record - 记录权限变更")
 	log.Println("  GET  /api/v1/blockchain/permission/change/history/{userId} - 查询权限变更历史")
 	log.Println("  POST /api/v1/blockchain/consistency/validate - 数据一致性校验")
+	log.Println("  POST /api/v1/blockchain/consistency/validate/{transactionId} - 单笔交易一致性校验")
 	log.Println("  GET  /api/v1/blockchain/transaction/list - 查询区块链交易列表")
 	log.Println("  GET  /health - 健康检查")
 
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +17,9 @@ import (
 type BlockchainService struct {
 	db        *sql.DB
 	jwtSecret string
+
+	consistencyRunning sync.Mutex
+	consistencyCursor  string // 增量校验的进度游标
 }
 
 // NewBlockchainService 创建区块链服务
@@ -516,31 +520,6 @@ func (s *BlockchainService) GetTransactionList(ctx context.Context, req Transact
 	return transactions, total, nil
 }
 
-// ValidateDataConsistency 数据一致性校验
-func (s *BlockchainService) ValidateDataConsistency(ctx context.Context) (*BlockchainResponse, error) {
-	// 这里可以实现跨版本数据一致性校验逻辑
-	// 例如：比较不同版本中同一用户的状态是否一致
-
-	log.Println("开始执行数据一致性校验...")
-
-	// 模拟校验过程
-	time.Sleep(100 * time.Millisecond)
-
-	result := map[string]interface{}{
-		"validation_time": time.Now().Format("2006-01-02 15:04:05"),
-		"status":          "PASSED",
-		"checked_records": 1000,
-		"inconsistencies": 0,
-		"message":         "所有版本数据一致性校验通过",
-	}
-
-	return &BlockchainResponse{
-		Code:    200,
-		Message: "数据一致性校验完成",
-		Data:    result,
-	}, nil
-}
-
 func stringOrEmpty(val sql.NullString) string {
 	if val.Valid {
 		return val.String
@@ -33,7 +33,12 @@ type BlockchainResponse struct {
 type ConsistencyValidationRequest struct {
 	UserID        string `json:"user_id"`
 	VersionSource string `json:"version_source"`
-	CheckType     string `json:"check_type"` // FULL, INCREMENTAL, SPECIFIC
+	CheckType     string `json:"check_type"`     // FULL, INCREMENTAL, SPECIFIC
+	TransactionID string `json:"transaction_id"` // SPECIFIC 时必填
+	Cursor        string `json:"cursor"`         // 从该游标之后继续校验
+	BatchSize     int    `json:"batch_size"`
+	MaxRecords    int    `json:"max_records"` // 本次最多校验的交易数，0表示校验到末尾
+	Repair        bool   `json:"repair"`      // 是否以账本为准自动修复记录表
 }
 
 // TransactionQueryRequest 交易查询请求
@@ -113,10 +118,19 @@ type BlockchainTransaction struct {
 // ConsistencyCheckResult 一致性校验结果
 type ConsistencyCheckResult struct {
 	ValidationTime      string                   `json:"validation_time"`
-	Status              string                   `json:"status"`
+	Status              string                   `json:"status"` // PASSED, PARTIAL, FAILED, REPAIRED
+	CheckType           string                   `json:"check_type"`
+	StartCursor         string                   `json:"start_cursor"`
+	NextCursor          string                   `json:"next_cursor"` // 未完成时下次从该游标继续
+	Complete            bool                     `json:"complete"`
 	CheckedRecords      int                      `json:"checked_records"`
 	Inconsistencies     int                      `json:"inconsistencies"`
+	Orphans             int                      `json:"orphans"`
+	RepairMode          bool                     `json:"repair_mode"`
+	Repaired            int                      `json:"repaired"`
+	RepairFailed        int                      `json:"repair_failed"`
 	InconsistentRecords []map[string]interface{} `json:"inconsistent_records"`
+	Truncated           bool                     `json:"truncated"`
 	Message             string                   `json:"message"`
 }
 
@@ -187,6 +187,75 @@ func setupServiceIntegrationRoutes(r *gin.Engine, si *ServiceIntegration) {
 			})
 		})
 
+		// 职位申请阶段变更事件
+		eventAPI.POST("/application-stage-changed", func(c *gin.Context) {
+			var req ApplicationStageEvent
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			err := si.HandleApplicationStageChanged(req)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "处理职位申请事件失败",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":  "success",
+				"message": "职位申请事件处理完成",
+			})
+		})
+
+		// 职位生命周期事件
+		eventAPI.POST("/job-lifecycle", func(c *gin.Context) {
+			var req JobLifecycleEvent
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			err := si.HandleJobLifecycleEvent(req)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "处理职位生命周期事件失败",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":  "success",
+				"message": "职位生命周期事件处理完成",
+			})
+		})
+
+		// 职位提醒事件
+		eventAPI.POST("/job-alert", func(c *gin.Context) {
+			var req JobAlertEvent
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			err := si.HandleJobAlert(req)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "处理职位提醒失败",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"status":  "success",
+				"message": "职位提醒处理完成",
+			})
+		})
+
 		// 订阅变更事件
 		eventAPI.POST("/subscription-changed", func(c *gin.Context) {
 			var req struct {
@@ -200,6 +200,48 @@ func (nb *NotificationBusiness) SendCostControlNotification(userID uint, notific
 	)
 }
 
+// SendApplicationNotification 发送职位申请相关通知
+func (nb *NotificationBusiness) SendApplicationNotification(userID uint, notificationType, title, content, priority string, applicationData map[string]interface{}) error {
+	metadata := map[string]interface{}{
+		"notification_type": notificationType,
+		"application_data":  applicationData,
+		"timestamp":         time.Now().Unix(),
+	}
+
+	metadataJSON, _ := json.Marshal(metadata)
+
+	return nb.CreateNotification(
+		userID,
+		notificationType,
+		title,
+		content,
+		"application",
+		priority,
+		string(metadataJSON),
+	)
+}
+
+// SendJobNotification 发送职位状态相关通知（定时发布、到期关闭、招满关闭、到期提醒）
+func (nb *NotificationBusiness) SendJobNotification(userID uint, notificationType, title, content, priority string, jobData map[string]interface{}) error {
+	metadata := map[string]interface{}{
+		"notification_type": notificationType,
+		"job_data":          jobData,
+		"timestamp":         time.Now().Unix(),
+	}
+
+	metadataJSON, _ := json.Marshal(metadata)
+
+	return nb.CreateNotification(
+		userID,
+		notificationType,
+		title,
+		content,
+		"job",
+		priority,
+		string(metadataJSON),
+	)
+}
+
 // CheckAndSendQuotaWarning 检查并发送配额警告通知
 func (nb *NotificationBusiness) CheckAndSendQuotaWarning(userID uint) error {
 	// 这里需要调用Company服务的AI配额API来获取用户配额信息
@@ -46,6 +46,68 @@ type UserInfo struct {
 	IsActive           bool   `json:"is_active"`
 }
 
+// ApplicationStageEvent 职位申请阶段变更事件（由Job服务投递）
+type ApplicationStageEvent struct {
+	ApplicationID uint      `json:"application_id" binding:"required"`
+	JobID         uint      `json:"job_id" binding:"required"`
+	JobTitle      string    `json:"job_title"`
+	CompanyName   string    `json:"company_name"`
+	CandidateID   uint      `json:"candidate_id" binding:"required"`
+	RecruiterID   uint      `json:"recruiter_id"`
+	FromStage     string    `json:"from_stage"`
+	ToStage       string    `json:"to_stage" binding:"required"`
+	ChangedBy     uint      `json:"changed_by"`
+	Note          string    `json:"note"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// applicationStageNotices 申请进入各阶段时通知候选人的标题、内容模板与优先级
+var applicationStageNotices = map[string]struct {
+	title    string
+	content  string
+	priority string
+}{
+	"screening": {"简历筛选中", "您申请的%s「%s」已进入简历筛选阶段。", "normal"},
+	"interview": {"面试邀请", "恭喜！您申请的%s「%s」已进入面试阶段，请留意招聘方的联系。", "high"},
+	"offer":     {"录用意向", "恭喜！%s向您发出了「%s」的录用意向。", "urgent"},
+	"hired":     {"录用确认", "恭喜您正式加入%s，担任「%s」！", "high"},
+	"rejected":  {"申请结果通知", "很遗憾，您申请的%s「%s」未能通过，感谢您的关注。", "normal"},
+}
+
+// JobLifecycleEvent 职位生命周期事件（由Job服务调度器投递）
+type JobLifecycleEvent struct {
+	JobID       uint       `json:"job_id" binding:"required"`
+	JobTitle    string     `json:"job_title"`
+	CompanyName string     `json:"company_name"`
+	CreatorID   uint       `json:"creator_id"`
+	Reason      string     `json:"reason" binding:"required"`
+	FromStatus  string     `json:"from_status"`
+	ToStatus    string     `json:"to_status"`
+	ExpireAt    *time.Time `json:"expire_at"`
+	OccurredAt  time.Time  `json:"occurred_at"`
+}
+
+// JobAlertEvent 保存的搜索命中新职位的提醒（由Job服务投递）
+type JobAlertEvent struct {
+	UserID         uint           `json:"user_id" binding:"required"`
+	SavedSearchID  uint           `json:"saved_search_id" binding:"required"`
+	SearchName     string         `json:"search_name"`
+	Frequency      string         `json:"frequency"`
+	Jobs           []JobAlertItem `json:"jobs"`
+	Total          int            `json:"total"`
+	UnsubscribeURL string         `json:"unsubscribe_url"`
+	OccurredAt     time.Time      `json:"occurred_at"`
+}
+
+// JobAlertItem 提醒中列出的职位
+type JobAlertItem struct {
+	JobID       uint   `json:"job_id"`
+	Title       string `json:"title"`
+	CompanyName string `json:"company_name"`
+	Location    string `json:"location"`
+	Salary      string `json:"salary"`
+}
+
 // CheckUserQuotaAndSendNotification 检查用户配额并发送通知
 func (si *ServiceIntegration) CheckUserQuotaAndSendNotification(userID uint) error {
 	// 1. 获取用户配额信息
@@ -200,6 +262,150 @@ func (si *ServiceIntegration) SendWelcomeNotification(userID uint) error {
 	return nil
 }
 
+// HandleApplicationStageChanged 处理职位申请阶段变更：新申请与撤回通知招聘方，其余阶段通知候选人
+func (si *ServiceIntegration) HandleApplicationStageChanged(event ApplicationStageEvent) error {
+	data := map[string]interface{}{
+		"application_id": event.ApplicationID,
+		"job_id":         event.JobID,
+		"from_stage":     event.FromStage,
+		"to_stage":       event.ToStage,
+		"note":           event.Note,
+	}
+
+	switch event.ToStage {
+	case "applied":
+		if event.RecruiterID == 0 {
+			return nil
+		}
+		err := si.notificationBusiness.SendApplicationNotification(
+			event.RecruiterID,
+			"application_submitted",
+			"收到新的职位申请",
+			fmt.Sprintf("您发布的职位「%s」收到一份新的申请。", event.JobTitle),
+			"normal",
+			data,
+		)
+		if err != nil {
+			return fmt.Errorf("发送新申请通知失败: %v", err)
+		}
+
+	case "withdrawn":
+		if event.RecruiterID == 0 {
+			return nil
+		}
+		err := si.notificationBusiness.SendApplicationNotification(
+			event.RecruiterID,
+			"application_withdrawn",
+			"候选人撤回申请",
+			fmt.Sprintf("有候选人撤回了职位「%s」的申请。", event.JobTitle),
+			"low",
+			data,
+		)
+		if err != nil {
+			return fmt.Errorf("发送撤回申请通知失败: %v", err)
+		}
+
+	default:
+		title := "申请进度更新"
+		content := fmt.Sprintf("您申请的%s「%s」进入了%s阶段。", event.CompanyName, event.JobTitle, event.ToStage)
+		priority := "normal"
+		if notice, ok := applicationStageNotices[event.ToStage]; ok {
+			title = notice.title
+			content = fmt.Sprintf(notice.content, event.CompanyName, event.JobTitle)
+			priority = notice.priority
+		}
+		err := si.notificationBusiness.SendApplicationNotification(
+			event.CandidateID,
+			"application_"+event.ToStage,
+			title,
+			content,
+			priority,
+			data,
+		)
+		if err != nil {
+			return fmt.Errorf("发送申请进度通知失败: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// HandleJobLifecycleEvent 处理职位生命周期事件，通知职位创建者
+func (si *ServiceIntegration) HandleJobLifecycleEvent(event JobLifecycleEvent) error {
+	if event.CreatorID == 0 {
+		return nil
+	}
+
+	var title, content, priority string
+	switch event.Reason {
+	case "scheduled_publish":
+		title = "职位已发布"
+		content = fmt.Sprintf("您设置定时发布的职位「%s」已按计划发布。", event.JobTitle)
+		priority = "normal"
+	case "expired":
+		title = "职位已到期关闭"
+		content = fmt.Sprintf("您发布的职位「%s」已到期，系统已自动关闭。", event.JobTitle)
+		priority = "normal"
+	case "headcount_filled":
+		title = "职位已招满"
+		content = fmt.Sprintf("您发布的职位「%s」录用人数已满，系统已自动关闭。", event.JobTitle)
+		priority = "normal"
+	case "expiry_reminder":
+		title = "职位即将到期"
+		content = fmt.Sprintf("您发布的职位「%s」即将到期", event.JobTitle)
+		if event.ExpireAt != nil {
+			content += fmt.Sprintf("（%s）", event.ExpireAt.Local().Format("2006-01-02 15:04"))
+		}
+		content += "，如需继续招聘请及时延长有效期。"
+		priority = "high"
+	default:
+		return fmt.Errorf("未知的职位生命周期事件: %s", event.Reason)
+	}
+
+	data := map[string]interface{}{
+		"job_id":      event.JobID,
+		"reason":      event.Reason,
+		"from_status": event.FromStatus,
+		"to_status":   event.ToStatus,
+	}
+	if err := si.notificationBusiness.SendJobNotification(event.CreatorID, "job_"+event.Reason, title, content, priority, data); err != nil {
+		return fmt.Errorf("发送职位生命周期通知失败: %v", err)
+	}
+	return nil
+}
+
+// HandleJobAlert 处理职位提醒，通知中附带退订链接
+func (si *ServiceIntegration) HandleJobAlert(event JobAlertEvent) error {
+	if len(event.Jobs) == 0 {
+		return nil
+	}
+
+	name := event.SearchName
+	if name == "" {
+		name = "保存的搜索"
+	}
+	title := fmt.Sprintf("「%s」有 %d 个新职位", name, event.Total)
+	first := event.Jobs[0]
+	content := fmt.Sprintf("%s - %s", first.CompanyName, first.Title)
+	if event.Total > 1 {
+		content += fmt.Sprintf(" 等 %d 个职位符合您的搜索条件。", event.Total)
+	} else {
+		content += " 符合您的搜索条件。"
+	}
+
+	data := map[string]interface{}{
+		"saved_search_id": event.SavedSearchID,
+		"frequency":       event.Frequency,
+		"jobs":            event.Jobs,
+		"total":           event.Total,
+		"unsubscribe_url": event.UnsubscribeURL,
+	}
+	if err := si.notificationBusiness.SendJobNotification(event.UserID, "job_alert", title, content, "normal", data); err != nil {
+		return fmt.Errorf("发送职位提醒失败: %v", err)
+	}
+	return nil
+}
+
 // getUserQuotaFromCompanyService 从Company服务获取用户配额信息
 func (si *ServiceIntegration) getUserQuotaFromCompanyService(userID uint) (*UserQuotaInfo, error) {
 	url := fmt.Sprintf("http://localhost:8083/api/v1/quota/user/%d", userID)
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +37,11 @@ func main() {
 		log.Fatalf("获取PostgreSQL连接失败: %v", err)
 	}
 
+	// 角色继承与策略版本号所需的表结构
+	if err := ensurePermissionSchema(sqlDB); err != nil {
+		log.Printf("初始化权限表结构失败: %v", err)
+	}
+
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
 
@@ -127,12 +133,17 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusBadRequest, "请求参数错误", err.Error())
 					return
 				}
+				if err := validateRoleExtends(sqlDB, "", req.RoleName, req.Extends); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "角色继承配置错误", err.Error())
+					return
+				}
 
 				roleID := createRole(sqlDB, req)
 				if roleID == "" {
 					standardErrorResponse(c, http.StatusInternalServerError, "创建角色失败", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				result := gin.H{
 					"roleId":  roleID,
@@ -150,11 +161,16 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusBadRequest, "请求参数错误", err.Error())
 					return
 				}
+				if err := validateRoleExtends(sqlDB, roleID, req.RoleName, req.Extends); err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "角色继承配置错误", err.Error())
+					return
+				}
 
 				if !updateRole(sqlDB, roleID, req) {
 					standardErrorResponse(c, http.StatusNotFound, "角色不存在", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				standardSuccessResponse(c, "角色已更新", "角色更新成功")
 			})
@@ -163,10 +179,16 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 			roles.DELETE("/:roleId", func(c *gin.Context) {
 				roleID := c.Param("roleId")
 
+				if children := getChildRoles(sqlDB, roleID); len(children) > 0 {
+					standardErrorResponse(c, http.StatusBadRequest, "存在继承该角色的子角色，无法删除", strings.Join(children, ","))
+					return
+				}
+
 				if !deleteRole(sqlDB, roleID) {
 					standardErrorResponse(c, http.StatusNotFound, "角色不存在", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				standardSuccessResponse(c, "角色已删除", "角色删除成功")
 			})
@@ -195,12 +217,19 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusBadRequest, "请求参数错误", err.Error())
 					return
 				}
+				expr, err := auth.ParsePermissionExpression(req.PermissionCode)
+				if err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "权限编码格式错误", err.Error())
+					return
+				}
+				fillPermissionResourceAction(&req.ResourceType, &req.Action, expr)
 
 				permissionID := createPermission(sqlDB, req)
 				if permissionID == "" {
 					standardErrorResponse(c, http.StatusInternalServerError, "创建权限失败", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				result := gin.H{
 					"permissionId": permissionID,
@@ -218,11 +247,18 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusBadRequest, "请求参数错误", err.Error())
 					return
 				}
+				expr, err := auth.ParsePermissionExpression(req.PermissionCode)
+				if err != nil {
+					standardErrorResponse(c, http.StatusBadRequest, "权限编码格式错误", err.Error())
+					return
+				}
+				fillPermissionResourceAction(&req.ResourceType, &req.Action, expr)
 
 				if !updatePermission(sqlDB, permissionID, req) {
 					standardErrorResponse(c, http.StatusNotFound, "权限不存在", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				standardSuccessResponse(c, "权限已更新", "权限更新成功")
 			})
@@ -235,6 +271,7 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusNotFound, "权限不存在", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				standardSuccessResponse(c, "权限已删除", "权限删除成功")
 			})
@@ -344,6 +381,7 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusInternalServerError, "分配权限失败", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				standardSuccessResponse(c, "权限分配成功", "权限分配成功")
 			})
@@ -357,6 +395,7 @@ func setupBusinessRoutes(r *gin.Engine, core *jobfirst.Core, sqlDB *sql.DB, jwtS
 					standardErrorResponse(c, http.StatusNotFound, "角色权限不存在", "")
 					return
 				}
+				bumpPolicyVersion(sqlDB)
 
 				standardSuccessResponse(c, "权限移除成功", "权限移除成功")
 			})
@@ -369,12 +408,14 @@ type CreateRoleRequest struct {
 	RoleName        string `json:"roleName" binding:"required"`
 	RoleDescription string `json:"roleDescription"`
 	Level           int    `json:"level"`
+	Extends         string `json:"extends"` // 父角色名，继承其全部权限
 }
 
 type UpdateRoleRequest struct {
 	RoleName        string `json:"roleName"`
 	RoleDescription string `json:"roleDescription"`
 	Level           int    `json:"level"`
+	Extends         string `json:"extends"`
 }
 
 type CreatePermissionRequest struct {
@@ -406,7 +447,7 @@ type AssignPermissionRequest struct {
 // 业务逻辑函数
 func getAllRoles(sqlDB *sql.DB) []gin.H {
 	query := `
-		SELECT id, role_name, role_description, level, created_at, updated_at
+		SELECT id, role_name, role_description, level, COALESCE(extends_role, ''), created_at, updated_at
 		FROM zervigo_auth_roles
 		ORDER BY level DESC, created_at ASC
 	`
@@ -420,11 +461,11 @@ func getAllRoles(sqlDB *sql.DB) []gin.H {
 	var roles []gin.H
 	for rows.Next() {
 		var id int
-		var roleName, roleDescription string
+		var roleName, roleDescription, extends string
 		var level int
 		var createdAt, updatedAt time.Time
 
-		err := rows.Scan(&id, &roleName, &roleDescription, &level, &createdAt, &updatedAt)
+		err := rows.Scan(&id, &roleName, &roleDescription, &level, &extends, &createdAt, &updatedAt)
 		if err != nil {
 			log.Printf("扫描角色列表失败: %v", err)
 			continue
@@ -435,6 +476,7 @@ func getAllRoles(sqlDB *sql.DB) []gin.H {
 			"roleName":        roleName,
 			"roleDescription": roleDescription,
 			"level":           level,
+			"extends":         extends,
 			"createdAt":       createdAt.UnixMilli(),
 			"updatedAt":       updatedAt.UnixMilli(),
 		}
@@ -490,13 +532,13 @@ func getAllPermissions(sqlDB *sql.DB) []gin.H {
 
 func createRole(sqlDB *sql.DB, req CreateRoleRequest) string {
 	query := `
-		INSERT INTO zervigo_auth_roles (role_name, role_description, level, created_at, updated_at)
-		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO zervigo_auth_roles (role_name, role_description, level, extends_role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING id
 	`
 
 	var roleID int
-	err := sqlDB.QueryRow(query, req.RoleName, req.RoleDescription, req.Level).Scan(&roleID)
+	err := sqlDB.QueryRow(query, req.RoleName, req.RoleDescription, req.Level, req.Extends).Scan(&roleID)
 	if err != nil {
 		log.Printf("创建角色失败: %v", err)
 		return ""
@@ -508,11 +550,11 @@ func createRole(sqlDB *sql.DB, req CreateRoleRequest) string {
 func updateRole(sqlDB *sql.DB, roleID string, req UpdateRoleRequest) bool {
 	query := `
 		UPDATE zervigo_auth_roles 
-		SET role_name = $1, role_description = $2, level = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4
+		SET role_name = $1, role_description = $2, level = $3, extends_role = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
 	`
 
-	result, err := sqlDB.Exec(query, req.RoleName, req.RoleDescription, req.Level, roleID)
+	result, err := sqlDB.Exec(query, req.RoleName, req.RoleDescription, req.Level, req.Extends, roleID)
 	if err != nil {
 		log.Printf("更新角色失败: %v", err)
 		return false
@@ -537,18 +579,18 @@ func deleteRole(sqlDB *sql.DB, roleID string) bool {
 
 func getRoleDetail(sqlDB *sql.DB, roleID string) *gin.H {
 	query := `
-		SELECT id, role_name, role_description, level, created_at, updated_at
+		SELECT id, role_name, role_description, level, COALESCE(extends_role, ''), created_at, updated_at
 		FROM zervigo_auth_roles 
 		WHERE id = $1
 	`
 	row := sqlDB.QueryRow(query, roleID)
 
 	var id int
-	var roleName, roleDescription string
+	var roleName, roleDescription, extends string
 	var level int
 	var createdAt, updatedAt time.Time
 
-	err := row.Scan(&id, &roleName, &roleDescription, &level, &createdAt, &updatedAt)
+	err := row.Scan(&id, &roleName, &roleDescription, &level, &extends, &createdAt, &updatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil
@@ -562,6 +604,7 @@ func getRoleDetail(sqlDB *sql.DB, roleID string) *gin.H {
 		"roleName":        roleName,
 		"roleDescription": roleDescription,
 		"level":           level,
+		"extends":         extends,
 		"createdAt":       createdAt.UnixMilli(),
 		"updatedAt":       updatedAt.UnixMilli(),
 	}
@@ -802,6 +845,112 @@ func removePermissionFromRole(sqlDB *sql.DB, roleID, permissionID string) bool {
 	return rowsAffected > 0
 }
 
+// ensurePermissionSchema 补充角色继承列与策略版本号表
+func ensurePermissionSchema(sqlDB *sql.DB) error {
+	statements := []string{
+		`ALTER TABLE zervigo_auth_roles ADD COLUMN IF NOT EXISTS extends_role VARCHAR(50) DEFAULT ''`,
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY,
+			version BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`, auth.PolicyVersionTable),
+	}
+	for _, statement := range statements {
+		if _, err := sqlDB.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bumpPolicyVersion 角色/权限变更后递增策略版本号，各服务据此清空权限缓存
+func bumpPolicyVersion(sqlDB *sql.DB) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (id, version, updated_at)
+		VALUES (1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (id) DO UPDATE SET version = %[1]s.version + 1, updated_at = CURRENT_TIMESTAMP
+	`, auth.PolicyVersionTable)
+
+	if _, err := sqlDB.Exec(query); err != nil {
+		log.Printf("更新策略版本号失败: %v", err)
+	}
+}
+
+// validateRoleExtends 校验父角色存在且继承链中不会出现当前角色（roleID为空表示新建角色）
+func validateRoleExtends(sqlDB *sql.DB, roleID, roleName, extends string) error {
+	if extends == "" {
+		return nil
+	}
+
+	self := map[string]bool{roleName: true}
+	if roleID != "" {
+		var currentName string
+		if err := sqlDB.QueryRow(`SELECT role_name FROM zervigo_auth_roles WHERE id = $1`, roleID).Scan(&currentName); err == nil {
+			self[currentName] = true
+		}
+	}
+
+	chain, err := auth.ResolveRoleChain(extends, func(role string) (string, error) {
+		var parent string
+		err := sqlDB.QueryRow(`SELECT COALESCE(extends_role, '') FROM zervigo_auth_roles WHERE role_name = $1`, role).Scan(&parent)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("角色 %s 不存在", role)
+		}
+		return parent, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, role := range chain {
+		if self[role] {
+			return fmt.Errorf("%w: %s", auth.ErrRoleInheritanceCycle, strings.Join(append(chain, extends), " -> "))
+		}
+	}
+	if len(chain) >= auth.MaxRoleInheritanceDepth {
+		return auth.ErrRoleInheritanceDepth
+	}
+	return nil
+}
+
+// getChildRoles 查询继承指定角色的子角色
+func getChildRoles(sqlDB *sql.DB, roleID string) []string {
+	query := `
+		SELECT child.role_name
+		FROM zervigo_auth_roles child
+		JOIN zervigo_auth_roles parent ON child.extends_role = parent.role_name
+		WHERE parent.id = $1
+	`
+	rows, err := sqlDB.Query(query, roleID)
+	if err != nil {
+		log.Printf("查询子角色失败: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var children []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			children = append(children, name)
+		}
+	}
+	return children
+}
+
+// fillPermissionResourceAction 未指定资源类型/操作时从权限编码中提取
+func fillPermissionResourceAction(resourceType, action *string, expr auth.PermissionExpression) {
+	if *resourceType == "" {
+		*resourceType = expr.Resource()
+	}
+	if *action == "" {
+		*action = expr.Action()
+		if *action == "" {
+			*action = auth.PermissionWildcard
+		}
+	}
+}
+
 // 辅助函数
 func registerToConsul(serviceName, serviceHost string, servicePort int) {
 	client, err := api.NewClient(api.DefaultConfig())
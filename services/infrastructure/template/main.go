@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -30,6 +31,11 @@ func main() {
 	if enhancedService != nil {
 		defer enhancedService.Close()
 		log.Println("模板增强服务初始化成功")
+
+		// 定期跨存储对账
+		reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+		defer stopReconcile()
+		enhancedService.StartReconciliation(reconcileCtx)
 	}
 
 	// 设置Gin模式
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/szjason72/zervigo/shared/core"
+	"github.com/szjason72/zervigo/shared/core/reconcile"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -21,6 +23,9 @@ type TemplateEnhancedService struct {
 	postgresDB  *gorm.DB
 	neo4jDriver neo4j.DriverWithContext
 	redisClient *redis.Client
+
+	reconcilerOnce sync.Once
+	reconciler     *reconcile.Engine
 }
 
 // NewTemplateEnhancedService 创建模板增强服务
@@ -144,11 +149,20 @@ func (s *TemplateEnhancedService) GenerateTemplateVector(templateID uint, conten
 		return fmt.Errorf("生成向量失败: %v", err)
 	}
 
+	// 元数据记录内容哈希，供对账比较向量是否基于最新内容生成
+	metadata, err := json.Marshal(map[string]interface{}{
+		"content_length": len(content),
+		"content_hash":   reconcile.HashValue(content),
+	})
+	if err != nil {
+		return err
+	}
+
 	// 保存向量到PostgreSQL
 	templateVector := TemplateVector{
 		TemplateID:    templateID,
 		ContentVector: vector,
-		Metadata:      `{"content_length": ` + fmt.Sprintf("%d", len(content)) + `}`,
+		Metadata:      string(metadata),
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -158,6 +172,7 @@ func (s *TemplateEnhancedService) GenerateTemplateVector(templateID uint, conten
 	if err := s.postgresDB.Where("template_id = ?", templateID).First(&existing).Error; err == nil {
 		// 更新现有向量
 		existing.ContentVector = vector
+		existing.Metadata = string(metadata)
 		existing.UpdatedAt = time.Now()
 		return s.postgresDB.Save(&existing).Error
 	} else {
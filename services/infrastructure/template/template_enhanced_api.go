@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/szjason72/zervigo/shared/core"
+	"github.com/szjason72/zervigo/shared/core/reconcile"
 )
 
 // setupEnhancedRoutes 设置增强API路由
@@ -251,6 +254,36 @@ func setupEnhancedRoutes(r *gin.Engine, core *jobfirst.Core, enhancedService *Te
 					"data":   status,
 				})
 			})
+
+			// 模板跨存储对账，repair=true 时以MySQL为准修复（仅限管理员）
+			sync.POST("/:id/reconcile", func(c *gin.Context) {
+				templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模板ID"})
+					return
+				}
+
+				repair, _ := strconv.ParseBool(c.Query("repair"))
+				if repair && !isAdminRole(c) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅管理员可修复"})
+					return
+				}
+
+				report, err := enhancedService.ReconcileTemplate(uint(templateID), repair)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "对账失败: " + err.Error()})
+					return
+				}
+
+				status := "consistent"
+				if !report.Consistent() {
+					status = "inconsistent"
+				}
+				c.JSON(http.StatusOK, gin.H{
+					"status": status,
+					"data":   report,
+				})
+			})
 		}
 
 		// 模板分析API
@@ -358,5 +391,58 @@ func setupEnhancedRoutes(r *gin.Engine, core *jobfirst.Core, enhancedService *Te
 				})
 			})
 		}
+
+		// 全量对账API（仅限管理员）
+		reconciliation := enhanced.Group("/reconcile")
+		{
+			// 按游标分批执行全量对账
+			reconciliation.POST("", func(c *gin.Context) {
+				if !isAdminRole(c) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+					return
+				}
+
+				var opts reconcile.Options
+				if c.Request.ContentLength > 0 {
+					if err := c.ShouldBindJSON(&opts); err != nil {
+						c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+						return
+					}
+				}
+
+				report, err := enhancedService.Reconciler().Run(context.Background(), opts)
+				if err != nil {
+					if errors.Is(err, reconcile.ErrRunInProgress) {
+						c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+						return
+					}
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+
+				c.JSON(http.StatusOK, gin.H{
+					"status": "success",
+					"data":   report,
+				})
+			})
+
+			// 获取最近的对账报告
+			reconciliation.GET("/reports", func(c *gin.Context) {
+				if !isAdminRole(c) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+					return
+				}
+
+				c.JSON(http.StatusOK, gin.H{
+					"status": "success",
+					"data":   enhancedService.Reconciler().History(),
+				})
+			})
+		}
 	}
 }
+
+func isAdminRole(c *gin.Context) bool {
+	role := c.GetString("role")
+	return role == "admin" || role == "super_admin"
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/szjason72/zervigo/shared/core/reconcile"
+	"gorm.io/gorm"
+)
+
+const defaultTemplateReconcileInterval = 6 * time.Hour
+
+// templateReconcileFields 模板的规范化字段；使用次数与评分会被直接更新、不经过同步，不参与对账
+func templateReconcileFields(template Template) map[string]string {
+	return map[string]string{
+		"name":         template.Name,
+		"category":     template.Category,
+		"description":  template.Description,
+		"content_hash": reconcile.HashValue(template.Content),
+		"variables":    template.Variables,
+		"preview":      template.Preview,
+		"is_active":    reconcile.Bool(template.IsActive),
+		"created_by":   reconcile.Uint(template.CreatedBy),
+	}
+}
+
+// Reconciler 模板跨存储对账引擎（MySQL为数据源），修复通过 SyncTemplateToAllDatabases 完成
+func (s *TemplateEnhancedService) Reconciler() *reconcile.Engine {
+	s.reconcilerOnce.Do(func() {
+		var targets []reconcile.Target
+		if s.postgresDB != nil {
+			targets = append(targets, &templatePostgresTarget{db: s.postgresDB})
+		}
+		if s.neo4jDriver != nil {
+			targets = append(targets, &templateNeo4jTarget{driver: s.neo4jDriver})
+		}
+		if s.redisClient != nil {
+			targets = append(targets, &templateRedisTarget{client: s.redisClient})
+		}
+		s.reconciler = reconcile.NewEngine("template", &templateMySQLSource{db: s.mysqlDB}, targets, reconcile.RepairFunc(s.repairTemplate))
+	})
+	return s.reconciler
+}
+
+// ReconcileTemplate 对单个模板做字段级对账，repair 时以MySQL为准修复
+func (s *TemplateEnhancedService) ReconcileTemplate(templateID uint, repair bool) (*reconcile.Report, error) {
+	return s.Reconciler().CheckEntity(context.Background(), templateID, repair)
+}
+
+// StartReconciliation 定期全量对账，间隔与是否自动修复由环境变量配置
+func (s *TemplateEnhancedService) StartReconciliation(ctx context.Context) {
+	interval := defaultTemplateReconcileInterval
+	if value := os.Getenv("TEMPLATE_RECONCILE_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			interval = parsed
+		}
+	}
+	autoRepair, _ := strconv.ParseBool(os.Getenv("TEMPLATE_RECONCILE_AUTO_REPAIR"))
+	s.Reconciler().Schedule(ctx, interval, reconcile.Options{Repair: autoRepair})
+}
+
+// repairTemplate 模板存在时重新同步到所有数据库，已删除时清理各目标中的孤儿数据
+func (s *TemplateEnhancedService) repairTemplate(ctx context.Context, templateID uint) error {
+	var template Template
+	err := s.mysqlDB.WithContext(ctx).First(&template, templateID).Error
+	if err == nil {
+		return s.SyncTemplateToAllDatabases(&template)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("获取模板失败: %v", err)
+	}
+
+	if s.postgresDB != nil {
+		if err := s.postgresDB.WithContext(ctx).Where("template_id = ?", templateID).Delete(&TemplateVector{}).Error; err != nil {
+			return fmt.Errorf("删除PostgreSQL模板向量失败: %v", err)
+		}
+	}
+	if s.neo4jDriver != nil {
+		session := s.neo4jDriver.NewSession(ctx, neo4j.SessionConfig{})
+		defer session.Close(ctx)
+		if _, err := session.Run(ctx, "MATCH (t:Template {id: $id}) DETACH DELETE t", map[string]interface{}{"id": int64(templateID)}); err != nil {
+			return fmt.Errorf("删除Neo4j模板节点失败: %v", err)
+		}
+	}
+	if s.redisClient != nil {
+		if err := s.redisClient.Del(ctx, fmt.Sprintf("template:%d", templateID)).Err(); err != nil {
+			return fmt.Errorf("删除Redis模板缓存失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// templateMySQLSource MySQL模板数据源
+type templateMySQLSource struct {
+	db *gorm.DB
+}
+
+func (src *templateMySQLSource) Batch(ctx context.Context, after uint, limit int) ([]reconcile.Record, error) {
+	var templates []Template
+	if err := src.db.WithContext(ctx).Where("id > ?", after).Order("id ASC").Limit(limit).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]reconcile.Record, 0, len(templates))
+	for _, template := range templates {
+		records = append(records, reconcile.Record{ID: template.ID, Fields: templateReconcileFields(template)})
+	}
+	return records, nil
+}
+
+func (src *templateMySQLSource) Get(ctx context.Context, id uint) (*reconcile.Record, error) {
+	var templates []Template
+	if err := src.db.WithContext(ctx).Where("id = ?", id).Limit(1).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	return &reconcile.Record{ID: id, Fields: templateReconcileFields(templates[0])}, nil
+}
+
+// templatePostgresTarget PostgreSQL中的模板向量，比较生成向量时的内容哈希
+type templatePostgresTarget struct {
+	db *gorm.DB
+}
+
+func (t *templatePostgresTarget) Name() string {
+	return "postgresql"
+}
+
+func (t *templatePostgresTarget) Load(ctx context.Context, ids []uint) (map[uint]reconcile.Record, error) {
+	var rows []struct {
+		TemplateID  uint
+		ContentHash string
+	}
+	if err := t.db.WithContext(ctx).Table("template_vectors").
+		Select("template_id, COALESCE(metadata->>'content_hash', '') AS content_hash").
+		Where("template_id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]reconcile.Record, len(rows))
+	for _, row := range rows {
+		records[row.TemplateID] = reconcile.Record{
+			ID:     row.TemplateID,
+			Fields: map[string]string{"content_hash": row.ContentHash},
+		}
+	}
+	return records, nil
+}
+
+func (t *templatePostgresTarget) IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error) {
+	query := t.db.WithContext(ctx).Table("template_vectors").Where("template_id > ?", after)
+	if upTo > 0 {
+		query = query.Where("template_id <= ?", upTo)
+	}
+	var ids []uint
+	err := query.Distinct("template_id").Order("template_id ASC").Limit(limit).Pluck("template_id", &ids).Error
+	return ids, err
+}
+
+// templateNeo4jTarget Neo4j中的模板节点
+type templateNeo4jTarget struct {
+	driver neo4j.DriverWithContext
+}
+
+func (t *templateNeo4jTarget) Name() string {
+	return "neo4j"
+}
+
+func (t *templateNeo4jTarget) Load(ctx context.Context, ids []uint) (map[uint]reconcile.Record, error) {
+	templateIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		templateIDs = append(templateIDs, int64(id))
+	}
+
+	session := t.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, `
+		MATCH (t:Template) WHERE t.id IN $ids
+		RETURN t.id AS id, t.name AS name, t.category AS category, t.description AS description, t.created_by AS created_by
+	`, map[string]interface{}{"ids": templateIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]reconcile.Record)
+	for result.Next(ctx) {
+		record := result.Record()
+		id, _ := record.Values[0].(int64)
+		createdBy, _ := record.Values[4].(int64)
+		records[uint(id)] = reconcile.Record{
+			ID: uint(id),
+			Fields: map[string]string{
+				"name":        neo4jString(record.Values[1]),
+				"category":    neo4jString(record.Values[2]),
+				"description": neo4jString(record.Values[3]),
+				"created_by":  reconcile.Int(createdBy),
+			},
+		}
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (t *templateNeo4jTarget) IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error) {
+	session := t.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, `
+		MATCH (t:Template) WHERE t.id > $after AND ($up_to = 0 OR t.id <= $up_to)
+		RETURN t.id AS id ORDER BY id LIMIT $limit
+	`, map[string]interface{}{"after": int64(after), "up_to": int64(upTo), "limit": int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	for result.Next(ctx) {
+		if id, ok := result.Record().Values[0].(int64); ok {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, result.Err()
+}
+
+func neo4jString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// templateRedisTarget Redis中的模板缓存
+type templateRedisTarget struct {
+	client *redis.Client
+}
+
+func (t *templateRedisTarget) Name() string {
+	return "redis"
+}
+
+func (t *templateRedisTarget) Load(ctx context.Context, ids []uint) (map[uint]reconcile.Record, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf("template:%d", id))
+	}
+	values, err := t.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]reconcile.Record)
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var template Template
+		if err := json.Unmarshal([]byte(data), &template); err != nil {
+			return nil, fmt.Errorf("解析模板缓存 %s 失败: %v", keys[i], err)
+		}
+		records[ids[i]] = reconcile.Record{ID: ids[i], Fields: templateReconcileFields(template)}
+	}
+	return records, nil
+}
+
+// IDsInRange 缓存有过期时间且无法高效按范围枚举，不检查孤儿缓存
+func (t *templateRedisTarget) IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error) {
+	return nil, reconcile.ErrRangeUnsupported
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/szjason72/zervigo/shared/central-brain/utils"
+)
+
+// gatewayAdminRoles 允许调用网关管理写接口的用户角色
+var gatewayAdminRoles = map[string]bool{"admin": true, "super_admin": true}
+
+// 服务token校验结果的缓存时间：有效结果缓存较久以减少Auth Service调用，无效结果只短暂缓存
+const (
+	serviceTokenValidTTL   = time.Minute
+	serviceTokenInvalidTTL = 10 * time.Second
+	serviceTokenCacheMax   = 1024
+)
+
+// errInvalidServiceToken Auth Service明确判定服务token无效
+var errInvalidServiceToken = errors.New("无效的服务token")
+
+// serviceIdentity 已校验的调用方服务身份
+type serviceIdentity struct {
+	serviceID   string
+	serviceName string
+}
+
+type serviceTokenEntry struct {
+	identity  *serviceIdentity // nil表示token无效
+	expiresAt time.Time
+}
+
+// serviceTokenCache 服务token校验结果缓存（按token摘要索引）
+type serviceTokenCache struct {
+	mu      sync.Mutex
+	entries map[string]serviceTokenEntry
+}
+
+func newServiceTokenCache() *serviceTokenCache {
+	return &serviceTokenCache{entries: make(map[string]serviceTokenEntry)}
+}
+
+func (sc *serviceTokenCache) get(key string) (serviceTokenEntry, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	entry, exists := sc.entries[key]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return serviceTokenEntry{}, false
+	}
+	return entry, true
+}
+
+func (sc *serviceTokenCache) set(key string, identity *serviceIdentity, ttl time.Duration) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	now := time.Now()
+	if len(sc.entries) >= serviceTokenCacheMax {
+		for k, entry := range sc.entries {
+			if now.After(entry.expiresAt) {
+				delete(sc.entries, k)
+			}
+		}
+		if len(sc.entries) >= serviceTokenCacheMax {
+			sc.entries = make(map[string]serviceTokenEntry)
+		}
+	}
+	sc.entries[key] = serviceTokenEntry{identity: identity, expiresAt: now.Add(ttl)}
+}
+
+// serviceIdentityMiddleware 校验调用方携带的X-Service-Token，通过后设置service_id、service_name，
+// 供策略限流分桶与管理接口鉴权使用。校验失败不拦截请求（转发时会剥离该头），只是不认可其服务身份
+func (cb *CentralBrain) serviceIdentityMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if serviceToken := c.GetHeader("X-Service-Token"); serviceToken != "" {
+			identity, err := cb.verifyServiceToken(serviceToken)
+			if err == nil {
+				c.Set("service_id", identity.serviceID)
+				c.Set("service_name", identity.serviceName)
+			} else if !errors.Is(err, errInvalidServiceToken) {
+				fmt.Printf("⚠️  服务token校验失败: %v\n", err)
+			}
+		}
+		c.Next()
+	}
+}
+
+// requireGatewayAdmin 网关管理写接口鉴权：
+// 1. 经网关令牌校验（TOKEN_VALIDATION_ENABLED）的admin/super_admin用户；
+// 2. 携带X-Service-Token且经Auth Service校验通过的内部服务。
+// 未启用令牌校验时用户身份无法在网关确认，只接受服务token。
+func (cb *CentralBrain) requireGatewayAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID := ""
+		if tid, exists := c.Get("trace_id"); exists {
+			traceID = tid.(string)
+		}
+
+		if role, exists := c.Get("role"); exists {
+			if roleName, ok := role.(string); ok && gatewayAdminRoles[roleName] {
+				c.Next()
+				return
+			}
+		}
+
+		if _, exists := c.Get("service_id"); exists {
+			c.Next()
+			return
+		}
+
+		_, hasUser := c.Get("user_id")
+		switch {
+		case c.GetHeader("X-Service-Token") != "":
+			utils.WriteErrorResponse(c.Writer, http.StatusUnauthorized, "服务token校验失败", traceID)
+		case hasUser:
+			utils.WriteErrorResponse(c.Writer, http.StatusForbidden, "需要管理员权限", traceID)
+		default:
+			utils.WriteErrorResponse(c.Writer, http.StatusUnauthorized, "需要管理员令牌或服务token", traceID)
+		}
+		c.Abort()
+	}
+}
+
+// verifyServiceToken 校验服务token（结果按token摘要短期缓存）
+func (cb *CentralBrain) verifyServiceToken(serviceToken string) (*serviceIdentity, error) {
+	sum := sha256.Sum256([]byte(serviceToken))
+	key := hex.EncodeToString(sum[:])
+
+	if entry, ok := cb.serviceTokens.get(key); ok {
+		if entry.identity == nil {
+			return nil, errInvalidServiceToken
+		}
+		return entry.identity, nil
+	}
+
+	identity, err := cb.validateServiceToken(serviceToken)
+	switch {
+	case err == nil:
+		cb.serviceTokens.set(key, identity, serviceTokenValidTTL)
+	case errors.Is(err, errInvalidServiceToken):
+		cb.serviceTokens.set(key, nil, serviceTokenInvalidTTL)
+	}
+	return identity, err
+}
+
+// validateServiceToken 调用Auth Service校验调用方的服务token
+func (cb *CentralBrain) validateServiceToken(serviceToken string) (*serviceIdentity, error) {
+	payload, err := json.Marshal(map[string]string{"service_token": serviceToken})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/api/v1/auth/service/validate", cb.authServiceURL)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cb.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求Auth Service失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			Valid       bool   `json:"valid"`
+			ServiceID   string `json:"service_id"`
+			ServiceName string `json:"service_name"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("Auth Service返回HTTP %d: %s", resp.StatusCode, result.Message)
+	}
+	if result.Code != 0 || !result.Data.Valid {
+		return nil, fmt.Errorf("%w: %s", errInvalidServiceToken, result.Message)
+	}
+
+	identity := &serviceIdentity{serviceID: result.Data.ServiceID, serviceName: result.Data.ServiceName}
+	if identity.serviceID == "" {
+		identity.serviceID = identity.serviceName
+	}
+	return identity, nil
+}
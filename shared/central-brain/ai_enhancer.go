@@ -21,10 +21,18 @@ type AIEnhancer struct {
 	// 预测缓存
 	predictions map[string]*Prediction
 
-	// 智能缓存
-	smartCache map[string]*CachedData
+	// 网关响应缓存（按用户/角色分区，nil表示未启用）
+	cache *ResponseCache
+
+	// 预测预取（nil表示未启用）
+	prefetcher *Prefetcher
 }
 
+const (
+	maxSessionActions    = 200 // 每个会话保留的最近操作数
+	minPredictionSamples = 2   // 基于历史预测所需的最少跳转样本数
+)
+
 // UserSession 用户会话
 type UserSession struct {
 	UserID      int
@@ -46,42 +54,39 @@ type UserAction struct {
 
 // PathAIStats AI需要的路径统计
 type PathAIStats struct {
-	Path            string
-	TotalAccess     int64
-	AvgDuration     int64
-	LastAccessTime  time.Time
-	AccessFrequency float64 // 访问频率（次/分钟）
-	DataChangeRate  float64 // 数据变化率（0-1）
+	Path             string
+	TotalAccess      int64
+	AvgDuration      int64
+	LastAccessTime   time.Time
+	AccessFrequency  float64     // 访问频率（次/分钟）
+	DataChangeRate   float64     // 数据变化率（0-1）
 	UserDistribution map[int]int // 哪些用户访问了这个路径
 }
 
 // Prediction 预测结果
 type Prediction struct {
-	CurrentPath  string
-	NextPath     string
-	Probability  float64
-	PreloadData  interface{}
-	GeneratedAt  time.Time
-}
-
-// CachedData 智能缓存数据
-type CachedData struct {
-	Data       interface{}
-	CachedAt   time.Time
-	ExpiresAt  time.Time
-	HitCount   int64
+	CurrentPath string
+	NextPath    string
+	Probability float64
+	PreloadData interface{}
+	GeneratedAt time.Time
 }
 
 // NewAIEnhancer 创建AI增强器
-func NewAIEnhancer() *AIEnhancer {
+func NewAIEnhancer(cache *ResponseCache) *AIEnhancer {
 	return &AIEnhancer{
 		userSessions: make(map[int]*UserSession),
 		pathStats:    make(map[string]*PathAIStats),
 		predictions:  make(map[string]*Prediction),
-		smartCache:   make(map[string]*CachedData),
+		cache:        cache,
 	}
 }
 
+// SetPrefetcher 设置预测预取执行器
+func (ai *AIEnhancer) SetPrefetcher(prefetcher *Prefetcher) {
+	ai.prefetcher = prefetcher
+}
+
 // RecordAction 记录用户操作（供AI分析）
 func (ai *AIEnhancer) RecordAction(userID int, actionType, path string, duration int64, success bool) {
 	ai.mu.Lock()
@@ -108,6 +113,9 @@ func (ai *AIEnhancer) RecordAction(userID int, actionType, path string, duration
 		Success:    success,
 	}
 	session.Actions = append(session.Actions, action)
+	if len(session.Actions) > maxSessionActions {
+		session.Actions = session.Actions[len(session.Actions)-maxSessionActions:]
+	}
 	session.LastActive = time.Now()
 	session.CurrentPath = path
 
@@ -150,106 +158,33 @@ func (ai *AIEnhancer) PredictNextAction(userID int, currentPath string) *Predict
 		GeneratedAt: time.Now(),
 	}
 
-	// 规则1: 查看列表 → 很可能编辑
+	// 规则1: 基于历史行为（概率为该路径之后各跳转中最常见跳转的占比）
+	if session, exists := ai.userSessions[userID]; exists && len(session.Actions) > 1 {
+		nextPath, probability := ai.findMostCommonNextPath(session.Actions, currentPath)
+		if nextPath != "" {
+			prediction.NextPath = nextPath
+			prediction.Probability = probability
+			return prediction
+		}
+	}
+
+	// 规则2: 查看列表 → 很可能编辑
 	if containsKeyword(currentPath, []string{"index", "list"}) {
 		prediction.NextPath = replaceLast(currentPath, "index", "save")
 		prediction.Probability = 0.7
 		return prediction
 	}
 
-	// 规则2: 用户管理 → 可能查看角色
+	// 规则3: 用户管理 → 可能查看角色
 	if contains(currentPath, "/admin") {
 		prediction.NextPath = "/roles"
 		prediction.Probability = 0.6
 		return prediction
 	}
 
-	// 规则3: 基于历史行为
-	if session, exists := ai.userSessions[userID]; exists && len(session.Actions) > 1 {
-		// 找到最常见的跳转路径
-		nextPath := ai.findMostCommonNextPath(session.Actions, currentPath)
-		if nextPath != "" {
-			prediction.NextPath = nextPath
-			prediction.Probability = 0.8
-			return prediction
-		}
-	}
-
 	return nil
 }
 
-// ShouldCache AI决定是否应该缓存（简化版）
-func (ai *AIEnhancer) ShouldCache(path string) bool {
-	ai.mu.RLock()
-	defer ai.mu.RUnlock()
-
-	stats, exists := ai.pathStats[path]
-	if !exists {
-		return false
-	}
-
-	// 缓存策略（基于规则，后续可用AI模型）
-	return stats.AccessFrequency > 5 &&    // 访问频率 > 5次/分钟
-		stats.AvgDuration > 10              // 平均耗时 > 10ms
-}
-
-// GetCacheDuration AI决定缓存时长
-func (ai *AIEnhancer) GetCacheDuration(path string) time.Duration {
-	ai.mu.RLock()
-	defer ai.mu.RUnlock()
-
-	stats, exists := ai.pathStats[path]
-	if !exists {
-		return 0
-	}
-
-	// 动态决定缓存时长
-	if stats.AccessFrequency > 20 {
-		return 10 * time.Minute // 高频访问
-	} else if stats.AccessFrequency > 5 {
-		return 5 * time.Minute // 中频访问
-	}
-	return 1 * time.Minute // 低频访问
-}
-
-// GetFromSmartCache 从智能缓存获取
-func (ai *AIEnhancer) GetFromSmartCache(path string) interface{} {
-	ai.mu.RLock()
-	defer ai.mu.RUnlock()
-
-	cached, exists := ai.smartCache[path]
-	if !exists {
-		return nil
-	}
-
-	// 检查是否过期
-	if time.Now().After(cached.ExpiresAt) {
-		return nil
-	}
-
-	// 更新命中次数
-	cached.HitCount++
-	return cached.Data
-}
-
-// PutToSmartCache 放入智能缓存
-func (ai *AIEnhancer) PutToSmartCache(path string, data interface{}) {
-	ai.mu.Lock()
-	defer ai.mu.Unlock()
-
-	duration := ai.GetCacheDuration(path)
-	if duration == 0 {
-		return
-	}
-
-	ai.smartCache[path] = &CachedData{
-		Data:      data,
-		CachedAt:  time.Now(),
-		ExpiresAt: time.Now().Add(duration),
-		HitCount:  0,
-	}
-}
-
 // AnalyzeMatchingEfficiency AI分析前后端匹配效率
 func (ai *AIEnhancer) AnalyzeMatchingEfficiency() map[string]interface{} {
 	ai.mu.RLock()
@@ -290,12 +225,23 @@ func (ai *AIEnhancer) AnalyzeMatchingEfficiency() map[string]interface{} {
 	// 计算总体评分
 	overallScore := ai.calculateOverallScore()
 
+	// 预取收益：预取条目被用户实际使用的比例
+	cacheStats := ai.cache.Stats()
+
 	return map[string]interface{}{
 		"overall_score":   overallScore,
 		"bottlenecks":     bottlenecks,
 		"recommendations": recommendations,
 		"total_sessions":  len(ai.userSessions),
 		"total_paths":     len(ai.pathStats),
+		"response_cache":  cacheStats,
+		"prefetch": map[string]interface{}{
+			"executor": ai.prefetcher.Stats(),
+			"stored":   cacheStats.PrefetchStored,
+			"used":     cacheStats.PrefetchHits,
+			"wasted":   cacheStats.PrefetchWasted,
+			"hit_rate": cacheStats.PrefetchHitRate,
+		},
 	}
 }
 
@@ -333,18 +279,23 @@ func (ai *AIEnhancer) calculateOverallScore() float64 {
 
 // 辅助函数
 func (ai *AIEnhancer) isCached(path string) bool {
-	_, exists := ai.smartCache[path]
-	return exists
+	return ai.cache.HasPath(path)
 }
 
-func (ai *AIEnhancer) findMostCommonNextPath(actions []UserAction, currentPath string) string {
+// findMostCommonNextPath 找到currentPath之后最常见的下一步及其占比；样本不足时返回空
+func (ai *AIEnhancer) findMostCommonNextPath(actions []UserAction, currentPath string) (string, float64) {
 	nextPaths := make(map[string]int)
+	total := 0
 
 	for i := 0; i < len(actions)-1; i++ {
 		if actions[i].Path == currentPath {
 			nextPaths[actions[i+1].Path]++
+			total++
 		}
 	}
+	if total < minPredictionSamples {
+		return "", 0
+	}
 
 	// 找到最常见的下一步
 	maxCount := 0
@@ -356,7 +307,7 @@ func (ai *AIEnhancer) findMostCommonNextPath(actions []UserAction, currentPath s
 		}
 	}
 
-	return mostCommon
+	return mostCommon, float64(maxCount) / float64(total)
 }
 
 func containsKeyword(path string, keywords []string) bool {
@@ -369,7 +320,7 @@ func containsKeyword(path string, keywords []string) bool {
 }
 
 func contains(s, substr string) bool {
-	return len(s) >= len(substr) && 
+	return len(s) >= len(substr) &&
 		(s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || findInString(s, substr)))
 }
 
@@ -395,50 +346,38 @@ func replaceLast(s, old, new string) string {
 }
 
 // Middleware AI增强中间件
+// 响应的缓存与命中由代理层的 ResponseCache 完成（按用户/角色分区），这里只记录访问行为
 func (ai *AIEnhancer) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 		path := c.Request.URL.Path
 		userID := c.GetInt("user_id")
 
-		// 1. 检查智能缓存
-		if cached := ai.GetFromSmartCache(path); cached != nil {
-			c.JSON(200, cached)
-			fmt.Printf("🎯 AI缓存命中: %s (< 1ms)\n", path)
-			return
-		}
-
-		// 2. AI预测和预加载
-		if userID > 0 {
-			go ai.predictAndPreload(userID, path)
+		// 1. AI预测和预取（只对已校验身份的用户预取）
+		if userID > 0 && ai.prefetcher != nil {
+			go ai.predictAndPreload(NewPrefetchOrigin(c), path)
 		}
 
-		// 3. 正常处理请求
+		// 2. 正常处理请求
 		c.Next()
 
-		// 4. 记录操作（供AI分析）
+		// 3. 记录操作（供AI分析）
 		duration := time.Since(startTime).Milliseconds()
 		success := c.Writer.Status() < 400
 		ai.RecordAction(userID, "api_call", path, duration, success)
-
-		// 5. 智能缓存决策
-		if ai.ShouldCache(path) && success {
-			// 从响应中提取数据并缓存
-			// 注意：这是简化版，实际需要更复杂的实现
-			fmt.Printf("🤖 AI建议缓存: %s\n", path)
-		}
 	}
 }
 
 // predictAndPreload AI预测并预加载（异步）
-func (ai *AIEnhancer) predictAndPreload(userID int, currentPath string) {
-	prediction := ai.PredictNextAction(userID, currentPath)
-	if prediction != nil && prediction.Probability > 0.7 {
-		fmt.Printf("🔮 AI预测: 用户 %d 可能访问 %s (概率%.0f%%)\n",
-			userID, prediction.NextPath, prediction.Probability*100)
-
-		// TODO: 实际的预加载逻辑
-		// ai.preloadData(prediction.NextPath)
+func (ai *AIEnhancer) predictAndPreload(origin PrefetchOrigin, currentPath string) {
+	prediction := ai.PredictNextAction(origin.UserID, currentPath)
+	if prediction == nil || prediction.Probability < ai.prefetcher.Threshold() {
+		return
+	}
+
+	if ai.prefetcher.Prefetch(origin, prediction) {
+		fmt.Printf("🔮 AI预取: 用户 %d 可能访问 %s (概率%.0f%%)\n",
+			origin.UserID, prediction.NextPath, prediction.Probability*100)
 	}
 }
 
@@ -446,4 +385,3 @@ func (ai *AIEnhancer) predictAndPreload(userID int, currentPath string) {
 func (ai *AIEnhancer) GetAnalysis() map[string]interface{} {
 	return ai.AnalyzeMatchingEfficiency()
 }
-
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +12,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/szjason72/zervigo/shared/core/service/registry"
+	"github.com/szjason72/zervigo/shared/core/tracing"
 	"github.com/szjason72/zervigo/shared/core/shared"
 
 	"github.com/szjason72/zervigo/shared/central-brain/client"
@@ -34,9 +37,28 @@ type CentralBrain struct {
 	// 中间件组件
 	requestLogger   *middleware.RequestLogger
 	metrics         *middleware.Metrics
+	promMetrics     *middleware.PrometheusMetrics // Prometheus指标（/metrics）
 	rateLimiter     *middleware.RateLimiter
+	policyLimiter   *middleware.PolicyRateLimiter // 多维度策略限流（用户/服务/IP/路由）
+	breakerMu       sync.RWMutex                  // 保护circuitBreakers（动态路由会按需创建熔断器）
 	circuitBreakers map[string]*middleware.CircuitBreaker
 
+	// 动态路由（服务注册中心 / Consul / Router Service路由表）
+	routeManager *RouteManager
+
+	// 分布式跟踪（W3C traceparent）
+	tracer *tracing.Tracer
+
+	// 用户令牌校验（TOKEN_VALIDATION_ENABLED，未启用时为nil）
+	tokenValidator *UserTokenValidator
+
+	// 调用方服务token的校验结果缓存
+	serviceTokens *serviceTokenCache
+
+	// 响应缓存（RESPONSE_CACHE_ENABLED，未启用时为nil）与访问行为分析
+	responseCache *ResponseCache
+	aiEnhancer    *AIEnhancer
+
 	// 服务token相关（带互斥锁保护）
 	tokenMu                sync.RWMutex // 保护serviceToken和serviceTokenExp的并发访问
 	serviceToken           string       // 缓存的服务token
@@ -49,6 +71,9 @@ type CentralBrain struct {
 	modelHandler  *VueCMFModelHandler // VueCMF 模型配置处理器
 }
 
+// contextKeyProxyError 标记本次代理发生了传输层错误（区别于上游返回的5xx）
+const contextKeyProxyError = "proxy_error"
+
 // ServiceProxy 服务代理配置
 type ServiceProxy struct {
 	ServiceName       string
@@ -57,6 +82,20 @@ type ServiceProxy struct {
 	TargetPrefix      string
 	CircuitBreakerKey string
 	Rewrite           map[string]string
+	Method            string // 限定HTTP方法（空表示任意方法）
+	Source            string // 路由来源：static、registry、router
+
+	// 流式代理配置（大文件上传、SSE、分块响应、WebSocket）
+	Streaming     bool          // 是否以流式方式双向转发请求/响应体（不做整体缓冲）
+	FlushInterval time.Duration // 流式响应刷新间隔（0表示仅在SSE/未知长度时立即刷新）
+
+	// 上游调用策略（超时、重试、对冲），nil时使用默认策略
+	Policy *UpstreamPolicy
+
+	// 熔断配置：nil时使用默认配置；HotRoutes中的热点路径前缀使用独立熔断器，
+	// 避免单个热点接口故障拖垮整个服务的熔断状态
+	Breaker   *middleware.CircuitBreakerConfig
+	HotRoutes []string
 }
 
 // NewCentralBrain 创建中央大脑服务
@@ -137,17 +176,21 @@ func NewCentralBrain(config *shared.Config) *CentralBrain {
 	requestLogger := middleware.NewRequestLogger(true) // 启用日志
 	metrics := middleware.NewMetrics()
 	rateLimiter := middleware.NewRateLimiter(100, 200, true) // 100 RPS, 200 burst
+	policyLimiter := newPolicyRateLimiter(config)
 
-	// 为每个服务创建熔断器
-	circuitBreakers := make(map[string]*middleware.CircuitBreaker)
-	serviceNames := []string{"auth", "ai", "blockchain", "user", "job", "resume", "company"}
-	for _, serviceName := range serviceNames {
-		circuitBreakers[serviceName] = middleware.NewCircuitBreaker(
-			5,              // 失败阈值：5次
-			60*time.Second, // 重置超时：60秒
-			3,              // 半开状态成功阈值：3次
-		)
+	// 分布式跟踪（导出方式见tracing.ConfigFromEnv）
+	tracer, err := tracing.NewTracerFromEnv("central-brain")
+	if err != nil {
+		fmt.Printf("⚠️  跟踪器初始化失败（仅传播跟踪上下文）: %v\n", err)
+		tracer = tracing.NewTracer("central-brain", 1, nil)
 	}
+	tracing.SetDefaultTracer(tracer)
+
+	// 熔断器按服务按需创建（见getCircuitBreaker）
+	circuitBreakers := make(map[string]*middleware.CircuitBreaker)
+
+	// 网关响应缓存（按用户/角色分区，遵循上游Cache-Control、ETag与Vary）
+	responseCache := NewResponseCache(config)
 
 	cb := &CentralBrain{
 		config:           config,
@@ -160,18 +203,64 @@ func NewCentralBrain(config *shared.Config) *CentralBrain {
 		requestLogger:    requestLogger,
 		metrics:          metrics,
 		rateLimiter:      rateLimiter,
+		policyLimiter:    policyLimiter,
 		circuitBreakers:  circuitBreakers,
+		tracer:           tracer,
+		tokenValidator:   newUserTokenValidator(config, authServiceURL),
+		serviceTokens:    newServiceTokenCache(),
+		responseCache:    responseCache,
+		aiEnhancer:       NewAIEnhancer(responseCache),
 		vuecmfHandler:    vuecmfHandler,
 		crudHandler:      crudHandler,
 		modelHandler:     modelHandler,
 	}
 
+	// Prometheus指标（熔断器状态在抓取时读取）
+	cb.promMetrics = middleware.NewPrometheusMetrics(cb.circuitBreakerStates)
+
+	// 初始化动态路由管理器（静态配置作为兜底路由）
+	cb.routeManager = NewRouteManager(config, routerClient, cb.staticServiceProxies())
+
+	// 预测预取（依赖路由表与响应缓存，PREFETCH_ENABLED）
+	cb.aiEnhancer.SetPrefetcher(NewPrefetcher(cb, config))
+
 	// 启动时获取服务token（带重试机制）
 	go cb.initializeServiceTokenWithRetry()
 
 	return cb
 }
 
+// newPolicyRateLimiter 根据配置创建策略限流器（Redis可用时使用分布式令牌桶）
+func newPolicyRateLimiter(config *shared.Config) *middleware.PolicyRateLimiter {
+	policies := middleware.DefaultRateLimitPolicies()
+	if config.RateLimit.PolicyFile != "" {
+		loaded, err := middleware.ReadRateLimitPolicies(config.RateLimit.PolicyFile)
+		if err != nil {
+			fmt.Printf("⚠️  加载限流策略文件失败（使用默认策略）: %v\n", err)
+		} else {
+			policies = loaded
+		}
+	}
+
+	var store middleware.BucketStore
+	if config.RateLimit.RedisEnabled && config.Database.Redis.Host != "" {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", config.Database.Redis.Host, config.Database.Redis.Port),
+			Password: config.Database.Redis.Password,
+			DB:       config.Database.Redis.DB,
+		})
+		store = middleware.NewRedisBucketStore(redisClient, "")
+		fmt.Printf("✅ 策略限流使用Redis分布式令牌桶\n")
+	}
+
+	limiter, err := middleware.NewPolicyRateLimiter(policies, store, config.RateLimit.Enabled)
+	if err != nil {
+		fmt.Printf("⚠️  限流策略无效（使用默认策略）: %v\n", err)
+		limiter, _ = middleware.NewPolicyRateLimiter(middleware.DefaultRateLimitPolicies(), store, config.RateLimit.Enabled)
+	}
+	return limiter
+}
+
 // Start 启动中央大脑服务
 func (cb *CentralBrain) Start() error {
 	// 配置CORS（必须在最前面）
@@ -183,8 +272,9 @@ func (cb *CentralBrain) Start() error {
 		// 修复：当 withCredentials=true 时，不能使用 *，必须指定具体域名
 		c.Header("Access-Control-Allow-Origin", origin)
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, accessToken, token")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, accessToken, token, traceparent, tracestate")
 		c.Header("Access-Control-Allow-Credentials", "true")
+		c.Header("Access-Control-Expose-Headers", "X-Trace-ID, traceparent, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -194,13 +284,23 @@ func (cb *CentralBrain) Start() error {
 		c.Next()
 	})
 
+	// 只采信可信代理转发的客户端IP（限流、日志按ClientIP识别调用方）
+	if err := cb.router.SetTrustedProxies(cb.config.TrustedProxies); err != nil {
+		return fmt.Errorf("可信代理配置无效: %v", err)
+	}
+
 	// 注册基础设施中间件（按顺序）
+	cb.router.Use(tracing.GinMiddleware(cb.tracer)) // 分布式跟踪（生成trace_id供后续中间件使用）
 	cb.router.Use(cb.requestLogger.Middleware()) // 请求日志（第一层）
 	cb.router.Use(cb.metrics.Middleware())       // 性能指标（第二层）
+	cb.router.Use(cb.promMetrics.Middleware())   // Prometheus指标
+	if cb.tokenValidator != nil {
+		cb.router.Use(cb.tokenValidator.Middleware(cb.extractUserToken)) // 用户令牌本地校验（JWKS）
+	}
+	cb.router.Use(cb.serviceIdentityMiddleware()) // 服务token校验（限流与管理接口鉴权使用已校验的服务身份）
 	cb.router.Use(cb.rateLimiter.Middleware())   // 限流（第三层）
-
-	// 注册服务代理（带熔断器保护）
-	cb.registerServiceProxies()
+	cb.router.Use(cb.policyLimiter.Middleware()) // 策略限流（第四层）
+	cb.router.Use(cb.aiEnhancer.Middleware())    // 访问行为记录（供效率分析）
 
 	// 注册管理API（健康检查、指标查询）
 	cb.registerManagementRoutes()
@@ -208,19 +308,43 @@ func (cb *CentralBrain) Start() error {
 	// 注册 VueCMF API 映射端点
 	cb.registerVueCMFRoutes()
 
+	// 注册服务代理（带熔断器保护，路由表可热更新）
+	cb.registerServiceProxies()
+
 	return cb.router.Run(fmt.Sprintf(":%d", cb.config.CentralBrainPort))
 }
 
 // registerManagementRoutes 注册管理API路由
 func (cb *CentralBrain) registerManagementRoutes() {
+	// 管理写接口仅允许管理员或内部服务调用
+	adminAuth := cb.requireGatewayAdmin()
+
 	// 健康检查
 	cb.router.GET("/health", cb.healthCheck)
 
 	// 指标查询
 	cb.router.GET("/api/v1/metrics", cb.getMetrics)
+	cb.router.GET("/metrics", cb.promMetrics.Handler()) // Prometheus文本格式
 
 	// 熔断器状态
 	cb.router.GET("/api/v1/circuit-breakers", cb.getCircuitBreakers)
+	cb.router.PUT("/api/v1/circuit-breakers", adminAuth, cb.updateCircuitBreaker)
+
+	// 限流策略管理（运行时热加载）
+	cb.router.GET("/api/v1/rate-limits", cb.getRateLimitPolicies)
+	cb.router.PUT("/api/v1/rate-limits", adminAuth, cb.updateRateLimitPolicies)
+	cb.router.POST("/api/v1/rate-limits/reload", adminAuth, cb.reloadRateLimitPolicies)
+
+	// 网关路由表与服务实例管理
+	cb.router.GET("/api/v1/gateway/routes", cb.getGatewayRoutes)
+	cb.router.POST("/api/v1/gateway/routes/refresh", adminAuth, cb.refreshGatewayRoutes)
+	cb.router.POST("/api/v1/gateway/instances", adminAuth, cb.registerGatewayInstance)
+	cb.router.DELETE("/api/v1/gateway/instances/:id", adminAuth, cb.deregisterGatewayInstance)
+
+	// 响应缓存统计与按标签失效（服务数据变更时调用），访问效率分析
+	cb.router.GET("/api/v1/gateway/cache", cb.getResponseCacheStats)
+	cb.router.POST("/api/v1/gateway/cache/invalidate", adminAuth, cb.invalidateResponseCache)
+	cb.router.GET("/api/v1/gateway/analysis", cb.getGatewayAnalysis)
 
 	// Router和Permission服务通过代理提供API，不需要单独注册管理路由
 }
@@ -281,10 +405,17 @@ func (cb *CentralBrain) registerVueCMFRoutes() {
 	cb.router.StaticFile("/test-vuecmf-flow.html", "/Users/szjason72/gozervi/zervigo.demo/test-vuecmf-flow.html")
 }
 
-// registerServiceProxies 注册服务代理
-func (cb *CentralBrain) registerServiceProxies() {
+// staticServiceProxies 基于配置端口的静态服务代理（动态路由不可用时的兜底）
+func (cb *CentralBrain) staticServiceProxies() []ServiceProxy {
 	serviceHost := cb.config.ServiceDiscovery.ServiceHost
 
+	jobPolicy := &UpstreamPolicy{
+		Timeout:    10 * time.Second,
+		Retry:      DefaultUpstreamPolicy().Retry,
+		HedgeDelay: 300 * time.Millisecond, // 职位查询为热点读，多实例时对冲长尾请求
+		MaxHedges:  1,
+	}
+
 	services := []ServiceProxy{
 		{
 			ServiceName:       "auth-service",
@@ -316,6 +447,10 @@ func (cb *CentralBrain) registerServiceProxies() {
 			CircuitBreakerKey: "ai",
 			BaseURL:           fmt.Sprintf("http://%s:%d", serviceHost, cb.config.AIServicePort),
 			PathPrefix:        "/api/v1/ai",
+			Streaming:         true,
+			Policy: &UpstreamPolicy{
+				Timeout: 120 * time.Second, // AI推理耗时较长
+			},
 		},
 		{
 			ServiceName:       "blockchain-service",
@@ -344,18 +479,30 @@ func (cb *CentralBrain) registerServiceProxies() {
 			CircuitBreakerKey: "job",
 			BaseURL:           fmt.Sprintf("http://%s:%d", serviceHost, cb.config.JobServicePort),
 			PathPrefix:        "/api/v1/job",
+			Policy:            jobPolicy,
+		},
+		{
+			// 职位服务实际提供的接口前缀；搜索为热点接口，使用独立熔断器
+			ServiceName:       "job-service",
+			CircuitBreakerKey: "job",
+			BaseURL:           fmt.Sprintf("http://%s:%d", serviceHost, cb.config.JobServicePort),
+			PathPrefix:        "/api/v1/jobs",
+			HotRoutes:         []string{"/api/v1/jobs/search"},
+			Policy:            jobPolicy,
 		},
 		{
 			ServiceName:       "resume-service",
 			CircuitBreakerKey: "resume",
 			BaseURL:           fmt.Sprintf("http://%s:%d", serviceHost, cb.config.ResumeServicePort),
 			PathPrefix:        "/api/v1/resume",
+			Streaming:         true,
 		},
 		{
 			ServiceName:       "resume-service",
 			CircuitBreakerKey: "resume",
 			BaseURL:           fmt.Sprintf("http://%s:%d", serviceHost, cb.config.ResumeServicePort),
 			PathPrefix:        "/api/resume",
+			Streaming:         true,
 			TargetPrefix:      "/api/v1/resume",
 		},
 		{
@@ -363,93 +510,186 @@ func (cb *CentralBrain) registerServiceProxies() {
 			CircuitBreakerKey: "company",
 			BaseURL:           fmt.Sprintf("http://%s:%d", serviceHost, cb.config.CompanyServicePort),
 			PathPrefix:        "/api/v1/company",
+			Streaming:         true,
 		},
 	}
 
-	for _, service := range services {
-		cb.registerServiceProxy(service)
+	for i := range services {
+		services[i].Source = "static"
 	}
+	return services
 }
 
-// registerServiceProxy 注册单个服务代理
-func (cb *CentralBrain) registerServiceProxy(service ServiceProxy) {
-	proxyGroup := cb.router.Group(service.PathPrefix)
+// registerServiceProxies 注册服务代理
+// 代理路由不直接注册到gin路由树，而是由NoRoute分发器按当前路由表匹配，
+// 从而在不重启gin引擎的情况下热替换路由。
+func (cb *CentralBrain) registerServiceProxies() {
+	cb.routeManager.Start(context.Background())
+	cb.router.NoRoute(cb.dispatchProxy)
 
-	circuitKey := service.CircuitBreakerKey
-	if circuitKey == "" {
-		circuitKey = service.ServiceName
+	for _, service := range cb.routeManager.Table().Proxies {
+		targetPrefix := service.TargetPrefix
+		if targetPrefix == "" {
+			targetPrefix = service.PathPrefix
+		}
+		fmt.Printf("✅ 注册服务代理: %s -> %s%s\n", service.PathPrefix, service.BaseURL, targetPrefix)
 	}
+}
 
-	if circuitBreaker, exists := cb.circuitBreakers[circuitKey]; exists {
-		proxyGroup.Use(circuitBreaker.Middleware(service.ServiceName))
+// dispatchProxy 按当前路由表分发代理请求
+func (cb *CentralBrain) dispatchProxy(c *gin.Context) {
+	service, ok := cb.routeManager.Match(c.Request.Method, c.Request.URL.Path)
+	if !ok {
+		traceID := ""
+		if tid, exists := c.Get("trace_id"); exists {
+			traceID = tid.(string)
+		}
+		utils.WriteErrorResponse(c.Writer, http.StatusNotFound,
+			fmt.Sprintf("未找到匹配的服务路由: %s", c.Request.URL.Path), traceID)
+		return
 	}
 
-	proxyGroup.Any("/*path", func(sp ServiceProxy) gin.HandlerFunc {
-		return func(c *gin.Context) {
-			cb.proxyRequest(c, sp)
+	// 指标标签：上游服务与路由模板
+	c.Set(middleware.ContextKeyUpstreamService, service.ServiceName)
+	c.Set(middleware.ContextKeyRouteTemplate, strings.TrimSuffix(service.PathPrefix, "/")+"/*")
+
+	// 代理跳转Span；每次物理上游请求（含重试/对冲）由跟踪Transport创建子Span并注入traceparent
+	ctx, span := cb.tracer.Start(c.Request.Context(), "proxy "+service.ServiceName, tracing.SpanKindInternal)
+	defer span.End()
+	c.Request = c.Request.WithContext(ctx)
+
+	breakerKey := breakerKeyFor(service, c.Request.URL.Path)
+	span.SetAttribute("gateway.route", service.PathPrefix)
+	span.SetAttribute("gateway.route_source", service.Source)
+	span.SetAttribute("gateway.circuit_breaker", breakerKey)
+
+	// 响应缓存先于熔断器：命中新鲜条目直接返回，不占用试探名额，也不计入上游调用结果
+	var lookup *cacheLookup
+	if !service.Streaming && !isUpgradeRequest(c.Request) {
+		var served bool
+		lookup, served = cb.responseCache.Begin(c, service, cb.extractUserToken(c.Request))
+		if served {
+			span.SetAttribute("gateway.cache", "HIT")
+			return
 		}
-	}(service))
+	}
 
-	targetPrefix := service.TargetPrefix
-	if targetPrefix == "" {
-		targetPrefix = service.PathPrefix
+	circuitBreaker := cb.getCircuitBreaker(breakerKey, service.Breaker)
+	permit, allowed := circuitBreaker.Acquire()
+	if !allowed {
+		cb.promMetrics.RecordUpstreamError(service.ServiceName, "circuit_open")
+		span.SetStatus(tracing.StatusError, "circuit breaker open")
+		circuitBreaker.Reject(c)
+		return
 	}
 
-	fmt.Printf("✅ 注册服务代理: %s -> %s%s\n", service.PathPrefix, service.BaseURL, targetPrefix)
-}
+	// 调用结果在defer中记录：代理过程中panic时按5xx计入，避免半开试探名额泄漏使熔断器无法恢复
+	defer func() {
+		if r := recover(); r != nil {
+			if r == http.ErrAbortHandler && !c.GetBool(contextKeyProxyError) {
+				// 客户端中断连接不是上游故障，按已写出的状态码记录
+				permit.Done(c.Writer.Status())
+			} else {
+				permit.Done(http.StatusInternalServerError)
+			}
+			panic(r)
+		}
+		permit.Done(c.Writer.Status())
+	}()
 
-// proxyRequest 代理请求
-func (cb *CentralBrain) proxyRequest(c *gin.Context, service ServiceProxy) {
-	originalPath := c.Request.URL.Path
-	relativePath := strings.TrimPrefix(originalPath, service.PathPrefix)
-	if relativePath == "" {
-		relativePath = "/"
-	} else if !strings.HasPrefix(relativePath, "/") {
-		relativePath = "/" + relativePath
+	// 通过负载均衡选择实例
+	baseURL, release := cb.routeManager.ResolveBaseURL(service)
+	defer release()
+	service.BaseURL = baseURL
+	span.SetAttribute("gateway.upstream", baseURL)
+
+	cb.proxyRequest(c, service, lookup)
+
+	statusCode := c.Writer.Status()
+	span.SetAttribute("http.status_code", statusCode)
+	if c.GetBool(contextKeyProxyError) {
+		cb.promMetrics.RecordUpstreamError(service.ServiceName, "transport")
+		span.SetStatus(tracing.StatusError, "upstream transport error")
+	} else if statusCode >= 500 {
+		cb.promMetrics.RecordUpstreamError(service.ServiceName, "status_5xx")
+		span.SetStatus(tracing.StatusError, fmt.Sprintf("HTTP %d", statusCode))
 	}
+}
 
-	targetPath := ""
-	if service.Rewrite != nil {
-		if rewrite, ok := service.Rewrite[relativePath]; ok {
-			targetPath = rewrite
+// breakerKeyFor 计算请求对应的熔断器键：热点路径使用 "服务键:路径前缀" 的独立熔断器
+func breakerKeyFor(service ServiceProxy, path string) string {
+	key := service.CircuitBreakerKey
+	if key == "" {
+		key = service.ServiceName
+	}
+	for _, route := range service.HotRoutes {
+		if path == route || strings.HasPrefix(path, strings.TrimSuffix(route, "/")+"/") {
+			return key + ":" + route
 		}
 	}
+	return key
+}
 
-	if targetPath == "" {
-		targetPrefix := service.TargetPrefix
-		if targetPrefix == "" {
-			targetPrefix = service.PathPrefix
-		}
+// circuitBreakerStates 获取所有熔断器的当前状态
+func (cb *CentralBrain) circuitBreakerStates() map[string]middleware.CircuitBreakerState {
+	cb.breakerMu.RLock()
+	defer cb.breakerMu.RUnlock()
 
-		if relativePath == "/" {
-			targetPath = targetPrefix
-		} else {
-			targetPath = path.Join(targetPrefix, relativePath)
-		}
+	states := make(map[string]middleware.CircuitBreakerState, len(cb.circuitBreakers))
+	for name, breaker := range cb.circuitBreakers {
+		states[name] = breaker.GetState()
 	}
+	return states
+}
 
-	baseURL := strings.TrimSuffix(service.BaseURL, "/")
-	targetURL := baseURL + targetPath
-	if c.Request.URL.RawQuery != "" {
-		targetURL += "?" + c.Request.URL.RawQuery
+// getCircuitBreaker 获取熔断器（不存在则按配置创建，config为nil时使用默认配置）
+func (cb *CentralBrain) getCircuitBreaker(key string, config *middleware.CircuitBreakerConfig) *middleware.CircuitBreaker {
+	cb.breakerMu.RLock()
+	breaker, exists := cb.circuitBreakers[key]
+	cb.breakerMu.RUnlock()
+	if exists {
+		return breaker
 	}
 
-	fmt.Printf("🔄 代理请求: %s -> %s\n", originalPath, targetURL)
+	cb.breakerMu.Lock()
+	defer cb.breakerMu.Unlock()
 
-	// 3. 读取请求体
-	var body []byte
-	if c.Request.Body != nil {
-		body, _ = io.ReadAll(c.Request.Body)
+	// 双重检查
+	if breaker, exists = cb.circuitBreakers[key]; exists {
+		return breaker
+	}
+	breakerConfig := middleware.DefaultCircuitBreakerConfig()
+	if config != nil {
+		breakerConfig = *config
 	}
+	breaker = middleware.NewCircuitBreaker(key, breakerConfig)
+	breaker.OnStateChange(cb.onBreakerStateChange)
+	cb.circuitBreakers[key] = breaker
+	return breaker
+}
 
-	// 4. 创建HTTP请求
-	req, err := http.NewRequestWithContext(c.Request.Context(),
-		c.Request.Method, targetURL, bytes.NewReader(body))
-	if err != nil {
-		cb.handleError(c, fmt.Errorf("创建请求失败: %v", err))
+// onBreakerStateChange 熔断器状态转换事件：写日志并计入指标
+func (cb *CentralBrain) onBreakerStateChange(event middleware.StateTransitionEvent) {
+	fmt.Printf("⚡ 熔断器 %s: %s -> %s（%s，窗口调用%d次，失败%d次，慢调用%d次）\n",
+		event.Name, event.From, event.To, event.Reason,
+		event.Stats.TotalCalls, event.Stats.FailedCalls, event.Stats.SlowCalls)
+	cb.promMetrics.RecordBreakerTransition(event)
+}
+
+// proxyRequest 代理请求（lookup为dispatchProxy中未命中的缓存查找结果，不可缓存时为nil）
+func (cb *CentralBrain) proxyRequest(c *gin.Context, service ServiceProxy, lookup *cacheLookup) {
+	// WebSocket升级请求无法缓冲，始终走流式代理（流式请求体不可重放，不做重试/对冲）
+	if service.Streaming || isUpgradeRequest(c.Request) {
+		cb.streamProxyRequest(c, service, cb.buildTargetURL(c.Request, service))
 		return
 	}
 
+	// 3. 读取请求体（缓冲后可在重试/对冲时重放）
+	var body []byte
+	if c.Request.Body != nil {
+		body, _ = io.ReadAll(c.Request.Body)
+	}
+
 	// 调试：记录Authorization头的透传情况
 	incomingAuth := c.Request.Header.Get("Authorization")
 	fmt.Printf("DEBUG Gateway: incoming Authorization: %s\n", func() string {
@@ -462,27 +702,64 @@ func (cb *CentralBrain) proxyRequest(c *gin.Context, service ServiceProxy) {
 		return incomingAuth
 	}())
 
-	// 5. 复制请求头（保留用户token）
+	// 4. 复制请求头（保留用户token）
+	header := make(http.Header)
 	for key, values := range c.Request.Header {
 		// 跳过某些内部头
 		if strings.EqualFold(key, "X-Service-Token") || strings.EqualFold(key, "X-Service-ID") {
 			continue
 		}
 		for _, value := range values {
-			req.Header.Add(key, value)
+			header.Add(key, value)
 		}
 	}
 
-	// 5.1 验证用户token（如果存在）- 使用jobfirst-2024密钥
+	// 4.1 注入用户token与服务token
+	cb.injectGatewayHeaders(c, header)
+
+	// 4.2 响应缓存：过期条目以ETag向上游重新验证
+	if lookup != nil {
+		lookup.PrepareUpstream(header)
+	}
+
+	// 5. 按上游策略发送请求（超时、重试、对冲）
+	result := cb.doUpstream(c, service, body, header)
+	if result.err != nil {
+		cb.handleError(c, fmt.Errorf("请求失败: %v", result.err))
+		return
+	}
+
+	// 5.1 写入缓存；写请求成功后失效相关缓存
+	if lookup != nil && lookup.Complete(c, result) {
+		return
+	}
+	cb.responseCache.InvalidateAfterWrite(c, result)
+
+	// 6. 复制响应头（过滤冲突头）
+	for key, values := range result.header {
+		if !cb.isFilteredHeader(key) {
+			for _, value := range values {
+				c.Header(key, value)
+			}
+		}
+	}
+
+	// 7. 返回响应
+	c.Data(result.statusCode, result.header.Get("Content-Type"), result.body)
+}
+
+// injectGatewayHeaders 注入网关统一的认证头（用户token透传 + 服务token）
+func (cb *CentralBrain) injectGatewayHeaders(c *gin.Context, header http.Header) {
+	// 验证用户token（如果存在）- 使用jobfirst-2024密钥
 	userToken := cb.extractUserToken(c.Request)
 	if userToken != "" {
 		// 这里可以验证用户token，但为了性能，我们直接转发给目标服务验证
 		// 目标服务会验证用户token（jobfirst-2024）
-		req.Header.Set("Authorization", "Bearer "+userToken)
+		header.Set("Authorization", "Bearer "+userToken)
 	}
 
 	// 调试：记录下游请求Authorization
-	outgoingAuth := req.Header.Get("Authorization")
+	outgoingAuth := header.Get("Authorization")
 	fmt.Printf("DEBUG Gateway: outgoing Authorization: %s\n", func() string {
 		if outgoingAuth == "" {
 			return "<empty>"
@@ -493,41 +770,49 @@ func (cb *CentralBrain) proxyRequest(c *gin.Context, service ServiceProxy) {
 		return outgoingAuth
 	}())
 
-	// 5.2 添加服务token（zervigo-2025）- 用于服务间认证
+	// 添加服务token（zervigo-2025）- 用于服务间认证
 	serviceToken := cb.getServiceToken()
 	if serviceToken != "" {
-		req.Header.Set("X-Service-Token", serviceToken)
-		req.Header.Set("X-Service-ID", "central-brain")
-		req.Header.Set("X-Service-Name", "Central Brain")
+		header.Set("X-Service-Token", serviceToken)
+		header.Set("X-Service-ID", "central-brain")
+		header.Set("X-Service-Name", "Central Brain")
 	}
+}
 
-	// 6. 发送请求（使用连接池的客户端）
-	client := cb.clientPool.GetClient(service.ServiceName)
-	resp, err := client.Do(req)
-	if err != nil {
-		cb.handleError(c, fmt.Errorf("请求失败: %v", err))
-		return
+// buildTargetURL 根据代理配置计算上游目标URL
+func (cb *CentralBrain) buildTargetURL(r *http.Request, service ServiceProxy) string {
+	relativePath := strings.TrimPrefix(r.URL.Path, service.PathPrefix)
+	if relativePath == "" {
+		relativePath = "/"
+	} else if !strings.HasPrefix(relativePath, "/") {
+		relativePath = "/" + relativePath
 	}
-	defer resp.Body.Close()
 
-	// 7. 读取响应
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		cb.handleError(c, fmt.Errorf("读取响应失败: %v", err))
-		return
+	targetPath := ""
+	if service.Rewrite != nil {
+		if rewrite, ok := service.Rewrite[relativePath]; ok {
+			targetPath = rewrite
+		}
 	}
 
-	// 8. 复制响应头（过滤冲突头）
-	for key, values := range resp.Header {
-		if !cb.isFilteredHeader(key) {
-			for _, value := range values {
-				c.Header(key, value)
-			}
+	if targetPath == "" {
+		targetPrefix := service.TargetPrefix
+		if targetPrefix == "" {
+			targetPrefix = service.PathPrefix
+		}
+
+		if relativePath == "/" {
+			targetPath = targetPrefix
+		} else {
+			targetPath = path.Join(targetPrefix, relativePath)
 		}
 	}
 
-	// 9. 返回响应
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	targetURL := strings.TrimSuffix(service.BaseURL, "/") + targetPath
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+	return targetURL
 }
 
 // isFilteredHeader 检查是否为需要过滤的响应头
@@ -544,12 +829,13 @@ func (cb *CentralBrain) isFilteredHeader(key string) bool {
 			return true
 		}
 	}
-	return false
+	return isInternalCacheHeader(key)
 }
 
 // handleError 处理错误
 func (cb *CentralBrain) handleError(c *gin.Context, err error) {
 	fmt.Printf("❌ 代理错误: %v\n", err)
+	c.Set(contextKeyProxyError, true)
 
 	// 获取追踪ID
 	traceID := ""
@@ -596,13 +882,237 @@ func (cb *CentralBrain) getCircuitBreakers(c *gin.Context) {
 	}
 
 	breakerStats := make(map[string]interface{})
+	cb.breakerMu.RLock()
 	for serviceName, breaker := range cb.circuitBreakers {
 		breakerStats[serviceName] = breaker.GetStats()
 	}
+	cb.breakerMu.RUnlock()
 
 	utils.WriteSuccessResponse(c.Writer, "熔断器状态获取成功", breakerStats, traceID)
 }
 
+// updateCircuitBreaker 管理员操作熔断器：force_open、force_close、reset
+func (cb *CentralBrain) updateCircuitBreaker(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	var req struct {
+		Name   string `json:"name" binding:"required"`
+		Action string `json:"action" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest, fmt.Sprintf("请求参数错误: %v", err), traceID)
+		return
+	}
+
+	cb.breakerMu.RLock()
+	breaker, exists := cb.circuitBreakers[req.Name]
+	cb.breakerMu.RUnlock()
+	if !exists {
+		utils.WriteErrorResponse(c.Writer, http.StatusNotFound, fmt.Sprintf("熔断器不存在: %s", req.Name), traceID)
+		return
+	}
+
+	switch req.Action {
+	case "force_open":
+		breaker.ForceOpen()
+	case "force_close":
+		breaker.ForceClose()
+	case "reset":
+		breaker.Reset()
+	default:
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			fmt.Sprintf("不支持的操作: %s（可选 force_open、force_close、reset）", req.Action), traceID)
+		return
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "熔断器状态已更新", breaker.GetStats(), traceID)
+}
+
+// getRateLimitPolicies 获取当前限流策略
+func (cb *CentralBrain) getRateLimitPolicies(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "限流策略获取成功", cb.policyLimiter.GetPolicies(), traceID)
+}
+
+// updateRateLimitPolicies 替换限流策略
+func (cb *CentralBrain) updateRateLimitPolicies(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	var policies []middleware.RateLimitPolicy
+	if err := c.ShouldBindJSON(&policies); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			fmt.Sprintf("请求参数错误: %v", err), traceID)
+		return
+	}
+
+	if err := cb.policyLimiter.SetPolicies(policies); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			fmt.Sprintf("限流策略无效: %v", err), traceID)
+		return
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "限流策略更新成功", policies, traceID)
+}
+
+// reloadRateLimitPolicies 从策略文件重新加载限流策略
+func (cb *CentralBrain) reloadRateLimitPolicies(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	policyFile := cb.config.RateLimit.PolicyFile
+	if policyFile == "" {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			"未配置限流策略文件（RATE_LIMIT_POLICY_FILE）", traceID)
+		return
+	}
+
+	if err := cb.policyLimiter.LoadPoliciesFromFile(policyFile); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusInternalServerError,
+			fmt.Sprintf("重新加载限流策略失败: %v", err), traceID)
+		return
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "限流策略重新加载成功", cb.policyLimiter.GetPolicies(), traceID)
+}
+
+// getGatewayRoutes 获取当前网关路由表
+func (cb *CentralBrain) getGatewayRoutes(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "路由表获取成功", cb.routeManager.GetStats(), traceID)
+}
+
+// refreshGatewayRoutes 立即刷新网关路由表
+func (cb *CentralBrain) refreshGatewayRoutes(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	table := cb.routeManager.Refresh()
+	utils.WriteSuccessResponse(c.Writer, "路由表刷新成功", gin.H{
+		"version":     table.Version,
+		"route_count": len(table.Proxies),
+	}, traceID)
+}
+
+// registerGatewayInstance 向网关本地注册中心注册服务实例
+func (cb *CentralBrain) registerGatewayInstance(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	var instance registry.ServiceInfo
+	if err := c.ShouldBindJSON(&instance); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			fmt.Sprintf("请求参数错误: %v", err), traceID)
+		return
+	}
+
+	if err := cb.routeManager.RegisterInstance(&instance); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			fmt.Sprintf("注册服务实例失败: %v", err), traceID)
+		return
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "服务实例注册成功", instance, traceID)
+}
+
+// deregisterGatewayInstance 从网关本地注册中心注销服务实例
+func (cb *CentralBrain) deregisterGatewayInstance(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	if err := cb.routeManager.DeregisterInstance(c.Param("id")); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusNotFound,
+			fmt.Sprintf("注销服务实例失败: %v", err), traceID)
+		return
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "服务实例注销成功", nil, traceID)
+}
+
+// CacheInvalidationRequest 缓存失效请求（服务数据变更事件）
+type CacheInvalidationRequest struct {
+	Service string   `json:"service"` // 仅指定服务时失效该服务的全部缓存
+	Tags    []string `json:"tags"`
+	Paths   []string `json:"paths"`
+}
+
+// getResponseCacheStats 获取响应缓存统计
+func (cb *CentralBrain) getResponseCacheStats(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "响应缓存统计获取成功", gin.H{
+		"enabled": cb.responseCache != nil,
+		"stats":   cb.responseCache.Stats(),
+	}, traceID)
+}
+
+// invalidateResponseCache 按标签、路径或服务失效响应缓存
+func (cb *CentralBrain) invalidateResponseCache(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	var req CacheInvalidationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest,
+			fmt.Sprintf("请求参数错误: %v", err), traceID)
+		return
+	}
+
+	tags := append([]string{}, req.Tags...)
+	for _, p := range req.Paths {
+		tags = append(tags, "path:"+path.Clean(p))
+	}
+	if len(tags) == 0 && req.Service != "" {
+		tags = append(tags, "service:"+req.Service)
+	}
+	if len(tags) == 0 {
+		utils.WriteErrorResponse(c.Writer, http.StatusBadRequest, "tags、paths和service不能同时为空", traceID)
+		return
+	}
+
+	removed := cb.responseCache.Invalidate(c.Request.Context(), tags...)
+	utils.WriteSuccessResponse(c.Writer, "响应缓存已失效", gin.H{
+		"tags":    tags,
+		"removed": removed,
+	}, traceID)
+}
+
+// getGatewayAnalysis 获取前后端匹配效率分析
+func (cb *CentralBrain) getGatewayAnalysis(c *gin.Context) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+
+	utils.WriteSuccessResponse(c.Writer, "效率分析获取成功", cb.aiEnhancer.GetAnalysis(), traceID)
+}
+
 // registerRouterRoutes 注册Router Service路由管理API
 func (cb *CentralBrain) registerRouterRoutes() {
 	// 公开API：获取所有路由配置
@@ -84,6 +84,15 @@ func (p *HTTPClientPool) GetClient(serviceName string) *http.Client {
 	return client
 }
 
+// GetTransport 获取服务专用的Transport（流式代理使用，不受客户端整体超时限制）
+func (p *HTTPClientPool) GetTransport(serviceName string) http.RoundTripper {
+	client := p.GetClient(serviceName)
+	if client.Transport != nil {
+		return client.Transport
+	}
+	return http.DefaultTransport
+}
+
 // GetDefaultClient 获取默认客户端
 func (p *HTTPClientPool) GetDefaultClient() *http.Client {
 	return p.defaultClient
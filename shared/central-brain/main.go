@@ -42,7 +42,9 @@ func main() {
 		config.ServiceDiscovery.ServiceHost, config.RouterServicePort)
 	log.Printf("  /health              → 健康检查")
 	log.Printf("  /api/v1/metrics      → 性能指标")
+	log.Printf("  /metrics             → Prometheus指标")
 	log.Printf("  /api/v1/circuit-breakers → 熔断器状态")
+	log.Printf("  /api/v1/gateway/routes → 动态路由表（注册中心/Consul/Router Service）")
 	log.Printf("  /api/v1/router/routes → 路由配置（公开）")
 	log.Printf("  /api/v1/router/pages  → 页面配置（公开）")
 	log.Printf("  /api/v1/router/user-routes → 用户路由（需认证）")
@@ -1,8 +1,8 @@
 package middleware
 
 import (
+	"fmt"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,172 +12,532 @@ import (
 type CircuitBreakerState int32
 
 const (
-	StateClosed   CircuitBreakerState = iota // 关闭状态（正常）
-	StateOpen                                // 打开状态（熔断）
-	StateHalfOpen                            // 半开状态（尝试恢复）
+	StateClosed       CircuitBreakerState = iota // 关闭状态（正常）
+	StateOpen                                    // 打开状态（熔断）
+	StateHalfOpen                                // 半开状态（尝试恢复）
+	StateForcedOpen                              // 管理员强制打开（拒绝所有请求）
+	StateForcedClosed                            // 管理员强制关闭（放行所有请求，不再评估）
 )
 
-// CircuitBreaker 熔断器
-type CircuitBreaker struct {
-	mu sync.RWMutex
+// String 状态名称
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	case StateForcedOpen:
+		return "forced-open"
+	case StateForcedClosed:
+		return "forced-closed"
+	}
+	return "closed"
+}
+
+// 滑动窗口类型
+const (
+	WindowTypeCount = "count" // 最近N次调用
+	WindowTypeTime  = "time"  // 最近N秒内的调用
+)
+
+// CircuitBreakerConfig 熔断器配置（参考resilience4j）
+type CircuitBreakerConfig struct {
+	WindowType               string        `json:"window_type"`                  // count 或 time
+	WindowSize               int           `json:"window_size"`                  // 调用次数（count）或秒数（time）
+	MinimumCalls             int           `json:"minimum_calls"`                // 窗口内至少多少次调用才计算失败率
+	FailureRateThreshold     float64       `json:"failure_rate_threshold"`       // 失败率阈值（百分比）
+	SlowCallRateThreshold    float64       `json:"slow_call_rate_threshold"`     // 慢调用率阈值（百分比）
+	SlowCallDuration         time.Duration `json:"slow_call_duration"`           // 超过该耗时视为慢调用
+	WaitDurationInOpen       time.Duration `json:"wait_duration_in_open"`        // 打开状态持续时间
+	PermittedCallsInHalfOpen int           `json:"permitted_calls_in_half_open"` // 半开状态允许的试探调用数
+}
 
-	// 配置
-	failureThreshold int           // 失败阈值
-	resetTimeout     time.Duration // 重置超时时间
-	successThreshold int           // 半开状态成功阈值
+// DefaultCircuitBreakerConfig 默认熔断器配置
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		WindowType:               WindowTypeCount,
+		WindowSize:               20,
+		MinimumCalls:             10,
+		FailureRateThreshold:     50,
+		SlowCallRateThreshold:    80,
+		SlowCallDuration:         5 * time.Second,
+		WaitDurationInOpen:       60 * time.Second,
+		PermittedCallsInHalfOpen: 3,
+	}
+}
+
+// normalize 补齐非法配置
+func (c CircuitBreakerConfig) normalize() CircuitBreakerConfig {
+	defaults := DefaultCircuitBreakerConfig()
+	if c.WindowType != WindowTypeTime {
+		c.WindowType = WindowTypeCount
+	}
+	if c.WindowSize <= 0 {
+		c.WindowSize = defaults.WindowSize
+	}
+	if c.MinimumCalls <= 0 {
+		c.MinimumCalls = defaults.MinimumCalls
+	}
+	if c.FailureRateThreshold <= 0 || c.FailureRateThreshold > 100 {
+		c.FailureRateThreshold = defaults.FailureRateThreshold
+	}
+	if c.SlowCallRateThreshold <= 0 || c.SlowCallRateThreshold > 100 {
+		c.SlowCallRateThreshold = defaults.SlowCallRateThreshold
+	}
+	if c.SlowCallDuration <= 0 {
+		c.SlowCallDuration = defaults.SlowCallDuration
+	}
+	if c.WaitDurationInOpen <= 0 {
+		c.WaitDurationInOpen = defaults.WaitDurationInOpen
+	}
+	if c.PermittedCallsInHalfOpen <= 0 {
+		c.PermittedCallsInHalfOpen = defaults.PermittedCallsInHalfOpen
+	}
+	return c
+}
 
-	// 状态
-	state         int32     // 当前状态（原子操作）
-	failureCount  int64     // 失败计数（原子操作）
-	successCount  int64     // 成功计数（原子操作）
-	lastFailTime  time.Time // 上次失败时间
-	lastResetTime time.Time // 上次重置时间
+// WindowStats 滑动窗口统计
+type WindowStats struct {
+	TotalCalls   int     `json:"total_calls"`
+	FailedCalls  int     `json:"failed_calls"`
+	SlowCalls    int     `json:"slow_calls"`
+	FailureRate  float64 `json:"failure_rate"`   // 百分比，调用数不足时为-1
+	SlowCallRate float64 `json:"slow_call_rate"` // 百分比，调用数不足时为-1
+}
 
-	// 服务状态
-	serviceStats map[string]*ServiceStats
+// StateTransitionEvent 状态转换事件
+type StateTransitionEvent struct {
+	Name   string              `json:"name"`
+	From   CircuitBreakerState `json:"-"`
+	To     CircuitBreakerState `json:"-"`
+	Reason string              `json:"reason"`
+	Stats  WindowStats         `json:"stats"`
+	At     time.Time           `json:"at"`
 }
 
-// ServiceStats 服务统计
-type ServiceStats struct {
-	FailureCount  int64
-	SuccessCount  int64
-	LastFailTime  time.Time
-	LastResetTime time.Time
+// StateTransitionListener 状态转换监听器
+type StateTransitionListener func(event StateTransitionEvent)
+
+// CircuitBreaker 熔断器（滑动窗口失败率 + 慢调用率）
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	name   string
+	config CircuitBreakerConfig
+
+	state      CircuitBreakerState
+	generation uint64    // 每次状态转换递增，旧状态下发放的许可结果不参与新状态评估
+	openedAt   time.Time // 进入打开状态的时间
+	window     slidingWindow
+
+	// 半开状态试探调用
+	halfOpenInFlight int
+	halfOpenDone     int
+	halfOpenFailed   int
+	halfOpenSlow     int
+
+	// 累计统计
+	totalCalls    int64
+	totalFailures int64
+	totalRejected int64
+
+	listeners []StateTransitionListener
 }
 
 // NewCircuitBreaker 创建熔断器
-func NewCircuitBreaker(failureThreshold int, resetTimeout time.Duration, successThreshold int) *CircuitBreaker {
+func NewCircuitBreaker(name string, config CircuitBreakerConfig) *CircuitBreaker {
+	config = config.normalize()
 	return &CircuitBreaker{
-		failureThreshold: failureThreshold,
-		resetTimeout:     resetTimeout,
-		successThreshold: successThreshold,
-		state:            int32(StateClosed),
-		serviceStats:     make(map[string]*ServiceStats),
+		name:   name,
+		config: config,
+		state:  StateClosed,
+		window: newSlidingWindow(config),
 	}
 }
 
-// Middleware 熔断器中间件
-func (cb *CircuitBreaker) Middleware(serviceName string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 检查熔断器状态
-		state := CircuitBreakerState(atomic.LoadInt32(&cb.state))
-
-		// 根据状态决定是否允许请求
-		if state == StateOpen {
-			// 检查是否可以尝试恢复
-			if time.Since(cb.lastResetTime) >= cb.resetTimeout {
-				// 转换为半开状态
-				atomic.CompareAndSwapInt32(&cb.state, int32(StateOpen), int32(StateHalfOpen))
-				atomic.StoreInt64(&cb.successCount, 0)
-			} else {
-				// 拒绝请求
-				c.JSON(503, gin.H{
-					"code":    503,
-					"message": "服务暂时不可用（熔断器打开）",
-					"data":    nil,
-				})
-				c.Abort()
-				return
-			}
-		}
+// OnStateChange 注册状态转换监听器
+func (cb *CircuitBreaker) OnStateChange(listener StateTransitionListener) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.listeners = append(cb.listeners, listener)
+}
 
-		// 执行请求
-		c.Next()
+// Permit 调用许可；调用结束后必须调用Done记录结果
+type Permit struct {
+	breaker    *CircuitBreaker
+	generation uint64
+	trial      bool // 是否为半开状态的试探调用
+	start      time.Time
+	once       sync.Once
+}
 
-		// 记录结果
-		statusCode := c.Writer.Status()
-		if statusCode >= 500 {
-			cb.recordFailure(serviceName)
-		} else {
-			cb.recordSuccess(serviceName)
+// Done 记录调用结果（状态码>=500视为失败）
+func (p *Permit) Done(statusCode int) {
+	p.once.Do(func() {
+		p.breaker.onResult(p, statusCode >= 500, time.Since(p.start))
+	})
+}
+
+// Acquire 申请调用许可，熔断时返回false
+func (cb *CircuitBreaker) Acquire() (*Permit, bool) {
+	var event *StateTransitionEvent
+	defer func() { cb.publish(event) }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	if cb.state == StateOpen && now.Sub(cb.openedAt) >= cb.config.WaitDurationInOpen {
+		event = cb.transitionLocked(StateHalfOpen, "等待时间结束，进入半开状态试探", now)
+	}
+
+	permit := &Permit{breaker: cb, generation: cb.generation, start: now}
+	switch cb.state {
+	case StateOpen, StateForcedOpen:
+		cb.totalRejected++
+		return nil, false
+	case StateHalfOpen:
+		// 限制试探调用总数（含进行中的调用）
+		if cb.halfOpenInFlight+cb.halfOpenDone >= cb.config.PermittedCallsInHalfOpen {
+			cb.totalRejected++
+			return nil, false
 		}
+		cb.halfOpenInFlight++
+		permit.trial = true
 	}
+	return permit, true
 }
 
-// recordFailure 记录失败
-func (cb *CircuitBreaker) recordFailure(serviceName string) {
+// onResult 记录调用结果并评估状态转换
+func (cb *CircuitBreaker) onResult(permit *Permit, failed bool, duration time.Duration) {
+	var event *StateTransitionEvent
+	defer func() { cb.publish(event) }()
+
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	// 更新服务统计
-	if cb.serviceStats[serviceName] == nil {
-		cb.serviceStats[serviceName] = &ServiceStats{}
+	now := time.Now()
+	slow := duration >= cb.config.SlowCallDuration
+
+	cb.totalCalls++
+	if failed {
+		cb.totalFailures++
+	}
+
+	// 状态已变化，旧许可的结果不参与评估
+	if permit.generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case StateClosed:
+		cb.window.record(failed, slow, now)
+		stats := cb.window.snapshot(now, cb.config.MinimumCalls)
+		if reason := cb.tripReason(stats); reason != "" {
+			event = cb.transitionLocked(StateOpen, reason, now)
+		}
+	case StateHalfOpen:
+		if !permit.trial {
+			return
+		}
+		cb.halfOpenInFlight--
+		cb.halfOpenDone++
+		if failed {
+			cb.halfOpenFailed++
+		}
+		if slow {
+			cb.halfOpenSlow++
+		}
+
+		if cb.halfOpenDone >= cb.config.PermittedCallsInHalfOpen {
+			stats := rateStats(cb.halfOpenDone, cb.halfOpenFailed, cb.halfOpenSlow, 1)
+			if reason := cb.tripReason(stats); reason != "" {
+				event = cb.transitionLocked(StateOpen, "半开试探未通过: "+reason, now)
+			} else {
+				event = cb.transitionLocked(StateClosed, "半开试探通过，恢复关闭状态", now)
+			}
+		}
+	}
+}
+
+// tripReason 判断统计是否达到熔断阈值，返回原因（未达到时为空）
+func (cb *CircuitBreaker) tripReason(stats WindowStats) string {
+	if stats.FailureRate >= 0 && stats.FailureRate >= cb.config.FailureRateThreshold {
+		return fmt.Sprintf("失败率 %.1f%% 超过阈值 %.1f%%", stats.FailureRate, cb.config.FailureRateThreshold)
+	}
+	if stats.SlowCallRate >= 0 && stats.SlowCallRate >= cb.config.SlowCallRateThreshold {
+		return fmt.Sprintf("慢调用率 %.1f%% 超过阈值 %.1f%%", stats.SlowCallRate, cb.config.SlowCallRateThreshold)
+	}
+	return ""
+}
+
+// transitionLocked 执行状态转换（调用方持有锁），返回待发布的事件
+func (cb *CircuitBreaker) transitionLocked(to CircuitBreakerState, reason string, now time.Time) *StateTransitionEvent {
+	from := cb.state
+	if from == to {
+		return nil
+	}
+
+	event := &StateTransitionEvent{
+		Name:   cb.name,
+		From:   from,
+		To:     to,
+		Reason: reason,
+		Stats:  cb.window.snapshot(now, cb.config.MinimumCalls),
+		At:     now,
 	}
-	stats := cb.serviceStats[serviceName]
-	stats.FailureCount++
-	stats.LastFailTime = time.Now()
 
-	// 更新全局计数
-	failureCount := atomic.AddInt64(&cb.failureCount, 1)
-	cb.lastFailTime = time.Now()
+	cb.state = to
+	cb.generation++
+	cb.halfOpenInFlight = 0
+	cb.halfOpenDone = 0
+	cb.halfOpenFailed = 0
+	cb.halfOpenSlow = 0
 
-	// 检查是否达到阈值
-	state := CircuitBreakerState(atomic.LoadInt32(&cb.state))
-	if state == StateHalfOpen {
-		// 半开状态：只要失败就立即打开
-		atomic.StoreInt32(&cb.state, int32(StateOpen))
-		atomic.StoreInt64(&cb.failureCount, 0)
-		cb.lastResetTime = time.Now()
-	} else if state == StateClosed && failureCount >= int64(cb.failureThreshold) {
-		// 关闭状态：达到失败阈值，打开熔断器
-		atomic.StoreInt32(&cb.state, int32(StateOpen))
-		atomic.StoreInt64(&cb.failureCount, 0)
-		cb.lastResetTime = time.Now()
+	switch to {
+	case StateOpen:
+		cb.openedAt = now
+	case StateClosed, StateForcedClosed:
+		cb.window.reset()
 	}
+	return event
 }
 
-// recordSuccess 记录成功
-func (cb *CircuitBreaker) recordSuccess(serviceName string) {
+// publish 在锁外通知监听器
+func (cb *CircuitBreaker) publish(event *StateTransitionEvent) {
+	if event == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	listeners := make([]StateTransitionListener, len(cb.listeners))
+	copy(listeners, cb.listeners)
+	cb.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(*event)
+	}
+}
+
+// ForceOpen 管理员强制打开熔断器
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.forceTransition(StateForcedOpen, "管理员强制打开")
+}
+
+// ForceClose 管理员强制关闭熔断器
+func (cb *CircuitBreaker) ForceClose() {
+	cb.forceTransition(StateForcedClosed, "管理员强制关闭")
+}
+
+// Reset 恢复为正常的关闭状态并清空窗口
+func (cb *CircuitBreaker) Reset() {
+	cb.forceTransition(StateClosed, "管理员重置")
+}
+
+func (cb *CircuitBreaker) forceTransition(to CircuitBreakerState, reason string) {
+	var event *StateTransitionEvent
+	defer func() { cb.publish(event) }()
+
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	// 更新服务统计
-	if cb.serviceStats[serviceName] == nil {
-		cb.serviceStats[serviceName] = &ServiceStats{}
-	}
-	stats := cb.serviceStats[serviceName]
-	stats.SuccessCount++
-
-	// 更新全局计数
-	state := CircuitBreakerState(atomic.LoadInt32(&cb.state))
-	if state == StateHalfOpen {
-		// 半开状态：检查是否达到成功阈值
-		successCount := atomic.AddInt64(&cb.successCount, 1)
-		if successCount >= int64(cb.successThreshold) {
-			// 恢复为关闭状态
-			atomic.StoreInt32(&cb.state, int32(StateClosed))
-			atomic.StoreInt64(&cb.successCount, 0)
-			atomic.StoreInt64(&cb.failureCount, 0)
+	event = cb.transitionLocked(to, reason, time.Now())
+	if to == StateClosed {
+		cb.window.reset()
+	}
+}
+
+// Middleware 熔断器中间件
+func (cb *CircuitBreaker) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permit, ok := cb.Acquire()
+		if !ok {
+			cb.Reject(c)
+			return
 		}
-	} else if state == StateClosed {
-		// 关闭状态：重置失败计数
-		atomic.StoreInt64(&cb.failureCount, 0)
+
+		// 执行请求
+		c.Next()
+
+		// 记录结果
+		permit.Done(c.Writer.Status())
 	}
 }
 
+// Reject 以统一格式拒绝请求
+func (cb *CircuitBreaker) Reject(c *gin.Context) {
+	c.JSON(503, gin.H{
+		"code":    503,
+		"message": "服务暂时不可用（熔断器打开）",
+		"data":    nil,
+	})
+	c.Abort()
+}
+
 // GetState 获取当前状态
 func (cb *CircuitBreaker) GetState() CircuitBreakerState {
-	return CircuitBreakerState(atomic.LoadInt32(&cb.state))
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
 }
 
 // GetStats 获取统计信息
 func (cb *CircuitBreaker) GetStats() map[string]interface{} {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
-	state := CircuitBreakerState(atomic.LoadInt32(&cb.state))
-	stateStr := "closed"
-	switch state {
+	now := time.Now()
+	stats := map[string]interface{}{
+		"name":           cb.name,
+		"state":          cb.state.String(),
+		"window":         cb.window.snapshot(now, cb.config.MinimumCalls),
+		"config":         cb.config,
+		"total_calls":    cb.totalCalls,
+		"total_failures": cb.totalFailures,
+		"total_rejected": cb.totalRejected,
+	}
+
+	switch cb.state {
 	case StateOpen:
-		stateStr = "open"
+		remaining := cb.config.WaitDurationInOpen - now.Sub(cb.openedAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		stats["opened_at"] = cb.openedAt.Format(time.RFC3339)
+		stats["half_open_in_ms"] = remaining.Milliseconds()
 	case StateHalfOpen:
-		stateStr = "half-open"
+		stats["half_open_trials"] = map[string]int{
+			"permitted": cb.config.PermittedCallsInHalfOpen,
+			"in_flight": cb.halfOpenInFlight,
+			"completed": cb.halfOpenDone,
+			"failed":    cb.halfOpenFailed,
+			"slow":      cb.halfOpenSlow,
+		}
+	}
+	return stats
+}
+
+// rateStats 根据计数计算失败率与慢调用率
+func rateStats(total, failed, slow, minimumCalls int) WindowStats {
+	stats := WindowStats{
+		TotalCalls:   total,
+		FailedCalls:  failed,
+		SlowCalls:    slow,
+		FailureRate:  -1,
+		SlowCallRate: -1,
 	}
+	if total > 0 && total >= minimumCalls {
+		stats.FailureRate = float64(failed) / float64(total) * 100
+		stats.SlowCallRate = float64(slow) / float64(total) * 100
+	}
+	return stats
+}
+
+// slidingWindow 滑动窗口
+type slidingWindow interface {
+	record(failed, slow bool, now time.Time)
+	snapshot(now time.Time, minimumCalls int) WindowStats
+	reset()
+}
+
+func newSlidingWindow(config CircuitBreakerConfig) slidingWindow {
+	if config.WindowType == WindowTypeTime {
+		return &timeWindow{buckets: make([]timeBucket, config.WindowSize)}
+	}
+	return &countWindow{outcomes: make([]callOutcome, config.WindowSize)}
+}
+
+// callOutcome 单次调用结果
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// countWindow 基于调用次数的环形窗口
+type countWindow struct {
+	outcomes []callOutcome
+	next     int
+	size     int
+	failed   int
+	slow     int
+}
+
+func (w *countWindow) record(failed, slow bool, now time.Time) {
+	if w.size == len(w.outcomes) {
+		// 淘汰最旧的结果
+		old := w.outcomes[w.next]
+		if old.failed {
+			w.failed--
+		}
+		if old.slow {
+			w.slow--
+		}
+	} else {
+		w.size++
+	}
+
+	w.outcomes[w.next] = callOutcome{failed: failed, slow: slow}
+	w.next = (w.next + 1) % len(w.outcomes)
+	if failed {
+		w.failed++
+	}
+	if slow {
+		w.slow++
+	}
+}
+
+func (w *countWindow) snapshot(now time.Time, minimumCalls int) WindowStats {
+	return rateStats(w.size, w.failed, w.slow, minimumCalls)
+}
+
+func (w *countWindow) reset() {
+	w.next, w.size, w.failed, w.slow = 0, 0, 0, 0
+}
+
+// timeBucket 每秒一个桶
+type timeBucket struct {
+	second int64
+	total  int
+	failed int
+	slow   int
+}
+
+// timeWindow 基于时间的环形窗口（最近N秒）
+type timeWindow struct {
+	buckets []timeBucket
+}
+
+func (w *timeWindow) record(failed, slow bool, now time.Time) {
+	second := now.Unix()
+	bucket := &w.buckets[second%int64(len(w.buckets))]
+	if bucket.second != second {
+		*bucket = timeBucket{second: second}
+	}
+
+	bucket.total++
+	if failed {
+		bucket.failed++
+	}
+	if slow {
+		bucket.slow++
+	}
+}
+
+func (w *timeWindow) snapshot(now time.Time, minimumCalls int) WindowStats {
+	oldest := now.Unix() - int64(len(w.buckets)) + 1
+	total, failed, slow := 0, 0, 0
+	for _, bucket := range w.buckets {
+		if bucket.second >= oldest {
+			total += bucket.total
+			failed += bucket.failed
+			slow += bucket.slow
+		}
+	}
+	return rateStats(total, failed, slow, minimumCalls)
+}
 
-	return map[string]interface{}{
-		"state":         stateStr,
-		"failure_count": atomic.LoadInt64(&cb.failureCount),
-		"success_count": atomic.LoadInt64(&cb.successCount),
-		"service_stats": cb.serviceStats,
+func (w *timeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = timeBucket{}
 	}
 }
@@ -1,9 +1,7 @@
 package middleware
 
 import (
-	"bytes"
 	"encoding/json"
-	"io"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,28 +26,29 @@ func (rl *RequestLogger) Middleware() gin.HandlerFunc {
 			return
 		}
 
-		// 生成请求追踪ID
-		traceID := c.GetHeader("X-Trace-ID")
+		// 请求追踪ID：优先使用跟踪中间件生成的W3C trace-id，未启用跟踪时兼容X-Trace-ID
+		traceID := c.GetString("trace_id")
 		if traceID == "" {
-			traceID = uuid.New().String()
+			traceID = c.GetHeader("X-Trace-ID")
+			if traceID == "" {
+				traceID = uuid.New().String()
+			}
+			c.Set("trace_id", traceID)
+			c.Header("X-Trace-ID", traceID)
 		}
-		c.Set("trace_id", traceID)
-		c.Header("X-Trace-ID", traceID)
 
 		// 记录开始时间
 		startTime := time.Now()
 
-		// 读取请求体（用于日志记录）
-		var requestBody []byte
-		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		// 请求体大小取自Content-Length，不读取请求体，避免破坏流式代理
+		requestSize := c.Request.ContentLength
+		if requestSize < 0 {
+			requestSize = 0
 		}
 
-		// 创建响应写入器（用于记录响应）
+		// 创建响应写入器（只统计响应大小，不缓冲响应体）
 		responseWriter := &responseWriter{
 			ResponseWriter: c.Writer,
-			body:           &bytes.Buffer{},
 		}
 		c.Writer = responseWriter
 
@@ -60,12 +59,12 @@ func (rl *RequestLogger) Middleware() gin.HandlerFunc {
 		duration := time.Since(startTime)
 
 		// 记录日志
-		rl.logRequest(c, traceID, requestBody, responseWriter, duration)
+		rl.logRequest(c, traceID, requestSize, responseWriter, duration)
 	}
 }
 
 // logRequest 记录请求日志
-func (rl *RequestLogger) logRequest(c *gin.Context, traceID string, requestBody []byte, rw *responseWriter, duration time.Duration) {
+func (rl *RequestLogger) logRequest(c *gin.Context, traceID string, requestSize int64, rw *responseWriter, duration time.Duration) {
 	logEntry := map[string]interface{}{
 		"timestamp":     time.Now().Format(time.RFC3339),
 		"trace_id":      traceID,
@@ -76,8 +75,11 @@ func (rl *RequestLogger) logRequest(c *gin.Context, traceID string, requestBody
 		"user_agent":    c.Request.UserAgent(),
 		"status_code":   rw.Status(),
 		"duration_ms":   duration.Milliseconds(),
-		"request_size":  len(requestBody),
-		"response_size": rw.body.Len(),
+		"request_size":  requestSize,
+		"response_size": rw.written,
+	}
+	if spanID := c.GetString("span_id"); spanID != "" {
+		logEntry["span_id"] = spanID
 	}
 
 	// 添加错误信息（如果有）
@@ -101,12 +103,20 @@ func (rl *RequestLogger) logRequest(c *gin.Context, traceID string, requestBody
 }
 
 // responseWriter 响应写入器包装器
+// 嵌入gin.ResponseWriter以保留Flush/Hijack能力（SSE、WebSocket）
 type responseWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	written int64
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	rw.body.Write(b)
-	return rw.ResponseWriter.Write(b)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	n, err := rw.ResponseWriter.WriteString(s)
+	rw.written += int64(n)
+	return n, err
 }
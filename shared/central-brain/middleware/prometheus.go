@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// gin上下文键：由代理分发器写入，供指标中间件打标签
+const (
+	ContextKeyUpstreamService = "upstream_service" // 目标上游服务名
+	ContextKeyRouteTemplate   = "route_template"   // 路由模板（如 /api/v1/job/*）
+)
+
+// PrometheusMetrics 网关Prometheus指标
+// 路由标签使用路由模板而非原始路径，避免路径参数导致标签基数爆炸。
+type PrometheusMetrics struct {
+	registry *prometheus.Registry
+
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	upstreamErrors  *prometheus.CounterVec
+	inFlight        prometheus.Gauge
+	breakerChanges  *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics 创建Prometheus指标收集器
+// breakerStates 在每次抓取时返回各熔断器的当前状态
+func NewPrometheusMetrics(breakerStates func() map[string]CircuitBreakerState) *PrometheusMetrics {
+	registry := prometheus.NewRegistry()
+
+	pm := &PrometheusMetrics{
+		registry: registry,
+		requestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "central_brain",
+				Name:      "http_requests_total",
+				Help:      "Total number of HTTP requests handled by the gateway",
+			},
+			[]string{"service", "route", "method", "status_class"},
+		),
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "central_brain",
+				Name:      "http_request_duration_seconds",
+				Help:      "HTTP request duration in seconds, including the upstream hop",
+				Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"service", "route", "method", "status_class"},
+		),
+		upstreamErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "central_brain",
+				Name:      "upstream_errors_total",
+				Help:      "Total number of upstream errors by reason",
+			},
+			[]string{"service", "reason"},
+		),
+		inFlight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: "central_brain",
+				Name:      "http_requests_in_flight",
+				Help:      "Number of HTTP requests currently being served",
+			},
+		),
+		breakerChanges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "central_brain",
+				Name:      "circuit_breaker_transitions_total",
+				Help:      "Total number of circuit breaker state transitions",
+			},
+			[]string{"breaker", "from", "to"},
+		),
+	}
+
+	registry.MustRegister(
+		pm.requestsTotal,
+		pm.requestDuration,
+		pm.upstreamErrors,
+		pm.inFlight,
+		pm.breakerChanges,
+		&circuitBreakerCollector{
+			states: breakerStates,
+			desc: prometheus.NewDesc(
+				"central_brain_circuit_breaker_state",
+				"Circuit breaker state (0=closed, 1=open, 2=half-open, 3=forced-open, 4=forced-closed)",
+				[]string{"breaker"}, nil,
+			),
+		},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return pm
+}
+
+// Middleware Prometheus指标中间件
+func (pm *PrometheusMetrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		pm.inFlight.Inc()
+		defer pm.inFlight.Dec()
+
+		// 处理请求
+		c.Next()
+
+		service := c.GetString(ContextKeyUpstreamService)
+		if service == "" {
+			service = "central-brain"
+		}
+
+		route := c.FullPath()
+		if template := c.GetString(ContextKeyRouteTemplate); template != "" {
+			route = template
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := prometheus.Labels{
+			"service":      service,
+			"route":        route,
+			"method":       c.Request.Method,
+			"status_class": statusClass(c.Writer.Status()),
+		}
+		pm.requestsTotal.With(labels).Inc()
+		pm.requestDuration.With(labels).Observe(time.Since(startTime).Seconds())
+	}
+}
+
+// RecordUpstreamError 记录上游错误（transport、status_5xx、circuit_open等）
+func (pm *PrometheusMetrics) RecordUpstreamError(service, reason string) {
+	pm.upstreamErrors.WithLabelValues(service, reason).Inc()
+}
+
+// RecordBreakerTransition 记录熔断器状态转换
+func (pm *PrometheusMetrics) RecordBreakerTransition(event StateTransitionEvent) {
+	pm.breakerChanges.WithLabelValues(event.Name, event.From.String(), event.To.String()).Inc()
+}
+
+// Handler Prometheus文本格式抓取端点
+func (pm *PrometheusMetrics) Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(pm.registry, promhttp.HandlerOpts{}))
+}
+
+// Registry 获取指标注册表（供其他组件注册自定义指标）
+func (pm *PrometheusMetrics) Registry() *prometheus.Registry {
+	return pm.registry
+}
+
+// statusClass 将状态码归类为 2xx/3xx/4xx/5xx
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+// circuitBreakerCollector 抓取时读取熔断器状态（熔断器按需创建，数量不固定）
+type circuitBreakerCollector struct {
+	states func() map[string]CircuitBreakerState
+	desc   *prometheus.Desc
+}
+
+func (c *circuitBreakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *circuitBreakerCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.states == nil {
+		return
+	}
+	for name, state := range c.states() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(state), name)
+	}
+}
@@ -0,0 +1,372 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// 限流维度
+const (
+	RateLimitKeyUser    = "user"    // 按用户ID（网关令牌校验后的user_id）
+	RateLimitKeyService = "service" // 按服务ID（经校验的X-Service-Token）
+	RateLimitKeyIP      = "ip"      // 按客户端IP
+	RateLimitKeyRoute   = "route"   // 按路由前缀（所有调用方共享）
+)
+
+// RateLimitPolicy 限流策略
+type RateLimitPolicy struct {
+	Name        string  `json:"name"`
+	KeyType     string  `json:"key_type"`               // user、service、ip、route
+	RoutePrefix string  `json:"route_prefix,omitempty"` // 仅作用于该前缀下的路由（空表示全部）
+	Rate        float64 `json:"rate"`                   // 每秒补充的令牌数
+	Burst       int     `json:"burst"`                  // 令牌桶容量
+	Enabled     bool    `json:"enabled"`
+}
+
+// Validate 校验策略
+func (p RateLimitPolicy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("策略名称不能为空")
+	}
+	switch p.KeyType {
+	case RateLimitKeyUser, RateLimitKeyService, RateLimitKeyIP, RateLimitKeyRoute:
+	default:
+		return fmt.Errorf("策略 %s 的限流维度无效: %s", p.Name, p.KeyType)
+	}
+	if p.KeyType == RateLimitKeyRoute && p.RoutePrefix == "" {
+		return fmt.Errorf("策略 %s 按路由限流时必须指定route_prefix", p.Name)
+	}
+	if p.Rate <= 0 || p.Burst <= 0 {
+		return fmt.Errorf("策略 %s 的rate和burst必须大于0", p.Name)
+	}
+	return nil
+}
+
+// DefaultRateLimitPolicies 默认限流策略
+func DefaultRateLimitPolicies() []RateLimitPolicy {
+	return []RateLimitPolicy{
+		{Name: "per-ip", KeyType: RateLimitKeyIP, Rate: 50, Burst: 100, Enabled: true},
+		{Name: "per-user", KeyType: RateLimitKeyUser, Rate: 30, Burst: 60, Enabled: true},
+		{Name: "per-service", KeyType: RateLimitKeyService, Rate: 200, Burst: 400, Enabled: true},
+	}
+}
+
+// BucketResult 令牌桶扣减结果
+type BucketResult struct {
+	Allowed    bool
+	Remaining  int
+	ResetAfter time.Duration // 令牌桶补满所需时间
+	RetryAfter time.Duration // 被拒绝时距下一个可用令牌的时间
+}
+
+// BucketStore 令牌桶存储
+type BucketStore interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (*BucketResult, error)
+}
+
+// bucketResultFromTokens 根据剩余令牌计算结果
+func bucketResultFromTokens(allowed bool, tokens, rate float64, burst int) *BucketResult {
+	result := &BucketResult{
+		Allowed:    allowed,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(burst) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result
+}
+
+// MemoryBucketStore 进程内令牌桶（单实例或Redis不可用时使用）
+type MemoryBucketStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryBucketStore 创建进程内令牌桶存储
+func NewMemoryBucketStore() *MemoryBucketStore {
+	return &MemoryBucketStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take 扣减一个令牌
+func (s *MemoryBucketStore) Take(ctx context.Context, key string, rate float64, burst int) (*BucketResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	bucket, exists := s.buckets[key]
+	if !exists {
+		bucket = &memoryBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+elapsed*rate)
+	bucket.last = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+
+	return bucketResultFromTokens(allowed, bucket.tokens, rate, burst), nil
+}
+
+// redisTokenBucketScript 原子令牌桶脚本（使用Redis服务器时间，避免多副本时钟偏差）
+var redisTokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(burst, tokens + elapsed * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisBucketStore Redis分布式令牌桶（多个中央大脑副本共享限流状态）
+type RedisBucketStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisBucketStore 创建Redis令牌桶存储
+func NewRedisBucketStore(client *redis.Client, keyPrefix string) *RedisBucketStore {
+	if keyPrefix == "" {
+		keyPrefix = "central-brain:ratelimit:"
+	}
+	return &RedisBucketStore{client: client, keyPrefix: keyPrefix}
+}
+
+// Take 扣减一个令牌
+func (s *RedisBucketStore) Take(ctx context.Context, key string, rate float64, burst int) (*BucketResult, error) {
+	values, err := redisTokenBucketScript.Run(ctx, s.client, []string{s.keyPrefix + key},
+		strconv.FormatFloat(rate, 'f', -1, 64), burst).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("执行Redis限流脚本失败: %v", err)
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("Redis限流脚本返回格式错误")
+	}
+
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析剩余令牌失败: %v", err)
+	}
+
+	return bucketResultFromTokens(allowed == 1, tokens, rate, burst), nil
+}
+
+// PolicyRateLimiter 基于策略的多维度限流器
+type PolicyRateLimiter struct {
+	mu       sync.RWMutex
+	policies []RateLimitPolicy
+
+	store    BucketStore
+	fallback BucketStore // 主存储（Redis）失败时降级使用
+	enabled  bool
+}
+
+// NewPolicyRateLimiter 创建策略限流器
+// store为nil时使用进程内令牌桶
+func NewPolicyRateLimiter(policies []RateLimitPolicy, store BucketStore, enabled bool) (*PolicyRateLimiter, error) {
+	fallback := NewMemoryBucketStore()
+	if store == nil {
+		store = fallback
+	}
+
+	rl := &PolicyRateLimiter{
+		store:    store,
+		fallback: fallback,
+		enabled:  enabled,
+	}
+	if err := rl.SetPolicies(policies); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// SetPolicies 替换限流策略（运行时热加载）
+func (rl *PolicyRateLimiter) SetPolicies(policies []RateLimitPolicy) error {
+	for _, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+
+	copied := make([]RateLimitPolicy, len(policies))
+	copy(copied, policies)
+
+	rl.mu.Lock()
+	rl.policies = copied
+	rl.mu.Unlock()
+	return nil
+}
+
+// GetPolicies 获取当前限流策略
+func (rl *PolicyRateLimiter) GetPolicies() []RateLimitPolicy {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	copied := make([]RateLimitPolicy, len(rl.policies))
+	copy(copied, rl.policies)
+	return copied
+}
+
+// LoadPoliciesFromFile 从JSON文件加载限流策略
+func (rl *PolicyRateLimiter) LoadPoliciesFromFile(path string) error {
+	policies, err := ReadRateLimitPolicies(path)
+	if err != nil {
+		return err
+	}
+	return rl.SetPolicies(policies)
+}
+
+// ReadRateLimitPolicies 读取JSON格式的限流策略文件
+func ReadRateLimitPolicies(path string) ([]RateLimitPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取限流策略文件失败: %v", err)
+	}
+
+	var policies []RateLimitPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("解析限流策略文件失败: %v", err)
+	}
+	return policies, nil
+}
+
+// Middleware 策略限流中间件
+// 依次评估所有匹配的策略，响应头反映剩余额度最少的策略；任一策略拒绝即返回429。
+func (rl *PolicyRateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rl.enabled {
+			c.Next()
+			return
+		}
+
+		var tightest *BucketResult
+		var tightestPolicy RateLimitPolicy
+
+		for _, policy := range rl.GetPolicies() {
+			if !policy.Enabled {
+				continue
+			}
+			if policy.RoutePrefix != "" && !strings.HasPrefix(c.Request.URL.Path, policy.RoutePrefix) {
+				continue
+			}
+
+			subject := rateLimitSubject(c, policy.KeyType, policy.RoutePrefix)
+			if subject == "" {
+				continue
+			}
+
+			key := fmt.Sprintf("%s:%s:%s", policy.Name, policy.KeyType, subject)
+			result, err := rl.store.Take(c.Request.Context(), key, policy.Rate, policy.Burst)
+			if err != nil {
+				// 分布式存储不可用时降级为本地令牌桶，避免限流失效或误拒绝
+				fmt.Printf("⚠️ 限流存储不可用，降级为本地限流: %v\n", err)
+				result, _ = rl.fallback.Take(c.Request.Context(), key, policy.Rate, policy.Burst)
+			}
+
+			if tightest == nil || !result.Allowed || (tightest.Allowed && result.Remaining < tightest.Remaining) {
+				tightest = result
+				tightestPolicy = policy
+			}
+			if !result.Allowed {
+				break
+			}
+		}
+
+		if tightest == nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(tightestPolicy.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(tightest.ResetAfter.Seconds()))))
+
+		if !tightest.Allowed {
+			retryAfter := int(math.Ceil(tightest.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(429, gin.H{
+				"code":    429,
+				"message": "请求过于频繁，请稍后再试",
+				"data": gin.H{
+					"policy":      tightestPolicy.Name,
+					"retry_after": retryAfter,
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitSubject 提取限流主体标识（无法识别时返回空，跳过该策略）
+// 用户与服务维度只采信网关已校验的身份（令牌校验设置的user_id、服务token校验设置的service_id）；
+// 请求声明了身份却未经校验时按客户端IP分桶，避免伪造身份绕过限流。
+// 客户端IP由gin按可信代理配置（TRUSTED_PROXIES）解析，不可信来源的X-Forwarded-For不会被采信。
+func rateLimitSubject(c *gin.Context, keyType, routePrefix string) string {
+	switch keyType {
+	case RateLimitKeyUser:
+		if userID, exists := c.Get("user_id"); exists {
+			return fmt.Sprint(userID)
+		}
+		if c.GetHeader("Authorization") != "" || c.GetHeader("accessToken") != "" {
+			return "ip:" + c.ClientIP()
+		}
+	case RateLimitKeyService:
+		if serviceID, exists := c.Get("service_id"); exists {
+			return fmt.Sprint(serviceID)
+		}
+		if c.GetHeader("X-Service-Token") != "" || c.GetHeader("X-Service-ID") != "" {
+			return "ip:" + c.ClientIP()
+		}
+	case RateLimitKeyIP:
+		return c.ClientIP()
+	case RateLimitKeyRoute:
+		return routePrefix
+	}
+	return ""
+}
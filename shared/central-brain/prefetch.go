@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/szjason72/zervigo/shared/central-brain/middleware"
+	"github.com/szjason72/zervigo/shared/core/shared"
+)
+
+// prefetchForwardHeaders 预取请求沿用触发请求的这些请求头（身份与内容协商，保证缓存键一致）
+var prefetchForwardHeaders = []string{"Authorization", "accessToken", "Accept", "Accept-Encoding", "Accept-Language"}
+
+// PrefetchOrigin 触发预取的请求快照（gin.Context会被复用，不能在异步预取中直接引用）
+type PrefetchOrigin struct {
+	UserID      int
+	Role        string
+	Permissions []string
+	Header      http.Header
+}
+
+// NewPrefetchOrigin 从当前请求提取预取所需的身份与请求头
+func NewPrefetchOrigin(c *gin.Context) PrefetchOrigin {
+	header := make(http.Header)
+	for _, name := range prefetchForwardHeaders {
+		if values := c.Request.Header.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return PrefetchOrigin{
+		UserID:      c.GetInt("user_id"),
+		Role:        c.GetString("role"),
+		Permissions: c.GetStringSlice("permissions"),
+		Header:      header,
+	}
+}
+
+// Prefetcher 预测预取执行器
+// 以用户自己的令牌在后台请求预测的下一路径，结果写入响应缓存（按用户/角色分区）。
+// 只预取配置中标记为安全的GET路由，使用专用并发预算，预算占满时直接放弃而不是排队，
+// 不重试、不对冲，熔断器未处于关闭状态时不预取，避免挤占正常请求的上游容量。
+type Prefetcher struct {
+	cb        *CentralBrain
+	threshold float64
+	routes    []string
+	timeout   time.Duration
+	slots     chan struct{}
+	inflight  sync.Map // 用户+路径 -> struct{}，同一预取不重复发起
+
+	issued  atomic.Int64
+	stored  atomic.Int64
+	skipped atomic.Int64 // 并发预算占满或已有新鲜缓存
+	failed  atomic.Int64
+}
+
+// PrefetchStats 预取统计
+type PrefetchStats struct {
+	Enabled   bool     `json:"enabled"`
+	Threshold float64  `json:"threshold"`
+	Routes    []string `json:"routes"`
+	Issued    int64    `json:"issued"`
+	Stored    int64    `json:"stored"`
+	Skipped   int64    `json:"skipped"`
+	Failed    int64    `json:"failed"`
+}
+
+// NewPrefetcher 根据配置创建预取执行器；未启用、未配置路由或响应缓存未启用时返回nil
+func NewPrefetcher(cb *CentralBrain, config *shared.Config) *Prefetcher {
+	if !config.Prefetch.Enabled || cb.responseCache == nil {
+		return nil
+	}
+	if len(config.Prefetch.Routes) == 0 {
+		fmt.Printf("⚠️  预取已启用但未配置PREFETCH_ROUTES，不执行预取\n")
+		return nil
+	}
+
+	maxConcurrent := config.Prefetch.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	timeout := time.Duration(config.Prefetch.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	fmt.Printf("✅ 预测预取已启用（阈值 %.2f，并发 %d，路由 %v）\n",
+		config.Prefetch.Threshold, maxConcurrent, config.Prefetch.Routes)
+	return &Prefetcher{
+		cb:        cb,
+		threshold: config.Prefetch.Threshold,
+		routes:    config.Prefetch.Routes,
+		timeout:   timeout,
+		slots:     make(chan struct{}, maxConcurrent),
+	}
+}
+
+// Threshold 预取所需的最低预测概率
+func (p *Prefetcher) Threshold() float64 {
+	return p.threshold
+}
+
+// Allowed 路径是否属于允许预取的路由
+func (p *Prefetcher) Allowed(requestPath string) bool {
+	for _, route := range p.routes {
+		if requestPath == route || strings.HasPrefix(requestPath, strings.TrimSuffix(route, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefetch 预测概率达到阈值时异步预取，返回是否已发起
+func (p *Prefetcher) Prefetch(origin PrefetchOrigin, prediction *Prediction) bool {
+	if p == nil || prediction == nil || prediction.Probability < p.threshold {
+		return false
+	}
+	target, err := url.Parse(prediction.NextPath)
+	if err != nil || !p.Allowed(target.Path) {
+		return false
+	}
+
+	inflightKey := fmt.Sprintf("%d|%s", origin.UserID, target.RequestURI())
+	if _, loaded := p.inflight.LoadOrStore(inflightKey, struct{}{}); loaded {
+		return false
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.inflight.Delete(inflightKey)
+		p.skipped.Add(1)
+		return false
+	}
+
+	go func() {
+		defer func() {
+			<-p.slots
+			p.inflight.Delete(inflightKey)
+		}()
+		p.run(origin, target)
+	}()
+	return true
+}
+
+// run 执行一次预取
+func (p *Prefetcher) run(origin PrefetchOrigin, target *url.URL) {
+	cb := p.cb
+	service, ok := cb.routeManager.Match(http.MethodGet, target.Path)
+	if !ok || service.Streaming {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.RequestURI(), nil)
+	if err != nil {
+		return
+	}
+	req.Header = origin.Header.Clone()
+
+	// 代理与缓存只读取请求和已校验的身份，这里构造独立的上下文
+	c := &gin.Context{Request: req}
+	if origin.UserID > 0 {
+		c.Set("user_id", origin.UserID)
+		c.Set("role", origin.Role)
+		c.Set("permissions", origin.Permissions)
+	}
+
+	userToken := cb.extractUserToken(req)
+	lookup, fresh := cb.responseCache.Fresh(c, service, userToken)
+	if fresh || lookup == nil {
+		// 已有新鲜缓存，或身份未经网关校验不可缓存
+		p.skipped.Add(1)
+		return
+	}
+
+	breaker := cb.getCircuitBreaker(breakerKeyFor(service, target.Path), service.Breaker)
+	if breaker.GetState() != middleware.StateClosed {
+		p.skipped.Add(1)
+		return
+	}
+	permit, allowed := breaker.Acquire()
+	if !allowed {
+		p.skipped.Add(1)
+		return
+	}
+
+	// 结果在defer中记录；预取是后台任务，panic时按5xx计入并结束本次预取，不影响进程
+	statusCode := http.StatusInternalServerError
+	defer func() {
+		if r := recover(); r != nil {
+			p.failed.Add(1)
+			fmt.Printf("⚠️  预取异常 %s: %v\n", target.Path, r)
+		}
+		permit.Done(statusCode)
+	}()
+
+	baseURL, release := cb.routeManager.ResolveBaseURL(service)
+	defer release()
+	service.BaseURL = baseURL
+
+	// 预取为低优先级请求：不重试、不对冲，超时不超过预取超时
+	policy := DefaultUpstreamPolicy()
+	if service.Policy != nil {
+		copied := *service.Policy
+		policy = &copied
+	}
+	policy.Retry = nil
+	policy.HedgeDelay = 0
+	policy.MaxHedges = 0
+	if policy.Timeout <= 0 || policy.Timeout > p.timeout {
+		policy.Timeout = p.timeout
+	}
+	service.Policy = policy
+
+	header := origin.Header.Clone()
+	cb.injectGatewayHeaders(c, header)
+	header.Set("Sec-Purpose", "prefetch")
+	lookup.PrepareUpstream(header)
+
+	p.issued.Add(1)
+	result := cb.doUpstream(c, service, nil, header)
+	if result.err != nil {
+		statusCode = http.StatusBadGateway
+		p.failed.Add(1)
+		fmt.Printf("⚠️  预取失败 %s: %v\n", target.Path, result.err)
+		return
+	}
+	statusCode = result.statusCode
+
+	if lookup.CompletePrefetch(c, result) {
+		p.stored.Add(1)
+	} else if result.statusCode >= http.StatusBadRequest {
+		p.failed.Add(1)
+	}
+}
+
+// Stats 获取预取统计
+func (p *Prefetcher) Stats() PrefetchStats {
+	if p == nil {
+		return PrefetchStats{}
+	}
+	return PrefetchStats{
+		Enabled:   true,
+		Threshold: p.threshold,
+		Routes:    p.routes,
+		Issued:    p.issued.Load(),
+		Stored:    p.stored.Load(),
+		Skipped:   p.skipped.Load(),
+		Failed:    p.failed.Load(),
+	}
+}
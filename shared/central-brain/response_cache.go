@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/szjason72/zervigo/shared/core/shared"
+)
+
+// 上游服务用于控制网关缓存的响应头（不会透传给客户端）
+const (
+	headerCacheScope      = "X-Cache-Scope"      // user（默认）或 role：同一角色与权限集合的用户共享缓存
+	headerCacheTags       = "X-Cache-Tags"       // 逗号分隔的失效标签，如 "job:12,jobs"
+	headerCacheInvalidate = "X-Cache-Invalidate" // 写请求响应中携带，网关据此失效对应标签
+	headerCacheStatus     = "X-Cache"            // 返回给客户端的缓存状态：HIT、MISS、REVALIDATED
+)
+
+const (
+	cacheScopeUser = "user"
+	cacheScopeRole = "role"
+)
+
+// CachedResponse 缓存的上游响应
+type CachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	ETag       string      `json:"etag,omitempty"`
+	StoredAt   time.Time   `json:"stored_at"`
+	FreshUntil time.Time   `json:"fresh_until"`
+	Prefetched bool        `json:"prefetched,omitempty"` // 由预测预取写入
+}
+
+// fresh 是否仍在上游声明的新鲜期内
+func (r *CachedResponse) fresh(now time.Time) bool {
+	return now.Before(r.FreshUntil)
+}
+
+// cacheVariant 上游对某个URL声明的缓存变体规则
+type cacheVariant struct {
+	Scope string   `json:"scope"`
+	Vary  []string `json:"vary,omitempty"`
+}
+
+// cacheIdentity 请求方身份：用户范围与角色范围的缓存分区
+type cacheIdentity struct {
+	user string
+	role string
+}
+
+// ResponseCache 网关响应缓存
+// 只缓存上游通过 Cache-Control/ETag 明确允许的GET响应，缓存键包含路径、查询参数、Accept系列请求头、
+// 上游 Vary 声明的请求头以及请求方身份（用户ID，或上游声明 X-Cache-Scope: role 时为角色与权限集合），
+// 因此一个用户的响应不会返回给另一个权限不同的用户。
+type ResponseCache struct {
+	store            *responseCacheStore
+	maxBodyBytes     int
+	revalidateWindow time.Duration // 过期后保留带ETag条目的时长，期间以条件请求向上游重新验证
+	redisEnabled     bool
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	revalidated atomic.Int64
+	stores      atomic.Int64
+	invalidated atomic.Int64
+
+	// 预取条目的使用情况：键 -> 过期时间，首次命中或过期后移除
+	prefetchMu     sync.Mutex
+	prefetched     map[string]time.Time
+	prefetchStored atomic.Int64
+	prefetchHits   atomic.Int64
+	prefetchWasted atomic.Int64
+}
+
+// ResponseCacheStats 缓存统计
+type ResponseCacheStats struct {
+	Entries      int     `json:"entries"`
+	Hits         int64   `json:"hits"`
+	Misses       int64   `json:"misses"`
+	Revalidated  int64   `json:"revalidated"`
+	Stores       int64   `json:"stores"`
+	Invalidated  int64   `json:"invalidated"`
+	HitRate      float64 `json:"hit_rate"`
+	RedisEnabled bool    `json:"redis_enabled"`
+
+	PrefetchStored  int64   `json:"prefetch_stored"`
+	PrefetchHits    int64   `json:"prefetch_hits"`   // 被用户请求使用过的预取条目数
+	PrefetchWasted  int64   `json:"prefetch_wasted"` // 过期前未被使用的预取条目数
+	PrefetchHitRate float64 `json:"prefetch_hit_rate"`
+}
+
+// NewResponseCache 根据配置创建响应缓存；未启用时返回nil
+func NewResponseCache(config *shared.Config) *ResponseCache {
+	if !config.ResponseCache.Enabled {
+		return nil
+	}
+
+	var redisClient *redis.Client
+	if config.ResponseCache.RedisEnabled && config.Database.Redis.Host != "" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", config.Database.Redis.Host, config.Database.Redis.Port),
+			Password: config.Database.Redis.Password,
+			DB:       config.Database.Redis.DB,
+		})
+		fmt.Printf("✅ 响应缓存启用Redis二级缓存\n")
+	}
+
+	cache := &ResponseCache{
+		store:            newResponseCacheStore(config.ResponseCache.MaxEntries, redisClient),
+		maxBodyBytes:     config.ResponseCache.MaxBodyBytes,
+		revalidateWindow: time.Duration(config.ResponseCache.RevalidateWindow) * time.Second,
+		redisEnabled:     redisClient != nil,
+		prefetched:       make(map[string]time.Time),
+	}
+	cache.store.SubscribeInvalidations(context.Background())
+	return cache
+}
+
+// cacheLookup 单次请求的缓存查找状态
+type cacheLookup struct {
+	cache    *ResponseCache
+	service  ServiceProxy
+	baseKey  string
+	identity cacheIdentity
+	key      string          // 已知变体时的响应键
+	stale    *CachedResponse // 需要向上游重新验证的条目
+}
+
+// Begin 查找请求对应的缓存；命中新鲜条目时直接写回响应并返回served=true。
+// 请求不可缓存时返回nil。
+func (rc *ResponseCache) Begin(c *gin.Context, service ServiceProxy, userToken string) (lookup *cacheLookup, served bool) {
+	if rc == nil || c.Request.Method != http.MethodGet || c.Request.Header.Get("Range") != "" {
+		return nil, false
+	}
+	if !identityVerified(c, userToken) {
+		return nil, false
+	}
+	requestCC := parseCacheControl(c.Request.Header.Get("Cache-Control"))
+	if requestCC.has("no-store") {
+		return nil, false
+	}
+
+	lookup, response := rc.find(c, service)
+	if response == nil {
+		rc.misses.Add(1)
+		return lookup, false
+	}
+
+	// 客户端要求重新验证时不直接使用新鲜条目
+	maxAge, hasMaxAge := requestCC.seconds("max-age")
+	forceRevalidate := requestCC.has("no-cache") || (hasMaxAge && maxAge == 0)
+	if response.fresh(time.Now()) && !forceRevalidate {
+		rc.hits.Add(1)
+		rc.markPrefetchUsed(lookup.key)
+		writeCachedResponse(c, response, "HIT")
+		return lookup, true
+	}
+
+	rc.misses.Add(1)
+	if response.ETag != "" {
+		lookup.stale = response
+	}
+	return lookup, false
+}
+
+// find 按变体索引定位响应条目，不存在时response为nil
+func (rc *ResponseCache) find(c *gin.Context, service ServiceProxy) (*cacheLookup, *CachedResponse) {
+	lookup := &cacheLookup{
+		cache:    rc,
+		service:  service,
+		baseKey:  cacheBaseKey(c.Request),
+		identity: requestIdentity(c),
+	}
+
+	ctx := c.Request.Context()
+	variantEntry := rc.store.Get(ctx, lookup.variantKey())
+	if variantEntry == nil || variantEntry.Variant == nil {
+		return lookup, nil
+	}
+	lookup.key = lookup.responseKey(c.Request, variantEntry.Variant)
+	entry := rc.store.Get(ctx, lookup.key)
+	if entry == nil || entry.Response == nil {
+		return lookup, nil
+	}
+	return lookup, entry.Response
+}
+
+// PrepareUpstream 调整上游请求头：去掉客户端的条件请求头（缓存需要完整响应），
+// 存在待验证条目时改用其ETag发起条件请求
+func (l *cacheLookup) PrepareUpstream(header http.Header) {
+	header.Del("If-None-Match")
+	header.Del("If-Modified-Since")
+	if l.stale != nil {
+		header.Set("If-None-Match", l.stale.ETag)
+	}
+}
+
+// Complete 处理上游响应：304时续期并返回缓存内容；可缓存时写入缓存。
+// 返回true表示响应已由缓存写回客户端。
+func (l *cacheLookup) Complete(c *gin.Context, result upstreamResult) bool {
+	if refreshed := l.revalidate(c, result); refreshed != nil {
+		l.cache.revalidated.Add(1)
+		l.cache.markPrefetchUsed(l.key)
+		writeCachedResponse(c, refreshed, "REVALIDATED")
+		return true
+	}
+
+	c.Header(headerCacheStatus, "MISS")
+	response := l.storeResult(c, result, false)
+	if response == nil {
+		return false
+	}
+
+	// 客户端携带的ETag与上游最新响应一致时返回304
+	if response.ETag != "" && etagMatches(c.Request.Header.Get("If-None-Match"), response.ETag) {
+		writeCachedResponse(c, response, "MISS")
+		return true
+	}
+	return false
+}
+
+// CompletePrefetch 保存预取得到的上游响应（不写回客户端），返回是否写入了缓存
+func (l *cacheLookup) CompletePrefetch(c *gin.Context, result upstreamResult) bool {
+	if refreshed := l.revalidate(c, result); refreshed != nil {
+		return true
+	}
+	return l.storeResult(c, result, true) != nil
+}
+
+// revalidate 上游对条件请求返回304时续期待验证条目
+func (l *cacheLookup) revalidate(c *gin.Context, result upstreamResult) *CachedResponse {
+	if result.statusCode != http.StatusNotModified || l.stale == nil {
+		return nil
+	}
+	now := time.Now()
+	refreshed := *l.stale
+	freshness, _ := responseFreshness(result.header)
+	refreshed.StoredAt = now
+	refreshed.FreshUntil = now.Add(freshness)
+	l.store(c, &refreshed, variantFromHeader(l.stale.Header), tagsFromHeader(l.service, c.Request.URL.Path, l.stale.Header))
+	return &refreshed
+}
+
+// storeResult 上游响应可缓存时写入缓存并返回条目
+func (l *cacheLookup) storeResult(c *gin.Context, result upstreamResult, prefetched bool) *CachedResponse {
+	rc := l.cache
+	if result.statusCode != http.StatusOK || len(result.body) > rc.maxBodyBytes {
+		return nil
+	}
+	if result.header.Get("Set-Cookie") != "" || hasVaryStar(result.header) {
+		return nil
+	}
+	freshness, ok := responseFreshness(result.header)
+	etag := result.header.Get("ETag")
+	if !ok || (freshness == 0 && etag == "") {
+		return nil
+	}
+
+	now := time.Now()
+	response := &CachedResponse{
+		StatusCode: result.statusCode,
+		Header:     result.header.Clone(),
+		Body:       result.body,
+		ETag:       etag,
+		StoredAt:   now,
+		FreshUntil: now.Add(freshness),
+		Prefetched: prefetched,
+	}
+	expiresAt := l.store(c, response, variantFromHeader(result.header), tagsFromHeader(l.service, c.Request.URL.Path, result.header))
+	if expiresAt.IsZero() {
+		return nil
+	}
+	rc.stores.Add(1)
+	if prefetched {
+		rc.trackPrefetched(l.key, expiresAt)
+	}
+	return response
+}
+
+// store 写入变体索引与响应条目，返回条目过期时间（未写入时为零值）
+func (l *cacheLookup) store(c *gin.Context, response *CachedResponse, variant *cacheVariant, tags []string) time.Time {
+	expiresAt := response.FreshUntil
+	if response.ETag != "" {
+		expiresAt = expiresAt.Add(l.cache.revalidateWindow)
+	}
+	if !expiresAt.After(time.Now()) {
+		return time.Time{}
+	}
+
+	ctx := c.Request.Context()
+	l.key = l.responseKey(c.Request, variant)
+	l.cache.store.Set(ctx, l.variantKey(), &cacheEntry{Variant: variant, Tags: tags, ExpiresAt: expiresAt})
+	l.cache.store.Set(ctx, l.key, &cacheEntry{Response: response, Tags: tags, ExpiresAt: expiresAt})
+	return expiresAt
+}
+
+func (l *cacheLookup) variantKey() string {
+	return "v:" + hashKey(l.baseKey)
+}
+
+// responseKey 响应条目的缓存键
+func (l *cacheLookup) responseKey(r *http.Request, variant *cacheVariant) string {
+	var builder strings.Builder
+	builder.WriteString(l.baseKey)
+	builder.WriteString("\n")
+	if variant.Scope == cacheScopeRole {
+		builder.WriteString(l.identity.role)
+	} else {
+		builder.WriteString(l.identity.user)
+	}
+	for _, name := range []string{"Accept", "Accept-Encoding", "Accept-Language"} {
+		builder.WriteString("\n" + name + "=" + r.Header.Get(name))
+	}
+	for _, name := range variant.Vary {
+		builder.WriteString("\n" + name + "=" + strings.Join(r.Header.Values(name), ","))
+	}
+	return "r:" + hashKey(builder.String())
+}
+
+// InvalidateAfterWrite 写请求成功后失效该路径及其上级集合路径的缓存，并处理上游的 X-Cache-Invalidate 声明
+func (rc *ResponseCache) InvalidateAfterWrite(c *gin.Context, result upstreamResult) {
+	if rc == nil || result.statusCode >= http.StatusBadRequest {
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+
+	requestPath := path.Clean(c.Request.URL.Path)
+	tags := []string{"path:" + requestPath, "path:" + path.Dir(requestPath)}
+	tags = append(tags, splitTags(result.header.Get(headerCacheInvalidate))...)
+	rc.Invalidate(c.Request.Context(), tags...)
+}
+
+// Invalidate 按标签失效缓存，返回本副本删除的条目数
+func (rc *ResponseCache) Invalidate(ctx context.Context, tags ...string) int {
+	if rc == nil || len(tags) == 0 {
+		return 0
+	}
+	removed := rc.store.InvalidateTags(ctx, tags)
+	rc.invalidated.Add(int64(removed))
+	return removed
+}
+
+// HasPath 本地是否缓存了该路径的响应
+func (rc *ResponseCache) HasPath(requestPath string) bool {
+	if rc == nil {
+		return false
+	}
+	return rc.store.HasTag("path:" + path.Clean(requestPath))
+}
+
+// Fresh 是否已有可直接使用的新鲜缓存（预取前检查，避免重复请求上游）
+func (rc *ResponseCache) Fresh(c *gin.Context, service ServiceProxy, userToken string) (*cacheLookup, bool) {
+	if rc == nil || !identityVerified(c, userToken) {
+		return nil, false
+	}
+	lookup, response := rc.find(c, service)
+	if response == nil {
+		return lookup, false
+	}
+	if response.fresh(time.Now()) {
+		return lookup, true
+	}
+	if response.ETag != "" {
+		lookup.stale = response
+	}
+	return lookup, false
+}
+
+// trackPrefetched 记录预取写入的条目，超出容量时清理过期条目并计为未使用
+func (rc *ResponseCache) trackPrefetched(key string, expiresAt time.Time) {
+	rc.prefetchStored.Add(1)
+
+	rc.prefetchMu.Lock()
+	defer rc.prefetchMu.Unlock()
+	rc.prefetched[key] = expiresAt
+	if len(rc.prefetched) > rc.store.maxEntries {
+		rc.prunePrefetchedLocked(time.Now())
+	}
+}
+
+// markPrefetchUsed 预取条目首次被用户请求使用时计入命中
+func (rc *ResponseCache) markPrefetchUsed(key string) {
+	if key == "" {
+		return
+	}
+	rc.prefetchMu.Lock()
+	defer rc.prefetchMu.Unlock()
+	if _, ok := rc.prefetched[key]; ok {
+		delete(rc.prefetched, key)
+		rc.prefetchHits.Add(1)
+	}
+}
+
+func (rc *ResponseCache) prunePrefetchedLocked(now time.Time) {
+	for key, expiresAt := range rc.prefetched {
+		if !now.Before(expiresAt) {
+			delete(rc.prefetched, key)
+			rc.prefetchWasted.Add(1)
+		}
+	}
+}
+
+// Stats 获取缓存统计
+func (rc *ResponseCache) Stats() ResponseCacheStats {
+	if rc == nil {
+		return ResponseCacheStats{}
+	}
+	stats := ResponseCacheStats{
+		Entries:      rc.store.Len(),
+		Hits:         rc.hits.Load(),
+		Misses:       rc.misses.Load(),
+		Revalidated:  rc.revalidated.Load(),
+		Stores:       rc.stores.Load(),
+		Invalidated:  rc.invalidated.Load(),
+		RedisEnabled: rc.redisEnabled,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.Revalidated) / float64(total)
+	}
+
+	rc.prefetchMu.Lock()
+	rc.prunePrefetchedLocked(time.Now())
+	rc.prefetchMu.Unlock()
+	stats.PrefetchStored = rc.prefetchStored.Load()
+	stats.PrefetchHits = rc.prefetchHits.Load()
+	stats.PrefetchWasted = rc.prefetchWasted.Load()
+	if settled := stats.PrefetchHits + stats.PrefetchWasted; settled > 0 {
+		stats.PrefetchHitRate = float64(stats.PrefetchHits) / float64(settled)
+	}
+	return stats
+}
+
+// writeCachedResponse 返回缓存的响应，客户端ETag匹配时返回304
+func writeCachedResponse(c *gin.Context, response *CachedResponse, status string) {
+	for key, values := range response.Header {
+		if isInternalCacheHeader(key) || isHopHeader(key) {
+			continue
+		}
+		c.Writer.Header()[key] = append([]string(nil), values...)
+	}
+	c.Header("Age", strconv.Itoa(int(time.Since(response.StoredAt).Seconds())))
+	c.Header(headerCacheStatus, status)
+
+	if response.ETag != "" && etagMatches(c.Request.Header.Get("If-None-Match"), response.ETag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(response.StatusCode, response.Header.Get("Content-Type"), response.Body)
+}
+
+// identityVerified 携带令牌的请求只有经网关令牌校验后才能使用缓存：
+// 未启用校验（或HS256旧令牌放行）时无法确认令牌未被吊销或伪造，缓存新鲜期内会绕过下游的身份校验
+func identityVerified(c *gin.Context, userToken string) bool {
+	if userToken == "" {
+		return true
+	}
+	_, exists := c.Get("user_id")
+	return exists
+}
+
+// requestIdentity 计算请求方身份分区：已校验的用户ID与角色/权限，未携带令牌时为匿名
+func requestIdentity(c *gin.Context) cacheIdentity {
+	if userID, exists := c.Get("user_id"); exists {
+		permissions := append([]string(nil), c.GetStringSlice("permissions")...)
+		sort.Strings(permissions)
+		return cacheIdentity{
+			user: fmt.Sprintf("user:%v", userID),
+			role: "role:" + c.GetString("role") + ":" + strings.Join(permissions, ","),
+		}
+	}
+	return cacheIdentity{user: "anonymous", role: "anonymous"}
+}
+
+// cacheBaseKey 方法、路径与规范化（按参数名排序）的查询串
+func cacheBaseKey(r *http.Request) string {
+	return r.Method + " " + r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// variantFromHeader 从上游响应头解析共享范围与Vary
+func variantFromHeader(header http.Header) *cacheVariant {
+	variant := &cacheVariant{Scope: cacheScopeUser}
+	if strings.EqualFold(strings.TrimSpace(header.Get(headerCacheScope)), cacheScopeRole) &&
+		!parseCacheControl(header.Get("Cache-Control")).has("private") {
+		variant.Scope = cacheScopeRole
+	}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				variant.Vary = append(variant.Vary, name)
+			}
+		}
+	}
+	sort.Strings(variant.Vary)
+	return variant
+}
+
+// tagsFromHeader 条目标签：服务、路径以及上游声明的标签
+func tagsFromHeader(service ServiceProxy, requestPath string, header http.Header) []string {
+	tags := []string{"service:" + service.ServiceName, "path:" + path.Clean(requestPath)}
+	return append(tags, splitTags(header.Get(headerCacheTags))...)
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// responseFreshness 根据上游 Cache-Control 计算新鲜期；ok=false 表示禁止缓存
+func responseFreshness(header http.Header) (freshness time.Duration, ok bool) {
+	cc := parseCacheControl(header.Get("Cache-Control"))
+	if cc.has("no-store") {
+		return 0, false
+	}
+	if cc.has("no-cache") {
+		return 0, true
+	}
+	if seconds, exists := cc.seconds("s-maxage"); exists {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if seconds, exists := cc.seconds("max-age"); exists {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, true
+}
+
+// cacheControl 解析后的 Cache-Control 指令
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	directives := make(cacheControl)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (int, bool) {
+	value, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return seconds, true
+}
+
+func hasVaryStar(header http.Header) bool {
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// etagMatches 按弱比较判断 If-None-Match 是否匹配
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == target {
+			return true
+		}
+	}
+	return false
+}
+
+// isInternalCacheHeader 上游控制缓存的内部响应头
+func isInternalCacheHeader(key string) bool {
+	return strings.EqualFold(key, headerCacheScope) ||
+		strings.EqualFold(key, headerCacheTags) ||
+		strings.EqualFold(key, headerCacheInvalidate)
+}
+
+func isHopHeader(key string) bool {
+	for _, name := range []string{"Transfer-Encoding", "Content-Length", "Connection", "Server", "Set-Cookie"} {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
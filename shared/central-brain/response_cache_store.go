@@ -0,0 +1,253 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// responseCacheInvalidateChannel 多副本之间广播标签失效的Redis频道
+const responseCacheInvalidateChannel = "central-brain:respcache:invalidate"
+
+// cacheEntry 缓存条目：变体索引（按URL记录上游声明的Vary与共享范围）或响应
+type cacheEntry struct {
+	Variant   *cacheVariant   `json:"variant,omitempty"`
+	Response  *CachedResponse `json:"response,omitempty"`
+	Tags      []string        `json:"tags,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// responseCacheStore 两级缓存：进程内LRU，配置Redis时作为共享的第二级
+type responseCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      map[string]*list.Element
+	order      *list.List                     // 前端为最近使用
+	tagIndex   map[string]map[string]struct{} // 标签 -> 键
+
+	redis     *redis.Client
+	keyPrefix string
+}
+
+type lruItem struct {
+	key   string
+	entry *cacheEntry
+}
+
+// newResponseCacheStore 创建缓存存储，redisClient为nil时仅使用进程内LRU
+func newResponseCacheStore(maxEntries int, redisClient *redis.Client) *responseCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &responseCacheStore{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		tagIndex:   make(map[string]map[string]struct{}),
+		redis:      redisClient,
+		keyPrefix:  "central-brain:respcache:",
+	}
+}
+
+// Get 读取条目：先查LRU，未命中时回源Redis并回填LRU
+func (s *responseCacheStore) Get(ctx context.Context, key string) *cacheEntry {
+	now := time.Now()
+
+	s.mu.Lock()
+	if element, ok := s.items[key]; ok {
+		item := element.Value.(*lruItem)
+		if now.Before(item.entry.ExpiresAt) {
+			s.order.MoveToFront(element)
+			s.mu.Unlock()
+			return item.entry
+		}
+		s.removeElement(element)
+	}
+	s.mu.Unlock()
+
+	if s.redis == nil {
+		return nil
+	}
+	data, err := s.redis.Get(ctx, s.keyPrefix+key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			fmt.Printf("⚠️  读取Redis响应缓存失败: %v\n", err)
+		}
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || !now.Before(entry.ExpiresAt) {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.putLocal(key, &entry)
+	s.mu.Unlock()
+	return &entry
+}
+
+// Set 写入条目（同时写入Redis，标签集合用于跨副本失效）
+func (s *responseCacheStore) Set(ctx context.Context, key string, entry *cacheEntry) {
+	ttl := time.Until(entry.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.putLocal(key, entry)
+	s.mu.Unlock()
+
+	if s.redis == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.keyPrefix+key, data, ttl)
+		for _, tag := range entry.Tags {
+			tagKey := s.tagKey(tag)
+			pipe.SAdd(ctx, tagKey, key)
+			// 标签集合只会比其中最长的条目活得更久，过期键在失效时被DEL忽略
+			pipe.Expire(ctx, tagKey, ttl+time.Hour)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("⚠️  写入Redis响应缓存失败: %v\n", err)
+	}
+}
+
+// Delete 删除单个条目
+func (s *responseCacheStore) Delete(ctx context.Context, key string) {
+	s.mu.Lock()
+	if element, ok := s.items[key]; ok {
+		s.removeElement(element)
+	}
+	s.mu.Unlock()
+
+	if s.redis != nil {
+		s.redis.Del(ctx, s.keyPrefix+key)
+	}
+}
+
+// InvalidateTags 删除带有任一标签的条目并通知其他副本，返回本地删除的条目数
+func (s *responseCacheStore) InvalidateTags(ctx context.Context, tags []string) int {
+	removed := s.invalidateLocal(tags)
+	if s.redis == nil || len(tags) == 0 {
+		return removed
+	}
+
+	for _, tag := range tags {
+		tagKey := s.tagKey(tag)
+		keys, err := s.redis.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			fmt.Printf("⚠️  读取缓存标签 %s 失败: %v\n", tag, err)
+			continue
+		}
+		redisKeys := make([]string, 0, len(keys)+1)
+		for _, key := range keys {
+			redisKeys = append(redisKeys, s.keyPrefix+key)
+		}
+		redisKeys = append(redisKeys, tagKey)
+		if err := s.redis.Del(ctx, redisKeys...).Err(); err != nil {
+			fmt.Printf("⚠️  删除缓存标签 %s 失败: %v\n", tag, err)
+		}
+	}
+
+	if err := s.redis.Publish(ctx, responseCacheInvalidateChannel, strings.Join(tags, "\n")).Err(); err != nil {
+		fmt.Printf("⚠️  广播缓存失效失败: %v\n", err)
+	}
+	return removed
+}
+
+// SubscribeInvalidations 订阅其他副本广播的标签失效，清理本地LRU
+func (s *responseCacheStore) SubscribeInvalidations(ctx context.Context) {
+	if s.redis == nil {
+		return
+	}
+	pubsub := s.redis.Subscribe(ctx, responseCacheInvalidateChannel)
+	go func() {
+		defer pubsub.Close()
+		for message := range pubsub.Channel() {
+			s.invalidateLocal(strings.Split(message.Payload, "\n"))
+		}
+	}()
+}
+
+// HasTag 本地是否存在带有该标签的条目
+func (s *responseCacheStore) HasTag(tag string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tagIndex[tag]) > 0
+}
+
+// Len 本地条目数
+func (s *responseCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *responseCacheStore) invalidateLocal(tags []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, tag := range tags {
+		for key := range s.tagIndex[tag] {
+			if element, ok := s.items[key]; ok {
+				s.removeElement(element)
+				removed++
+			}
+		}
+		delete(s.tagIndex, tag)
+	}
+	return removed
+}
+
+// putLocal 写入LRU并按容量淘汰（调用方持有锁）
+func (s *responseCacheStore) putLocal(key string, entry *cacheEntry) {
+	if element, ok := s.items[key]; ok {
+		s.removeElement(element)
+	}
+	s.items[key] = s.order.PushFront(&lruItem{key: key, entry: entry})
+	for _, tag := range entry.Tags {
+		keys, ok := s.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tagIndex[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for s.order.Len() > s.maxEntries {
+		s.removeElement(s.order.Back())
+	}
+}
+
+// removeElement 从LRU与标签索引中移除（调用方持有锁）
+func (s *responseCacheStore) removeElement(element *list.Element) {
+	item := element.Value.(*lruItem)
+	s.order.Remove(element)
+	delete(s.items, item.key)
+	for _, tag := range item.entry.Tags {
+		if keys, ok := s.tagIndex[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(s.tagIndex, tag)
+			}
+		}
+	}
+}
+
+func (s *responseCacheStore) tagKey(tag string) string {
+	return s.keyPrefix + "tag:" + tag
+}
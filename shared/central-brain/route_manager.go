@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/szjason72/zervigo/shared/core/service/registry"
+	"github.com/szjason72/zervigo/shared/core/shared"
+
+	"github.com/szjason72/zervigo/shared/central-brain/middleware"
+	"github.com/szjason72/zervigo/shared/central-brain/router"
+)
+
+// 服务实例元数据键（服务注册时通过Metadata/Consul Meta声明网关路由）
+const (
+	metaPathPrefix   = "path_prefix"
+	metaTargetPrefix = "target_prefix"
+	metaStreaming    = "streaming"
+	metaBreakerKey   = "circuit_breaker_key"
+)
+
+// RouteTable 网关路由表快照（构建后只读，刷新时整体替换）
+type RouteTable struct {
+	Proxies   []ServiceProxy                     // 按PathPrefix长度降序排列
+	Instances map[string][]*registry.ServiceInfo // 服务名 -> 健康实例
+	Version   int64
+	BuiltAt   time.Time
+}
+
+// Match 按最长前缀匹配路由
+func (t *RouteTable) Match(method, requestPath string) (ServiceProxy, bool) {
+	for _, proxy := range t.Proxies {
+		if proxy.Method != "" && !strings.EqualFold(proxy.Method, method) {
+			continue
+		}
+		if requestPath == proxy.PathPrefix || strings.HasPrefix(requestPath, strings.TrimSuffix(proxy.PathPrefix, "/")+"/") {
+			return proxy, true
+		}
+	}
+	return ServiceProxy{}, false
+}
+
+// RouteManager 动态路由管理器
+// 路由来源（优先级从低到高，同前缀后者覆盖前者）：
+//  1. 配置文件中的静态服务端口（兜底）
+//  2. 服务注册中心（本地SimpleServiceRegistry / Consul）中实例声明的path_prefix元数据
+//  3. Router Service的RouteConfig路由配置表
+//
+// 服务实例来自本地注册中心与Consul，请求时经registry.LoadBalancer选择实例。
+type RouteManager struct {
+	mu    sync.RWMutex
+	table *RouteTable
+
+	staticProxies   []ServiceProxy
+	localRegistry   *registry.SimpleServiceRegistry
+	consulRegistry  *registry.ConsulRegistry
+	routerClient    *router.RouterClient
+	routerEnabled   bool
+	refreshInterval time.Duration
+	strategy        string
+
+	// 上次成功拉取的数据（数据源暂时不可用时沿用）
+	lastRouterRoutes    []router.RouteConfig
+	lastConsulInstances map[string][]*registry.ServiceInfo
+
+	balancerMu sync.Mutex
+	balancers  map[string]*registry.LoadBalancer
+}
+
+// NewRouteManager 创建动态路由管理器
+func NewRouteManager(config *shared.Config, routerClient *router.RouterClient, staticProxies []ServiceProxy) *RouteManager {
+	localRegistry, _ := registry.NewSimpleServiceRegistry(&registry.RegistryConfig{})
+
+	var consulRegistry *registry.ConsulRegistry
+	if config.ServiceDiscovery.Enabled && config.ServiceDiscovery.ConsulURL != "" {
+		cr, err := registry.NewConsulRegistry(config.ServiceDiscovery.ConsulURL)
+		if err != nil {
+			fmt.Printf("⚠️  Consul注册中心初始化失败（仅使用本地注册中心）: %v\n", err)
+		} else {
+			consulRegistry = cr
+		}
+	}
+
+	refreshInterval := time.Duration(config.ServiceDiscovery.RefreshInterval) * time.Second
+	if refreshInterval <= 0 {
+		refreshInterval = 30 * time.Second
+	}
+
+	strategy := config.ServiceDiscovery.LoadBalanceStrategy
+	if _, err := registry.NewStrategy(strategy, nil); err != nil {
+		fmt.Printf("⚠️  未知的负载均衡策略 %q，使用round_robin\n", strategy)
+		strategy = "round_robin"
+	}
+
+	rm := &RouteManager{
+		staticProxies:       staticProxies,
+		localRegistry:       localRegistry,
+		consulRegistry:      consulRegistry,
+		routerClient:        routerClient,
+		routerEnabled:       config.ServiceDiscovery.RouterRoutesEnabled,
+		refreshInterval:     refreshInterval,
+		strategy:            strategy,
+		lastConsulInstances: make(map[string][]*registry.ServiceInfo),
+		balancers:           make(map[string]*registry.LoadBalancer),
+	}
+
+	// 先用静态路由构建初始路由表，保证启动即可服务
+	rm.swap(rm.buildTable(nil, nil, 1))
+	return rm
+}
+
+// Start 启动后台刷新循环
+func (rm *RouteManager) Start(ctx context.Context) {
+	rm.Refresh()
+
+	go func() {
+		ticker := time.NewTicker(rm.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rm.Refresh()
+			}
+		}
+	}()
+}
+
+// Refresh 从各数据源重新构建路由表并热替换
+func (rm *RouteManager) Refresh() *RouteTable {
+	routerRoutes := rm.fetchRouterRoutes()
+	consulInstances := rm.fetchConsulInstances()
+
+	rm.mu.RLock()
+	version := rm.table.Version + 1
+	rm.mu.RUnlock()
+
+	table := rm.buildTable(routerRoutes, consulInstances, version)
+	rm.swap(table)
+	return table
+}
+
+// Table 获取当前路由表
+func (rm *RouteManager) Table() *RouteTable {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.table
+}
+
+// Match 在当前路由表中匹配请求
+func (rm *RouteManager) Match(method, requestPath string) (ServiceProxy, bool) {
+	return rm.Table().Match(method, requestPath)
+}
+
+// ResolveBaseURL 通过负载均衡选择服务实例，返回实例地址和释放函数
+// 没有已发现的实例时使用路由自带的BaseURL
+func (rm *RouteManager) ResolveBaseURL(service ServiceProxy) (string, func()) {
+	instances := rm.Table().Instances[service.ServiceName]
+	if len(instances) == 0 {
+		return service.BaseURL, func() {}
+	}
+
+	balancer := rm.getBalancer(service.ServiceName)
+	instance := balancer.Select(instances)
+	if instance == nil {
+		return service.BaseURL, func() {}
+	}
+
+	release := func() {}
+	if lcs, ok := balancer.Strategy().(*registry.LeastConnectionsStrategy); ok {
+		release = func() { lcs.ReleaseConnection(instance.ID) }
+	}
+
+	return instanceBaseURL(instance), release
+}
+
+// InstanceCount 获取服务当前已发现的健康实例数
+func (rm *RouteManager) InstanceCount(serviceName string) int {
+	return len(rm.Table().Instances[serviceName])
+}
+
+// RegisterInstance 向本地注册中心注册服务实例，并立即刷新路由表
+func (rm *RouteManager) RegisterInstance(instance *registry.ServiceInfo) error {
+	if instance.Endpoint == "" && instance.Address != "" && instance.Port > 0 {
+		instance.Endpoint = fmt.Sprintf("%s:%d", instance.Address, instance.Port)
+	}
+	if instance.Health == nil {
+		instance.Health = &registry.HealthStatus{Status: "healthy", Timestamp: time.Now()}
+	}
+	if err := rm.localRegistry.Register(instance); err != nil {
+		return err
+	}
+	rm.rebuildFromCache()
+	return nil
+}
+
+// DeregisterInstance 从本地注册中心注销服务实例，并立即刷新路由表
+func (rm *RouteManager) DeregisterInstance(instanceID string) error {
+	if _, err := rm.localRegistry.GetService(instanceID); err != nil {
+		return err
+	}
+	if err := rm.localRegistry.Deregister(instanceID); err != nil {
+		return err
+	}
+	rm.rebuildFromCache()
+	return nil
+}
+
+// rebuildFromCache 使用缓存的远端数据重建路由表（本地注册中心变化时使用）
+func (rm *RouteManager) rebuildFromCache() {
+	rm.mu.RLock()
+	routerRoutes := rm.lastRouterRoutes
+	consulInstances := rm.lastConsulInstances
+	version := rm.table.Version + 1
+	rm.mu.RUnlock()
+
+	rm.swap(rm.buildTable(routerRoutes, consulInstances, version))
+}
+
+// swap 原子替换路由表
+func (rm *RouteManager) swap(table *RouteTable) {
+	rm.mu.Lock()
+	rm.table = table
+	rm.mu.Unlock()
+}
+
+// fetchRouterRoutes 从Router Service拉取路由配置
+func (rm *RouteManager) fetchRouterRoutes() []router.RouteConfig {
+	if !rm.routerEnabled || rm.routerClient == nil {
+		return nil
+	}
+
+	routes, err := rm.routerClient.GetAllRoutes()
+	if err != nil {
+		fmt.Printf("⚠️  拉取Router Service路由配置失败（沿用上次结果）: %v\n", err)
+		rm.mu.RLock()
+		defer rm.mu.RUnlock()
+		return rm.lastRouterRoutes
+	}
+
+	rm.mu.Lock()
+	rm.lastRouterRoutes = routes
+	rm.mu.Unlock()
+	return routes
+}
+
+// fetchConsulInstances 从Consul拉取所有健康服务实例
+func (rm *RouteManager) fetchConsulInstances() map[string][]*registry.ServiceInfo {
+	if rm.consulRegistry == nil {
+		return nil
+	}
+
+	names, err := rm.consulRegistry.ListServices()
+	if err != nil {
+		fmt.Printf("⚠️  拉取Consul服务列表失败（沿用上次结果）: %v\n", err)
+		rm.mu.RLock()
+		defer rm.mu.RUnlock()
+		return rm.lastConsulInstances
+	}
+
+	instances := make(map[string][]*registry.ServiceInfo)
+	for name := range names {
+		if name == "consul" {
+			continue
+		}
+		serviceInstances, err := rm.consulRegistry.GetServiceInstances(name)
+		if err != nil {
+			fmt.Printf("⚠️  获取Consul服务 %s 实例失败: %v\n", name, err)
+			continue
+		}
+		if len(serviceInstances) > 0 {
+			instances[name] = serviceInstances
+		}
+	}
+
+	rm.mu.Lock()
+	rm.lastConsulInstances = instances
+	rm.mu.Unlock()
+	return instances
+}
+
+// buildTable 合并各数据源构建新的路由表
+func (rm *RouteManager) buildTable(routerRoutes []router.RouteConfig, consulInstances map[string][]*registry.ServiceInfo, version int64) *RouteTable {
+	// 1. 汇总健康实例（本地注册中心 + Consul）
+	instances := make(map[string][]*registry.ServiceInfo)
+	for _, instance := range rm.localRegistry.GetServices() {
+		if instance.Health != nil && instance.Health.Status != "healthy" {
+			continue
+		}
+		instances[instance.Name] = append(instances[instance.Name], instance)
+	}
+	for name, serviceInstances := range consulInstances {
+		instances[name] = append(instances[name], serviceInstances...)
+	}
+	for name := range instances {
+		// 固定顺序，保证轮询策略在刷新前后行为稳定
+		sort.Slice(instances[name], func(i, j int) bool {
+			return instances[name][i].ID < instances[name][j].ID
+		})
+	}
+
+	// 2. 按前缀合并路由（后加入的来源覆盖先加入的）
+	proxies := make(map[string]ServiceProxy)
+	routeKey := func(p ServiceProxy) string {
+		return strings.ToUpper(p.Method) + " " + p.PathPrefix
+	}
+
+	// 动态路由沿用静态配置中按服务划分的熔断设置，同一熔断器键的配置保持一致
+	serviceBaseURLs := make(map[string]string)
+	serviceBreakerKeys := make(map[string]string)
+	servicePolicies := make(map[string]*UpstreamPolicy)
+	serviceBreakers := make(map[string]*middleware.CircuitBreakerConfig)
+	serviceHotRoutes := make(map[string][]string)
+	for _, proxy := range rm.staticProxies {
+		proxies[routeKey(proxy)] = proxy
+		if _, exists := serviceBaseURLs[proxy.ServiceName]; !exists {
+			serviceBaseURLs[proxy.ServiceName] = proxy.BaseURL
+			serviceBreakerKeys[proxy.ServiceName] = proxy.CircuitBreakerKey
+			servicePolicies[proxy.ServiceName] = proxy.Policy
+			serviceBreakers[proxy.ServiceName] = proxy.Breaker
+			serviceHotRoutes[proxy.ServiceName] = proxy.HotRoutes
+		}
+	}
+
+	for name, serviceInstances := range instances {
+		if proxy, ok := proxyFromInstanceMetadata(name, serviceInstances); ok {
+			if proxy.CircuitBreakerKey == "" {
+				proxy.CircuitBreakerKey = serviceBreakerKeys[name]
+			}
+			proxy.Breaker = serviceBreakers[name]
+			proxy.HotRoutes = serviceHotRoutes[name]
+			proxies[routeKey(proxy)] = proxy
+		}
+	}
+
+	for _, route := range routerRoutes {
+		proxy, ok := proxyFromRouteConfig(route)
+		if !ok {
+			continue
+		}
+		proxy.CircuitBreakerKey = serviceBreakerKeys[proxy.ServiceName]
+		proxy.BaseURL = serviceBaseURLs[proxy.ServiceName]
+		proxy.Policy = servicePolicies[proxy.ServiceName]
+		proxy.Breaker = serviceBreakers[proxy.ServiceName]
+		proxy.HotRoutes = serviceHotRoutes[proxy.ServiceName]
+		// Router Service不下发流式与熔断配置，沿用覆盖该路径的最长静态前缀路由的配置
+		if static, exists := rm.coveringStaticProxy(proxy.PathPrefix); exists {
+			proxy.Streaming = static.Streaming
+			proxy.FlushInterval = static.FlushInterval
+			if static.ServiceName == proxy.ServiceName {
+				proxy.Policy = static.Policy
+				proxy.Breaker = static.Breaker
+				proxy.HotRoutes = static.HotRoutes
+			}
+		}
+		if proxy.BaseURL == "" && len(instances[proxy.ServiceName]) == 0 {
+			// 既无静态地址也无已发现实例，无法转发
+			continue
+		}
+		proxies[routeKey(proxy)] = proxy
+	}
+
+	sorted := make([]ServiceProxy, 0, len(proxies))
+	for _, proxy := range proxies {
+		sorted = append(sorted, proxy)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].PathPrefix) != len(sorted[j].PathPrefix) {
+			return len(sorted[i].PathPrefix) > len(sorted[j].PathPrefix)
+		}
+		// 同一前缀时，限定方法的路由优先
+		return sorted[i].Method > sorted[j].Method
+	})
+
+	return &RouteTable{
+		Proxies:   sorted,
+		Instances: instances,
+		Version:   version,
+		BuiltAt:   time.Now(),
+	}
+}
+
+// coveringStaticProxy 查找覆盖该路径前缀的最长静态路由（前缀相同或为其上级路径）
+func (rm *RouteManager) coveringStaticProxy(pathPrefix string) (ServiceProxy, bool) {
+	var covering ServiceProxy
+	found := false
+	for _, static := range rm.staticProxies {
+		prefix := strings.TrimSuffix(static.PathPrefix, "/")
+		if pathPrefix != prefix && !strings.HasPrefix(pathPrefix, prefix+"/") {
+			continue
+		}
+		if !found || len(static.PathPrefix) > len(covering.PathPrefix) {
+			covering = static
+			found = true
+		}
+	}
+	return covering, found
+}
+
+// getBalancer 获取服务对应的负载均衡器（每个服务独立维护策略状态）
+func (rm *RouteManager) getBalancer(serviceName string) *registry.LoadBalancer {
+	rm.balancerMu.Lock()
+	defer rm.balancerMu.Unlock()
+
+	balancer, exists := rm.balancers[serviceName]
+	if !exists {
+		balancer = registry.NewLoadBalancer()
+		if strategy, err := registry.NewStrategy(rm.strategy, nil); err == nil {
+			balancer.SetStrategy(strategy)
+		}
+		rm.balancers[serviceName] = balancer
+	}
+	return balancer
+}
+
+// GetStats 获取路由表状态（供管理API使用）
+func (rm *RouteManager) GetStats() map[string]interface{} {
+	table := rm.Table()
+
+	routes := make([]map[string]interface{}, 0, len(table.Proxies))
+	for _, proxy := range table.Proxies {
+		targetPrefix := proxy.TargetPrefix
+		if targetPrefix == "" {
+			targetPrefix = proxy.PathPrefix
+		}
+		routes = append(routes, map[string]interface{}{
+			"service_name":  proxy.ServiceName,
+			"method":        proxy.Method,
+			"path_prefix":   proxy.PathPrefix,
+			"target_prefix": targetPrefix,
+			"base_url":      proxy.BaseURL,
+			"streaming":     proxy.Streaming,
+			"source":        proxy.Source,
+			"policy":        describePolicy(proxy.Policy),
+		})
+	}
+
+	instances := make(map[string][]string)
+	for name, serviceInstances := range table.Instances {
+		for _, instance := range serviceInstances {
+			instances[name] = append(instances[name], instanceBaseURL(instance))
+		}
+	}
+
+	return map[string]interface{}{
+		"version":        table.Version,
+		"built_at":       table.BuiltAt.Format(time.RFC3339),
+		"strategy":       rm.strategy,
+		"consul_enabled": rm.consulRegistry != nil,
+		"router_enabled": rm.routerEnabled,
+		"routes":         routes,
+		"instances":      instances,
+	}
+}
+
+// proxyFromInstanceMetadata 根据实例元数据生成路由（新服务注册即可接入网关）
+func proxyFromInstanceMetadata(serviceName string, instances []*registry.ServiceInfo) (ServiceProxy, bool) {
+	for _, instance := range instances {
+		pathPrefix := instance.Metadata[metaPathPrefix]
+		if pathPrefix == "" {
+			continue
+		}
+
+		streaming, _ := strconv.ParseBool(instance.Metadata[metaStreaming])
+		return ServiceProxy{
+			ServiceName:       serviceName,
+			BaseURL:           instanceBaseURL(instance),
+			PathPrefix:        pathPrefix,
+			TargetPrefix:      instance.Metadata[metaTargetPrefix],
+			CircuitBreakerKey: instance.Metadata[metaBreakerKey],
+			Streaming:         streaming,
+			Policy:            policyFromMetadata(instance.Metadata),
+			Source:            "registry",
+		}, true
+	}
+	return ServiceProxy{}, false
+}
+
+// proxyFromRouteConfig 将Router Service的路由配置转换为代理路由
+func proxyFromRouteConfig(route router.RouteConfig) (ServiceProxy, bool) {
+	if !route.IsActive || route.ServiceName == "" || route.RoutePath == "" {
+		return ServiceProxy{}, false
+	}
+	if route.RouteType != "" && route.RouteType != "api" {
+		return ServiceProxy{}, false
+	}
+
+	pathPrefix := trimRouteWildcard(route.RoutePath)
+	targetPrefix := trimRouteWildcard(route.ServiceEndpoint)
+
+	method := strings.ToUpper(route.Method)
+	if method == "*" || method == "ANY" {
+		method = ""
+	}
+
+	return ServiceProxy{
+		ServiceName:  route.ServiceName,
+		PathPrefix:   pathPrefix,
+		TargetPrefix: targetPrefix,
+		Method:       method,
+		Source:       "router",
+	}, pathPrefix != ""
+}
+
+// trimRouteWildcard 去掉路由路径末尾的通配符（/*、/**）
+func trimRouteWildcard(routePath string) string {
+	routePath = strings.TrimSuffix(routePath, "/**")
+	routePath = strings.TrimSuffix(routePath, "/*")
+	return routePath
+}
+
+// instanceBaseURL 计算服务实例的基础URL
+func instanceBaseURL(instance *registry.ServiceInfo) string {
+	endpoint := instance.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("%s:%d", instance.Address, instance.Port)
+	}
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		return strings.TrimSuffix(endpoint, "/")
+	}
+	return "http://" + strings.TrimSuffix(endpoint, "/")
+}
+
+// describePolicy 上游策略描述（供管理API使用）
+func describePolicy(policy *UpstreamPolicy) map[string]interface{} {
+	if policy == nil {
+		policy = DefaultUpstreamPolicy()
+	}
+
+	maxRetries := 0
+	var retryOn []int
+	if policy.Retry != nil {
+		maxRetries = policy.Retry.MaxRetries
+		retryOn = policy.Retry.RetryableErrors
+	}
+
+	return map[string]interface{}{
+		"timeout_ms":     policy.Timeout.Milliseconds(),
+		"max_retries":    maxRetries,
+		"retry_on":       retryOn,
+		"hedge_delay_ms": policy.HedgeDelay.Milliseconds(),
+		"max_hedges":     policy.MaxHedges,
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/szjason72/zervigo/shared/core/tracing"
+)
+
+// streamProxyRequest 流式代理请求
+// 请求体与响应体直接在客户端和上游之间管道传输，不做整体缓冲，
+// 支持大文件上传、SSE（text/event-stream）、分块响应以及WebSocket升级。
+func (cb *CentralBrain) streamProxyRequest(c *gin.Context, service ServiceProxy, targetURL string) {
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		cb.handleError(c, fmt.Errorf("解析目标地址失败: %v", err))
+		return
+	}
+
+	fmt.Printf("🌊 流式代理请求: %s -> %s\n", c.Request.URL.Path, targetURL)
+
+	// 流式请求体不可重放，上游策略中只有超时生效：作为等待响应头的首字节超时和响应体的空闲超时。
+	// 未显式配置策略的流式路由（如大文件上传）不设超时
+	request := c.Request
+	var watchdog *streamWatchdog
+	if service.Policy != nil && service.Policy.Timeout > 0 {
+		ctx, cancel := context.WithCancel(request.Context())
+		defer cancel()
+		watchdog = newStreamWatchdog(service.Policy.Timeout, cancel)
+		defer watchdog.Stop()
+		defer func() {
+			// 响应已开始写出后超时只能中断连接，仍需标记为上游错误
+			if watchdog.Expired() {
+				c.Set(contextKeyProxyError, true)
+			}
+		}()
+		request = request.WithContext(ctx)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.Host = target.Host
+
+			// 跳过客户端伪造的内部头，再由网关统一注入
+			pr.Out.Header.Del("X-Service-Token")
+			pr.Out.Header.Del("X-Service-ID")
+			cb.injectGatewayHeaders(c, pr.Out.Header)
+		},
+		Transport: tracing.NewTransport(cb.clientPool.GetTransport(service.ServiceName), cb.tracer),
+		// SSE与未知长度的响应会被立即刷新，其余按配置间隔刷新
+		FlushInterval: service.FlushInterval,
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Del("Server")
+			if watchdog != nil {
+				if resp.StatusCode == http.StatusSwitchingProtocols {
+					// 协议升级后为长连接，不再计时
+					watchdog.Stop()
+				} else {
+					watchdog.Reset()
+					resp.Body = &idleTimeoutBody{ReadCloser: resp.Body, watchdog: watchdog}
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if watchdog != nil && watchdog.Expired() {
+				err = fmt.Errorf("上游超过%v无响应: %v", watchdog.timeout, err)
+			}
+			cb.handleError(c, fmt.Errorf("请求失败: %v", err))
+		},
+	}
+
+	proxy.ServeHTTP(c.Writer, request)
+}
+
+// streamWatchdog 流式代理超时计时器：超时后取消上游请求
+type streamWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+func newStreamWatchdog(timeout time.Duration, cancel context.CancelFunc) *streamWatchdog {
+	w := &streamWatchdog{timeout: timeout}
+	w.timer = time.AfterFunc(timeout, func() {
+		w.expired.Store(true)
+		cancel()
+	})
+	return w
+}
+
+// Reset 收到上游数据后重新计时
+func (w *streamWatchdog) Reset() {
+	w.timer.Reset(w.timeout)
+}
+
+// Stop 停止计时
+func (w *streamWatchdog) Stop() {
+	w.timer.Stop()
+}
+
+// Expired 是否因超时取消了上游请求
+func (w *streamWatchdog) Expired() bool {
+	return w.expired.Load()
+}
+
+// idleTimeoutBody 响应体每次读到数据都重置空闲计时
+type idleTimeoutBody struct {
+	io.ReadCloser
+	watchdog *streamWatchdog
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.watchdog.Reset()
+	}
+	return n, err
+}
+
+// isUpgradeRequest 判断是否为协议升级请求（如WebSocket）
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
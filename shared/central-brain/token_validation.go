@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/szjason72/zervigo/shared/central-brain/utils"
+	"github.com/szjason72/zervigo/shared/core/auth"
+	"github.com/szjason72/zervigo/shared/core/shared"
+)
+
+// tokenValidationExemptPrefixes 认证相关路由不在网关拦截（登录时携带过期token不应被拒绝）
+var tokenValidationExemptPrefixes = []string{"/api/v1/auth/", "/api/auth/"}
+
+// UserTokenValidator 网关用户令牌校验：按认证服务JWKS本地校验签名，不持有任何签名密钥
+type UserTokenValidator struct {
+	keys        auth.VerificationKeys
+	revocations auth.RevocationStore // 与认证服务共享的Redis吊销列表，未配置时由下游服务检查
+	allowHS256  bool
+}
+
+// newUserTokenValidator 根据配置创建校验器；未启用时返回nil
+func newUserTokenValidator(config *shared.Config, authServiceURL string) *UserTokenValidator {
+	if !config.TokenValidation.Enabled {
+		return nil
+	}
+
+	jwksURL := config.TokenValidation.JWKSURL
+	if jwksURL == "" {
+		jwksURL = auth.JWKSURL(authServiceURL)
+	}
+	validator := &UserTokenValidator{
+		keys:       auth.NewJWKSCache(jwksURL),
+		allowHS256: config.TokenValidation.AllowHS256,
+	}
+
+	if config.Database.Redis.Enabled && config.Database.Redis.Host != "" {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", config.Database.Redis.Host, config.Database.Redis.Port),
+			Password: config.Database.Redis.Password,
+			DB:       config.Database.Redis.DB,
+		})
+		validator.revocations = auth.NewRedisRevocationStore(redisClient)
+	}
+
+	fmt.Printf("✅ 网关令牌校验已启用（JWKS: %s）\n", jwksURL)
+	return validator
+}
+
+// Middleware 校验请求携带的用户令牌；未携带令牌的请求放行，由下游服务决定是否需要登录
+func (v *UserTokenValidator) Middleware(extractToken func(*http.Request) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := extractToken(c.Request)
+		if token == "" || isTokenValidationExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		// HS256旧令牌：网关无共享密钥，迁移期交给下游服务校验
+		if auth.IsHS256Token(token) {
+			if v.allowHS256 {
+				c.Next()
+				return
+			}
+			v.reject(c, "不再接受HS256签名的token")
+			return
+		}
+
+		claims, err := auth.ParseUserToken(token, v.keys, "")
+		if err != nil {
+			message := "无效的token"
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				message = "token已过期"
+			}
+			v.reject(c, message)
+			return
+		}
+
+		if v.revocations != nil {
+			if revoked, err := v.isRevoked(c, claims.ID); err != nil || revoked {
+				v.reject(c, "token已被注销")
+				return
+			}
+		}
+
+		// 已校验的身份供限流分桶与响应缓存分区使用
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Set("permissions", claims.Permissions)
+		c.Next()
+	}
+}
+
+// isRevoked 查询吊销列表（吊销列表不可用时按已吊销处理）
+func (v *UserTokenValidator) isRevoked(c *gin.Context, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	return v.revocations.IsRevoked(c.Request.Context(), jti)
+}
+
+// reject 返回401
+func (v *UserTokenValidator) reject(c *gin.Context, message string) {
+	traceID := ""
+	if tid, exists := c.Get("trace_id"); exists {
+		traceID = tid.(string)
+	}
+	utils.WriteErrorResponse(c.Writer, http.StatusUnauthorized, message, traceID)
+	c.Abort()
+}
+
+// isTokenValidationExempt 是否为免校验路由
+func isTokenValidationExempt(path string) bool {
+	for _, prefix := range tokenValidationExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/szjason72/zervigo/shared/central-brain/utils"
+	"github.com/szjason72/zervigo/shared/core/tracing"
+)
+
+// UpstreamPolicy 上游调用策略
+type UpstreamPolicy struct {
+	Timeout    time.Duration      // 单次尝试超时
+	Retry      *utils.RetryConfig // 重试配置（nil表示不重试），仅对幂等请求生效
+	HedgeDelay time.Duration      // 首个请求超过该时间未返回时发起对冲请求（0表示不对冲）
+	MaxHedges  int                // 每次尝试最多额外发起的对冲请求数
+}
+
+// DefaultUpstreamPolicy 默认上游调用策略：30秒超时，网关类错误重试2次
+func DefaultUpstreamPolicy() *UpstreamPolicy {
+	return &UpstreamPolicy{
+		Timeout: 30 * time.Second,
+		Retry: &utils.RetryConfig{
+			MaxRetries:    2,
+			InitialDelay:  100 * time.Millisecond,
+			MaxDelay:      2 * time.Second,
+			BackoffFactor: 2.0,
+			RetryableErrors: []int{
+				http.StatusBadGateway,
+				http.StatusServiceUnavailable,
+				http.StatusGatewayTimeout,
+			},
+		},
+	}
+}
+
+// policyFromMetadata 根据服务实例元数据覆盖上游策略
+// 支持的键：timeout_ms、max_retries、hedge_delay_ms、max_hedges
+func policyFromMetadata(metadata map[string]string) *UpstreamPolicy {
+	policy := DefaultUpstreamPolicy()
+	if v, err := strconv.Atoi(metadata["timeout_ms"]); err == nil && v > 0 {
+		policy.Timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, err := strconv.Atoi(metadata["max_retries"]); err == nil && v >= 0 {
+		policy.Retry.MaxRetries = v
+	}
+	if v, err := strconv.Atoi(metadata["hedge_delay_ms"]); err == nil && v > 0 {
+		policy.HedgeDelay = time.Duration(v) * time.Millisecond
+		policy.MaxHedges = 1
+	}
+	if v, err := strconv.Atoi(metadata["max_hedges"]); err == nil && v >= 0 {
+		policy.MaxHedges = v
+	}
+	return policy
+}
+
+// upstreamResult 一次上游调用的结果（响应体已完整读取）
+type upstreamResult struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+	baseURL    string
+}
+
+// retryable 判断结果是否需要重试（传输错误或可重试状态码）
+func (r upstreamResult) retryable(retry *utils.RetryConfig) bool {
+	if r.err != nil {
+		return true
+	}
+	return retry != nil && retry.ShouldRetry(r.statusCode)
+}
+
+// isIdempotentRequest 判断请求是否可安全重放
+// POST/PATCH仅在客户端提供Idempotency-Key时视为幂等
+func isIdempotentRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// doUpstream 按策略执行一次逻辑请求
+// 重试与对冲产生的多次物理请求只对应一次逻辑请求，熔断器由调用方统一记录一次结果。
+func (cb *CentralBrain) doUpstream(c *gin.Context, service ServiceProxy, body []byte, header http.Header) upstreamResult {
+	policy := service.Policy
+	if policy == nil {
+		policy = DefaultUpstreamPolicy()
+	}
+
+	idempotent := isIdempotentRequest(c.Request)
+	maxAttempts := 1
+	if policy.Retry != nil && idempotent {
+		maxAttempts += policy.Retry.MaxRetries
+	}
+
+	var result upstreamResult
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := policy.Retry.CalculateDelay(attempt - 1)
+			fmt.Printf("🔁 重试上游请求 %s (%d/%d)，%v后执行，上次结果: %s\n",
+				service.ServiceName, attempt, maxAttempts-1, delay, describeUpstreamResult(result))
+
+			select {
+			case <-c.Request.Context().Done():
+				return upstreamResult{err: c.Request.Context().Err()}
+			case <-time.After(delay):
+			}
+		}
+
+		result = cb.hedgedAttempt(c, service, policy, body, header, attempt, idempotent)
+		if !result.retryable(policy.Retry) {
+			return result
+		}
+	}
+	return result
+}
+
+// hedgedAttempt 执行一次尝试；启用对冲时，首个请求超时未返回则向其他实例并发请求，取最先成功的结果
+func (cb *CentralBrain) hedgedAttempt(c *gin.Context, service ServiceProxy, policy *UpstreamPolicy,
+	body []byte, header http.Header, attempt int, idempotent bool) upstreamResult {
+
+	maxRequests := 1
+	if policy.HedgeDelay > 0 && idempotent && cb.routeManager.InstanceCount(service.ServiceName) > 1 {
+		maxRequests += policy.MaxHedges
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	results := make(chan upstreamResult, maxRequests)
+	launched := 0
+	launch := func() {
+		// 首次尝试的首个请求使用已选中的实例，其余请求重新经负载均衡选择实例
+		baseURL, release := service.BaseURL, func() {}
+		if attempt > 0 || launched > 0 {
+			baseURL, release = cb.routeManager.ResolveBaseURL(service)
+		}
+		launched++
+
+		go func() {
+			defer release()
+			results <- cb.sendUpstream(ctx, c, service, baseURL, body, header, policy.Timeout)
+		}()
+	}
+
+	launch()
+
+	var hedgeTimer <-chan time.Time
+	if launched < maxRequests {
+		hedgeTimer = time.After(policy.HedgeDelay)
+	}
+
+	var last upstreamResult
+	for received := 0; received < launched; {
+		select {
+		case result := <-results:
+			received++
+			if !result.retryable(policy.Retry) {
+				return result
+			}
+			last = result
+
+			// 已有请求失败，剩余对冲请求立即发出，不再等待
+			if received == launched && launched < maxRequests {
+				launch()
+			}
+		case <-hedgeTimer:
+			fmt.Printf("🪃 对冲请求 %s（%v内未响应）\n", service.ServiceName, policy.HedgeDelay)
+			launch()
+			hedgeTimer = nil
+			if launched < maxRequests {
+				hedgeTimer = time.After(policy.HedgeDelay)
+			}
+		}
+	}
+	return last
+}
+
+// sendUpstream 发送单个物理请求并完整读取响应
+func (cb *CentralBrain) sendUpstream(ctx context.Context, c *gin.Context, service ServiceProxy,
+	baseURL string, body []byte, header http.Header, timeout time.Duration) upstreamResult {
+
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	target := service
+	target.BaseURL = baseURL
+	targetURL := cb.buildTargetURL(c.Request, target)
+	fmt.Printf("🔄 代理请求: %s -> %s\n", c.Request.URL.Path, targetURL)
+
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return upstreamResult{err: fmt.Errorf("创建请求失败: %v", err), baseURL: baseURL}
+	}
+	req.Header = header.Clone()
+
+	// 超时由策略控制，这里直接使用服务专用Transport（外层跟踪Transport为每次尝试创建Client Span）
+	client := &http.Client{Transport: tracing.NewTransport(cb.clientPool.GetTransport(service.ServiceName), cb.tracer)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return upstreamResult{err: err, baseURL: baseURL}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return upstreamResult{err: fmt.Errorf("读取响应失败: %v", err), baseURL: baseURL}
+	}
+
+	return upstreamResult{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       respBody,
+		baseURL:    baseURL,
+	}
+}
+
+// describeUpstreamResult 用于日志输出的结果描述
+func describeUpstreamResult(r upstreamResult) string {
+	if r.err != nil {
+		return fmt.Sprintf("%s 错误: %v", r.baseURL, r.err)
+	}
+	return fmt.Sprintf("%s HTTP %d", r.baseURL, r.statusCode)
+}
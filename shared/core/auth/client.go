@@ -3,18 +3,22 @@ package auth
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/szjason72/zervigo/shared/core/response"
 )
 
 // AuthClient 认证服务客户端
 type AuthClient struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	keys        VerificationKeys // 认证服务JWKS（本地缓存）
+	revocations RevocationStore  // 与认证服务共享的吊销列表，未设置时每次都远程校验
 }
 
 // NewAuthClient 创建认证服务客户端
@@ -24,9 +28,15 @@ func NewAuthClient(baseURL string) *AuthClient {
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
+		keys: sharedJWKSCache(JWKSURL(baseURL)),
 	}
 }
 
+// SetRevocationStore 设置吊销列表后，非对称签名的令牌在本地完成校验
+func (c *AuthClient) SetRevocationStore(store RevocationStore) {
+	c.revocations = store
+}
+
 // ValidateTokenRequest 验证Token请求
 type ValidateTokenRequest struct {
 	Token string `json:"token"`
@@ -39,8 +49,58 @@ type ValidateTokenResponse struct {
 	Data    *AuthResult `json:"data"`
 }
 
-// ValidateToken 验证JWT Token（调用auth-service）
+// ValidateToken 验证JWT Token
+// 非对称签名的令牌用JWKS本地校验；HS256旧令牌或公钥不可用时调用auth-service。
 func (c *AuthClient) ValidateToken(token string) (*AuthResult, error) {
+	if result, ok := c.validateLocally(token); ok {
+		return result, nil
+	}
+	return c.validateRemotely(token)
+}
+
+// validateLocally 本地校验签名、有效期与吊销状态；无法得出结论时返回false
+// 本地校验不查询用户状态，禁用账户需同时吊销其令牌。
+func (c *AuthClient) validateLocally(token string) (*AuthResult, bool) {
+	if c.revocations == nil || IsHS256Token(token) {
+		return nil, false
+	}
+
+	claims, err := ParseUserToken(token, c.keys, "")
+	if err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenUnverifiable):
+			// 取不到公钥（认证服务不可达等），交给auth-service判断
+			return nil, false
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return &AuthResult{Success: false, Error: "token已过期", ErrorCode: "TOKEN_EXPIRED"}, true
+		default:
+			return &AuthResult{Success: false, Error: "无效的token", ErrorCode: "INVALID_TOKEN"}, true
+		}
+	}
+
+	if err := checkRevoked(c.revocations, claims.ID); err != nil {
+		if !errors.Is(err, ErrTokenRevoked) {
+			return nil, false
+		}
+		return &AuthResult{Success: false, Error: err.Error(), ErrorCode: "TOKEN_REVOKED"}, true
+	}
+
+	return &AuthResult{
+		Success: true,
+		User: &UserInfo{
+			ID:       claims.UserID,
+			Username: claims.Username,
+			Email:    claims.Email,
+			Role:     claims.Role,
+			Status:   "active",
+		},
+		Permissions: claims.Permissions,
+		AMR:         claims.AMR,
+	}, true
+}
+
+// validateRemotely 调用auth-service校验
+func (c *AuthClient) validateRemotely(token string) (*AuthResult, error) {
 	// 构建请求
 	reqBody := ValidateTokenRequest{
 		Token: token,
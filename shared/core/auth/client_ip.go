@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TrustedProxiesFromEnv 读取 TRUSTED_PROXIES（逗号分隔的IP或CIDR）
+func TrustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, item := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			proxies = append(proxies, item)
+		}
+	}
+	return proxies
+}
+
+// ClientIPResolver 客户端IP解析
+// 默认只使用连接地址；仅当连接来自可信代理时才采信X-Forwarded-For/X-Real-IP，
+// 避免客户端伪造转发头绕过按IP的登录锁定。
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver 创建客户端IP解析器（proxies为空表示不信任任何转发头）
+func NewClientIPResolver(proxies []string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理地址 %q: %v", proxy, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// isTrusted 判断地址是否属于可信代理
+func (r *ClientIPResolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 解析请求的客户端IP
+// 连接地址是可信代理时，从X-Forwarded-For末端向前跳过可信代理，取第一个不可信的地址
+func (r *ClientIPResolver) ClientIP(req *http.Request) string {
+	remoteIP := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		remoteIP = host
+	}
+
+	ip := net.ParseIP(remoteIP)
+	if ip == nil || !r.isTrusted(ip) {
+		return remoteIP
+	}
+
+	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			hopIP := net.ParseIP(hop)
+			if hopIP == nil {
+				break
+			}
+			if i == 0 || !r.isTrusted(hopIP) {
+				return hop
+			}
+		}
+	}
+	if xri := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return remoteIP
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWKSCache 远程JWKS的本地缓存，各服务据此离线校验认证服务签发的令牌
+type JWKSCache struct {
+	url        string
+	httpClient *http.Client
+	ttl        time.Duration // 缓存有效期，过期后下次校验时刷新
+	minRefresh time.Duration // 遇到未知kid时的最小刷新间隔，防止伪造kid打满认证服务
+
+	mu          sync.RWMutex
+	keys        map[string]cachedJWK
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	refreshMu   sync.Mutex // 同一时刻只有一个刷新请求
+}
+
+type cachedJWK struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// NewJWKSCache 创建JWKS缓存（首次校验时拉取）
+func NewJWKSCache(url string) *JWKSCache {
+	return &JWKSCache{
+		url:        url,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		ttl:        10 * time.Minute,
+		minRefresh: 30 * time.Second,
+		keys:       make(map[string]cachedJWK),
+	}
+}
+
+var (
+	jwksCachesMu sync.Mutex
+	jwksCaches   = make(map[string]*JWKSCache)
+)
+
+// sharedJWKSCache 同一进程内同一地址共用一个缓存
+func sharedJWKSCache(url string) *JWKSCache {
+	jwksCachesMu.Lock()
+	defer jwksCachesMu.Unlock()
+
+	cache, exists := jwksCaches[url]
+	if !exists {
+		cache = NewJWKSCache(url)
+		jwksCaches[url] = cache
+	}
+	return cache
+}
+
+// VerificationKeysFromEnv 按AUTH_JWKS_URL创建JWKS缓存；未配置时返回nil（仅能校验HS256旧令牌）
+func VerificationKeysFromEnv() VerificationKeys {
+	url := os.Getenv("AUTH_JWKS_URL")
+	if url == "" {
+		return nil
+	}
+	return sharedJWKSCache(url)
+}
+
+// JWKSURL 认证服务的JWKS地址
+func JWKSURL(authServiceURL string) string {
+	return strings.TrimSuffix(authServiceURL, "/") + "/.well-known/jwks.json"
+}
+
+// VerificationKey 按kid返回公钥；缓存过期或kid未知时刷新，认证服务不可用时继续使用已缓存的公钥
+func (c *JWKSCache) VerificationKey(kid, alg string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	entry, exists := c.keys[kid]
+	stale := time.Since(c.fetchedAt) > c.ttl
+	c.mu.RUnlock()
+
+	if !exists || stale {
+		if err := c.refresh(); err != nil {
+			if !exists {
+				return nil, err
+			}
+			log.Printf("WARN: 刷新JWKS失败，继续使用缓存的公钥: %v", err)
+		} else {
+			c.mu.RLock()
+			entry, exists = c.keys[kid]
+			c.mu.RUnlock()
+		}
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("未知的kid: %s", kid)
+	}
+	if entry.alg != alg {
+		return nil, fmt.Errorf("kid %s 的签名算法为 %s，令牌声明为 %s", kid, entry.alg, alg)
+	}
+	return entry.key, nil
+}
+
+// refresh 拉取JWKS；距上次尝试不足minRefresh时跳过
+func (c *JWKSCache) refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	recent := time.Since(c.lastAttempt) < c.minRefresh
+	c.mu.RUnlock()
+	if recent {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.lastAttempt = time.Now()
+	c.mu.Unlock()
+
+	resp, err := c.httpClient.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("请求JWKS失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求JWKS失败: HTTP %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("解析JWKS失败: %w", err)
+	}
+
+	keys := make(map[string]cachedJWK, len(body.Keys))
+	for _, jwk := range body.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, alg, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("WARN: 跳过无法解析的JWK %s: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = cachedJWK{alg: alg, key: key}
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
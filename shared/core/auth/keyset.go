@@ -0,0 +1,506 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 签名算法
+const (
+	SigningAlgRS256 = "RS256"
+	SigningAlgEdDSA = "EdDSA"
+)
+
+// 签名密钥状态
+const (
+	KeyStatusActive   = "active"   // 当前用于签名
+	KeyStatusRetiring = "retiring" // 已轮换，仅用于校验此前签发的令牌，到期后从JWKS移除
+)
+
+// SigningKey 令牌签名密钥
+type SigningKey struct {
+	KID       string
+	Algorithm string
+	Status    string
+	Private   crypto.Signer // *rsa.PrivateKey 或 ed25519.PrivateKey
+	CreatedAt time.Time
+	RetireAt  time.Time // 仅retiring密钥有效
+}
+
+// Public 公钥
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// KeyStore 签名密钥持久化，多个认证服务实例共享同一组密钥
+type KeyStore interface {
+	LoadKeys() ([]*SigningKey, error)
+	SaveKey(key *SigningKey) error
+	// RetireKey 将active密钥标记为retiring；密钥已被其他实例轮换时返回false
+	RetireKey(kid string, retireAt time.Time) (bool, error)
+	DeleteKey(kid string) error
+}
+
+// VerificationKeys 按kid查找校验公钥（本地KeySet或远程JWKS）
+type VerificationKeys interface {
+	VerificationKey(kid, alg string) (crypto.PublicKey, error)
+}
+
+// KeySetConfig 密钥集配置
+type KeySetConfig struct {
+	Algorithm        string        // RS256 或 EdDSA
+	RotationInterval time.Duration // 活动密钥的使用时长
+	RetentionPeriod  time.Duration // 轮换后旧密钥的保留时长，需不短于最长令牌有效期（用户token为7天）
+	ReloadInterval   time.Duration // 从KeyStore同步其他实例轮换结果的间隔
+}
+
+// KeySetConfigFromEnv 从 JWT_SIGNING_ALG / JWT_KEY_ROTATION_INTERVAL / JWT_KEY_RETENTION 读取配置
+func KeySetConfigFromEnv() KeySetConfig {
+	config := KeySetConfig{
+		Algorithm:        os.Getenv("JWT_SIGNING_ALG"),
+		RotationInterval: 30 * 24 * time.Hour,
+		RetentionPeriod:  8 * 24 * time.Hour,
+		ReloadInterval:   time.Minute,
+	}
+
+	for env, target := range map[string]*time.Duration{
+		"JWT_KEY_ROTATION_INTERVAL": &config.RotationInterval,
+		"JWT_KEY_RETENTION":         &config.RetentionPeriod,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			log.Printf("WARN: %s=%q 无效，使用默认值 %s", env, value, *target)
+			continue
+		}
+		*target = duration
+	}
+	return config
+}
+
+// normalize 补齐默认值
+func (c *KeySetConfig) normalize() {
+	if c.Algorithm == "" {
+		c.Algorithm = SigningAlgRS256
+	}
+	if c.RotationInterval <= 0 {
+		c.RotationInterval = 30 * 24 * time.Hour
+	}
+	if c.RetentionPeriod <= 0 {
+		c.RetentionPeriod = 8 * 24 * time.Hour
+	}
+	if c.ReloadInterval <= 0 {
+		c.ReloadInterval = time.Minute
+	}
+}
+
+// KeySet 签名密钥集：一个active密钥负责签名，retiring密钥在保留期内继续用于校验
+type KeySet struct {
+	mu     sync.RWMutex
+	config KeySetConfig
+	store  KeyStore
+	active *SigningKey
+	keys   map[string]*SigningKey
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewKeySet 创建密钥集；store为nil时密钥只保存在进程内，重启后已签发的令牌全部失效
+func NewKeySet(config KeySetConfig, store KeyStore) (*KeySet, error) {
+	config.normalize()
+	if config.Algorithm != SigningAlgRS256 && config.Algorithm != SigningAlgEdDSA {
+		return nil, fmt.Errorf("不支持的签名算法: %s", config.Algorithm)
+	}
+	if store == nil {
+		log.Printf("WARN: 签名密钥未持久化，服务重启后已签发的令牌全部失效")
+	}
+
+	ks := &KeySet{
+		config: config,
+		store:  store,
+		keys:   make(map[string]*SigningKey),
+		stopCh: make(chan struct{}),
+	}
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	active := ks.active
+	ks.mu.RUnlock()
+	// 首次启动或切换了算法时生成新密钥（旧密钥转为retiring，已签发令牌不受影响）
+	if active == nil || active.Algorithm != config.Algorithm {
+		if err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Algorithm 当前签名算法
+func (ks *KeySet) Algorithm() string {
+	return ks.config.Algorithm
+}
+
+// Sign 使用活动密钥签名，头部写入kid（typ为空时不设置）
+func (ks *KeySet) Sign(claims jwt.Claims, typ string) (string, error) {
+	ks.mu.RLock()
+	key := ks.active
+	ks.mu.RUnlock()
+	if key == nil {
+		return "", errors.New("没有可用的签名密钥")
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.KID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(key.Private)
+}
+
+// VerificationKey 按kid返回公钥；算法必须与密钥一致，防止算法混淆
+func (ks *KeySet) VerificationKey(kid, alg string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	key, exists := ks.keys[kid]
+	ks.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("未知的kid: %s", kid)
+	}
+	if key.Algorithm != alg {
+		return nil, fmt.Errorf("kid %s 的签名算法为 %s，令牌声明为 %s", kid, key.Algorithm, alg)
+	}
+	return key.Public(), nil
+}
+
+// JWKS 活动与保留期内密钥的公钥集合
+func (ks *KeySet) JWKS() map[string]interface{} {
+	ks.mu.RLock()
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	ks.mu.RUnlock()
+
+	// 新密钥在前，便于排查
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	jwks := make([]jsonWebKey, 0, len(keys))
+	for _, key := range keys {
+		jwks = append(jwks, newJSONWebKey(key))
+	}
+	return map[string]interface{}{"keys": jwks}
+}
+
+// Rotate 生成新的活动密钥，原活动密钥转为retiring
+func (ks *KeySet) Rotate() error {
+	key, err := generateSigningKey(ks.config.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	previous := ks.active
+	retireAt := time.Now().Add(ks.config.RetentionPeriod)
+	if ks.store != nil {
+		// 先写入新密钥再下线旧密钥，任何时刻库中都有可用的活动密钥
+		if err := ks.store.SaveKey(key); err != nil {
+			return fmt.Errorf("保存签名密钥失败: %w", err)
+		}
+		if previous != nil {
+			if _, err := ks.store.RetireKey(previous.KID, retireAt); err != nil {
+				return fmt.Errorf("下线签名密钥失败: %w", err)
+			}
+		}
+		// 其他实例可能同时完成了轮换，以库中状态为准
+		if err := ks.reloadLocked(); err != nil {
+			return err
+		}
+	} else {
+		if previous != nil {
+			previous.Status = KeyStatusRetiring
+			previous.RetireAt = retireAt
+		}
+		ks.keys[key.KID] = key
+		ks.active = key
+	}
+
+	log.Printf("INFO: 签名密钥已轮换 kid=%s alg=%s", ks.active.KID, ks.active.Algorithm)
+	return nil
+}
+
+// Start 启动定时任务：同步其他实例的轮换结果，活动密钥到期时轮换
+func (ks *KeySet) Start() {
+	go func() {
+		ticker := time.NewTicker(ks.config.ReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ks.stopCh:
+				return
+			case <-ticker.C:
+				if err := ks.reload(); err != nil {
+					log.Printf("WARN: 同步签名密钥失败: %v", err)
+					continue
+				}
+				if ks.rotationDue() {
+					if err := ks.Rotate(); err != nil {
+						log.Printf("WARN: 签名密钥轮换失败: %v", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止定时任务
+func (ks *KeySet) Stop() {
+	ks.stopOnce.Do(func() { close(ks.stopCh) })
+}
+
+// rotationDue 活动密钥是否已到轮换时间
+func (ks *KeySet) rotationDue() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active == nil || time.Since(ks.active.CreatedAt) >= ks.config.RotationInterval
+}
+
+// reload 从KeyStore重新加载密钥
+func (ks *KeySet) reload() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.reloadLocked()
+}
+
+// reloadLocked 加载密钥并清理过期的retiring密钥（调用方持有写锁）
+func (ks *KeySet) reloadLocked() error {
+	now := time.Now()
+	if ks.store == nil {
+		for kid, key := range ks.keys {
+			if key.Status == KeyStatusRetiring && now.After(key.RetireAt) {
+				delete(ks.keys, kid)
+			}
+		}
+		return nil
+	}
+
+	loaded, err := ks.store.LoadKeys()
+	if err != nil {
+		return fmt.Errorf("加载签名密钥失败: %w", err)
+	}
+
+	// 多个active密钥（并发轮换）时以最新的为准，其余下线
+	var active *SigningKey
+	for _, key := range loaded {
+		if key.Status == KeyStatusActive && (active == nil || key.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+	}
+
+	keys := make(map[string]*SigningKey, len(loaded))
+	for _, key := range loaded {
+		if key.Status == KeyStatusActive && key != active {
+			key.Status = KeyStatusRetiring
+			key.RetireAt = now.Add(ks.config.RetentionPeriod)
+			if _, err := ks.store.RetireKey(key.KID, key.RetireAt); err != nil {
+				log.Printf("WARN: 下线重复的活动密钥 %s 失败: %v", key.KID, err)
+			}
+		}
+		if key.Status == KeyStatusRetiring && now.After(key.RetireAt) {
+			if err := ks.store.DeleteKey(key.KID); err != nil {
+				log.Printf("WARN: 删除过期签名密钥 %s 失败: %v", key.KID, err)
+			}
+			continue
+		}
+		keys[key.KID] = key
+	}
+
+	ks.keys = keys
+	if active != nil {
+		ks.active = active
+	}
+	return nil
+}
+
+// generateSigningKey 生成签名密钥
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case SigningAlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("生成RSA密钥失败: %w", err)
+		}
+		private = key
+	case SigningAlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("生成Ed25519密钥失败: %w", err)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", algorithm)
+	}
+
+	return &SigningKey{
+		KID:       keyID(private.Public()),
+		Algorithm: algorithm,
+		Status:    KeyStatusActive,
+		Private:   private,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// signingMethod 算法名对应的jwt签名方法
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == SigningAlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// keyID 以公钥DER的SHA-256作为kid，同一密钥在各实例上kid一致
+func keyID(pub crypto.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// jsonWebKey 公钥的JWK表示（RFC 7517 / RFC 8037）
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// newJSONWebKey 签名密钥的公钥JWK
+func newJSONWebKey(key *SigningKey) jsonWebKey {
+	jwk := jsonWebKey{Use: "sig", Alg: key.Algorithm, Kid: key.KID}
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// publicKey 解析JWK中的公钥，返回公钥与签名算法
+func (k jsonWebKey) publicKey() (crypto.PublicKey, string, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, "", fmt.Errorf("无效的RSA模数: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, "", errors.New("无效的RSA指数")
+		}
+		alg := k.Alg
+		if alg == "" {
+			alg = SigningAlgRS256
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, "", fmt.Errorf("不支持的曲线: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New("无效的Ed25519公钥")
+		}
+		return ed25519.PublicKey(x), SigningAlgEdDSA, nil
+	default:
+		return nil, "", fmt.Errorf("不支持的密钥类型: %s", k.Kty)
+	}
+}
+
+// jwtKeyFunc 校验用的Keyfunc：非对称算法按kid查公钥；legacySecret非空时兼容旧版HS256令牌
+func jwtKeyFunc(keys VerificationKeys, legacySecret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if legacySecret == "" {
+				return nil, errors.New("不再接受HS256签名的token")
+			}
+			return []byte(legacySecret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+			if keys == nil {
+				return nil, errors.New("未配置校验公钥")
+			}
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				return nil, errors.New("token缺少kid")
+			}
+			return keys.VerificationKey(kid, token.Method.Alg())
+		default:
+			return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+		}
+	}
+}
+
+// legacyHS256Allowed 是否继续接受HS256令牌（JWT_ACCEPT_HS256，默认false；
+// 仅在迁移期需要兼容旧令牌时显式开启，开启期间持有共享密钥的服务仍可伪造令牌）
+func legacyHS256Allowed() bool {
+	value := os.Getenv("JWT_ACCEPT_HS256")
+	if value == "true" || value == "1" {
+		log.Printf("WARN: 仍接受HS256签名的旧令牌，迁移完成后请设置 JWT_ACCEPT_HS256=false")
+		return true
+	}
+	return false
+}
+
+// ParseUserToken 校验用户令牌签名与有效期
+// 同一密钥集也签发OAuth2令牌与服务令牌，非对称签名的令牌按issuer区分用途。
+func ParseUserToken(tokenString string, keys VerificationKeys, legacySecret string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, jwtKeyFunc(keys, legacySecret))
+	if err != nil {
+		return nil, err
+	}
+	if _, legacy := token.Method.(*jwt.SigningMethodHMAC); !legacy && claims.Issuer != UserTokenIssuer {
+		return nil, fmt.Errorf("%w: 不是用户令牌", jwt.ErrTokenInvalidIssuer)
+	}
+	return claims, nil
+}
+
+// IsHS256Token 是否为共享密钥签名的旧令牌（不校验签名）
+func IsHS256Token(tokenString string) bool {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return false
+	}
+	_, ok := token.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqlKeyStore 签名密钥存储在认证库中，所有认证服务实例共享
+// 私钥以PKCS#8 PEM保存，该表的访问权限应与用户密码表一致。
+type sqlKeyStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// SigningKeyStore 创建基于数据库的签名密钥存储（表不存在时自动创建）
+func (uas *UnifiedAuthSystem) SigningKeyStore() (KeyStore, error) {
+	return newSQLKeyStore(uas.db, uas.isPostgres())
+}
+
+// newSQLKeyStore 创建签名密钥表并返回存储（认证服务与用户服务共用同一张表）
+func newSQLKeyStore(db *sql.DB, postgres bool) (KeyStore, error) {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS zervigo_auth_signing_keys (
+            kid VARCHAR(64) PRIMARY KEY,
+            algorithm VARCHAR(16) NOT NULL,
+            private_key TEXT NOT NULL,
+            status VARCHAR(16) NOT NULL,
+            created_at TIMESTAMP NOT NULL,
+            retire_at TIMESTAMP NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建签名密钥表失败: %w", err)
+	}
+	return &sqlKeyStore{db: db, postgres: postgres}, nil
+}
+
+// placeholder SQL参数占位符（PostgreSQL为$n，MySQL为?）
+func (s *sqlKeyStore) placeholder(idx int) string {
+	if s.postgres {
+		return fmt.Sprintf("$%d", idx)
+	}
+	return "?"
+}
+
+func (s *sqlKeyStore) placeholders(count int) string {
+	parts := make([]string, count)
+	for i := range parts {
+		parts[i] = s.placeholder(i + 1)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LoadKeys 加载全部密钥
+func (s *sqlKeyStore) LoadKeys() ([]*SigningKey, error) {
+	rows, err := s.db.Query(`
+        SELECT kid, algorithm, private_key, status, created_at, retire_at
+        FROM zervigo_auth_signing_keys
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*SigningKey
+	for rows.Next() {
+		var key SigningKey
+		var privatePEM string
+		var retireAt sql.NullTime
+		if err := rows.Scan(&key.KID, &key.Algorithm, &privatePEM, &key.Status, &key.CreatedAt, &retireAt); err != nil {
+			return nil, err
+		}
+		private, err := parsePrivateKeyPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("解析签名密钥 %s 失败: %w", key.KID, err)
+		}
+		key.Private = private
+		if retireAt.Valid {
+			key.RetireAt = retireAt.Time
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
+}
+
+// SaveKey 保存新密钥
+func (s *sqlKeyStore) SaveKey(key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return fmt.Errorf("编码私钥失败: %w", err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	query := fmt.Sprintf(`
+        INSERT INTO zervigo_auth_signing_keys (kid, algorithm, private_key, status, created_at)
+        VALUES (%s)
+    `, s.placeholders(5))
+	_, err = s.db.Exec(query, key.KID, key.Algorithm, string(privatePEM), key.Status, key.CreatedAt)
+	return err
+}
+
+// RetireKey 条件更新保证并发轮换时只有一个实例生效
+func (s *sqlKeyStore) RetireKey(kid string, retireAt time.Time) (bool, error) {
+	query := fmt.Sprintf(`
+        UPDATE zervigo_auth_signing_keys SET status = %s, retire_at = %s
+        WHERE kid = %s AND status = %s
+    `, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4))
+	result, err := s.db.Exec(query, KeyStatusRetiring, retireAt, kid, KeyStatusActive)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteKey 删除过期密钥
+func (s *sqlKeyStore) DeleteKey(kid string) error {
+	query := fmt.Sprintf(`DELETE FROM zervigo_auth_signing_keys WHERE kid = %s`, s.placeholder(1))
+	_, err := s.db.Exec(query, kid)
+	return err
+}
+
+// parsePrivateKeyPEM 解析PKCS#8私钥（RSA或Ed25519）
+func parsePrivateKeyPEM(data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("不是有效的PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的密钥类型 %T", parsed)
+	}
+	return signer, nil
+}
@@ -0,0 +1,439 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	// ErrLoginLocked 账号或IP因连续登录失败被临时锁定
+	ErrLoginLocked = errors.New("登录已被临时锁定")
+	// ErrInvalidLockoutKey 锁定键必须以 user: 或 ip: 开头
+	ErrInvalidLockoutKey = errors.New("无效的锁定键")
+)
+
+// LockoutError 锁定详情（errors.Is(err, ErrLoginLocked) 为true）
+type LockoutError struct {
+	Key   string
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	minutes := int(time.Until(e.Until).Minutes()) + 1
+	return fmt.Sprintf("登录失败次数过多，请%d分钟后重试", minutes)
+}
+
+// Is 支持 errors.Is(err, ErrLoginLocked)
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+// RetryAfter 距离解锁的时间
+func (e *LockoutError) RetryAfter() time.Duration {
+	if d := time.Until(e.Until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// LoginAttemptState 账号或IP的登录失败状态
+type LoginAttemptState struct {
+	Key         string    `json:"key"`          // user:<用户名> 或 ip:<地址>
+	Failures    int       `json:"failures"`     // 当前连续失败次数
+	Lockouts    int       `json:"lockouts"`     // 保留期内的锁定次数（决定下次锁定时长）
+	LockedUntil time.Time `json:"locked_until"` // 零值表示未锁定
+}
+
+// Locked 是否处于锁定期
+func (s *LoginAttemptState) Locked() bool {
+	return time.Now().Before(s.LockedUntil)
+}
+
+// LoginAttemptStore 登录失败计数存储
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (*LoginAttemptState, error)
+	// RecordFailure 失败次数+1并刷新保留时长
+	RecordFailure(ctx context.Context, key string, ttl time.Duration) (*LoginAttemptState, error)
+	// Lock 锁定到until：失败次数清零，锁定次数+1
+	Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error
+	Reset(ctx context.Context, key string) error
+	List(ctx context.Context) ([]*LoginAttemptState, error)
+}
+
+// RedisLoginAttemptStore 基于Redis的计数，多实例与多服务（用户服务、认证服务）共享
+type RedisLoginAttemptStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisLoginAttemptStore 创建Redis登录失败计数
+func NewRedisLoginAttemptStore(client *redis.Client) *RedisLoginAttemptStore {
+	return &RedisLoginAttemptStore{
+		client: client,
+		prefix: "auth:login:",
+	}
+}
+
+// Get 查询状态（不存在时返回零值状态）
+func (s *RedisLoginAttemptStore) Get(ctx context.Context, key string) (*LoginAttemptState, error) {
+	fields, err := s.client.HGetAll(ctx, s.prefix+key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("查询登录失败计数失败: %w", err)
+	}
+	return parseLoginAttemptState(key, fields), nil
+}
+
+// RecordFailure 失败次数+1
+func (s *RedisLoginAttemptStore) RecordFailure(ctx context.Context, key string, ttl time.Duration) (*LoginAttemptState, error) {
+	redisKey := s.prefix + key
+	pipe := s.client.TxPipeline()
+	pipe.HIncrBy(ctx, redisKey, "failures", 1)
+	pipe.Expire(ctx, redisKey, ttl)
+	fields := pipe.HGetAll(ctx, redisKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("记录登录失败失败: %w", err)
+	}
+	return parseLoginAttemptState(key, fields.Val()), nil
+}
+
+// Lock 锁定
+func (s *RedisLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	redisKey := s.prefix + key
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, redisKey, "failures", 0, "locked_until", until.Unix())
+	pipe.HIncrBy(ctx, redisKey, "lockouts", 1)
+	pipe.Expire(ctx, redisKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("写入登录锁定失败: %w", err)
+	}
+	return nil
+}
+
+// Reset 清除状态
+func (s *RedisLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// List 列出全部状态
+func (s *RedisLoginAttemptStore) List(ctx context.Context) ([]*LoginAttemptState, error) {
+	var states []*LoginAttemptState
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		redisKey := iter.Val()
+		fields, err := s.client.HGetAll(ctx, redisKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			states = append(states, parseLoginAttemptState(strings.TrimPrefix(redisKey, s.prefix), fields))
+		}
+	}
+	return states, iter.Err()
+}
+
+// parseLoginAttemptState 解析Redis哈希
+func parseLoginAttemptState(key string, fields map[string]string) *LoginAttemptState {
+	state := &LoginAttemptState{Key: key}
+	state.Failures, _ = strconv.Atoi(fields["failures"])
+	state.Lockouts, _ = strconv.Atoi(fields["lockouts"])
+	if until, _ := strconv.ParseInt(fields["locked_until"], 10, 64); until > 0 {
+		state.LockedUntil = time.Unix(until, 0)
+	}
+	return state
+}
+
+// MemoryLoginAttemptStore 进程内计数（未配置Redis时使用，仅对当前进程生效）
+type MemoryLoginAttemptStore struct {
+	mu      sync.Mutex
+	states  map[string]*LoginAttemptState
+	expires map[string]time.Time
+}
+
+// NewMemoryLoginAttemptStore 创建进程内登录失败计数
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		states:  make(map[string]*LoginAttemptState),
+		expires: make(map[string]time.Time),
+	}
+}
+
+// Get 查询状态
+func (s *MemoryLoginAttemptStore) Get(ctx context.Context, key string) (*LoginAttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := *s.stateLocked(key)
+	return &state, nil
+}
+
+// RecordFailure 失败次数+1
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, ttl time.Duration) (*LoginAttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.stateLocked(key)
+	state.Failures++
+	s.states[key] = state
+	s.expires[key] = time.Now().Add(ttl)
+	copied := *state
+	return &copied, nil
+}
+
+// Lock 锁定
+func (s *MemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.stateLocked(key)
+	state.Failures = 0
+	state.Lockouts++
+	state.LockedUntil = until
+	s.states[key] = state
+	s.expires[key] = time.Now().Add(ttl)
+	return nil
+}
+
+// Reset 清除状态
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	delete(s.expires, key)
+	return nil
+}
+
+// List 列出全部状态
+func (s *MemoryLoginAttemptStore) List(ctx context.Context) ([]*LoginAttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]*LoginAttemptState, 0, len(s.states))
+	for key := range s.states {
+		state := *s.stateLocked(key)
+		if state.Failures > 0 || state.Lockouts > 0 {
+			states = append(states, &state)
+		}
+	}
+	return states, nil
+}
+
+// stateLocked 返回未过期的状态（调用方持有锁）
+func (s *MemoryLoginAttemptStore) stateLocked(key string) *LoginAttemptState {
+	state, exists := s.states[key]
+	if !exists || time.Now().After(s.expires[key]) {
+		delete(s.states, key)
+		delete(s.expires, key)
+		return &LoginAttemptState{Key: key}
+	}
+	return state
+}
+
+// LoginAttemptStoreFromEnv 按REDIS_*环境变量创建计数存储；Redis不可用时回退到进程内实现
+func LoginAttemptStoreFromEnv() LoginAttemptStore {
+	client := redisClientFromEnv()
+	if client == nil {
+		log.Printf("WARN: 未连接Redis，登录失败计数仅在当前进程内生效")
+		return NewMemoryLoginAttemptStore()
+	}
+	return NewRedisLoginAttemptStore(client)
+}
+
+// LockoutPolicy 登录锁定策略
+type LockoutPolicy struct {
+	MaxAccountFailures int           // 单个账号连续失败次数上限
+	MaxIPFailures      int           // 单个IP失败次数上限（跨账号，防撞库）
+	BaseLockout        time.Duration // 首次锁定时长，之后每次翻倍
+	MaxLockout         time.Duration // 锁定时长上限
+	StateTTL           time.Duration // 失败计数与锁定次数的保留时长
+}
+
+// LockoutPolicyFromConfig 由AuthConfig生成锁定策略（未配置的项使用默认值）
+func LockoutPolicyFromConfig(config AuthConfig) LockoutPolicy {
+	policy := LockoutPolicy{
+		MaxAccountFailures: config.MaxLoginAttempts,
+		MaxIPFailures:      config.MaxIPLoginAttempts,
+		BaseLockout:        config.LockoutDuration,
+		MaxLockout:         config.MaxLockoutDuration,
+	}
+	policy.normalize()
+	return policy
+}
+
+// LockoutPolicyFromEnv 从 LOGIN_MAX_ATTEMPTS / LOGIN_MAX_IP_ATTEMPTS / LOGIN_LOCKOUT_DURATION / LOGIN_MAX_LOCKOUT_DURATION 读取
+func LockoutPolicyFromEnv() LockoutPolicy {
+	var config AuthConfig
+	config.MaxLoginAttempts, _ = strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS"))
+	config.MaxIPLoginAttempts, _ = strconv.Atoi(os.Getenv("LOGIN_MAX_IP_ATTEMPTS"))
+	config.LockoutDuration, _ = time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"))
+	config.MaxLockoutDuration, _ = time.ParseDuration(os.Getenv("LOGIN_MAX_LOCKOUT_DURATION"))
+	return LockoutPolicyFromConfig(config)
+}
+
+// normalize 补齐默认值
+func (p *LockoutPolicy) normalize() {
+	if p.MaxAccountFailures <= 0 {
+		p.MaxAccountFailures = 5
+	}
+	if p.MaxIPFailures <= 0 {
+		p.MaxIPFailures = 50
+	}
+	if p.BaseLockout <= 0 {
+		p.BaseLockout = 15 * time.Minute
+	}
+	if p.MaxLockout <= 0 {
+		p.MaxLockout = 24 * time.Hour
+	}
+	if p.StateTTL <= 0 {
+		p.StateTTL = 24 * time.Hour
+	}
+}
+
+// lockoutDuration 第n次（从0开始）锁定的时长
+func (p LockoutPolicy) lockoutDuration(previousLockouts int) time.Duration {
+	duration := p.BaseLockout
+	for i := 0; i < previousLockouts && duration < p.MaxLockout; i++ {
+		duration *= 2
+	}
+	if duration > p.MaxLockout {
+		duration = p.MaxLockout
+	}
+	return duration
+}
+
+// LoginGuard 登录暴力破解防护：按账号与IP分别计数，超过阈值后指数退避锁定
+type LoginGuard struct {
+	store  LoginAttemptStore
+	policy LockoutPolicy
+}
+
+// NewLoginGuard 创建登录防护
+func NewLoginGuard(policy LockoutPolicy, store LoginAttemptStore) *LoginGuard {
+	policy.normalize()
+	if store == nil {
+		store = NewMemoryLoginAttemptStore()
+	}
+	return &LoginGuard{store: store, policy: policy}
+}
+
+// Check 登录前检查账号与IP是否处于锁定期
+// 计数存储不可用时放行（记录WARN），避免Redis故障导致全员无法登录。
+func (g *LoginGuard) Check(username, clientIP string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for _, key := range loginAttemptKeys(username, clientIP) {
+		state, err := g.store.Get(ctx, key)
+		if err != nil {
+			log.Printf("WARN: %v", err)
+			continue
+		}
+		if state.Locked() {
+			return &LockoutError{Key: key, Until: state.LockedUntil}
+		}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次失败；达到阈值时锁定并返回LockoutError
+func (g *LoginGuard) RecordFailure(username, clientIP string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var lockout *LockoutError
+	for _, key := range loginAttemptKeys(username, clientIP) {
+		limit := g.policy.MaxAccountFailures
+		if strings.HasPrefix(key, "ip:") {
+			limit = g.policy.MaxIPFailures
+		}
+
+		state, err := g.store.RecordFailure(ctx, key, g.policy.StateTTL)
+		if err != nil {
+			log.Printf("WARN: %v", err)
+			continue
+		}
+		if state.Failures < limit {
+			continue
+		}
+
+		duration := g.policy.lockoutDuration(state.Lockouts)
+		until := time.Now().Add(duration)
+		if err := g.store.Lock(ctx, key, until, duration+g.policy.StateTTL); err != nil {
+			log.Printf("WARN: %v", err)
+			continue
+		}
+		log.Printf("WARN: 连续登录失败已锁定 key=%s 失败次数=%d 锁定时长=%s", key, state.Failures, duration)
+		if lockout == nil {
+			lockout = &LockoutError{Key: key, Until: until}
+		}
+	}
+	if lockout != nil {
+		return lockout
+	}
+	return nil
+}
+
+// RecordSuccess 登录成功清除账号的失败计数
+// IP计数保留，防止攻击者用自己的账号登录来重置撞库计数。
+func (g *LoginGuard) RecordSuccess(username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := g.store.Reset(ctx, accountAttemptKey(username)); err != nil {
+		log.Printf("WARN: 清除登录失败计数失败: %v", err)
+	}
+}
+
+// Lockouts 当前处于锁定期的账号与IP（按解锁时间排序）
+func (g *LoginGuard) Lockouts() ([]*LoginAttemptState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	states, err := g.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	locked := make([]*LoginAttemptState, 0, len(states))
+	for _, state := range states {
+		if state.Locked() {
+			locked = append(locked, state)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].LockedUntil.Before(locked[j].LockedUntil) })
+	return locked, nil
+}
+
+// Clear 管理员解除锁定（同时清空失败计数与锁定次数）
+func (g *LoginGuard) Clear(key string) error {
+	if !strings.HasPrefix(key, "user:") && !strings.HasPrefix(key, "ip:") {
+		return fmt.Errorf("%w: %s", ErrInvalidLockoutKey, key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return g.store.Reset(ctx, key)
+}
+
+// loginAttemptKeys 需要计数的键
+func loginAttemptKeys(username, clientIP string) []string {
+	keys := []string{accountAttemptKey(username)}
+	if clientIP != "" {
+		keys = append(keys, "ip:"+clientIP)
+	}
+	return keys
+}
+
+// accountAttemptKey 账号键（用户名不区分大小写，不存在的账号同样计数，避免枚举）
+func accountAttemptKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
@@ -8,22 +8,87 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// ErrPasswordMismatch 原密码错误
+var ErrPasswordMismatch = errors.New("原密码错误")
+
 // AuthManager 认证管理器
 type AuthManager struct {
-	db     *gorm.DB
-	config AuthConfig
+	db             *gorm.DB
+	config         AuthConfig
+	revocations    RevocationStore // 访问令牌吊销列表（见SetRevocationStore）
+	loginGuard     *LoginGuard     // 登录失败计数与锁定（见SetLoginAttemptStore）
+	passwordPolicy *PasswordPolicy
+	mfa            *MFAService      // TOTP两步验证
+	keySet         *KeySet          // 非对称签名密钥集（见SetKeySet）
+	verifier       VerificationKeys // 校验公钥（本地KeySet或远程JWKS）
+	acceptHS256    bool             // 是否仍接受JWTSecret签名的旧令牌
 }
 
 // NewAuthManager 创建认证管理器
 func NewAuthManager(db *gorm.DB, config AuthConfig) *AuthManager {
 	return &AuthManager{
-		db:     db,
-		config: config,
+		db:             db,
+		config:         config,
+		revocations:    NewMemoryRevocationStore(),
+		loginGuard:     NewLoginGuard(LockoutPolicyFromConfig(config), NewMemoryLoginAttemptStore()),
+		passwordPolicy: PasswordPolicyFromConfig(config),
+		mfa:            NewMFAService(NewGormMFAStore(db), MFAPolicyFromEnv()),
+	}
+}
+
+// SetKeySet 使用非对称密钥签发令牌（与认证服务共享密钥表，公钥由认证服务JWKS发布），同时用于本地校验
+func (am *AuthManager) SetKeySet(keySet *KeySet) {
+	am.keySet = keySet
+	am.verifier = keySet
+	am.acceptHS256 = legacyHS256Allowed()
+}
+
+// SetVerificationKeys 只校验不签发的服务通过JWKS获取公钥
+func (am *AuthManager) SetVerificationKeys(keys VerificationKeys) {
+	am.verifier = keys
+	am.acceptHS256 = legacyHS256Allowed()
+}
+
+// SigningKeyStore 创建基于数据库的签名密钥存储（与认证服务共用zervigo_auth_signing_keys）
+func (am *AuthManager) SigningKeyStore() (KeyStore, error) {
+	sqlDB, err := am.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return newSQLKeyStore(sqlDB, am.db.Dialector.Name() == "postgres")
+}
+
+// legacySecret 仍接受HS256旧令牌（或未配置密钥集）时返回共享密钥
+func (am *AuthManager) legacySecret() string {
+	if am.acceptHS256 || am.verifier == nil {
+		return am.config.JWTSecret
 	}
+	return ""
+}
+
+// SetLoginAttemptStore 设置登录失败计数存储（多实例部署需使用Redis）
+func (am *AuthManager) SetLoginAttemptStore(store LoginAttemptStore) {
+	am.loginGuard = NewLoginGuard(LockoutPolicyFromConfig(am.config), store)
+}
+
+// Lockouts 当前被锁定的账号与IP
+func (am *AuthManager) Lockouts() ([]*LoginAttemptState, error) {
+	return am.loginGuard.Lockouts()
+}
+
+// ClearLockout 解除锁定（key 形如 user:<用户名> 或 ip:<地址>）
+func (am *AuthManager) ClearLockout(key string) error {
+	return am.loginGuard.Clear(key)
+}
+
+// ValidatePassword 按密码策略校验
+func (am *AuthManager) ValidatePassword(password string, userInputs ...string) error {
+	return am.passwordPolicy.Validate(password, userInputs...)
 }
 
 // Register 用户注册
@@ -34,6 +99,10 @@ func (am *AuthManager) Register(req RegisterRequest) (*RegisterResponse, error)
 		return nil, errors.New("用户名或邮箱已存在")
 	}
 
+	if err := am.passwordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
 	// 哈希密码
 	passwordHash, err := am.hashPassword(req.Password)
 	if err != nil {
@@ -88,15 +157,26 @@ func (am *AuthManager) Register(req RegisterRequest) (*RegisterResponse, error)
 
 // Login 用户登录
 func (am *AuthManager) Login(req LoginRequest, clientIP, userAgent string) (*LoginResponse, error) {
+	// 账号或IP处于锁定期时不再校验密码
+	if err := am.loginGuard.Check(req.Username, clientIP); err != nil {
+		return nil, err
+	}
+
 	// 查找用户
 	var user User
 	if err := am.db.Where("username = ? AND status = 'active'", req.Username).First(&user).Error; err != nil {
+		if lockErr := am.loginGuard.RecordFailure(req.Username, clientIP); lockErr != nil {
+			return nil, lockErr
+		}
 		return nil, errors.New("用户不存在或已被禁用")
 	}
 
 	// 验证密码
 	if !am.validatePassword(req.Password, user.PasswordHash) {
 		am.logLoginAttempt(user.ID, clientIP, userAgent, "failed", "密码错误")
+		if lockErr := am.loginGuard.RecordFailure(req.Username, clientIP); lockErr != nil {
+			return nil, lockErr
+		}
 		return nil, errors.New("密码错误")
 	}
 
@@ -105,49 +185,37 @@ func (am *AuthManager) Login(req LoginRequest, clientIP, userAgent string) (*Log
 		log.Printf("WARN: 获取用户角色失败 user_id=%d: %v", user.ID, err)
 		role = "user"
 	}
-	user.Role = role
-
-	// 生成JWT token
-	token, expiresAt, err := am.generateToken(user.ID, user.Username, role)
-	if err != nil {
-		return nil, fmt.Errorf("生成token失败: %w", err)
-	}
-
-	// 更新最后登录时间
-	now := time.Now()
-	am.db.Model(&user).Update("last_login_at", now)
-
-	// 记录登录日志
-	am.logLoginAttempt(user.ID, clientIP, userAgent, "success", "登录成功")
-
-	response := &LoginResponse{
-		Success:   true,
-		Token:     token,
-		User:      user,
-		ExpiresAt: expiresAt.Format(time.RFC3339),
-		Message:   "登录成功",
-	}
 
-	// 检查是否为开发团队成员
-	var devTeam DevTeamUser
-	if err := am.db.Where("user_id = ? AND status = 'active'", user.ID).First(&devTeam).Error; err == nil {
-		response.DevTeam = devTeam
+	// 已启用两步验证或角色强制两步验证时，先返回两步验证凭证（失败计数在两步验证通过后才清零）
+	if challenge, err := am.mfaChallenge(user, role, MFAFlowLogin, false); err != nil || challenge != nil {
+		return challenge, err
 	}
+	am.loginGuard.RecordSuccess(req.Username)
 
-	return response, nil
+	return am.completeLogin(user, role, []string{AMRPassword}, nil, clientIP, userAgent, "登录成功")
 }
 
 // SuperAdminLogin 超级管理员登录
 func (am *AuthManager) SuperAdminLogin(req LoginRequest, clientIP, userAgent string) (*LoginResponse, error) {
+	if err := am.loginGuard.Check(req.Username, clientIP); err != nil {
+		return nil, err
+	}
+
 	// 查找用户
 	var user User
 	if err := am.db.Where("username = ? AND status = 'active'", req.Username).First(&user).Error; err != nil {
+		if lockErr := am.loginGuard.RecordFailure(req.Username, clientIP); lockErr != nil {
+			return nil, lockErr
+		}
 		return nil, errors.New("用户不存在或已被禁用")
 	}
 
 	// 验证密码
 	if !am.validatePassword(req.Password, user.PasswordHash) {
 		am.logLoginAttempt(user.ID, clientIP, userAgent, "failed", "密码错误")
+		if lockErr := am.loginGuard.RecordFailure(req.Username, clientIP); lockErr != nil {
+			return nil, lockErr
+		}
 		return nil, errors.New("密码错误")
 	}
 
@@ -162,30 +230,100 @@ func (am *AuthManager) SuperAdminLogin(req LoginRequest, clientIP, userAgent str
 		role = "super_admin"
 	}
 
+	// 超级管理员始终需要两步验证，令牌在 VerifyMFALogin 中签发
+	return am.mfaChallenge(user, role, MFAFlowSuperAdmin, true)
+}
+
+// completeLogin 签发访问令牌与刷新令牌；devTeam非空时为超级管理员登录
+func (am *AuthManager) completeLogin(user User, role string, amr []string, devTeam *DevTeamUser, clientIP, userAgent, message string) (*LoginResponse, error) {
+	user.Role = role
+
 	// 生成JWT token
-	token, expiresAt, err := am.generateToken(user.ID, user.Username, role)
+	token, expiresAt, err := am.generateToken(user.ID, user.Username, role, amr)
 	if err != nil {
 		return nil, fmt.Errorf("生成token失败: %w", err)
 	}
 
+	// 签发刷新令牌（新的令牌家族）
+	refreshToken, refreshExpiresAt, err := am.issueRefreshToken(user.ID, amr, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
 	// 更新最后登录时间
 	now := time.Now()
 	am.db.Model(&user).Update("last_login_at", now)
-	am.db.Model(&devTeam).Update("last_login_at", now)
+	if devTeam != nil {
+		am.db.Model(devTeam).Update("last_login_at", now)
+	}
 
 	// 记录登录日志
-	am.logLoginAttempt(user.ID, clientIP, userAgent, "success", "超级管理员登录成功")
+	am.logLoginAttempt(user.ID, clientIP, userAgent, "success", message)
 
-	user.Role = role
+	response := &LoginResponse{
+		Success:          true,
+		Token:            token,
+		RefreshToken:     refreshToken,
+		User:             user,
+		ExpiresAt:        expiresAt.Format(time.RFC3339),
+		RefreshExpiresAt: refreshExpiresAt.Format(time.RFC3339),
+		Message:          message,
+	}
 
-	return &LoginResponse{
-		Success:   true,
-		Token:     token,
-		User:      user,
-		DevTeam:   devTeam,
-		ExpiresAt: expiresAt.Format(time.RFC3339),
-		Message:   "超级管理员登录成功",
-	}, nil
+	// 检查是否为开发团队成员
+	if devTeam != nil {
+		response.DevTeam = *devTeam
+	} else {
+		var member DevTeamUser
+		if err := am.db.Where("user_id = ? AND status = 'active'", user.ID).First(&member).Error; err == nil {
+			response.DevTeam = member
+		}
+	}
+
+	return response, nil
+}
+
+// ChangePassword 修改密码：校验原密码与密码策略，成功后吊销该用户全部刷新令牌
+// 原密码错误计入登录失败次数，防止持有会话者暴力猜测密码。
+func (am *AuthManager) ChangePassword(userID uint, oldPassword, newPassword, clientIP string) error {
+	var user User
+	if err := am.db.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("用户不存在: %w", err)
+	}
+
+	if err := am.loginGuard.Check(user.Username, clientIP); err != nil {
+		return err
+	}
+	if !am.validatePassword(oldPassword, user.PasswordHash) {
+		if lockErr := am.loginGuard.RecordFailure(user.Username, clientIP); lockErr != nil {
+			return lockErr
+		}
+		return ErrPasswordMismatch
+	}
+
+	if oldPassword == newPassword {
+		return &PasswordPolicyError{Violations: []string{"新密码不能与原密码相同"}}
+	}
+	if err := am.passwordPolicy.Validate(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	passwordHash, err := am.hashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("密码哈希失败: %w", err)
+	}
+	if err := am.db.Model(&user).Updates(map[string]interface{}{
+		"password_hash": passwordHash,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("更新密码失败: %w", err)
+	}
+
+	// 其他设备需重新登录
+	if err := am.RevokeUserRefreshTokens(user.ID); err != nil {
+		log.Printf("WARN: 修改密码后吊销刷新令牌失败 user_id=%d: %v", user.ID, err)
+	}
+	return nil
 }
 
 // ValidateToken 验证JWT token
@@ -195,20 +333,10 @@ func (am *AuthManager) ValidateToken(tokenString string) (*Claims, error) {
 	} else {
 		log.Printf("DEBUG: 开始验证JWT token: %s", tokenString)
 	}
-	log.Printf("DEBUG: 使用JWT secret: %s", am.config.JWTSecret)
 
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		log.Printf("DEBUG: JWT解析 - 算法: %v", token.Header["alg"])
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			log.Printf("DEBUG: JWT解析失败 - 不支持的签名方法: %v", token.Header["alg"])
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		log.Printf("DEBUG: JWT解析 - 使用secret进行验证")
-		return []byte(am.config.JWTSecret), nil
-	})
-
+	token, err := jwt.ParseWithClaims(tokenString, claims, jwtKeyFunc(am.verifier, am.legacySecret()))
 	if err != nil {
 		log.Printf("DEBUG: JWT解析失败: %v", err)
 		return nil, fmt.Errorf("token解析失败: %w", err)
@@ -218,6 +346,9 @@ func (am *AuthManager) ValidateToken(tokenString string) (*Claims, error) {
 		log.Printf("DEBUG: JWT token无效")
 		return nil, errors.New("无效的token")
 	}
+	if _, legacy := token.Method.(*jwt.SigningMethodHMAC); !legacy && claims.Issuer != UserTokenIssuer {
+		return nil, errors.New("token不是用户令牌")
+	}
 
 	// 检查token是否过期
 	currentTime := time.Now().Unix()
@@ -227,6 +358,12 @@ func (am *AuthManager) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("token已过期")
 	}
 
+	// 检查token是否已被注销
+	if err := checkRevoked(am.revocations, claims.ID); err != nil {
+		log.Printf("DEBUG: Token吊销校验未通过: %v", err)
+		return nil, err
+	}
+
 	return claims, nil
 }
 
@@ -285,17 +422,27 @@ func (am *AuthManager) validatePassword(password, hash string) bool {
 }
 
 // generateToken 生成JWT token
-func (am *AuthManager) generateToken(userID uint, username, role string) (string, time.Time, error) {
+func (am *AuthManager) generateToken(userID uint, username, role string, amr []string) (string, time.Time, error) {
 	expiresAt := time.Now().Add(am.config.TokenExpiry)
 
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		AMR:      amr,
 		Exp:      expiresAt.Unix(),
 		Iat:      time.Now().Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:      uuid.New().String(), // jti，用于登出时吊销
+			Issuer:  UserTokenIssuer,
+			Subject: fmt.Sprintf("%d", userID),
+		},
 	}
 
+	if am.keySet != nil {
+		tokenString, err := am.keySet.Sign(claims, "")
+		return tokenString, expiresAt, err
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(am.config.JWTSecret))
 
@@ -0,0 +1,452 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// 认证方式（写入令牌的amr声明，取值参照RFC 8176）
+const (
+	AMRPassword = "pwd" // 密码
+	AMROTP      = "otp" // TOTP动态码
+	AMRMFA      = "mfa" // 已完成多因素认证（含恢复码）
+)
+
+// 两步验证相关错误
+var (
+	ErrMFAInvalidCode      = errors.New("两步验证码错误")
+	ErrMFANotEnrolled      = errors.New("未启用两步验证")
+	ErrMFAAlreadyEnabled   = errors.New("已启用两步验证")
+	ErrMFARequired         = errors.New("当前角色必须启用两步验证")
+	ErrMFAChallengeInvalid = errors.New("两步验证凭证无效或已过期")
+)
+
+const (
+	totpDigits          = 6
+	totpPeriod          = 30 // 秒
+	totpSkew            = 1  // 允许前后各一个时间窗，容忍客户端时钟偏差
+	recoveryCodeCount   = 10
+	mfaChallengeTTL     = 5 * time.Minute
+	mfaChallengeSubject = "mfa_challenge"
+)
+
+// 登录流程（两步验证完成后按原流程签发令牌）
+const (
+	MFAFlowLogin      = "login"
+	MFAFlowSuperAdmin = "super_admin_login"
+)
+
+// MFAEnrollment 用户的TOTP登记；Enabled为false表示已生成密钥但尚未用动态码确认
+type MFAEnrollment struct {
+	UserID       uint       `json:"user_id" gorm:"primaryKey;column:user_id"`
+	Secret       string     `json:"-" gorm:"column:secret;type:varchar(64);not null"`
+	Enabled      bool       `json:"enabled" gorm:"column:enabled;not null;default:false"`
+	LastUsedStep int64      `json:"-" gorm:"column:last_used_step;not null;default:0"` // 已使用的最大时间窗，防止同一动态码重放
+	CreatedAt    time.Time  `json:"created_at" gorm:"column:created_at"`
+	EnabledAt    *time.Time `json:"enabled_at" gorm:"column:enabled_at"`
+}
+
+// TableName 指定表名
+func (MFAEnrollment) TableName() string {
+	return "zervigo_auth_user_mfa"
+}
+
+// MFARecoveryCode 一次性恢复码（只保存SHA-256摘要）
+type MFARecoveryCode struct {
+	UserID    uint       `json:"user_id" gorm:"primaryKey;column:user_id"`
+	CodeHash  string     `json:"-" gorm:"primaryKey;column:code_hash;type:varchar(64)"`
+	UsedAt    *time.Time `json:"used_at" gorm:"column:used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName 指定表名
+func (MFARecoveryCode) TableName() string {
+	return "zervigo_auth_mfa_recovery_codes"
+}
+
+// MFAStore 两步验证数据存储
+type MFAStore interface {
+	// GetEnrollment 未登记时返回 nil, nil
+	GetEnrollment(userID uint) (*MFAEnrollment, error)
+	// SavePendingEnrollment 保存待确认的登记（覆盖之前未确认的登记）
+	SavePendingEnrollment(enrollment *MFAEnrollment) error
+	// EnableEnrollment 确认登记并写入恢复码
+	EnableEnrollment(userID uint, step int64, recoveryCodeHashes []string) error
+	// DeleteEnrollment 删除登记及恢复码
+	DeleteEnrollment(userID uint) error
+	// UseTOTPStep 仅当step大于已使用的时间窗时更新，返回是否成功
+	UseTOTPStep(userID uint, step int64) (bool, error)
+	// ReplaceRecoveryCodes 重新生成恢复码（旧恢复码全部作废）
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	// UseRecoveryCode 标记恢复码已使用，返回是否成功（不存在或已使用为false）
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	// RemainingRecoveryCodes 未使用的恢复码数量
+	RemainingRecoveryCodes(userID uint) (int, error)
+}
+
+// MFAPolicy 按角色要求两步验证
+type MFAPolicy struct {
+	RequiredRoles map[string]bool
+}
+
+// MFAPolicyFromEnv 读取MFA_REQUIRED_ROLES（逗号分隔），super_admin始终必须启用
+func MFAPolicyFromEnv() MFAPolicy {
+	policy := MFAPolicy{RequiredRoles: map[string]bool{"super_admin": true}}
+	for _, role := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			policy.RequiredRoles[role] = true
+		}
+	}
+	return policy
+}
+
+// Required 该角色是否必须启用两步验证
+func (p MFAPolicy) Required(role string) bool {
+	return p.RequiredRoles[role]
+}
+
+// MFAStatus 两步验证状态
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	Pending                bool `json:"pending"`  // 已生成密钥、待确认
+	Required               bool `json:"required"` // 当前角色是否强制
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollmentResponse 登记信息（密钥只在登记时返回一次）
+type MFAEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// 地址，前端生成二维码供验证器App扫描
+}
+
+// MFAService TOTP两步验证（RFC 6238）与恢复码
+type MFAService struct {
+	store  MFAStore
+	issuer string
+	policy MFAPolicy
+}
+
+// NewMFAService 创建两步验证服务；issuer为验证器App中显示的名称（默认读取MFA_ISSUER）
+func NewMFAService(store MFAStore, policy MFAPolicy) *MFAService {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "Zervigo"
+	}
+	return &MFAService{store: store, issuer: issuer, policy: policy}
+}
+
+// Policy 角色策略
+func (s *MFAService) Policy() MFAPolicy {
+	return s.policy
+}
+
+// Status 查询两步验证状态
+func (s *MFAService) Status(userID uint, role string) (*MFAStatus, error) {
+	status := &MFAStatus{Required: s.policy.Required(role)}
+	enrollment, err := s.store.GetEnrollment(userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	if enrollment == nil {
+		return status, nil
+	}
+
+	status.Enabled = enrollment.Enabled
+	status.Pending = !enrollment.Enabled
+	if enrollment.Enabled {
+		if status.RecoveryCodesRemaining, err = s.store.RemainingRecoveryCodes(userID); err != nil {
+			return nil, fmt.Errorf("查询恢复码失败: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// Enabled 是否已启用两步验证
+func (s *MFAService) Enabled(userID uint) (bool, error) {
+	enrollment, err := s.store.GetEnrollment(userID)
+	if err != nil {
+		return false, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	return enrollment != nil && enrollment.Enabled, nil
+}
+
+// BeginEnrollment 生成新的TOTP密钥，需调用ConfirmEnrollment确认后才生效
+func (s *MFAService) BeginEnrollment(userID uint, account string) (*MFAEnrollmentResponse, error) {
+	enrollment, err := s.store.GetEnrollment(userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	if enrollment != nil && enrollment.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SavePendingEnrollment(&MFAEnrollment{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return nil, fmt.Errorf("保存两步验证密钥失败: %w", err)
+	}
+
+	return &MFAEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: TOTPProvisioningURI(s.issuer, account, secret),
+	}, nil
+}
+
+// ConfirmEnrollment 用第一个动态码确认登记，返回恢复码明文（只返回这一次）
+func (s *MFAService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	enrollment, err := s.store.GetEnrollment(userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	if enrollment == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if enrollment.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := ValidateTOTP(enrollment.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.EnableEnrollment(userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("启用两步验证失败: %w", err)
+	}
+	return codes, nil
+}
+
+// Verify 校验动态码或恢复码，返回应写入令牌的amr
+func (s *MFAService) Verify(userID uint, code string) ([]string, error) {
+	enrollment, err := s.store.GetEnrollment(userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	if enrollment == nil || !enrollment.Enabled {
+		return nil, ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		step, ok := ValidateTOTP(enrollment.Secret, code, time.Now())
+		if !ok || step <= enrollment.LastUsedStep {
+			return nil, ErrMFAInvalidCode
+		}
+		used, err := s.store.UseTOTPStep(userID, step)
+		if err != nil {
+			return nil, fmt.Errorf("记录动态码使用失败: %w", err)
+		}
+		if !used {
+			// 并发请求已使用同一时间窗的动态码
+			return nil, ErrMFAInvalidCode
+		}
+		return []string{AMRPassword, AMROTP, AMRMFA}, nil
+	}
+
+	used, err := s.store.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return nil, fmt.Errorf("校验恢复码失败: %w", err)
+	}
+	if !used {
+		return nil, ErrMFAInvalidCode
+	}
+	return []string{AMRPassword, AMRMFA}, nil
+}
+
+// RegenerateRecoveryCodes 校验动态码后重新生成恢复码
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	if _, err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+	return codes, nil
+}
+
+// Disable 校验动态码后关闭两步验证；角色强制启用时拒绝
+func (s *MFAService) Disable(userID uint, role, code string) error {
+	if s.policy.Required(role) {
+		return ErrMFARequired
+	}
+	if _, err := s.Verify(userID, code); err != nil {
+		return err
+	}
+	if err := s.store.DeleteEnrollment(userID); err != nil {
+		return fmt.Errorf("关闭两步验证失败: %w", err)
+	}
+	return nil
+}
+
+// GenerateTOTPSecret 生成160位随机密钥（Base32，无填充）
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成TOTP密钥失败: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// TOTPProvisioningURI 生成验证器App可识别的 otpauth:// 地址
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// ValidateTOTP 校验动态码，返回匹配的时间窗序号
+func ValidateTOTP(secret, code string, at time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := at.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step := current + offset
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, uint64(step))), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode 计算指定时间窗的动态码（HMAC-SHA1 + 动态截断，RFC 4226）
+func totpCode(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// generateRecoveryCodes 生成恢复码（形如 k7m2-x9qp-4hzd），返回明文与摘要
+func generateRecoveryCodes(count int) ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789" // 去掉易混淆的 i l o 0 1
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j > 0 && j%4 == 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+		code := sb.String()
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode 恢复码摘要（忽略大小写、空格与连字符）
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// MFAChallengeClaims 密码校验通过后签发的两步验证凭证，只能用于完成两步验证
+type MFAChallengeClaims struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Flow     string `json:"flow"`
+	jwt.RegisteredClaims
+}
+
+// mfaChallengeKey 凭证签名密钥由JWT密钥派生，凭证无法被当作访问令牌通过校验
+func mfaChallengeKey(jwtSecret string) []byte {
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("zervigo-mfa-challenge"))
+	return mac.Sum(nil)
+}
+
+// signMFAChallenge 签发两步验证凭证（5分钟有效）
+func signMFAChallenge(jwtSecret string, userID uint, username, flow string) (string, error) {
+	now := time.Now()
+	claims := &MFAChallengeClaims{
+		UserID:   userID,
+		Username: username,
+		Flow:     flow,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   mfaChallengeSubject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(mfaChallengeKey(jwtSecret))
+}
+
+// parseMFAChallenge 校验两步验证凭证
+func parseMFAChallenge(jwtSecret, tokenString string) (*MFAChallengeClaims, error) {
+	claims := &MFAChallengeClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return mfaChallengeKey(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.Subject != mfaChallengeSubject || claims.UserID == 0 {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return claims, nil
+}
+
+// HasAMR 令牌的amr是否包含指定认证方式
+func HasAMR(amr []string, method string) bool {
+	for _, m := range amr {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// mfaChallenge 需要两步验证时返回待验证的登录响应，否则返回nil；force为true时不论角色策略都要求两步验证
+func (am *AuthManager) mfaChallenge(user User, role, flow string, force bool) (*LoginResponse, error) {
+	enabled, err := am.mfa.Enabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled && !force && !am.mfa.Policy().Required(role) {
+		return nil, nil
+	}
+
+	challenge, err := signMFAChallenge(am.config.JWTSecret, user.ID, user.Username, flow)
+	if err != nil {
+		return nil, fmt.Errorf("生成两步验证凭证失败: %w", err)
+	}
+
+	message := "请输入两步验证码"
+	if !enabled {
+		message = "当前账号必须启用两步验证，请先完成登记"
+	}
+	return &LoginResponse{
+		Success:               true,
+		MFARequired:           true,
+		MFAEnrollmentRequired: !enabled,
+		ChallengeToken:        challenge,
+		Message:               message,
+	}, nil
+}
+
+// VerifyMFALogin 校验两步验证码并完成登录；尚未登记的账号以此确认登记并返回恢复码
+func (am *AuthManager) VerifyMFALogin(challengeToken, code, clientIP, userAgent string) (*LoginResponse, error) {
+	claims, err := am.parseMFAChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := am.db.Where("id = ? AND status = 'active'", claims.UserID).First(&user).Error; err != nil {
+		return nil, errors.New("用户不存在或已被禁用")
+	}
+
+	enabled, err := am.mfa.Enabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	amr := []string{AMRPassword, AMROTP, AMRMFA}
+	var recoveryCodes []string
+	err = am.checkMFACode(user, clientIP, func() error {
+		var verifyErr error
+		if enabled {
+			amr, verifyErr = am.mfa.Verify(user.ID, code)
+		} else {
+			recoveryCodes, verifyErr = am.mfa.ConfirmEnrollment(user.ID, code)
+		}
+		return verifyErr
+	})
+	if err != nil {
+		if errors.Is(err, ErrMFAInvalidCode) {
+			am.logLoginAttempt(user.ID, clientIP, userAgent, "failed", "两步验证码错误")
+		}
+		return nil, err
+	}
+
+	// 凭证只能使用一次
+	am.revokeMFAChallenge(claims)
+	am.loginGuard.RecordSuccess(user.Username)
+
+	var devTeam *DevTeamUser
+	role, err := am.fetchPrimaryRole(user.ID)
+	if claims.Flow == MFAFlowSuperAdmin {
+		if err != nil {
+			role = "super_admin"
+		}
+		devTeam = &DevTeamUser{}
+		if err := am.db.Where("user_id = ? AND team_role = 'super_admin' AND status = 'active'", user.ID).First(devTeam).Error; err != nil {
+			return nil, errors.New("您不是超级管理员")
+		}
+	} else if err != nil {
+		log.Printf("WARN: 获取用户角色失败 user_id=%d: %v", user.ID, err)
+		role = "user"
+	}
+
+	message := "登录成功"
+	if devTeam != nil {
+		message = "超级管理员登录成功"
+	}
+	response, err := am.completeLogin(user, role, amr, devTeam, clientIP, userAgent, message)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// BeginMFAEnrollmentWithChallenge 角色强制两步验证但尚未登记的账号，凭登录时的两步验证凭证生成密钥
+func (am *AuthManager) BeginMFAEnrollmentWithChallenge(challengeToken string) (*MFAEnrollmentResponse, error) {
+	claims, err := am.parseMFAChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	return am.BeginMFAEnrollment(claims.UserID)
+}
+
+// BeginMFAEnrollment 为已登录用户生成TOTP密钥
+func (am *AuthManager) BeginMFAEnrollment(userID uint) (*MFAEnrollmentResponse, error) {
+	user, err := am.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return am.mfa.BeginEnrollment(user.ID, user.Username)
+}
+
+// ConfirmMFAEnrollment 已登录用户用动态码确认登记，返回恢复码
+func (am *AuthManager) ConfirmMFAEnrollment(userID uint, code, clientIP string) ([]string, error) {
+	user, err := am.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = am.checkMFACode(*user, clientIP, func() error {
+		var confirmErr error
+		codes, confirmErr = am.mfa.ConfirmEnrollment(user.ID, code)
+		return confirmErr
+	})
+	return codes, err
+}
+
+// MFAStatus 查询两步验证状态
+func (am *AuthManager) MFAStatus(userID uint) (*MFAStatus, error) {
+	role, err := am.fetchPrimaryRole(userID)
+	if err != nil {
+		role = "user"
+	}
+	return am.mfa.Status(userID, role)
+}
+
+// DisableMFA 校验动态码后关闭两步验证
+func (am *AuthManager) DisableMFA(userID uint, code, clientIP string) error {
+	user, err := am.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	role, err := am.fetchPrimaryRole(userID)
+	if err != nil {
+		role = "user"
+	}
+
+	return am.checkMFACode(*user, clientIP, func() error {
+		return am.mfa.Disable(user.ID, role, code)
+	})
+}
+
+// RegenerateMFARecoveryCodes 校验动态码后重新生成恢复码
+func (am *AuthManager) RegenerateMFARecoveryCodes(userID uint, code, clientIP string) ([]string, error) {
+	user, err := am.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = am.checkMFACode(*user, clientIP, func() error {
+		var regenerateErr error
+		codes, regenerateErr = am.mfa.RegenerateRecoveryCodes(user.ID, code)
+		return regenerateErr
+	})
+	return codes, err
+}
+
+// checkMFACode 校验动态码，错误计入登录失败次数（与密码共用锁定策略）
+func (am *AuthManager) checkMFACode(user User, clientIP string, verify func() error) error {
+	if err := am.loginGuard.Check(user.Username, clientIP); err != nil {
+		return err
+	}
+
+	err := verify()
+	if errors.Is(err, ErrMFAInvalidCode) {
+		if lockErr := am.loginGuard.RecordFailure(user.Username, clientIP); lockErr != nil {
+			return lockErr
+		}
+	}
+	return err
+}
+
+// parseMFAChallenge 校验两步验证凭证（已使用的凭证记录在吊销列表中）
+func (am *AuthManager) parseMFAChallenge(challengeToken string) (*MFAChallengeClaims, error) {
+	claims, err := parseMFAChallenge(am.config.JWTSecret, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRevoked(am.revocations, claims.ID); err != nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return claims, nil
+}
+
+// revokeMFAChallenge 作废已使用的两步验证凭证
+func (am *AuthManager) revokeMFAChallenge(claims *MFAChallengeClaims) {
+	if am.revocations == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := am.revocations.Revoke(ctx, claims.ID, time.Until(claims.ExpiresAt.Time)); err != nil {
+		log.Printf("WARN: 作废两步验证凭证失败 user_id=%d: %v", claims.UserID, err)
+	}
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// gormMFAStore 用户服务（AuthManager）使用的两步验证存储
+type gormMFAStore struct {
+	db *gorm.DB
+}
+
+// NewGormMFAStore 创建基于GORM的两步验证存储
+func NewGormMFAStore(db *gorm.DB) MFAStore {
+	return &gormMFAStore{db: db}
+}
+
+func (s *gormMFAStore) GetEnrollment(userID uint) (*MFAEnrollment, error) {
+	var enrollment MFAEnrollment
+	err := s.db.Where("user_id = ?", userID).First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (s *gormMFAStore) SavePendingEnrollment(enrollment *MFAEnrollment) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 只覆盖未确认的登记，避免并发请求替换已启用的密钥
+		if err := tx.Where("user_id = ? AND enabled = ?", enrollment.UserID, false).Delete(&MFAEnrollment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(enrollment).Error
+	})
+}
+
+func (s *gormMFAStore) EnableEnrollment(userID uint, step int64, recoveryCodeHashes []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&MFAEnrollment{}).
+			Where("user_id = ? AND enabled = ?", userID, false).
+			Updates(map[string]interface{}{"enabled": true, "enabled_at": now, "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAAlreadyEnabled
+		}
+		return replaceRecoveryCodesTx(tx, userID, recoveryCodeHashes)
+	})
+}
+
+func (s *gormMFAStore) DeleteEnrollment(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&MFAEnrollment{}).Error
+	})
+}
+
+func (s *gormMFAStore) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := s.db.Model(&MFAEnrollment{}).
+		Where("user_id = ? AND enabled = ? AND last_used_step < ?", userID, true, step).
+		Update("last_used_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *gormMFAStore) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodesTx(tx, userID, codeHashes)
+	})
+}
+
+func (s *gormMFAStore) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := s.db.Model(&MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s *gormMFAStore) RemainingRecoveryCodes(userID uint) (int, error) {
+	var count int64
+	err := s.db.Model(&MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return int(count), err
+}
+
+// replaceRecoveryCodesTx 删除旧恢复码并写入新恢复码
+func replaceRecoveryCodesTx(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	codes := make([]MFARecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, MFARecoveryCode{UserID: userID, CodeHash: hash, CreatedAt: now})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// sqlMFAStore 统一认证服务使用的两步验证存储（与用户服务共用同一组表）
+type sqlMFAStore struct {
+	uas *UnifiedAuthSystem
+}
+
+// MFAStore 创建基于数据库的两步验证存储（表不存在时自动创建）
+func (uas *UnifiedAuthSystem) MFAStore() (MFAStore, error) {
+	statements := []string{`
+        CREATE TABLE IF NOT EXISTS zervigo_auth_user_mfa (
+            user_id BIGINT PRIMARY KEY,
+            secret VARCHAR(64) NOT NULL,
+            enabled BOOLEAN NOT NULL DEFAULT FALSE,
+            last_used_step BIGINT NOT NULL DEFAULT 0,
+            created_at TIMESTAMP NOT NULL,
+            enabled_at TIMESTAMP NULL
+        )
+    `, `
+        CREATE TABLE IF NOT EXISTS zervigo_auth_mfa_recovery_codes (
+            user_id BIGINT NOT NULL,
+            code_hash VARCHAR(64) NOT NULL,
+            used_at TIMESTAMP NULL,
+            created_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (user_id, code_hash)
+        )
+    `}
+	for _, statement := range statements {
+		if _, err := uas.db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("创建两步验证表失败: %w", err)
+		}
+	}
+	return &sqlMFAStore{uas: uas}, nil
+}
+
+func (s *sqlMFAStore) GetEnrollment(userID uint) (*MFAEnrollment, error) {
+	query := fmt.Sprintf(`
+        SELECT user_id, secret, enabled, last_used_step, created_at, enabled_at
+        FROM zervigo_auth_user_mfa WHERE user_id = %s
+    `, s.uas.placeholder(1))
+
+	var enrollment MFAEnrollment
+	var enabledAt sql.NullTime
+	err := s.uas.db.QueryRow(query, userID).Scan(&enrollment.UserID, &enrollment.Secret, &enrollment.Enabled,
+		&enrollment.LastUsedStep, &enrollment.CreatedAt, &enabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		enrollment.EnabledAt = &enabledAt.Time
+	}
+	return &enrollment, nil
+}
+
+func (s *sqlMFAStore) SavePendingEnrollment(enrollment *MFAEnrollment) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		deleteQuery := fmt.Sprintf(`DELETE FROM zervigo_auth_user_mfa WHERE user_id = %s AND enabled = %s`,
+			s.uas.placeholder(1), s.uas.placeholder(2))
+		if _, err := tx.Exec(deleteQuery, enrollment.UserID, false); err != nil {
+			return err
+		}
+		insertQuery := fmt.Sprintf(`
+            INSERT INTO zervigo_auth_user_mfa (user_id, secret, enabled, last_used_step, created_at)
+            VALUES (%s)
+        `, s.uas.makePlaceholders(5))
+		_, err := tx.Exec(insertQuery, enrollment.UserID, enrollment.Secret, false, 0, enrollment.CreatedAt)
+		return err
+	})
+}
+
+func (s *sqlMFAStore) EnableEnrollment(userID uint, step int64, recoveryCodeHashes []string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		query := fmt.Sprintf(`
+            UPDATE zervigo_auth_user_mfa SET enabled = %s, enabled_at = %s, last_used_step = %s
+            WHERE user_id = %s AND enabled = %s
+        `, s.uas.placeholder(1), s.uas.placeholder(2), s.uas.placeholder(3), s.uas.placeholder(4), s.uas.placeholder(5))
+		result, err := tx.Exec(query, true, time.Now(), step, userID, false)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return ErrMFAAlreadyEnabled
+		}
+		return s.replaceRecoveryCodesTx(tx, userID, recoveryCodeHashes)
+	})
+}
+
+func (s *sqlMFAStore) DeleteEnrollment(userID uint) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, table := range []string{"zervigo_auth_mfa_recovery_codes", "zervigo_auth_user_mfa"} {
+			query := fmt.Sprintf(`DELETE FROM %s WHERE user_id = %s`, table, s.uas.placeholder(1))
+			if _, err := tx.Exec(query, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlMFAStore) UseTOTPStep(userID uint, step int64) (bool, error) {
+	query := fmt.Sprintf(`
+        UPDATE zervigo_auth_user_mfa SET last_used_step = %s
+        WHERE user_id = %s AND enabled = %s AND last_used_step < %s
+    `, s.uas.placeholder(1), s.uas.placeholder(2), s.uas.placeholder(3), s.uas.placeholder(4))
+	return s.execAffected(query, step, userID, true, step)
+}
+
+func (s *sqlMFAStore) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return s.replaceRecoveryCodesTx(tx, userID, codeHashes)
+	})
+}
+
+func (s *sqlMFAStore) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	query := fmt.Sprintf(`
+        UPDATE zervigo_auth_mfa_recovery_codes SET used_at = %s
+        WHERE user_id = %s AND code_hash = %s AND used_at IS NULL
+    `, s.uas.placeholder(1), s.uas.placeholder(2), s.uas.placeholder(3))
+	return s.execAffected(query, time.Now(), userID, codeHash)
+}
+
+func (s *sqlMFAStore) RemainingRecoveryCodes(userID uint) (int, error) {
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM zervigo_auth_mfa_recovery_codes WHERE user_id = %s AND used_at IS NULL`,
+		s.uas.placeholder(1))
+	var count int
+	err := s.uas.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+// replaceRecoveryCodesTx 删除旧恢复码并写入新恢复码
+func (s *sqlMFAStore) replaceRecoveryCodesTx(tx *sql.Tx, userID uint, codeHashes []string) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM zervigo_auth_mfa_recovery_codes WHERE user_id = %s`, s.uas.placeholder(1))
+	if _, err := tx.Exec(deleteQuery, userID); err != nil {
+		return err
+	}
+
+	insertQuery := fmt.Sprintf(`
+        INSERT INTO zervigo_auth_mfa_recovery_codes (user_id, code_hash, created_at)
+        VALUES (%s)
+    `, s.uas.makePlaceholders(3))
+	now := time.Now()
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(insertQuery, userID, hash, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTx 在事务中执行
+func (s *sqlMFAStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.uas.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAffected 执行条件更新，返回是否有行被更新
+func (s *sqlMFAStore) execAffected(query string, args ...interface{}) (bool, error) {
+	result, err := s.uas.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
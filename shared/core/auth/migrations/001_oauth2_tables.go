@@ -1,23 +1,36 @@
 package migrations
 
 import (
+	"fmt"
+
+	"github.com/szjason72/zervigo/shared/core/auth"
 	"gorm.io/gorm"
 )
 
 // RunOAuth2Migrations 执行 OAuth2 数据库迁移
+// AutoMigrate 只建表与索引，外键约束与清理函数见 001_oauth2_tables.sql（生产环境建议执行SQL脚本）。
 func RunOAuth2Migrations(db *gorm.DB) error {
-	// 注意：这里使用 GORM 的 AutoMigrate，但建议在生产环境使用 SQL 脚本
-	// 因为 GORM 的 AutoMigrate 可能无法完全处理复杂的索引和约束
-	
-	// 如果使用 SQL 脚本，可以直接执行：
-	// db.Exec(`<SQL content from 001_oauth2_tables.sql>`)
-	
-	// 或者使用 GORM AutoMigrate（简化版本）
 	err := db.AutoMigrate(
-		// OAuth2Client, AuthorizationCode, RefreshToken, AccessToken 需要导入
-		// 这里只是示例，实际使用时需要导入对应的模型
+		&auth.OAuth2Client{},
+		&auth.AuthorizationCode{},
+		&auth.RefreshToken{},
+		&auth.AccessToken{},
 	)
-	
-	return err
-}
+	if err != nil {
+		return fmt.Errorf("OAuth2表迁移失败: %w", err)
+	}
 
+	// 用户服务自身签发的刷新令牌挂在第一方客户端下（外键需要该记录；OAuth2端点拒绝该客户端）
+	firstParty := auth.OAuth2Client{
+		ID:           auth.FirstPartyClientID,
+		Name:         "Zervigo",
+		RedirectURIs: []string{},
+		Scopes:       []string{},
+		GrantTypes:   []string{auth.GrantTypeRefreshToken},
+		Status:       "active",
+	}
+	if err := db.Where("id = ?", firstParty.ID).FirstOrCreate(&firstParty).Error; err != nil {
+		return fmt.Errorf("初始化第一方OAuth2客户端失败: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ CREATE TABLE IF NOT EXISTS oauth2_clients (
     name VARCHAR(255) NOT NULL,
     redirect_uris JSONB NOT NULL DEFAULT '[]',
     scopes JSONB DEFAULT '[]',
+    grant_types JSONB DEFAULT '[]',  -- 为空时允许 authorization_code 与 refresh_token
     status VARCHAR(50) DEFAULT 'active',
     created_at TIMESTAMP DEFAULT NOW(),
     updated_at TIMESTAMP DEFAULT NOW()
@@ -23,6 +24,7 @@ COMMENT ON COLUMN oauth2_clients.client_secret IS '客户端密钥（加密存
 COMMENT ON COLUMN oauth2_clients.name IS '客户端名称';
 COMMENT ON COLUMN oauth2_clients.redirect_uris IS '允许的重定向 URI 列表（JSON 数组）';
 COMMENT ON COLUMN oauth2_clients.scopes IS '允许的权限范围（JSON 数组）';
+COMMENT ON COLUMN oauth2_clients.grant_types IS '允许的授权类型（JSON 数组）';
 COMMENT ON COLUMN oauth2_clients.status IS '客户端状态：active, inactive, revoked';
 
 -- 授权码表
@@ -34,6 +36,7 @@ CREATE TABLE IF NOT EXISTS oauth2_authorization_codes (
     scope VARCHAR(255),
     code_challenge VARCHAR(255),  -- PKCE support
     code_challenge_method VARCHAR(10),  -- 'plain' or 'S256'
+    nonce VARCHAR(255),  -- OIDC nonce
     expires_at TIMESTAMP NOT NULL,
     created_at TIMESTAMP DEFAULT NOW(),
     FOREIGN KEY (user_id) REFERENCES zervigo_auth_users(id) ON DELETE CASCADE,
@@ -45,7 +48,7 @@ CREATE INDEX idx_oauth2_codes_user ON oauth2_authorization_codes(user_id);
 CREATE INDEX idx_oauth2_codes_expires ON oauth2_authorization_codes(expires_at);
 
 COMMENT ON TABLE oauth2_authorization_codes IS 'OAuth2 授权码表，存储临时授权码';
-COMMENT ON COLUMN oauth2_authorization_codes.code IS '授权码（唯一标识符）';
+COMMENT ON COLUMN oauth2_authorization_codes.code IS '授权码的 SHA-256 摘要';
 COMMENT ON COLUMN oauth2_authorization_codes.client_id IS '客户端 ID';
 COMMENT ON COLUMN oauth2_authorization_codes.user_id IS '用户 ID';
 COMMENT ON COLUMN oauth2_authorization_codes.redirect_uri IS '重定向 URI';
@@ -57,9 +60,13 @@ COMMENT ON COLUMN oauth2_authorization_codes.expires_at IS '过期时间';
 -- 刷新令牌表
 CREATE TABLE IF NOT EXISTS oauth2_refresh_tokens (
     token VARCHAR(255) PRIMARY KEY,
+    family_id VARCHAR(64),  -- 同一次授权轮换出的令牌共享
     client_id VARCHAR(255) NOT NULL,
     user_id INTEGER NOT NULL,
     scope VARCHAR(255),
+    replaced_by VARCHAR(255),
+    client_ip VARCHAR(45),
+    user_agent TEXT,
     expires_at TIMESTAMP NOT NULL,
     created_at TIMESTAMP DEFAULT NOW(),
     revoked_at TIMESTAMP,
@@ -67,13 +74,16 @@ CREATE TABLE IF NOT EXISTS oauth2_refresh_tokens (
     FOREIGN KEY (client_id) REFERENCES oauth2_clients(id) ON DELETE CASCADE
 );
 
+CREATE INDEX idx_oauth2_refresh_family ON oauth2_refresh_tokens(family_id);
 CREATE INDEX idx_oauth2_refresh_client ON oauth2_refresh_tokens(client_id);
 CREATE INDEX idx_oauth2_refresh_user ON oauth2_refresh_tokens(user_id);
 CREATE INDEX idx_oauth2_refresh_expires ON oauth2_refresh_tokens(expires_at);
 CREATE INDEX idx_oauth2_refresh_revoked ON oauth2_refresh_tokens(revoked_at);
 
 COMMENT ON TABLE oauth2_refresh_tokens IS 'OAuth2 刷新令牌表，存储刷新令牌';
-COMMENT ON COLUMN oauth2_refresh_tokens.token IS '刷新令牌（唯一标识符）';
+COMMENT ON COLUMN oauth2_refresh_tokens.token IS '刷新令牌的 SHA-256 摘要';
+COMMENT ON COLUMN oauth2_refresh_tokens.family_id IS '令牌家族 ID（检测到重放时整个家族被吊销）';
+COMMENT ON COLUMN oauth2_refresh_tokens.replaced_by IS '轮换后的新令牌摘要';
 COMMENT ON COLUMN oauth2_refresh_tokens.client_id IS '客户端 ID';
 COMMENT ON COLUMN oauth2_refresh_tokens.user_id IS '用户 ID';
 COMMENT ON COLUMN oauth2_refresh_tokens.scope IS '权限范围';
@@ -107,6 +117,19 @@ COMMENT ON COLUMN oauth2_access_tokens.scope IS '权限范围';
 COMMENT ON COLUMN oauth2_access_tokens.expires_at IS '过期时间';
 COMMENT ON COLUMN oauth2_access_tokens.revoked_at IS '撤销时间（NULL 表示未撤销）';
 
+-- 已部署旧版表结构时补齐新增列
+ALTER TABLE oauth2_clients ADD COLUMN IF NOT EXISTS grant_types JSONB DEFAULT '[]';
+ALTER TABLE oauth2_authorization_codes ADD COLUMN IF NOT EXISTS nonce VARCHAR(255);
+ALTER TABLE oauth2_refresh_tokens ADD COLUMN IF NOT EXISTS family_id VARCHAR(64);
+ALTER TABLE oauth2_refresh_tokens ADD COLUMN IF NOT EXISTS replaced_by VARCHAR(255);
+ALTER TABLE oauth2_refresh_tokens ADD COLUMN IF NOT EXISTS client_ip VARCHAR(45);
+ALTER TABLE oauth2_refresh_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+
+-- 第一方客户端：用户服务签发的刷新令牌归属于它（OAuth2 端点拒绝该客户端）
+INSERT INTO oauth2_clients (id, client_secret, name, redirect_uris, scopes, grant_types, status)
+VALUES ('zervigo', '', 'Zervigo', '[]', '[]', '["refresh_token"]', 'active')
+ON CONFLICT (id) DO NOTHING;
+
 -- 清理过期数据的函数（可选）
 CREATE OR REPLACE FUNCTION cleanup_expired_oauth2_tokens()
 RETURNS void AS $$
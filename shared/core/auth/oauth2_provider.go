@@ -0,0 +1,773 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// OAuth2 授权类型
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// OIDC 标准scope
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// supportedScopes 客户端未限定scope时可申请的范围
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// OAuth2Error 符合RFC 6749的错误（error/error_description）
+type OAuth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *OAuth2Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// newOAuth2Error 按错误码确定HTTP状态码
+func newOAuth2Error(code, description string) *OAuth2Error {
+	status := http.StatusBadRequest
+	switch code {
+	case "invalid_client", "invalid_token", "login_required":
+		status = http.StatusUnauthorized
+	case "insufficient_scope", "access_denied":
+		status = http.StatusForbidden
+	case "server_error":
+		status = http.StatusInternalServerError
+	}
+	return &OAuth2Error{Code: code, Description: description, Status: status}
+}
+
+// OAuth2Config 授权服务器配置
+type OAuth2Config struct {
+	Issuer          string // 签发者，需与合作方配置的issuer完全一致
+	LoginURL        string // 未登录时跳转的登录页，登录后带 return_to 回到授权端点
+	CodeTTL         time.Duration
+	AccessTokenTTL  time.Duration
+	IDTokenTTL      time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// OAuth2ConfigFromEnv 从 OAUTH2_* 环境变量读取配置
+func OAuth2ConfigFromEnv(port int) OAuth2Config {
+	config := OAuth2Config{
+		Issuer:          os.Getenv("OAUTH2_ISSUER"),
+		LoginURL:        os.Getenv("OAUTH2_LOGIN_URL"),
+		CodeTTL:         5 * time.Minute,
+		AccessTokenTTL:  time.Hour,
+		IDTokenTTL:      time.Hour,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+	}
+	if config.Issuer == "" {
+		config.Issuer = fmt.Sprintf("http://localhost:%d", port)
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	for env, target := range map[string]*time.Duration{
+		"OAUTH2_ACCESS_TOKEN_TTL":  &config.AccessTokenTTL,
+		"OAUTH2_REFRESH_TOKEN_TTL": &config.RefreshTokenTTL,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			log.Printf("WARN: %s=%q 无效，使用默认值 %s", env, value, *target)
+			continue
+		}
+		*target = duration
+	}
+	return config
+}
+
+// OAuth2AccessClaims OAuth2访问令牌声明（RFC 9068 JWT访问令牌）
+// 用户授权时 sub 为用户ID，client_credentials 时 sub 为客户端ID。
+type OAuth2AccessClaims struct {
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenClaims OIDC ID Token声明
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// OAuth2Provider OAuth2/OIDC授权服务器
+type OAuth2Provider struct {
+	authSystem *UnifiedAuthSystem
+	config     OAuth2Config
+	keySet     *KeySet // 与用户令牌共用的签名密钥集，按issuer区分用途
+}
+
+// NewOAuth2Provider 创建授权服务器（需先为authSystem设置签名密钥集）
+func NewOAuth2Provider(authSystem *UnifiedAuthSystem, config OAuth2Config) (*OAuth2Provider, error) {
+	keySet := authSystem.KeySet()
+	if keySet == nil {
+		return nil, errors.New("未配置签名密钥集")
+	}
+	return &OAuth2Provider{
+		authSystem: authSystem,
+		config:     config,
+		keySet:     keySet,
+	}, nil
+}
+
+// IsPublic 公开客户端没有密钥
+func (c *OAuth2Client) IsPublic() bool {
+	return c.Secret == ""
+}
+
+// AllowsGrant 客户端是否允许使用该授权类型
+func (c *OAuth2Client) AllowsGrant(grantType string) bool {
+	grantTypes := c.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+	}
+	return containsString(grantTypes, grantType)
+}
+
+// AuthenticateClient 校验客户端凭证；公开客户端只校验client_id
+func (p *OAuth2Provider) AuthenticateClient(clientID, clientSecret string) (*OAuth2Client, *OAuth2Error) {
+	if clientID == "" {
+		return nil, newOAuth2Error("invalid_client", "缺少client_id")
+	}
+	if clientID == FirstPartyClientID {
+		// 第一方刷新令牌只能通过用户服务的 /auth/refresh 使用
+		return nil, newOAuth2Error("invalid_client", "第一方客户端不能使用OAuth2端点")
+	}
+	client, err := p.authSystem.getOAuth2Client(clientID)
+	if errors.Is(err, errOAuth2NotFound) {
+		return nil, newOAuth2Error("invalid_client", "客户端不存在")
+	}
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return nil, newOAuth2Error("server_error", "查询客户端失败")
+	}
+	if client.Status != "active" {
+		return nil, newOAuth2Error("invalid_client", "客户端已停用")
+	}
+	if client.IsPublic() {
+		return client, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(client.Secret), []byte(clientSecret)) != nil {
+		return nil, newOAuth2Error("invalid_client", "客户端认证失败")
+	}
+	return client, nil
+}
+
+// ValidateAuthorizeClient 校验授权请求的客户端与redirect_uri
+// 返回错误时不得重定向回redirect_uri（否则会成为开放重定向）。
+func (p *OAuth2Provider) ValidateAuthorizeClient(req *OAuth2AuthorizationRequest) (*OAuth2Client, *OAuth2Error) {
+	client, err := p.authSystem.getOAuth2Client(req.ClientID)
+	if err != nil {
+		if !errors.Is(err, errOAuth2NotFound) {
+			log.Printf("WARN: %v", err)
+		}
+		return nil, newOAuth2Error("invalid_request", "客户端不存在")
+	}
+	if client.Status != "active" {
+		return nil, newOAuth2Error("invalid_request", "客户端已停用")
+	}
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		return nil, newOAuth2Error("invalid_request", "redirect_uri 未注册")
+	}
+	return client, nil
+}
+
+// CreateAuthorizationCode 为已登录用户签发授权码
+func (p *OAuth2Provider) CreateAuthorizationCode(client *OAuth2Client, req *OAuth2AuthorizationRequest, user *UserInfo) (string, *OAuth2Error) {
+	if req.ResponseType != "code" {
+		return "", newOAuth2Error("unsupported_response_type", "仅支持 response_type=code")
+	}
+	if !client.AllowsGrant(GrantTypeAuthorizationCode) {
+		return "", newOAuth2Error("unauthorized_client", "客户端未开通授权码模式")
+	}
+
+	scope, oauthErr := p.resolveScope(client, req.Scope)
+	if oauthErr != nil {
+		return "", oauthErr
+	}
+
+	// PKCE：公开客户端必须使用；未指定方法时按RFC 7636默认为plain
+	method := req.CodeChallengeMethod
+	if req.CodeChallenge == "" {
+		if client.IsPublic() {
+			return "", newOAuth2Error("invalid_request", "公开客户端必须使用PKCE（code_challenge）")
+		}
+		method = ""
+	} else if method == "" {
+		method = "plain"
+	}
+	if method != "" && method != "S256" && method != "plain" {
+		return "", newOAuth2Error("invalid_request", "code_challenge_method 仅支持 S256 与 plain")
+	}
+
+	code, codeHash, err := generateRefreshToken()
+	if err != nil {
+		return "", newOAuth2Error("server_error", "生成授权码失败")
+	}
+	record := &AuthorizationCode{
+		Code:                codeHash,
+		ClientID:            client.ID,
+		UserID:              uint(user.ID),
+		RedirectURI:         req.RedirectURI,
+		Scope:               scope,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: method,
+		Nonce:               req.Nonce,
+		ExpiresAt:           time.Now().Add(p.config.CodeTTL),
+	}
+	if err := p.authSystem.saveAuthorizationCode(record); err != nil {
+		log.Printf("WARN: %v", err)
+		return "", newOAuth2Error("server_error", "保存授权码失败")
+	}
+	return code, nil
+}
+
+// AuthorizationRedirect 拼接回调地址（成功时带code，失败时带error）
+func AuthorizationRedirect(redirectURI, state string, params url.Values) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	return redirectURI + separator + params.Encode()
+}
+
+// ExchangeAuthorizationCode authorization_code 授权：兑换授权码
+func (p *OAuth2Provider) ExchangeAuthorizationCode(client *OAuth2Client, code, redirectURI, codeVerifier, clientIP, userAgent string) (*OAuth2TokenResponse, *OAuth2Error) {
+	if !client.AllowsGrant(GrantTypeAuthorizationCode) {
+		return nil, newOAuth2Error("unauthorized_client", "客户端未开通授权码模式")
+	}
+
+	record, err := p.authSystem.consumeAuthorizationCode(hashRefreshToken(code))
+	if errors.Is(err, errOAuth2NotFound) {
+		return nil, newOAuth2Error("invalid_grant", "授权码无效或已使用")
+	}
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return nil, newOAuth2Error("server_error", "兑换授权码失败")
+	}
+
+	if record.ClientID != client.ID {
+		return nil, newOAuth2Error("invalid_grant", "授权码不属于该客户端")
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, newOAuth2Error("invalid_grant", "授权码已过期")
+	}
+	if record.RedirectURI != redirectURI {
+		return nil, newOAuth2Error("invalid_grant", "redirect_uri 与授权请求不一致")
+	}
+	if record.CodeChallenge != "" {
+		if !verifyPKCE(record.CodeChallenge, record.CodeChallengeMethod, codeVerifier) {
+			return nil, newOAuth2Error("invalid_grant", "code_verifier 校验失败")
+		}
+	} else if client.IsPublic() {
+		return nil, newOAuth2Error("invalid_grant", "公开客户端必须使用PKCE")
+	}
+
+	user, oauthErr := p.activeUser(int(record.UserID))
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	resp, oauthErr := p.issueUserTokens(client, user, record.Scope, record.Nonce)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	if client.AllowsGrant(GrantTypeRefreshToken) {
+		refreshToken, refreshHash, err := generateRefreshToken()
+		if err != nil {
+			return nil, newOAuth2Error("server_error", "生成刷新令牌失败")
+		}
+		err = p.authSystem.insertRefreshToken(p.authSystem.db, &RefreshToken{
+			Token:     refreshHash,
+			FamilyID:  uuid.New().String(),
+			ClientID:  client.ID,
+			UserID:    uint(user.ID),
+			Scope:     record.Scope,
+			ClientIP:  clientIP,
+			UserAgent: userAgent,
+			ExpiresAt: time.Now().Add(p.config.RefreshTokenTTL),
+		})
+		if err != nil {
+			log.Printf("WARN: %v", err)
+			return nil, newOAuth2Error("server_error", "保存刷新令牌失败")
+		}
+		resp.RefreshToken = refreshToken
+	}
+
+	p.authSystem.logAccess(user.ID, "oauth2_authorize:"+client.ID, "auth", "success", clientIP, userAgent)
+	return resp, nil
+}
+
+// RefreshGrant refresh_token 授权：轮换刷新令牌，已轮换的令牌再次出现时吊销整个家族
+func (p *OAuth2Provider) RefreshGrant(client *OAuth2Client, refreshToken, scope, clientIP, userAgent string) (*OAuth2TokenResponse, *OAuth2Error) {
+	if !client.AllowsGrant(GrantTypeRefreshToken) {
+		return nil, newOAuth2Error("unauthorized_client", "客户端未开通刷新令牌")
+	}
+
+	tokenHash := hashRefreshToken(refreshToken)
+	record, err := p.authSystem.getRefreshToken(tokenHash)
+	if errors.Is(err, errOAuth2NotFound) {
+		return nil, newOAuth2Error("invalid_grant", ErrRefreshTokenInvalid.Error())
+	}
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return nil, newOAuth2Error("server_error", "查询刷新令牌失败")
+	}
+	if record.ClientID != client.ID {
+		return nil, newOAuth2Error("invalid_grant", "刷新令牌不属于该客户端")
+	}
+	if record.RevokedAt != nil {
+		if record.ReplacedBy != "" {
+			p.handleRefreshTokenReuse(record, clientIP, userAgent)
+			return nil, newOAuth2Error("invalid_grant", ErrRefreshTokenReused.Error())
+		}
+		return nil, newOAuth2Error("invalid_grant", ErrRefreshTokenRevoked.Error())
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, newOAuth2Error("invalid_grant", ErrRefreshTokenExpired.Error())
+	}
+
+	// 刷新时只能缩小scope（RFC 6749 §6）
+	grantedScope := record.Scope
+	if strings.TrimSpace(scope) != "" {
+		granted := strings.Fields(record.Scope)
+		for _, s := range strings.Fields(scope) {
+			if !containsString(granted, s) {
+				return nil, newOAuth2Error("invalid_scope", "不能超出原授权范围: "+s)
+			}
+		}
+		grantedScope = strings.Join(strings.Fields(scope), " ")
+	}
+
+	user, oauthErr := p.activeUser(int(record.UserID))
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	newToken, newHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, newOAuth2Error("server_error", "生成刷新令牌失败")
+	}
+	err = p.authSystem.rotateRefreshToken(tokenHash, &RefreshToken{
+		Token:     newHash,
+		FamilyID:  record.FamilyID,
+		ClientID:  client.ID,
+		UserID:    record.UserID,
+		Scope:     grantedScope,
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(p.config.RefreshTokenTTL),
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		p.handleRefreshTokenReuse(record, clientIP, userAgent)
+		return nil, newOAuth2Error("invalid_grant", ErrRefreshTokenReused.Error())
+	}
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return nil, newOAuth2Error("server_error", "轮换刷新令牌失败")
+	}
+
+	resp, oauthErr := p.issueUserTokens(client, user, grantedScope, "")
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+	resp.RefreshToken = newToken
+	return resp, nil
+}
+
+// ClientCredentials client_credentials 授权：服务账号以自身身份获取访问令牌
+func (p *OAuth2Provider) ClientCredentials(client *OAuth2Client, scope string) (*OAuth2TokenResponse, *OAuth2Error) {
+	if client.IsPublic() || !client.AllowsGrant(GrantTypeClientCredentials) {
+		return nil, newOAuth2Error("unauthorized_client", "客户端未开通client_credentials模式")
+	}
+	grantedScope, oauthErr := p.resolveScope(client, scope)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	accessToken, err := p.issueAccessToken(client, client.ID, grantedScope)
+	if err != nil {
+		log.Printf("WARN: 签发访问令牌失败: %v", err)
+		return nil, newOAuth2Error("server_error", "签发访问令牌失败")
+	}
+	return &OAuth2TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(p.config.AccessTokenTTL.Seconds()),
+		Scope:       grantedScope,
+	}, nil
+}
+
+// ParseAccessToken 校验本服务签发的OAuth2访问令牌
+func (p *OAuth2Provider) ParseAccessToken(tokenString string) (*OAuth2AccessClaims, error) {
+	claims := &OAuth2AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, jwtKeyFunc(p.keySet, ""),
+		jwt.WithIssuer(p.config.Issuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID == "" {
+		return nil, errors.New("不是OAuth2访问令牌")
+	}
+	if err := checkRevoked(p.authSystem.revocations, claims.ID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// UserInfo OIDC /userinfo：按访问令牌的scope返回用户声明
+func (p *OAuth2Provider) UserInfo(accessToken string) (*OAuth2UserInfoResponse, *OAuth2Error) {
+	claims, err := p.ParseAccessToken(accessToken)
+	if err != nil {
+		return nil, newOAuth2Error("invalid_token", err.Error())
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !containsString(scopes, ScopeOpenID) {
+		return nil, newOAuth2Error("insufficient_scope", "访问令牌缺少 openid scope")
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, newOAuth2Error("invalid_token", "访问令牌未关联用户")
+	}
+	user, oauthErr := p.activeUser(userID)
+	if oauthErr != nil {
+		return nil, newOAuth2Error("invalid_token", oauthErr.Description)
+	}
+
+	info := &OAuth2UserInfoResponse{Sub: claims.Subject}
+	if containsString(scopes, ScopeProfile) {
+		info.Name = user.Username
+	}
+	if containsString(scopes, ScopeEmail) {
+		info.Email = user.Email
+		info.EmailVerified = user.EmailVerified
+	}
+	return info, nil
+}
+
+// Introspect 令牌内省（RFC 7662）；刷新令牌只允许所属客户端查询
+func (p *OAuth2Provider) Introspect(client *OAuth2Client, token, tokenTypeHint string) map[string]interface{} {
+	if tokenTypeHint != GrantTypeRefreshToken {
+		if claims, err := p.ParseAccessToken(token); err == nil {
+			result := map[string]interface{}{
+				"active":     true,
+				"token_type": "Bearer",
+				"client_id":  claims.ClientID,
+				"sub":        claims.Subject,
+				"scope":      claims.Scope,
+				"iss":        claims.Issuer,
+				"jti":        claims.ID,
+				"exp":        claims.ExpiresAt.Unix(),
+			}
+			if claims.IssuedAt != nil {
+				result["iat"] = claims.IssuedAt.Unix()
+			}
+			return result
+		}
+	}
+
+	record, err := p.authSystem.getRefreshToken(hashRefreshToken(token))
+	if err == nil && record.ClientID == client.ID && record.RevokedAt == nil && time.Now().Before(record.ExpiresAt) {
+		return map[string]interface{}{
+			"active":     true,
+			"token_type": GrantTypeRefreshToken,
+			"client_id":  record.ClientID,
+			"sub":        strconv.FormatUint(uint64(record.UserID), 10),
+			"scope":      record.Scope,
+			"exp":        record.ExpiresAt.Unix(),
+		}
+	}
+	return map[string]interface{}{"active": false}
+}
+
+// Revoke 令牌吊销（RFC 7009）；令牌不存在或不属于该客户端时静默成功
+func (p *OAuth2Provider) Revoke(client *OAuth2Client, token, tokenTypeHint string) *OAuth2Error {
+	if tokenTypeHint != "access_token" {
+		record, err := p.authSystem.getRefreshToken(hashRefreshToken(token))
+		if err == nil && record.ClientID == client.ID {
+			if err := p.authSystem.revokeRefreshFamily(record.FamilyID); err != nil {
+				log.Printf("WARN: %v", err)
+				return newOAuth2Error("server_error", "吊销刷新令牌失败")
+			}
+			return nil
+		}
+		if err != nil && !errors.Is(err, errOAuth2NotFound) {
+			log.Printf("WARN: %v", err)
+			return newOAuth2Error("server_error", "查询刷新令牌失败")
+		}
+	}
+
+	claims, err := p.ParseAccessToken(token)
+	if err != nil || claims.ClientID != client.ID || claims.ID == "" {
+		return nil
+	}
+	if err := p.authSystem.revokeJTI(claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("WARN: %v", err)
+		return newOAuth2Error("server_error", "吊销访问令牌失败")
+	}
+	return nil
+}
+
+// RegisterClient 注册OAuth2客户端，返回的明文密钥只在此时可见
+func (p *OAuth2Provider) RegisterClient(name string, redirectURIs, scopes, grantTypes []string, public bool) (*OAuth2Client, string, error) {
+	if name == "" {
+		return nil, "", errors.New("客户端名称不能为空")
+	}
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+	}
+	for _, grantType := range grantTypes {
+		switch grantType {
+		case GrantTypeAuthorizationCode, GrantTypeRefreshToken:
+		case GrantTypeClientCredentials:
+			if public {
+				return nil, "", errors.New("公开客户端不能使用client_credentials模式")
+			}
+		default:
+			return nil, "", fmt.Errorf("不支持的授权类型: %s", grantType)
+		}
+	}
+	if containsString(grantTypes, GrantTypeAuthorizationCode) && len(redirectURIs) == 0 {
+		return nil, "", errors.New("授权码模式必须登记redirect_uris")
+	}
+	for _, redirectURI := range redirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return nil, "", fmt.Errorf("无效的redirect_uri: %s", redirectURI)
+		}
+	}
+
+	client := &OAuth2Client{
+		ID:           uuid.New().String(),
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		GrantTypes:   grantTypes,
+		Status:       "active",
+	}
+
+	var secret string
+	if !public {
+		generated, _, err := generateRefreshToken()
+		if err != nil {
+			return nil, "", err
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(generated), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, "", fmt.Errorf("加密客户端密钥失败: %w", err)
+		}
+		secret = generated
+		client.Secret = string(hashed)
+	}
+
+	if err := p.authSystem.createOAuth2Client(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// Discovery OIDC发现文档
+func (p *OAuth2Provider) Discovery() map[string]interface{} {
+	issuer := p.config.Issuer
+	return map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth2/authorize",
+		"token_endpoint":                        issuer + "/oauth2/token",
+		"userinfo_endpoint":                     issuer + "/oauth2/userinfo",
+		"introspection_endpoint":                issuer + "/oauth2/introspect",
+		"revocation_endpoint":                   issuer + "/oauth2/revoke",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials, GrantTypeRefreshToken},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{p.keySet.Algorithm()},
+		"scopes_supported":                      supportedScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "nonce", "name", "preferred_username", "email", "email_verified"},
+	}
+}
+
+// LoginURL 未登录时的登录页地址（未配置时返回空）
+func (p *OAuth2Provider) LoginURL(returnTo string) string {
+	if p.config.LoginURL == "" {
+		return ""
+	}
+	return AuthorizationRedirect(p.config.LoginURL, "", url.Values{"return_to": {returnTo}})
+}
+
+// Issuer 签发者
+func (p *OAuth2Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// issueUserTokens 为用户签发访问令牌，scope包含openid时同时签发ID Token
+func (p *OAuth2Provider) issueUserTokens(client *OAuth2Client, user *UserInfo, scope, nonce string) (*OAuth2TokenResponse, *OAuth2Error) {
+	accessToken, err := p.issueAccessToken(client, strconv.Itoa(user.ID), scope)
+	if err != nil {
+		log.Printf("WARN: 签发访问令牌失败: %v", err)
+		return nil, newOAuth2Error("server_error", "签发访问令牌失败")
+	}
+	resp := &OAuth2TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(p.config.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}
+
+	scopes := strings.Fields(scope)
+	if containsString(scopes, ScopeOpenID) {
+		now := time.Now()
+		claims := &IDTokenClaims{
+			Nonce: nonce,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    p.config.Issuer,
+				Subject:   strconv.Itoa(user.ID),
+				Audience:  jwt.ClaimStrings{client.ID},
+				ExpiresAt: jwt.NewNumericDate(now.Add(p.config.IDTokenTTL)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+		}
+		if containsString(scopes, ScopeProfile) {
+			claims.Name = user.Username
+			claims.PreferredUsername = user.Username
+		}
+		if containsString(scopes, ScopeEmail) {
+			emailVerified := user.EmailVerified
+			claims.Email = user.Email
+			claims.EmailVerified = &emailVerified
+		}
+		idToken, err := p.keySet.Sign(claims, "")
+		if err != nil {
+			log.Printf("WARN: 签发ID Token失败: %v", err)
+			return nil, newOAuth2Error("server_error", "签发ID Token失败")
+		}
+		resp.IDToken = idToken
+	}
+	return resp, nil
+}
+
+// issueAccessToken 签发JWT访问令牌（typ=at+jwt）
+func (p *OAuth2Provider) issueAccessToken(client *OAuth2Client, subject, scope string) (string, error) {
+	now := time.Now()
+	claims := &OAuth2AccessClaims{
+		ClientID: client.ID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    p.config.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{client.ID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(p.config.AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	return p.keySet.Sign(claims, "at+jwt")
+}
+
+// resolveScope 校验申请的scope；未申请时授予客户端允许的全部scope
+func (p *OAuth2Provider) resolveScope(client *OAuth2Client, requested string) (string, *OAuth2Error) {
+	allowed := client.Scopes
+	if len(allowed) == 0 {
+		allowed = supportedScopes
+	}
+	if strings.TrimSpace(requested) == "" {
+		return strings.Join(allowed, " "), nil
+	}
+
+	granted := make([]string, 0)
+	for _, scope := range strings.Fields(requested) {
+		if !containsString(allowed, scope) {
+			return "", newOAuth2Error("invalid_scope", "不允许的scope: "+scope)
+		}
+		if !containsString(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// activeUser 查询状态正常的用户
+func (p *OAuth2Provider) activeUser(userID int) (*UserInfo, *OAuth2Error) {
+	user, err := p.authSystem.getUserByID(userID)
+	if err != nil {
+		return nil, newOAuth2Error("invalid_grant", "用户不存在")
+	}
+	if user.Status != "active" {
+		return nil, newOAuth2Error("invalid_grant", "用户账户已被禁用")
+	}
+	return user, nil
+}
+
+// handleRefreshTokenReuse 刷新令牌被重复使用：吊销整个家族并记录安全日志
+func (p *OAuth2Provider) handleRefreshTokenReuse(record *RefreshToken, clientIP, userAgent string) {
+	log.Printf("WARN: 检测到OAuth2刷新令牌重复使用 client=%s user_id=%d family=%s ip=%s",
+		record.ClientID, record.UserID, record.FamilyID, clientIP)
+	if err := p.authSystem.revokeRefreshFamily(record.FamilyID); err != nil {
+		log.Printf("ERROR: %v", err)
+	}
+	p.authSystem.logAccess(int(record.UserID), "oauth2_refresh:"+record.ClientID, "auth", "refresh_token_reused", clientIP, userAgent)
+}
+
+// verifyPKCE 校验code_verifier（RFC 7636）
+func verifyPKCE(challenge, method, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	expected := verifier
+	if method == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errOAuth2NotFound 客户端、授权码或刷新令牌不存在
+var errOAuth2NotFound = errors.New("记录不存在")
+
+// sqlExecer *sql.DB 与 *sql.Tx 共有的写操作
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// getOAuth2Client 查询OAuth2客户端
+func (uas *UnifiedAuthSystem) getOAuth2Client(clientID string) (*OAuth2Client, error) {
+	query := fmt.Sprintf(`
+        SELECT id, client_secret, name, redirect_uris, scopes, grant_types, COALESCE(status, 'active')
+        FROM oauth2_clients
+        WHERE id = %s
+    `, uas.placeholder(1))
+
+	var client OAuth2Client
+	var redirectURIs, scopes, grantTypes []byte
+	err := uas.db.QueryRow(query, clientID).Scan(
+		&client.ID, &client.Secret, &client.Name, &redirectURIs, &scopes, &grantTypes, &client.Status,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errOAuth2NotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询OAuth2客户端失败: %w", err)
+	}
+
+	for _, field := range []struct {
+		raw    []byte
+		target *[]string
+	}{{redirectURIs, &client.RedirectURIs}, {scopes, &client.Scopes}, {grantTypes, &client.GrantTypes}} {
+		if len(field.raw) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(field.raw, field.target); err != nil {
+			return nil, fmt.Errorf("解析OAuth2客户端 %s 配置失败: %w", clientID, err)
+		}
+	}
+	return &client, nil
+}
+
+// createOAuth2Client 保存OAuth2客户端（Secret 应已是bcrypt摘要）
+func (uas *UnifiedAuthSystem) createOAuth2Client(client *OAuth2Client) error {
+	redirectURIs, _ := json.Marshal(nonNilStrings(client.RedirectURIs))
+	scopes, _ := json.Marshal(nonNilStrings(client.Scopes))
+	grantTypes, _ := json.Marshal(nonNilStrings(client.GrantTypes))
+
+	now := time.Now()
+	query := fmt.Sprintf(`
+        INSERT INTO oauth2_clients (id, client_secret, name, redirect_uris, scopes, grant_types, status, created_at, updated_at)
+        VALUES (%s)
+    `, uas.makePlaceholders(9))
+	_, err := uas.db.Exec(query, client.ID, client.Secret, client.Name,
+		string(redirectURIs), string(scopes), string(grantTypes), client.Status, now, now)
+	if err != nil {
+		return fmt.Errorf("保存OAuth2客户端失败: %w", err)
+	}
+	client.CreatedAt = now
+	client.UpdatedAt = now
+	return nil
+}
+
+// saveAuthorizationCode 保存授权码
+func (uas *UnifiedAuthSystem) saveAuthorizationCode(code *AuthorizationCode) error {
+	query := fmt.Sprintf(`
+        INSERT INTO oauth2_authorization_codes
+        (code, client_id, user_id, redirect_uri, scope, code_challenge, code_challenge_method, nonce, expires_at, created_at)
+        VALUES (%s)
+    `, uas.makePlaceholders(10))
+	_, err := uas.db.Exec(query, code.Code, code.ClientID, code.UserID, code.RedirectURI, code.Scope,
+		code.CodeChallenge, code.CodeChallengeMethod, code.Nonce, code.ExpiresAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("保存授权码失败: %w", err)
+	}
+	return nil
+}
+
+// consumeAuthorizationCode 取出并删除授权码；并发兑换同一授权码时只有一个请求成功
+func (uas *UnifiedAuthSystem) consumeAuthorizationCode(codeHash string) (*AuthorizationCode, error) {
+	tx, err := uas.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+        SELECT code, client_id, user_id, redirect_uri, COALESCE(scope, ''),
+               COALESCE(code_challenge, ''), COALESCE(code_challenge_method, ''), COALESCE(nonce, ''), expires_at
+        FROM oauth2_authorization_codes
+        WHERE code = %s
+        FOR UPDATE
+    `, uas.placeholder(1))
+
+	var code AuthorizationCode
+	err = tx.QueryRow(query, codeHash).Scan(&code.Code, &code.ClientID, &code.UserID, &code.RedirectURI, &code.Scope,
+		&code.CodeChallenge, &code.CodeChallengeMethod, &code.Nonce, &code.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errOAuth2NotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询授权码失败: %w", err)
+	}
+
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM oauth2_authorization_codes WHERE code = %s", uas.placeholder(1)), codeHash)
+	if err != nil {
+		return nil, fmt.Errorf("删除授权码失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, errOAuth2NotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交授权码事务失败: %w", err)
+	}
+	return &code, nil
+}
+
+// getRefreshToken 按摘要查询刷新令牌
+func (uas *UnifiedAuthSystem) getRefreshToken(tokenHash string) (*RefreshToken, error) {
+	query := fmt.Sprintf(`
+        SELECT token, COALESCE(family_id, ''), client_id, user_id, COALESCE(scope, ''),
+               COALESCE(replaced_by, ''), expires_at, revoked_at
+        FROM oauth2_refresh_tokens
+        WHERE token = %s
+    `, uas.placeholder(1))
+
+	var record RefreshToken
+	var revokedAt sql.NullTime
+	err := uas.db.QueryRow(query, tokenHash).Scan(&record.Token, &record.FamilyID, &record.ClientID, &record.UserID,
+		&record.Scope, &record.ReplacedBy, &record.ExpiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errOAuth2NotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询刷新令牌失败: %w", err)
+	}
+	if revokedAt.Valid {
+		record.RevokedAt = &revokedAt.Time
+	}
+	return &record, nil
+}
+
+// insertRefreshToken 写入刷新令牌
+func (uas *UnifiedAuthSystem) insertRefreshToken(exec sqlExecer, record *RefreshToken) error {
+	query := fmt.Sprintf(`
+        INSERT INTO oauth2_refresh_tokens
+        (token, family_id, client_id, user_id, scope, client_ip, user_agent, expires_at, created_at)
+        VALUES (%s)
+    `, uas.makePlaceholders(9))
+	_, err := exec.Exec(query, record.Token, record.FamilyID, record.ClientID, record.UserID, record.Scope,
+		record.ClientIP, record.UserAgent, record.ExpiresAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+	return nil
+}
+
+// rotateRefreshToken 吊销旧令牌并写入替代令牌；旧令牌已被轮换时返回 ErrRefreshTokenReused
+func (uas *UnifiedAuthSystem) rotateRefreshToken(oldHash string, next *RefreshToken) error {
+	tx, err := uas.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+        UPDATE oauth2_refresh_tokens SET revoked_at = %s, replaced_by = %s
+        WHERE token = %s AND revoked_at IS NULL
+    `, uas.placeholder(1), uas.placeholder(2), uas.placeholder(3))
+	result, err := tx.Exec(query, time.Now(), next.Token, oldHash)
+	if err != nil {
+		return fmt.Errorf("轮换刷新令牌失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	if err := uas.insertRefreshToken(tx, next); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交刷新令牌事务失败: %w", err)
+	}
+	return nil
+}
+
+// revokeRefreshFamily 吊销令牌家族中所有未吊销的刷新令牌
+func (uas *UnifiedAuthSystem) revokeRefreshFamily(familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+	query := fmt.Sprintf(`
+        UPDATE oauth2_refresh_tokens SET revoked_at = %s
+        WHERE family_id = %s AND revoked_at IS NULL
+    `, uas.placeholder(1), uas.placeholder(2))
+	if _, err := uas.db.Exec(query, time.Now(), familyID); err != nil {
+		return fmt.Errorf("吊销令牌家族失败: %w", err)
+	}
+	return nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxPasswordBytes bcrypt只处理前72字节，更长的密码直接拒绝
+const bcryptMaxPasswordBytes = 72
+
+// commonPasswords 内置的常见弱密码（未配置列表文件时也会拒绝）
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "p@ssw0rd",
+	"12345678", "123456789", "1234567890", "11111111", "88888888",
+	"qwerty123", "qwertyuiop", "1qaz2wsx", "abc12345", "abcd1234",
+	"admin123", "admin@123", "iloveyou", "welcome1", "zervigo123",
+}
+
+// PasswordPolicyError 密码不符合策略（列出全部不满足的规则）
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "密码不符合要求：" + strings.Join(e.Violations, "；")
+}
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength  int // 最小长度（字符数）
+	MinClasses int // 小写/大写/数字/符号中至少包含几类
+
+	denylist   map[string]struct{} // 小写明文
+	denyHashes map[string]struct{} // 大写SHA-1（兼容 Have I Been Pwned 的 HASH:COUNT 格式）
+}
+
+// NewPasswordPolicy 创建密码策略；denylistFile为空时只使用内置弱密码列表
+func NewPasswordPolicy(minLength, minClasses int, denylistFile string) (*PasswordPolicy, error) {
+	if minLength <= 0 {
+		minLength = 8
+	}
+	if minClasses <= 0 {
+		minClasses = 2
+	}
+
+	policy := &PasswordPolicy{
+		MinLength:  minLength,
+		MinClasses: minClasses,
+		denylist:   make(map[string]struct{}, len(commonPasswords)),
+		denyHashes: make(map[string]struct{}),
+	}
+	for _, password := range commonPasswords {
+		policy.denylist[password] = struct{}{}
+	}
+
+	if denylistFile != "" {
+		if err := policy.loadDenylist(denylistFile); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// PasswordPolicyFromConfig 由AuthConfig创建密码策略；列表文件加载失败时只使用内置列表
+func PasswordPolicyFromConfig(config AuthConfig) *PasswordPolicy {
+	policy, err := NewPasswordPolicy(config.PasswordMin, config.PasswordMinClasses, config.PasswordDenylistFile)
+	if err != nil {
+		log.Printf("WARN: 加载密码黑名单失败，仅使用内置弱密码列表: %v", err)
+		policy, _ = NewPasswordPolicy(config.PasswordMin, config.PasswordMinClasses, "")
+	}
+	return policy
+}
+
+// loadDenylist 加载黑名单文件：每行一个明文密码或40位SHA-1（可带 :次数 后缀），#开头为注释
+func (p *PasswordPolicy) loadDenylist(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开密码黑名单失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			p.denyHashes[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		p.denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取密码黑名单失败: %w", err)
+	}
+
+	log.Printf("INFO: 已加载密码黑名单 %s（明文 %d 条，SHA-1 %d 条）", path, len(p.denylist), len(p.denyHashes))
+	return nil
+}
+
+// Validate 校验密码；userInputs 为用户名、邮箱等，密码不能包含它们
+func (p *PasswordPolicy) Validate(password string, userInputs ...string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("长度至少%d个字符", p.MinLength))
+	}
+	if len(password) > bcryptMaxPasswordBytes {
+		violations = append(violations, fmt.Sprintf("长度不能超过%d字节", bcryptMaxPasswordBytes))
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		violations = append(violations, fmt.Sprintf("需包含小写字母、大写字母、数字、符号中的至少%d类", p.MinClasses))
+	}
+
+	lower := strings.ToLower(password)
+	for _, input := range userInputs {
+		// 邮箱只比较@前的部分
+		input, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(input)), "@")
+		if len(input) >= 3 && strings.Contains(lower, input) {
+			violations = append(violations, "不能包含用户名或邮箱")
+			break
+		}
+	}
+
+	if p.isDenied(password) {
+		violations = append(violations, "该密码过于常见或已在泄露数据中出现")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// isDenied 是否在黑名单中
+func (p *PasswordPolicy) isDenied(password string) bool {
+	if _, denied := p.denylist[strings.ToLower(password)]; denied {
+		return true
+	}
+	if len(p.denyHashes) == 0 {
+		return false
+	}
+	sum := sha1.Sum([]byte(password))
+	_, denied := p.denyHashes[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return denied
+}
+
+// characterClasses 统计包含的字符类别数
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// isSHA1Hex 是否为40位十六进制
+func isSHA1Hex(value string) bool {
+	if len(value) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
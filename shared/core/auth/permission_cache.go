@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPermissionCacheTTL = time.Minute
+	// permissionVersionCheckInterval 两次读取策略版本号的最小间隔
+	permissionVersionCheckInterval = 5 * time.Second
+)
+
+// PolicyVersionTable 角色/权限变更时递增版本号的表，各服务据此让本地权限缓存失效
+const PolicyVersionTable = "zervigo_auth_policy_version"
+
+// rolePermissions 角色展开继承后的权限
+type rolePermissions struct {
+	expressions []string
+	set         *PermissionSet
+	expiresAt   time.Time
+}
+
+// permissionCache 按角色缓存展开后的权限
+type permissionCache struct {
+	mu        sync.RWMutex
+	ttl       time.Duration
+	entries   map[string]*rolePermissions
+	version   int64
+	checkedAt time.Time
+}
+
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	if ttl <= 0 {
+		ttl = defaultPermissionCacheTTL
+	}
+	return &permissionCache{
+		ttl:     ttl,
+		entries: make(map[string]*rolePermissions),
+	}
+}
+
+// permissionCacheTTLFromEnv 读取PERMISSION_CACHE_TTL，未设置时使用默认值
+func permissionCacheTTLFromEnv() time.Duration {
+	ttl, _ := time.ParseDuration(os.Getenv("PERMISSION_CACHE_TTL"))
+	return ttl
+}
+
+func (c *permissionCache) get(role string) (*rolePermissions, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[role]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *permissionCache) put(role string, expressions []string) *rolePermissions {
+	entry := &rolePermissions{
+		expressions: expressions,
+		set:         NewPermissionSet(expressions),
+		expiresAt:   time.Now().Add(c.ttl),
+	}
+
+	c.mu.Lock()
+	c.entries[role] = entry
+	c.mu.Unlock()
+	return entry
+}
+
+func (c *permissionCache) invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[string]*rolePermissions)
+	c.mu.Unlock()
+}
+
+// versionCheckDue 距上次检查版本号超过间隔时返回true，并记录本次检查时间
+func (c *permissionCache) versionCheckDue() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checkedAt) < permissionVersionCheckInterval {
+		return false
+	}
+	c.checkedAt = time.Now()
+	return true
+}
+
+// observeVersion 记录最新的策略版本号，版本变化时清空缓存
+func (c *permissionCache) observeVersion(version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		c.version = version
+		c.entries = make(map[string]*rolePermissions)
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// 权限表达式语法：
+//
+//	resource:action        精确匹配，如 resume:read
+//	company:*              * 匹配任意一段，位于末尾时同时匹配其下所有层级（company:job:read）
+//	*                      匹配所有权限
+//	!company:delete        显式拒绝，优先于任何允许规则（包括从父角色继承的）
+//
+// 服务API路径模式：
+//
+//	/api/v1/company/*      * 匹配一段路径
+//	/api/v1/company/**     ** 匹配零段或多段路径
+//	!/api/v1/admin/**      显式拒绝
+const (
+	PermissionWildcard   = "*"
+	PermissionDenyPrefix = "!"
+
+	permissionSeparator = ":"
+	pathDeepWildcard    = "**"
+)
+
+// MaxRoleInheritanceDepth 角色继承链的最大深度
+const MaxRoleInheritanceDepth = 8
+
+var (
+	ErrInvalidPermission    = errors.New("权限表达式格式错误")
+	ErrInvalidAPIPattern    = errors.New("API路径模式格式错误")
+	ErrRoleInheritanceCycle = errors.New("角色继承存在循环")
+	ErrRoleInheritanceDepth = errors.New("角色继承层级过深")
+)
+
+// PermissionExpression 解析后的权限表达式
+type PermissionExpression struct {
+	Deny     bool
+	Segments []string
+}
+
+// Resource 资源段（首段）
+func (e PermissionExpression) Resource() string {
+	if len(e.Segments) == 0 {
+		return ""
+	}
+	return e.Segments[0]
+}
+
+// Action 操作段（末段，只有一段时为空）
+func (e PermissionExpression) Action() string {
+	if len(e.Segments) < 2 {
+		return ""
+	}
+	return e.Segments[len(e.Segments)-1]
+}
+
+// ParsePermissionExpression 解析并校验权限表达式
+func ParsePermissionExpression(expr string) (PermissionExpression, error) {
+	var parsed PermissionExpression
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, PermissionDenyPrefix) {
+		parsed.Deny = true
+		expr = strings.TrimPrefix(expr, PermissionDenyPrefix)
+	}
+	if expr == "" {
+		return parsed, fmt.Errorf("%w: 表达式为空", ErrInvalidPermission)
+	}
+
+	parsed.Segments = strings.Split(expr, permissionSeparator)
+	for _, segment := range parsed.Segments {
+		switch {
+		case segment == "":
+			return parsed, fmt.Errorf("%w: %q 含有空段", ErrInvalidPermission, expr)
+		case segment == PermissionWildcard:
+		case strings.ContainsAny(segment, "*! \t"):
+			return parsed, fmt.Errorf("%w: %q 中的 %q 非法（通配符必须独占一段）", ErrInvalidPermission, expr, segment)
+		}
+	}
+	return parsed, nil
+}
+
+// ValidatePermissionExpression 校验权限表达式
+func ValidatePermissionExpression(expr string) error {
+	_, err := ParsePermissionExpression(expr)
+	return err
+}
+
+// MatchPermission 判断权限模式（不含 ! 前缀）是否覆盖所需权限
+func MatchPermission(pattern, required string) bool {
+	if pattern == PermissionWildcard {
+		return true
+	}
+
+	patternSegments := strings.Split(pattern, permissionSeparator)
+	requiredSegments := strings.Split(required, permissionSeparator)
+	for i, segment := range patternSegments {
+		if i >= len(requiredSegments) {
+			return false
+		}
+		if segment == PermissionWildcard {
+			if i == len(patternSegments)-1 {
+				return true
+			}
+			continue
+		}
+		if segment != requiredSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(requiredSegments)
+}
+
+// PermissionSet 一组允许/拒绝规则，拒绝优先
+type PermissionSet struct {
+	allow []string
+	deny  []string
+}
+
+// NewPermissionSet 由权限表达式列表构建权限集合，非法表达式被忽略
+func NewPermissionSet(expressions []string) *PermissionSet {
+	set := &PermissionSet{}
+	for _, expr := range expressions {
+		parsed, err := ParsePermissionExpression(expr)
+		if err != nil {
+			continue
+		}
+		pattern := strings.Join(parsed.Segments, permissionSeparator)
+		if parsed.Deny {
+			set.deny = append(set.deny, pattern)
+		} else {
+			set.allow = append(set.allow, pattern)
+		}
+	}
+	return set
+}
+
+// Allows 判断是否拥有所需权限
+func (s *PermissionSet) Allows(required string) bool {
+	for _, pattern := range s.deny {
+		if MatchPermission(pattern, required) {
+			return false
+		}
+	}
+	for _, pattern := range s.allow {
+		if MatchPermission(pattern, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAPIPattern 校验服务API路径模式
+func ValidateAPIPattern(pattern string) error {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), PermissionDenyPrefix)
+	if pattern == PermissionWildcard {
+		return nil
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("%w: %q 必须以 / 开头", ErrInvalidAPIPattern, pattern)
+	}
+	for _, segment := range splitAPIPath(pattern) {
+		if segment == pathDeepWildcard {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidAPIPattern, pattern)
+		}
+	}
+	return nil
+}
+
+// MatchAPIPath 判断API路径模式（不含 ! 前缀）是否匹配请求路径
+// 请求路径先经path.Clean规范化，清理后仍含 .. 段的路径不匹配任何模式
+func MatchAPIPath(pattern, apiPath string) bool {
+	cleaned, ok := cleanAPIPath(apiPath)
+	if !ok {
+		return false
+	}
+	if pattern == PermissionWildcard {
+		return true
+	}
+	return matchPathSegments(splitAPIPath(pattern), splitAPIPath(cleaned))
+}
+
+// AllowsAPIPath 按允许/拒绝规则判断是否可以访问API，拒绝优先；含 .. 越界段的路径一律拒绝
+func AllowsAPIPath(patterns []string, apiPath string) bool {
+	cleaned, ok := cleanAPIPath(apiPath)
+	if !ok {
+		return false
+	}
+	apiPath = cleaned
+
+	allowed := false
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if strings.HasPrefix(pattern, PermissionDenyPrefix) {
+			if MatchAPIPath(strings.TrimPrefix(pattern, PermissionDenyPrefix), apiPath) {
+				return false
+			}
+			continue
+		}
+		if !allowed && MatchAPIPath(pattern, apiPath) {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// cleanAPIPath 规范化请求路径（去掉 .、多余的 / 并折叠 ..），清理后仍含 .. 段时返回false
+func cleanAPIPath(apiPath string) (string, bool) {
+	cleaned := path.Clean(apiPath)
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	return cleaned, true
+}
+
+func splitAPIPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchPathSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == pathDeepWildcard {
+			// ** 尝试吞掉0到全部剩余段
+			for i := 0; i <= len(segments); i++ {
+				if matchPathSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// ResolveRoleChain 沿继承关系返回角色链（自身在前），parentOf 返回角色的父角色（无父角色时为空）
+func ResolveRoleChain(role string, parentOf func(role string) (string, error)) ([]string, error) {
+	chain := []string{role}
+	visited := map[string]bool{role: true}
+	current := role
+	for {
+		parent, err := parentOf(current)
+		if err != nil {
+			return chain, err
+		}
+		if parent == "" {
+			return chain, nil
+		}
+		if visited[parent] {
+			return chain, fmt.Errorf("%w: %s -> %s", ErrRoleInheritanceCycle, strings.Join(chain, " -> "), parent)
+		}
+		if len(chain) >= MaxRoleInheritanceDepth {
+			return chain, ErrRoleInheritanceDepth
+		}
+		visited[parent] = true
+		chain = append(chain, parent)
+		current = parent
+	}
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FirstPartyClientID 用户服务自身签发的刷新令牌所属客户端
+const FirstPartyClientID = "zervigo"
+
+// 刷新令牌与吊销相关错误
+var (
+	ErrRefreshTokenInvalid = errors.New("无效的刷新令牌")
+	ErrRefreshTokenExpired = errors.New("刷新令牌已过期")
+	ErrRefreshTokenRevoked = errors.New("刷新令牌已被吊销")
+	ErrRefreshTokenReused  = errors.New("检测到刷新令牌重复使用，该登录会话已全部失效")
+	ErrTokenRevoked        = errors.New("token已被注销")
+)
+
+// EnsureTokenTables 确保刷新令牌与两步验证表存在（迁移失败时仅记录警告）
+func (am *AuthManager) EnsureTokenTables() {
+	if am.db == nil {
+		return
+	}
+	if err := am.db.AutoMigrate(&RefreshToken{}); err != nil {
+		log.Printf("WARN: 刷新令牌表迁移失败: %v", err)
+	}
+	if err := am.db.AutoMigrate(&MFAEnrollment{}, &MFARecoveryCode{}); err != nil {
+		log.Printf("WARN: 两步验证表迁移失败: %v", err)
+	}
+}
+
+// SetRevocationStore 设置访问令牌吊销列表（多实例部署时应使用Redis实现）
+func (am *AuthManager) SetRevocationStore(store RevocationStore) {
+	am.revocations = store
+}
+
+// Refresh 使用刷新令牌换取新的令牌对
+// 刷新令牌一次性使用：成功后旧令牌立即失效；已轮换的令牌再次出现视为被盗用，吊销整个令牌家族。
+func (am *AuthManager) Refresh(refreshToken, clientIP, userAgent string) (*RefreshResponse, error) {
+	tokenHash := hashRefreshToken(refreshToken)
+
+	// 只接受用户服务自身签发的令牌：OAuth2合作方的刷新令牌共用同一张表，不能换取完整会话
+	var record RefreshToken
+	if err := am.db.Where("token = ? AND client_id = ?", tokenHash, FirstPartyClientID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, fmt.Errorf("查询刷新令牌失败: %w", err)
+	}
+
+	if record.RevokedAt != nil {
+		if record.ReplacedBy != "" {
+			am.handleRefreshTokenReuse(record, clientIP, userAgent)
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrRefreshTokenRevoked
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	var user User
+	if err := am.db.Where("id = ? AND status = 'active'", record.UserID).First(&user).Error; err != nil {
+		return nil, errors.New("用户不存在或已被禁用")
+	}
+	role, err := am.fetchPrimaryRole(user.ID)
+	if err != nil {
+		log.Printf("WARN: 获取用户角色失败 user_id=%d: %v", user.ID, err)
+		role = "user"
+	}
+
+	newToken, newHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(am.refreshExpiry())
+
+	err = am.db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发刷新时只有一个请求能轮换成功
+		result := tx.Model(&RefreshToken{}).
+			Where("token = ? AND client_id = ? AND revoked_at IS NULL", tokenHash, FirstPartyClientID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by": newHash})
+		if result.Error != nil {
+			return fmt.Errorf("轮换刷新令牌失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		return tx.Create(&RefreshToken{
+			Token:     newHash,
+			FamilyID:  record.FamilyID,
+			ClientID:  record.ClientID,
+			UserID:    record.UserID,
+			Scope:     record.Scope,
+			AMR:       record.AMR,
+			ClientIP:  clientIP,
+			UserAgent: userAgent,
+			ExpiresAt: refreshExpiresAt,
+		}).Error
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		am.handleRefreshTokenReuse(record, clientIP, userAgent)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+
+	// 刷新后的访问令牌沿用登录时的认证方式
+	var amr []string
+	if record.AMR != "" {
+		amr = strings.Split(record.AMR, ",")
+	}
+	accessToken, expiresAt, err := am.generateToken(user.ID, user.Username, role, amr)
+	if err != nil {
+		return nil, fmt.Errorf("生成token失败: %w", err)
+	}
+
+	return &RefreshResponse{
+		Success:          true,
+		Token:            accessToken,
+		RefreshToken:     newToken,
+		ExpiresAt:        expiresAt.Format(time.RFC3339),
+		RefreshExpiresAt: refreshExpiresAt.Format(time.RFC3339),
+		Message:          "刷新成功",
+	}, nil
+}
+
+// Logout 登出：按出示的刷新令牌吊销其所在的整个令牌家族（不依赖访问令牌，访问令牌过期后仍可登出），
+// 访问令牌校验通过时同时吊销其jti
+func (am *AuthManager) Logout(accessToken, refreshToken, clientIP, userAgent string) error {
+	var claims *Claims
+	claimsErr := errors.New("未提供访问令牌")
+	if accessToken != "" {
+		claims, claimsErr = am.ValidateToken(accessToken)
+	}
+	if refreshToken == "" && claimsErr != nil {
+		return claimsErr
+	}
+
+	var userID uint
+	if refreshToken != "" {
+		var record RefreshToken
+		err := am.db.Where("token = ? AND client_id = ?", hashRefreshToken(refreshToken), FirstPartyClientID).First(&record).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// 刷新令牌不存在时仍完成访问令牌的吊销
+			if claimsErr != nil {
+				return errors.New("无效的刷新令牌")
+			}
+		case err != nil:
+			return fmt.Errorf("查询刷新令牌失败: %w", err)
+		case claims != nil && record.UserID != claims.UserID:
+			return errors.New("刷新令牌与当前用户不匹配")
+		default:
+			if err := am.revokeFamily(record.FamilyID); err != nil {
+				return err
+			}
+			userID = record.UserID
+		}
+	}
+
+	if claims != nil {
+		if err := am.RevokeAccessToken(claims); err != nil {
+			return err
+		}
+		userID = claims.UserID
+	}
+
+	am.logLoginAttempt(userID, clientIP, userAgent, "success", "登出成功")
+	return nil
+}
+
+// RevokeAccessToken 将访问令牌的jti加入吊销列表，保留到令牌过期
+func (am *AuthManager) RevokeAccessToken(claims *Claims) error {
+	if claims.ID == "" {
+		// 旧版令牌没有jti，无法单独吊销，只能等待过期
+		return nil
+	}
+	if am.revocations == nil {
+		return errors.New("未配置令牌吊销列表")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return am.revocations.Revoke(ctx, claims.ID, time.Until(time.Unix(claims.Exp, 0)))
+}
+
+// RevokeUserRefreshTokens 吊销用户的所有刷新令牌（如修改密码后强制重新登录）
+func (am *AuthManager) RevokeUserRefreshTokens(userID uint) error {
+	err := am.db.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("吊销刷新令牌失败: %w", err)
+	}
+	return nil
+}
+
+// issueRefreshToken 为新登录签发刷新令牌（开启新的令牌家族）
+func (am *AuthManager) issueRefreshToken(userID uint, amr []string, clientIP, userAgent string) (string, time.Time, error) {
+	token, tokenHash, err := generateRefreshToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(am.refreshExpiry())
+	record := RefreshToken{
+		Token:     tokenHash,
+		FamilyID:  uuid.New().String(),
+		ClientID:  FirstPartyClientID,
+		UserID:    userID,
+		AMR:       strings.Join(amr, ","),
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+		ExpiresAt: expiresAt,
+	}
+	if err := am.db.Create(&record).Error; err != nil {
+		return "", time.Time{}, fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// handleRefreshTokenReuse 刷新令牌被重复使用：吊销整个家族并记录安全日志
+func (am *AuthManager) handleRefreshTokenReuse(record RefreshToken, clientIP, userAgent string) {
+	log.Printf("WARN: 检测到刷新令牌重复使用 user_id=%d family=%s ip=%s", record.UserID, record.FamilyID, clientIP)
+	if err := am.revokeFamily(record.FamilyID); err != nil {
+		log.Printf("ERROR: 吊销令牌家族失败 family=%s: %v", record.FamilyID, err)
+	}
+	am.logLoginAttempt(record.UserID, clientIP, userAgent, "blocked", "刷新令牌重复使用，令牌家族已吊销")
+}
+
+// revokeFamily 吊销令牌家族中所有未吊销的刷新令牌
+func (am *AuthManager) revokeFamily(familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+	err := am.db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("吊销令牌家族失败: %w", err)
+	}
+	return nil
+}
+
+// refreshExpiry 刷新令牌有效期（未配置时7天）
+func (am *AuthManager) refreshExpiry() time.Duration {
+	if am.config.RefreshExpiry > 0 {
+		return am.config.RefreshExpiry
+	}
+	return 7 * 24 * time.Hour
+}
+
+// generateRefreshToken 生成随机刷新令牌，返回明文与摘要
+func generateRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("生成刷新令牌失败: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashRefreshToken(token), nil
+}
+
+// hashRefreshToken 刷新令牌摘要（数据库只保存摘要）
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -44,10 +44,16 @@ type ServiceAuthResult struct {
 	ErrorCode string              `json:"error_code,omitempty"`
 }
 
+// ServiceTokenIssuer 服务令牌的签发者
+const ServiceTokenIssuer = "zervigo-service-auth"
+
 // ServiceAuthService 服务认证服务
 type ServiceAuthService struct {
 	db               *sql.DB
-	serviceJWTSecret string // zervigo-mvp-secret-key-2025
+	serviceJWTSecret string           // zervigo-mvp-secret-key-2025
+	keySet           *KeySet          // 非对称签名密钥集，未设置时沿用HS256
+	verifier         VerificationKeys // 校验公钥（本地KeySet或远程JWKS）
+	acceptHS256      bool             // 是否仍接受serviceJWTSecret签名的旧令牌
 }
 
 // NewServiceAuthService 创建服务认证服务
@@ -55,7 +61,29 @@ func NewServiceAuthService(db *sql.DB, serviceJWTSecret string) *ServiceAuthServ
 	return &ServiceAuthService{
 		db:               db,
 		serviceJWTSecret: serviceJWTSecret,
+		acceptHS256:      true,
+	}
+}
+
+// SetKeySet 使用非对称密钥签发服务令牌（认证服务调用）
+func (sas *ServiceAuthService) SetKeySet(keySet *KeySet) {
+	sas.keySet = keySet
+	sas.verifier = keySet
+	sas.acceptHS256 = legacyHS256Allowed()
+}
+
+// SetVerificationKeys 只校验不签发的服务通过JWKS获取公钥
+func (sas *ServiceAuthService) SetVerificationKeys(keys VerificationKeys) {
+	sas.verifier = keys
+	sas.acceptHS256 = legacyHS256Allowed()
+}
+
+// legacySecret 仍接受HS256旧令牌时返回共享密钥
+func (sas *ServiceAuthService) legacySecret() string {
+	if sas.acceptHS256 || sas.verifier == nil {
+		return sas.serviceJWTSecret
 	}
+	return ""
 }
 
 // AuthenticateService 服务认证
@@ -112,9 +140,8 @@ func (sas *ServiceAuthService) AuthenticateService(serviceID, serviceSecret stri
 // ValidateServiceToken 验证服务token
 func (sas *ServiceAuthService) ValidateServiceToken(tokenString string) (*ServiceAuthResult, error) {
 	// 解析JWT token
-	token, err := jwt.ParseWithClaims(tokenString, &ServiceTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(sas.serviceJWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &ServiceTokenClaims{}, jwtKeyFunc(sas.verifier, sas.legacySecret()),
+		jwt.WithIssuer(ServiceTokenIssuer))
 
 	if err != nil {
 		return &ServiceAuthResult{
@@ -223,13 +250,18 @@ func (sas *ServiceAuthService) generateServiceToken(service *ServiceCredentials)
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "zervigo-service-auth",
+			Issuer:    ServiceTokenIssuer,
 			Subject:   service.ServiceID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(sas.serviceJWTSecret))
+	var tokenString string
+	var err error
+	if sas.keySet != nil {
+		tokenString, err = sas.keySet.Sign(claims, "")
+	} else {
+		tokenString, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(sas.serviceJWTSecret))
+	}
 	if err != nil {
 		return "", 0, err
 	}
@@ -266,15 +298,6 @@ func (sas *ServiceAuthService) CheckServicePermission(serviceID, apiPath string)
 		return false, err
 	}
 
-	// 如果allowed_apis包含"*"，表示允许所有API
-	for _, allowedAPI := range service.AllowedAPIs {
-		if allowedAPI == "*" {
-			return true, nil
-		}
-		if allowedAPI == apiPath {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	// allowed_apis支持"*"、路径通配（/api/v1/company/**）以及"!"开头的拒绝规则
+	return AllowsAPIPath(service.AllowedAPIs, apiPath), nil
 }
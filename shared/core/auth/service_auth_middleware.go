@@ -9,7 +9,7 @@ import (
 )
 
 // ServiceAuthMiddleware 服务认证中间件
-// 验证服务间通信的token（使用zervigo-2025密钥）
+// 验证服务间通信的token（配置AUTH_JWKS_URL后按认证服务发布的公钥校验）
 type ServiceAuthMiddleware struct {
 	serviceAuthService *ServiceAuthService
 }
@@ -18,6 +18,9 @@ type ServiceAuthMiddleware struct {
 func NewServiceAuthMiddleware(db *sql.DB) *ServiceAuthMiddleware {
 	serviceJWTSecret := "zervigo-mvp-secret-key-2025"
 	serviceAuthService := NewServiceAuthService(db, serviceJWTSecret)
+	if keys := VerificationKeysFromEnv(); keys != nil {
+		serviceAuthService.SetVerificationKeys(keys)
+	}
 
 	return &ServiceAuthMiddleware{
 		serviceAuthService: serviceAuthService,
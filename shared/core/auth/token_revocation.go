@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RevocationStore 访问令牌吊销列表（按jti记录，保留到令牌自然过期）
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RedisRevocationStore 基于Redis的吊销列表，多个服务实例共享
+type RedisRevocationStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRevocationStore 创建Redis吊销列表
+func NewRedisRevocationStore(client *redis.Client) *RedisRevocationStore {
+	return &RedisRevocationStore{
+		client: client,
+		prefix: "auth:revoked:jti:",
+	}
+}
+
+// Revoke 吊销jti；ttl<=0时不写入（令牌已过期，无需吊销）
+func (s *RedisRevocationStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	if err := s.client.Set(ctx, s.prefix+jti, "1", ttl).Err(); err != nil {
+		return fmt.Errorf("写入吊销列表失败: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked 检查jti是否已吊销
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	n, err := s.client.Exists(ctx, s.prefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("查询吊销列表失败: %w", err)
+	}
+	return n > 0, nil
+}
+
+// MemoryRevocationStore 进程内吊销列表（未配置Redis时使用，仅对当前进程生效）
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time // jti -> 过期时间
+}
+
+// NewMemoryRevocationStore 创建进程内吊销列表
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Revoke 吊销jti
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// 顺带清理已过期的记录
+	for id, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[jti] = now.Add(ttl)
+	return nil
+}
+
+// IsRevoked 检查jti是否已吊销
+func (s *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, exists := s.revoked[jti]
+	if !exists {
+		return false, nil
+	}
+	if time.Now().After(expiresAt) {
+		delete(s.revoked, jti)
+		return false, nil
+	}
+	return true, nil
+}
+
+// RevocationStoreFromEnv 按REDIS_HOST/REDIS_PORT/REDIS_PASSWORD/REDIS_DB创建吊销列表；
+// 未配置或Redis不可达时回退到进程内实现
+func RevocationStoreFromEnv() RevocationStore {
+	client := redisClientFromEnv()
+	if client == nil {
+		log.Printf("WARN: 未连接Redis，令牌吊销列表仅在当前进程内生效")
+		return NewMemoryRevocationStore()
+	}
+	return NewRedisRevocationStore(client)
+}
+
+// redisClientFromEnv 按REDIS_*环境变量连接Redis；未配置或不可达时返回nil
+func redisClientFromEnv() *redis.Client {
+	host := os.Getenv("REDIS_HOST")
+	if host == "" {
+		return nil
+	}
+
+	port := os.Getenv("REDIS_PORT")
+	if port == "" {
+		port = "6379"
+	}
+	db, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     host + ":" + port,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Printf("WARN: 连接Redis失败: %v", err)
+		client.Close()
+		return nil
+	}
+	return client
+}
+
+// checkRevoked 校验jti未被吊销；吊销列表不可用时拒绝（fail closed）
+func checkRevoked(store RevocationStore, jti string) error {
+	if store == nil || jti == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	revoked, err := store.IsRevoked(ctx, jti)
+	if err != nil {
+		return fmt.Errorf("无法校验token吊销状态: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
@@ -70,11 +70,12 @@ type DevOperationLog struct {
 
 // JWT Claims
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	Exp      int64  `json:"exp"`
-	Iat      int64  `json:"iat"`
+	UserID   uint     `json:"user_id"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	AMR      []string `json:"amr,omitempty"` // 认证方式，完成两步验证时包含 mfa
+	Exp      int64    `json:"exp"`
+	Iat      int64    `json:"iat"`
 	jwt.RegisteredClaims
 }
 
@@ -86,12 +87,56 @@ type LoginRequest struct {
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Success   bool        `json:"success"`
-	Token     string      `json:"token"`
-	User      User        `json:"user"`
-	DevTeam   DevTeamUser `json:"dev_team,omitempty"`
-	ExpiresAt string      `json:"expires_at"`
-	Message   string      `json:"message"`
+	Success          bool        `json:"success"`
+	Token            string      `json:"token"`
+	RefreshToken     string      `json:"refresh_token,omitempty"`
+	User             User        `json:"user"`
+	DevTeam          DevTeamUser `json:"dev_team,omitempty"`
+	ExpiresAt        string      `json:"expires_at"`
+	RefreshExpiresAt string      `json:"refresh_expires_at,omitempty"`
+	Message          string      `json:"message"`
+
+	// 需要两步验证时不返回令牌，客户端携带 ChallengeToken 调用两步验证接口完成登录
+	MFARequired           bool     `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"` // 角色强制两步验证但尚未登记
+	ChallengeToken        string   `json:"challenge_token,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"` // 首次登记完成时返回，仅显示一次
+}
+
+// MFAVerifyRequest 两步验证登录请求（code 为6位动态码或恢复码）
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// MFAEnrollRequest 使用两步验证凭证登记（角色强制两步验证但尚未登记时）
+type MFAEnrollRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// MFACodeRequest 需要动态码确认的操作
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshResponse 刷新令牌响应（刷新令牌每次使用后轮换）
+type RefreshResponse struct {
+	Success          bool   `json:"success"`
+	Token            string `json:"token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresAt        string `json:"expires_at"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+	Message          string `json:"message"`
+}
+
+// LogoutRequest 登出请求（refresh_token可选，提供时吊销其整个令牌家族）
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // RegisterRequest 注册请求
@@ -127,21 +172,27 @@ type Permission struct {
 
 // AuthConfig 认证配置
 type AuthConfig struct {
-	JWTSecret        string        `json:"jwt_secret"`
-	TokenExpiry      time.Duration `json:"token_expiry"`
-	RefreshExpiry    time.Duration `json:"refresh_expiry"`
-	PasswordMin      int           `json:"password_min_length"`
-	MaxLoginAttempts int           `json:"max_login_attempts"`
-	LockoutDuration  time.Duration `json:"lockout_duration"`
+	JWTSecret            string        `json:"jwt_secret"`
+	TokenExpiry          time.Duration `json:"token_expiry"`
+	RefreshExpiry        time.Duration `json:"refresh_expiry"`
+	PasswordMin          int           `json:"password_min_length"`
+	PasswordMinClasses   int           `json:"password_min_classes"`   // 小写/大写/数字/符号中至少包含几类
+	PasswordDenylistFile string        `json:"password_denylist_file"` // 弱密码/泄露密码列表（每行一个明文或SHA-1）
+	MaxLoginAttempts     int           `json:"max_login_attempts"`
+	MaxIPLoginAttempts   int           `json:"max_ip_login_attempts"`
+	LockoutDuration      time.Duration `json:"lockout_duration"`     // 首次锁定时长，之后每次翻倍
+	MaxLockoutDuration   time.Duration `json:"max_lockout_duration"` // 锁定时长上限
 }
 
 // OAuth2Client OAuth2 客户端
+// Secret 保存bcrypt摘要；为空表示公开客户端（SPA/移动端），必须使用PKCE。
 type OAuth2Client struct {
 	ID           string    `json:"id" gorm:"primaryKey;column:id;type:varchar(255)"`
 	Secret       string    `json:"-" gorm:"column:client_secret;type:varchar(255);not null"`
 	Name         string    `json:"name" gorm:"column:name;type:varchar(255);not null"`
-	RedirectURIs []string  `json:"redirect_uris" gorm:"column:redirect_uris;type:jsonb;default:'[]'"`
-	Scopes       []string  `json:"scopes" gorm:"column:scopes;type:jsonb;default:'[]'"`
+	RedirectURIs []string  `json:"redirect_uris" gorm:"column:redirect_uris;type:jsonb;serializer:json;default:'[]'"`
+	Scopes       []string  `json:"scopes" gorm:"column:scopes;type:jsonb;serializer:json;default:'[]'"`
+	GrantTypes   []string  `json:"grant_types" gorm:"column:grant_types;type:jsonb;serializer:json;default:'[]'"` // 为空时允许 authorization_code 与 refresh_token
 	Status       string    `json:"status" gorm:"column:status;type:varchar(50);default:'active'"`
 	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt    time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime"`
@@ -152,7 +203,7 @@ func (OAuth2Client) TableName() string {
 	return "oauth2_clients"
 }
 
-// AuthorizationCode 授权码
+// AuthorizationCode 授权码（Code 保存授权码的SHA-256摘要，兑换一次即删除）
 type AuthorizationCode struct {
 	Code                string    `json:"code" gorm:"primaryKey;column:code;type:varchar(255)"`
 	ClientID            string    `json:"client_id" gorm:"column:client_id;type:varchar(255);not null;index"`
@@ -161,6 +212,7 @@ type AuthorizationCode struct {
 	Scope               string    `json:"scope" gorm:"column:scope;type:varchar(255)"`
 	CodeChallenge       string    `json:"code_challenge" gorm:"column:code_challenge;type:varchar(255)"`
 	CodeChallengeMethod string    `json:"code_challenge_method" gorm:"column:code_challenge_method;type:varchar(10)"`
+	Nonce               string    `json:"nonce" gorm:"column:nonce;type:varchar(255)"` // OIDC nonce，原样写入ID Token
 	ExpiresAt           time.Time `json:"expires_at" gorm:"column:expires_at;not null;index"`
 	CreatedAt           time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime"`
 }
@@ -171,14 +223,21 @@ func (AuthorizationCode) TableName() string {
 }
 
 // RefreshToken 刷新令牌
+// Token 保存令牌的SHA-256摘要而非明文；同一次登录轮换出的令牌共享 FamilyID，
+// 已轮换的令牌记录 ReplacedBy，再次出现即视为重放，整个家族被吊销。
 type RefreshToken struct {
-	Token     string     `json:"token" gorm:"primaryKey;column:token;type:varchar(255)"`
-	ClientID  string     `json:"client_id" gorm:"column:client_id;type:varchar(255);not null;index"`
-	UserID    uint       `json:"user_id" gorm:"column:user_id;not null;index"`
-	Scope     string     `json:"scope" gorm:"column:scope;type:varchar(255)"`
-	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at;not null;index"`
-	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime"`
-	RevokedAt *time.Time `json:"revoked_at" gorm:"column:revoked_at;index"`
+	Token      string     `json:"-" gorm:"primaryKey;column:token;type:varchar(255)"`
+	FamilyID   string     `json:"family_id" gorm:"column:family_id;type:varchar(64);index"`
+	ClientID   string     `json:"client_id" gorm:"column:client_id;type:varchar(255);not null;index"`
+	UserID     uint       `json:"user_id" gorm:"column:user_id;not null;index"`
+	Scope      string     `json:"scope" gorm:"column:scope;type:varchar(255)"`
+	AMR        string     `json:"amr" gorm:"column:amr;type:varchar(64)"` // 登录时的认证方式（逗号分隔），刷新后沿用
+	ReplacedBy string     `json:"-" gorm:"column:replaced_by;type:varchar(255)"`
+	ClientIP   string     `json:"client_ip" gorm:"column:client_ip;type:varchar(45)"`
+	UserAgent  string     `json:"user_agent" gorm:"column:user_agent;type:text"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"column:expires_at;not null;index"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"column:revoked_at;index"`
 }
 
 // TableName 指定表名
@@ -211,6 +270,8 @@ type OAuth2AuthorizationRequest struct {
 	State        string `json:"state"`
 	CodeChallenge       string `json:"code_challenge"`        // PKCE
 	CodeChallengeMethod string `json:"code_challenge_method"` // "plain" or "S256"
+	Nonce               string `json:"nonce"`                 // OIDC
+	Prompt              string `json:"prompt"`                // OIDC，"none" 表示不允许跳转登录页
 }
 
 // OAuth2TokenRequest Token 请求
@@ -231,6 +292,7 @@ type OAuth2TokenResponse struct {
 	ExpiresIn    int64  `json:"expires_in"` // seconds
 	RefreshToken string `json:"refresh_token,omitempty"`
 	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"` // scope 包含 openid 时返回
 }
 
 // OAuth2UserInfoResponse 用户信息响应
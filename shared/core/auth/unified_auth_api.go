@@ -3,6 +3,7 @@ package auth
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,6 +15,8 @@ import (
 type UnifiedAuthAPI struct {
 	authSystem         *UnifiedAuthSystem
 	serviceAuthService *ServiceAuthService
+	oauth2             *OAuth2Provider // 为nil时OAuth2端点返回503
+	clientIPs          *ClientIPResolver
 	port               int
 }
 
@@ -22,10 +25,28 @@ func NewUnifiedAuthAPI(authSystem *UnifiedAuthSystem, port int) *UnifiedAuthAPI
 	// 创建服务认证服务（使用zervigo-2025密钥）
 	serviceJWTSecret := "zervigo-mvp-secret-key-2025"
 	serviceAuthService := NewServiceAuthService(authSystem.db, serviceJWTSecret)
+	if keySet := authSystem.KeySet(); keySet != nil {
+		serviceAuthService.SetKeySet(keySet)
+	}
+
+	// OAuth2/OIDC授权服务器（未配置签名密钥集时只禁用OAuth2，不影响原有认证接口）
+	oauth2Provider, err := NewOAuth2Provider(authSystem, OAuth2ConfigFromEnv(port))
+	if err != nil {
+		log.Printf("ERROR: OAuth2授权服务器初始化失败，OAuth2端点不可用: %v", err)
+	}
+
+	// 客户端IP解析（TRUSTED_PROXIES配置错误时不采信任何转发头）
+	clientIPs, err := NewClientIPResolver(TrustedProxiesFromEnv())
+	if err != nil {
+		log.Printf("ERROR: 可信代理配置无效，客户端IP按连接地址识别: %v", err)
+		clientIPs, _ = NewClientIPResolver(nil)
+	}
 
 	return &UnifiedAuthAPI{
 		authSystem:         authSystem,
 		serviceAuthService: serviceAuthService,
+		oauth2:             oauth2Provider,
+		clientIPs:          clientIPs,
 		port:               port,
 	}
 }
@@ -43,11 +64,29 @@ func (api *UnifiedAuthAPI) Start() error {
 	http.HandleFunc("/api/v1/auth/roles", api.handleGetRoles)
 	http.HandleFunc("/api/v1/auth/permissions", api.handleGetPermissions)
 
+	// 两步验证（TOTP）
+	http.HandleFunc("/api/v1/auth/mfa/verify", api.handleMFAVerify)
+	http.HandleFunc("/api/v1/auth/mfa/enroll", api.handleMFAEnroll)
+	http.HandleFunc("/api/v1/auth/mfa/confirm", api.handleMFAConfirm)
+	http.HandleFunc("/api/v1/auth/mfa/status", api.handleMFAStatus)
+	http.HandleFunc("/api/v1/auth/mfa/disable", api.handleMFADisable)
+	http.HandleFunc("/api/v1/auth/mfa/recovery-codes", api.handleMFARecoveryCodes)
+
 	// 服务认证路由（使用zervigo-2025密钥）
 	http.HandleFunc("/api/v1/auth/service/login", api.handleServiceLogin)
 	http.HandleFunc("/api/v1/auth/service/validate", api.handleServiceValidate)
 	http.HandleFunc("/api/v1/auth/service/permission", api.handleServicePermission)
 
+	// OAuth2 / OpenID Connect
+	http.HandleFunc("/oauth2/authorize", api.handleOAuth2Authorize)
+	http.HandleFunc("/oauth2/token", api.handleOAuth2Token)
+	http.HandleFunc("/oauth2/userinfo", api.handleOAuth2UserInfo)
+	http.HandleFunc("/oauth2/introspect", api.handleOAuth2Introspect)
+	http.HandleFunc("/oauth2/revoke", api.handleOAuth2Revoke)
+	http.HandleFunc("/.well-known/openid-configuration", api.handleOIDCDiscovery)
+	http.HandleFunc("/.well-known/jwks.json", api.handleJWKS)
+	http.HandleFunc("/api/v1/auth/oauth2/clients", api.handleOAuth2Clients)
+
 	http.HandleFunc("/health", api.handleHealth)
 
 	// 启动服务器
@@ -98,7 +137,7 @@ func (api *UnifiedAuthAPI) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := api.authSystem.Authenticate(username, password)
+	result, err := api.authSystem.AuthenticateFrom(username, password, api.clientIP(r))
 	if err != nil {
 		api.writeErrorResponse(w, response.Error(response.CodeInternalError, err.Error()))
 		return
@@ -111,60 +150,18 @@ func (api *UnifiedAuthAPI) handleLogin(w http.ResponseWriter, r *http.Request) {
 	// 记录访问日志
 	api.authSystem.logAccess(0, "login", "auth",
 		map[bool]string{true: "success", false: "failed"}[result.Success],
-		getClientIP(r), getUserAgent(r))
+		api.clientIP(r), getUserAgent(r))
 
 	// 构建 VueCMF 兼容的响应格式
 	if result.Success && result.User != nil {
-		// 获取客户端IP
-		clientIP := getClientIP(r)
-		
-		// 格式化最后登录时间
-		var lastLoginTime string
-		if result.User.LastLogin != nil {
-			lastLoginTime = result.User.LastLogin.Format("2006-01-02 15:04:05")
-		} else {
-			lastLoginTime = ""
-		}
-		
-		// VueCMF 期望的用户对象（包含完整的登录信息）
-		userObj := map[string]interface{}{
-			"id":              result.User.ID,
-			"username":        result.User.Username,
-			"email":           result.User.Email,
-			"phone":           result.User.Phone,
-			"status":          result.User.Status,
-			"role":            result.User.Role,            // 关键！VueCMF 前端需要这个字段
-			"last_login_ip":   clientIP,                    // 最后登录IP
-			"last_login_time": lastLoginTime,               // 最后登录时间
-		}
-		
-		// VueCMF 期望的服务器信息（字段名必须与前端 Welcome.vue 匹配）
-		serverObj := map[string]interface{}{
-			"name":             "Zervigo MVP",
-			"version":          "1.0.0",
-			"os":               "macOS (darwin)",           // 服务器运行环境的操作系统部分
-			"software":         "Go + Gin",                 // 服务器运行环境的软件部分
-			"mysql":            "PostgreSQL 14.19",         // 前端显示"mysql"标签，但我们填PostgreSQL版本
-			"upload_max_size":  "10MB",                     // 最大上传文件大小
-		}
-		
-		// VueCMF 期望的完整登录数据
-		loginData := map[string]interface{}{
-			"token":  result.Token,      // VueCMF 期望 token 字段
-			"user":   userObj,            // VueCMF 期望 user 对象
-			"server": serverObj,          // VueCMF 期望 server 对象
-			
-			// 同时保留原有字段以保持兼容性
-			"userId":       result.User.ID,
-			"userName":     result.User.Username,
-			"userPhone":    result.User.Phone,
-			"userAvatar":   nil,
-			"userStatus":   result.User.Status,
-			"loginStatus":  api.calculateLoginStatus(api.getUserStatusInt(result.User.Status)),
-			"accessToken":  result.Token,
-			"refreshToken": "",
-		}
-		api.writeSuccessResponse(w, response.Success("登录成功", loginData))
+		api.writeLoginSuccess(w, r, result)
+	} else if result.ErrorCode == "MFA_REQUIRED" {
+		// 密码正确，需完成两步验证后才签发令牌
+		api.writeSuccessResponse(w, response.Success(result.Error, map[string]interface{}{
+			"mfa_required":            true,
+			"mfa_enrollment_required": result.MFAEnrollmentRequired,
+			"challenge_token":         result.ChallengeToken,
+		}))
 	} else {
 		// 使用result中的错误信息
 		errorCode := response.CodeUserNotFound
@@ -172,11 +169,70 @@ func (api *UnifiedAuthAPI) handleLogin(w http.ResponseWriter, r *http.Request) {
 			errorCode = response.CodeUnauthorized
 		} else if result.ErrorCode == "USER_DISABLED" {
 			errorCode = response.CodeForbidden
+		} else if result.ErrorCode == "ACCOUNT_LOCKED" {
+			errorCode = response.CodeTooManyRequests
 		}
 		api.writeErrorResponse(w, response.Error(errorCode, result.Error))
 	}
 }
 
+// writeLoginSuccess 输出 VueCMF 兼容的登录成功响应
+func (api *UnifiedAuthAPI) writeLoginSuccess(w http.ResponseWriter, r *http.Request, result *AuthResult) {
+	// 获取客户端IP
+	clientIP := api.clientIP(r)
+	
+	// 格式化最后登录时间
+	var lastLoginTime string
+	if result.User.LastLogin != nil {
+		lastLoginTime = result.User.LastLogin.Format("2006-01-02 15:04:05")
+	} else {
+		lastLoginTime = ""
+	}
+	
+	// VueCMF 期望的用户对象（包含完整的登录信息）
+	userObj := map[string]interface{}{
+		"id":              result.User.ID,
+		"username":        result.User.Username,
+		"email":           result.User.Email,
+		"phone":           result.User.Phone,
+		"status":          result.User.Status,
+		"role":            result.User.Role,            // 关键！VueCMF 前端需要这个字段
+		"last_login_ip":   clientIP,                    // 最后登录IP
+		"last_login_time": lastLoginTime,               // 最后登录时间
+	}
+	
+	// VueCMF 期望的服务器信息（字段名必须与前端 Welcome.vue 匹配）
+	serverObj := map[string]interface{}{
+		"name":             "Zervigo MVP",
+		"version":          "1.0.0",
+		"os":               "macOS (darwin)",           // 服务器运行环境的操作系统部分
+		"software":         "Go + Gin",                 // 服务器运行环境的软件部分
+		"mysql":            "PostgreSQL 14.19",         // 前端显示"mysql"标签，但我们填PostgreSQL版本
+		"upload_max_size":  "10MB",                     // 最大上传文件大小
+	}
+	
+	// VueCMF 期望的完整登录数据
+	loginData := map[string]interface{}{
+		"token":  result.Token,      // VueCMF 期望 token 字段
+		"user":   userObj,            // VueCMF 期望 user 对象
+		"server": serverObj,          // VueCMF 期望 server 对象
+		
+		// 同时保留原有字段以保持兼容性
+		"userId":       result.User.ID,
+		"userName":     result.User.Username,
+		"userPhone":    result.User.Phone,
+		"userAvatar":   nil,
+		"userStatus":   result.User.Status,
+		"loginStatus":  api.calculateLoginStatus(api.getUserStatusInt(result.User.Status)),
+		"accessToken":  result.Token,
+		"refreshToken": "",
+	}
+	if len(result.RecoveryCodes) > 0 {
+		loginData["recovery_codes"] = result.RecoveryCodes // 首次登记两步验证，仅显示一次
+	}
+	api.writeSuccessResponse(w, response.Success("登录成功", loginData))
+}
+
 // handleLogout 处理登出请求
 func (api *UnifiedAuthAPI) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -206,7 +262,7 @@ func (api *UnifiedAuthAPI) handleLogout(w http.ResponseWriter, r *http.Request)
 		// 验证 token 获取用户信息
 		result, _ := api.authSystem.ValidateJWT(req.Token)
 		if result != nil && result.Success && result.User != nil {
-			api.authSystem.logAccess(result.User.ID, "logout", "auth", "success", getClientIP(r), getUserAgent(r))
+			api.authSystem.logAccess(result.User.ID, "logout", "auth", "success", api.clientIP(r), getUserAgent(r))
 		}
 	}
 
@@ -459,6 +515,8 @@ func (api *UnifiedAuthAPI) handleHealth(w http.ResponseWriter, r *http.Request)
 			"permission_management",
 			"access_logging",
 			"database_optimization",
+			"oauth2_oidc_provider",
+			"totp_mfa",
 		},
 	}
 
@@ -507,14 +565,9 @@ func (api *UnifiedAuthAPI) getUserStatusInt(status string) int {
 }
 
 // 辅助函数
-func getClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
-	}
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	return r.RemoteAddr
+// clientIP 客户端IP（只在连接来自可信代理时采信转发头，用于按IP计数登录失败）
+func (api *UnifiedAuthAPI) clientIP(r *http.Request) string {
+	return api.clientIPs.ClientIP(r)
 }
 
 func getUserAgent(r *http.Request) string {
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"errors"
+	"log"
+)
+
+// mfaChallenge 需要两步验证时返回带两步验证凭证的认证结果，否则返回nil
+func (uas *UnifiedAuthSystem) mfaChallenge(user *UserInfo) (*AuthResult, error) {
+	if uas.mfa == nil {
+		return nil, nil
+	}
+
+	enabled, err := uas.mfa.Enabled(uint(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !enabled && !uas.mfa.Policy().Required(user.Role) {
+		return nil, nil
+	}
+
+	challenge, err := signMFAChallenge(uas.jwtSecret, uint(user.ID), user.Username, MFAFlowLogin)
+	if err != nil {
+		return &AuthResult{
+			Success:   false,
+			Error:     "生成两步验证凭证失败",
+			ErrorCode: "TOKEN_ERROR",
+		}, nil
+	}
+
+	message := "请输入两步验证码"
+	if !enabled {
+		message = "当前账号必须启用两步验证，请先完成登记"
+	}
+	return &AuthResult{
+		Success:               false,
+		Error:                 message,
+		ErrorCode:             "MFA_REQUIRED",
+		ChallengeToken:        challenge,
+		MFAEnrollmentRequired: !enabled,
+	}, nil
+}
+
+// VerifyMFA 校验两步验证码并签发令牌；尚未登记的账号以此确认登记并返回恢复码
+func (uas *UnifiedAuthSystem) VerifyMFA(challengeToken, code, clientIP string) (*AuthResult, error) {
+	if uas.mfa == nil {
+		return nil, errors.New("未启用两步验证")
+	}
+
+	claims, err := uas.parseMFAChallenge(challengeToken)
+	if err != nil {
+		return &AuthResult{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_CHALLENGE",
+		}, nil
+	}
+
+	user, err := uas.getUserByID(int(claims.UserID))
+	if err != nil || user.Status != "active" {
+		return &AuthResult{
+			Success:   false,
+			Error:     "用户不存在或已被禁用",
+			ErrorCode: "USER_NOT_FOUND",
+		}, nil
+	}
+
+	enabled, err := uas.mfa.Enabled(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	amr := []string{AMRPassword, AMROTP, AMRMFA}
+	var recoveryCodes []string
+	err = uas.checkMFACode(user.Username, clientIP, func() error {
+		var verifyErr error
+		if enabled {
+			amr, verifyErr = uas.mfa.Verify(claims.UserID, code)
+		} else {
+			recoveryCodes, verifyErr = uas.mfa.ConfirmEnrollment(claims.UserID, code)
+		}
+		return verifyErr
+	})
+	if err != nil {
+		return mfaFailureResult(err)
+	}
+
+	// 凭证只能使用一次
+	if err := uas.revokeJTI(claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("WARN: 作废两步验证凭证失败 user_id=%d: %v", claims.UserID, err)
+	}
+	uas.loginGuard.RecordSuccess(user.Username)
+
+	result := uas.completeAuthentication(user, amr, clientIP)
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+// BeginMFAEnrollmentWithChallenge 角色强制两步验证但尚未登记的账号，凭登录时的两步验证凭证生成密钥
+func (uas *UnifiedAuthSystem) BeginMFAEnrollmentWithChallenge(challengeToken string) (*MFAEnrollmentResponse, error) {
+	claims, err := uas.parseMFAChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	return uas.BeginMFAEnrollment(int(claims.UserID))
+}
+
+// BeginMFAEnrollment 为已登录用户生成TOTP密钥
+func (uas *UnifiedAuthSystem) BeginMFAEnrollment(userID int) (*MFAEnrollmentResponse, error) {
+	if uas.mfa == nil {
+		return nil, errors.New("未启用两步验证")
+	}
+	user, err := uas.getUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return uas.mfa.BeginEnrollment(uint(user.ID), user.Username)
+}
+
+// ConfirmMFAEnrollment 已登录用户用动态码确认登记，返回恢复码
+func (uas *UnifiedAuthSystem) ConfirmMFAEnrollment(user *UserInfo, code, clientIP string) ([]string, error) {
+	if uas.mfa == nil {
+		return nil, errors.New("未启用两步验证")
+	}
+
+	var codes []string
+	err := uas.checkMFACode(user.Username, clientIP, func() error {
+		var confirmErr error
+		codes, confirmErr = uas.mfa.ConfirmEnrollment(uint(user.ID), code)
+		return confirmErr
+	})
+	return codes, err
+}
+
+// MFAStatus 查询两步验证状态
+func (uas *UnifiedAuthSystem) MFAStatus(user *UserInfo) (*MFAStatus, error) {
+	if uas.mfa == nil {
+		return &MFAStatus{}, nil
+	}
+	return uas.mfa.Status(uint(user.ID), user.Role)
+}
+
+// DisableMFA 校验动态码后关闭两步验证
+func (uas *UnifiedAuthSystem) DisableMFA(user *UserInfo, code, clientIP string) error {
+	if uas.mfa == nil {
+		return errors.New("未启用两步验证")
+	}
+	return uas.checkMFACode(user.Username, clientIP, func() error {
+		return uas.mfa.Disable(uint(user.ID), user.Role, code)
+	})
+}
+
+// RegenerateMFARecoveryCodes 校验动态码后重新生成恢复码
+func (uas *UnifiedAuthSystem) RegenerateMFARecoveryCodes(user *UserInfo, code, clientIP string) ([]string, error) {
+	if uas.mfa == nil {
+		return nil, errors.New("未启用两步验证")
+	}
+
+	var codes []string
+	err := uas.checkMFACode(user.Username, clientIP, func() error {
+		var regenerateErr error
+		codes, regenerateErr = uas.mfa.RegenerateRecoveryCodes(uint(user.ID), code)
+		return regenerateErr
+	})
+	return codes, err
+}
+
+// checkMFACode 校验动态码，错误计入登录失败次数（与密码共用锁定策略）
+func (uas *UnifiedAuthSystem) checkMFACode(username, clientIP string, verify func() error) error {
+	if err := uas.loginGuard.Check(username, clientIP); err != nil {
+		return err
+	}
+
+	err := verify()
+	if errors.Is(err, ErrMFAInvalidCode) {
+		if lockErr := uas.loginGuard.RecordFailure(username, clientIP); lockErr != nil {
+			return lockErr
+		}
+	}
+	return err
+}
+
+// parseMFAChallenge 校验两步验证凭证（已使用的凭证记录在吊销列表中）
+func (uas *UnifiedAuthSystem) parseMFAChallenge(challengeToken string) (*MFAChallengeClaims, error) {
+	claims, err := parseMFAChallenge(uas.jwtSecret, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRevoked(uas.revocations, claims.ID); err != nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return claims, nil
+}
+
+// mfaFailureResult 两步验证失败的认证结果
+func mfaFailureResult(err error) (*AuthResult, error) {
+	switch {
+	case errors.Is(err, ErrLoginLocked):
+		return lockedAuthResult(err), nil
+	case errors.Is(err, ErrMFAInvalidCode):
+		return &AuthResult{
+			Success:   false,
+			Error:     err.Error(),
+			ErrorCode: "INVALID_MFA_CODE",
+		}, nil
+	case errors.Is(err, ErrMFANotEnrolled):
+		return &AuthResult{
+			Success:   false,
+			Error:     "请先登记两步验证",
+			ErrorCode: "MFA_NOT_ENROLLED",
+		}, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/szjason72/zervigo/shared/core/response"
+)
+
+// handleMFAVerify 使用两步验证凭证与动态码（或恢复码）完成登录
+func (api *UnifiedAuthAPI) handleMFAVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Method not allowed"))
+		return
+	}
+
+	var req MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" || req.Code == "" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "challenge_token and code are required"))
+		return
+	}
+
+	result, err := api.authSystem.VerifyMFA(req.ChallengeToken, req.Code, api.clientIP(r))
+	if err != nil {
+		api.writeErrorResponse(w, response.Error(response.CodeInternalError, err.Error()))
+		return
+	}
+	if !result.Success || result.User == nil {
+		errorCode := response.CodeUnauthorized
+		if result.ErrorCode == "ACCOUNT_LOCKED" {
+			errorCode = response.CodeTooManyRequests
+		}
+		api.writeErrorResponse(w, response.Error(errorCode, result.Error))
+		return
+	}
+
+	api.writeLoginSuccess(w, r, result)
+}
+
+// handleMFAEnroll 生成TOTP密钥：已登录用户携带访问令牌，强制两步验证但尚未登记的账号携带两步验证凭证
+func (api *UnifiedAuthAPI) handleMFAEnroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Method not allowed"))
+		return
+	}
+
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Invalid JSON"))
+			return
+		}
+	}
+
+	var enrollment *MFAEnrollmentResponse
+	var err error
+	if req.ChallengeToken != "" {
+		enrollment, err = api.authSystem.BeginMFAEnrollmentWithChallenge(req.ChallengeToken)
+	} else {
+		user, ok := api.authenticatedUser(w, r)
+		if !ok {
+			return
+		}
+		enrollment, err = api.authSystem.BeginMFAEnrollment(user.ID)
+	}
+	if err != nil {
+		api.writeMFAError(w, err)
+		return
+	}
+
+	api.writeSuccessResponse(w, response.Success("请使用验证器App扫描二维码，并输入动态码完成登记", enrollment))
+}
+
+// handleMFAConfirm 已登录用户用动态码确认登记
+func (api *UnifiedAuthAPI) handleMFAConfirm(w http.ResponseWriter, r *http.Request) {
+	user, code, ok := api.mfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := api.authSystem.ConfirmMFAEnrollment(user, code, api.clientIP(r))
+	if err != nil {
+		api.writeMFAError(w, err)
+		return
+	}
+	api.authSystem.logAccess(user.ID, "mfa_enable", "auth", "success", api.clientIP(r), getUserAgent(r))
+
+	api.writeSuccessResponse(w, response.Success("两步验证已启用，请妥善保存恢复码", map[string]interface{}{
+		"recovery_codes": codes,
+	}))
+}
+
+// handleMFAStatus 查询两步验证状态
+func (api *UnifiedAuthAPI) handleMFAStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Method not allowed"))
+		return
+	}
+
+	user, ok := api.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+	status, err := api.authSystem.MFAStatus(user)
+	if err != nil {
+		api.writeErrorResponse(w, response.Error(response.CodeInternalError, err.Error()))
+		return
+	}
+
+	api.writeSuccessResponse(w, response.Success("获取两步验证状态成功", status))
+}
+
+// handleMFADisable 校验动态码后关闭两步验证
+func (api *UnifiedAuthAPI) handleMFADisable(w http.ResponseWriter, r *http.Request) {
+	user, code, ok := api.mfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := api.authSystem.DisableMFA(user, code, api.clientIP(r)); err != nil {
+		api.writeMFAError(w, err)
+		return
+	}
+	api.authSystem.logAccess(user.ID, "mfa_disable", "auth", "success", api.clientIP(r), getUserAgent(r))
+
+	api.writeSuccessResponse(w, response.Success("两步验证已关闭", nil))
+}
+
+// handleMFARecoveryCodes 校验动态码后重新生成恢复码
+func (api *UnifiedAuthAPI) handleMFARecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, code, ok := api.mfaCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := api.authSystem.RegenerateMFARecoveryCodes(user, code, api.clientIP(r))
+	if err != nil {
+		api.writeMFAError(w, err)
+		return
+	}
+	api.authSystem.logAccess(user.ID, "mfa_recovery_codes", "auth", "success", api.clientIP(r), getUserAgent(r))
+
+	api.writeSuccessResponse(w, response.Success("恢复码已重新生成，旧恢复码全部失效", map[string]interface{}{
+		"recovery_codes": codes,
+	}))
+}
+
+// mfaCodeRequest 解析需要已登录且携带动态码的请求
+func (api *UnifiedAuthAPI) mfaCodeRequest(w http.ResponseWriter, r *http.Request) (*UserInfo, string, bool) {
+	if r.Method != "POST" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Method not allowed"))
+		return nil, "", false
+	}
+
+	user, ok := api.authenticatedUser(w, r)
+	if !ok {
+		return nil, "", false
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "code is required"))
+		return nil, "", false
+	}
+	return user, req.Code, true
+}
+
+// authenticatedUser 校验Authorization头中的访问令牌
+func (api *UnifiedAuthAPI) authenticatedUser(w http.ResponseWriter, r *http.Request) (*UserInfo, bool) {
+	result, err := api.authSystem.ValidateJWT(bearerToken(r))
+	if err != nil || !result.Success {
+		api.writeErrorResponse(w, response.Error(response.CodeUnauthorized, "未登录或token无效"))
+		return nil, false
+	}
+	return result.User, true
+}
+
+// requireMFASession 敏感操作要求当前会话已完成两步验证（未启用两步验证功能时不检查）
+func (api *UnifiedAuthAPI) requireMFASession(w http.ResponseWriter, result *AuthResult) bool {
+	if api.authSystem.mfa == nil || HasAMR(result.AMR, AMRMFA) {
+		return true
+	}
+	api.writeErrorResponse(w, response.Error(response.CodeForbidden, "该操作需要完成两步验证后重新登录"))
+	return false
+}
+
+// writeMFAError 两步验证错误映射为响应码
+func (api *UnifiedAuthAPI) writeMFAError(w http.ResponseWriter, err error) {
+	errorCode := response.CodeInternalError
+	switch {
+	case errors.Is(err, ErrLoginLocked):
+		errorCode = response.CodeTooManyRequests
+	case errors.Is(err, ErrMFAChallengeInvalid):
+		errorCode = response.CodeUnauthorized
+	case errors.Is(err, ErrMFAInvalidCode), errors.Is(err, ErrMFANotEnrolled), errors.Is(err, ErrMFAAlreadyEnabled):
+		errorCode = response.CodeInvalidParams
+	case errors.Is(err, ErrMFARequired):
+		errorCode = response.CodeForbidden
+	}
+	api.writeErrorResponse(w, response.Error(errorCode, err.Error()))
+}
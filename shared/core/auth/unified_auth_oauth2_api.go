@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/szjason72/zervigo/shared/core/response"
+)
+
+// ==================== OAuth2 / OpenID Connect ====================
+//
+// 合作方门户通过授权码模式（PKCE）使用本平台账号登录：
+//  1. 浏览器跳转到 GET /oauth2/authorize，未登录时重定向到 OAUTH2_LOGIN_URL?return_to=...
+//  2. 登录页完成登录后携带 Authorization: Bearer <token> 以 POST 提交同样的参数，
+//     返回 {"redirect_to": "..."}，由登录页完成跳转
+//  3. 合作方后端用授权码在 /oauth2/token 换取 access_token / id_token / refresh_token
+
+// handleOAuth2Authorize 授权端点
+func (api *UnifiedAuthAPI) handleOAuth2Authorize(w http.ResponseWriter, r *http.Request) {
+	if !api.oauth2Enabled(w) {
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		api.writeOAuth2Error(w, &OAuth2Error{Code: "invalid_request", Description: "Method not allowed", Status: http.StatusMethodNotAllowed})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		api.writeOAuth2Error(w, newOAuth2Error("invalid_request", "无法解析请求参数"))
+		return
+	}
+
+	req := &OAuth2AuthorizationRequest{
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		ResponseType:        r.Form.Get("response_type"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+		Nonce:               r.Form.Get("nonce"),
+		Prompt:              r.Form.Get("prompt"),
+	}
+
+	// 客户端或redirect_uri无效时直接返回错误，不能重定向
+	client, oauthErr := api.oauth2.ValidateAuthorizeClient(req)
+	if oauthErr != nil {
+		api.writeOAuth2Error(w, oauthErr)
+		return
+	}
+
+	// 识别当前登录用户（本平台签发的用户token）
+	var user *UserInfo
+	if token := bearerToken(r); token != "" {
+		if result, err := api.authSystem.ValidateJWT(token); err == nil && result.Success {
+			user = result.User
+		}
+	}
+	if user == nil {
+		if req.Prompt == "none" {
+			api.redirectAuthorization(w, r, req, url.Values{"error": {"login_required"}})
+			return
+		}
+		if loginURL := api.oauth2.LoginURL(api.oauth2.Issuer() + "/oauth2/authorize?" + authorizeQuery(req)); loginURL != "" {
+			http.Redirect(w, r, loginURL, http.StatusFound)
+			return
+		}
+		api.writeOAuth2Error(w, newOAuth2Error("login_required", "用户未登录"))
+		return
+	}
+
+	code, oauthErr := api.oauth2.CreateAuthorizationCode(client, req, user)
+	if oauthErr != nil {
+		api.redirectAuthorization(w, r, req, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+		})
+		return
+	}
+	api.redirectAuthorization(w, r, req, url.Values{"code": {code}})
+}
+
+// handleOAuth2Token 令牌端点（application/x-www-form-urlencoded）
+func (api *UnifiedAuthAPI) handleOAuth2Token(w http.ResponseWriter, r *http.Request) {
+	if !api.oauth2Enabled(w) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		api.writeOAuth2Error(w, &OAuth2Error{Code: "invalid_request", Description: "Method not allowed", Status: http.StatusMethodNotAllowed})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		api.writeOAuth2Error(w, newOAuth2Error("invalid_request", "无法解析请求参数"))
+		return
+	}
+
+	clientID, clientSecret := clientCredentialsFromRequest(r)
+	client, oauthErr := api.oauth2.AuthenticateClient(clientID, clientSecret)
+	if oauthErr != nil {
+		if oauthErr.Code == "invalid_client" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		}
+		api.writeOAuth2Error(w, oauthErr)
+		return
+	}
+
+	var resp *OAuth2TokenResponse
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case GrantTypeAuthorizationCode:
+		resp, oauthErr = api.oauth2.ExchangeAuthorizationCode(client,
+			r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"),
+			api.clientIP(r), getUserAgent(r))
+	case GrantTypeRefreshToken:
+		resp, oauthErr = api.oauth2.RefreshGrant(client,
+			r.PostForm.Get("refresh_token"), r.PostForm.Get("scope"), api.clientIP(r), getUserAgent(r))
+	case GrantTypeClientCredentials:
+		resp, oauthErr = api.oauth2.ClientCredentials(client, r.PostForm.Get("scope"))
+	case "":
+		oauthErr = newOAuth2Error("invalid_request", "缺少grant_type")
+	default:
+		oauthErr = newOAuth2Error("unsupported_grant_type", "不支持的授权类型: "+grantType)
+	}
+	if oauthErr != nil {
+		api.writeOAuth2Error(w, oauthErr)
+		return
+	}
+	api.writeOAuth2JSON(w, http.StatusOK, resp)
+}
+
+// handleOAuth2UserInfo OIDC用户信息端点
+func (api *UnifiedAuthAPI) handleOAuth2UserInfo(w http.ResponseWriter, r *http.Request) {
+	if !api.oauth2Enabled(w) {
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		api.writeOAuth2Error(w, &OAuth2Error{Code: "invalid_request", Description: "Method not allowed", Status: http.StatusMethodNotAllowed})
+		return
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="oauth2"`)
+		api.writeOAuth2Error(w, newOAuth2Error("invalid_token", "缺少访问令牌"))
+		return
+	}
+	info, oauthErr := api.oauth2.UserInfo(token)
+	if oauthErr != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+		api.writeOAuth2Error(w, oauthErr)
+		return
+	}
+	api.writeOAuth2JSON(w, http.StatusOK, info)
+}
+
+// handleOAuth2Introspect 令牌内省端点（需客户端认证）
+func (api *UnifiedAuthAPI) handleOAuth2Introspect(w http.ResponseWriter, r *http.Request) {
+	client, ok := api.authenticateTokenRequest(w, r)
+	if !ok {
+		return
+	}
+	api.writeOAuth2JSON(w, http.StatusOK,
+		api.oauth2.Introspect(client, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint")))
+}
+
+// handleOAuth2Revoke 令牌吊销端点（需客户端认证）
+func (api *UnifiedAuthAPI) handleOAuth2Revoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := api.authenticateTokenRequest(w, r)
+	if !ok {
+		return
+	}
+	if oauthErr := api.oauth2.Revoke(client, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint")); oauthErr != nil {
+		api.writeOAuth2Error(w, oauthErr)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleOIDCDiscovery OIDC发现文档
+func (api *UnifiedAuthAPI) handleOIDCDiscovery(w http.ResponseWriter, r *http.Request) {
+	if !api.oauth2Enabled(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.oauth2.Discovery())
+}
+
+// handleJWKS 签名公钥（用户令牌、服务令牌与OAuth2令牌共用），各服务据此本地校验
+func (api *UnifiedAuthAPI) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	keySet := api.authSystem.KeySet()
+	if keySet == nil {
+		api.writeErrorResponse(w, response.Error(response.CodeInternalError, "未配置签名密钥集"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(keySet.JWKS())
+}
+
+// handleOAuth2Clients 注册OAuth2客户端（仅超级管理员）
+func (api *UnifiedAuthAPI) handleOAuth2Clients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Method not allowed"))
+		return
+	}
+	if api.oauth2 == nil {
+		api.writeErrorResponse(w, response.Error(response.CodeInternalError, "OAuth2未启用"))
+		return
+	}
+
+	result, err := api.authSystem.ValidateJWT(bearerToken(r))
+	if err != nil || !result.Success {
+		api.writeErrorResponse(w, response.Error(response.CodeUnauthorized, "未登录或token无效"))
+		return
+	}
+	if result.User.Role != "super_admin" {
+		api.writeErrorResponse(w, response.Error(response.CodeForbidden, "仅超级管理员可注册OAuth2客户端"))
+		return
+	}
+	if !api.requireMFASession(w, result) {
+		return
+	}
+
+	var req struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		GrantTypes   []string `json:"grant_types"`
+		Public       bool     `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, "Invalid JSON"))
+		return
+	}
+
+	client, secret, err := api.oauth2.RegisterClient(req.Name, req.RedirectURIs, req.Scopes, req.GrantTypes, req.Public)
+	if err != nil {
+		api.writeErrorResponse(w, response.Error(response.CodeInvalidParams, err.Error()))
+		return
+	}
+	api.authSystem.logAccess(result.User.ID, "oauth2_client_register:"+client.ID, "auth", "success", api.clientIP(r), getUserAgent(r))
+
+	api.writeSuccessResponse(w, response.Success("OAuth2客户端注册成功", map[string]interface{}{
+		"client_id":     client.ID,
+		"client_secret": secret, // 仅返回一次，请妥善保存
+		"name":          client.Name,
+		"redirect_uris": client.RedirectURIs,
+		"scopes":        client.Scopes,
+		"grant_types":   client.GrantTypes,
+		"public":        client.IsPublic(),
+	}))
+}
+
+// authenticateTokenRequest 内省/吊销端点的公共校验：POST表单 + 客户端认证
+func (api *UnifiedAuthAPI) authenticateTokenRequest(w http.ResponseWriter, r *http.Request) (*OAuth2Client, bool) {
+	if !api.oauth2Enabled(w) {
+		return nil, false
+	}
+	if r.Method != http.MethodPost {
+		api.writeOAuth2Error(w, &OAuth2Error{Code: "invalid_request", Description: "Method not allowed", Status: http.StatusMethodNotAllowed})
+		return nil, false
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		api.writeOAuth2Error(w, newOAuth2Error("invalid_request", "缺少token"))
+		return nil, false
+	}
+
+	clientID, clientSecret := clientCredentialsFromRequest(r)
+	client, oauthErr := api.oauth2.AuthenticateClient(clientID, clientSecret)
+	if oauthErr != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		api.writeOAuth2Error(w, oauthErr)
+		return nil, false
+	}
+	return client, true
+}
+
+// redirectAuthorization 授权结果回调；POST（登录页提交）时返回JSON由前端跳转
+func (api *UnifiedAuthAPI) redirectAuthorization(w http.ResponseWriter, r *http.Request, req *OAuth2AuthorizationRequest, params url.Values) {
+	location := AuthorizationRedirect(req.RedirectURI, req.State, params)
+	if r.Method == http.MethodPost {
+		api.writeOAuth2JSON(w, http.StatusOK, map[string]string{"redirect_to": location})
+		return
+	}
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+func (api *UnifiedAuthAPI) oauth2Enabled(w http.ResponseWriter) bool {
+	if api.oauth2 == nil {
+		api.writeOAuth2Error(w, &OAuth2Error{Code: "temporarily_unavailable", Description: "OAuth2未启用", Status: http.StatusServiceUnavailable})
+		return false
+	}
+	return true
+}
+
+// writeOAuth2JSON OAuth2响应禁止缓存（RFC 6749 §5.1）
+func (api *UnifiedAuthAPI) writeOAuth2JSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeOAuth2Error OAuth2端点按规范使用HTTP状态码与 error/error_description 返回错误
+func (api *UnifiedAuthAPI) writeOAuth2Error(w http.ResponseWriter, oauthErr *OAuth2Error) {
+	api.writeOAuth2JSON(w, oauthErr.Status, oauthErr)
+}
+
+// authorizeQuery 重建授权请求参数（登录后回到授权端点）
+func authorizeQuery(req *OAuth2AuthorizationRequest) string {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"client_id":             req.ClientID,
+		"redirect_uri":          req.RedirectURI,
+		"response_type":         req.ResponseType,
+		"scope":                 req.Scope,
+		"state":                 req.State,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+		"nonce":                 req.Nonce,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values.Encode()
+}
+
+// clientCredentialsFromRequest 支持 client_secret_basic 与 client_secret_post
+func clientCredentialsFromRequest(r *http.Request) (string, string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		// RFC 6749 §2.3.1：Basic认证中的凭证需先做表单编码
+		if decoded, err := url.QueryUnescape(id); err == nil {
+			id = decoded
+		}
+		if decoded, err := url.QueryUnescape(secret); err == nil {
+			secret = decoded
+		}
+		return id, secret
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+// bearerToken 从Authorization头提取Bearer令牌
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+	return ""
+}
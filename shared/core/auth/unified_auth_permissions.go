@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// InvalidatePermissionCache 清空本地权限缓存（其他服务修改角色/权限后通过策略版本号自动失效）
+func (uas *UnifiedAuthSystem) InvalidatePermissionCache() {
+	uas.permissions.invalidate()
+}
+
+// resolveRolePermissions 展开角色继承链并合并权限，结果按角色缓存
+func (uas *UnifiedAuthSystem) resolveRolePermissions(role string) (*rolePermissions, error) {
+	uas.checkPolicyVersion()
+	if cached, ok := uas.permissions.get(role); ok {
+		return cached, nil
+	}
+
+	chain, err := ResolveRoleChain(role, uas.getRoleParent)
+	if err != nil {
+		if !errors.Is(err, ErrRoleInheritanceCycle) && !errors.Is(err, ErrRoleInheritanceDepth) {
+			return nil, err
+		}
+		// 继承配置有误时只使用已展开的部分，避免整个角色失去权限
+		log.Printf("WARN: 展开角色 %s 的继承链失败: %v", role, err)
+	}
+
+	seen := make(map[string]bool)
+	expressions := make([]string, 0)
+	for _, name := range chain {
+		permissions, err := uas.getRolePermissions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, permission := range permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				expressions = append(expressions, permission)
+			}
+		}
+	}
+
+	return uas.permissions.put(role, expressions), nil
+}
+
+// getRoleParent 获取角色继承的父角色，数据库未配置时使用内置角色配置
+func (uas *UnifiedAuthSystem) getRoleParent(role string) (string, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(extends_role, '') FROM zervigo_auth_roles WHERE role_name = %s`, uas.placeholder(1))
+
+	var parent string
+	err := uas.db.QueryRow(query, role).Scan(&parent)
+	if err == nil && parent != "" {
+		return parent, nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		// 旧库没有extends_role列时退回内置配置
+		log.Printf("DEBUG: 查询角色 %s 的父角色失败: %v", role, err)
+	}
+
+	if roleInfo, exists := uas.roleConfig.Roles[role]; exists {
+		return roleInfo.Extends, nil
+	}
+	return "", nil
+}
+
+// checkPolicyVersion 定期读取策略版本号，其他服务修改角色/权限后清空本地缓存
+func (uas *UnifiedAuthSystem) checkPolicyVersion() {
+	if !uas.permissions.versionCheckDue() {
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT version FROM %s WHERE id = 1`, PolicyVersionTable)
+	var version int64
+	if err := uas.db.QueryRow(query).Scan(&version); err != nil {
+		// 版本表不存在时仅依赖缓存过期时间
+		return
+	}
+	uas.permissions.observeVersion(version)
+}
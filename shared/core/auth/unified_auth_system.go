@@ -1,22 +1,32 @@
 package auth
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // UnifiedAuthSystem 统一认证系统
 type UnifiedAuthSystem struct {
-	db         *sql.DB
-	jwtSecret  string
-	roleConfig *RoleConfig
-	dbType     string // "postgresql" 或 "mysql"
+	db          *sql.DB
+	jwtSecret   string
+	roleConfig  *RoleConfig
+	dbType      string           // "postgresql" 或 "mysql"
+	revocations RevocationStore  // 访问令牌吊销列表（与用户服务共享同一Redis时登出才能跨服务生效）
+	keySet      *KeySet          // 非对称签名密钥集，未设置时沿用HS256
+	verifier    VerificationKeys // 校验公钥（本地KeySet或远程JWKS）
+	acceptHS256 bool             // 是否仍接受jwtSecret签名的旧令牌
+	loginGuard  *LoginGuard      // 登录失败计数与锁定
+	mfa         *MFAService      // 两步验证，未设置时只校验密码
+	permissions *permissionCache // 角色展开继承后的权限缓存
 }
 
 // detectDatabaseType 检测数据库类型
@@ -67,6 +77,7 @@ type RoleInfo struct {
 	Name        string   `json:"name"`
 	Level       int      `json:"level"`
 	Permissions []string `json:"permissions"`
+	Extends     string   `json:"extends,omitempty"` // 父角色，继承其全部权限（数据库中未配置时使用）
 	Description string   `json:"description"`
 }
 
@@ -97,6 +108,9 @@ type UserInfo struct {
 	UpdatedAt          *time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// UserTokenIssuer 用户令牌的签发者
+const UserTokenIssuer = "jobfirst-auth"
+
 // JWTClaims JWT声明
 type JWTClaims struct {
 	UserID      int      `json:"user_id"`
@@ -105,6 +119,7 @@ type JWTClaims struct {
 	Role        string   `json:"role"`
 	Level       int      `json:"level"`
 	Permissions []string `json:"permissions"`
+	AMR         []string `json:"amr,omitempty"` // 认证方式，完成两步验证时包含 mfa
 	jwt.RegisteredClaims
 }
 
@@ -114,8 +129,14 @@ type AuthResult struct {
 	Token       string    `json:"token,omitempty"`
 	User        *UserInfo `json:"user,omitempty"`
 	Permissions []string  `json:"permissions,omitempty"`
+	AMR         []string  `json:"amr,omitempty"`
 	Error       string    `json:"error,omitempty"`
 	ErrorCode   string    `json:"error_code,omitempty"`
+
+	// ErrorCode为MFA_REQUIRED时返回，凭此调用两步验证接口完成登录
+	ChallengeToken        string   `json:"challenge_token,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"` // 首次登记完成时返回，仅显示一次
 }
 
 // NewUnifiedAuthSystem 创建统一认证系统
@@ -132,12 +153,14 @@ func NewUnifiedAuthSystem(db *sql.DB, jwtSecret string) *UnifiedAuthSystem {
 				Name:        "user",
 				Level:       2,
 				Permissions: []string{"read:public", "read:own", "write:own"},
+				Extends:     "guest",
 				Description: "普通用户",
 			},
 			"admin": {
 				Name:        "admin",
 				Level:       3,
 				Permissions: []string{"read:public", "read:own", "write:own", "read:all", "write:all", "delete:own"},
+				Extends:     "user",
 				Description: "管理员",
 			},
 			"super_admin": {
@@ -150,10 +173,14 @@ func NewUnifiedAuthSystem(db *sql.DB, jwtSecret string) *UnifiedAuthSystem {
 	}
 
 	uas := &UnifiedAuthSystem{
-		db:         db,
-		jwtSecret:  jwtSecret,
-		roleConfig: roleConfig,
-		dbType:     detectDatabaseType(db),
+		db:          db,
+		jwtSecret:   jwtSecret,
+		roleConfig:  roleConfig,
+		dbType:      detectDatabaseType(db),
+		revocations: NewMemoryRevocationStore(),
+		acceptHS256: true,
+		loginGuard:  NewLoginGuard(LockoutPolicy{}, NewMemoryLoginAttemptStore()),
+		permissions: newPermissionCache(permissionCacheTTLFromEnv()),
 	}
 	log.Printf("INFO: UnifiedAuthSystem 检测到数据库类型: %s", uas.dbType)
 	return uas
@@ -604,9 +631,21 @@ func (uas *UnifiedAuthSystem) createDefaultSuperAdmin() error {
 
 // Authenticate 用户认证
 func (uas *UnifiedAuthSystem) Authenticate(username, password string) (*AuthResult, error) {
+	return uas.AuthenticateFrom(username, password, "")
+}
+
+// AuthenticateFrom 用户认证（按账号与客户端IP统计失败次数，超过阈值后临时锁定）
+func (uas *UnifiedAuthSystem) AuthenticateFrom(username, password, clientIP string) (*AuthResult, error) {
+	if err := uas.loginGuard.Check(username, clientIP); err != nil {
+		return lockedAuthResult(err), nil
+	}
+
 	// 查询用户信息
 	user, err := uas.getUserByUsername(username)
 	if err != nil {
+		if lockErr := uas.loginGuard.RecordFailure(username, clientIP); lockErr != nil {
+			return lockedAuthResult(lockErr), nil
+		}
 		return &AuthResult{
 			Success:   false,
 			Error:     "用户不存在",
@@ -625,6 +664,9 @@ func (uas *UnifiedAuthSystem) Authenticate(username, password string) (*AuthResu
 
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		if lockErr := uas.loginGuard.RecordFailure(username, clientIP); lockErr != nil {
+			return lockedAuthResult(lockErr), nil
+		}
 		return &AuthResult{
 			Success:   false,
 			Error:     "密码错误",
@@ -632,6 +674,17 @@ func (uas *UnifiedAuthSystem) Authenticate(username, password string) (*AuthResu
 		}, nil
 	}
 
+	// 已启用两步验证或角色强制两步验证时，先返回两步验证凭证（失败计数在两步验证通过后才清零）
+	if challenge, err := uas.mfaChallenge(user); err != nil || challenge != nil {
+		return challenge, err
+	}
+	uas.loginGuard.RecordSuccess(username)
+
+	return uas.completeAuthentication(user, []string{AMRPassword}, clientIP), nil
+}
+
+// completeAuthentication 签发访问令牌
+func (uas *UnifiedAuthSystem) completeAuthentication(user *UserInfo, amr []string, clientIP string) *AuthResult {
 	// 获取用户权限
 	permissions, err := uas.getUserPermissions(user.Role)
 	if err != nil {
@@ -639,40 +692,111 @@ func (uas *UnifiedAuthSystem) Authenticate(username, password string) (*AuthResu
 			Success:   false,
 			Error:     "获取用户权限失败",
 			ErrorCode: "PERMISSION_ERROR",
-		}, nil
+		}
 	}
 
 	// 生成JWT token
-	token, err := uas.generateJWT(user, permissions)
+	token, err := uas.generateJWT(user, permissions, amr)
 	if err != nil {
 		return &AuthResult{
 			Success:   false,
 			Error:     "生成token失败",
 			ErrorCode: "TOKEN_ERROR",
-		}, nil
+		}
 	}
 
 	// 更新最后登录时间
 	uas.updateLastLogin(user.ID)
 
 	// 记录访问日志
-	uas.logAccess(user.ID, "login", "auth", "success", "", "")
+	uas.logAccess(user.ID, "login", "auth", "success", clientIP, "")
 
 	return &AuthResult{
 		Success:     true,
 		Token:       token,
 		User:        user,
 		Permissions: permissions,
-	}, nil
+		AMR:         amr,
+	}
+}
+
+// lockedAuthResult 锁定期内的认证结果
+func lockedAuthResult(err error) *AuthResult {
+	return &AuthResult{
+		Success:   false,
+		Error:     err.Error(),
+		ErrorCode: "ACCOUNT_LOCKED",
+	}
+}
+
+// SetLoginGuard 设置登录防护（多实例部署需使用Redis计数）
+func (uas *UnifiedAuthSystem) SetLoginGuard(guard *LoginGuard) {
+	uas.loginGuard = guard
+}
+
+// SetMFAService 启用两步验证（认证服务调用）
+func (uas *UnifiedAuthSystem) SetMFAService(mfa *MFAService) {
+	uas.mfa = mfa
+}
+
+// SetRevocationStore 设置访问令牌吊销列表
+func (uas *UnifiedAuthSystem) SetRevocationStore(store RevocationStore) {
+	uas.revocations = store
+}
+
+// SetKeySet 使用非对称密钥签发令牌（认证服务调用），同时用于本地校验
+func (uas *UnifiedAuthSystem) SetKeySet(keySet *KeySet) {
+	uas.keySet = keySet
+	uas.verifier = keySet
+	uas.acceptHS256 = legacyHS256Allowed()
+}
+
+// SetVerificationKeys 只校验不签发的服务通过JWKS获取公钥
+func (uas *UnifiedAuthSystem) SetVerificationKeys(keys VerificationKeys) {
+	uas.verifier = keys
+	uas.acceptHS256 = legacyHS256Allowed()
+}
+
+// KeySet 签名密钥集（未设置时为nil）
+func (uas *UnifiedAuthSystem) KeySet() *KeySet {
+	return uas.keySet
+}
+
+// legacySecret 仍接受HS256旧令牌时返回共享密钥
+func (uas *UnifiedAuthSystem) legacySecret() string {
+	if uas.acceptHS256 || uas.verifier == nil {
+		return uas.jwtSecret
+	}
+	return ""
+}
+
+// RevokeToken 吊销访问令牌（按jti记录到令牌过期）
+func (uas *UnifiedAuthSystem) RevokeToken(tokenString string) error {
+	claims, err := ParseUserToken(tokenString, uas.verifier, uas.legacySecret())
+	if err != nil {
+		return fmt.Errorf("无效的token: %w", err)
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return uas.revokeJTI(claims.ID, claims.ExpiresAt.Time)
+}
+
+// revokeJTI 将jti加入吊销列表，保留到令牌过期
+func (uas *UnifiedAuthSystem) revokeJTI(jti string, expiresAt time.Time) error {
+	if uas.revocations == nil {
+		return errors.New("未配置令牌吊销列表")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return uas.revocations.Revoke(ctx, jti, time.Until(expiresAt))
 }
 
 // ValidateJWT 验证JWT token
 func (uas *UnifiedAuthSystem) ValidateJWT(tokenString string) (*AuthResult, error) {
 	// 解析JWT token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(uas.jwtSecret), nil
-	})
-
+	claims, err := ParseUserToken(tokenString, uas.verifier, uas.legacySecret())
 	if err != nil {
 		return &AuthResult{
 			Success:   false,
@@ -681,21 +805,25 @@ func (uas *UnifiedAuthSystem) ValidateJWT(tokenString string) (*AuthResult, erro
 		}, nil
 	}
 
-	claims, ok := token.Claims.(*JWTClaims)
-	if !ok || !token.Valid {
+	// 检查token是否过期
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
 		return &AuthResult{
 			Success:   false,
-			Error:     "token验证失败",
-			ErrorCode: "TOKEN_VALIDATION_FAILED",
+			Error:     "token已过期",
+			ErrorCode: "TOKEN_EXPIRED",
 		}, nil
 	}
 
-	// 检查token是否过期
-	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
+	// 检查token是否已被注销
+	if err := checkRevoked(uas.revocations, claims.ID); err != nil {
+		errorCode := "TOKEN_REVOKED"
+		if !errors.Is(err, ErrTokenRevoked) {
+			errorCode = "REVOCATION_CHECK_FAILED"
+		}
 		return &AuthResult{
 			Success:   false,
-			Error:     "token已过期",
-			ErrorCode: "TOKEN_EXPIRED",
+			Error:     err.Error(),
+			ErrorCode: errorCode,
 		}, nil
 	}
 
@@ -722,6 +850,7 @@ func (uas *UnifiedAuthSystem) ValidateJWT(tokenString string) (*AuthResult, erro
 		Success:     true,
 		User:        user,
 		Permissions: claims.Permissions,
+		AMR:         claims.AMR,
 	}, nil
 }
 
@@ -738,19 +867,13 @@ func (uas *UnifiedAuthSystem) CheckPermission(userID int, permission string) (bo
 		return true, nil
 	}
 
-	// 检查角色权限
-	permissions, err := uas.getUserPermissions(user.Role)
+	// 检查角色权限（含继承的权限，显式拒绝优先）
+	resolved, err := uas.resolveRolePermissions(user.Role)
 	if err != nil {
 		return false, err
 	}
 
-	for _, perm := range permissions {
-		if perm == permission || perm == "*" {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return resolved.set.Allows(permission), nil
 }
 
 // getUserByUsername 根据用户名获取用户信息
@@ -789,8 +912,17 @@ func (uas *UnifiedAuthSystem) getUserByID(userID int) (*UserInfo, error) {
 	return uas.scanUser(uas.db.QueryRow(query, userID))
 }
 
-// getUserPermissions 获取用户权限
+// getUserPermissions 获取用户权限（含继承的权限）
 func (uas *UnifiedAuthSystem) getUserPermissions(role string) ([]string, error) {
+	resolved, err := uas.resolveRolePermissions(role)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, resolved.expressions...), nil
+}
+
+// getRolePermissions 获取角色自身配置的权限（不含继承）
+func (uas *UnifiedAuthSystem) getRolePermissions(role string) ([]string, error) {
 	query := fmt.Sprintf(`
         SELECT p.permission_code
         FROM zervigo_auth_roles r
@@ -854,7 +986,7 @@ func (uas *UnifiedAuthSystem) getAllPermissionCodes() ([]string, error) {
 }
 
 // generateJWT 生成JWT token
-func (uas *UnifiedAuthSystem) generateJWT(user *UserInfo, permissions []string) (string, error) {
+func (uas *UnifiedAuthSystem) generateJWT(user *UserInfo, permissions []string, amr []string) (string, error) {
 	roleInfo, exists := uas.roleConfig.Roles[user.Role]
 	if !exists {
 		roleInfo = &RoleInfo{Level: 1}
@@ -867,15 +999,20 @@ func (uas *UnifiedAuthSystem) generateJWT(user *UserInfo, permissions []string)
 		Role:        user.Role,
 		Level:       roleInfo.Level,
 		Permissions: permissions,
+		AMR:         amr,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(), // jti，用于登出时吊销
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(168 * time.Hour)), // 7天，适配测试需要
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "jobfirst-auth",
+			Issuer:    UserTokenIssuer,
 			Subject:   fmt.Sprintf("%d", user.ID),
 		},
 	}
 
+	if uas.keySet != nil {
+		return uas.keySet.Sign(claims, "")
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(uas.jwtSecret))
 }
@@ -19,11 +19,24 @@ type ZerviAuthAdapter struct {
 // NewZerviAuthAdapter 创建Go-Zervi认证适配器
 func NewZerviAuthAdapter(db *sql.DB, jwtSecret string) *ZerviAuthAdapter {
 	unifiedAuth := NewUnifiedAuthSystem(db, jwtSecret)
+	if keys := VerificationKeysFromEnv(); keys != nil {
+		unifiedAuth.SetVerificationKeys(keys)
+	}
 	return &ZerviAuthAdapter{
 		unifiedAuth: unifiedAuth,
 	}
 }
 
+// SetRevocationStore 设置访问令牌吊销列表
+func (adapter *ZerviAuthAdapter) SetRevocationStore(store RevocationStore) {
+	adapter.unifiedAuth.SetRevocationStore(store)
+}
+
+// SetVerificationKeys 设置校验公钥（默认按AUTH_JWKS_URL）
+func (adapter *ZerviAuthAdapter) SetVerificationKeys(keys VerificationKeys) {
+	adapter.unifiedAuth.SetVerificationKeys(keys)
+}
+
 // RequireAuth 需要登录的中间件（适配jobfirst-core接口）
 func (adapter *ZerviAuthAdapter) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -71,6 +84,11 @@ func (adapter *ZerviAuthAdapter) RequireAuth() gin.HandlerFunc {
 		c.Set("username", result.User.Username)
 		c.Set("role", result.User.Role)
 		c.Set("email", result.User.Email)
+		c.Set("amr", result.AMR)
+		c.Set("subscription_status", result.User.SubscriptionStatus)
+		if result.User.SubscriptionType != nil {
+			c.Set("subscription_type", *result.User.SubscriptionType)
+		}
 
 		fmt.Printf("DEBUG: Zervi认证中间件 - 用户信息已设置到上下文，继续处理请求\n")
 		c.Next()
@@ -108,6 +126,29 @@ func (adapter *ZerviAuthAdapter) RequireDevTeam() gin.HandlerFunc {
 	}
 }
 
+// RequireMFA 需要已完成两步验证的会话（用于修改生产配置等敏感操作）
+func (adapter *ZerviAuthAdapter) RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 路由组已使用RequireAuth时不重复校验
+		if _, authenticated := c.Get("user_id"); !authenticated {
+			adapter.RequireAuth()(c)
+			if c.IsAborted() {
+				return
+			}
+		}
+
+		amr, _ := c.Get("amr")
+		methods, _ := amr.([]string)
+		if !HasAMR(methods, AMRMFA) {
+			adapter.writeErrorResponse(c, response.Error(response.CodeForbidden, "该操作需要完成两步验证后重新登录"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // extractToken 提取token（与jobfirst-core保持兼容）
 func (adapter *ZerviAuthAdapter) extractToken(c *gin.Context) string {
 	// 1. 从Authorization header获取
@@ -166,7 +207,7 @@ func (adapter *ZerviAuthAdapter) writeSuccessResponse(c *gin.Context, resp *resp
 
 // Login 登录方法（适配jobfirst-core接口）
 func (adapter *ZerviAuthAdapter) Login(req ZerviLoginRequest, clientIP, userAgent string) (*ZerviLoginResponse, error) {
-	result, err := adapter.unifiedAuth.Authenticate(req.Username, req.Password)
+	result, err := adapter.unifiedAuth.AuthenticateFrom(req.Username, req.Password, clientIP)
 	if err != nil {
 		return nil, err
 	}
@@ -165,12 +165,16 @@ type ServerConfig struct {
 
 // AuthConfig 认证配置
 type AuthConfig struct {
-	JWTSecret        string `mapstructure:"jwt_secret"`
-	TokenExpiry      string `mapstructure:"token_expiry"`
-	RefreshExpiry    string `mapstructure:"refresh_expiry"`
-	PasswordMin      int    `mapstructure:"password_min_length"`
-	MaxLoginAttempts int    `mapstructure:"max_login_attempts"`
-	LockoutDuration  string `mapstructure:"lockout_duration"`
+	JWTSecret            string `mapstructure:"jwt_secret"`
+	TokenExpiry          string `mapstructure:"token_expiry"`
+	RefreshExpiry        string `mapstructure:"refresh_expiry"`
+	PasswordMin          int    `mapstructure:"password_min_length"`
+	PasswordMinClasses   int    `mapstructure:"password_min_classes"`
+	PasswordDenylistFile string `mapstructure:"password_denylist_file"`
+	MaxLoginAttempts     int    `mapstructure:"max_login_attempts"`
+	MaxIPLoginAttempts   int    `mapstructure:"max_ip_login_attempts"`
+	LockoutDuration      string `mapstructure:"lockout_duration"`
+	MaxLockoutDuration   string `mapstructure:"max_lockout_duration"`
 }
 
 // LogConfig 日志配置
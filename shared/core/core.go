@@ -26,6 +26,7 @@ import (
 type AuthMiddlewareInterface interface {
 	RequireAuth() gin.HandlerFunc
 	RequireDevTeam() gin.HandlerFunc
+	RequireMFA() gin.HandlerFunc
 }
 
 // Core JobFirst核心包
@@ -191,17 +192,46 @@ func NewCore(configPath string) (*Core, error) {
 		}
 	}
 
+	// 定期补偿进程中断时未完成的多数据库事务（Saga）
+	sagaRecoveryInterval := parseOptionalDuration(getEnvString("SAGA_RECOVERY_INTERVAL", ""))
+	if sagaRecoveryInterval <= 0 {
+		sagaRecoveryInterval = time.Minute
+	}
+	sagaStaleAfter := parseOptionalDuration(getEnvString("SAGA_RECOVERY_STALE_AFTER", ""))
+	if sagaStaleAfter <= 0 {
+		sagaStaleAfter = 10 * time.Minute
+	}
+	dbManager.StartSagaRecovery(sagaRecoveryInterval, sagaStaleAfter)
+
 	// 6. 初始化认证管理器
 	authConfig := auth.AuthConfig{
-		JWTSecret:        appConfig.Auth.JWTSecret,
-		TokenExpiry:      parseDuration(appConfig.Auth.TokenExpiry),
-		RefreshExpiry:    parseDuration(appConfig.Auth.RefreshExpiry),
-		PasswordMin:      appConfig.Auth.PasswordMin,
-		MaxLoginAttempts: appConfig.Auth.MaxLoginAttempts,
-		LockoutDuration:  parseDuration(appConfig.Auth.LockoutDuration),
+		JWTSecret:            appConfig.Auth.JWTSecret,
+		TokenExpiry:          parseDuration(appConfig.Auth.TokenExpiry),
+		RefreshExpiry:        parseDuration(appConfig.Auth.RefreshExpiry),
+		PasswordMin:          appConfig.Auth.PasswordMin,
+		PasswordMinClasses:   appConfig.Auth.PasswordMinClasses,
+		PasswordDenylistFile: appConfig.Auth.PasswordDenylistFile,
+		MaxLoginAttempts:     appConfig.Auth.MaxLoginAttempts,
+		MaxIPLoginAttempts:   appConfig.Auth.MaxIPLoginAttempts,
+		LockoutDuration:      parseOptionalDuration(appConfig.Auth.LockoutDuration),
+		MaxLockoutDuration:   parseOptionalDuration(appConfig.Auth.MaxLockoutDuration),
 	}
 
 	authManager := auth.NewAuthManager(dbManager.GetDB(), authConfig)
+	authManager.EnsureTokenTables()
+	// 按AUTH_JWKS_URL校验非对称签名的令牌；签发令牌的服务另行调用SetKeySet
+	if keys := auth.VerificationKeysFromEnv(); keys != nil {
+		authManager.SetVerificationKeys(keys)
+	}
+
+	// 访问令牌吊销列表：Redis可用时多实例共享，否则仅在进程内生效
+	var revocations auth.RevocationStore = auth.NewMemoryRevocationStore()
+	if dbManager.GetRedis() != nil {
+		revocations = auth.NewRedisRevocationStore(dbManager.GetRedis().GetClient())
+		// 登录失败计数同样放在Redis，与认证服务共享锁定状态
+		authManager.SetLoginAttemptStore(auth.NewRedisLoginAttemptStore(dbManager.GetRedis().GetClient()))
+	}
+	authManager.SetRevocationStore(revocations)
 
 	// 7. 初始化团队管理器
 	teamManager := team.NewManager(dbManager.GetDB())
@@ -235,6 +265,7 @@ func NewCore(configPath string) (*Core, error) {
 
 	// 创建Go-Zervi认证适配器
 	zerviAuthAdapter := auth.NewZerviAuthAdapter(sqlDB, appConfig.Auth.JWTSecret)
+	zerviAuthAdapter.SetRevocationStore(revocations)
 
 	// 为了兼容性，创建一个包装器
 	// authMiddleware := &ZerviAuthMiddlewareWrapper{adapter: zerviAuthAdapter}
@@ -380,6 +411,15 @@ func parseDuration(s string) time.Duration {
 	return duration
 }
 
+// parseOptionalDuration 解析时间字符串，未配置或无效时返回0（由使用方取默认值）
+func parseOptionalDuration(s string) time.Duration {
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
 // parseGORMLogLevel 解析GORM日志级别
 func parseGORMLogLevel(level string) gormlogger.LogLevel {
 	switch level {
@@ -417,6 +457,11 @@ func (w *ZerviAuthMiddlewareWrapper) RequireDevTeam() gin.HandlerFunc {
 	return w.adapter.RequireDevTeam()
 }
 
+// RequireMFA 需要已完成两步验证的中间件
+func (w *ZerviAuthMiddlewareWrapper) RequireMFA() gin.HandlerFunc {
+	return w.adapter.RequireMFA()
+}
+
 // ZerviAuthMiddlewareInterface 接口，使Go-Zervi认证适配器兼容jobfirst-core接口
 type ZerviAuthMiddlewareInterface struct {
 	adapter *auth.ZerviAuthAdapter
@@ -432,6 +477,11 @@ func (w *ZerviAuthMiddlewareInterface) RequireDevTeam() gin.HandlerFunc {
 	return w.adapter.RequireDevTeam()
 }
 
+// RequireMFA 需要已完成两步验证的中间件
+func (w *ZerviAuthMiddlewareInterface) RequireMFA() gin.HandlerFunc {
+	return w.adapter.RequireMFA()
+}
+
 // getEnvString 从环境变量读取字符串，如果不存在则返回默认值
 func getEnvString(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
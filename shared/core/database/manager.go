@@ -3,6 +3,8 @@ package database
 import (
 	"context"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -45,6 +47,10 @@ type Manager struct {
 	Neo4j      *Neo4jManager
 	MongoDB    *MongoDBManager
 	config     Config
+	sagaStop   chan struct{}
+
+	sagaMu         sync.Mutex
+	sagaTableReady bool
 }
 
 // NewManager 创建统一数据库管理器
@@ -141,6 +147,11 @@ func (dm *Manager) GetMongoDB() *MongoDBManager {
 func (dm *Manager) Close() error {
 	var errors []error
 
+	if dm.sagaStop != nil {
+		close(dm.sagaStop)
+		dm.sagaStop = nil
+	}
+
 	if dm.MySQL != nil {
 		if err := dm.MySQL.Close(); err != nil {
 			errors = append(errors, fmt.Errorf("关闭MySQL失败: %w", err))
@@ -250,91 +261,110 @@ func (dm *Manager) Transaction(fn func(*gorm.DB) error) error {
 	return fmt.Errorf("未检测到可用的关系型数据库配置")
 }
 
-// MultiDBTransaction 多数据库事务
-type MultiDBTransaction struct {
-	MySQL      *gorm.DB
-	Redis      *RedisManager
-	PostgreSQL *gorm.DB
-	Neo4j      *Neo4jManager
+// MultiDBTransaction 执行多数据库事务（Saga），见 MultiDBTransactionContext
+func (dm *Manager) MultiDBTransaction(fn func(*MultiDBTransaction) error) error {
+	return dm.MultiDBTransactionContext(context.Background(), fn)
 }
 
-// Transaction 执行多数据库事务
-func (dm *Manager) MultiDBTransaction(fn func(*MultiDBTransaction) error) error {
-	tx := &MultiDBTransaction{}
+// MultiDBTransactionContext 执行多数据库事务（Saga）：
+// fn 中的SQL操作在本地事务中执行，Neo4j 写入立即执行并登记补偿，Redis 写入暂存；
+// fn 成功后依次提交MySQL、PostgreSQL，再执行Redis写入，任一步骤失败时按相反顺序补偿已完成的步骤
+func (dm *Manager) MultiDBTransactionContext(ctx context.Context, fn func(*MultiDBTransaction) error) error {
+	s, err := dm.beginSaga(ctx)
+	if err != nil {
+		return err
+	}
+	tx := &MultiDBTransaction{ctx: ctx, manager: dm, saga: s}
 
 	// 准备PostgreSQL事务（优先级更高）
-	var pgTx *gorm.DB
 	if dm.PostgreSQL != nil {
-		pgTx = dm.PostgreSQL.GetDB().Begin()
+		pgTx := dm.PostgreSQL.GetDB().WithContext(ctx).Begin()
 		if pgTx.Error != nil {
+			s.compensate(ctx, pgTx.Error, false)
 			return fmt.Errorf("开始PostgreSQL事务失败: %w", pgTx.Error)
 		}
 		tx.PostgreSQL = pgTx
 	}
 
 	// 准备MySQL事务（可选）
-	var mysqlTx *gorm.DB
 	if dm.MySQL != nil {
-		mysqlTx = dm.MySQL.GetDB().Begin()
+		mysqlTx := dm.MySQL.GetDB().WithContext(ctx).Begin()
 		if mysqlTx.Error != nil {
-			if pgTx != nil {
-				pgTx.Rollback()
-			}
+			tx.rollbackSQL()
+			s.compensate(ctx, mysqlTx.Error, false)
 			return fmt.Errorf("开始MySQL事务失败: %w", mysqlTx.Error)
 		}
 		tx.MySQL = mysqlTx
 	}
 
-	// 处理 panic，确保事务回滚
+	// 处理 panic，确保事务回滚并补偿已执行的步骤
 	defer func() {
 		if r := recover(); r != nil {
-			if mysqlTx != nil {
-				mysqlTx.Rollback()
-			}
-			if pgTx != nil {
-				pgTx.Rollback()
-			}
+			tx.rollbackSQL()
+			s.compensate(ctx, fmt.Errorf("panic: %v", r), false)
 			panic(r)
 		}
 	}()
 
-	// 准备其他数据库连接
-	if dm.Redis != nil {
-		tx.Redis = dm.Redis
-	}
-	if dm.Neo4j != nil {
-		tx.Neo4j = dm.Neo4j
+	// fail 回滚未提交的事务并补偿已完成的步骤
+	fail := func(cause error) error {
+		tx.rollbackSQL()
+		if compensateErr := s.compensate(ctx, cause, false); compensateErr != nil {
+			return fmt.Errorf("%w（%v）", cause, compensateErr)
+		}
+		return cause
 	}
 
 	// 执行事务函数
 	if err := fn(tx); err != nil {
-		if mysqlTx != nil {
-			mysqlTx.Rollback()
-		}
-		if pgTx != nil {
-			pgTx.Rollback()
-		}
-		return err
+		return fail(err)
 	}
 
-	if mysqlTx != nil {
-		if err := mysqlTx.Commit().Error; err != nil {
-			if pgTx != nil {
-				pgTx.Rollback()
-			}
-			return fmt.Errorf("提交MySQL事务失败: %w", err)
+	// 提交前一次性登记全部提交步骤，登记失败时不提交任何数据
+	commitSteps, err := tx.beginCommit()
+	if err != nil {
+		return fail(err)
+	}
+
+	if tx.MySQL != nil {
+		mysqlTx := tx.MySQL
+		tx.MySQL = nil
+		if err := tx.commitSQL(commitSteps.mysql, mysqlTx); err != nil {
+			return fail(fmt.Errorf("提交MySQL事务失败: %w", err))
 		}
 	}
 
-	if pgTx != nil {
-		if err := pgTx.Commit().Error; err != nil {
-			return fmt.Errorf("提交PostgreSQL事务失败: %w", err)
+	if tx.PostgreSQL != nil {
+		pgTx := tx.PostgreSQL
+		tx.PostgreSQL = nil
+		if err := tx.commitSQL(commitSteps.postgresql, pgTx); err != nil {
+			return fail(fmt.Errorf("提交PostgreSQL事务失败: %w", err))
 		}
 	}
 
+	if err := tx.execRedis(commitSteps.redis); err != nil {
+		return fail(fmt.Errorf("执行Redis写入失败: %w", err))
+	}
+
+	// 数据已全部生效，不能再补偿；状态写入失败时由 RecoverSagas 按“提交中且步骤全部完成”补记
+	if err := s.commit(ctx); err != nil {
+		log.Printf("WARN: %v", err)
+	}
 	return nil
 }
 
+// rollbackSQL 回滚尚未提交的SQL事务
+func (tx *MultiDBTransaction) rollbackSQL() {
+	if tx.MySQL != nil {
+		tx.MySQL.Rollback()
+		tx.MySQL = nil
+	}
+	if tx.PostgreSQL != nil {
+		tx.PostgreSQL.Rollback()
+		tx.PostgreSQL = nil
+	}
+}
+
 // Health 健康检查
 func (dm *Manager) Health() map[string]interface{} {
 	health := map[string]interface{}{
@@ -103,6 +103,17 @@ func (nm *Neo4jManager) ExecuteWrite(ctx context.Context, query string, paramete
 	return err
 }
 
+// WriteTransaction 在显式写事务中执行，失败时整体回滚并按驱动策略重试
+func (nm *Neo4jManager) WriteTransaction(ctx context.Context, work neo4j.ManagedTransactionWork) (any, error) {
+	session := nm.driver.NewSession(ctx, neo4j.SessionConfig{
+		DatabaseName: nm.config.Database,
+		AccessMode:   neo4j.AccessModeWrite,
+	})
+	defer session.Close(ctx)
+
+	return session.ExecuteWrite(ctx, work)
+}
+
 // CreateNode 创建节点
 func (nm *Neo4jManager) CreateNode(ctx context.Context, labels []string, properties map[string]interface{}) (string, error) {
 	query := "CREATE (n"
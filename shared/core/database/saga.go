@@ -0,0 +1,644 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"gorm.io/gorm"
+)
+
+// Saga 状态
+const (
+	SagaStatusRunning      = "running"
+	SagaStatusCommitting   = "committing" // 提交阶段的步骤已全部登记，全部完成即等同于已提交
+	SagaStatusCommitted    = "committed"
+	SagaStatusCompensating = "compensating"
+	SagaStatusCompensated  = "compensated"
+	SagaStatusFailed       = "failed" // 补偿失败，需要人工处理
+)
+
+// Saga 步骤状态
+const (
+	SagaStepPending     = "pending" // 已登记、尚未确认完成；崩溃恢复时视为可能已生效
+	SagaStepDone        = "done"
+	SagaStepFailed      = "failed"
+	SagaStepCompensated = "compensated"
+)
+
+// 内置补偿处理器
+const (
+	SagaCompensateSQL      = "sql.exec"
+	SagaCompensateCypher   = "neo4j.write"
+	SagaCompensateRedisDel = "redis.del"
+	SagaCompensateRedisSet = "redis.set"
+)
+
+// SagaLog 持久化的 Saga 日志，用于进程崩溃后的补偿恢复
+type SagaLog struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:64"`
+	Status    string    `json:"status" gorm:"size:20;index"`
+	Steps     string    `json:"steps" gorm:"type:text"`
+	Error     string    `json:"error" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (SagaLog) TableName() string {
+	return "saga_logs"
+}
+
+// SagaCompensation 补偿动作：按名称查找处理器，参数以JSON持久化，恢复时可在新进程中执行。
+// 补偿动作可能在崩溃恢复时重复执行，必须是幂等的
+type SagaCompensation struct {
+	Handler string      `json:"handler"`
+	Payload interface{} `json:"payload"`
+}
+
+// SagaStep Saga 中的一个步骤及其补偿动作
+type SagaStep struct {
+	Name          string             `json:"name"`
+	Target        string             `json:"target"`
+	Status        string             `json:"status"`
+	Compensations []SagaCompensation `json:"compensations,omitempty"`
+	Error         string             `json:"error,omitempty"`
+}
+
+// SagaCompensationHandler 补偿处理器
+type SagaCompensationHandler func(ctx context.Context, dm *Manager, payload json.RawMessage) error
+
+var (
+	sagaHandlersMu sync.RWMutex
+	sagaHandlers   = map[string]SagaCompensationHandler{
+		SagaCompensateSQL:      compensateSQL,
+		SagaCompensateCypher:   compensateCypher,
+		SagaCompensateRedisDel: compensateRedisDel,
+		SagaCompensateRedisSet: compensateRedisSet,
+	}
+)
+
+// RegisterSagaCompensation 注册自定义补偿处理器；需在启动时注册，崩溃恢复才能找到对应处理器
+func RegisterSagaCompensation(name string, handler SagaCompensationHandler) {
+	sagaHandlersMu.Lock()
+	defer sagaHandlersMu.Unlock()
+	sagaHandlers[name] = handler
+}
+
+func sagaHandler(name string) (SagaCompensationHandler, bool) {
+	sagaHandlersMu.RLock()
+	defer sagaHandlersMu.RUnlock()
+	handler, ok := sagaHandlers[name]
+	return handler, ok
+}
+
+// CompensateSQL 执行SQL语句的补偿，db 为 "mysql" 或 "postgresql"
+func CompensateSQL(db, query string, args ...interface{}) SagaCompensation {
+	return SagaCompensation{Handler: SagaCompensateSQL, Payload: sqlCompensationPayload{DB: db, Query: query, Args: args}}
+}
+
+// CompensateCypher 在Neo4j写事务中执行Cypher的补偿
+func CompensateCypher(query string, params map[string]interface{}) SagaCompensation {
+	return SagaCompensation{Handler: SagaCompensateCypher, Payload: cypherCompensationPayload{Query: query, Params: params}}
+}
+
+// CompensateRedisDel 删除Redis键的补偿
+func CompensateRedisDel(keys ...string) SagaCompensation {
+	return SagaCompensation{Handler: SagaCompensateRedisDel, Payload: keys}
+}
+
+// CompensateRedisSet 恢复Redis键原值的补偿，ttl 为 0 表示不过期
+func CompensateRedisSet(key, value string, ttl time.Duration) SagaCompensation {
+	return SagaCompensation{Handler: SagaCompensateRedisSet, Payload: redisSetPayload{Key: key, Value: value, TTL: ttl}}
+}
+
+type sqlCompensationPayload struct {
+	DB    string        `json:"db"`
+	Query string        `json:"query"`
+	Args  []interface{} `json:"args"`
+}
+
+type cypherCompensationPayload struct {
+	Query  string                 `json:"query"`
+	Params map[string]interface{} `json:"params"`
+}
+
+type redisSetPayload struct {
+	Key   string        `json:"key"`
+	Value string        `json:"value"`
+	TTL   time.Duration `json:"ttl"`
+}
+
+func compensateSQL(ctx context.Context, dm *Manager, payload json.RawMessage) error {
+	var p sqlCompensationPayload
+	if err := decodeSagaPayload(payload, &p); err != nil {
+		return err
+	}
+
+	var db *gorm.DB
+	switch p.DB {
+	case "mysql":
+		if dm.MySQL != nil {
+			db = dm.MySQL.GetDB()
+		}
+	case "postgresql":
+		if dm.PostgreSQL != nil {
+			db = dm.PostgreSQL.GetDB()
+		}
+	}
+	if db == nil {
+		return fmt.Errorf("数据库 %s 未配置", p.DB)
+	}
+	return db.WithContext(ctx).Exec(p.Query, normalizeSagaArgs(p.Args)...).Error
+}
+
+func compensateCypher(ctx context.Context, dm *Manager, payload json.RawMessage) error {
+	var p cypherCompensationPayload
+	if err := decodeSagaPayload(payload, &p); err != nil {
+		return err
+	}
+	if dm.Neo4j == nil {
+		return fmt.Errorf("Neo4j未配置")
+	}
+	for key, value := range p.Params {
+		p.Params[key] = normalizeSagaValue(value)
+	}
+	_, err := dm.Neo4j.WriteTransaction(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		result, err := tx.Run(ctx, p.Query, p.Params)
+		if err != nil {
+			return nil, err
+		}
+		return result.Consume(ctx)
+	})
+	return err
+}
+
+func compensateRedisDel(ctx context.Context, dm *Manager, payload json.RawMessage) error {
+	var keys []string
+	if err := decodeSagaPayload(payload, &keys); err != nil {
+		return err
+	}
+	if dm.Redis == nil {
+		return fmt.Errorf("Redis未配置")
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return dm.Redis.Del(ctx, keys...)
+}
+
+func compensateRedisSet(ctx context.Context, dm *Manager, payload json.RawMessage) error {
+	var p redisSetPayload
+	if err := decodeSagaPayload(payload, &p); err != nil {
+		return err
+	}
+	if dm.Redis == nil {
+		return fmt.Errorf("Redis未配置")
+	}
+	return dm.Redis.Set(ctx, p.Key, p.Value, p.TTL)
+}
+
+// decodeSagaPayload 解析补偿参数，数字保留为 json.Number 以免整数被转换为浮点数
+func decodeSagaPayload(payload json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("解析补偿参数失败: %w", err)
+	}
+	return nil
+}
+
+func normalizeSagaArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		args[i] = normalizeSagaValue(arg)
+	}
+	return args
+}
+
+// normalizeSagaValue 将 json.Number 还原为 int64 或 float64
+func normalizeSagaValue(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return number.String()
+}
+
+// sagaCommitAttempts 提交状态写入的重试次数
+const sagaCommitAttempts = 3
+
+// saga 一次多数据库操作的 Saga 执行状态
+type saga struct {
+	id      string
+	manager *Manager
+	store   *gorm.DB // 为 nil 时不持久化（未配置关系型数据库）
+	status  string   // 登记和完成步骤时写入的状态（执行中或提交中）
+	steps   []*SagaStep
+}
+
+// beginSaga 创建并持久化 Saga 日志；日志写入失败时不执行任何操作
+func (dm *Manager) beginSaga(ctx context.Context) (*saga, error) {
+	id, err := newSagaID()
+	if err != nil {
+		return nil, err
+	}
+	s := &saga{id: id, manager: dm, store: dm.sagaStore(), status: SagaStatusRunning}
+	if s.store == nil {
+		return s, nil
+	}
+
+	if err := dm.ensureSagaTable(); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.WithContext(ctx).Create(&SagaLog{ID: id, Status: SagaStatusRunning, Steps: "[]"}).Error; err != nil {
+		return nil, fmt.Errorf("写入Saga日志失败: %w", err)
+	}
+	return s, nil
+}
+
+// ensureSagaTable 首次使用时创建 Saga 日志表，失败后下次调用会重试
+func (dm *Manager) ensureSagaTable() error {
+	dm.sagaMu.Lock()
+	defer dm.sagaMu.Unlock()
+	if dm.sagaTableReady {
+		return nil
+	}
+	if err := dm.sagaStore().AutoMigrate(&SagaLog{}); err != nil {
+		return fmt.Errorf("创建Saga日志表失败: %w", err)
+	}
+	dm.sagaTableReady = true
+	return nil
+}
+
+// sagaStore Saga 日志与业务数据存放在同一个主关系型数据库中
+func (dm *Manager) sagaStore() *gorm.DB {
+	if dm.PostgreSQL != nil {
+		return dm.PostgreSQL.GetDB()
+	}
+	if dm.MySQL != nil {
+		return dm.MySQL.GetDB()
+	}
+	return nil
+}
+
+// addStep 登记步骤；必须在执行步骤之前持久化，崩溃后才能知道需要补偿什么
+func (s *saga) addStep(ctx context.Context, name, target string, compensations []SagaCompensation) (*SagaStep, error) {
+	step := &SagaStep{Name: name, Target: target, Status: SagaStepPending, Compensations: compensations}
+	s.steps = append(s.steps, step)
+	if err := s.persist(ctx, s.status, ""); err != nil {
+		step.Status = SagaStepFailed
+		step.Error = err.Error()
+		return nil, err
+	}
+	return step, nil
+}
+
+// beginCommit 一次性登记提交阶段的全部步骤并将 Saga 置为提交中。
+// 之后不会再有新步骤，崩溃恢复时全部步骤完成即可确认已提交，不需要补偿
+func (s *saga) beginCommit(ctx context.Context, steps ...*SagaStep) error {
+	for _, step := range steps {
+		step.Status = SagaStepPending
+	}
+	s.steps = append(s.steps, steps...)
+	if err := s.persist(ctx, SagaStatusCommitting, ""); err != nil {
+		for _, step := range steps {
+			step.Status = SagaStepFailed
+			step.Error = err.Error()
+		}
+		return fmt.Errorf("登记Saga提交步骤失败: %w", err)
+	}
+	s.status = SagaStatusCommitting
+	return nil
+}
+
+// finishStep 记录步骤结果；此时操作已经发生，日志写入失败只记录告警
+func (s *saga) finishStep(ctx context.Context, step *SagaStep, err error) {
+	if err != nil {
+		step.Status = SagaStepFailed
+		step.Error = err.Error()
+	} else {
+		step.Status = SagaStepDone
+	}
+	if persistErr := s.persist(ctx, s.status, ""); persistErr != nil {
+		log.Printf("WARN: 更新Saga %s 步骤 %s 状态失败: %v", s.id, step.Name, persistErr)
+	}
+}
+
+func (s *saga) persist(ctx context.Context, status, errMessage string) error {
+	if s.store == nil {
+		return nil
+	}
+	steps, err := json.Marshal(s.steps)
+	if err != nil {
+		return fmt.Errorf("序列化Saga步骤失败: %w", err)
+	}
+	return s.store.WithContext(ctx).Model(&SagaLog{}).Where("id = ?", s.id).Updates(map[string]interface{}{
+		"status":     status,
+		"steps":      string(steps),
+		"error":      errMessage,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// commit 所有步骤完成，写入已提交状态（失败时重试）
+func (s *saga) commit(ctx context.Context) error {
+	var err error
+	for attempt := 1; attempt <= sagaCommitAttempts; attempt++ {
+		if err = s.persist(ctx, SagaStatusCommitted, ""); err == nil {
+			return nil
+		}
+		if attempt < sagaCommitAttempts {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("更新Saga %s 为已提交失败: %w", s.id, err)
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		}
+	}
+	return fmt.Errorf("更新Saga %s 为已提交失败: %w", s.id, err)
+}
+
+// allStepsDone 是否所有步骤都已完成
+func (s *saga) allStepsDone() bool {
+	for _, step := range s.steps {
+		if step.Status != SagaStepDone {
+			return false
+		}
+	}
+	return true
+}
+
+// compensate 按相反顺序执行已完成步骤的补偿；includePending 用于崩溃恢复，此时无法确认待定步骤是否已生效
+func (s *saga) compensate(ctx context.Context, cause error, includePending bool) error {
+	causeMessage := ""
+	if cause != nil {
+		causeMessage = cause.Error()
+	}
+	if err := s.persist(ctx, SagaStatusCompensating, causeMessage); err != nil {
+		log.Printf("WARN: 更新Saga %s 为补偿中失败: %v", s.id, err)
+	}
+
+	var failed []string
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if step.Status != SagaStepDone && !(includePending && step.Status == SagaStepPending) {
+			continue
+		}
+
+		stepFailed := false
+		for j := len(step.Compensations) - 1; j >= 0; j-- {
+			if err := s.runCompensation(ctx, step.Compensations[j]); err != nil {
+				stepFailed = true
+				step.Error = err.Error()
+				failed = append(failed, fmt.Sprintf("%s: %v", step.Name, err))
+				log.Printf("WARN: Saga %s 步骤 %s 补偿失败: %v", s.id, step.Name, err)
+			}
+		}
+		if stepFailed {
+			step.Status = SagaStepFailed
+		} else {
+			step.Status = SagaStepCompensated
+		}
+	}
+
+	status := SagaStatusCompensated
+	var compensateErr error
+	if len(failed) > 0 {
+		status = SagaStatusFailed
+		compensateErr = fmt.Errorf("补偿失败: %v", failed)
+		causeMessage = fmt.Sprintf("%s; %v", causeMessage, compensateErr)
+	}
+	if err := s.persist(ctx, status, causeMessage); err != nil {
+		log.Printf("WARN: 更新Saga %s 补偿结果失败: %v", s.id, err)
+	}
+	return compensateErr
+}
+
+func (s *saga) runCompensation(ctx context.Context, compensation SagaCompensation) error {
+	handler, ok := sagaHandler(compensation.Handler)
+	if !ok {
+		return fmt.Errorf("未注册的补偿处理器: %s", compensation.Handler)
+	}
+	payload, err := json.Marshal(compensation.Payload)
+	if err != nil {
+		return fmt.Errorf("序列化补偿参数失败: %w", err)
+	}
+	return handler(ctx, s.manager, payload)
+}
+
+// RecoverSagas 补偿崩溃时仍在执行、提交中或补偿中的 Saga；提交中且全部步骤已完成的 Saga 只补记为已提交。
+// staleAfter 应大于最长的 Saga 执行时间，避免补偿其他实例正在执行的 Saga
+func (dm *Manager) RecoverSagas(ctx context.Context, staleAfter time.Duration) (int, error) {
+	store := dm.sagaStore()
+	if store == nil {
+		return 0, nil
+	}
+	if err := dm.ensureSagaTable(); err != nil {
+		return 0, err
+	}
+
+	var logs []SagaLog
+	err := store.WithContext(ctx).
+		Where("status IN ? AND updated_at < ?", []string{SagaStatusRunning, SagaStatusCommitting, SagaStatusCompensating}, time.Now().Add(-staleAfter)).
+		Order("created_at ASC").Limit(100).Find(&logs).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待恢复Saga失败: %w", err)
+	}
+
+	recovered := 0
+	for _, sagaLog := range logs {
+		var steps []*SagaStep
+		if err := decodeSagaPayload(json.RawMessage(sagaLog.Steps), &steps); err != nil {
+			log.Printf("WARN: 解析Saga %s 步骤失败: %v", sagaLog.ID, err)
+			continue
+		}
+
+		s := &saga{id: sagaLog.ID, manager: dm, store: store, status: sagaLog.Status, steps: steps}
+
+		// 提交阶段的步骤全部完成说明事务已生效，只是已提交状态没有写入
+		if sagaLog.Status == SagaStatusCommitting && s.allStepsDone() {
+			if err := s.commit(ctx); err != nil {
+				log.Printf("WARN: 恢复Saga %s 的已提交状态失败: %v", sagaLog.ID, err)
+				continue
+			}
+			recovered++
+			continue
+		}
+
+		cause := fmt.Errorf("进程中断后恢复")
+		if sagaLog.Error != "" {
+			cause = fmt.Errorf("%s（进程中断后恢复）", sagaLog.Error)
+		}
+		if err := s.compensate(ctx, cause, true); err != nil {
+			log.Printf("WARN: 恢复Saga %s 失败，需要人工处理: %v", sagaLog.ID, err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// StartSagaRecovery 定期恢复中断的 Saga，Close 时停止
+func (dm *Manager) StartSagaRecovery(interval, staleAfter time.Duration) {
+	if dm.sagaStore() == nil {
+		return
+	}
+	dm.sagaStop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-dm.sagaStop:
+				return
+			case <-ticker.C:
+				recovered, err := dm.RecoverSagas(context.Background(), staleAfter)
+				if err != nil {
+					log.Printf("WARN: Saga恢复失败: %v", err)
+				} else if recovered > 0 {
+					log.Printf("INFO: 已补偿 %d 个中断的Saga", recovered)
+				}
+			}
+		}
+	}()
+}
+
+// MultiDBTransaction 多数据库事务（Saga）：
+// MySQL/PostgreSQL 在本地事务中执行，Neo4j 在显式写事务中立即执行，Redis 写入暂存到SQL提交后以 MULTI/EXEC 执行；
+// 任一步骤失败时按相反顺序执行已完成步骤的补偿动作
+type MultiDBTransaction struct {
+	MySQL      *gorm.DB
+	PostgreSQL *gorm.DB
+
+	ctx           context.Context
+	manager       *Manager
+	saga          *saga
+	mysqlComp     []SagaCompensation
+	postgresComp  []SagaCompensation
+	redisWrites   []func(redis.Pipeliner) error
+	redisComp     []SagaCompensation
+	redisStepName []string
+}
+
+// Context 事务上下文
+func (tx *MultiDBTransaction) Context() context.Context {
+	return tx.ctx
+}
+
+// CompensateMySQL 登记MySQL提交后的补偿动作，在后续步骤失败时执行
+func (tx *MultiDBTransaction) CompensateMySQL(compensations ...SagaCompensation) {
+	tx.mysqlComp = append(tx.mysqlComp, compensations...)
+}
+
+// CompensatePostgreSQL 登记PostgreSQL提交后的补偿动作，在后续步骤失败时执行
+func (tx *MultiDBTransaction) CompensatePostgreSQL(compensations ...SagaCompensation) {
+	tx.postgresComp = append(tx.postgresComp, compensations...)
+}
+
+// Neo4jWrite 在Neo4j显式写事务中立即执行，compensations 在后续步骤失败时撤销本次写入
+func (tx *MultiDBTransaction) Neo4jWrite(name string, work neo4j.ManagedTransactionWork, compensations ...SagaCompensation) (any, error) {
+	if tx.manager.Neo4j == nil {
+		return nil, fmt.Errorf("Neo4j未配置")
+	}
+
+	step, err := tx.saga.addStep(tx.ctx, name, "neo4j", compensations)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.manager.Neo4j.WriteTransaction(tx.ctx, work)
+	tx.saga.finishStep(tx.ctx, step, err)
+	if err != nil {
+		return nil, fmt.Errorf("Neo4j步骤 %s 失败: %w", name, err)
+	}
+	return result, nil
+}
+
+// StageRedis 暂存Redis写入，在所有SQL事务提交后以 MULTI/EXEC 原子执行
+func (tx *MultiDBTransaction) StageRedis(name string, write func(pipe redis.Pipeliner) error, compensations ...SagaCompensation) error {
+	if tx.manager.Redis == nil {
+		return fmt.Errorf("Redis未配置")
+	}
+	tx.redisWrites = append(tx.redisWrites, write)
+	tx.redisComp = append(tx.redisComp, compensations...)
+	tx.redisStepName = append(tx.redisStepName, name)
+	return nil
+}
+
+// sagaCommitSteps 提交阶段的步骤，未参与的数据库为 nil
+type sagaCommitSteps struct {
+	mysql      *SagaStep
+	postgresql *SagaStep
+	redis      *SagaStep
+}
+
+// beginCommit 登记提交阶段的全部步骤（MySQL、PostgreSQL提交与Redis写入）
+func (tx *MultiDBTransaction) beginCommit() (*sagaCommitSteps, error) {
+	steps := &sagaCommitSteps{}
+	var pending []*SagaStep
+	if tx.MySQL != nil {
+		steps.mysql = &SagaStep{Name: "mysql:commit", Target: "mysql", Compensations: tx.mysqlComp}
+		pending = append(pending, steps.mysql)
+	}
+	if tx.PostgreSQL != nil {
+		steps.postgresql = &SagaStep{Name: "postgresql:commit", Target: "postgresql", Compensations: tx.postgresComp}
+		pending = append(pending, steps.postgresql)
+	}
+	if len(tx.redisWrites) > 0 {
+		steps.redis = &SagaStep{Name: fmt.Sprintf("redis:%v", tx.redisStepName), Target: "redis", Compensations: tx.redisComp}
+		pending = append(pending, steps.redis)
+	}
+	if err := tx.saga.beginCommit(tx.ctx, pending...); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// commitSQL 提交单个SQL事务并记录 Saga 步骤结果
+func (tx *MultiDBTransaction) commitSQL(step *SagaStep, db *gorm.DB) error {
+	err := db.Commit().Error
+	tx.saga.finishStep(tx.ctx, step, err)
+	return err
+}
+
+// execRedis 以 MULTI/EXEC 执行暂存的Redis写入
+func (tx *MultiDBTransaction) execRedis(step *SagaStep) error {
+	if step == nil {
+		return nil
+	}
+
+	_, err := tx.manager.Redis.GetClient().TxPipelined(tx.ctx, func(pipe redis.Pipeliner) error {
+		for _, write := range tx.redisWrites {
+			if err := write(pipe); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	tx.saga.finishStep(tx.ctx, step, err)
+	return err
+}
+
+func newSagaID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成Saga ID失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -65,6 +65,7 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("amr", claims.AMR)
 
 		log.Printf("DEBUG: 认证中间件 - 用户信息已设置到上下文，继续处理请求")
 		c.Next()
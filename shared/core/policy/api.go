@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/response"
+)
+
+// adminRoles 可以管理访问策略、以任意主体试运行的角色
+var adminRoles = map[string]bool{"admin": true, "super_admin": true}
+
+// ExplainRequest 试运行请求：按资源类型/ID解析属性，subject/resource/context中的属性覆盖解析结果
+type ExplainRequest struct {
+	ResourceType string     `json:"resource_type" binding:"required"`
+	ResourceID   string     `json:"resource_id"`
+	Action       string     `json:"action" binding:"required"`
+	Subject      Attributes `json:"subject"`
+	Resource     Attributes `json:"resource"`
+	Context      Attributes `json:"context"`
+}
+
+// RegisterRoutes 注册策略管理与试运行接口（group需已使用认证中间件）
+//
+//	GET    /policies          规则列表（管理员）
+//	POST   /policies          新建规则（管理员）
+//	PUT    /policies/:id      更新规则（管理员）
+//	DELETE /policies/:id      删除规则（管理员）
+//	POST   /policies/explain  试运行，返回决策及每条规则的评估过程
+func (m *Middleware) RegisterRoutes(group *gin.RouterGroup) {
+	policies := group.Group("/policies")
+	{
+		policies.GET("", m.requireAdmin, m.handleList)
+		policies.POST("", m.requireAdmin, m.handleCreate)
+		policies.PUT("/:id", m.requireAdmin, m.handleUpdate)
+		policies.DELETE("/:id", m.requireAdmin, m.handleDelete)
+		policies.POST("/explain", m.handleExplain)
+	}
+}
+
+func (m *Middleware) requireAdmin(c *gin.Context) {
+	if !adminRoles[c.GetString("role")] {
+		c.JSON(http.StatusOK, response.Error(response.CodeForbidden, "需要管理员权限"))
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (m *Middleware) handleList(c *gin.Context) {
+	policies, err := m.engine.Store().List()
+	if err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success("获取访问策略成功", policies))
+}
+
+func (m *Middleware) handleCreate(c *gin.Context) {
+	var p Policy
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInvalidParams, "请求参数错误: "+err.Error()))
+		return
+	}
+	p.ID = 0
+	m.savePolicy(c, &p, "访问策略已创建")
+}
+
+func (m *Middleware) handleUpdate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInvalidParams, "策略ID格式错误"))
+		return
+	}
+
+	var p Policy
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInvalidParams, "请求参数错误: "+err.Error()))
+		return
+	}
+	p.ID = uint(id)
+	m.savePolicy(c, &p, "访问策略已更新")
+}
+
+func (m *Middleware) handleDelete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInvalidParams, "策略ID格式错误"))
+		return
+	}
+
+	if err := m.engine.Store().Delete(uint(id)); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	m.reload(c, "访问策略已删除", nil)
+}
+
+func (m *Middleware) savePolicy(c *gin.Context, p *Policy, message string) {
+	if _, err := p.Validate(); err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInvalidParams, err.Error()))
+		return
+	}
+	if err := m.engine.Store().Save(p); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	m.reload(c, message, p)
+}
+
+// reload 修改规则后立即重新加载，本实例马上生效
+func (m *Middleware) reload(c *gin.Context, message string, data interface{}) {
+	if err := m.engine.Reload(); err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Success(message, data))
+}
+
+// handleExplain 试运行：普通用户只能以自身身份试运行，管理员可以覆盖主体属性
+func (m *Middleware) handleExplain(c *gin.Context) {
+	var req ExplainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, response.Error(response.CodeInvalidParams, "请求参数错误: "+err.Error()))
+		return
+	}
+	if len(req.Subject) > 0 && !adminRoles[c.GetString("role")] {
+		c.JSON(http.StatusOK, response.Error(response.CodeForbidden, "只有管理员可以指定主体属性"))
+		return
+	}
+
+	evalReq, err := m.BuildRequest(c, req.ResourceType, req.ResourceID, req.Action)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	for key, value := range req.Subject {
+		evalReq.Subject[key] = value
+	}
+	for key, value := range req.Resource {
+		evalReq.Resource[key] = value
+	}
+	for key, value := range req.Context {
+		evalReq.Context[key] = value
+	}
+
+	decision := m.engine.Evaluate(evalReq)
+	c.JSON(http.StatusOK, response.Success(decision.Reason, gin.H{
+		"decision": decision,
+		"request":  evalReq,
+	}))
+}
+
+func writeStoreError(c *gin.Context, err error) {
+	if errors.Is(err, ErrPolicyNotFound) {
+		c.JSON(http.StatusOK, response.Error(response.CodeNotFound, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+}
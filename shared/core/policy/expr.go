@@ -0,0 +1,699 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 条件表达式语法（类CEL）：
+//
+//	subject.role == "admin" && resource.owner_id == subject.user_id
+//	action in ["read", "view_authorized_users"]
+//	subject.company_role != "authorized_user" || startsWith(action, "view_")
+//	!intersects(subject.company_ids, resource.blacklisted_companies)
+//	context.hour >= 9 && cidr(context.ip, "10.0.0.0/8")
+//
+// 运算符：|| && ! == != < <= > >= in，字面量：字符串、数字、true/false/null、列表。
+// 变量：subject.* resource.* context.* 以及 action，不存在的属性为null；
+// 只有与null字面量的 ==、!= 可以判断属性是否存在，其余比较遇到缺失属性时求值失败。
+// 函数：startsWith endsWith contains lower size intersects cidr has
+
+var ErrInvalidCondition = errors.New("策略条件表达式错误")
+
+// Attributes 表达式求值时的变量
+type Attributes map[string]interface{}
+
+// Expression 编译后的条件表达式
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile 编译条件表达式，空表达式恒为真
+func Compile(source string) (*Expression, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return &Expression{source: source, root: literalNode{value: true}}, nil
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCondition, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCondition, err)
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("%w: 位置 %d 处存在多余的内容 %q", ErrInvalidCondition, p.peek().pos, p.peek().text)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// String 返回表达式源码
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval 对属性求值，结果必须为布尔值
+func (e *Expression) Eval(vars Attributes) (bool, error) {
+	value, err := e.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("条件结果不是布尔值: %v", value)
+	}
+	return result, nil
+}
+
+// ==================== 词法分析 ====================
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			quote := r
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != quote; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("位置 %d 处的字符串未闭合", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("位置 %d 处存在非法字符 %q", i, r)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// ==================== 语法分析 ====================
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) done() bool {
+	return p.peek().kind == tokenEOF
+}
+
+func (p *parser) acceptOperator(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOperator(op string) error {
+	if !p.acceptOperator(op) {
+		t := p.peek()
+		return fmt.Errorf("位置 %d 处需要 %q，实际为 %q", t.pos, op, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOperator("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOperator("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.acceptOperator("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == tokenOperator && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+		op = t.text
+	case t.kind == tokenIdent && t.text == "in":
+		op = "in"
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return literalNode{value: t.text}, nil
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d 处的数字 %q 非法", t.pos, t.text)
+		}
+		return literalNode{value: value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		if p.acceptOperator("(") {
+			return p.parseCall(t)
+		}
+		path := []string{t.text}
+		for p.acceptOperator(".") {
+			field := p.next()
+			if field.kind != tokenIdent {
+				return nil, fmt.Errorf("位置 %d 处需要属性名", field.pos)
+			}
+			path = append(path, field.text)
+		}
+		return pathNode{path: path}, nil
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expectOperator(")")
+		case "[":
+			var items []node
+			if p.acceptOperator("]") {
+				return listNode{items: items}, nil
+			}
+			for {
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.acceptOperator("]") {
+					return listNode{items: items}, nil
+				}
+				if err := p.expectOperator(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if t.kind == tokenEOF {
+		return nil, errors.New("表达式不完整")
+	}
+	return nil, fmt.Errorf("位置 %d 处存在意外的 %q", t.pos, t.text)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("位置 %d 处的函数 %s 不存在", name.pos, name.text)
+	}
+
+	var args []node
+	if !p.acceptOperator(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.acceptOperator(")") {
+				break
+			}
+			if err := p.expectOperator(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("函数 %s 需要 %d 个参数，实际为 %d 个", name.text, fn.arity, len(args))
+	}
+	return callNode{name: name.text, fn: fn.call, args: args}, nil
+}
+
+// ==================== 求值 ====================
+
+type node interface {
+	eval(vars Attributes) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(Attributes) (interface{}, error) { return n.value, nil }
+
+type pathNode struct{ path []string }
+
+func (n pathNode) eval(vars Attributes) (interface{}, error) {
+	var current interface{} = map[string]interface{}(vars)
+	for _, field := range n.path {
+		switch m := current.(type) {
+		case Attributes:
+			current = m[field]
+		case map[string]interface{}:
+			current = m[field]
+		default:
+			return nil, nil
+		}
+	}
+	return normalize(current), nil
+}
+
+type listNode struct{ items []node }
+
+func (n listNode) eval(vars Attributes) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(vars Attributes) (interface{}, error) {
+	value, err := evalBool(n.operand, vars)
+	if err != nil {
+		return nil, err
+	}
+	return !value, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicalNode) eval(vars Attributes) (interface{}, error) {
+	left, err := evalBool(n.left, vars)
+	if err != nil {
+		return nil, err
+	}
+	// 短路求值
+	if n.op == "||" && left {
+		return true, nil
+	}
+	if n.op == "&&" && !left {
+		return false, nil
+	}
+	return evalBool(n.right, vars)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(vars Attributes) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if left == nil || right == nil {
+		// 与null字面量比较用于判断属性是否存在；其余比较中属性缺失时求值失败
+		// （拒绝规则按命中处理），避免两个缺失属性相等或缺失属性与任意值不等而放行
+		if (n.op == "==" || n.op == "!=") && (isNullLiteral(n.left) || isNullLiteral(n.right)) {
+			return (left == nil && right == nil) == (n.op == "=="), nil
+		}
+		return nil, fmt.Errorf("运算符 %s 的操作数不存在", n.op)
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("in 的右侧必须是列表，实际为 %v", right)
+		}
+		return containsValue(list, left), nil
+	}
+
+	if lf, ok := left.(float64); ok {
+		rf, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("无法比较 %v 与 %v", left, right)
+		}
+		return compareOrdered(n.op, lf, rf), nil
+	}
+	if ls, ok := left.(string); ok {
+		rs, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("无法比较 %v 与 %v", left, right)
+		}
+		return compareOrdered(n.op, ls, rs), nil
+	}
+	return nil, fmt.Errorf("运算符 %s 不支持 %v", n.op, left)
+}
+
+type callNode struct {
+	name string
+	fn   func(args []interface{}) (interface{}, error)
+	args []node
+}
+
+func (n callNode) eval(vars Attributes) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	result, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %v", n.name, err)
+	}
+	return result, nil
+}
+
+func evalBool(n node, vars Attributes) (bool, error) {
+	value, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	if value == nil {
+		return false, nil
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("需要布尔值，实际为 %v", value)
+	}
+	return result, nil
+}
+
+func compareOrdered[T float64 | string](op string, left, right T) bool {
+	switch op {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	default:
+		return left >= right
+	}
+}
+
+// normalize 将属性值统一为 string/float64/bool/[]interface{}/map，便于比较
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list
+	case []uint:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = float64(item)
+		}
+		return list
+	case []int:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = float64(item)
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = normalize(item)
+		}
+		return list
+	case map[string]string:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[key] = item
+		}
+		return object
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[key] = normalize(item)
+		}
+		return object
+	}
+	return value
+}
+
+func isNullLiteral(n node) bool {
+	literal, ok := n.(literalNode)
+	return ok && literal.value == nil
+}
+
+// equal 比较两个值，列表与对象逐项深度比较；缺失值（null）与任何值都不相等
+func equal(left, right interface{}) bool {
+	left, right = normalize(left), normalize(right)
+	switch l := left.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, value := range l {
+			if other, exists := r[key]; !exists || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case string, float64, bool:
+		return left == right
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if equal(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== 内置函数 ====================
+
+type function struct {
+	arity int
+	call  func(args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"startsWith": {2, stringFunc(strings.HasPrefix)},
+	"endsWith":   {2, stringFunc(strings.HasSuffix)},
+	"contains": {2, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return false, nil
+		case string:
+			s, _ := args[1].(string)
+			return strings.Contains(v, s), nil
+		case []interface{}:
+			return containsValue(v, args[1]), nil
+		}
+		return nil, fmt.Errorf("不支持的参数 %v", args[0])
+	}},
+	"lower": {1, func(args []interface{}) (interface{}, error) {
+		s, _ := args[0].(string)
+		return strings.ToLower(s), nil
+	}},
+	"size": {1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("不支持的参数 %v", args[0])
+	}},
+	"intersects": {2, func(args []interface{}) (interface{}, error) {
+		left, _ := args[0].([]interface{})
+		right, _ := args[1].([]interface{})
+		for _, item := range left {
+			if containsValue(right, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}},
+	"cidr": {2, func(args []interface{}) (interface{}, error) {
+		ipText, _ := args[0].(string)
+		cidrText, _ := args[1].(string)
+		_, network, err := net.ParseCIDR(cidrText)
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(ipText)
+		return ip != nil && network.Contains(ip), nil
+	}},
+	"has": {1, func(args []interface{}) (interface{}, error) {
+		return args[0] != nil, nil
+	}},
+}
+
+func stringFunc(fn func(s, part string) bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, _ := args[0].(string)
+		part, _ := args[1].(string)
+		return fn(s, part), nil
+	}
+}
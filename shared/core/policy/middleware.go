@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/szjason72/zervigo/shared/core/response"
+)
+
+// ErrResourceNotFound 资源解析器找不到资源时返回
+var ErrResourceNotFound = errors.New("资源不存在")
+
+// ResourceResolver 按资源ID加载资源属性（owner_id、company_id、sensitivity_level等）
+type ResourceResolver func(c *gin.Context, resourceID string) (Attributes, error)
+
+// SubjectEnricher 补充主体属性（如所属企业、在该企业中的角色），在资源属性解析之后执行
+type SubjectEnricher func(c *gin.Context, subject, resource Attributes) error
+
+// Middleware gin访问控制中间件，任何服务在认证中间件之后使用
+type Middleware struct {
+	engine    *Engine
+	mu        sync.RWMutex
+	resolvers map[string]ResourceResolver
+	enrichers []SubjectEnricher
+}
+
+// NewMiddleware 创建访问控制中间件
+func NewMiddleware(engine *Engine) *Middleware {
+	return &Middleware{
+		engine:    engine,
+		resolvers: make(map[string]ResourceResolver),
+	}
+}
+
+// Engine 策略引擎
+func (m *Middleware) Engine() *Engine {
+	return m.engine
+}
+
+// RegisterResource 注册资源类型的属性解析器
+func (m *Middleware) RegisterResource(resourceType string, resolver ResourceResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolvers[resourceType] = resolver
+}
+
+// AddSubjectEnricher 添加主体属性补充函数
+func (m *Middleware) AddSubjectEnricher(enricher SubjectEnricher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enrichers = append(m.enrichers, enricher)
+}
+
+// Require 要求对路径参数idParam指定的资源拥有action权限（idParam为空表示不针对具体资源）
+func (m *Middleware) Require(resourceType, action, idParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resourceID := ""
+		if idParam != "" {
+			resourceID = c.Param(idParam)
+		}
+
+		decision, err := m.Check(c, resourceType, resourceID, action, nil)
+		if err != nil {
+			writeError(c, err)
+			c.Abort()
+			return
+		}
+		if !decision.Allowed {
+			c.JSON(http.StatusOK, response.Error(response.CodeForbidden, "权限不足："+decision.Reason))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Check 评估当前用户对资源的访问，extra中的资源属性会覆盖解析器的结果
+func (m *Middleware) Check(c *gin.Context, resourceType, resourceID, action string, extra Attributes) (*Decision, error) {
+	req, err := m.BuildRequest(c, resourceType, resourceID, action)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		req.Resource[key] = value
+	}
+
+	decision := m.engine.Evaluate(req)
+	c.Set("policy_decision", decision)
+	return decision, nil
+}
+
+// BuildRequest 由gin上下文构建访问请求
+func (m *Middleware) BuildRequest(c *gin.Context, resourceType, resourceID, action string) (Request, error) {
+	req := Request{
+		Subject:  SubjectFromContext(c),
+		Resource: Attributes{"type": resourceType, "id": resourceID},
+		Action:   action,
+		Context:  RequestContext(c),
+	}
+
+	m.mu.RLock()
+	resolver := m.resolvers[resourceType]
+	enrichers := m.enrichers
+	m.mu.RUnlock()
+
+	if resolver != nil && resourceID != "" {
+		attrs, err := resolver(c, resourceID)
+		if err != nil {
+			return req, err
+		}
+		for key, value := range attrs {
+			req.Resource[key] = value
+		}
+	}
+	for _, enrich := range enrichers {
+		if err := enrich(c, req.Subject, req.Resource); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// SubjectFromContext 由认证中间件写入的上下文构建主体属性
+func SubjectFromContext(c *gin.Context) Attributes {
+	subject := Attributes{
+		"user_id":             contextUserID(c),
+		"username":            c.GetString("username"),
+		"role":                c.GetString("role"),
+		"email":               c.GetString("email"),
+		"subscription_status": c.GetString("subscription_status"),
+		"subscription_type":   c.GetString("subscription_type"),
+	}
+	if amr, ok := c.Get("amr"); ok {
+		subject["amr"] = amr
+	}
+	return subject
+}
+
+// RequestContext 请求上下文属性：时间与来源IP
+func RequestContext(c *gin.Context) Attributes {
+	now := time.Now()
+	return Attributes{
+		"ip":         c.ClientIP(),
+		"time":       now.Format(time.RFC3339),
+		"hour":       now.Hour(),
+		"weekday":    int(now.Weekday()),
+		"method":     c.Request.Method,
+		"path":       c.Request.URL.Path,
+		"user_agent": c.GetHeader("User-Agent"),
+	}
+}
+
+// contextUserID 认证中间件可能写入int或uint类型的用户ID
+func contextUserID(c *gin.Context) uint {
+	value, ok := c.Get("user_id")
+	if !ok {
+		return 0
+	}
+	switch v := value.(type) {
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	case int64:
+		return uint(v)
+	case uint64:
+		return uint(v)
+	case float64:
+		return uint(v)
+	case string:
+		id, _ := strconv.ParseUint(v, 10, 64)
+		return uint(id)
+	}
+	return 0
+}
+
+func writeError(c *gin.Context, err error) {
+	if errors.Is(err, ErrResourceNotFound) {
+		c.JSON(http.StatusOK, response.Error(response.CodeNotFound, err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.Error(response.CodeInternalError, err.Error()))
+}
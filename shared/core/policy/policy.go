@@ -0,0 +1,305 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 规则效果
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// defaultReloadInterval 规则定期从数据库重新加载的间隔（其他实例修改规则后生效）
+const defaultReloadInterval = 30 * time.Second
+
+var ErrInvalidPolicy = errors.New("策略配置错误")
+
+// Policy 存储在数据库中的访问控制规则
+type Policy struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"size:100;uniqueIndex;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Resource    string    `json:"resource" gorm:"size:50;index;not null"` // 资源类型，如 company、resume，* 表示全部
+	Actions     string    `json:"actions" gorm:"size:500;not null"`       // 逗号分隔，支持通配，如 view_*、*
+	Effect      string    `json:"effect" gorm:"size:10;not null"`         // allow 或 deny
+	Condition   string    `json:"condition" gorm:"type:text"`             // 条件表达式，为空时恒成立
+	Priority    int       `json:"priority" gorm:"default:0"`              // 数值越大越先评估
+	Enabled     bool      `json:"enabled" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Policy) TableName() string {
+	return "zervigo_abac_policies"
+}
+
+// ActionList 规则适用的操作
+func (p *Policy) ActionList() []string {
+	var actions []string
+	for _, action := range strings.Split(p.Actions, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// Validate 校验规则并编译条件表达式
+func (p *Policy) Validate() (*Expression, error) {
+	if strings.TrimSpace(p.Name) == "" {
+		return nil, fmt.Errorf("%w: 规则名称不能为空", ErrInvalidPolicy)
+	}
+	if strings.TrimSpace(p.Resource) == "" {
+		return nil, fmt.Errorf("%w: 资源类型不能为空", ErrInvalidPolicy)
+	}
+	if p.Effect != EffectAllow && p.Effect != EffectDeny {
+		return nil, fmt.Errorf("%w: effect 只能是 allow 或 deny", ErrInvalidPolicy)
+	}
+	actions := p.ActionList()
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要一个操作", ErrInvalidPolicy)
+	}
+	for _, pattern := range append(actions, p.Resource) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: 通配模式 %q 非法", ErrInvalidPolicy, pattern)
+		}
+	}
+	return Compile(p.Condition)
+}
+
+// Request 一次访问请求的属性
+type Request struct {
+	Subject  Attributes `json:"subject"`
+	Resource Attributes `json:"resource"`
+	Action   string     `json:"action"`
+	Context  Attributes `json:"context"`
+}
+
+// ResourceType 资源类型（resource.type）
+func (r *Request) ResourceType() string {
+	value, _ := r.Resource["type"].(string)
+	return value
+}
+
+func (r *Request) vars() Attributes {
+	return Attributes{
+		"subject":  r.Subject,
+		"resource": r.Resource,
+		"action":   r.Action,
+		"context":  r.Context,
+	}
+}
+
+// RuleTrace 单条规则的评估结果
+type RuleTrace struct {
+	PolicyID  uint   `json:"policy_id"`
+	Name      string `json:"name"`
+	Effect    string `json:"effect"`
+	Priority  int    `json:"priority"`
+	Condition string `json:"condition"`
+	Matched   bool   `json:"matched"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Decision 访问决策
+type Decision struct {
+	Allowed  bool        `json:"allowed"`
+	Effect   string      `json:"effect"`
+	PolicyID uint        `json:"policy_id,omitempty"` // 决定结果的规则，默认拒绝时为0
+	Policy   string      `json:"policy,omitempty"`
+	Reason   string      `json:"reason"`
+	Trace    []RuleTrace `json:"trace"` // 所有适用规则的评估过程
+}
+
+type compiledPolicy struct {
+	policy    Policy
+	actions   []string
+	condition *Expression
+}
+
+func (cp *compiledPolicy) appliesTo(resourceType, action string) bool {
+	if ok, _ := path.Match(cp.policy.Resource, resourceType); !ok {
+		return false
+	}
+	for _, pattern := range cp.actions {
+		if ok, _ := path.Match(pattern, action); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine 基于属性的访问控制引擎：拒绝优先，无匹配的允许规则时默认拒绝
+type Engine struct {
+	mu             sync.RWMutex
+	store          Store
+	policies       []*compiledPolicy
+	loadedAt       time.Time
+	reloadInterval time.Duration
+}
+
+// NewEngine 创建策略引擎并加载规则
+func NewEngine(store Store) (*Engine, error) {
+	engine := &Engine{
+		store:          store,
+		reloadInterval: defaultReloadInterval,
+	}
+	if err := engine.Reload(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// Store 规则存储
+func (e *Engine) Store() Store {
+	return e.store
+}
+
+// Reload 从存储重新加载规则，无法编译的规则会被跳过
+func (e *Engine) Reload() error {
+	policies, err := e.store.List()
+	if err != nil {
+		return fmt.Errorf("加载访问策略失败: %w", err)
+	}
+
+	compiled := make([]*compiledPolicy, 0, len(policies))
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+		condition, err := p.Validate()
+		if err != nil {
+			log.Printf("WARN: 跳过无效的访问策略 %s(id=%d): %v", p.Name, p.ID, err)
+			continue
+		}
+		compiled = append(compiled, &compiledPolicy{policy: p, actions: p.ActionList(), condition: condition})
+	}
+	sort.SliceStable(compiled, func(i, j int) bool {
+		if compiled[i].policy.Priority != compiled[j].policy.Priority {
+			return compiled[i].policy.Priority > compiled[j].policy.Priority
+		}
+		return compiled[i].policy.ID < compiled[j].policy.ID
+	})
+
+	e.mu.Lock()
+	e.policies = compiled
+	e.loadedAt = time.Now()
+	e.mu.Unlock()
+	return nil
+}
+
+// Evaluate 评估访问请求
+func (e *Engine) Evaluate(req Request) *Decision {
+	e.reloadIfStale()
+
+	e.mu.RLock()
+	policies := e.policies
+	e.mu.RUnlock()
+
+	vars := req.vars()
+	resourceType := req.ResourceType()
+	decision := &Decision{Effect: EffectDeny, Trace: []RuleTrace{}}
+	var allow, deny *compiledPolicy
+
+	for _, cp := range policies {
+		if !cp.appliesTo(resourceType, req.Action) {
+			continue
+		}
+
+		trace := RuleTrace{
+			PolicyID:  cp.policy.ID,
+			Name:      cp.policy.Name,
+			Effect:    cp.policy.Effect,
+			Priority:  cp.policy.Priority,
+			Condition: cp.policy.Condition,
+		}
+		matched, err := cp.condition.Eval(vars)
+		if err != nil {
+			trace.Error = err.Error()
+			// 拒绝规则求值失败时按命中处理，避免因属性缺失而放行
+			matched = cp.policy.Effect == EffectDeny
+		}
+		trace.Matched = matched
+		decision.Trace = append(decision.Trace, trace)
+
+		if !matched {
+			continue
+		}
+		if cp.policy.Effect == EffectDeny && deny == nil {
+			deny = cp
+		}
+		if cp.policy.Effect == EffectAllow && allow == nil {
+			allow = cp
+		}
+	}
+
+	switch {
+	case deny != nil:
+		decision.PolicyID = deny.policy.ID
+		decision.Policy = deny.policy.Name
+		decision.Reason = fmt.Sprintf("被拒绝规则 %s 拒绝", deny.policy.Name)
+	case allow != nil:
+		decision.Allowed = true
+		decision.Effect = EffectAllow
+		decision.PolicyID = allow.policy.ID
+		decision.Policy = allow.policy.Name
+		decision.Reason = fmt.Sprintf("由允许规则 %s 放行", allow.policy.Name)
+	default:
+		decision.Reason = "没有匹配的允许规则，默认拒绝"
+	}
+	return decision
+}
+
+// reloadIfStale 超过加载间隔时重新加载规则，失败时继续使用已加载的规则
+func (e *Engine) reloadIfStale() {
+	e.mu.RLock()
+	stale := time.Since(e.loadedAt) > e.reloadInterval
+	e.mu.RUnlock()
+	if !stale {
+		return
+	}
+	if err := e.Reload(); err != nil {
+		log.Printf("WARN: %v", err)
+		// 推迟下次重试，避免每个请求都访问数据库
+		e.mu.Lock()
+		e.loadedAt = time.Now()
+		e.mu.Unlock()
+	}
+}
+
+// SeedDefaults 按资源类型写入默认规则：某资源类型在规则表中还没有任何规则时才写入该类型的默认规则，
+// 多个服务共用同一张规则表时互不影响；已被管理员修改或删除部分规则的资源类型不再补写
+func (e *Engine) SeedDefaults(defaults []Policy) error {
+	existing, err := e.store.List()
+	if err != nil {
+		return err
+	}
+	seededResources := make(map[string]bool)
+	existingNames := make(map[string]bool)
+	for _, p := range existing {
+		seededResources[p.Resource] = true
+		existingNames[p.Name] = true
+	}
+
+	for i := range defaults {
+		if seededResources[defaults[i].Resource] || existingNames[defaults[i].Name] {
+			continue
+		}
+		if _, err := defaults[i].Validate(); err != nil {
+			return fmt.Errorf("默认规则 %s 无效: %w", defaults[i].Name, err)
+		}
+		if err := e.store.Save(&defaults[i]); err != nil {
+			return err
+		}
+	}
+	return e.Reload()
+}
@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPolicyNotFound 规则不存在
+var ErrPolicyNotFound = errors.New("访问策略不存在")
+
+// Store 访问策略存储
+type Store interface {
+	List() ([]Policy, error)
+	Get(id uint) (*Policy, error)
+	Save(policy *Policy) error // ID为0时新建
+	Delete(id uint) error
+}
+
+// gormStore 基于GORM的策略存储
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建基于GORM的策略存储（表不存在时自动创建）
+func NewGormStore(db *gorm.DB) (Store, error) {
+	if err := db.AutoMigrate(&Policy{}); err != nil {
+		return nil, err
+	}
+	return &gormStore{db: db}, nil
+}
+
+func (s *gormStore) List() ([]Policy, error) {
+	var policies []Policy
+	err := s.db.Order("priority DESC, id ASC").Find(&policies).Error
+	return policies, err
+}
+
+func (s *gormStore) Get(id uint) (*Policy, error) {
+	var policy Policy
+	err := s.db.First(&policy, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (s *gormStore) Save(policy *Policy) error {
+	if policy.ID == 0 {
+		return s.db.Create(policy).Error
+	}
+	result := s.db.Model(&Policy{}).Where("id = ?", policy.ID).Select("*").Omit("created_at").Updates(policy)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+func (s *gormStore) Delete(id uint) error {
+	result := s.db.Delete(&Policy{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+// memoryStore 内存策略存储（数据库不可用时使用默认规则）
+type memoryStore struct {
+	mu       sync.RWMutex
+	policies []Policy
+	nextID   uint
+}
+
+// NewMemoryStore 创建内存策略存储
+func NewMemoryStore(policies []Policy) Store {
+	store := &memoryStore{}
+	for i := range policies {
+		p := policies[i]
+		store.Save(&p)
+	}
+	return store
+}
+
+func (s *memoryStore) List() ([]Policy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Policy{}, s.policies...), nil
+}
+
+func (s *memoryStore) Get(id uint) (*Policy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.policies {
+		if p.ID == id {
+			policy := p
+			return &policy, nil
+		}
+	}
+	return nil, ErrPolicyNotFound
+}
+
+func (s *memoryStore) Save(policy *Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	policy.UpdatedAt = now
+	if policy.ID == 0 {
+		s.nextID++
+		policy.ID = s.nextID
+		policy.CreatedAt = now
+		s.policies = append(s.policies, *policy)
+		return nil
+	}
+	for i := range s.policies {
+		if s.policies[i].ID == policy.ID {
+			policy.CreatedAt = s.policies[i].CreatedAt
+			s.policies[i] = *policy
+			return nil
+		}
+	}
+	return ErrPolicyNotFound
+}
+
+func (s *memoryStore) Delete(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.policies {
+		if s.policies[i].ID == id {
+			s.policies = append(s.policies[:i], s.policies[i+1:]...)
+			return nil
+		}
+	}
+	return ErrPolicyNotFound
+}
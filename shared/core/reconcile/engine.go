@@ -0,0 +1,310 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize   = 200
+	maxBatchSize       = 1000
+	maxReportDiffs     = 1000
+	maxOrphanScan      = 1000 // 单批次最多检查的孤儿数据
+	defaultHistorySize = 20
+)
+
+// Options 对账选项
+type Options struct {
+	Cursor      uint `json:"cursor"`       // 从ID大于该值的实体开始
+	BatchSize   int  `json:"batch_size"`   // 每批实体数
+	MaxEntities int  `json:"max_entities"` // 本次最多检查的实体数，0表示检查到末尾
+	Repair      bool `json:"repair"`       // 是否以数据源为准自动修复
+}
+
+// Engine 跨存储对账引擎：按游标分批读取数据源，与各目标逐字段比较内容哈希
+type Engine struct {
+	entity   string
+	source   Source
+	targets  []Target
+	repairer Repairer
+
+	running sync.Mutex
+	mu      sync.RWMutex
+	history []*Report
+}
+
+// NewEngine 创建对账引擎，repairer 为 nil 时不支持自动修复
+func NewEngine(entity string, source Source, targets []Target, repairer Repairer) *Engine {
+	return &Engine{
+		entity:   entity,
+		source:   source,
+		targets:  targets,
+		repairer: repairer,
+	}
+}
+
+// Run 执行一次对账；同一时间只允许一个全量对账任务运行
+func (e *Engine) Run(ctx context.Context, opts Options) (*Report, error) {
+	if !e.running.TryLock() {
+		return nil, ErrRunInProgress
+	}
+	defer e.running.Unlock()
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.BatchSize > maxBatchSize {
+		opts.BatchSize = maxBatchSize
+	}
+
+	report := e.newReport(opts)
+	cursor := opts.Cursor
+	for {
+		if err := ctx.Err(); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			break
+		}
+
+		batch, err := e.source.Batch(ctx, cursor, opts.BatchSize)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("读取数据源失败: %v", err))
+			break
+		}
+
+		// 最后一批不设上限，以便发现ID大于数据源最大ID的孤儿数据
+		last := len(batch) < opts.BatchSize
+		var upTo uint
+		if !last {
+			upTo = batch[len(batch)-1].ID
+		}
+		e.compareBatch(ctx, report, batch, cursor, upTo, true)
+
+		report.Checked += len(batch)
+		if len(batch) > 0 {
+			cursor = batch[len(batch)-1].ID
+		}
+		if last {
+			report.Complete = true
+			break
+		}
+		if opts.MaxEntities > 0 && report.Checked >= opts.MaxEntities {
+			break
+		}
+	}
+
+	if !report.Complete {
+		report.NextCursor = cursor
+	}
+	if opts.Repair {
+		e.repair(ctx, report)
+	}
+	e.finish(report)
+	return report, nil
+}
+
+// CheckEntity 对单个实体对账
+func (e *Engine) CheckEntity(ctx context.Context, id uint, repair bool) (*Report, error) {
+	report := e.newReport(Options{Cursor: id, Repair: repair})
+
+	record, err := e.source.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("读取数据源失败: %v", err)
+	}
+	var batch []Record
+	if record != nil {
+		batch = append(batch, *record)
+		report.Checked = 1
+	}
+	e.compareEntity(ctx, report, id, batch)
+	report.Complete = true
+
+	if repair {
+		e.repair(ctx, report)
+	}
+	e.finish(report)
+	return report, nil
+}
+
+// Schedule 按固定间隔执行全量对账，上一轮未结束时跳过本轮
+func (e *Engine) Schedule(ctx context.Context, interval time.Duration, opts Options) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := e.Run(ctx, opts)
+				if err != nil {
+					log.Printf("%s对账跳过: %v", e.entity, err)
+					continue
+				}
+				if !report.Consistent() {
+					log.Printf("WARN: %s对账发现差异: 检查 %d 条，差异 %d 条，修复 %d 条，错误 %d 个",
+						e.entity, report.Checked, len(report.Diffs), report.Repaired, len(report.Errors))
+				}
+			}
+		}
+	}()
+}
+
+// History 最近的对账报告，最新的在前
+func (e *Engine) History() []*Report {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	reports := make([]*Report, len(e.history))
+	for i, report := range e.history {
+		reports[len(e.history)-1-i] = report
+	}
+	return reports
+}
+
+func (e *Engine) newReport(opts Options) *Report {
+	report := &Report{
+		Entity:      e.entity,
+		StartedAt:   time.Now(),
+		StartCursor: opts.Cursor,
+		Targets:     make(map[string]*TargetSummary),
+		RepairMode:  opts.Repair,
+	}
+	for _, target := range e.targets {
+		report.Targets[target.Name()] = &TargetSummary{}
+	}
+	return report
+}
+
+func (e *Engine) finish(report *Report) {
+	report.FinishedAt = time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.history = append(e.history, report)
+	if len(e.history) > defaultHistorySize {
+		e.history = e.history[len(e.history)-defaultHistorySize:]
+	}
+}
+
+// compareBatch 比较一批数据源记录；scanOrphans 时同时检查 (after, upTo] 内目标多出的实体
+func (e *Engine) compareBatch(ctx context.Context, report *Report, batch []Record, after, upTo uint, scanOrphans bool) {
+	ids := make([]uint, 0, len(batch))
+	sourceIDs := make(map[uint]bool, len(batch))
+	for _, record := range batch {
+		ids = append(ids, record.ID)
+		sourceIDs[record.ID] = true
+	}
+
+	for _, target := range e.targets {
+		summary := report.Targets[target.Name()]
+
+		if len(ids) > 0 {
+			loaded, err := target.Load(ctx, ids)
+			if err != nil {
+				summary.Errors++
+				report.Errors = append(report.Errors, fmt.Sprintf("读取%s失败: %v", target.Name(), err))
+				continue
+			}
+			for _, record := range batch {
+				summary.Checked++
+				targetRecord, ok := loaded[record.ID]
+				if !ok {
+					summary.Missing++
+					e.addDiff(report, Diff{EntityID: record.ID, Target: target.Name(), Kind: DiffMissing})
+					continue
+				}
+				if fields := CompareFields(record.Fields, targetRecord.Fields); len(fields) > 0 {
+					summary.Mismatch++
+					e.addDiff(report, Diff{EntityID: record.ID, Target: target.Name(), Kind: DiffMismatch, Fields: fields})
+				}
+			}
+		}
+
+		if !scanOrphans {
+			continue
+		}
+		targetIDs, err := target.IDsInRange(ctx, after, upTo, maxOrphanScan)
+		if err == ErrRangeUnsupported {
+			continue
+		}
+		if err != nil {
+			summary.Errors++
+			report.Errors = append(report.Errors, fmt.Sprintf("枚举%s失败: %v", target.Name(), err))
+			continue
+		}
+		for _, id := range targetIDs {
+			if !sourceIDs[id] {
+				summary.Orphan++
+				e.addDiff(report, Diff{EntityID: id, Target: target.Name(), Kind: DiffOrphan})
+			}
+		}
+	}
+}
+
+// compareEntity 单个实体对账：数据源中不存在时，目标中存在即为孤儿数据
+func (e *Engine) compareEntity(ctx context.Context, report *Report, id uint, batch []Record) {
+	if len(batch) > 0 {
+		e.compareBatch(ctx, report, batch, 0, 0, false)
+		return
+	}
+	for _, target := range e.targets {
+		summary := report.Targets[target.Name()]
+		loaded, err := target.Load(ctx, []uint{id})
+		if err != nil {
+			summary.Errors++
+			report.Errors = append(report.Errors, fmt.Sprintf("读取%s失败: %v", target.Name(), err))
+			continue
+		}
+		summary.Checked++
+		if _, ok := loaded[id]; ok {
+			summary.Orphan++
+			e.addDiff(report, Diff{EntityID: id, Target: target.Name(), Kind: DiffOrphan})
+		}
+	}
+}
+
+func (e *Engine) addDiff(report *Report, diff Diff) {
+	if len(report.Diffs) >= maxReportDiffs {
+		report.Truncated = true
+		return
+	}
+	report.Diffs = append(report.Diffs, diff)
+}
+
+// repair 按实体修复报告中的差异，每个实体只修复一次
+func (e *Engine) repair(ctx context.Context, report *Report) {
+	if e.repairer == nil {
+		if len(report.Diffs) > 0 {
+			report.Errors = append(report.Errors, "未配置修复器，跳过自动修复")
+		}
+		return
+	}
+
+	byEntity := make(map[uint][]int)
+	for i, diff := range report.Diffs {
+		byEntity[diff.EntityID] = append(byEntity[diff.EntityID], i)
+	}
+	ids := make([]uint, 0, len(byEntity))
+	for id := range byEntity {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		err := e.repairer.Repair(ctx, id)
+		if err != nil {
+			report.RepairFailed++
+		} else {
+			report.Repaired++
+		}
+		for _, i := range byEntity[id] {
+			report.Diffs[i].Repaired = err == nil
+			if err != nil {
+				report.Diffs[i].RepairError = err.Error()
+			}
+		}
+	}
+}
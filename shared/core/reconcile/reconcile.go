@@ -0,0 +1,211 @@
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 差异类型
+const (
+	DiffMissing  = "missing"  // 目标中缺失
+	DiffOrphan   = "orphan"   // 目标中存在，但数据源中已不存在
+	DiffMismatch = "mismatch" // 字段内容不一致
+)
+
+var (
+	ErrRunInProgress    = errors.New("对账任务正在运行")
+	ErrRangeUnsupported = errors.New("目标不支持按范围枚举")
+)
+
+// Record 规范化后的实体记录；字段值统一转换为可比较的字符串
+type Record struct {
+	ID     uint
+	Fields map[string]string
+}
+
+// Source 数据源（真实来源），按ID游标分批读取
+type Source interface {
+	// Batch 返回ID大于after的最多limit条记录，按ID升序
+	Batch(ctx context.Context, after uint, limit int) ([]Record, error)
+	// Get 读取单个实体，不存在时返回 nil, nil
+	Get(ctx context.Context, id uint) (*Record, error)
+}
+
+// Target 需要与数据源保持一致的存储
+type Target interface {
+	Name() string
+	// Load 读取指定实体在目标中的记录，不存在的实体不出现在结果中；
+	// 只比较目标记录中出现的字段，目标应为其保存的每个字段都返回值（缺失时为空字符串）
+	Load(ctx context.Context, ids []uint) (map[uint]Record, error)
+	// IDsInRange 返回目标中ID在 (after, upTo] 内的实体，upTo 为 0 表示不设上限；
+	// 无法枚举时返回 ErrRangeUnsupported，此时不检查孤儿数据
+	IDsInRange(ctx context.Context, after, upTo uint, limit int) ([]uint, error)
+}
+
+// Repairer 以数据源为准修复单个实体在各目标中的数据（包括删除孤儿数据）
+type Repairer interface {
+	Repair(ctx context.Context, id uint) error
+}
+
+// RepairFunc 函数形式的 Repairer
+type RepairFunc func(ctx context.Context, id uint) error
+
+// Repair 实现 Repairer
+func (f RepairFunc) Repair(ctx context.Context, id uint) error {
+	return f(ctx, id)
+}
+
+// FieldDiff 单个字段的差异，只给出内容哈希，不暴露字段值
+type FieldDiff struct {
+	Field      string `json:"field"`
+	SourceHash string `json:"source_hash"`
+	TargetHash string `json:"target_hash"`
+}
+
+// Diff 单个实体在单个目标中的差异
+type Diff struct {
+	EntityID    uint        `json:"entity_id"`
+	Target      string      `json:"target"`
+	Kind        string      `json:"kind"`
+	Fields      []FieldDiff `json:"fields,omitempty"`
+	Repaired    bool        `json:"repaired"`
+	RepairError string      `json:"repair_error,omitempty"`
+}
+
+// TargetSummary 单个目标的对账统计
+type TargetSummary struct {
+	Checked  int `json:"checked"`
+	Missing  int `json:"missing"`
+	Orphan   int `json:"orphan"`
+	Mismatch int `json:"mismatch"`
+	Errors   int `json:"errors"`
+}
+
+// Report 对账报告
+type Report struct {
+	Entity       string                    `json:"entity"`
+	StartedAt    time.Time                 `json:"started_at"`
+	FinishedAt   time.Time                 `json:"finished_at"`
+	StartCursor  uint                      `json:"start_cursor"`
+	NextCursor   uint                      `json:"next_cursor"` // 未完成时下次从该游标继续
+	Complete     bool                      `json:"complete"`
+	Checked      int                       `json:"checked"`
+	Targets      map[string]*TargetSummary `json:"targets"`
+	Diffs        []Diff                    `json:"diffs"`
+	Truncated    bool                      `json:"truncated"` // 差异过多时只保留前 maxReportDiffs 条
+	RepairMode   bool                      `json:"repair_mode"`
+	Repaired     int                       `json:"repaired"`
+	RepairFailed int                       `json:"repair_failed"`
+	Errors       []string                  `json:"errors,omitempty"`
+}
+
+// Consistent 是否未发现任何差异与错误
+func (r *Report) Consistent() bool {
+	if len(r.Errors) > 0 {
+		return false
+	}
+	for _, summary := range r.Targets {
+		if summary.Missing+summary.Orphan+summary.Mismatch+summary.Errors > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// HashValue 字段内容哈希
+func HashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Fingerprint 整条记录的内容哈希，字段按名称排序后计算
+func Fingerprint(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(HashValue(fields[name]))
+		b.WriteByte(';')
+	}
+	return HashValue(b.String())
+}
+
+// CompareFields 比较目标记录中出现的每个字段与数据源是否一致
+func CompareFields(source, target map[string]string) []FieldDiff {
+	names := make([]string, 0, len(target))
+	for name := range target {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diffs []FieldDiff
+	for _, name := range names {
+		sourceValue, ok := source[name]
+		sourceHash := ""
+		if ok {
+			sourceHash = HashValue(sourceValue)
+		}
+		targetHash := HashValue(target[name])
+		if sourceHash != targetHash {
+			diffs = append(diffs, FieldDiff{Field: name, SourceHash: sourceHash, TargetHash: targetHash})
+		}
+	}
+	return diffs
+}
+
+// 规范化辅助函数，保证各存储中的同一取值得到相同的字符串
+
+// Uint 无符号整数转字符串
+func Uint(v uint) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// Int 整数转字符串
+func Int(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// Float 浮点数转字符串，保留6位小数以消除各存储的精度差异
+func Float(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+// OptionalFloat 可空浮点数，nil 转为空字符串
+func OptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return Float(*v)
+}
+
+// Bool 布尔值转字符串
+func Bool(v bool) string {
+	return strconv.FormatBool(v)
+}
+
+// Time 时间统一为UTC秒级精度
+func Time(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+// SortedList 列表排序后拼接，用于比较无序集合
+func SortedList(items []string) string {
+	sorted := append([]string(nil), items...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -45,6 +45,7 @@ const (
 	CodeUnauthorized      = 401  // 未授权
 	CodeForbidden         = 403  // 禁止访问
 	CodeNotFound          = 404  // 未找到
+	CodeTooManyRequests   = 429  // 请求过多（登录锁定等）
 	CodeInternalError     = 500  // 内部错误
 	CodeUserNotFound      = 1001 // 用户不存在
 	CodeInvalidToken      = 1002 // 无效令牌
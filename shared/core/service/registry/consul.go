@@ -74,6 +74,53 @@ func (cr *ConsulRegistry) GetService(serviceName string) ([]*api.ServiceEntry, e
 	return services, nil
 }
 
+// ListServices 列出Consul目录中的所有服务名及其标签
+func (cr *ConsulRegistry) ListServices() (map[string][]string, error) {
+	services, _, err := cr.client.Catalog().Services(nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取服务列表失败: %v", err)
+	}
+
+	return services, nil
+}
+
+// GetServiceInstances 获取健康的服务实例（转换为ServiceInfo）
+func (cr *ConsulRegistry) GetServiceInstances(serviceName string) ([]*ServiceInfo, error) {
+	entries, err := cr.GetService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]*ServiceInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Service == nil {
+			continue
+		}
+
+		address := entry.Service.Address
+		if address == "" && entry.Node != nil {
+			address = entry.Node.Address
+		}
+
+		instances = append(instances, &ServiceInfo{
+			ID:       entry.Service.ID,
+			Name:     entry.Service.Service,
+			Address:  address,
+			Port:     entry.Service.Port,
+			Endpoint: fmt.Sprintf("%s:%d", address, entry.Service.Port),
+			Metadata: entry.Service.Meta,
+			Tags:     entry.Service.Tags,
+			Health: &HealthStatus{
+				Status:    "healthy",
+				Timestamp: time.Now(),
+			},
+			LastCheck: time.Now(),
+		})
+	}
+
+	return instances, nil
+}
+
 // UpdateHealth 更新服务健康状态
 func (cr *ConsulRegistry) UpdateHealth(serviceID string, health *HealthStatus) error {
 	checkID := fmt.Sprintf("service:%s", serviceID)
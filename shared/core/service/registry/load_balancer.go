@@ -53,6 +53,14 @@ func (lb *LoadBalancer) SetStrategy(strategy LoadBalanceStrategy) {
 	lb.strategy = strategy
 }
 
+// Strategy 获取当前策略实例
+func (lb *LoadBalancer) Strategy() LoadBalanceStrategy {
+	lb.mutex.RLock()
+	defer lb.mutex.RUnlock()
+
+	return lb.strategy
+}
+
 // GetStrategy 获取当前策略名称
 func (lb *LoadBalancer) GetStrategy() string {
 	lb.mutex.RLock()
@@ -1,6 +1,7 @@
 package template
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/szjason72/zervigo/shared/core"
 	"github.com/szjason72/zervigo/shared/core/service/health"
 	"github.com/szjason72/zervigo/shared/core/service/registry"
+	"github.com/szjason72/zervigo/shared/core/tracing"
 )
 
 // ServiceConfig 服务配置
@@ -29,6 +31,7 @@ type ServiceTemplate struct {
 	health      *health.ServiceHealth
 	consulReg   *registry.ConsulRegistry
 	serviceInfo *registry.ServiceInfo
+	tracer      *tracing.Tracer
 }
 
 // NewServiceTemplate 创建服务模板
@@ -54,9 +57,18 @@ func NewServiceTemplate(config *ServiceConfig) (*ServiceTemplate, error) {
 		serviceHealth.AddChecker(dbChecker)
 	}
 
+	// 创建跟踪器（导出方式见tracing.ConfigFromEnv），并设为进程默认，供utils.HTTPClient延续调用链
+	tracer, err := tracing.NewTracerFromEnv(config.Name)
+	if err != nil {
+		log.Printf("警告: 创建跟踪器失败，仅传播跟踪上下文: %v", err)
+		tracer = tracing.NewTracer(config.Name, 1, nil)
+	}
+	tracing.SetDefaultTracer(tracer)
+
 	// 创建Gin引擎
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
+	router.Use(tracing.GinMiddleware(tracer))
 
 	// 创建Consul注册器
 	consulReg, err := registry.NewConsulRegistry("localhost:8500")
@@ -81,6 +93,7 @@ func NewServiceTemplate(config *ServiceConfig) (*ServiceTemplate, error) {
 		health:      serviceHealth,
 		consulReg:   consulReg,
 		serviceInfo: serviceInfo,
+		tracer:      tracer,
 	}, nil
 }
 
@@ -99,6 +112,11 @@ func (st *ServiceTemplate) GetRouter() *gin.Engine {
 	return st.router
 }
 
+// GetTracer 获取跟踪器（业务代码可用于创建内部Span）
+func (st *ServiceTemplate) GetTracer() *tracing.Tracer {
+	return st.tracer
+}
+
 // GetCore 获取核心实例
 func (st *ServiceTemplate) GetCore() *jobfirst.Core {
 	return st.core
@@ -169,6 +187,13 @@ func (st *ServiceTemplate) Stop() error {
 		log.Printf("警告: 从Consul注销失败: %v", err)
 	}
 
+	// 导出剩余的跟踪数据
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := st.tracer.Shutdown(ctx); err != nil {
+		log.Printf("警告: 关闭跟踪器失败: %v", err)
+	}
+
 	// 关闭核心包
 	if st.core != nil {
 		st.core.Close()
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config Central Brain配置结构
@@ -19,11 +20,18 @@ type Config struct {
 	RouterServicePort     int // Router Service端口
 	PermissionServicePort int // Permission Service端口
 
+	// 可信反向代理（IP或CIDR）：只采信来自这些地址的X-Forwarded-For/X-Real-IP，为空时按连接地址识别客户端IP
+	TrustedProxies []string
+
 	// 服务发现配置
 	ServiceDiscovery struct {
 		Enabled     bool
 		ConsulURL   string
 		ServiceHost string // 服务主机地址（localhost或Docker服务名）
+
+		RefreshInterval     int    // 路由表刷新间隔（秒）
+		LoadBalanceStrategy string // 负载均衡策略（round_robin、random、least_connections等）
+		RouterRoutesEnabled bool   // 是否从Router Service的路由配置表加载路由
 	}
 
 	// 服务凭证配置
@@ -75,6 +83,38 @@ type Config struct {
 		}
 	}
 
+	// 限流配置
+	RateLimit struct {
+		Enabled      bool   // 是否启用多维度策略限流
+		PolicyFile   string // 限流策略文件（JSON），为空时使用默认策略
+		RedisEnabled bool   // 是否使用Redis分布式令牌桶（多副本共享状态）
+	}
+
+	// 网关响应缓存配置（只缓存上游通过Cache-Control/ETag允许的GET响应）
+	ResponseCache struct {
+		Enabled          bool // 是否启用响应缓存
+		MaxEntries       int  // 进程内LRU最大条目数
+		MaxBodyBytes     int  // 可缓存的最大响应体字节数
+		RevalidateWindow int  // 过期后保留带ETag条目的秒数（期间以条件请求重新验证）
+		RedisEnabled     bool // 是否使用Redis二级缓存（多副本共享并广播失效）
+	}
+
+	// 预测预取配置（按用户访问历史预取下一步可能访问的GET接口并写入响应缓存）
+	Prefetch struct {
+		Enabled       bool     // 是否启用预取（依赖响应缓存）
+		Threshold     float64  // 预测概率达到该值才预取
+		MaxConcurrent int      // 预取专用并发数，占满时直接放弃新的预取
+		Timeout       int      // 单次预取超时（秒）
+		Routes        []string // 允许预取的GET路径前缀（只应包含无副作用的接口）
+	}
+
+	// 令牌校验配置（网关按认证服务JWKS本地校验用户令牌）
+	TokenValidation struct {
+		Enabled    bool   // 是否在网关校验用户令牌
+		JWKSURL    string // 为空时使用认证服务的 /.well-known/jwks.json
+		AllowHS256 bool   // 迁移期放行HS256旧令牌（默认关闭；网关不持有共享密钥，交由下游服务校验）
+	}
+
 	// 数据库检查配置
 	DatabaseCheck struct {
 		Enabled    bool // 是否启用数据库<｜place▁holder▁no▁196｜>
@@ -100,10 +140,15 @@ func GetDefaultConfig() *Config {
 		PermissionServicePort: getEnvInt("PERMISSION_SERVICE_PORT", 8086),
 	}
 
+	config.TrustedProxies = getEnvList("TRUSTED_PROXIES")
+
 	// 服务发现配置
 	config.ServiceDiscovery.Enabled = getEnvBool("SERVICE_DISCOVERY_ENABLED", false)
 	config.ServiceDiscovery.ConsulURL = getEnvString("CONSUL_AGENT_URL", "http://localhost:8500")
 	config.ServiceDiscovery.ServiceHost = getEnvString("SERVICE_HOST", "localhost")
+	config.ServiceDiscovery.RefreshInterval = getEnvInt("ROUTE_REFRESH_INTERVAL", 30)
+	config.ServiceDiscovery.LoadBalanceStrategy = getEnvString("LOAD_BALANCE_STRATEGY", "round_robin")
+	config.ServiceDiscovery.RouterRoutesEnabled = getEnvBool("ROUTER_ROUTES_ENABLED", true)
 
 	// 服务凭证配置
 	config.ServiceCredentials.ServiceID = getEnvString("SERVICE_ID", "central-brain")
@@ -147,6 +192,30 @@ func GetDefaultConfig() *Config {
 	config.Database.MongoDB.Database = getEnvString("MONGODB_DATABASE", "")
 	config.Database.MongoDB.Enabled = config.Database.MongoDB.URL != ""
 
+	// 限流配置
+	config.RateLimit.Enabled = getEnvBool("RATE_LIMIT_ENABLED", true)
+	config.RateLimit.PolicyFile = getEnvString("RATE_LIMIT_POLICY_FILE", "")
+	config.RateLimit.RedisEnabled = getEnvBool("RATE_LIMIT_REDIS_ENABLED", config.Database.Redis.Enabled)
+
+	// 响应缓存配置
+	config.ResponseCache.Enabled = getEnvBool("RESPONSE_CACHE_ENABLED", true)
+	config.ResponseCache.MaxEntries = getEnvInt("RESPONSE_CACHE_MAX_ENTRIES", 10000)
+	config.ResponseCache.MaxBodyBytes = getEnvInt("RESPONSE_CACHE_MAX_BODY_BYTES", 1<<20)
+	config.ResponseCache.RevalidateWindow = getEnvInt("RESPONSE_CACHE_REVALIDATE_WINDOW", 600)
+	config.ResponseCache.RedisEnabled = getEnvBool("RESPONSE_CACHE_REDIS_ENABLED", config.Database.Redis.Enabled)
+
+	// 预测预取配置
+	config.Prefetch.Enabled = getEnvBool("PREFETCH_ENABLED", false)
+	config.Prefetch.Threshold = getEnvFloat("PREFETCH_THRESHOLD", 0.75)
+	config.Prefetch.MaxConcurrent = getEnvInt("PREFETCH_MAX_CONCURRENT", 4)
+	config.Prefetch.Timeout = getEnvInt("PREFETCH_TIMEOUT", 5)
+	config.Prefetch.Routes = getEnvList("PREFETCH_ROUTES")
+
+	// 令牌校验配置
+	config.TokenValidation.Enabled = getEnvBool("TOKEN_VALIDATION_ENABLED", false)
+	config.TokenValidation.JWKSURL = getEnvString("AUTH_JWKS_URL", "")
+	config.TokenValidation.AllowHS256 = getEnvBool("JWT_ACCEPT_HS256", false)
+
 	// 数据库检查配置
 	config.DatabaseCheck.Enabled = getEnvBool("DATABASE_CHECK_ENABLED", true)
 	config.DatabaseCheck.Required = getEnvBool("DATABASE_CHECK_REQUIRED", false)
@@ -177,6 +246,27 @@ func getEnvString(key, defaultValue string) string {
 	return defaultValue
 }
 
+// 辅助函数：从环境变量读取浮点数，如果没有则返回默认值
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// 辅助函数：从环境变量读取逗号分隔的列表（忽略空项）
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // 辅助函数：从环境变量读取整数，如果没有则返回默认值
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
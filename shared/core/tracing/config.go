@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 导出器类型
+const (
+	ExporterNone = "none" // 仅传播上下文，不导出
+	ExporterOTLP = "otlp" // OTLP/HTTP发送到Collector
+	ExporterFile = "file" // 本地JSON Lines文件
+)
+
+// Config 跟踪配置
+type Config struct {
+	ServiceName  string
+	Exporter     string
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+	FilePath     string
+	SampleRatio  float64
+}
+
+// ConfigFromEnv 从环境变量读取跟踪配置
+//
+//	TRACING_EXPORTER             none | otlp | file（默认none）
+//	OTEL_EXPORTER_OTLP_ENDPOINT  Collector地址（默认 http://localhost:4318）
+//	OTEL_EXPORTER_OTLP_HEADERS   附加请求头，格式 k1=v1,k2=v2
+//	TRACING_FILE_PATH            文件导出路径（默认 ./logs/traces.jsonl）
+//	TRACING_SAMPLE_RATIO         根Span采样比例 0~1（默认1）
+func ConfigFromEnv(serviceName string) Config {
+	config := Config{
+		ServiceName:  serviceName,
+		Exporter:     strings.ToLower(getEnv("TRACING_EXPORTER", ExporterNone)),
+		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		OTLPHeaders:  make(map[string]string),
+		FilePath:     getEnv("TRACING_FILE_PATH", "./logs/traces.jsonl"),
+		SampleRatio:  1,
+	}
+
+	if v, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64); err == nil {
+		config.SampleRatio = v
+	}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			config.OTLPHeaders[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return config
+}
+
+// NewTracerFromConfig 按配置创建跟踪器
+func NewTracerFromConfig(config Config) (*Tracer, error) {
+	var exporter Exporter
+	switch config.Exporter {
+	case ExporterOTLP:
+		exporter = NewOTLPExporter(config.OTLPEndpoint, config.OTLPHeaders)
+	case ExporterFile:
+		if dir := filepath.Dir(config.FilePath); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建跟踪目录失败: %w", err)
+			}
+		}
+		fileExporter, err := NewFileExporter(config.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		exporter = fileExporter
+	case ExporterNone, "":
+	default:
+		return nil, fmt.Errorf("不支持的跟踪导出器: %s", config.Exporter)
+	}
+
+	return NewTracer(config.ServiceName, config.SampleRatio, exporter), nil
+}
+
+// NewTracerFromEnv 按环境变量创建跟踪器
+func NewTracerFromEnv(serviceName string) (*Tracer, error) {
+	return NewTracerFromConfig(ConfigFromEnv(serviceName))
+}
+
+var (
+	defaultTracerMu sync.RWMutex
+	defaultTracer   = NewTracer("unknown-service", 1, nil)
+)
+
+// SetDefaultTracer 设置进程级默认跟踪器（供未显式传入跟踪器的HTTP客户端使用）
+func SetDefaultTracer(tracer *Tracer) {
+	if tracer == nil {
+		return
+	}
+	defaultTracerMu.Lock()
+	defer defaultTracerMu.Unlock()
+	defaultTracer = tracer
+}
+
+// DefaultTracer 获取默认跟踪器
+func DefaultTracer() *Tracer {
+	defaultTracerMu.RLock()
+	defer defaultTracerMu.RUnlock()
+	return defaultTracer
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
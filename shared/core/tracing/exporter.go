@@ -0,0 +1,366 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 批处理默认参数
+const (
+	DefaultBatchSize     = 256
+	DefaultFlushInterval = 5 * time.Second
+	defaultQueueSize     = 4096
+)
+
+// Exporter Span导出器
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []*SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// BatchProcessor 异步批量导出Span；队列满时丢弃，避免跟踪拖慢请求
+type BatchProcessor struct {
+	exporter      Exporter
+	batchSize     int
+	flushInterval time.Duration
+
+	queue    chan *SpanData
+	flushReq chan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	dropped  int64
+	mu       sync.Mutex
+}
+
+// NewBatchProcessor 创建批处理器并启动后台导出
+func NewBatchProcessor(exporter Exporter, batchSize int, flushInterval time.Duration) *BatchProcessor {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+
+	bp := &BatchProcessor{
+		exporter:      exporter,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan *SpanData, defaultQueueSize),
+		flushReq:      make(chan chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go bp.run()
+	return bp
+}
+
+// OnEnd Span结束时入队
+func (bp *BatchProcessor) OnEnd(span *SpanData) {
+	select {
+	case bp.queue <- span:
+	default:
+		bp.mu.Lock()
+		bp.dropped++
+		bp.mu.Unlock()
+	}
+}
+
+// Dropped 因队列满而丢弃的Span数
+func (bp *BatchProcessor) Dropped() int64 {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.dropped
+}
+
+func (bp *BatchProcessor) run() {
+	ticker := time.NewTicker(bp.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, bp.batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := bp.exporter.ExportSpans(ctx, batch); err != nil {
+			fmt.Printf("⚠️ 导出跟踪数据失败（%d个Span）: %v\n", len(batch), err)
+		}
+		cancel()
+		batch = make([]*SpanData, 0, bp.batchSize)
+	}
+
+	for {
+		select {
+		case span := <-bp.queue:
+			batch = append(batch, span)
+			if len(batch) >= bp.batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-bp.flushReq:
+			// 先取空队列再导出
+			for drained := false; !drained; {
+				select {
+				case span := <-bp.queue:
+					batch = append(batch, span)
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(ack)
+		case <-bp.done:
+			return
+		}
+	}
+}
+
+// ForceFlush 立即导出队列中的Span
+func (bp *BatchProcessor) ForceFlush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case bp.flushReq <- ack:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown 导出剩余Span并关闭导出器
+func (bp *BatchProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	bp.stopOnce.Do(func() {
+		if flushErr := bp.ForceFlush(ctx); flushErr != nil {
+			err = flushErr
+		}
+		close(bp.done)
+		if shutdownErr := bp.exporter.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	})
+	return err
+}
+
+// FileExporter 以JSON Lines格式写入本地文件（本地开发使用）
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileExporter 创建文件导出器（追加写入）
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开跟踪文件失败: %w", err)
+	}
+	return &FileExporter{file: file}, nil
+}
+
+// ExportSpans 每个Span写一行JSON
+func (e *FileExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.file)
+	for _, span := range spans {
+		if err := encoder.Encode(span); err != nil {
+			return fmt.Errorf("写入跟踪文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown 关闭文件
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
+
+// OTLPExporter 通过OTLP/HTTP（JSON编码）发送到Collector的 /v1/traces
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPExporter 创建OTLP导出器；endpoint可为Collector地址（如 http://localhost:4318）或完整的 /v1/traces 地址
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &OTLPExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ExportSpans 按服务分组编码为OTLP ExportTraceServiceRequest
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	payload, err := json.Marshal(buildOTLPRequest(spans))
+	if err != nil {
+		return fmt.Errorf("序列化OTLP请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建OTLP请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送OTLP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP Collector返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Shutdown 无需释放资源
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// OTLP JSON结构（仅包含用到的字段）
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func buildOTLPRequest(spans []*SpanData) otlpRequest {
+	byService := make(map[string][]otlpSpan)
+	var services []string
+	for _, span := range spans {
+		if _, exists := byService[span.Service]; !exists {
+			services = append(services, span.Service)
+		}
+
+		events := make([]otlpEvent, 0, len(span.Events))
+		for _, event := range span.Events {
+			events = append(events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+				Name:         event.Name,
+				Attributes:   otlpAttributes(event.Attributes),
+			})
+		}
+
+		byService[span.Service] = append(byService[span.Service], otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Events:            events,
+			Status:            otlpStatus{Code: int(span.Status), Message: span.StatusMessage},
+		})
+	}
+
+	request := otlpRequest{}
+	for _, service := range services {
+		request.ResourceSpans = append(request.ResourceSpans, otlpResourceSpans{
+			Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": service})},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/szjason72/zervigo/shared/core/tracing"},
+				Spans: byService[service],
+			}},
+		})
+	}
+	return request
+}
+
+// otlpAttributes 转换为OTLP AnyValue（int64按规范编码为字符串）
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	result := make([]otlpKeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		var anyValue map[string]interface{}
+		switch v := value.(type) {
+		case string:
+			anyValue = map[string]interface{}{"stringValue": v}
+		case bool:
+			anyValue = map[string]interface{}{"boolValue": v}
+		case int:
+			anyValue = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			anyValue = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			anyValue = map[string]interface{}{"doubleValue": v}
+		default:
+			anyValue = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		result = append(result, otlpKeyValue{Key: key, Value: anyValue})
+	}
+	return result
+}
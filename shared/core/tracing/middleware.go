@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gin上下文键（与各服务现有的trace_id读取方式保持一致）
+const (
+	ContextKeyTraceID = "trace_id"
+	ContextKeySpanID  = "span_id"
+)
+
+// GinMiddleware 服务端跟踪中间件
+// 接收上游traceparent（无则新建链路），为本次请求创建Server Span，
+// 并在响应头中回写traceparent与X-Trace-ID。
+func GinMiddleware(tracer *Tracer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if remote, ok := Extract(c.Request.Header); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, remote)
+		}
+
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+c.Request.URL.Path, SpanKindServer)
+		defer span.End()
+
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.target", c.Request.URL.RequestURI())
+		span.SetAttribute("http.client_ip", c.ClientIP())
+		if ua := c.Request.UserAgent(); ua != "" {
+			span.SetAttribute("http.user_agent", ua)
+		}
+
+		sc := span.SpanContext()
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(ContextKeyTraceID, sc.TraceID.String())
+		c.Set(ContextKeySpanID, sc.SpanID.String())
+		c.Header(HeaderTraceParent, FormatTraceParent(sc))
+		c.Header("X-Trace-ID", sc.TraceID.String())
+
+		c.Next()
+
+		// 使用路由模板命名，避免Span名称基数过高
+		if route := c.FullPath(); route != "" {
+			span.SetName(c.Request.Method + " " + route)
+			span.SetAttribute("http.route", route)
+		}
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+// Transport 客户端跟踪RoundTripper：为每个出站请求创建Client Span并注入traceparent
+type Transport struct {
+	Base   http.RoundTripper
+	Tracer *Tracer
+}
+
+// NewTransport 包装base（nil时使用http.DefaultTransport）
+func NewTransport(base http.RoundTripper, tracer *Tracer) *Transport {
+	return &Transport{Base: base, Tracer: tracer}
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tracer := t.Tracer
+	if tracer == nil {
+		tracer = DefaultTracer()
+	}
+
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method, SpanKindClient)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+	span.SetAttribute("net.peer.name", req.URL.Host)
+
+	// RoundTripper不得修改原请求
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(StatusError, fmt.Sprintf("HTTP %d", resp.StatusCode))
+	}
+	span.End()
+	return resp, nil
+}
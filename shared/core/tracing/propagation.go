@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// W3C Trace Context 请求头
+const (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+// ParseTraceParent 解析traceparent头：{version}-{trace-id}-{parent-id}-{flags}
+func ParseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+
+	version, traceHex, spanHex, flagsHex := parts[0], parts[1], parts[2], parts[3]
+	// 版本ff无效；版本00必须恰好4段，更高版本允许追加字段
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if len(traceHex) != 32 || len(spanHex) != 16 || len(flagsHex) != 2 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if !decodeLowerHex(sc.TraceID[:], traceHex) || !decodeLowerHex(sc.SpanID[:], spanHex) {
+		return SpanContext{}, false
+	}
+	var flags [1]byte
+	if !decodeLowerHex(flags[:], flagsHex) {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	sc.Sampled = flags[0]&0x01 == 0x01
+	sc.Remote = true
+	return sc, true
+}
+
+// FormatTraceParent 生成traceparent头
+func FormatTraceParent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// decodeLowerHex 规范要求小写十六进制
+func decodeLowerHex(dst []byte, s string) bool {
+	if strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Extract 从请求头提取上游跟踪上下文
+func Extract(header http.Header) (SpanContext, bool) {
+	sc, ok := ParseTraceParent(header.Get(HeaderTraceParent))
+	if !ok {
+		return SpanContext{}, false
+	}
+	sc.TraceState = header.Get(HeaderTraceState)
+	return sc, true
+}
+
+// Inject 将ctx中的跟踪上下文写入请求头
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(HeaderTraceParent, FormatTraceParent(sc))
+	if sc.TraceState != "" {
+		header.Set(HeaderTraceState, sc.TraceState)
+	} else {
+		header.Del(HeaderTraceState)
+	}
+}
@@ -0,0 +1,371 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// TraceID 16字节跟踪ID
+type TraceID [16]byte
+
+// SpanID 8字节Span ID
+type SpanID [8]byte
+
+// String 十六进制表示
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid 全零ID无效
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String 十六进制表示
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid 全零ID无效
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanKind Span类型（取值与OTLP一致）
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// String 类型名称
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+// StatusCode Span状态（取值与OTLP一致）
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// SpanContext 跨进程传播的跟踪上下文
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string // 原样透传的tracestate
+	Remote     bool   // 是否来自上游请求头
+}
+
+// IsValid TraceID与SpanID均有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanEvent Span事件
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Time       time.Time              `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// SpanData 已结束Span的只读快照（供导出器使用）
+type SpanData struct {
+	Service       string                 `json:"service"`
+	Name          string                 `json:"name"`
+	Kind          SpanKind               `json:"-"`
+	KindName      string                 `json:"kind"`
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	StartTime     time.Time              `json:"start_time"`
+	EndTime       time.Time              `json:"end_time"`
+	DurationMS    float64                `json:"duration_ms"`
+	Status        StatusCode             `json:"status"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Events        []SpanEvent            `json:"events,omitempty"`
+}
+
+// Span 进行中的Span；所有方法对nil安全，未启用跟踪时可直接调用
+type Span struct {
+	mu sync.Mutex
+
+	tracer     *Tracer
+	context    SpanContext
+	parentID   SpanID
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	events     []SpanEvent
+	status     StatusCode
+	statusMsg  string
+	ended      bool
+}
+
+// SpanContext 获取Span的传播上下文
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetName 修改Span名称
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.name = name
+	}
+}
+
+// SetAttribute 设置属性
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.attributes[key] = value
+	}
+}
+
+// AddEvent 添加事件
+func (s *Span) AddEvent(name string, attributes map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.events = append(s.events, SpanEvent{Name: name, Time: time.Now(), Attributes: attributes})
+	}
+}
+
+// SetStatus 设置状态
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.status = code
+		s.statusMsg = message
+	}
+}
+
+// RecordError 记录错误并将状态置为Error
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", map[string]interface{}{"exception.message": err.Error()})
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束Span并提交导出（重复调用无效）
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	data := s.snapshotLocked()
+	s.mu.Unlock()
+
+	if s.context.Sampled && s.tracer.processor != nil {
+		s.tracer.processor.OnEnd(data)
+	}
+}
+
+func (s *Span) snapshotLocked() *SpanData {
+	attributes := make(map[string]interface{}, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+
+	data := &SpanData{
+		Service:       s.tracer.serviceName,
+		Name:          s.name,
+		Kind:          s.kind,
+		KindName:      s.kind.String(),
+		TraceID:       s.context.TraceID.String(),
+		SpanID:        s.context.SpanID.String(),
+		StartTime:     s.start,
+		EndTime:       s.end,
+		DurationMS:    float64(s.end.Sub(s.start).Microseconds()) / 1000,
+		Status:        s.status,
+		StatusMessage: s.statusMsg,
+		Attributes:    attributes,
+		Events:        append([]SpanEvent(nil), s.events...),
+	}
+	if s.parentID.IsValid() {
+		data.ParentSpanID = s.parentID.String()
+	}
+	return data
+}
+
+// Tracer 跟踪器
+type Tracer struct {
+	serviceName string
+	sampleRatio float64
+	processor   *BatchProcessor
+}
+
+// NewTracer 创建跟踪器；exporter为nil时只传播上下文不导出Span
+func NewTracer(serviceName string, sampleRatio float64, exporter Exporter) *Tracer {
+	if sampleRatio < 0 {
+		sampleRatio = 0
+	}
+	if sampleRatio > 1 {
+		sampleRatio = 1
+	}
+
+	tracer := &Tracer{
+		serviceName: serviceName,
+		sampleRatio: sampleRatio,
+	}
+	if exporter != nil {
+		tracer.processor = NewBatchProcessor(exporter, DefaultBatchSize, DefaultFlushInterval)
+	}
+	return tracer
+}
+
+// ServiceName 服务名
+func (t *Tracer) ServiceName() string {
+	return t.serviceName
+}
+
+// Start 以ctx中的Span（或远程上下文）为父级创建新Span
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		// 父级决定采样，保证整条链路要么全部记录要么全部丢弃
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.shouldSample(sc.TraceID)
+	}
+
+	span := &Span{
+		tracer:     t,
+		context:    sc,
+		parentID:   parent.SpanID,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// shouldSample 基于TraceID的比例采样（同一TraceID在各服务结果一致）
+func (t *Tracer) shouldSample(traceID TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	bound := uint64(t.sampleRatio * math.MaxUint64)
+	return binary.BigEndian.Uint64(traceID[8:]) < bound
+}
+
+// Shutdown 刷新并关闭导出器
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.processor == nil {
+		return nil
+	}
+	return t.processor.Shutdown(ctx)
+}
+
+type spanContextKey struct{}
+type remoteContextKey struct{}
+
+// ContextWithSpan 将Span放入context
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// ContextWithRemoteSpanContext 将上游传入的跟踪上下文放入context
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// SpanFromContext 获取context中的Span（不存在时返回nil）
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext 获取context中的跟踪上下文（本地Span优先）
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteContextKey{}).(SpanContext)
+	return sc
+}
+
+// TraceIDFromContext 获取跟踪ID字符串（不存在时返回空）
+func TraceIDFromContext(ctx context.Context) string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.TraceID.IsValid() {
+		return ""
+	}
+	return sc.TraceID.String()
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(fmt.Sprintf("生成TraceID失败: %v", err))
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(fmt.Sprintf("生成SpanID失败: %v", err))
+		}
+	}
+	return id
+}
@@ -2,11 +2,14 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/szjason72/zervigo/shared/core/tracing"
 )
 
 // HTTPClient HTTP客户端
@@ -17,10 +20,12 @@ type HTTPClient struct {
 }
 
 // NewHTTPClient 创建HTTP客户端
+// 出站请求经跟踪Transport发送：使用*Context方法传入请求上下文即可延续调用链（traceparent）
 func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
 	return &HTTPClient{
 		client: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: tracing.NewTransport(nil, nil),
 		},
 		baseURL: baseURL,
 		headers: make(map[string]string),
@@ -39,28 +44,53 @@ func (c *HTTPClient) SetHeaders(headers map[string]string) {
 	}
 }
 
+// SetTracer 指定跟踪器（默认使用tracing.DefaultTracer）
+func (c *HTTPClient) SetTracer(tracer *tracing.Tracer) {
+	c.client.Transport = tracing.NewTransport(nil, tracer)
+}
+
 // Get 发送GET请求
 func (c *HTTPClient) Get(path string, params map[string]string) (*HTTPResponse, error) {
-	return c.request("GET", path, params, nil)
+	return c.request(context.Background(), "GET", path, params, nil)
 }
 
 // Post 发送POST请求
 func (c *HTTPClient) Post(path string, data interface{}) (*HTTPResponse, error) {
-	return c.request("POST", path, nil, data)
+	return c.request(context.Background(), "POST", path, nil, data)
 }
 
 // Put 发送PUT请求
 func (c *HTTPClient) Put(path string, data interface{}) (*HTTPResponse, error) {
-	return c.request("PUT", path, nil, data)
+	return c.request(context.Background(), "PUT", path, nil, data)
 }
 
 // Delete 发送DELETE请求
 func (c *HTTPClient) Delete(path string) (*HTTPResponse, error) {
-	return c.request("DELETE", path, nil, nil)
+	return c.request(context.Background(), "DELETE", path, nil, nil)
+}
+
+// GetContext 发送GET请求（延续ctx中的跟踪上下文）
+func (c *HTTPClient) GetContext(ctx context.Context, path string, params map[string]string) (*HTTPResponse, error) {
+	return c.request(ctx, "GET", path, params, nil)
+}
+
+// PostContext 发送POST请求（延续ctx中的跟踪上下文）
+func (c *HTTPClient) PostContext(ctx context.Context, path string, data interface{}) (*HTTPResponse, error) {
+	return c.request(ctx, "POST", path, nil, data)
+}
+
+// PutContext 发送PUT请求（延续ctx中的跟踪上下文）
+func (c *HTTPClient) PutContext(ctx context.Context, path string, data interface{}) (*HTTPResponse, error) {
+	return c.request(ctx, "PUT", path, nil, data)
+}
+
+// DeleteContext 发送DELETE请求（延续ctx中的跟踪上下文）
+func (c *HTTPClient) DeleteContext(ctx context.Context, path string) (*HTTPResponse, error) {
+	return c.request(ctx, "DELETE", path, nil, nil)
 }
 
 // request 发送HTTP请求
-func (c *HTTPClient) request(method, path string, params map[string]string, data interface{}) (*HTTPResponse, error) {
+func (c *HTTPClient) request(ctx context.Context, method, path string, params map[string]string, data interface{}) (*HTTPResponse, error) {
 	url := c.baseURL + path
 
 	// 添加查询参数
@@ -85,7 +115,7 @@ func (c *HTTPClient) request(method, path string, params map[string]string, data
 		body = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}